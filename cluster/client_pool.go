@@ -1,6 +1,7 @@
 package cluster
 
 import (
+	"fmt"
 	"net"
 	"sync"
 
@@ -31,6 +32,16 @@ func (c *clientPool) getPool(nodeID uint64) (pool.Pool, bool) {
 	return p, ok
 }
 
+// removePool closes the pool of a node and removes it.
+func (c *clientPool) removePool(nodeID uint64) {
+	c.mu.Lock()
+	if p, ok := c.pool[nodeID]; ok {
+		p.Close()
+		delete(c.pool, nodeID)
+	}
+	c.mu.Unlock()
+}
+
 func (c *clientPool) size() int {
 	c.mu.RLock()
 	var size int
@@ -43,9 +54,12 @@ func (c *clientPool) size() int {
 
 func (c *clientPool) conn(nodeID uint64) (net.Conn, error) {
 	c.mu.RLock()
-	conn, err := c.pool[nodeID].Get()
+	p, ok := c.pool[nodeID]
 	c.mu.RUnlock()
-	return conn, err
+	if !ok {
+		return nil, fmt.Errorf("no connection pool for node %d", nodeID)
+	}
+	return p.Get()
 }
 
 func (c *clientPool) close() {
@@ -0,0 +1,62 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/golang/snappy"
+)
+
+// Compressions used for write batches. The compression used with a node is
+// negotiated when the first batch is sent to it.
+const (
+	compressionNone byte = iota
+	compressionSnappy
+)
+
+// compressions maps the configured names to compressions.
+var compressions = map[string]byte{
+	"none":   compressionNone,
+	"snappy": compressionSnappy,
+}
+
+// negotiateCompression returns the first of the offered compressions that is
+// supported. Returns compressionNone if none are supported.
+func negotiateCompression(offered []byte) byte {
+	for _, c := range offered {
+		switch c {
+		case compressionNone, compressionSnappy:
+			return c
+		}
+	}
+	return compressionNone
+}
+
+// encodeBatch compresses buf and prefixes it with the compression used.
+func encodeBatch(compression byte, buf []byte) ([]byte, error) {
+	switch compression {
+	case compressionNone:
+		return append([]byte{compressionNone}, buf...), nil
+	case compressionSnappy:
+		return append([]byte{compressionSnappy}, snappy.Encode(nil, buf)...), nil
+	default:
+		return nil, fmt.Errorf("unknown compression: %d", compression)
+	}
+}
+
+// decodeBatch returns the decompressed contents of a buffer encoded by
+// encodeBatch.
+func decodeBatch(buf []byte) ([]byte, error) {
+	if len(buf) == 0 {
+		return nil, errors.New("empty batch")
+	}
+
+	switch buf[0] {
+	case compressionNone:
+		return buf[1:], nil
+	case compressionSnappy:
+		return snappy.Decode(nil, buf[1:])
+	default:
+		return nil, fmt.Errorf("unknown compression: %d", buf[0])
+	}
+}
@@ -0,0 +1,46 @@
+package cluster
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Ensure batches can be encoded and decoded with each compression.
+func TestEncodeBatch(t *testing.T) {
+	buf := bytes.Repeat([]byte("cpu,host=server01 value=100 0\n"), 100)
+	for name, compression := range compressions {
+		b, err := encodeBatch(compression, buf)
+		if err != nil {
+			t.Fatalf("%s: encode: %s", name, err)
+		} else if b[0] != compression {
+			t.Fatalf("%s: unexpected compression: %d", name, b[0])
+		}
+
+		if b, err = decodeBatch(b); err != nil {
+			t.Fatalf("%s: decode: %s", name, err)
+		} else if !bytes.Equal(b, buf) {
+			t.Fatalf("%s: unexpected data: %q", name, b)
+		}
+	}
+
+	if _, err := decodeBatch([]byte{255}); err == nil || err.Error() != "unknown compression: 255" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure the first supported compression is chosen.
+func TestNegotiateCompression(t *testing.T) {
+	for i, tt := range []struct {
+		offered []byte
+		exp     byte
+	}{
+		{offered: []byte{compressionSnappy, compressionNone}, exp: compressionSnappy},
+		{offered: []byte{255, compressionNone}, exp: compressionNone},
+		{offered: []byte{255}, exp: compressionNone},
+		{offered: nil, exp: compressionNone},
+	} {
+		if c := negotiateCompression(tt.offered); c != tt.exp {
+			t.Errorf("%d. unexpected compression: got %d, exp %d", i, c, tt.exp)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package cluster
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/influxdb/influxdb/toml"
@@ -15,6 +17,18 @@ const (
 
 	// DefaultShardMapperTimeout is the default timeout set on shard mappers.
 	DefaultShardMapperTimeout = 5 * time.Second
+
+	// DefaultWriteBatchSize is the default maximum number of points sent to
+	// a node in a single batch.
+	DefaultWriteBatchSize = 5000
+
+	// DefaultWriteMaxInFlight is the default maximum number of batches being
+	// sent to a node at once.
+	DefaultWriteMaxInFlight = 4
+
+	// DefaultWriteCompression is the default compression requested for
+	// batches sent to other nodes.
+	DefaultWriteCompression = "snappy"
 )
 
 // Config represents the configuration for the clustering service.
@@ -23,6 +37,9 @@ type Config struct {
 	WriteTimeout            toml.Duration `toml:"write-timeout"`
 	ShardWriterTimeout      toml.Duration `toml:"shard-writer-timeout"`
 	ShardMapperTimeout      toml.Duration `toml:"shard-mapper-timeout"`
+	WriteBatchSize          int           `toml:"write-batch-size"`
+	WriteMaxInFlight        int           `toml:"write-max-in-flight"`
+	WriteCompression        string        `toml:"write-compression"`
 }
 
 // NewConfig returns an instance of Config with defaults.
@@ -31,5 +48,22 @@ func NewConfig() Config {
 		WriteTimeout:       toml.Duration(DefaultWriteTimeout),
 		ShardWriterTimeout: toml.Duration(DefaultShardWriterTimeout),
 		ShardMapperTimeout: toml.Duration(DefaultShardMapperTimeout),
+		WriteBatchSize:     DefaultWriteBatchSize,
+		WriteMaxInFlight:   DefaultWriteMaxInFlight,
+		WriteCompression:   DefaultWriteCompression,
+	}
+}
+
+// Validate returns an error if the config is invalid.
+func (c Config) Validate() error {
+	if c.WriteBatchSize <= 0 {
+		return errors.New("write-batch-size must be positive")
+	}
+	if c.WriteMaxInFlight <= 0 {
+		return errors.New("write-max-in-flight must be positive")
+	}
+	if _, ok := compressions[c.WriteCompression]; !ok {
+		return fmt.Errorf("unknown write-compression: %s", c.WriteCompression)
 	}
+	return nil
 }
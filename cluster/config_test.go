@@ -14,6 +14,9 @@ func TestConfig_Parse(t *testing.T) {
 	if _, err := toml.Decode(`
 shard-writer-timeout = "10s"
 write-timeout = "20s"
+write-batch-size = 100
+write-max-in-flight = 2
+write-compression = "none"
 `, &c); err != nil {
 		t.Fatal(err)
 	}
@@ -23,5 +26,23 @@ write-timeout = "20s"
 		t.Fatalf("unexpected shard-writer timeout: %s", c.ShardWriterTimeout)
 	} else if time.Duration(c.WriteTimeout) != 20*time.Second {
 		t.Fatalf("unexpected write timeout s: %s", c.WriteTimeout)
+	} else if c.WriteBatchSize != 100 {
+		t.Fatalf("unexpected write batch size: %d", c.WriteBatchSize)
+	} else if c.WriteMaxInFlight != 2 {
+		t.Fatalf("unexpected write max in flight: %d", c.WriteMaxInFlight)
+	} else if c.WriteCompression != "none" {
+		t.Fatalf("unexpected write compression: %s", c.WriteCompression)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	c := cluster.NewConfig()
+	if err := c.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	c.WriteCompression = "gzip"
+	if err := c.Validate(); err == nil || err.Error() != "unknown write-compression: gzip" {
+		t.Fatalf("unexpected error: %v", err)
 	}
 }
@@ -0,0 +1,139 @@
+package cluster
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultHealthRetryInterval is the default time a node is considered
+// unhealthy after a failed request.
+const DefaultHealthRetryInterval = 10 * time.Second
+
+// HealthTracker tracks the latency and availability of remote nodes as seen
+// by the requests made to them. It is shared by the ShardMapper and the
+// ShardWriter so reads and writes both contribute to the picture of a node.
+type HealthTracker struct {
+	mu    sync.RWMutex
+	nodes map[uint64]*nodeHealth
+
+	// RetryInterval is the time a node is considered unhealthy after a
+	// failed request.
+	RetryInterval time.Duration
+
+	now func() time.Time
+}
+
+// nodeHealth is the state of a single node.
+type nodeHealth struct {
+	latency  time.Duration // moving average of request latency
+	failedAt time.Time     // time of the last failure, zero if it succeeded since
+}
+
+// NewHealthTracker returns a new instance of HealthTracker.
+func NewHealthTracker() *HealthTracker {
+	return &HealthTracker{
+		nodes:         make(map[uint64]*nodeHealth),
+		RetryInterval: DefaultHealthRetryInterval,
+		now:           time.Now,
+	}
+}
+
+// Success records a successful request to a node that took d.
+func (h *HealthTracker) Success(nodeID uint64, d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	n := h.node(nodeID)
+	if n.latency == 0 {
+		n.latency = d
+	} else {
+		// Weight recent requests more heavily than older ones.
+		n.latency += (d - n.latency) / 4
+	}
+	n.failedAt = time.Time{}
+}
+
+// Failure records a failed request to a node.
+func (h *HealthTracker) Failure(nodeID uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.node(nodeID).failedAt = h.now()
+}
+
+// Healthy returns true if the node hasn't failed within the retry interval.
+// Nodes without any recorded requests are healthy.
+func (h *HealthTracker) Healthy(nodeID uint64) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.healthy(h.nodes[nodeID])
+}
+
+// Latency returns the average request latency of a node. Returns zero if no
+// request to the node has succeeded.
+func (h *HealthTracker) Latency(nodeID uint64) time.Duration {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if n := h.nodes[nodeID]; n != nil {
+		return n.latency
+	}
+	return 0
+}
+
+// Order returns the node IDs in the order they should be tried. Healthy nodes
+// come before unhealthy ones and faster nodes before slower ones. Nodes
+// without a known latency are tried first so they get measured. Nodes that
+// are otherwise equal are returned in a random order to spread the load.
+func (h *HealthTracker) Order(nodeIDs []uint64) []uint64 {
+	h.mu.RLock()
+	a := make(rankedNodes, len(nodeIDs))
+	for i, j := range rand.Perm(len(nodeIDs)) {
+		n := h.nodes[nodeIDs[j]]
+		a[i] = rankedNode{id: nodeIDs[j], healthy: h.healthy(n)}
+		if n != nil {
+			a[i].latency = n.latency
+		}
+	}
+	h.mu.RUnlock()
+
+	sort.Stable(a)
+
+	ids := make([]uint64, len(a))
+	for i := range a {
+		ids[i] = a[i].id
+	}
+	return ids
+}
+
+// node returns the state of a node, creating it if it doesn't exist.
+func (h *HealthTracker) node(nodeID uint64) *nodeHealth {
+	n := h.nodes[nodeID]
+	if n == nil {
+		n = &nodeHealth{}
+		h.nodes[nodeID] = n
+	}
+	return n
+}
+
+func (h *HealthTracker) healthy(n *nodeHealth) bool {
+	return n == nil || n.failedAt.IsZero() || h.now().Sub(n.failedAt) >= h.RetryInterval
+}
+
+type rankedNode struct {
+	id      uint64
+	healthy bool
+	latency time.Duration
+}
+
+// rankedNodes sorts nodes by health and then by latency.
+type rankedNodes []rankedNode
+
+func (a rankedNodes) Len() int      { return len(a) }
+func (a rankedNodes) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a rankedNodes) Less(i, j int) bool {
+	if a[i].healthy != a[j].healthy {
+		return a[i].healthy
+	}
+	return a[i].latency < a[j].latency
+}
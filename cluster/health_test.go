@@ -0,0 +1,59 @@
+package cluster
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Ensure a node is unhealthy for the retry interval after a failure.
+func TestHealthTracker_Healthy(t *testing.T) {
+	now := time.Unix(0, 0)
+	h := NewHealthTracker()
+	h.now = func() time.Time { return now }
+
+	if !h.Healthy(1) {
+		t.Fatal("expected unknown node to be healthy")
+	}
+
+	h.Failure(1)
+	if h.Healthy(1) {
+		t.Fatal("expected failed node to be unhealthy")
+	}
+
+	now = now.Add(h.RetryInterval)
+	if !h.Healthy(1) {
+		t.Fatal("expected node to be healthy after retry interval")
+	}
+
+	h.Failure(1)
+	h.Success(1, time.Millisecond)
+	if !h.Healthy(1) {
+		t.Fatal("expected node to be healthy after success")
+	}
+}
+
+// Ensure the latency is a moving average of the request latencies.
+func TestHealthTracker_Latency(t *testing.T) {
+	h := NewHealthTracker()
+	h.Success(1, 8*time.Millisecond)
+	h.Success(1, 16*time.Millisecond)
+	if d := h.Latency(1); d != 10*time.Millisecond {
+		t.Fatalf("unexpected latency: %s", d)
+	} else if d := h.Latency(2); d != 0 {
+		t.Fatalf("unexpected latency: %s", d)
+	}
+}
+
+// Ensure nodes are ordered by health and then by latency.
+func TestHealthTracker_Order(t *testing.T) {
+	h := NewHealthTracker()
+	h.Success(1, 30*time.Millisecond)
+	h.Success(2, 10*time.Millisecond)
+	h.Success(3, time.Millisecond)
+	h.Failure(3)
+
+	if ids := h.Order([]uint64{1, 2, 3, 4}); !reflect.DeepEqual(ids, []uint64{4, 2, 1, 3}) {
+		t.Fatalf("unexpected order: %v", ids)
+	}
+}
@@ -11,8 +11,19 @@ It is generated from these files:
 It has these top-level messages:
 	WriteShardRequest
 	WriteShardResponse
+	WriteShardBatchRequest
+	WriteShardBatchResponse
 	MapShardRequest
 	MapShardResponse
+	Tag
+	FieldValue
+	MapperValue
+	MapperChunk
+	ShardDigestRequest
+	SeriesDigest
+	ShardDigestResponse
+	SeriesPointsRequest
+	SeriesPointsResponse
 */
 package internal
 
@@ -71,10 +82,59 @@ func (m *WriteShardResponse) GetMessage() string {
 	return ""
 }
 
+type WriteShardBatchRequest struct {
+	Requests         []*WriteShardRequest `protobuf:"bytes,1,rep" json:"Requests,omitempty"`
+	XXX_unrecognized []byte               `json:"-"`
+}
+
+func (m *WriteShardBatchRequest) Reset()         { *m = WriteShardBatchRequest{} }
+func (m *WriteShardBatchRequest) String() string { return proto.CompactTextString(m) }
+func (*WriteShardBatchRequest) ProtoMessage()    {}
+
+func (m *WriteShardBatchRequest) GetRequests() []*WriteShardRequest {
+	if m != nil {
+		return m.Requests
+	}
+	return nil
+}
+
+type WriteShardBatchResponse struct {
+	Code             *int32                `protobuf:"varint,1,req" json:"Code,omitempty"`
+	Message          *string               `protobuf:"bytes,2,opt" json:"Message,omitempty"`
+	Responses        []*WriteShardResponse `protobuf:"bytes,3,rep" json:"Responses,omitempty"`
+	XXX_unrecognized []byte                `json:"-"`
+}
+
+func (m *WriteShardBatchResponse) Reset()         { *m = WriteShardBatchResponse{} }
+func (m *WriteShardBatchResponse) String() string { return proto.CompactTextString(m) }
+func (*WriteShardBatchResponse) ProtoMessage()    {}
+
+func (m *WriteShardBatchResponse) GetCode() int32 {
+	if m != nil && m.Code != nil {
+		return *m.Code
+	}
+	return 0
+}
+
+func (m *WriteShardBatchResponse) GetMessage() string {
+	if m != nil && m.Message != nil {
+		return *m.Message
+	}
+	return ""
+}
+
+func (m *WriteShardBatchResponse) GetResponses() []*WriteShardResponse {
+	if m != nil {
+		return m.Responses
+	}
+	return nil
+}
+
 type MapShardRequest struct {
 	ShardID          *uint64 `protobuf:"varint,1,req" json:"ShardID,omitempty"`
 	Query            *string `protobuf:"bytes,2,req" json:"Query,omitempty"`
 	ChunkSize        *int32  `protobuf:"varint,3,req" json:"ChunkSize,omitempty"`
+	Credit           *uint32 `protobuf:"varint,4,opt" json:"Credit,omitempty"`
 	XXX_unrecognized []byte  `json:"-"`
 }
 
@@ -103,6 +163,13 @@ func (m *MapShardRequest) GetChunkSize() int32 {
 	return 0
 }
 
+func (m *MapShardRequest) GetCredit() uint32 {
+	if m != nil && m.Credit != nil {
+		return *m.Credit
+	}
+	return 0
+}
+
 type MapShardResponse struct {
 	Code             *int32   `protobuf:"varint,1,req" json:"Code,omitempty"`
 	Message          *string  `protobuf:"bytes,2,opt" json:"Message,omitempty"`
@@ -151,5 +218,357 @@ func (m *MapShardResponse) GetFields() []string {
 	return nil
 }
 
+type Tag struct {
+	Key              *string `protobuf:"bytes,1,req" json:"Key,omitempty"`
+	Value            *string `protobuf:"bytes,2,req" json:"Value,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Tag) Reset()         { *m = Tag{} }
+func (m *Tag) String() string { return proto.CompactTextString(m) }
+func (*Tag) ProtoMessage()    {}
+
+func (m *Tag) GetKey() string {
+	if m != nil && m.Key != nil {
+		return *m.Key
+	}
+	return ""
+}
+
+func (m *Tag) GetValue() string {
+	if m != nil && m.Value != nil {
+		return *m.Value
+	}
+	return ""
+}
+
+type FieldValue struct {
+	Name             *string  `protobuf:"bytes,1,opt" json:"Name,omitempty"`
+	FloatValue       *float64 `protobuf:"fixed64,2,opt" json:"FloatValue,omitempty"`
+	IntegerValue     *int64   `protobuf:"varint,3,opt" json:"IntegerValue,omitempty"`
+	StringValue      *string  `protobuf:"bytes,4,opt" json:"StringValue,omitempty"`
+	BooleanValue     *bool    `protobuf:"varint,5,opt" json:"BooleanValue,omitempty"`
+	UnsignedValue    *uint64  `protobuf:"varint,6,opt" json:"UnsignedValue,omitempty"`
+	TimeValue        *int64   `protobuf:"varint,7,opt" json:"TimeValue,omitempty"`
+	DurationValue    *int64   `protobuf:"varint,8,opt" json:"DurationValue,omitempty"`
+	XXX_unrecognized []byte   `json:"-"`
+}
+
+func (m *FieldValue) Reset()         { *m = FieldValue{} }
+func (m *FieldValue) String() string { return proto.CompactTextString(m) }
+func (*FieldValue) ProtoMessage()    {}
+
+func (m *FieldValue) GetName() string {
+	if m != nil && m.Name != nil {
+		return *m.Name
+	}
+	return ""
+}
+
+func (m *FieldValue) GetFloatValue() float64 {
+	if m != nil && m.FloatValue != nil {
+		return *m.FloatValue
+	}
+	return 0
+}
+
+func (m *FieldValue) GetIntegerValue() int64 {
+	if m != nil && m.IntegerValue != nil {
+		return *m.IntegerValue
+	}
+	return 0
+}
+
+func (m *FieldValue) GetStringValue() string {
+	if m != nil && m.StringValue != nil {
+		return *m.StringValue
+	}
+	return ""
+}
+
+func (m *FieldValue) GetBooleanValue() bool {
+	if m != nil && m.BooleanValue != nil {
+		return *m.BooleanValue
+	}
+	return false
+}
+
+func (m *FieldValue) GetUnsignedValue() uint64 {
+	if m != nil && m.UnsignedValue != nil {
+		return *m.UnsignedValue
+	}
+	return 0
+}
+
+func (m *FieldValue) GetTimeValue() int64 {
+	if m != nil && m.TimeValue != nil {
+		return *m.TimeValue
+	}
+	return 0
+}
+
+func (m *FieldValue) GetDurationValue() int64 {
+	if m != nil && m.DurationValue != nil {
+		return *m.DurationValue
+	}
+	return 0
+}
+
+type MapperValue struct {
+	Time             *int64        `protobuf:"varint,1,req" json:"Time,omitempty"`
+	Tags             []*Tag        `protobuf:"bytes,2,rep" json:"Tags,omitempty"`
+	Value            *FieldValue   `protobuf:"bytes,3,opt" json:"Value,omitempty"`
+	Fields           []*FieldValue `protobuf:"bytes,4,rep" json:"Fields,omitempty"`
+	AggData          [][]byte      `protobuf:"bytes,5,rep" json:"AggData,omitempty"`
+	XXX_unrecognized []byte        `json:"-"`
+}
+
+func (m *MapperValue) Reset()         { *m = MapperValue{} }
+func (m *MapperValue) String() string { return proto.CompactTextString(m) }
+func (*MapperValue) ProtoMessage()    {}
+
+func (m *MapperValue) GetTime() int64 {
+	if m != nil && m.Time != nil {
+		return *m.Time
+	}
+	return 0
+}
+
+func (m *MapperValue) GetTags() []*Tag {
+	if m != nil {
+		return m.Tags
+	}
+	return nil
+}
+
+func (m *MapperValue) GetValue() *FieldValue {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+func (m *MapperValue) GetFields() []*FieldValue {
+	if m != nil {
+		return m.Fields
+	}
+	return nil
+}
+
+func (m *MapperValue) GetAggData() [][]byte {
+	if m != nil {
+		return m.AggData
+	}
+	return nil
+}
+
+type MapperChunk struct {
+	Name             *string        `protobuf:"bytes,1,req" json:"Name,omitempty"`
+	Tags             []*Tag         `protobuf:"bytes,2,rep" json:"Tags,omitempty"`
+	Fields           []string       `protobuf:"bytes,3,rep" json:"Fields,omitempty"`
+	Values           []*MapperValue `protobuf:"bytes,4,rep" json:"Values,omitempty"`
+	XXX_unrecognized []byte         `json:"-"`
+}
+
+func (m *MapperChunk) Reset()         { *m = MapperChunk{} }
+func (m *MapperChunk) String() string { return proto.CompactTextString(m) }
+func (*MapperChunk) ProtoMessage()    {}
+
+func (m *MapperChunk) GetName() string {
+	if m != nil && m.Name != nil {
+		return *m.Name
+	}
+	return ""
+}
+
+func (m *MapperChunk) GetTags() []*Tag {
+	if m != nil {
+		return m.Tags
+	}
+	return nil
+}
+
+func (m *MapperChunk) GetFields() []string {
+	if m != nil {
+		return m.Fields
+	}
+	return nil
+}
+
+func (m *MapperChunk) GetValues() []*MapperValue {
+	if m != nil {
+		return m.Values
+	}
+	return nil
+}
+
+type ShardDigestRequest struct {
+	ShardID          *uint64 `protobuf:"varint,1,req" json:"ShardID,omitempty"`
+	Min              *int64  `protobuf:"varint,2,req" json:"Min,omitempty"`
+	Max              *int64  `protobuf:"varint,3,req" json:"Max,omitempty"`
+	Depth            *uint32 `protobuf:"varint,4,req" json:"Depth,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *ShardDigestRequest) Reset()         { *m = ShardDigestRequest{} }
+func (m *ShardDigestRequest) String() string { return proto.CompactTextString(m) }
+func (*ShardDigestRequest) ProtoMessage()    {}
+
+func (m *ShardDigestRequest) GetShardID() uint64 {
+	if m != nil && m.ShardID != nil {
+		return *m.ShardID
+	}
+	return 0
+}
+
+func (m *ShardDigestRequest) GetMin() int64 {
+	if m != nil && m.Min != nil {
+		return *m.Min
+	}
+	return 0
+}
+
+func (m *ShardDigestRequest) GetMax() int64 {
+	if m != nil && m.Max != nil {
+		return *m.Max
+	}
+	return 0
+}
+
+func (m *ShardDigestRequest) GetDepth() uint32 {
+	if m != nil && m.Depth != nil {
+		return *m.Depth
+	}
+	return 0
+}
+
+type SeriesDigest struct {
+	Key              *string  `protobuf:"bytes,1,req" json:"Key,omitempty"`
+	Hashes           []uint64 `protobuf:"varint,2,rep" json:"Hashes,omitempty"`
+	XXX_unrecognized []byte   `json:"-"`
+}
+
+func (m *SeriesDigest) Reset()         { *m = SeriesDigest{} }
+func (m *SeriesDigest) String() string { return proto.CompactTextString(m) }
+func (*SeriesDigest) ProtoMessage()    {}
+
+func (m *SeriesDigest) GetKey() string {
+	if m != nil && m.Key != nil {
+		return *m.Key
+	}
+	return ""
+}
+
+func (m *SeriesDigest) GetHashes() []uint64 {
+	if m != nil {
+		return m.Hashes
+	}
+	return nil
+}
+
+type ShardDigestResponse struct {
+	Code             *int32          `protobuf:"varint,1,req" json:"Code,omitempty"`
+	Message          *string         `protobuf:"bytes,2,opt" json:"Message,omitempty"`
+	Series           []*SeriesDigest `protobuf:"bytes,3,rep" json:"Series,omitempty"`
+	XXX_unrecognized []byte          `json:"-"`
+}
+
+func (m *ShardDigestResponse) Reset()         { *m = ShardDigestResponse{} }
+func (m *ShardDigestResponse) String() string { return proto.CompactTextString(m) }
+func (*ShardDigestResponse) ProtoMessage()    {}
+
+func (m *ShardDigestResponse) GetCode() int32 {
+	if m != nil && m.Code != nil {
+		return *m.Code
+	}
+	return 0
+}
+
+func (m *ShardDigestResponse) GetMessage() string {
+	if m != nil && m.Message != nil {
+		return *m.Message
+	}
+	return ""
+}
+
+func (m *ShardDigestResponse) GetSeries() []*SeriesDigest {
+	if m != nil {
+		return m.Series
+	}
+	return nil
+}
+
+type SeriesPointsRequest struct {
+	ShardID          *uint64 `protobuf:"varint,1,req" json:"ShardID,omitempty"`
+	Key              *string `protobuf:"bytes,2,req" json:"Key,omitempty"`
+	Min              *int64  `protobuf:"varint,3,req" json:"Min,omitempty"`
+	Max              *int64  `protobuf:"varint,4,req" json:"Max,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *SeriesPointsRequest) Reset()         { *m = SeriesPointsRequest{} }
+func (m *SeriesPointsRequest) String() string { return proto.CompactTextString(m) }
+func (*SeriesPointsRequest) ProtoMessage()    {}
+
+func (m *SeriesPointsRequest) GetShardID() uint64 {
+	if m != nil && m.ShardID != nil {
+		return *m.ShardID
+	}
+	return 0
+}
+
+func (m *SeriesPointsRequest) GetKey() string {
+	if m != nil && m.Key != nil {
+		return *m.Key
+	}
+	return ""
+}
+
+func (m *SeriesPointsRequest) GetMin() int64 {
+	if m != nil && m.Min != nil {
+		return *m.Min
+	}
+	return 0
+}
+
+func (m *SeriesPointsRequest) GetMax() int64 {
+	if m != nil && m.Max != nil {
+		return *m.Max
+	}
+	return 0
+}
+
+type SeriesPointsResponse struct {
+	Code             *int32   `protobuf:"varint,1,req" json:"Code,omitempty"`
+	Message          *string  `protobuf:"bytes,2,opt" json:"Message,omitempty"`
+	Points           [][]byte `protobuf:"bytes,3,rep" json:"Points,omitempty"`
+	XXX_unrecognized []byte   `json:"-"`
+}
+
+func (m *SeriesPointsResponse) Reset()         { *m = SeriesPointsResponse{} }
+func (m *SeriesPointsResponse) String() string { return proto.CompactTextString(m) }
+func (*SeriesPointsResponse) ProtoMessage()    {}
+
+func (m *SeriesPointsResponse) GetCode() int32 {
+	if m != nil && m.Code != nil {
+		return *m.Code
+	}
+	return 0
+}
+
+func (m *SeriesPointsResponse) GetMessage() string {
+	if m != nil && m.Message != nil {
+		return *m.Message
+	}
+	return ""
+}
+
+func (m *SeriesPointsResponse) GetPoints() [][]byte {
+	if m != nil {
+		return m.Points
+	}
+	return nil
+}
+
 func init() {
 }
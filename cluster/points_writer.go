@@ -209,11 +209,15 @@ func (w *PointsWriter) WritePoints(p *WritePointsRequest) error {
 	w.statMap.Add(statWriteReq, 1)
 	w.statMap.Add(statPointWriteReq, int64(len(p.Points)))
 
+	db, err := w.MetaStore.Database(p.Database)
+	if err != nil {
+		return err
+	} else if db != nil && db.ReadOnly {
+		return influxdb.ErrDatabaseReadOnly
+	}
+
 	if p.RetentionPolicy == "" {
-		db, err := w.MetaStore.Database(p.Database)
-		if err != nil {
-			return err
-		} else if db == nil {
+		if db == nil {
 			return influxdb.ErrDatabaseNotFound(p.Database)
 		}
 		p.RetentionPolicy = db.DefaultRetentionPolicy
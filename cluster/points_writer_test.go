@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/influxdb/influxdb"
 	"github.com/influxdb/influxdb/cluster"
 	"github.com/influxdb/influxdb/meta"
 	"github.com/influxdb/influxdb/models"
@@ -328,6 +329,30 @@ func TestPointsWriter_WritePoints(t *testing.T) {
 	}
 }
 
+// Ensures the points writer rejects writes to a read-only database.
+func TestPointsWriter_WritePoints_ReadOnly(t *testing.T) {
+	ms := NewMetaStore()
+	ms.DatabaseFn = func(database string) (*meta.DatabaseInfo, error) {
+		return &meta.DatabaseInfo{Name: database, DefaultRetentionPolicy: "myp", ReadOnly: true}, nil
+	}
+	ms.NodeIDFn = func() uint64 { return 1 }
+
+	c := cluster.NewPointsWriter()
+	c.MetaStore = ms
+	c.TSDBStore = &fakeStore{
+		WriteFn: func(shardID uint64, points []models.Point) error {
+			t.Fatal("unexpected shard write")
+			return nil
+		},
+	}
+
+	pr := &cluster.WritePointsRequest{Database: "mydb"}
+	pr.AddPoint("cpu", 1.0, time.Unix(0, 0), nil)
+	if err := c.WritePoints(pr); err != influxdb.ErrDatabaseReadOnly {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
 var shardID uint64
 
 type fakeShardWriter struct {
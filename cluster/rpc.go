@@ -1,12 +1,15 @@
 package cluster
 
 import (
+	"encoding/json"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/gogo/protobuf/proto"
 	"github.com/influxdb/influxdb/cluster/internal"
 	"github.com/influxdb/influxdb/models"
+	"github.com/influxdb/influxdb/tsdb"
 )
 
 //go:generate protoc --gogo_out=. internal/data.proto
@@ -34,6 +37,14 @@ func (m *MapShardRequest) SetQuery(query string) { m.pb.Query = &query }
 // SetChunkSize sets the Shard map request's chunk size
 func (m *MapShardRequest) SetChunkSize(chunkSize int32) { m.pb.ChunkSize = &chunkSize }
 
+// Credit returns the number of chunks that can be streamed before waiting for
+// more credit.
+func (m *MapShardRequest) Credit() int { return int(m.pb.GetCredit()) }
+
+// SetCredit sets the number of chunks that can be streamed before waiting
+// for more credit.
+func (m *MapShardRequest) SetCredit(credit int) { m.pb.Credit = proto.Uint32(uint32(credit)) }
+
 // MarshalBinary encodes the object to a binary format.
 func (m *MapShardRequest) MarshalBinary() ([]byte, error) {
 	return proto.Marshal(&m.pb)
@@ -103,6 +114,195 @@ func (r *MapShardResponse) UnmarshalBinary(buf []byte) error {
 	return nil
 }
 
+// MapperChunk represents a chunk of mapper output sent by the streaming
+// mapper protocol.
+type MapperChunk struct {
+	pb internal.MapperChunk
+}
+
+// SetOutput encodes mapper output into the chunk. Field values are encoded by
+// type. Aggregate values are encoded by the map functions that produced them.
+func (c *MapperChunk) SetOutput(mo *tsdb.MapperOutput) error {
+	c.pb.Name = proto.String(mo.Name)
+	c.pb.Tags = marshalTags(mo.Tags)
+	c.pb.Fields = mo.Fields
+	c.pb.Values = make([]*internal.MapperValue, len(mo.Values))
+	for i, v := range mo.Values {
+		pb := &internal.MapperValue{
+			Time: proto.Int64(v.Time),
+			Tags: marshalTags(v.Tags),
+		}
+
+		switch value := v.Value.(type) {
+		case []interface{}:
+			for _, a := range value {
+				b, err := json.Marshal(a)
+				if err != nil {
+					return err
+				}
+				pb.AggData = append(pb.AggData, b)
+			}
+		case map[string]interface{}:
+			names := make([]string, 0, len(value))
+			for name := range value {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			for _, name := range names {
+				f, err := marshalFieldValue(value[name])
+				if err != nil {
+					return err
+				}
+				f.Name = proto.String(name)
+				pb.Fields = append(pb.Fields, f)
+			}
+		default:
+			f, err := marshalFieldValue(value)
+			if err != nil {
+				return err
+			}
+			pb.Value = f
+		}
+		c.pb.Values[i] = pb
+	}
+	return nil
+}
+
+// Output decodes the mapper output in the chunk. Aggregate values are decoded
+// by the unmarshal functions of the statement's function calls.
+func (c *MapperChunk) Output(unmarshallers []tsdb.UnmarshalFunc) (*tsdb.MapperOutput, error) {
+	mo := &tsdb.MapperOutput{
+		Name:   c.pb.GetName(),
+		Tags:   unmarshalTags(c.pb.GetTags()),
+		Fields: c.pb.GetFields(),
+	}
+
+	for _, pb := range c.pb.GetValues() {
+		v := &tsdb.MapperValue{
+			Time: pb.GetTime(),
+			Tags: unmarshalTags(pb.GetTags()),
+		}
+
+		if data := pb.GetAggData(); len(data) > 0 {
+			if len(data) > len(unmarshallers) {
+				return nil, fmt.Errorf("expected %d aggregate values but got %d", len(unmarshallers), len(data))
+			}
+
+			values := make([]interface{}, len(data))
+			for i, b := range data {
+				a, err := unmarshallers[i](b)
+				if err != nil {
+					return nil, err
+				}
+				values[i] = a
+			}
+			v.Value = values
+		} else if fields := pb.GetFields(); len(fields) > 0 {
+			values := make(map[string]interface{}, len(fields))
+			for _, f := range fields {
+				values[f.GetName()] = unmarshalFieldValue(f)
+			}
+			v.Value = values
+		} else {
+			v.Value = unmarshalFieldValue(pb.GetValue())
+		}
+		mo.Values = append(mo.Values, v)
+	}
+	return mo, nil
+}
+
+// MarshalBinary encodes the object to a binary format.
+func (c *MapperChunk) MarshalBinary() ([]byte, error) {
+	return proto.Marshal(&c.pb)
+}
+
+// UnmarshalBinary populates MapperChunk from a binary format.
+func (c *MapperChunk) UnmarshalBinary(buf []byte) error {
+	return proto.Unmarshal(buf, &c.pb)
+}
+
+// marshalTags encodes tags sorted by key.
+func marshalTags(tags map[string]string) []*internal.Tag {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	a := make([]*internal.Tag, len(keys))
+	for i, k := range keys {
+		a[i] = &internal.Tag{Key: proto.String(k), Value: proto.String(tags[k])}
+	}
+	return a
+}
+
+func unmarshalTags(a []*internal.Tag) map[string]string {
+	if len(a) == 0 {
+		return nil
+	}
+
+	tags := make(map[string]string, len(a))
+	for _, t := range a {
+		tags[t.GetKey()] = t.GetValue()
+	}
+	return tags
+}
+
+// marshalFieldValue encodes a field value. A nil value is encoded without
+// any value set, times as nanoseconds since the epoch and durations as
+// nanoseconds.
+func marshalFieldValue(v interface{}) (*internal.FieldValue, error) {
+	switch v := v.(type) {
+	case nil:
+		return &internal.FieldValue{}, nil
+	case float64:
+		return &internal.FieldValue{FloatValue: proto.Float64(v)}, nil
+	case int64:
+		return &internal.FieldValue{IntegerValue: proto.Int64(v)}, nil
+	case string:
+		return &internal.FieldValue{StringValue: proto.String(v)}, nil
+	case bool:
+		return &internal.FieldValue{BooleanValue: proto.Bool(v)}, nil
+	case uint64:
+		return &internal.FieldValue{UnsignedValue: proto.Uint64(v)}, nil
+	case time.Time:
+		return &internal.FieldValue{TimeValue: proto.Int64(v.UnixNano())}, nil
+	case time.Duration:
+		return &internal.FieldValue{DurationValue: proto.Int64(int64(v))}, nil
+	default:
+		return nil, fmt.Errorf("unsupported field value type: %T", v)
+	}
+}
+
+// unmarshalFieldValue decodes a field value. Times are decoded in UTC.
+func unmarshalFieldValue(f *internal.FieldValue) interface{} {
+	switch {
+	case f == nil:
+		return nil
+	case f.FloatValue != nil:
+		return f.GetFloatValue()
+	case f.IntegerValue != nil:
+		return f.GetIntegerValue()
+	case f.StringValue != nil:
+		return f.GetStringValue()
+	case f.BooleanValue != nil:
+		return f.GetBooleanValue()
+	case f.UnsignedValue != nil:
+		return f.GetUnsignedValue()
+	case f.TimeValue != nil:
+		return time.Unix(0, f.GetTimeValue()).UTC()
+	case f.DurationValue != nil:
+		return time.Duration(f.GetDurationValue())
+	default:
+		return nil
+	}
+}
+
 // WritePointsRequest represents a request to write point data to the cluster
 type WritePointsRequest struct {
 	Database         string
@@ -203,3 +403,253 @@ func (w *WriteShardResponse) UnmarshalBinary(buf []byte) error {
 	}
 	return nil
 }
+
+// WriteShardBatchRequest represents a request to write to several shards at once.
+type WriteShardBatchRequest struct {
+	pb internal.WriteShardBatchRequest
+}
+
+// AddRequest adds a write of points to a shard to the batch.
+func (r *WriteShardBatchRequest) AddRequest(shardID uint64, points []models.Point) {
+	var req WriteShardRequest
+	req.SetShardID(shardID)
+	req.AddPoints(points)
+	r.pb.Requests = append(r.pb.Requests, &req.pb)
+}
+
+// Requests returns the writes in the batch.
+func (r *WriteShardBatchRequest) Requests() []*WriteShardRequest {
+	a := make([]*WriteShardRequest, len(r.pb.GetRequests()))
+	for i, req := range r.pb.GetRequests() {
+		a[i] = &WriteShardRequest{pb: *req}
+	}
+	return a
+}
+
+// MarshalBinary encodes the object to a binary format.
+func (r *WriteShardBatchRequest) MarshalBinary() ([]byte, error) {
+	return proto.Marshal(&r.pb)
+}
+
+// UnmarshalBinary populates WriteShardBatchRequest from a binary format.
+func (r *WriteShardBatchRequest) UnmarshalBinary(buf []byte) error {
+	return proto.Unmarshal(buf, &r.pb)
+}
+
+// WriteShardBatchResponse represents the response returned from a remote
+// WriteShardBatchRequest call. It holds a response for each write in the batch.
+type WriteShardBatchResponse struct {
+	pb internal.WriteShardBatchResponse
+}
+
+// Code returns the response code of the batch.
+func (r *WriteShardBatchResponse) Code() int { return int(r.pb.GetCode()) }
+
+// Message returns the response message of the batch.
+func (r *WriteShardBatchResponse) Message() string { return r.pb.GetMessage() }
+
+// Responses returns the response of each write in the batch.
+func (r *WriteShardBatchResponse) Responses() []*WriteShardResponse {
+	a := make([]*WriteShardResponse, len(r.pb.GetResponses()))
+	for i, resp := range r.pb.GetResponses() {
+		a[i] = &WriteShardResponse{pb: *resp}
+	}
+	return a
+}
+
+// SetCode sets the response code of the batch.
+func (r *WriteShardBatchResponse) SetCode(code int) { r.pb.Code = proto.Int32(int32(code)) }
+
+// SetMessage sets the response message of the batch.
+func (r *WriteShardBatchResponse) SetMessage(message string) { r.pb.Message = &message }
+
+// AddResponse adds the response of the next write in the batch.
+func (r *WriteShardBatchResponse) AddResponse(err error) {
+	var resp WriteShardResponse
+	if err != nil {
+		resp.SetCode(1)
+		resp.SetMessage(err.Error())
+	} else {
+		resp.SetCode(0)
+	}
+	r.pb.Responses = append(r.pb.Responses, &resp.pb)
+}
+
+// MarshalBinary encodes the object to a binary format.
+func (r *WriteShardBatchResponse) MarshalBinary() ([]byte, error) {
+	return proto.Marshal(&r.pb)
+}
+
+// UnmarshalBinary populates WriteShardBatchResponse from a binary format.
+func (r *WriteShardBatchResponse) UnmarshalBinary(buf []byte) error {
+	return proto.Unmarshal(buf, &r.pb)
+}
+
+// ShardDigestRequest represents a request for the digests of a shard's series.
+type ShardDigestRequest struct {
+	pb internal.ShardDigestRequest
+}
+
+// ShardID returns the ID of the shard to digest.
+func (r *ShardDigestRequest) ShardID() uint64 { return r.pb.GetShardID() }
+
+// Min returns the inclusive start of the time range to digest.
+func (r *ShardDigestRequest) Min() int64 { return r.pb.GetMin() }
+
+// Max returns the exclusive end of the time range to digest.
+func (r *ShardDigestRequest) Max() int64 { return r.pb.GetMax() }
+
+// Depth returns the depth of the digest trees.
+func (r *ShardDigestRequest) Depth() int { return int(r.pb.GetDepth()) }
+
+// SetShardID sets the ID of the shard to digest.
+func (r *ShardDigestRequest) SetShardID(id uint64) { r.pb.ShardID = &id }
+
+// SetTimeRange sets the time range to digest.
+func (r *ShardDigestRequest) SetTimeRange(min, max int64) {
+	r.pb.Min = &min
+	r.pb.Max = &max
+}
+
+// SetDepth sets the depth of the digest trees.
+func (r *ShardDigestRequest) SetDepth(depth int) { r.pb.Depth = proto.Uint32(uint32(depth)) }
+
+// MarshalBinary encodes the object to a binary format.
+func (r *ShardDigestRequest) MarshalBinary() ([]byte, error) {
+	return proto.Marshal(&r.pb)
+}
+
+// UnmarshalBinary populates ShardDigestRequest from a binary format.
+func (r *ShardDigestRequest) UnmarshalBinary(buf []byte) error {
+	return proto.Unmarshal(buf, &r.pb)
+}
+
+// ShardDigestResponse represents the response returned from a remote ShardDigestRequest call.
+type ShardDigestResponse struct {
+	pb internal.ShardDigestResponse
+}
+
+// Code returns the response code.
+func (r *ShardDigestResponse) Code() int { return int(r.pb.GetCode()) }
+
+// Message returns the response message.
+func (r *ShardDigestResponse) Message() string { return r.pb.GetMessage() }
+
+// Digests returns the digests of the shard's series.
+func (r *ShardDigestResponse) Digests() []*tsdb.SeriesDigest {
+	a := make([]*tsdb.SeriesDigest, len(r.pb.GetSeries()))
+	for i, d := range r.pb.GetSeries() {
+		a[i] = &tsdb.SeriesDigest{Key: d.GetKey(), Hashes: d.GetHashes()}
+	}
+	return a
+}
+
+// SetCode sets the response code.
+func (r *ShardDigestResponse) SetCode(code int) { r.pb.Code = proto.Int32(int32(code)) }
+
+// SetMessage sets the response message.
+func (r *ShardDigestResponse) SetMessage(message string) { r.pb.Message = &message }
+
+// SetDigests sets the digests of the shard's series.
+func (r *ShardDigestResponse) SetDigests(a []*tsdb.SeriesDigest) {
+	r.pb.Series = make([]*internal.SeriesDigest, len(a))
+	for i, d := range a {
+		r.pb.Series[i] = &internal.SeriesDigest{Key: proto.String(d.Key), Hashes: d.Hashes}
+	}
+}
+
+// MarshalBinary encodes the object to a binary format.
+func (r *ShardDigestResponse) MarshalBinary() ([]byte, error) {
+	return proto.Marshal(&r.pb)
+}
+
+// UnmarshalBinary populates ShardDigestResponse from a binary format.
+func (r *ShardDigestResponse) UnmarshalBinary(buf []byte) error {
+	return proto.Unmarshal(buf, &r.pb)
+}
+
+// SeriesPointsRequest represents a request for the points of a series in a shard.
+type SeriesPointsRequest struct {
+	pb internal.SeriesPointsRequest
+}
+
+// ShardID returns the ID of the shard to read.
+func (r *SeriesPointsRequest) ShardID() uint64 { return r.pb.GetShardID() }
+
+// Key returns the key of the series to read.
+func (r *SeriesPointsRequest) Key() string { return r.pb.GetKey() }
+
+// Min returns the inclusive start of the time range to read.
+func (r *SeriesPointsRequest) Min() int64 { return r.pb.GetMin() }
+
+// Max returns the exclusive end of the time range to read.
+func (r *SeriesPointsRequest) Max() int64 { return r.pb.GetMax() }
+
+// SetShardID sets the ID of the shard to read.
+func (r *SeriesPointsRequest) SetShardID(id uint64) { r.pb.ShardID = &id }
+
+// SetKey sets the key of the series to read.
+func (r *SeriesPointsRequest) SetKey(key string) { r.pb.Key = &key }
+
+// SetTimeRange sets the time range to read.
+func (r *SeriesPointsRequest) SetTimeRange(min, max int64) {
+	r.pb.Min = &min
+	r.pb.Max = &max
+}
+
+// MarshalBinary encodes the object to a binary format.
+func (r *SeriesPointsRequest) MarshalBinary() ([]byte, error) {
+	return proto.Marshal(&r.pb)
+}
+
+// UnmarshalBinary populates SeriesPointsRequest from a binary format.
+func (r *SeriesPointsRequest) UnmarshalBinary(buf []byte) error {
+	return proto.Unmarshal(buf, &r.pb)
+}
+
+// SeriesPointsResponse represents the response returned from a remote SeriesPointsRequest call.
+type SeriesPointsResponse struct {
+	pb internal.SeriesPointsResponse
+}
+
+// Code returns the response code.
+func (r *SeriesPointsResponse) Code() int { return int(r.pb.GetCode()) }
+
+// Message returns the response message.
+func (r *SeriesPointsResponse) Message() string { return r.pb.GetMessage() }
+
+// Points returns the points of the series.
+func (r *SeriesPointsResponse) Points() ([]models.Point, error) {
+	points := make([]models.Point, len(r.pb.GetPoints()))
+	for i, p := range r.pb.GetPoints() {
+		pt, err := models.ParsePoints(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse point: `%v`: %v", string(p), err)
+		}
+		points[i] = pt[0]
+	}
+	return points, nil
+}
+
+// SetCode sets the response code.
+func (r *SeriesPointsResponse) SetCode(code int) { r.pb.Code = proto.Int32(int32(code)) }
+
+// SetMessage sets the response message.
+func (r *SeriesPointsResponse) SetMessage(message string) { r.pb.Message = &message }
+
+// AddPoints adds points of the series.
+func (r *SeriesPointsResponse) AddPoints(points []models.Point) {
+	for _, p := range points {
+		r.pb.Points = append(r.pb.Points, []byte(p.String()))
+	}
+}
+
+// MarshalBinary encodes the object to a binary format.
+func (r *SeriesPointsResponse) MarshalBinary() ([]byte, error) {
+	return proto.Marshal(&r.pb)
+}
+
+// UnmarshalBinary populates SeriesPointsResponse from a binary format.
+func (r *SeriesPointsResponse) UnmarshalBinary(buf []byte) error {
+	return proto.Unmarshal(buf, &r.pb)
+}
@@ -1,8 +1,12 @@
 package cluster
 
 import (
+	"reflect"
 	"testing"
 	"time"
+
+	"github.com/influxdb/influxdb/influxql"
+	"github.com/influxdb/influxdb/tsdb"
 )
 
 func TestWriteShardRequestBinary(t *testing.T) {
@@ -108,3 +112,126 @@ func TestWriteShardResponseBinary(t *testing.T) {
 	}
 
 }
+
+func TestShardDigestResponseBinary(t *testing.T) {
+	digests := []*tsdb.SeriesDigest{
+		{Key: "cpu,host=serverA", Hashes: []uint64{1, 2, 3}},
+		{Key: "cpu,host=serverB", Hashes: []uint64{4, 0, 4}},
+	}
+
+	sr := &ShardDigestResponse{}
+	sr.SetCode(0)
+	sr.SetDigests(digests)
+	b, err := sr.MarshalBinary()
+	if err != nil {
+		t.Fatalf("ShardDigestResponse.MarshalBinary() failed: %v", err)
+	}
+
+	got := &ShardDigestResponse{}
+	if err := got.UnmarshalBinary(b); err != nil {
+		t.Fatalf("ShardDigestResponse.UnmarshalBinary() failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(got.Digests(), digests) {
+		t.Errorf("Digests mismatch: got %v, exp %v", got.Digests(), digests)
+	}
+}
+
+func TestMapperChunkBinary(t *testing.T) {
+	stmt := mustParseStmt("SELECT sum(value), median(value) FROM cpu").(*influxql.SelectStatement)
+	var unmarshallers []tsdb.UnmarshalFunc
+	for _, c := range stmt.FunctionCalls() {
+		fn, err := tsdb.InitializeUnmarshaller(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		unmarshallers = append(unmarshallers, fn)
+	}
+
+	for i, mo := range []*tsdb.MapperOutput{
+		{
+			Name:   "cpu",
+			Tags:   map[string]string{"host": "serverA", "region": "uswest"},
+			Fields: []string{"value"},
+			Values: []*tsdb.MapperValue{
+				{Time: 1, Value: float64(1.5), Tags: map[string]string{"host": "serverA"}},
+				{Time: 2, Value: int64(2)},
+				{Time: 3, Value: "three"},
+				{Time: 4, Value: true},
+				{Time: 5, Value: nil},
+				{Time: 6, Value: uint64(6)},
+			},
+		},
+		{
+			Name:   "cpu",
+			Fields: []string{"idle", "user"},
+			Values: []*tsdb.MapperValue{
+				{Time: 1, Value: map[string]interface{}{"idle": int64(90), "user": float64(10)}},
+			},
+		},
+		{
+			Name: "cpu",
+			Values: []*tsdb.MapperValue{
+				{Time: 60, Value: []interface{}{float64(10), []float64{1, 2, 3}}},
+			},
+		},
+	} {
+		var c MapperChunk
+		if err := c.SetOutput(mo); err != nil {
+			t.Fatalf("%d. set output: %s", i, err)
+		}
+
+		b, err := c.MarshalBinary()
+		if err != nil {
+			t.Fatalf("%d. marshal: %s", i, err)
+		}
+
+		var other MapperChunk
+		if err := other.UnmarshalBinary(b); err != nil {
+			t.Fatalf("%d. unmarshal: %s", i, err)
+		}
+
+		if out, err := other.Output(unmarshallers); err != nil {
+			t.Fatalf("%d. output: %s", i, err)
+		} else if !reflect.DeepEqual(out, mo) {
+			t.Errorf("%d. output mismatch:\n\tgot %#v\n\texp %#v", i, out, mo)
+		}
+	}
+
+	// Values of unsupported types can't be encoded.
+	var c MapperChunk
+	if err := c.SetOutput(&tsdb.MapperOutput{Values: []*tsdb.MapperValue{{Value: uint8(1)}}}); err == nil || err.Error() != "unsupported field value type: uint8" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure a field value of every data type can be encoded and decoded.
+func TestFieldValueBinary(t *testing.T) {
+	values := map[influxql.DataType]interface{}{
+		influxql.Unknown:  nil,
+		influxql.Float:    float64(1.5),
+		influxql.Integer:  int64(-2),
+		influxql.Boolean:  true,
+		influxql.String:   "three",
+		influxql.Time:     time.Unix(0, 4).UTC(),
+		influxql.Duration: 5 * time.Second,
+		influxql.Unsigned: uint64(1<<64 - 1),
+	}
+
+	for typ := influxql.Unknown; typ <= influxql.Unsigned; typ++ {
+		v, ok := values[typ]
+		if !ok {
+			t.Fatalf("no value for %s", typ)
+		} else if got := influxql.InspectDataType(v); got != typ {
+			t.Fatalf("%s: value has type %s", typ, got)
+		}
+
+		f, err := marshalFieldValue(v)
+		if err != nil {
+			t.Fatalf("%s: marshal: %s", typ, err)
+		}
+		if got := unmarshalFieldValue(f); !reflect.DeepEqual(got, v) {
+			t.Errorf("%s: got %#v, exp %#v", typ, got, v)
+		}
+	}
+}
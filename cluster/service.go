@@ -1,8 +1,10 @@
 package cluster
 
 import (
+	"encoding"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"expvar"
 	"fmt"
 	"io"
@@ -25,13 +27,30 @@ const MaxMessageSize = 1024 * 1024 * 1024 // 1GB
 // MuxHeader is the header byte used in the TCP mux.
 const MuxHeader = 2
 
+// MapperMuxHeader is the header byte used in the TCP mux by the streaming
+// mapper protocol. Nodes that don't support the protocol close connections
+// with this header so clients fall back to MuxHeader.
+const MapperMuxHeader = 8
+
+// mapperProtocolVersion is the version of the streaming mapper protocol. It's
+// sent to clients when they connect to MapperMuxHeader.
+const mapperProtocolVersion = 1
+
+// errMapperCancelled is returned when the client cancels a mapper stream.
+var errMapperCancelled = errors.New("mapper cancelled")
+
 // Statistics maintained by the cluster package
 const (
 	writeShardReq       = "write_shard_req"
 	writeShardPointsReq = "write_shard_points_req"
 	writeShardFail      = "write_shard_fail"
+	writeShardBatchReq  = "write_shard_batch_req"
+	writeShardForward   = "write_shard_forward"
 	mapShardReq         = "map_shard_req"
 	mapShardResp        = "map_shard_resp"
+	mapShardCancel      = "map_shard_cancel"
+	shardDigestReq      = "shard_digest_req"
+	seriesPointsReq     = "series_points_req"
 )
 
 // Service processes data received over raw TCP connections.
@@ -43,7 +62,11 @@ type Service struct {
 
 	Listener net.Listener
 
+	// MapperListener accepts connections using the streaming mapper protocol.
+	MapperListener net.Listener
+
 	MetaStore interface {
+		NodeID() uint64
 		ShardOwner(shardID uint64) (string, string, *meta.ShardGroupInfo)
 	}
 
@@ -51,6 +74,13 @@ type Service struct {
 		CreateShard(database, policy string, shardID uint64) error
 		WriteToShard(shardID uint64, points []models.Point) error
 		CreateMapper(shardID uint64, stmt influxql.Statement, chunkSize int) (tsdb.Mapper, error)
+		ShardDigest(shardID uint64, min, max int64, depth int) ([]*tsdb.SeriesDigest, error)
+		ShardSeriesPoints(shardID uint64, key string, min, max int64) ([]models.Point, error)
+	}
+
+	// ShardWriter forwards writes for shards this node no longer owns.
+	ShardWriter interface {
+		WriteShard(shardID, ownerID uint64, points []models.Point) error
 	}
 
 	Logger  *log.Logger
@@ -72,7 +102,12 @@ func (s *Service) Open() error {
 	s.Logger.Println("Starting cluster service")
 	// Begin serving conections.
 	s.wg.Add(1)
-	go s.serve()
+	go s.serve(s.Listener, s.handleConn)
+
+	if s.MapperListener != nil {
+		s.wg.Add(1)
+		go s.serve(s.MapperListener, s.handleMapperConn)
+	}
 
 	return nil
 }
@@ -83,7 +118,7 @@ func (s *Service) SetLogger(l *log.Logger) {
 }
 
 // serve accepts connections from the listener and handles them.
-func (s *Service) serve() {
+func (s *Service) serve(ln net.Listener, handle func(net.Conn)) {
 	defer s.wg.Done()
 
 	for {
@@ -95,7 +130,7 @@ func (s *Service) serve() {
 		}
 
 		// Accept the next connection.
-		conn, err := s.Listener.Accept()
+		conn, err := ln.Accept()
 		if err != nil {
 			if strings.Contains(err.Error(), "connection closed") {
 				s.Logger.Printf("cluster service accept error: %s", err)
@@ -109,7 +144,7 @@ func (s *Service) serve() {
 		s.wg.Add(1)
 		go func() {
 			defer s.wg.Done()
-			s.handleConn(conn)
+			handle(conn)
 		}()
 	}
 }
@@ -119,6 +154,9 @@ func (s *Service) Close() error {
 	if s.Listener != nil {
 		s.Listener.Close()
 	}
+	if s.MapperListener != nil {
+		s.MapperListener.Close()
+	}
 
 	// Shut down all handlers.
 	close(s.closing)
@@ -164,6 +202,23 @@ func (s *Service) handleConn(conn net.Conn) {
 				s.Logger.Printf("process write shard error: %s", err)
 			}
 			s.writeShardResponse(conn, err)
+		case negotiateRequestMessage:
+			compression := negotiateCompression(buf)
+			if err := WriteTLV(conn, negotiateResponseMessage, []byte{compression}); err != nil {
+				s.Logger.Printf("negotiate response error: %s", err)
+			}
+		case writeShardBatchRequestMessage:
+			s.statMap.Add(writeShardBatchReq, 1)
+			resp, err := s.processWriteShardBatchRequest(buf)
+			if err != nil {
+				s.Logger.Printf("process write shard batch error: %s", err)
+				resp = &WriteShardBatchResponse{}
+				resp.SetCode(1)
+				resp.SetMessage(err.Error())
+			}
+			if err := writeMessage(conn, writeShardBatchResponseMessage, resp); err != nil {
+				s.Logger.Printf("write shard batch response error: %s", err)
+			}
 		case mapShardRequestMessage:
 			s.statMap.Add(mapShardReq, 1)
 			err := s.processMapShardRequest(conn, buf)
@@ -173,6 +228,30 @@ func (s *Service) handleConn(conn net.Conn) {
 					s.Logger.Printf("process map shard error writing response: %s", err.Error())
 				}
 			}
+		case shardDigestRequestMessage:
+			s.statMap.Add(shardDigestReq, 1)
+			resp, err := s.processShardDigestRequest(buf)
+			if err != nil {
+				s.Logger.Printf("process shard digest error: %s", err)
+				resp = &ShardDigestResponse{}
+				resp.SetCode(1)
+				resp.SetMessage(err.Error())
+			}
+			if err := writeMessage(conn, shardDigestResponseMessage, resp); err != nil {
+				s.Logger.Printf("shard digest response error: %s", err)
+			}
+		case seriesPointsRequestMessage:
+			s.statMap.Add(seriesPointsReq, 1)
+			resp, err := s.processSeriesPointsRequest(buf)
+			if err != nil {
+				s.Logger.Printf("process series points error: %s", err)
+				resp = &SeriesPointsResponse{}
+				resp.SetCode(1)
+				resp.SetMessage(err.Error())
+			}
+			if err := writeMessage(conn, seriesPointsResponseMessage, resp); err != nil {
+				s.Logger.Printf("series points response error: %s", err)
+			}
 		default:
 			s.Logger.Printf("cluster service message type not found: %d", typ)
 		}
@@ -185,7 +264,36 @@ func (s *Service) processWriteShardRequest(buf []byte) error {
 	if err := req.UnmarshalBinary(buf); err != nil {
 		return err
 	}
+	return s.writeShard(&req)
+}
+
+// processWriteShardBatchRequest writes each request in a batch and returns
+// the result of each write.
+func (s *Service) processWriteShardBatchRequest(buf []byte) (*WriteShardBatchResponse, error) {
+	buf, err := decodeBatch(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	var req WriteShardBatchRequest
+	if err := req.UnmarshalBinary(buf); err != nil {
+		return nil, err
+	}
 
+	resp := &WriteShardBatchResponse{}
+	resp.SetCode(0)
+	for _, r := range req.Requests() {
+		s.statMap.Add(writeShardReq, 1)
+		err := s.writeShard(r)
+		if err != nil {
+			s.Logger.Printf("process write shard error: %s", err)
+		}
+		resp.AddResponse(err)
+	}
+	return resp, nil
+}
+
+func (s *Service) writeShard(req *WriteShardRequest) error {
 	points := req.Points()
 	s.statMap.Add(writeShardPointsReq, int64(len(points)))
 	err := s.TSDBStore.WriteToShard(req.ShardID(), req.Points())
@@ -207,6 +315,14 @@ func (s *Service) processWriteShardRequest(buf []byte) error {
 			return nil
 		}
 
+		// Writes for shards moved off this node, e.g. replayed from another
+		// node's hinted handoff queue, are forwarded to the shard's owners.
+		for _, si := range sgi.Shards {
+			if si.ID == req.ShardID() && !si.OwnedBy(s.MetaStore.NodeID()) {
+				return s.forwardWrite(&si, points)
+			}
+		}
+
 		err = s.TSDBStore.CreateShard(database, retentionPolicy, req.ShardID())
 		if err != nil {
 			return err
@@ -222,6 +338,18 @@ func (s *Service) processWriteShardRequest(buf []byte) error {
 	return nil
 }
 
+// forwardWrite writes points to every owner of a shard.
+func (s *Service) forwardWrite(si *meta.ShardInfo, points []models.Point) error {
+	for _, so := range si.Owners {
+		if err := s.ShardWriter.WriteShard(si.ID, so.NodeID, points); err != nil {
+			s.statMap.Add(writeShardFail, 1)
+			return fmt.Errorf("forward write to node %d: %s", so.NodeID, err)
+		}
+	}
+	s.statMap.Add(writeShardForward, 1)
+	return nil
+}
+
 func (s *Service) writeShardResponse(w io.Writer, e error) {
 	// Build response.
 	var resp WriteShardResponse
@@ -245,31 +373,180 @@ func (s *Service) writeShardResponse(w io.Writer, e error) {
 	}
 }
 
-func (s *Service) processMapShardRequest(w io.Writer, buf []byte) error {
-	// Decode request
+// handleMapperConn services a connection using the streaming mapper protocol.
+// The client requests a shard to be mapped and grants credit for a number of
+// chunks. Chunks are sent while there is credit and the client grants more
+// as it consumes them. The client can cancel the stream at any time.
+func (s *Service) handleMapperConn(conn net.Conn) {
+	// Ensure connection is closed when service is closed.
+	closing := make(chan struct{})
+	defer close(closing)
+	go func() {
+		select {
+		case <-closing:
+		case <-s.closing:
+		}
+		conn.Close()
+	}()
+
+	// Announce the protocol version.
+	if _, err := conn.Write([]byte{mapperProtocolVersion}); err != nil {
+		s.Logger.Printf("unable to write mapper protocol version: %s", err)
+		return
+	}
+
+	typ, buf, err := ReadTLV(conn)
+	if err != nil {
+		s.Logger.Printf("unable to read type-length-value %s", err)
+		return
+	} else if typ != mapShardStreamRequestMessage {
+		s.Logger.Printf("unexpected mapper message type: %d", typ)
+		return
+	}
+
+	s.statMap.Add(mapShardReq, 1)
+	if err := s.processMapShardStreamRequest(conn, buf); err == errMapperCancelled {
+		s.statMap.Add(mapShardCancel, 1)
+	} else if err != nil {
+		s.Logger.Printf("process map shard stream error: %s", err)
+		if err := writeMessage(conn, mapShardStreamResponseMessage, NewMapShardResponse(1, err.Error())); err != nil {
+			s.Logger.Printf("process map shard stream error writing response: %s", err)
+		}
+	}
+}
+
+// processMapShardStreamRequest streams the output of a mapper. A response is
+// sent before the first chunk with the tag sets and fields of the mapper and
+// after the last chunk to end the stream.
+func (s *Service) processMapShardStreamRequest(conn net.Conn, buf []byte) error {
 	var req MapShardRequest
 	if err := req.UnmarshalBinary(buf); err != nil {
 		return err
 	}
 
+	m, err := s.openMapper(req.ShardID(), req.Query(), int(req.ChunkSize()))
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		if err := writeMessage(conn, mapShardStreamResponseMessage, NewMapShardResponse(0, "")); err != nil {
+			return err
+		}
+		return writeMessage(conn, mapShardStreamResponseMessage, NewMapShardResponse(0, ""))
+	}
+	defer m.Close()
+
+	resp := NewMapShardResponse(0, "")
+	resp.SetTagSets(m.TagSets())
+	resp.SetFields(m.Fields())
+	if err := writeMessage(conn, mapShardStreamResponseMessage, resp); err != nil {
+		return err
+	}
+
+	// Read credit and cancellation from the client while mapping.
+	credits := make(chan int)
+	cancelled := make(chan struct{})
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer close(cancelled)
+		for {
+			typ, buf, err := ReadTLV(conn)
+			if err != nil || typ != mapShardStreamCreditMessage || len(buf) != 4 {
+				return
+			}
+
+			select {
+			case credits <- int(binary.BigEndian.Uint32(buf)):
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	credit := req.Credit()
+	for {
+		// Wait for credit if the client hasn't consumed the chunks sent.
+		if credit == 0 {
+			select {
+			case n := <-credits:
+				credit += n
+			case <-cancelled:
+				return errMapperCancelled
+			}
+		} else {
+			select {
+			case n := <-credits:
+				credit += n
+			case <-cancelled:
+				return errMapperCancelled
+			default:
+			}
+		}
+
+		chunk, err := m.NextChunk()
+		if err != nil {
+			return fmt.Errorf("next chunk: %s", err)
+		} else if chunk == nil {
+			// All mapper data sent.
+			return writeMessage(conn, mapShardStreamResponseMessage, NewMapShardResponse(0, ""))
+		}
+
+		mo, ok := chunk.(*tsdb.MapperOutput)
+		if !ok {
+			return fmt.Errorf("unexpected chunk type: %T", chunk)
+		}
+
+		var c MapperChunk
+		if err := c.SetOutput(mo); err != nil {
+			return fmt.Errorf("encoding: %s", err)
+		}
+		if err := writeMessage(conn, mapShardStreamChunkMessage, &c); err != nil {
+			return err
+		}
+		s.statMap.Add(mapShardResp, 1)
+		credit--
+	}
+}
+
+// openMapper parses a query and opens a mapper for a local shard. Returns nil
+// if there is no data for the shard.
+func (s *Service) openMapper(shardID uint64, query string, chunkSize int) (tsdb.Mapper, error) {
 	// Parse the statement.
-	q, err := influxql.ParseQuery(req.Query())
+	q, err := influxql.ParseQuery(query)
 	if err != nil {
-		return fmt.Errorf("processing map shard: %s", err)
+		return nil, fmt.Errorf("processing map shard: %s", err)
 	} else if len(q.Statements) != 1 {
-		return fmt.Errorf("processing map shard: expected 1 statement but got %d", len(q.Statements))
+		return nil, fmt.Errorf("processing map shard: expected 1 statement but got %d", len(q.Statements))
 	}
 
-	m, err := s.TSDBStore.CreateMapper(req.ShardID(), q.Statements[0], int(req.ChunkSize()))
+	m, err := s.TSDBStore.CreateMapper(shardID, q.Statements[0], chunkSize)
 	if err != nil {
-		return fmt.Errorf("create mapper: %s", err)
+		return nil, fmt.Errorf("create mapper: %s", err)
 	}
 	if m == nil {
-		return writeMapShardResponseMessage(w, NewMapShardResponse(0, ""))
+		return nil, nil
 	}
 
 	if err := m.Open(); err != nil {
-		return fmt.Errorf("mapper open: %s", err)
+		return nil, fmt.Errorf("mapper open: %s", err)
+	}
+	return m, nil
+}
+
+func (s *Service) processMapShardRequest(w io.Writer, buf []byte) error {
+	// Decode request
+	var req MapShardRequest
+	if err := req.UnmarshalBinary(buf); err != nil {
+		return err
+	}
+
+	m, err := s.openMapper(req.ShardID(), req.Query(), int(req.ChunkSize()))
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		return writeMapShardResponseMessage(w, NewMapShardResponse(0, ""))
 	}
 	defer m.Close()
 
@@ -313,6 +590,49 @@ func (s *Service) processMapShardRequest(w io.Writer, buf []byte) error {
 	}
 }
 
+func (s *Service) processShardDigestRequest(buf []byte) (*ShardDigestResponse, error) {
+	var req ShardDigestRequest
+	if err := req.UnmarshalBinary(buf); err != nil {
+		return nil, err
+	}
+
+	a, err := s.TSDBStore.ShardDigest(req.ShardID(), req.Min(), req.Max(), req.Depth())
+	if err != nil {
+		return nil, fmt.Errorf("digest shard %d: %s", req.ShardID(), err)
+	}
+
+	resp := &ShardDigestResponse{}
+	resp.SetCode(0)
+	resp.SetDigests(a)
+	return resp, nil
+}
+
+func (s *Service) processSeriesPointsRequest(buf []byte) (*SeriesPointsResponse, error) {
+	var req SeriesPointsRequest
+	if err := req.UnmarshalBinary(buf); err != nil {
+		return nil, err
+	}
+
+	points, err := s.TSDBStore.ShardSeriesPoints(req.ShardID(), req.Key(), req.Min(), req.Max())
+	if err != nil {
+		return nil, fmt.Errorf("read shard %d: %s", req.ShardID(), err)
+	}
+
+	resp := &SeriesPointsResponse{}
+	resp.SetCode(0)
+	resp.AddPoints(points)
+	return resp, nil
+}
+
+// writeMessage marshals a message and writes it to w.
+func writeMessage(w io.Writer, typ byte, msg encoding.BinaryMarshaler) error {
+	buf, err := msg.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return WriteTLV(w, typ, buf)
+}
+
 func writeMapShardResponseMessage(w io.Writer, msg *MapShardResponse) error {
 	buf, err := msg.MarshalBinary()
 	if err != nil {
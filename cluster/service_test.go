@@ -31,6 +31,8 @@ type testService struct {
 	writeShardFunc   func(shardID uint64, points []models.Point) error
 	createShardFunc  func(database, policy string, shardID uint64) error
 	createMapperFunc func(shardID uint64, stmt influxql.Statement, chunkSize int) (tsdb.Mapper, error)
+	shardDigestFunc  func(shardID uint64, min, max int64, depth int) ([]*tsdb.SeriesDigest, error)
+	seriesPointsFunc func(shardID uint64, key string, min, max int64) ([]models.Point, error)
 }
 
 func newTestWriteService(f func(shardID uint64, points []models.Point) error) testService {
@@ -75,6 +77,14 @@ func (t testService) CreateMapper(shardID uint64, stmt influxql.Statement, chunk
 	return t.createMapperFunc(shardID, stmt, chunkSize)
 }
 
+func (t testService) ShardDigest(shardID uint64, min, max int64, depth int) ([]*tsdb.SeriesDigest, error) {
+	return t.shardDigestFunc(shardID, min, max, depth)
+}
+
+func (t testService) ShardSeriesPoints(shardID uint64, key string, min, max int64) ([]models.Point, error) {
+	return t.seriesPointsFunc(shardID, key, min, max)
+}
+
 func writeShardSuccess(shardID uint64, points []models.Point) error {
 	responses <- &serviceResponse{
 		shardID: shardID,
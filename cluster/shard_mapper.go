@@ -1,14 +1,19 @@
 package cluster
 
 import (
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"math/rand"
+	"io"
 	"net"
 	"time"
 
 	"github.com/influxdb/influxdb/influxql"
 	"github.com/influxdb/influxdb/meta"
+	"github.com/influxdb/influxdb/tcp"
 	"github.com/influxdb/influxdb/tsdb"
 )
 
@@ -27,60 +32,411 @@ type ShardMapper struct {
 		CreateMapper(shardID uint64, stmt influxql.Statement, chunkSize int) (tsdb.Mapper, error)
 	}
 
+	// Health tracks the latency and availability of the remote owners.
+	Health *HealthTracker
+
+	// If set, connections to other nodes are made over TLS.
+	TLSConfig *tls.Config
+
+	// Credit is the number of chunks a remote node can stream before waiting
+	// for them to be consumed.
+	Credit int
+
 	timeout time.Duration
 	pool    *clientPool
 }
 
+// DefaultMapperCredit is the default number of chunks a remote node can stream
+// before waiting for them to be consumed.
+const DefaultMapperCredit = 8
+
+// ErrReadConsistency is returned when too few owners of a shard are available
+// to satisfy the read consistency level of a query.
+var ErrReadConsistency = errors.New("read consistency not met: too few owners available")
+
+// ErrReplicasDiffer is returned when the owners read for a query return
+// different data for a shard.
+var ErrReplicasDiffer = errors.New("read consistency not met: owners returned different data")
+
 // NewShardMapper returns a mapper of local and remote shards.
 func NewShardMapper(timeout time.Duration) *ShardMapper {
 	return &ShardMapper{
+		Health:  NewHealthTracker(),
+		Credit:  DefaultMapperCredit,
 		pool:    newClientPool(),
 		timeout: timeout,
 	}
 }
 
-// CreateMapper returns a Mapper for the given shard ID.
-func (s *ShardMapper) CreateMapper(sh meta.ShardInfo, stmt influxql.Statement, chunkSize int) (tsdb.Mapper, error) {
-	// Create a remote mapper if the local node doesn't own the shard.
-	if !sh.OwnedBy(s.MetaStore.NodeID()) || s.ForceRemoteMapping {
-		// Pick a node in a pseudo-random manner.
-		conn, err := s.dial(sh.Owners[rand.Intn(len(sh.Owners))].NodeID)
+// CreateMapper returns a Mapper for the given shard ID. The shard is read
+// from as many owners as the read consistency level requires. Local data is
+// read first if the local node owns the shard, then the remaining owners are
+// tried from the fastest healthy node to the slowest. If more than one owner
+// is read their outputs are compared and the query fails if they differ.
+func (s *ShardMapper) CreateMapper(sh meta.ShardInfo, stmt influxql.Statement, chunkSize int, level tsdb.ReadConsistency) (tsdb.Mapper, error) {
+	nodeID := s.MetaStore.NodeID()
+	local := sh.OwnedBy(nodeID) && !s.ForceRemoteMapping
+	required := level.Required(len(sh.Owners))
+
+	var mappers []tsdb.Mapper
+	closeAll := func() {
+		for _, m := range mappers {
+			m.Close()
+		}
+	}
+
+	// If it is local then start with the mapper from the store.
+	var ids []uint64
+	if local {
+		m, err := s.TSDBStore.CreateMapper(sh.ID, stmt, chunkSize)
 		if err != nil {
 			return nil, err
 		}
-		conn.SetDeadline(time.Now().Add(s.timeout))
+		mappers = append(mappers, m)
+	}
+	for _, o := range sh.Owners {
+		if !local || o.NodeID != nodeID {
+			ids = append(ids, o.NodeID)
+		}
+	}
+
+	// Create remote mappers on the owners that can be reached.
+	err := ErrReadConsistency
+	for _, id := range s.Health.Order(ids) {
+		if len(mappers) >= required {
+			break
+		}
+
+		var m tsdb.Mapper
+		if m, err = s.createRemoteMapper(id, sh.ID, stmt, chunkSize); err != nil {
+			s.Health.Failure(id)
+			continue
+		}
+		mappers = append(mappers, m)
+	}
+
+	switch {
+	case len(mappers) < required && required > 1:
+		closeAll()
+		return nil, ErrReadConsistency
+	case len(mappers) < required:
+		closeAll()
+		return nil, err
+	case len(mappers) == 1:
+		return mappers[0], nil
+	default:
+		return &replicaMapper{mappers: mappers}, nil
+	}
+}
+
+// replicaMapper reads a shard from several owners. Chunks are only returned
+// if every owner returned the same chunk.
+type replicaMapper struct {
+	mappers []tsdb.Mapper
+}
+
+// Open opens the mapper of each owner and checks that they have the same tag
+// sets and fields.
+func (m *replicaMapper) Open() error {
+	for i, r := range m.mappers {
+		if err := r.Open(); err != nil {
+			return err
+		} else if i == 0 {
+			continue
+		}
+
+		if !stringsEqual(r.TagSets(), m.mappers[0].TagSets()) || !stringsEqual(r.Fields(), m.mappers[0].Fields()) {
+			return ErrReplicasDiffer
+		}
+	}
+	return nil
+}
+
+// TagSets returns the tag sets of the shard.
+func (m *replicaMapper) TagSets() []string { return m.mappers[0].TagSets() }
+
+// Fields returns the fields of the shard.
+func (m *replicaMapper) Fields() []string { return m.mappers[0].Fields() }
+
+// NextChunk reads the next chunk from each owner. The chunks are compared in
+// their encoded form since local and remote chunks may hold values of
+// different types.
+func (m *replicaMapper) NextChunk() (interface{}, error) {
+	var chunk interface{}
+	var exp []byte
+	for i, r := range m.mappers {
+		c, err := r.NextChunk()
+		if err != nil {
+			return nil, err
+		}
+
+		b, err := json.Marshal(c)
+		if err != nil {
+			return nil, err
+		}
+
+		if i == 0 {
+			chunk, exp = c, b
+		} else if !bytes.Equal(b, exp) {
+			return nil, ErrReplicasDiffer
+		}
+	}
+	return chunk, nil
+}
+
+// Close closes the mapper of each owner.
+func (m *replicaMapper) Close() {
+	for _, r := range m.mappers {
+		r.Close()
+	}
+}
 
-		return NewRemoteMapper(conn, sh.ID, stmt, chunkSize), nil
+// stringsEqual returns true if a and b contain the same strings in the same
+// order.
+func stringsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
 	}
+	return true
+}
 
-	// If it is local then return the mapper from the store.
-	m, err := s.TSDBStore.CreateMapper(sh.ID, stmt, chunkSize)
+// createRemoteMapper returns a mapper for a shard on a remote node. The
+// streaming mapper protocol is used if the node supports it.
+func (s *ShardMapper) createRemoteMapper(nodeID, shardID uint64, stmt influxql.Statement, chunkSize int) (tsdb.Mapper, error) {
+	conn, err := s.dial(nodeID, MapperMuxHeader)
 	if err != nil {
 		return nil, err
 	}
 
+	// Nodes that support the streaming protocol announce its version. Older
+	// nodes close the connection so fall back to the JSON protocol.
+	var version [1]byte
+	conn.SetReadDeadline(time.Now().Add(s.timeout))
+	if _, err := io.ReadFull(conn, version[:]); err == io.EOF {
+		conn.Close()
+		if conn, err = s.dial(nodeID, MuxHeader); err != nil {
+			return nil, err
+		}
+		conn.SetDeadline(time.Now().Add(s.timeout))
+
+		m := newLegacyRemoteMapper(conn, shardID, stmt, chunkSize)
+		m.nodeID, m.health = nodeID, s.Health
+		return m, nil
+	} else if err != nil {
+		conn.Close()
+		return nil, err
+	} else if version[0] != mapperProtocolVersion {
+		conn.Close()
+		return nil, fmt.Errorf("unsupported mapper protocol version: %d", version[0])
+	}
+
+	m := NewRemoteMapper(conn, shardID, stmt, chunkSize)
+	m.nodeID, m.health = nodeID, s.Health
+	m.timeout, m.credit = s.timeout, s.Credit
 	return m, nil
 }
 
-func (s *ShardMapper) dial(nodeID uint64) (net.Conn, error) {
+func (s *ShardMapper) dial(nodeID uint64, header byte) (net.Conn, error) {
 	ni, err := s.MetaStore.Node(nodeID)
 	if err != nil {
 		return nil, err
 	}
-	conn, err := net.Dial("tcp", ni.Host)
+
+	// Write the cluster multiplexing header byte
+	return tcp.DialTLS("tcp", ni.Host, header, 0, s.TLSConfig)
+}
+
+// RemoteMapper implements the tsdb.Mapper interface using the streaming
+// mapper protocol. The remote node streams chunks while it has credit and
+// credit is granted as the chunks are consumed. Closing the mapper before
+// the stream ends cancels it on the remote node.
+type RemoteMapper struct {
+	shardID   uint64
+	stmt      influxql.Statement
+	chunkSize int
+
+	tagsets []string
+	fields  []string
+
+	conn    net.Conn
+	timeout time.Duration // maximum wait for each message, zero means no timeout
+
+	credit   int  // chunks the remote node can send before waiting
+	consumed int  // chunks consumed since credit was last granted
+	done     bool // true once the stream has ended
+
+	nodeID uint64
+	health *HealthTracker // records the latency of the first response
+
+	unmarshallers []tsdb.UnmarshalFunc // Mapping-specific unmarshal functions.
+}
+
+// NewRemoteMapper returns a new remote mapper using the given connection.
+func NewRemoteMapper(c net.Conn, shardID uint64, stmt influxql.Statement, chunkSize int) *RemoteMapper {
+	return &RemoteMapper{
+		conn:      c,
+		shardID:   shardID,
+		stmt:      stmt,
+		chunkSize: chunkSize,
+		credit:    DefaultMapperCredit,
+	}
+}
+
+// Open sends the map request to the remote node and reads the tag sets and
+// fields of the mapper.
+func (r *RemoteMapper) Open() (err error) {
+	start := time.Now()
+	defer func() {
+		if err != nil {
+			r.done = true
+			r.conn.Close()
+		}
+	}()
+
+	// Build Map request.
+	var request MapShardRequest
+	request.SetShardID(r.shardID)
+	request.SetQuery(r.stmt.String())
+	request.SetChunkSize(int32(r.chunkSize))
+	request.SetCredit(r.credit)
+
+	// Write request.
+	r.setDeadline()
+	if err := writeMessage(r.conn, mapShardStreamRequestMessage, &request); err != nil {
+		if r.health != nil {
+			r.health.Failure(r.nodeID)
+		}
+		return err
+	}
+
+	// Read the response.
+	typ, buf, err := ReadTLV(r.conn)
+	if err != nil {
+		if r.health != nil {
+			r.health.Failure(r.nodeID)
+		}
+		return err
+	}
+	if r.health != nil {
+		r.health.Success(r.nodeID, time.Since(start))
+	}
+
+	if typ != mapShardStreamResponseMessage {
+		return fmt.Errorf("unexpected mapper message type: %d", typ)
+	}
+
+	var response MapShardResponse
+	if err := response.UnmarshalBinary(buf); err != nil {
+		return err
+	} else if response.Code() != 0 {
+		return fmt.Errorf("error code %d: %s", response.Code(), response.Message())
+	}
+	r.tagsets = response.TagSets()
+	r.fields = response.Fields()
+
+	// Set up each mapping function for this statement.
+	if stmt, ok := r.stmt.(*influxql.SelectStatement); ok {
+		for _, c := range stmt.FunctionCalls() {
+			fn, err := tsdb.InitializeUnmarshaller(c)
+			if err != nil {
+				return err
+			}
+			r.unmarshallers = append(r.unmarshallers, fn)
+		}
+	}
+
+	return nil
+}
+
+// TagSets returns the TagSets
+func (r *RemoteMapper) TagSets() []string {
+	return r.tagsets
+}
+
+// Fields returns RemoteMapper's Fields
+func (r *RemoteMapper) Fields() []string {
+	return r.fields
+}
+
+// NextChunk returns the next chunk streamed from the remote node. Returns nil
+// once the stream has ended.
+func (r *RemoteMapper) NextChunk() (chunk interface{}, err error) {
+	if r.done {
+		return nil, nil
+	}
+
+	r.setDeadline()
+	typ, buf, err := ReadTLV(r.conn)
 	if err != nil {
 		return nil, err
 	}
 
-	// Write the cluster multiplexing header byte
-	conn.Write([]byte{MuxHeader})
+	switch typ {
+	case mapShardStreamChunkMessage:
+		var c MapperChunk
+		if err := c.UnmarshalBinary(buf); err != nil {
+			return nil, err
+		}
+		mo, err := c.Output(r.unmarshallers)
+		if err != nil {
+			return nil, err
+		}
+
+		// Grant more credit once half of it has been consumed so the remote
+		// node can keep streaming.
+		r.consumed++
+		if r.consumed >= (r.credit+1)/2 {
+			var b [4]byte
+			binary.BigEndian.PutUint32(b[:], uint32(r.consumed))
+			if err := WriteTLV(r.conn, mapShardStreamCreditMessage, b[:]); err != nil {
+				return nil, err
+			}
+			r.consumed = 0
+		}
+		return mo, nil
+
+	case mapShardStreamResponseMessage:
+		r.done = true
+
+		var response MapShardResponse
+		if err := response.UnmarshalBinary(buf); err != nil {
+			return nil, err
+		} else if response.Code() != 0 {
+			return nil, fmt.Errorf("error code %d: %s", response.Code(), response.Message())
+		}
+		return nil, nil
 
-	return conn, nil
+	default:
+		return nil, fmt.Errorf("unexpected mapper message type: %d", typ)
+	}
 }
 
-// RemoteMapper implements the tsdb.Mapper interface. It connects to a remote node,
-// sends a query, and interprets the stream of data that comes back.
-type RemoteMapper struct {
+// Close closes the mapper. If the stream hasn't ended then the remote node is
+// told to stop mapping.
+func (r *RemoteMapper) Close() {
+	if !r.done {
+		r.done = true
+		r.setDeadline()
+		WriteTLV(r.conn, mapShardStreamCancelMessage, nil)
+	}
+	r.conn.Close()
+}
+
+// setDeadline sets the deadline for the next message.
+func (r *RemoteMapper) setDeadline() {
+	if r.timeout > 0 {
+		r.conn.SetDeadline(time.Now().Add(r.timeout))
+	}
+}
+
+// legacyRemoteMapper implements the tsdb.Mapper interface using the JSON
+// protocol. It's used with nodes that don't support the streaming protocol.
+type legacyRemoteMapper struct {
 	shardID   uint64
 	stmt      influxql.Statement
 	chunkSize int
@@ -91,12 +447,15 @@ type RemoteMapper struct {
 	conn             net.Conn
 	bufferedResponse *MapShardResponse
 
+	nodeID uint64
+	health *HealthTracker // records the latency of the first response
+
 	unmarshallers []tsdb.UnmarshalFunc // Mapping-specific unmarshal functions.
 }
 
-// NewRemoteMapper returns a new remote mapper using the given connection.
-func NewRemoteMapper(c net.Conn, shardID uint64, stmt influxql.Statement, chunkSize int) *RemoteMapper {
-	return &RemoteMapper{
+// newLegacyRemoteMapper returns a new remote mapper using the given connection.
+func newLegacyRemoteMapper(c net.Conn, shardID uint64, stmt influxql.Statement, chunkSize int) *legacyRemoteMapper {
+	return &legacyRemoteMapper{
 		conn:      c,
 		shardID:   shardID,
 		stmt:      stmt,
@@ -105,7 +464,8 @@ func NewRemoteMapper(c net.Conn, shardID uint64, stmt influxql.Statement, chunkS
 }
 
 // Open connects to the remote node and starts receiving data.
-func (r *RemoteMapper) Open() (err error) {
+func (r *legacyRemoteMapper) Open() (err error) {
+	start := time.Now()
 	defer func() {
 		if err != nil {
 			r.conn.Close()
@@ -126,14 +486,23 @@ func (r *RemoteMapper) Open() (err error) {
 
 	// Write request.
 	if err := WriteTLV(r.conn, mapShardRequestMessage, buf); err != nil {
+		if r.health != nil {
+			r.health.Failure(r.nodeID)
+		}
 		return err
 	}
 
 	// Read the response.
 	_, buf, err = ReadTLV(r.conn)
 	if err != nil {
+		if r.health != nil {
+			r.health.Failure(r.nodeID)
+		}
 		return err
 	}
+	if r.health != nil {
+		r.health.Success(r.nodeID, time.Since(start))
+	}
 
 	// Unmarshal response.
 	r.bufferedResponse = &MapShardResponse{}
@@ -164,17 +533,17 @@ func (r *RemoteMapper) Open() (err error) {
 }
 
 // TagSets returns the TagSets
-func (r *RemoteMapper) TagSets() []string {
+func (r *legacyRemoteMapper) TagSets() []string {
 	return r.tagsets
 }
 
-// Fields returns RemoteMapper's Fields
-func (r *RemoteMapper) Fields() []string {
+// Fields returns legacyRemoteMapper's Fields
+func (r *legacyRemoteMapper) Fields() []string {
 	return r.fields
 }
 
 // NextChunk returns the next chunk read from the remote node to the client.
-func (r *RemoteMapper) NextChunk() (chunk interface{}, err error) {
+func (r *legacyRemoteMapper) NextChunk() (chunk interface{}, err error) {
 	var response *MapShardResponse
 	if r.bufferedResponse != nil {
 		response = r.bufferedResponse
@@ -254,6 +623,6 @@ func (r *RemoteMapper) NextChunk() (chunk interface{}, err error) {
 }
 
 // Close the Mapper
-func (r *RemoteMapper) Close() {
+func (r *legacyRemoteMapper) Close() {
 	r.conn.Close()
 }
@@ -5,10 +5,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"log"
 	"net"
+	"reflect"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/influxdb/influxdb/influxql"
+	"github.com/influxdb/influxdb/meta"
+	"github.com/influxdb/influxdb/models"
+	"github.com/influxdb/influxdb/tcp"
 	"github.com/influxdb/influxdb/tsdb"
 )
 
@@ -66,7 +74,7 @@ func TestShardWriter_RemoteMapper_Success(t *testing.T) {
 
 	c := newRemoteShardResponder([]*tsdb.MapperOutput{expOutput, nil}, expTagSets)
 
-	r := NewRemoteMapper(c, 1234, mustParseStmt("SELECT * FROM CPU"), 10)
+	r := newLegacyRemoteMapper(c, 1234, mustParseStmt("SELECT * FROM CPU"), 10)
 	if err := r.Open(); err != nil {
 		t.Fatalf("failed to open remote mapper: %s", err.Error())
 	}
@@ -98,6 +106,273 @@ func TestShardWriter_RemoteMapper_Success(t *testing.T) {
 	}
 }
 
+// Ensure the shard mapper requires enough available owners for the read
+// consistency level.
+func TestShardMapper_CreateMapper_ReadConsistency(t *testing.T) {
+	sh := meta.ShardInfo{ID: 1, Owners: []meta.ShardOwner{{NodeID: 1}, {NodeID: 2}}}
+	mapper := &tsdb.RawMapper{}
+
+	s := NewShardMapper(time.Second)
+	s.MetaStore = &shardMapperMetaStore{nodeID: 1}
+	s.TSDBStore = &shardMapperTSDBStore{mapper: mapper}
+	s.Health.Failure(2)
+
+	if m, err := s.CreateMapper(sh, mustParseStmt("SELECT * FROM cpu"), 10, tsdb.ReadConsistencyOne); err != nil {
+		t.Fatal(err)
+	} else if m != mapper {
+		t.Fatalf("unexpected mapper: %#v", m)
+	}
+
+	if _, err := s.CreateMapper(sh, mustParseStmt("SELECT * FROM cpu"), 10, tsdb.ReadConsistencyQuorum); err != ErrReadConsistency {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure the shard mapper reads from several owners for the read consistency
+// level and fails if they return different data.
+func TestShardMapper_CreateMapper_ReadConsistency_Compare(t *testing.T) {
+	remote := &streamMapper{n: 3, closed: make(chan struct{})}
+	ts, host := openMapperService(remote, true)
+	defer ts.Close()
+
+	sh := meta.ShardInfo{ID: 1, Owners: []meta.ShardOwner{{NodeID: 1}, {NodeID: 2}, {NodeID: 3}}}
+	s := NewShardMapper(time.Second)
+	s.MetaStore = &shardMapperMetaStore{nodeID: 1, host: host}
+
+	// The local owner and one remote owner are read for a quorum.
+	local := &streamMapper{n: 2, closed: make(chan struct{})}
+	s.TSDBStore = &shardMapperTSDBStore{mapper: local}
+	m, err := s.CreateMapper(sh, mustParseStmt("SELECT value FROM cpu"), 10, tsdb.ReadConsistencyQuorum)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := m.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	for i := 1; i <= 2; i++ {
+		chunk, err := m.NextChunk()
+		if err != nil {
+			t.Fatalf("%d. unexpected error: %s", i, err)
+		} else if v := chunk.(*tsdb.MapperOutput).Values[0].Value; v != int64(i) {
+			t.Fatalf("%d. unexpected value: %v", i, v)
+		}
+	}
+
+	// The remote owner has more data than the local owner.
+	if _, err := m.NextChunk(); err != ErrReplicasDiffer {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure a remote mapper streams chunks from a remote node.
+func TestShardMapper_CreateMapper_Stream(t *testing.T) {
+	m := &streamMapper{n: 20, closed: make(chan struct{})}
+	s, host := openMapperService(m, true)
+	defer s.Close()
+
+	sm := NewShardMapper(time.Second)
+	sm.MetaStore = &shardMapperMetaStore{nodeID: 1, host: host}
+	sm.Credit = 2
+
+	sh := meta.ShardInfo{ID: 1, Owners: []meta.ShardOwner{{NodeID: 2}}}
+	mapper, err := sm.CreateMapper(sh, mustParseStmt("SELECT value FROM cpu"), 10, tsdb.ReadConsistencyOne)
+	if err != nil {
+		t.Fatal(err)
+	} else if _, ok := mapper.(*RemoteMapper); !ok {
+		t.Fatalf("unexpected mapper type: %T", mapper)
+	}
+	defer mapper.Close()
+
+	if err := mapper.Open(); err != nil {
+		t.Fatal(err)
+	} else if tagsets := mapper.TagSets(); !reflect.DeepEqual(tagsets, []string{"tagsetA"}) {
+		t.Fatalf("unexpected tagsets: %v", tagsets)
+	}
+
+	// Integer values are preserved by the binary encoding.
+	for i := 1; i <= 20; i++ {
+		chunk, err := mapper.NextChunk()
+		if err != nil {
+			t.Fatal(err)
+		} else if mo := chunk.(*tsdb.MapperOutput); mo.Values[0].Value != int64(i) {
+			t.Fatalf("%d. unexpected value: %#v", i, mo.Values[0].Value)
+		}
+	}
+
+	if chunk, err := mapper.NextChunk(); err != nil {
+		t.Fatal(err)
+	} else if chunk != nil {
+		t.Fatalf("unexpected chunk: %#v", chunk)
+	}
+}
+
+// Ensure closing a remote mapper stops the mapper on the remote node.
+func TestShardMapper_CreateMapper_Cancel(t *testing.T) {
+	m := &streamMapper{n: -1, closed: make(chan struct{})}
+	s, host := openMapperService(m, true)
+	defer s.Close()
+
+	sm := NewShardMapper(time.Second)
+	sm.MetaStore = &shardMapperMetaStore{nodeID: 1, host: host}
+	sm.Credit = 4
+
+	sh := meta.ShardInfo{ID: 1, Owners: []meta.ShardOwner{{NodeID: 2}}}
+	mapper, err := sm.CreateMapper(sh, mustParseStmt("SELECT value FROM cpu"), 10, tsdb.ReadConsistencyOne)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := mapper.Open(); err != nil {
+		t.Fatal(err)
+	} else if _, err := mapper.NextChunk(); err != nil {
+		t.Fatal(err)
+	}
+	mapper.Close()
+
+	select {
+	case <-m.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("remote mapper not closed")
+	}
+
+	// No more chunks are mapped than the credit allows.
+	if n := atomic.LoadInt64(&m.calls); n > 5 {
+		t.Fatalf("unexpected chunks mapped: %d", n)
+	}
+}
+
+// Ensure the JSON protocol is used with nodes that don't support streaming.
+func TestShardMapper_CreateMapper_Legacy(t *testing.T) {
+	m := &streamMapper{n: 2, closed: make(chan struct{})}
+	s, host := openMapperService(m, false)
+	defer s.Close()
+
+	sm := NewShardMapper(time.Second)
+	sm.MetaStore = &shardMapperMetaStore{nodeID: 1, host: host}
+
+	sh := meta.ShardInfo{ID: 1, Owners: []meta.ShardOwner{{NodeID: 2}}}
+	mapper, err := sm.CreateMapper(sh, mustParseStmt("SELECT value FROM cpu"), 10, tsdb.ReadConsistencyOne)
+	if err != nil {
+		t.Fatal(err)
+	} else if _, ok := mapper.(*legacyRemoteMapper); !ok {
+		t.Fatalf("unexpected mapper type: %T", mapper)
+	}
+	defer mapper.Close()
+
+	if err := mapper.Open(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 2; i++ {
+		if chunk, err := mapper.NextChunk(); err != nil {
+			t.Fatal(err)
+		} else if mo := chunk.(*tsdb.MapperOutput); mo.Values[0].Value != float64(i) {
+			t.Fatalf("%d. unexpected value: %#v", i, mo.Values[0].Value)
+		}
+	}
+	if chunk, err := mapper.NextChunk(); err != nil || chunk != nil {
+		t.Fatalf("unexpected chunk: %#v, %v", chunk, err)
+	}
+}
+
+// mapperService is a cluster service serving mappers on a local listener.
+type mapperService struct {
+	*Service
+	ln net.Listener
+}
+
+// Close closes the listener and the service.
+func (s *mapperService) Close() error {
+	s.ln.Close()
+	return s.Service.Close()
+}
+
+// openMapperService opens a cluster service which maps shards with m. The
+// streaming mapper protocol is only served if stream is true. Returns the
+// service and its address.
+func openMapperService(m tsdb.Mapper, stream bool) (*mapperService, string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+
+	mux := tcp.NewMux()
+	mux.Logger = log.New(ioutil.Discard, "", 0)
+
+	s := NewService(Config{})
+	s.Logger = log.New(ioutil.Discard, "", 0)
+	s.Listener = mux.Listen(MuxHeader)
+	if stream {
+		s.MapperListener = mux.Listen(MapperMuxHeader)
+	}
+	s.TSDBStore = &mapperTSDBStore{mapper: m}
+	go mux.Serve(ln)
+
+	if err := s.Open(); err != nil {
+		panic(err)
+	}
+	return &mapperService{Service: s, ln: ln}, ln.Addr().String()
+}
+
+// streamMapper is a mapper returning n chunks, or chunks forever if n is -1.
+type streamMapper struct {
+	n      int64
+	calls  int64
+	closed chan struct{}
+}
+
+func (m *streamMapper) Open() error       { return nil }
+func (m *streamMapper) TagSets() []string { return []string{"tagsetA"} }
+func (m *streamMapper) Fields() []string  { return []string{"value"} }
+func (m *streamMapper) Close()            { close(m.closed) }
+func (m *streamMapper) NextChunk() (interface{}, error) {
+	i := atomic.AddInt64(&m.calls, 1)
+	if m.n >= 0 && i > m.n {
+		return nil, nil
+	}
+	return &tsdb.MapperOutput{
+		Name:   "cpu",
+		Fields: []string{"value"},
+		Values: []*tsdb.MapperValue{{Time: i, Value: i}},
+	}, nil
+}
+
+// mapperTSDBStore is a store that only creates mappers.
+type mapperTSDBStore struct {
+	mapper tsdb.Mapper
+}
+
+func (s *mapperTSDBStore) CreateShard(database, policy string, shardID uint64) error { return nil }
+func (s *mapperTSDBStore) WriteToShard(shardID uint64, points []models.Point) error  { return nil }
+func (s *mapperTSDBStore) CreateMapper(shardID uint64, stmt influxql.Statement, chunkSize int) (tsdb.Mapper, error) {
+	return s.mapper, nil
+}
+func (s *mapperTSDBStore) ShardDigest(shardID uint64, min, max int64, depth int) ([]*tsdb.SeriesDigest, error) {
+	return nil, nil
+}
+func (s *mapperTSDBStore) ShardSeriesPoints(shardID uint64, key string, min, max int64) ([]models.Point, error) {
+	return nil, nil
+}
+
+type shardMapperMetaStore struct {
+	nodeID uint64
+	host   string
+}
+
+func (m *shardMapperMetaStore) NodeID() uint64 { return m.nodeID }
+func (m *shardMapperMetaStore) Node(id uint64) (*meta.NodeInfo, error) {
+	if m.host == "" {
+		return nil, fmt.Errorf("node not found: %d", id)
+	}
+	return &meta.NodeInfo{ID: id, Host: m.host}, nil
+}
+
+type shardMapperTSDBStore struct {
+	mapper tsdb.Mapper
+}
+
+func (s *shardMapperTSDBStore) CreateMapper(shardID uint64, stmt influxql.Statement, chunkSize int) (tsdb.Mapper, error) {
+	return s.mapper, nil
+}
+
 // mustParseStmt parses a single statement or panics.
 func mustParseStmt(stmt string) influxql.Statement {
 	q, err := influxql.ParseQuery(stmt)
@@ -0,0 +1,125 @@
+package cluster
+
+import (
+	"crypto/tls"
+	"encoding"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/influxdb/influxdb/meta"
+	"github.com/influxdb/influxdb/models"
+	"github.com/influxdb/influxdb/tsdb"
+	"gopkg.in/fatih/pool.v2"
+)
+
+// ShardReader reads the digests and points of shards on remote nodes.
+type ShardReader struct {
+	pool    *clientPool
+	timeout time.Duration
+
+	MetaStore interface {
+		Node(id uint64) (ni *meta.NodeInfo, err error)
+	}
+
+	// If set, connections to other nodes are made over TLS.
+	TLSConfig *tls.Config
+}
+
+// NewShardReader returns a new instance of ShardReader.
+func NewShardReader(timeout time.Duration) *ShardReader {
+	return &ShardReader{
+		pool:    newClientPool(),
+		timeout: timeout,
+	}
+}
+
+// ShardDigest returns the digests of the series in a shard on node ownerID
+// between min, inclusive, and max, exclusive.
+func (r *ShardReader) ShardDigest(ownerID, shardID uint64, min, max int64, depth int) ([]*tsdb.SeriesDigest, error) {
+	var req ShardDigestRequest
+	req.SetShardID(shardID)
+	req.SetTimeRange(min, max)
+	req.SetDepth(depth)
+
+	var resp ShardDigestResponse
+	if err := r.call(ownerID, shardDigestRequestMessage, &req, &resp); err != nil {
+		return nil, err
+	} else if resp.Code() != 0 {
+		return nil, fmt.Errorf("error code %d: %s", resp.Code(), resp.Message())
+	}
+	return resp.Digests(), nil
+}
+
+// SeriesPoints returns the points of a series in a shard on node ownerID
+// between min, inclusive, and max, exclusive.
+func (r *ShardReader) SeriesPoints(ownerID, shardID uint64, key string, min, max int64) ([]models.Point, error) {
+	var req SeriesPointsRequest
+	req.SetShardID(shardID)
+	req.SetKey(key)
+	req.SetTimeRange(min, max)
+
+	var resp SeriesPointsResponse
+	if err := r.call(ownerID, seriesPointsRequestMessage, &req, &resp); err != nil {
+		return nil, err
+	} else if resp.Code() != 0 {
+		return nil, fmt.Errorf("error code %d: %s", resp.Code(), resp.Message())
+	}
+	return resp.Points()
+}
+
+// call sends a request to a node and reads the response.
+func (r *ShardReader) call(nodeID uint64, typ byte, req encoding.BinaryMarshaler, resp encoding.BinaryUnmarshaler) error {
+	c, err := r.dial(nodeID)
+	if err != nil {
+		return err
+	}
+
+	conn, ok := c.(*pool.PoolConn)
+	if !ok {
+		panic("wrong connection type")
+	}
+	defer conn.Close() // return to pool
+
+	// Write request.
+	conn.SetWriteDeadline(time.Now().Add(r.timeout))
+	if err := writeMessage(conn, typ, req); err != nil {
+		conn.MarkUnusable()
+		return err
+	}
+
+	// Read the response.
+	conn.SetReadDeadline(time.Now().Add(r.timeout))
+	_, buf, err := ReadTLV(conn)
+	if err != nil {
+		conn.MarkUnusable()
+		return err
+	}
+	return resp.UnmarshalBinary(buf)
+}
+
+func (r *ShardReader) dial(nodeID uint64) (net.Conn, error) {
+	// If we don't have a connection pool for that addr yet, create one
+	_, ok := r.pool.getPool(nodeID)
+	if !ok {
+		factory := &connFactory{nodeID: nodeID, clientPool: r.pool, timeout: r.timeout, tlsConfig: r.TLSConfig}
+		factory.metaStore = r.MetaStore
+
+		p, err := pool.NewChannelPool(1, 3, factory.dial)
+		if err != nil {
+			return nil, err
+		}
+		r.pool.setPool(nodeID, p)
+	}
+	return r.pool.conn(nodeID)
+}
+
+// Close closes ShardReader's pool
+func (r *ShardReader) Close() error {
+	if r.pool == nil {
+		return fmt.Errorf("client already closed")
+	}
+	r.pool.close()
+	r.pool = nil
+	return nil
+}
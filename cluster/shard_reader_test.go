@@ -0,0 +1,81 @@
+package cluster_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdb/influxdb/cluster"
+	"github.com/influxdb/influxdb/models"
+	"github.com/influxdb/influxdb/tsdb"
+)
+
+// Ensure the shard reader can read the digests of a remote shard.
+func TestShardReader_ShardDigest(t *testing.T) {
+	ts := newTestWriteService(nil)
+	ts.shardDigestFunc = func(shardID uint64, min, max int64, depth int) ([]*tsdb.SeriesDigest, error) {
+		if shardID != 1 || min != 10 || max != 20 || depth != 2 {
+			return nil, fmt.Errorf("unexpected request: %d %d %d %d", shardID, min, max, depth)
+		}
+		return []*tsdb.SeriesDigest{{Key: "cpu,host=server01", Hashes: []uint64{1, 2, 3}}}, nil
+	}
+	s := cluster.NewService(cluster.Config{})
+	s.Listener = ts.muxln
+	s.TSDBStore = ts
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	defer ts.Close()
+
+	r := cluster.NewShardReader(time.Minute)
+	r.MetaStore = &metaStore{host: ts.ln.Addr().String()}
+	defer r.Close()
+
+	a, err := r.ShardDigest(2, 1, 10, 20, 2)
+	if err != nil {
+		t.Fatal(err)
+	} else if exp := []*tsdb.SeriesDigest{{Key: "cpu,host=server01", Hashes: []uint64{1, 2, 3}}}; !reflect.DeepEqual(a, exp) {
+		t.Fatalf("unexpected digests: %#v", a)
+	}
+
+	// Errors are returned to the caller.
+	if _, err := r.ShardDigest(2, 3, 10, 20, 2); err == nil || !strings.Contains(err.Error(), "unexpected request") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure the shard reader can read the points of a series in a remote shard.
+func TestShardReader_SeriesPoints(t *testing.T) {
+	now := time.Unix(0, 15)
+	pt := models.NewPoint("cpu", models.Tags{"host": "server01"}, map[string]interface{}{"value": 1.5}, now)
+
+	ts := newTestWriteService(nil)
+	ts.seriesPointsFunc = func(shardID uint64, key string, min, max int64) ([]models.Point, error) {
+		if shardID != 1 || key != "cpu,host=server01" || min != 10 || max != 20 {
+			return nil, fmt.Errorf("unexpected request: %d %s %d %d", shardID, key, min, max)
+		}
+		return []models.Point{pt}, nil
+	}
+	s := cluster.NewService(cluster.Config{})
+	s.Listener = ts.muxln
+	s.TSDBStore = ts
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	defer ts.Close()
+
+	r := cluster.NewShardReader(time.Minute)
+	r.MetaStore = &metaStore{host: ts.ln.Addr().String()}
+	defer r.Close()
+
+	points, err := r.SeriesPoints(2, 1, "cpu,host=server01", 10, 20)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(points) != 1 || points[0].String() != pt.String() {
+		t.Fatalf("unexpected points: %v", points)
+	}
+}
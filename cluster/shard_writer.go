@@ -1,12 +1,21 @@
 package cluster
 
 import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"expvar"
 	"fmt"
+	"io"
 	"net"
+	"strconv"
+	"sync"
 	"time"
 
+	"github.com/influxdb/influxdb"
 	"github.com/influxdb/influxdb/meta"
 	"github.com/influxdb/influxdb/models"
+	"github.com/influxdb/influxdb/tcp"
 	"gopkg.in/fatih/pool.v2"
 )
 
@@ -15,104 +24,515 @@ const (
 	writeShardResponseMessage
 	mapShardRequestMessage
 	mapShardResponseMessage
+	shardDigestRequestMessage
+	shardDigestResponseMessage
+	seriesPointsRequestMessage
+	seriesPointsResponseMessage
+	negotiateRequestMessage
+	negotiateResponseMessage
+	writeShardBatchRequestMessage
+	writeShardBatchResponseMessage
+	mapShardStreamRequestMessage
+	mapShardStreamResponseMessage
+	mapShardStreamChunkMessage
+	mapShardStreamCreditMessage
+	mapShardStreamCancelMessage
 )
 
-// ShardWriter writes a set of points to a shard.
+// Statistics maintained for each node written to by the ShardWriter.
+const (
+	writeBatchReq        = "write_batch_req"
+	writeBatchFail       = "write_batch_fail"
+	writeBatchWrites     = "write_batch_writes"
+	writeBatchPoints     = "write_batch_points"
+	writeBatchBytes      = "write_batch_bytes"
+	writeBatchBytesSent  = "write_batch_bytes_sent"
+	writeBatchDurationNs = "write_batch_duration_ns"
+	writeLegacyReq       = "write_legacy_req"
+)
+
+// ErrShardWriterClosed is returned when writing to a closed ShardWriter.
+var ErrShardWriterClosed = errors.New("shard writer closed")
+
+// errBatchUnsupported is returned by negotiate when the node doesn't support
+// batches.
+var errBatchUnsupported = errors.New("write batches not supported")
+
+// ShardWriter writes a set of points to a shard. Writes to the same node are
+// coalesced into batches which are sent asynchronously.
 type ShardWriter struct {
+	mu       sync.Mutex
+	wg       sync.WaitGroup
+	closing  chan struct{}
+	batchers map[uint64]*writeBatcher
+
 	pool    *clientPool
 	timeout time.Duration
 
+	// BatchSize is the maximum number of points sent to a node in a batch.
+	// Larger writes are sent in a batch of their own.
+	BatchSize int
+
+	// MaxInFlight is the maximum number of batches being sent to a node at
+	// once. Writes are queued while all of them are in flight.
+	MaxInFlight int
+
+	// Compression is the compression requested for batches. Batches are sent
+	// uncompressed to nodes that don't support it.
+	Compression string
+
 	MetaStore interface {
 		Node(id uint64) (ni *meta.NodeInfo, err error)
 	}
+
+	// Health tracks the latency and availability of the remote owners.
+	Health *HealthTracker
+
+	// If set, connections to other nodes are made over TLS.
+	TLSConfig *tls.Config
 }
 
 // NewShardWriter returns a new instance of ShardWriter.
 func NewShardWriter(timeout time.Duration) *ShardWriter {
 	return &ShardWriter{
-		pool:    newClientPool(),
-		timeout: timeout,
+		closing:     make(chan struct{}),
+		batchers:    make(map[uint64]*writeBatcher),
+		Health:      NewHealthTracker(),
+		pool:        newClientPool(),
+		timeout:     timeout,
+		BatchSize:   DefaultWriteBatchSize,
+		MaxInFlight: DefaultWriteMaxInFlight,
+		Compression: DefaultWriteCompression,
 	}
 }
 
-// WriteShard writes time series points to a shard
+// WriteShard writes time series points to a shard. The write is added to the
+// next batch sent to the owner and WriteShard blocks until the owner responds.
 func (w *ShardWriter) WriteShard(shardID, ownerID uint64, points []models.Point) error {
-	c, err := w.dial(ownerID)
+	b, err := w.batcher(ownerID)
 	if err != nil {
 		return err
 	}
+	return b.write(shardID, points)
+}
 
-	conn, ok := c.(*pool.PoolConn)
+// batcher returns the batcher for a node, starting it if it doesn't exist.
+func (w *ShardWriter) batcher(nodeID uint64) (*writeBatcher, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	select {
+	case <-w.closing:
+		return nil, ErrShardWriterClosed
+	default:
+	}
+
+	b := w.batchers[nodeID]
+	if b == nil {
+		key := fmt.Sprintf("shard_writer:node:%d", nodeID)
+		tags := map[string]string{"nodeID": strconv.FormatUint(nodeID, 10)}
+
+		b = &writeBatcher{
+			w:        w,
+			nodeID:   nodeID,
+			requests: make(chan *batchWrite),
+			inflight: make(chan struct{}, w.MaxInFlight),
+			statMap:  influxdb.NewStatistics(key, "shard_writer", tags),
+		}
+		w.batchers[nodeID] = b
+
+		w.wg.Add(1)
+		go b.run()
+	}
+	return b, nil
+}
+
+// Ping returns an error if a connection can't be made to a node. A new
+// connection is used so that stale pooled connections aren't mistaken for a
+// reachable node.
+func (w *ShardWriter) Ping(nodeID uint64) error {
+	factory := &connFactory{nodeID: nodeID, clientPool: w.pool, timeout: w.timeout, tlsConfig: w.TLSConfig}
+	factory.metaStore = w.MetaStore
+
+	start := time.Now()
+	conn, err := factory.dial()
+	if err != nil {
+		w.Health.Failure(nodeID)
+		return err
+	}
+	w.Health.Success(nodeID, time.Since(start))
+	return conn.Close()
+}
+
+func (w *ShardWriter) dial(nodeID uint64) (net.Conn, error) {
+	// If we don't have a connection pool for that addr yet, create one
+	_, ok := w.pool.getPool(nodeID)
 	if !ok {
-		panic("wrong connection type")
+		factory := &connFactory{nodeID: nodeID, clientPool: w.pool, timeout: w.timeout, tlsConfig: w.TLSConfig}
+		factory.metaStore = w.MetaStore
+
+		p, err := pool.NewChannelPool(1, 3, factory.dial)
+		if err != nil {
+			return nil, err
+		}
+		w.pool.setPool(nodeID, p)
+	}
+	return w.pool.conn(nodeID)
+}
+
+// Close stops the batchers, waits for the batches in flight and closes
+// ShardWriter's pool
+func (w *ShardWriter) Close() error {
+	if w.pool == nil {
+		return fmt.Errorf("client already closed")
+	}
+
+	w.mu.Lock()
+	close(w.closing)
+	w.mu.Unlock()
+	w.wg.Wait()
+
+	w.pool.close()
+	w.pool = nil
+	return nil
+}
+
+// writeBatcher coalesces the writes to a node into batches.
+type writeBatcher struct {
+	w      *ShardWriter
+	nodeID uint64
+
+	requests chan *batchWrite
+	inflight chan struct{}
+
+	// Compression negotiated with the node at host. If legacy is set the
+	// node doesn't support batches and each write is sent in its own request.
+	mu          sync.Mutex
+	host        string
+	negotiated  bool
+	legacy      bool
+	compression byte
+
+	statMap *expvar.Map
+}
+
+// batchWrite is a single write waiting to be sent in a batch.
+type batchWrite struct {
+	shardID uint64
+	points  []models.Point
+	err     chan error
+}
+
+// write queues a write for the next batch and waits for its result.
+func (b *writeBatcher) write(shardID uint64, points []models.Point) error {
+	r := &batchWrite{shardID: shardID, points: points, err: make(chan error, 1)}
+	select {
+	case b.requests <- r:
+	case <-b.w.closing:
+		return ErrShardWriterClosed
+	}
+	return <-r.err
+}
+
+// run collects queued writes into batches and sends them while fewer than
+// the maximum number of batches are in flight.
+func (b *writeBatcher) run() {
+	defer b.w.wg.Done()
+
+	for {
+		var batch []*batchWrite
+		select {
+		case r := <-b.requests:
+			batch = append(batch, r)
+		case <-b.w.closing:
+			return
+		}
+
+		// Wait for a batch to complete if too many are in flight. Writes
+		// queued in the meantime are added to this batch.
+		select {
+		case b.inflight <- struct{}{}:
+		case <-b.w.closing:
+			batch[0].err <- ErrShardWriterClosed
+			return
+		}
+
+		n := len(batch[0].points)
+	loop:
+		for n < b.w.BatchSize {
+			select {
+			case r := <-b.requests:
+				batch = append(batch, r)
+				n += len(r.points)
+			default:
+				break loop
+			}
+		}
+
+		b.w.wg.Add(1)
+		go func(batch []*batchWrite) {
+			defer b.w.wg.Done()
+			defer func() { <-b.inflight }()
+			b.send(batch)
+		}(batch)
+	}
+}
+
+// send writes a batch to the node and returns the result of each write to
+// its caller.
+func (b *writeBatcher) send(batch []*batchWrite) {
+	start := time.Now()
+	errs, err := b.writeBatch(batch)
+	if err != nil {
+		b.w.Health.Failure(b.nodeID)
+		b.statMap.Add(writeBatchFail, 1)
+
+		// Negotiate again in case the node was replaced. Nodes without
+		// batches aren't asked again unless their host changes.
+		b.mu.Lock()
+		b.negotiated = false
+		b.mu.Unlock()
+
+		for _, r := range batch {
+			r.err <- err
+		}
+		return
+	}
+
+	d := time.Since(start)
+	b.w.Health.Success(b.nodeID, d)
+	b.statMap.Add(writeBatchDurationNs, int64(d))
+	for i, r := range batch {
+		r.err <- errs[i]
+	}
+}
+
+// writeBatch sends a batch to the node. Returns the error of each write or an
+// error if the batch couldn't be sent.
+func (b *writeBatcher) writeBatch(batch []*batchWrite) ([]error, error) {
+	if legacy, err := b.isLegacy(); err != nil {
+		return nil, err
+	} else if legacy {
+		return b.writeLegacy(batch)
+	}
+
+	conn, err := b.conn()
+	if err != nil {
+		return nil, err
 	}
 	defer func(conn net.Conn) {
 		conn.Close() // return to pool
 	}(conn)
 
-	// Build write request.
-	var request WriteShardRequest
-	request.SetShardID(shardID)
-	request.AddPoints(points)
+	compression, err := b.negotiate(conn)
+	if err == errBatchUnsupported {
+		// The node may still answer the negotiate request later so the
+		// connection can't be reused.
+		conn.MarkUnusable()
+		return b.writeLegacy(batch)
+	} else if err != nil {
+		conn.MarkUnusable()
+		return nil, err
+	}
+
+	// Build batch request.
+	var request WriteShardBatchRequest
+	var points int
+	for _, r := range batch {
+		request.AddRequest(r.shardID, r.points)
+		points += len(r.points)
+	}
 
-	// Marshal into protocol buffers.
+	// Marshal into protocol buffers and compress.
 	buf, err := request.MarshalBinary()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	b.statMap.Add(writeBatchReq, 1)
+	b.statMap.Add(writeBatchWrites, int64(len(batch)))
+	b.statMap.Add(writeBatchPoints, int64(points))
+	b.statMap.Add(writeBatchBytes, int64(len(buf)))
+
+	if buf, err = encodeBatch(compression, buf); err != nil {
+		return nil, err
 	}
+	b.statMap.Add(writeBatchBytesSent, int64(len(buf)))
 
 	// Write request.
-	conn.SetWriteDeadline(time.Now().Add(w.timeout))
-	if err := WriteTLV(conn, writeShardRequestMessage, buf); err != nil {
+	conn.SetWriteDeadline(time.Now().Add(b.w.timeout))
+	if err := WriteTLV(conn, writeShardBatchRequestMessage, buf); err != nil {
 		conn.MarkUnusable()
-		return err
+		return nil, err
 	}
 
 	// Read the response.
-	conn.SetReadDeadline(time.Now().Add(w.timeout))
+	conn.SetReadDeadline(time.Now().Add(b.w.timeout))
 	_, buf, err = ReadTLV(conn)
 	if err != nil {
 		conn.MarkUnusable()
-		return err
+		return nil, err
 	}
 
 	// Unmarshal response.
-	var response WriteShardResponse
+	var response WriteShardBatchResponse
 	if err := response.UnmarshalBinary(buf); err != nil {
-		return err
+		return nil, err
 	}
 
+	errs := make([]error, len(batch))
 	if response.Code() != 0 {
-		return fmt.Errorf("error code %d: %s", response.Code(), response.Message())
+		err := fmt.Errorf("error code %d: %s", response.Code(), response.Message())
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs, nil
 	}
 
-	return nil
+	responses := response.Responses()
+	if len(responses) != len(batch) {
+		return nil, fmt.Errorf("expected %d write responses but got %d", len(batch), len(responses))
+	}
+	for i, resp := range responses {
+		if resp.Code() != 0 {
+			errs[i] = fmt.Errorf("error code %d: %s", resp.Code(), resp.Message())
+		}
+	}
+	return errs, nil
 }
 
-func (w *ShardWriter) dial(nodeID uint64) (net.Conn, error) {
-	// If we don't have a connection pool for that addr yet, create one
-	_, ok := w.pool.getPool(nodeID)
-	if !ok {
-		factory := &connFactory{nodeID: nodeID, clientPool: w.pool, timeout: w.timeout}
-		factory.metaStore = w.MetaStore
+// writeLegacy sends each write of a batch in its own request to a node that
+// doesn't support batches.
+func (b *writeBatcher) writeLegacy(batch []*batchWrite) ([]error, error) {
+	conn, err := b.conn()
+	if err != nil {
+		return nil, err
+	}
+	defer func(conn net.Conn) {
+		conn.Close() // return to pool
+	}(conn)
 
-		p, err := pool.NewChannelPool(1, 3, factory.dial)
+	errs := make([]error, len(batch))
+	for i, r := range batch {
+		var request WriteShardRequest
+		request.SetShardID(r.shardID)
+		request.AddPoints(r.points)
+
+		buf, err := request.MarshalBinary()
 		if err != nil {
 			return nil, err
 		}
-		w.pool.setPool(nodeID, p)
+		b.statMap.Add(writeLegacyReq, 1)
+		b.statMap.Add(writeBatchWrites, 1)
+		b.statMap.Add(writeBatchPoints, int64(len(r.points)))
+		b.statMap.Add(writeBatchBytes, int64(len(buf)))
+		b.statMap.Add(writeBatchBytesSent, int64(len(buf)))
+
+		conn.SetWriteDeadline(time.Now().Add(b.w.timeout))
+		if err := WriteTLV(conn, writeShardRequestMessage, buf); err != nil {
+			conn.MarkUnusable()
+			return nil, err
+		}
+
+		conn.SetReadDeadline(time.Now().Add(b.w.timeout))
+		_, buf, err = ReadTLV(conn)
+		if err != nil {
+			conn.MarkUnusable()
+			return nil, err
+		}
+
+		var response WriteShardResponse
+		if err := response.UnmarshalBinary(buf); err != nil {
+			return nil, err
+		}
+		if response.Code() != 0 {
+			errs[i] = fmt.Errorf("error code %d: %s", response.Code(), response.Message())
+		}
 	}
-	return w.pool.conn(nodeID)
+	return errs, nil
 }
 
-// Close closes ShardWriter's pool
-func (w *ShardWriter) Close() error {
-	if w.pool == nil {
-		return fmt.Errorf("client already closed")
+// isLegacy returns true if the node doesn't support batches. If the node's
+// host changed since it was last checked the pooled connections are closed
+// and the node is negotiated with again.
+func (b *writeBatcher) isLegacy() (bool, error) {
+	ni, err := b.w.MetaStore.Node(b.nodeID)
+	if err != nil {
+		return false, err
+	} else if ni == nil {
+		return false, fmt.Errorf("node %d does not exist", b.nodeID)
 	}
-	w.pool.close()
-	w.pool = nil
-	return nil
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ni.Host != b.host {
+		if b.host != "" {
+			b.w.pool.removePool(b.nodeID)
+		}
+		b.host, b.negotiated, b.legacy = ni.Host, false, false
+	}
+	return b.legacy, nil
+}
+
+// conn returns a pooled connection to the node.
+func (b *writeBatcher) conn() (*pool.PoolConn, error) {
+	c, err := b.w.dial(b.nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, ok := c.(*pool.PoolConn)
+	if !ok {
+		panic("wrong connection type")
+	}
+	return conn, nil
+}
+
+// negotiate returns the compression to use for batches sent to the node. The
+// node is asked which of the configured compression and no compression it
+// supports the first time. Nodes that predate batches don't answer unknown
+// requests, so a timeout or another response type means the node only
+// supports single writes and errBatchUnsupported is returned.
+func (b *writeBatcher) negotiate(conn net.Conn) (byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.negotiated {
+		return b.compression, nil
+	}
+
+	offered := []byte{compressions[b.w.Compression]}
+	if offered[0] != compressionNone {
+		offered = append(offered, compressionNone)
+	}
+
+	conn.SetWriteDeadline(time.Now().Add(b.w.timeout))
+	if err := WriteTLV(conn, negotiateRequestMessage, offered); err != nil {
+		return 0, err
+	}
+
+	// Read the message type separately so that a timeout can be detected.
+	conn.SetReadDeadline(time.Now().Add(b.w.timeout))
+	var typ [1]byte
+	if _, err := io.ReadFull(conn, typ[:]); err != nil {
+		if err, ok := err.(net.Error); ok && err.Timeout() {
+			b.negotiated, b.legacy = true, true
+			return 0, errBatchUnsupported
+		}
+		return 0, err
+	} else if typ[0] != negotiateResponseMessage {
+		b.negotiated, b.legacy = true, true
+		return 0, errBatchUnsupported
+	}
+
+	_, buf, err := ReadTLV(io.MultiReader(bytes.NewReader(typ[:]), conn))
+	if err != nil {
+		return 0, err
+	} else if len(buf) != 1 {
+		return 0, fmt.Errorf("invalid negotiate response length: %d", len(buf))
+	}
+
+	b.compression, b.negotiated = buf[0], true
+	return b.compression, nil
 }
 
 const (
@@ -123,8 +543,9 @@ const (
 var errMaxConnectionsExceeded = fmt.Errorf("can not exceed max connections of %d", maxConnections)
 
 type connFactory struct {
-	nodeID  uint64
-	timeout time.Duration
+	nodeID    uint64
+	timeout   time.Duration
+	tlsConfig *tls.Config
 
 	clientPool interface {
 		size() int
@@ -149,17 +570,6 @@ func (c *connFactory) dial() (net.Conn, error) {
 		return nil, fmt.Errorf("node %d does not exist", c.nodeID)
 	}
 
-	conn, err := net.DialTimeout("tcp", ni.Host, c.timeout)
-	if err != nil {
-		return nil, err
-	}
-
 	// Write a marker byte for cluster messages.
-	_, err = conn.Write([]byte{MuxHeader})
-	if err != nil {
-		conn.Close()
-		return nil, err
-	}
-
-	return conn, nil
+	return tcp.DialTLS("tcp", ni.Host, MuxHeader, c.timeout, c.tlsConfig)
 }
@@ -1,13 +1,17 @@
 package cluster_test
 
 import (
+	"fmt"
 	"net"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/influxdb/influxdb/cluster"
+	"github.com/influxdb/influxdb/meta"
 	"github.com/influxdb/influxdb/models"
+	"github.com/influxdb/influxdb/tsdb"
 )
 
 // Ensure the shard writer can successful write a single request.
@@ -57,6 +61,62 @@ func TestShardWriter_WriteShard_Success(t *testing.T) {
 	}
 }
 
+// Ensure writes for a shard the node no longer owns are forwarded to its owners.
+func TestShardWriter_WriteShard_Forward(t *testing.T) {
+	ts := newTestWriteService(func(shardID uint64, points []models.Point) error { return tsdb.ErrShardNotFound })
+	s := cluster.NewService(cluster.Config{})
+	s.Listener = ts.muxln
+	s.TSDBStore = ts
+	s.MetaStore = &serviceMetaStore{
+		nodeID: 1,
+		sgi:    &meta.ShardGroupInfo{Shards: []meta.ShardInfo{{ID: 1, Owners: []meta.ShardOwner{{NodeID: 2}, {NodeID: 3}}}}},
+	}
+	fw := &forwardWriter{}
+	s.ShardWriter = fw
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	defer ts.Close()
+
+	w := cluster.NewShardWriter(time.Minute)
+	w.MetaStore = &metaStore{host: ts.ln.Addr().String()}
+	defer w.Close()
+
+	points := []models.Point{models.NewPoint("cpu", nil, models.Fields{"value": int64(100)}, time.Unix(0, 0))}
+	if err := w.WriteShard(1, 1, points); err != nil {
+		t.Fatal(err)
+	} else if exp := []string{"1 2", "1 3"}; fmt.Sprint(fw.writes) != fmt.Sprint(exp) {
+		t.Fatalf("unexpected forwarded writes: %v", fw.writes)
+	}
+}
+
+// serviceMetaStore is a mock of the cluster service's meta store that
+// returns a single shard group.
+type serviceMetaStore struct {
+	nodeID uint64
+	sgi    *meta.ShardGroupInfo
+}
+
+func (m *serviceMetaStore) NodeID() uint64 { return m.nodeID }
+
+func (m *serviceMetaStore) ShardOwner(shardID uint64) (string, string, *meta.ShardGroupInfo) {
+	return "db0", "rp0", m.sgi
+}
+
+// forwardWriter records the shards and owners written to.
+type forwardWriter struct {
+	mu     sync.Mutex
+	writes []string
+}
+
+func (w *forwardWriter) WriteShard(shardID, ownerID uint64, points []models.Point) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.writes = append(w.writes, fmt.Sprintf("%d %d", shardID, ownerID))
+	return nil
+}
+
 // Ensure the shard writer can successful write a multiple requests.
 func TestShardWriter_WriteShard_Multiple(t *testing.T) {
 	ts := newTestWriteService(writeShardSuccess)
@@ -134,6 +194,201 @@ func TestShardWriter_WriteShard_Error(t *testing.T) {
 	}
 }
 
+// Ensure the shard writer returns the result of each write in a batch.
+func TestShardWriter_WriteShard_Batch(t *testing.T) {
+	ts := newTestWriteService(func(shardID uint64, points []models.Point) error {
+		if shardID%2 == 0 {
+			return fmt.Errorf("failed to write")
+		}
+		return nil
+	})
+	s := cluster.NewService(cluster.Config{})
+	s.Listener = ts.muxln
+	s.TSDBStore = ts
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	defer ts.Close()
+
+	w := cluster.NewShardWriter(time.Minute)
+	w.MetaStore = &metaStore{host: ts.ln.Addr().String()}
+	w.MaxInFlight = 1
+	defer w.Close()
+
+	points := []models.Point{models.NewPoint("cpu", models.Tags{"host": "server01"}, map[string]interface{}{"value": int64(100)}, time.Now())}
+
+	// Write to several shards at once so writes are queued while a batch is
+	// in flight.
+	var wg sync.WaitGroup
+	errs := make([]error, 20)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = w.WriteShard(uint64(i), 2, points)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if i%2 == 0 {
+			if exp := fmt.Sprintf("error code 1: write shard %d: failed to write", i); err == nil || err.Error() != exp {
+				t.Errorf("%d. unexpected error: %v", i, err)
+			}
+		} else if err != nil {
+			t.Errorf("%d. unexpected error: %s", i, err)
+		}
+	}
+}
+
+// Ensure the shard writer can write without compression.
+func TestShardWriter_WriteShard_NoCompression(t *testing.T) {
+	ts := newTestWriteService(writeShardSuccess)
+	s := cluster.NewService(cluster.Config{})
+	s.Listener = ts.muxln
+	s.TSDBStore = ts
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	defer ts.Close()
+
+	w := cluster.NewShardWriter(time.Minute)
+	w.MetaStore = &metaStore{host: ts.ln.Addr().String()}
+	w.Compression = "none"
+
+	points := []models.Point{models.NewPoint("cpu", models.Tags{"host": "server01"}, map[string]interface{}{"value": int64(100)}, time.Now())}
+	if err := w.WriteShard(1, 2, points); err != nil {
+		t.Fatal(err)
+	} else if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if responses, err := ts.ResponseN(1); err != nil {
+		t.Fatal(err)
+	} else if responses[0].shardID != 1 {
+		t.Fatalf("unexpected shard id: %d", responses[0].shardID)
+	}
+}
+
+// Ensure the shard writer falls back to single writes for nodes that don't
+// answer negotiate requests, and keeps doing so after a failed write until the
+// node's host changes.
+func TestShardWriter_WriteShard_Legacy(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	// Serve like a node that predates batches: unknown message types are
+	// ignored and only single writes are answered. Writes to shard 2 fail by
+	// closing the connection.
+	var mu sync.Mutex
+	var types []byte
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				var header [1]byte
+				if _, err := conn.Read(header[:]); err != nil {
+					return
+				}
+				for {
+					typ, buf, err := cluster.ReadTLV(conn)
+					if err != nil {
+						return
+					}
+					mu.Lock()
+					types = append(types, typ)
+					mu.Unlock()
+					if typ != 1 { // writeShardRequestMessage
+						continue
+					}
+
+					var req cluster.WriteShardRequest
+					if err := req.UnmarshalBinary(buf); err != nil || req.ShardID() == 2 {
+						return
+					}
+
+					var resp cluster.WriteShardResponse
+					resp.SetCode(0)
+					buf, _ = resp.MarshalBinary()
+					if err := cluster.WriteTLV(conn, 2, buf); err != nil {
+						return
+					}
+				}
+			}(conn)
+		}
+	}()
+
+	w := cluster.NewShardWriter(100 * time.Millisecond)
+	w.MetaStore = &metaStore{host: ln.Addr().String()}
+	defer w.Close()
+
+	points := []models.Point{models.NewPoint("cpu", models.Tags{"host": "server01"}, map[string]interface{}{"value": int64(100)}, time.Now())}
+	for i := 0; i < 2; i++ {
+		if err := w.WriteShard(1, 2, points); err != nil {
+			t.Fatalf("%d. unexpected error: %s", i, err)
+		}
+	}
+
+	// A failed write doesn't make the node be asked to negotiate again.
+	if err := w.WriteShard(2, 2, points); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := w.WriteShard(1, 2, points); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	if exp := []byte{9, 1, 1, 1, 1}; string(types) != string(exp) {
+		t.Fatalf("unexpected message types: %v", types)
+	}
+	mu.Unlock()
+
+	// Batches are negotiated with the node at its new host.
+	ts := newTestWriteService(writeShardSuccess)
+	s := cluster.NewService(cluster.Config{})
+	s.Listener = ts.muxln
+	s.TSDBStore = ts
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	defer ts.Close()
+
+	w.MetaStore = &metaStore{host: ts.ln.Addr().String()}
+	if err := w.WriteShard(1, 2, points); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing else is sent to the old host.
+	mu.Lock()
+	defer mu.Unlock()
+	if len(types) != 5 {
+		t.Fatalf("unexpected message types: %v", types)
+	}
+}
+
+// Ensure the shard writer returns an error after it's closed.
+func TestShardWriter_WriteShard_ErrClosed(t *testing.T) {
+	w := cluster.NewShardWriter(time.Minute)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	points := []models.Point{models.NewPoint("cpu", models.Tags{"host": "server01"}, map[string]interface{}{"value": int64(100)}, time.Now())}
+	if err := w.WriteShard(1, 2, points); err != cluster.ErrShardWriterClosed {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
 // Ensure the shard writer returns an error when dialing times out.
 func TestShardWriter_Write_ErrDialTimeout(t *testing.T) {
 	ts := newTestWriteService(writeShardSuccess)
@@ -1,22 +1,20 @@
 package backup
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
-	"net"
 	"os"
 
 	"github.com/influxdb/influxdb/services/snapshotter"
 	"github.com/influxdb/influxdb/snapshot"
+	"github.com/influxdb/influxdb/tcp"
 )
 
-// Suffix is a suffix added to the backup while it's in-process.
-const Suffix = ".pending"
-
 // Command represents the program execution for "influxd backup".
 type Command struct {
 	// The logger passed to the ticker during execution.
@@ -24,6 +22,9 @@ type Command struct {
 
 	// Standard input/output, overridden for testing.
 	Stderr io.Writer
+
+	// If set, the connection to the server is made over TLS.
+	tlsConfig *tls.Config
 }
 
 // NewCommand returns a new instance of Command with default settings.
@@ -40,39 +41,76 @@ func (cmd *Command) Run(args ...string) error {
 	cmd.Logger.Printf("influxdb backup")
 
 	// Parse command line arguments.
-	host, path, err := cmd.parseFlags(args)
+	host, path, targetURL, retain, verify, err := cmd.parseFlags(args)
 	if err != nil {
 		return err
 	}
 
-	// Retrieve snapshot from local file.
-	m, err := snapshot.ReadFileManifest(path)
-	if err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("read file snapshot: %s", err)
+	// Verify an existing backup chain if requested.
+	if verify {
+		return cmd.verify(targetURL, path)
 	}
 
-	// Determine temporary path to download to.
-	tmppath := path + Suffix
+	// Open the storage target. Without one the path is a local file.
+	target, name, err := snapshot.OpenPathTarget(targetURL, path)
+	if err != nil {
+		return fmt.Errorf("open target: %s", err)
+	}
+
+	// Retrieve snapshot manifest from the existing backup chain.
+	m, err := snapshot.ReadTargetManifest(target, name)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("read snapshot manifest: %s", err)
+	}
 
-	// Calculate path of next backup file.
-	// This uses the path if it doesn't exist.
+	// Calculate name of next backup file.
+	// This uses the name if it doesn't exist.
 	// Otherwise it appends an autoincrementing number.
-	path, err = cmd.nextPath(path)
+	next, err := snapshot.NextName(target, name)
 	if err != nil {
-		return fmt.Errorf("next path: %s", err)
+		return fmt.Errorf("next name: %s", err)
 	}
 
-	// Retrieve snapshot.
-	if err := cmd.download(host, m, tmppath); err != nil {
+	// Retrieve snapshot into a temporary file.
+	f, err := snapshot.SpoolFile(target, next)
+	if err != nil {
+		return fmt.Errorf("open temp file: %s", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if err := cmd.download(host, m, f); err != nil {
 		return fmt.Errorf("download: %s", err)
 	}
 
-	// Rename temporary file to final path.
-	if err := os.Rename(tmppath, path); err != nil {
-		return fmt.Errorf("rename: %s", err)
+	// Verify the integrity of the snapshot before storing it.
+	size, err := f.Seek(0, os.SEEK_CUR)
+	if err != nil {
+		return fmt.Errorf("seek: %s", err)
+	} else if _, err := f.Seek(0, os.SEEK_SET); err != nil {
+		return fmt.Errorf("seek: %s", err)
+	}
+	sr := snapshot.NewReader(f)
+	if err := sr.Verify(); err != nil {
+		return fmt.Errorf("verify: %s", err)
+	} else if !sr.Verified() {
+		cmd.Logger.Printf("snapshot not verified: server did not send checksums")
+	}
+	if _, err := f.Seek(0, os.SEEK_SET); err != nil {
+		return fmt.Errorf("seek: %s", err)
+	}
+
+	// Store the snapshot and its checksum in the target.
+	if err := snapshot.Put(target, name, next, f, size); err != nil {
+		return fmt.Errorf("store: %s", err)
 	}
 
-	// TODO: Check file integrity.
+	// Remove the oldest backup chains.
+	if retain > 0 {
+		if err := snapshot.Retain(target, retain); err != nil {
+			return fmt.Errorf("retain: %s", err)
+		}
+	}
 
 	// Notify user of completion.
 	cmd.Logger.Println("backup complete")
@@ -81,79 +119,101 @@ func (cmd *Command) Run(args ...string) error {
 }
 
 // parseFlags parses and validates the command line arguments.
-func (cmd *Command) parseFlags(args []string) (host string, path string, err error) {
+func (cmd *Command) parseFlags(args []string) (host, path, target string, retain int, verify bool, err error) {
 	fs := flag.NewFlagSet("", flag.ContinueOnError)
 	fs.StringVar(&host, "host", "localhost:8088", "")
+	fs.StringVar(&target, "target", "", "")
+	fs.IntVar(&retain, "retain", 0, "")
+	fs.BoolVar(&verify, "verify", false, "")
+	useTLS := fs.Bool("tls", false, "")
+	caFile := fs.String("ca", "", "")
+	certFile := fs.String("cert", "", "")
 	fs.SetOutput(cmd.Stderr)
 	fs.Usage = cmd.printUsage
 	if err := fs.Parse(args); err != nil {
-		return "", "", err
+		return "", "", "", 0, false, err
 	}
 
 	// Ensure that only one arg is specified.
 	if fs.NArg() == 0 {
-		return "", "", errors.New("snapshot path required")
+		return "", "", "", 0, false, errors.New("snapshot path required")
 	} else if fs.NArg() != 1 {
-		return "", "", errors.New("only one snapshot path allowed")
+		return "", "", "", 0, false, errors.New("only one snapshot path allowed")
+	} else if retain < 0 {
+		return "", "", "", 0, false, errors.New("retain must be positive")
 	}
 	path = fs.Arg(0)
 
-	return host, path, nil
-}
-
-// nextPath returns the next file to write to.
-func (cmd *Command) nextPath(path string) (string, error) {
-	// Use base path if it doesn't exist.
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return path, nil
-	} else if err != nil {
-		return "", err
-	}
-
-	// Otherwise iterate through incremental files until one is available.
-	for i := 0; ; i++ {
-		s := fmt.Sprintf(path+".%d", i)
-		if _, err := os.Stat(s); os.IsNotExist(err) {
-			return s, nil
-		} else if err != nil {
-			return "", err
+	if *useTLS {
+		if cmd.tlsConfig, err = tcp.LoadClientTLSConfig(*certFile, *caFile); err != nil {
+			return "", "", "", 0, false, err
 		}
+	} else if *caFile != "" || *certFile != "" {
+		return "", "", "", 0, false, errors.New("-ca and -cert require -tls")
 	}
-}
 
-// download downloads a snapshot from a host to a given path.
-func (cmd *Command) download(host string, m *snapshot.Manifest, path string) error {
-	// Create local file to write to.
-	f, err := os.Create(path)
-	if err != nil {
-		return fmt.Errorf("open temp file: %s", err)
-	}
-	defer f.Close()
+	return host, path, target, retain, verify, nil
+}
 
+// download downloads a snapshot from a host to w.
+func (cmd *Command) download(host string, m *snapshot.Manifest, w io.Writer) error {
 	// Connect to snapshotter service.
-	conn, err := net.Dial("tcp", host)
+	conn, err := tcp.DialTLS("tcp", host, snapshotter.MuxHeader, 0, cmd.tlsConfig)
 	if err != nil {
 		return err
 	}
 	defer conn.Close()
 
-	// Send snapshotter marker byte.
-	if _, err := conn.Write([]byte{snapshotter.MuxHeader}); err != nil {
-		return fmt.Errorf("write snapshot header byte: %s", err)
-	}
-
 	// Write the manifest we currently have.
 	if err := json.NewEncoder(conn).Encode(m); err != nil {
 		return fmt.Errorf("encode snapshot manifest: %s", err)
 	}
 
 	// Read snapshot from the connection.
-	if _, err := io.Copy(f, conn); err != nil {
+	if _, err := io.Copy(w, conn); err != nil {
 		return fmt.Errorf("copy snapshot to file: %s", err)
 	}
 
-	// FIXME(benbjohnson): Verify integrity of snapshot.
+	return nil
+}
+
+// verify validates every snapshot in a backup chain and the files inside
+// them against their checksums.
+func (cmd *Command) verify(targetURL, path string) error {
+	target, name, err := snapshot.OpenPathTarget(targetURL, path)
+	if err != nil {
+		return fmt.Errorf("open target: %s", err)
+	}
+
+	// Verify the snapshot checksums recorded when the chain was stored.
+	// Chains written before checksums were recorded are only checked by file.
+	if err := snapshot.Verify(target, name); os.IsNotExist(err) {
+		cmd.Logger.Printf("no snapshot checksums found: %s", name)
+	} else if err != nil {
+		return err
+	}
+
+	// Verify each file in every snapshot of the chain.
+	ssr, closers, err := snapshot.OpenTargetMultiReader(target, name)
+	if err != nil {
+		return fmt.Errorf("open: %s", err)
+	}
+	defer func() {
+		for _, c := range closers {
+			c.Close()
+		}
+	}()
+
+	ss, err := ssr.Manifest()
+	if err != nil {
+		return fmt.Errorf("manifest: %s", err)
+	} else if err := ssr.Verify(); err != nil {
+		return err
+	} else if !ssr.Verified() {
+		cmd.Logger.Printf("some files have no checksums and were not verified")
+	}
 
+	cmd.Logger.Printf("backup verified: %d snapshot(s), %d file(s)", len(closers), len(ss.Files))
 	return nil
 }
 
@@ -166,5 +226,37 @@ backup downloads a snapshot of a data node and saves it to disk.
         -host <host:port>
                           The host to connect to snapshot.
                           Defaults to 127.0.0.1:8088.
+
+        -tls
+                          Connect to the host over TLS. Required when the
+                          server has tls-enabled set in its [meta] section.
+
+        -ca <file>
+                          Verify the host against the CA certificate in file
+                          instead of the system roots. Requires -tls.
+
+        -cert <file>
+                          Present the certificate in file, which must also
+                          contain the private key, to servers that have
+                          tls-verify-client set. Requires -tls.
+
+        -target <dir|s3://bucket/prefix>
+                          Store the backup chain named PATH in a directory
+                          or an S3-compatible bucket. The endpoint and region
+                          are set with the "endpoint" and "region" URL query
+                          parameters. Credentials are read from the
+                          AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY
+                          environment variables.
+                          Defaults to the local file at PATH.
+
+        -retain <n>
+                          Delete all but the n most recently updated backup
+                          chains in the target. Defaults to keeping all.
+
+        -verify
+                          Check every snapshot in the backup chain named PATH
+                          and the files inside them against their checksums
+                          instead of taking a backup. Files from servers that
+                          don't send checksums are reported as unverified.
 `)
 }
@@ -0,0 +1,177 @@
+package compact
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/BurntSushi/toml"
+	"github.com/influxdb/influxdb/tsdb"
+	"github.com/influxdb/influxdb/tsdb/engine/bz1"
+)
+
+// Command represents the program execution for "influxd compact".
+type Command struct {
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// NewCommand returns a new instance of Command with default settings.
+func NewCommand() *Command {
+	return &Command{
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+}
+
+// Options represents which shards are compacted.
+type Options struct {
+	Database        string
+	RetentionPolicy string
+	ShardID         uint64
+}
+
+// Run executes the program.
+func (cmd *Command) Run(args ...string) error {
+	config, opt, err := cmd.parseFlags(args)
+	if err != nil {
+		return err
+	}
+
+	return cmd.Compact(config, opt)
+}
+
+// Compact compacts the bz1 shards in the data directories of config that match opt.
+// The server must not be running.
+func (cmd *Command) Compact(config *Config, opt Options) error {
+	var total int64
+	for _, dir := range []string{config.Data.Dir, config.Data.ColdDir} {
+		if dir == "" {
+			continue
+		}
+
+		paths, err := shardPaths(dir, opt)
+		if err != nil {
+			return err
+		}
+
+		for _, path := range paths {
+			stats, err := bz1.CompactFile(path, bz1.DefaultBlockSize)
+			if err != nil {
+				fmt.Fprintf(cmd.Stderr, "skipping %s: %s\n", path, err)
+				continue
+			}
+			total += stats.BytesSaved()
+
+			fmt.Fprintf(cmd.Stdout, "compacted %s: %d series rewritten, %d blocks to %d, %d bytes saved\n",
+				path, stats.SeriesN, stats.BlocksBefore, stats.BlocksAfter, stats.BytesSaved())
+		}
+	}
+
+	fmt.Fprintf(cmd.Stdout, "compaction complete: %d bytes saved\n", total)
+	return nil
+}
+
+// shardPaths returns the paths of the shards under dir that match opt.
+// Shard paths are <dir>/<database>/<retention policy>/<id>.
+func shardPaths(dir string, opt Options) ([]string, error) {
+	var paths []string
+	dbs, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for _, db := range dbs {
+		if !db.IsDir() || (opt.Database != "" && db.Name() != opt.Database) {
+			continue
+		}
+
+		rps, err := ioutil.ReadDir(filepath.Join(dir, db.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, rp := range rps {
+			if !rp.IsDir() || (opt.RetentionPolicy != "" && rp.Name() != opt.RetentionPolicy) {
+				continue
+			}
+
+			shards, err := ioutil.ReadDir(filepath.Join(dir, db.Name(), rp.Name()))
+			if err != nil {
+				return nil, err
+			}
+			for _, sh := range shards {
+				// Shard file names are numeric shard IDs.
+				id, err := strconv.ParseUint(sh.Name(), 10, 64)
+				if err != nil || sh.IsDir() || (opt.ShardID != 0 && id != opt.ShardID) {
+					continue
+				}
+				paths = append(paths, filepath.Join(dir, db.Name(), rp.Name(), sh.Name()))
+			}
+		}
+	}
+	return paths, nil
+}
+
+// parseFlags parses and validates the command line arguments.
+func (cmd *Command) parseFlags(args []string) (*Config, Options, error) {
+	var opt Options
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	configPath := fs.String("config", "", "")
+	fs.StringVar(&opt.Database, "database", "", "")
+	fs.StringVar(&opt.RetentionPolicy, "retention", "", "")
+	fs.Uint64Var(&opt.ShardID, "shard", 0, "")
+	fs.SetOutput(cmd.Stderr)
+	fs.Usage = cmd.printUsage
+	if err := fs.Parse(args); err != nil {
+		return nil, opt, err
+	}
+
+	// Parse configuration file from disk.
+	if *configPath == "" {
+		return nil, opt, fmt.Errorf("config required")
+	}
+
+	config := Config{Data: tsdb.NewConfig()}
+	if _, err := toml.DecodeFile(*configPath, &config); err != nil {
+		return nil, opt, err
+	}
+
+	if opt.RetentionPolicy != "" && opt.Database == "" {
+		return nil, opt, fmt.Errorf("database required with retention policy")
+	}
+
+	return &config, opt, nil
+}
+
+// printUsage prints the usage message to STDERR.
+func (cmd *Command) printUsage() {
+	fmt.Fprintf(cmd.Stderr, `usage: influxd compact [flags]
+
+compact rewrites fragmented series in bz1 shards into full blocks and
+copies each shard into a fresh file to release free pages. The server
+must be stopped.
+
+        -config <path>
+                          Set the path to the configuration file.
+
+        -database <name>
+                          Only compact shards of this database.
+
+        -retention <name>
+                          Only compact shards of this retention policy.
+
+        -shard <id>
+                          Only compact the shard with this ID.
+`)
+}
+
+// Config represents a partial config for compacting shards.
+type Config struct {
+	Data tsdb.Config `toml:"data"`
+}
@@ -35,6 +35,7 @@ Usage:
 The commands are:
 
     backup               downloads a snapshot of a data node and saves it to disk
+    compact              rewrites fragmented shards while the server is stopped
     config               display the default configuration
     restore              uses a snapshot of a data node to rebuild a cluster
     run                  run node with existing configuration
@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/influxdb/influxdb/cmd/influxd/backup"
+	"github.com/influxdb/influxdb/cmd/influxd/compact"
 	"github.com/influxdb/influxdb/cmd/influxd/help"
 	"github.com/influxdb/influxdb/cmd/influxd/restore"
 	"github.com/influxdb/influxdb/cmd/influxd/run"
@@ -124,6 +125,11 @@ func (m *Main) Run(args ...string) error {
 		if err := name.Run(args...); err != nil {
 			return fmt.Errorf("restore: %s", err)
 		}
+	case "compact":
+		name := compact.NewCommand()
+		if err := name.Run(args...); err != nil {
+			return fmt.Errorf("compact: %s", err)
+		}
 	case "config":
 		if err := run.NewPrintConfigCommand().Run(args...); err != nil {
 			return fmt.Errorf("config: %s", err)
@@ -2,6 +2,7 @@ package restore
 
 import (
 	"bytes"
+	"crypto/tls"
 	"errors"
 	"flag"
 	"fmt"
@@ -9,13 +10,21 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/influxdb/influxdb/meta"
+	"github.com/influxdb/influxdb/services/restorer"
 	"github.com/influxdb/influxdb/snapshot"
+	"github.com/influxdb/influxdb/tcp"
 	"github.com/influxdb/influxdb/tsdb"
 )
 
+// restoreExtension is appended to the meta and data directories to name the
+// directories a backup is unpacked into before they're replaced.
+const restoreExtension = ".restore"
+
 // Command represents the program execution for "influxd restore".
 type Command struct {
 	Stdout io.Writer
@@ -30,72 +39,275 @@ func NewCommand() *Command {
 	}
 }
 
+// Options represents the filters applied to a restore and how to connect to
+// a running server.
+type Options struct {
+	// The backup chain is read from this storage target, if set.
+	// Otherwise the path is a local file.
+	Target string
+
+	// Snapshots taken after Until are not restored, if set.
+	Until time.Time
+
+	// Only the given database and retention policy are restored, if set.
+	Database        string
+	RetentionPolicy string
+
+	// The database is restored under this name, if set.
+	// Only supported when restoring into a running server.
+	NewDatabase string
+
+	// If set, the connection to a running server is made over TLS.
+	TLSConfig *tls.Config
+}
+
 // Run executes the program.
 func (cmd *Command) Run(args ...string) error {
-	config, path, err := cmd.parseFlags(args)
+	config, host, path, opt, err := cmd.parseFlags(args)
 	if err != nil {
 		return err
 	}
 
-	return cmd.Restore(config, path)
-}
-
-func (cmd *Command) Restore(config *Config, path string) error {
-	// Remove meta and data directories.
-	if err := os.RemoveAll(config.Meta.Dir); err != nil {
-		return fmt.Errorf("remove meta dir: %s", err)
-	} else if err := os.RemoveAll(config.Data.Dir); err != nil {
-		return fmt.Errorf("remove data dir: %s", err)
+	// Push the snapshot to a running server if a host is given.
+	if host != "" {
+		return cmd.RestoreOnline(host, path, opt)
 	}
+	return cmd.Restore(config, path, opt)
+}
 
+// Restore rebuilds the meta and data directories from the snapshot at path
+// and its incremental backups. The existing directories are only replaced
+// once the whole backup chain has been restored.
+func (cmd *Command) Restore(config *Config, path string, opt Options) error {
 	// Open snapshot file and all incremental backups.
-	mr, files, err := snapshot.OpenFileMultiReader(path)
+	mr, files, err := openSnapshot(path, opt)
 	if err != nil {
-		return fmt.Errorf("open multireader: %s", err)
+		return err
 	}
 	defer closeAll(files)
 
+	// Unpack into directories next to the meta and data directories.
+	dirs := []string{filepath.Clean(config.Meta.Dir), filepath.Clean(config.Data.Dir)}
+	staged := *config
+	metaConfig := *config.Meta
+	metaConfig.Dir = dirs[0] + restoreExtension
+	staged.Meta = &metaConfig
+	staged.Data.Dir = dirs[1] + restoreExtension
+	for _, dir := range dirs {
+		if err := os.RemoveAll(dir + restoreExtension); err != nil {
+			return fmt.Errorf("remove %s: %s", dir+restoreExtension, err)
+		} else if err := os.MkdirAll(dir+restoreExtension, 0777); err != nil {
+			return fmt.Errorf("mkdir %s: %s", dir+restoreExtension, err)
+		}
+		defer os.RemoveAll(dir + restoreExtension)
+	}
+
 	// Unpack files from archive.
-	if err := cmd.unpack(mr, config); err != nil {
+	if err := cmd.unpack(mr, &staged, opt); err != nil {
 		return fmt.Errorf("unpack: %s", err)
 	}
 
+	// Replace the meta and data directories. Cold shards are removed since
+	// all restored shards are in the data directory.
+	if config.Data.ColdDir != "" {
+		if err := os.RemoveAll(config.Data.ColdDir); err != nil {
+			return fmt.Errorf("remove cold dir: %s", err)
+		}
+	}
+	for _, dir := range dirs {
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("remove %s: %s", dir, err)
+		} else if err := os.Rename(dir+restoreExtension, dir); err != nil {
+			return fmt.Errorf("rename %s: %s", dir, err)
+		}
+	}
+
 	// Notify user of completion.
 	fmt.Fprintf(os.Stdout, "restore complete using %s", path)
 	return nil
 }
 
+// RestoreOnline pushes the snapshot at path and its incremental backups to
+// the server at host, which restores it without downtime.
+func (cmd *Command) RestoreOnline(host, path string, opt Options) error {
+	mr, files, err := openSnapshot(path, opt)
+	if err != nil {
+		return err
+	}
+	defer closeAll(files)
+
+	client := restorer.NewClient(host)
+	client.TLSConfig = opt.TLSConfig
+	resp, err := client.Restore(&restorer.Request{
+		Database:        opt.Database,
+		RetentionPolicy: opt.RetentionPolicy,
+		NewDatabase:     opt.NewDatabase,
+	}, mr)
+	if err != nil {
+		return fmt.Errorf("restore: %s", err)
+	}
+
+	// Notify user of completion.
+	fmt.Fprintf(cmd.Stdout, "restore complete using %s: %d shards restored on %s\n", path, len(resp.Shards), host)
+	return nil
+}
+
+// openSnapshot opens the snapshot at path and all its incremental backups,
+// reading only the snapshots and shards matching opt. The backup chain's
+// checksums are verified if it has any.
+func openSnapshot(path string, opt Options) (*snapshot.MultiReader, []io.Closer, error) {
+	target, name, err := snapshot.OpenPathTarget(opt.Target, path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open target: %s", err)
+	}
+
+	if err := snapshot.Verify(target, name); err != nil && !os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("verify: %s", err)
+	}
+
+	mr, files, err := snapshot.OpenTargetMultiReader(target, name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open multireader: %s", err)
+	}
+
+	// Only read the matching snapshots and shards.
+	mr.Until = opt.Until
+	mr.Filter = func(f snapshot.File) bool {
+		return f.Name == "meta" || opt.match(f.Name)
+	}
+
+	// Ensure a snapshot was taken before the until time.
+	if m, err := mr.Manifest(); err != nil {
+		closeAll(files)
+		return nil, nil, fmt.Errorf("manifest: %s", err)
+	} else if len(m.Files) == 0 && !opt.Until.IsZero() {
+		closeAll(files)
+		return nil, nil, fmt.Errorf("no snapshot found before %s", opt.Until.Format(time.RFC3339))
+	}
+
+	return mr, files, nil
+}
+
 // parseFlags parses and validates the command line arguments.
-func (cmd *Command) parseFlags(args []string) (*Config, string, error) {
+// The config is nil when restoring into a running server at host.
+func (cmd *Command) parseFlags(args []string) (config *Config, host string, path string, opt Options, err error) {
 	fs := flag.NewFlagSet("", flag.ContinueOnError)
 	configPath := fs.String("config", "", "")
+	fs.StringVar(&host, "host", "", "")
+	fs.StringVar(&opt.Target, "target", "", "")
+	until := fs.String("until", "", "")
+	fs.StringVar(&opt.Database, "database", "", "")
+	fs.StringVar(&opt.RetentionPolicy, "retention", "", "")
+	fs.StringVar(&opt.NewDatabase, "newdb", "", "")
+	useTLS := fs.Bool("tls", false, "")
+	caFile := fs.String("ca", "", "")
+	certFile := fs.String("cert", "", "")
 	fs.SetOutput(cmd.Stderr)
 	fs.Usage = cmd.printUsage
 	if err := fs.Parse(args); err != nil {
-		return nil, "", err
+		return nil, "", "", opt, err
+	}
+
+	// Parse until time.
+	if *until != "" {
+		t, err := time.Parse(time.RFC3339, *until)
+		if err != nil {
+			return nil, "", "", opt, fmt.Errorf("invalid until time: %s", err)
+		}
+		opt.Until = t
+	}
+
+	// A retention policy or new name can only apply to a single database.
+	if opt.RetentionPolicy != "" && opt.Database == "" {
+		return nil, "", "", opt, fmt.Errorf("database required with retention policy")
+	} else if opt.NewDatabase != "" && opt.Database == "" {
+		return nil, "", "", opt, fmt.Errorf("database required with new database name")
+	}
+
+	// Require output path.
+	path = fs.Arg(0)
+	if path == "" {
+		return nil, "", "", opt, fmt.Errorf("snapshot path required")
+	}
+
+	// The running server's configuration is used when restoring online.
+	if host != "" {
+		if *useTLS {
+			if opt.TLSConfig, err = tcp.LoadClientTLSConfig(*certFile, *caFile); err != nil {
+				return nil, "", "", opt, err
+			}
+		} else if *caFile != "" || *certFile != "" {
+			return nil, "", "", opt, fmt.Errorf("-ca and -cert require -tls")
+		}
+		return nil, host, path, opt, nil
+	} else if opt.NewDatabase != "" {
+		return nil, "", "", opt, fmt.Errorf("new database name requires host")
+	} else if *useTLS {
+		return nil, "", "", opt, fmt.Errorf("-tls requires host")
 	}
 
 	// Parse configuration file from disk.
 	if *configPath == "" {
-		return nil, "", fmt.Errorf("config required")
+		return nil, "", "", opt, fmt.Errorf("config required")
 	}
 
 	// Parse config.
-	config := Config{
+	config = &Config{
 		Meta: meta.NewConfig(),
 		Data: tsdb.NewConfig(),
 	}
-	if _, err := toml.DecodeFile(*configPath, &config); err != nil {
-		return nil, "", err
+	if _, err := toml.DecodeFile(*configPath, config); err != nil {
+		return nil, "", "", opt, err
 	}
 
-	// Require output path.
-	path := fs.Arg(0)
-	if path == "" {
-		return nil, "", fmt.Errorf("snapshot path required")
+	return config, "", path, opt, nil
+}
+
+// match returns true if a shard file, named <database>/<retention policy>/<id>,
+// is restored.
+func (opt *Options) match(name string) bool {
+	a := strings.Split(filepath.ToSlash(name), "/")
+	if opt.Database != "" && a[0] != opt.Database {
+		return false
+	} else if opt.RetentionPolicy != "" && (len(a) < 2 || a[1] != opt.RetentionPolicy) {
+		return false
 	}
+	return true
+}
+
+// filter removes the databases and retention policies that aren't restored
+// from the metadata.
+func (opt *Options) filter(data *meta.Data) {
+	if opt.Database == "" {
+		return
+	}
+
+	var dbs []meta.DatabaseInfo
+	for _, di := range data.Databases {
+		if di.Name != opt.Database {
+			continue
+		}
 
-	return &config, path, nil
+		if opt.RetentionPolicy != "" {
+			var rps []meta.RetentionPolicyInfo
+			for _, rpi := range di.RetentionPolicies {
+				if rpi.Name == opt.RetentionPolicy {
+					rps = append(rps, rpi)
+				}
+			}
+			di.RetentionPolicies = rps
+
+			// The restored policy becomes the default if the default was removed.
+			if di.DefaultRetentionPolicy != opt.RetentionPolicy {
+				di.DefaultRetentionPolicy = ""
+				if len(rps) > 0 {
+					di.DefaultRetentionPolicy = opt.RetentionPolicy
+				}
+			}
+		}
+		dbs = append(dbs, di)
+	}
+	data.Databases = dbs
 }
 
 func closeAll(a []io.Closer) {
@@ -105,7 +317,7 @@ func closeAll(a []io.Closer) {
 }
 
 // unpack expands the files in the snapshot archive into a directory.
-func (cmd *Command) unpack(mr *snapshot.MultiReader, config *Config) error {
+func (cmd *Command) unpack(mr *snapshot.MultiReader, config *Config, opt Options) error {
 	// Loop over files and extract.
 	for {
 		// Read entry header.
@@ -122,7 +334,7 @@ func (cmd *Command) unpack(mr *snapshot.MultiReader, config *Config) error {
 		// Handle meta and tsdb files separately.
 		switch sf.Name {
 		case "meta":
-			if err := cmd.unpackMeta(mr, sf, config); err != nil {
+			if err := cmd.unpackMeta(mr, sf, config, opt); err != nil {
 				return fmt.Errorf("meta: %s", err)
 			}
 		default:
@@ -137,7 +349,7 @@ func (cmd *Command) unpack(mr *snapshot.MultiReader, config *Config) error {
 
 // unpackMeta reads the metadata from the snapshot and initializes a raft
 // cluster and replaces the root metadata.
-func (cmd *Command) unpackMeta(mr *snapshot.MultiReader, sf snapshot.File, config *Config) error {
+func (cmd *Command) unpackMeta(mr *snapshot.MultiReader, sf snapshot.File, config *Config, opt Options) error {
 	// Read meta into buffer.
 	var buf bytes.Buffer
 	if _, err := io.CopyN(&buf, mr, sf.Size); err != nil {
@@ -150,6 +362,12 @@ func (cmd *Command) unpackMeta(mr *snapshot.MultiReader, sf snapshot.File, confi
 		return fmt.Errorf("unmarshal: %s", err)
 	}
 
+	// Remove databases that aren't restored.
+	opt.filter(&data)
+	if opt.Database != "" && len(data.Databases) == 0 {
+		return fmt.Errorf("database not found in snapshot: %s", opt.Database)
+	}
+
 	// Copy meta config and remove peers so it starts in single mode.
 	c := config.Meta
 	c.Peers = nil
@@ -224,6 +442,44 @@ restore uses a snapshot of a data node to rebuild a cluster.
 
         -config <path>
                           Set the path to the configuration file.
+                          Required unless -host is set.
+
+        -host <host:port>
+                          Restore into the running server at host
+                          instead of rebuilding a stopped one.
+
+        -tls
+                          Connect to the host over TLS. Required when the
+                          server has tls-enabled set in its [meta] section.
+
+        -ca <file>
+                          Verify the host against the CA certificate in file
+                          instead of the system roots. Requires -tls.
+
+        -cert <file>
+                          Present the certificate in file, which must also
+                          contain the private key, to servers that have
+                          tls-verify-client set. Requires -tls.
+
+        -target <dir|s3://bucket/prefix>
+                          Read the backup chain named PATH from a directory
+                          or an S3-compatible bucket. See "influxd backup".
+                          Defaults to the local file at PATH.
+
+        -until <time>
+                          Only restore snapshots taken up to an RFC3339
+                          timestamp. Defaults to the latest snapshot.
+
+        -database <name>
+                          Only restore the given database.
+
+        -retention <name>
+                          Only restore the given retention policy.
+                          Requires -database.
+
+        -newdb <name>
+                          Restore the database under a new name.
+                          Requires -database and -host.
 `)
 }
 
@@ -15,6 +15,7 @@ import (
 	"github.com/influxdb/influxdb/meta"
 	"github.com/influxdb/influxdb/monitor"
 	"github.com/influxdb/influxdb/services/admin"
+	"github.com/influxdb/influxdb/services/antientropy"
 	"github.com/influxdb/influxdb/services/collectd"
 	"github.com/influxdb/influxdb/services/continuous_querier"
 	"github.com/influxdb/influxdb/services/graphite"
@@ -22,18 +23,23 @@ import (
 	"github.com/influxdb/influxdb/services/httpd"
 	"github.com/influxdb/influxdb/services/opentsdb"
 	"github.com/influxdb/influxdb/services/precreator"
+	"github.com/influxdb/influxdb/services/rebalancer"
 	"github.com/influxdb/influxdb/services/retention"
+	"github.com/influxdb/influxdb/services/tiering"
 	"github.com/influxdb/influxdb/services/udp"
 	"github.com/influxdb/influxdb/tsdb"
 )
 
 // Config represents the configuration format for the influxd binary.
 type Config struct {
-	Meta       *meta.Config      `toml:"meta"`
-	Data       tsdb.Config       `toml:"data"`
-	Cluster    cluster.Config    `toml:"cluster"`
-	Retention  retention.Config  `toml:"retention"`
-	Precreator precreator.Config `toml:"shard-precreation"`
+	Meta        *meta.Config       `toml:"meta"`
+	Data        tsdb.Config        `toml:"data"`
+	Cluster     cluster.Config     `toml:"cluster"`
+	Retention   retention.Config   `toml:"retention"`
+	Tiering     tiering.Config     `toml:"tiering"`
+	Rebalancer  rebalancer.Config  `toml:"rebalancer"`
+	AntiEntropy antientropy.Config `toml:"anti-entropy"`
+	Precreator  precreator.Config  `toml:"shard-precreation"`
 
 	Admin     admin.Config      `toml:"admin"`
 	Monitor   monitor.Config    `toml:"monitor"`
@@ -68,6 +74,9 @@ func NewConfig() *Config {
 
 	c.ContinuousQuery = continuous_querier.NewConfig()
 	c.Retention = retention.NewConfig()
+	c.Tiering = tiering.NewConfig()
+	c.Rebalancer = rebalancer.NewConfig()
+	c.AntiEntropy = antientropy.NewConfig()
 	c.HintedHandoff = hh.NewConfig()
 
 	return c
@@ -110,6 +119,32 @@ func (c *Config) Validate() error {
 		return errors.New("Data.WALDir must be specified")
 	}
 
+	if err := c.Meta.Validate(); err != nil {
+		return fmt.Errorf("invalid meta config: %v", err)
+	}
+
+	if err := c.Data.Validate(); err != nil {
+		return fmt.Errorf("invalid data config: %v", err)
+	}
+
+	if err := c.Cluster.Validate(); err != nil {
+		return fmt.Errorf("invalid cluster config: %v", err)
+	}
+
+	if err := c.Tiering.Validate(); err != nil {
+		return fmt.Errorf("invalid tiering config: %v", err)
+	} else if c.Tiering.Enabled && c.Data.ColdDir == "" {
+		return errors.New("Data.ColdDir must be specified when tiering is enabled")
+	}
+
+	if err := c.Rebalancer.Validate(); err != nil {
+		return fmt.Errorf("invalid rebalancer config: %v", err)
+	}
+
+	if err := c.AntiEntropy.Validate(); err != nil {
+		return fmt.Errorf("invalid anti-entropy config: %v", err)
+	}
+
 	for _, g := range c.Graphites {
 		if err := g.Validate(); err != nil {
 			return fmt.Errorf("invalid graphite config: %v", err)
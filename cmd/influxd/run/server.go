@@ -2,6 +2,7 @@ package run
 
 import (
 	"bytes"
+	"crypto/tls"
 	"fmt"
 	"log"
 	"net"
@@ -16,20 +17,26 @@ import (
 	"github.com/influxdb/influxdb/meta"
 	"github.com/influxdb/influxdb/monitor"
 	"github.com/influxdb/influxdb/services/admin"
+	"github.com/influxdb/influxdb/services/antientropy"
 	"github.com/influxdb/influxdb/services/collectd"
 	"github.com/influxdb/influxdb/services/continuous_querier"
 	"github.com/influxdb/influxdb/services/copier"
+	"github.com/influxdb/influxdb/services/decommissioner"
 	"github.com/influxdb/influxdb/services/graphite"
 	"github.com/influxdb/influxdb/services/hh"
 	"github.com/influxdb/influxdb/services/httpd"
 	"github.com/influxdb/influxdb/services/opentsdb"
 	"github.com/influxdb/influxdb/services/precreator"
+	"github.com/influxdb/influxdb/services/rebalancer"
+	"github.com/influxdb/influxdb/services/restorer"
 	"github.com/influxdb/influxdb/services/retention"
 	"github.com/influxdb/influxdb/services/snapshotter"
+	"github.com/influxdb/influxdb/services/tiering"
 	"github.com/influxdb/influxdb/services/udp"
 	"github.com/influxdb/influxdb/tcp"
 	"github.com/influxdb/influxdb/tsdb"
 	_ "github.com/influxdb/influxdb/tsdb/engine"
+	"github.com/influxdb/influxdb/tsdb/engine/wal"
 )
 
 // BuildInfo represents the build details for the server code.
@@ -53,6 +60,9 @@ type Server struct {
 	BindAddress string
 	Listener    net.Listener
 
+	// TLS configuration for connections between nodes. Nil if disabled.
+	tlsConfig *tls.Config
+
 	MetaStore     *meta.Store
 	TSDBStore     *tsdb.Store
 	QueryExecutor *tsdb.QueryExecutor
@@ -67,6 +77,7 @@ type Server struct {
 	ClusterService     *cluster.Service
 	SnapshotterService *snapshotter.Service
 	CopierService      *copier.Service
+	RestorerService    *restorer.Service
 
 	Monitor *monitor.Monitor
 
@@ -80,6 +91,12 @@ type Server struct {
 
 // NewServer returns a new instance of Server built from a config.
 func NewServer(c *Config, buildInfo *BuildInfo) (*Server, error) {
+	// Load the TLS configuration for connections between nodes.
+	tlsConfig, err := c.Meta.TLSConfig()
+	if err != nil {
+		return nil, fmt.Errorf("meta tls: %s", err)
+	}
+
 	// Construct base meta store and data store.
 	tsdbStore := tsdb.NewStore(c.Data.Dir)
 	tsdbStore.EngineOptions.Config = c.Data
@@ -91,6 +108,7 @@ func NewServer(c *Config, buildInfo *BuildInfo) (*Server, error) {
 
 		Hostname:    c.Meta.Hostname,
 		BindAddress: c.Meta.BindAddress,
+		tlsConfig:   tlsConfig,
 
 		MetaStore: meta.NewStore(c.Meta),
 		TSDBStore: tsdbStore,
@@ -100,6 +118,8 @@ func NewServer(c *Config, buildInfo *BuildInfo) (*Server, error) {
 		reportingDisabled: c.ReportingDisabled,
 	}
 
+	s.MetaStore.TLSConfig = tlsConfig
+
 	// Copy TSDB configuration.
 	s.TSDBStore.EngineOptions.EngineVersion = c.Data.Engine
 	s.TSDBStore.EngineOptions.MaxWALSize = c.Data.MaxWALSize
@@ -111,21 +131,28 @@ func NewServer(c *Config, buildInfo *BuildInfo) (*Server, error) {
 	s.ShardMapper.ForceRemoteMapping = c.Cluster.ForceRemoteShardMapping
 	s.ShardMapper.MetaStore = s.MetaStore
 	s.ShardMapper.TSDBStore = s.TSDBStore
+	s.ShardMapper.TLSConfig = tlsConfig
 
 	// Initialize query executor.
 	s.QueryExecutor = tsdb.NewQueryExecutor(s.TSDBStore)
 	s.QueryExecutor.MetaStore = s.MetaStore
-	s.QueryExecutor.MetaStatementExecutor = &meta.StatementExecutor{Store: s.MetaStore}
-	s.QueryExecutor.MonitorStatementExecutor = &monitor.StatementExecutor{Monitor: s.Monitor}
+	s.QueryExecutor.MetaStatementExecutor = &meta.StatementExecutor{Store: s.MetaStore, TSDBStore: s.TSDBStore}
 	s.QueryExecutor.ShardMapper = s.ShardMapper
 	s.QueryExecutor.QueryLogEnabled = c.Data.QueryLogEnabled
 
 	// Set the shard writer
 	s.ShardWriter = cluster.NewShardWriter(time.Duration(c.Cluster.ShardWriterTimeout))
 	s.ShardWriter.MetaStore = s.MetaStore
+	s.ShardWriter.Health = s.ShardMapper.Health
+	s.ShardWriter.TLSConfig = tlsConfig
+	s.ShardWriter.BatchSize = c.Cluster.WriteBatchSize
+	s.ShardWriter.MaxInFlight = c.Cluster.WriteMaxInFlight
+	s.ShardWriter.Compression = c.Cluster.WriteCompression
 
 	// Create the hinted handoff service
 	s.HintedHandoff = hh.NewService(c.HintedHandoff, s.ShardWriter)
+	s.HintedHandoff.MetaStore = s.MetaStore
+	s.QueryExecutor.MonitorStatementExecutor = &monitor.StatementExecutor{Monitor: s.Monitor, HintedHandoff: s.HintedHandoff}
 
 	// Initialize points writer.
 	s.PointsWriter = cluster.NewPointsWriter()
@@ -147,7 +174,9 @@ func NewServer(c *Config, buildInfo *BuildInfo) (*Server, error) {
 	s.appendClusterService(c.Cluster)
 	s.appendPrecreatorService(c.Precreator)
 	s.appendSnapshotterService()
-	s.appendCopierService()
+	s.appendCopierService(c.Cluster)
+	s.appendDecommissionerService()
+	s.appendRestorerService()
 	s.appendAdminService(c.Admin)
 	s.appendContinuousQueryService(c.ContinuousQuery)
 	s.appendHTTPDService(c.HTTPD)
@@ -159,6 +188,9 @@ func NewServer(c *Config, buildInfo *BuildInfo) (*Server, error) {
 		s.appendUDPService(g)
 	}
 	s.appendRetentionPolicyService(c.Retention)
+	s.appendTieringService(c.Tiering)
+	s.appendRebalancerService(c.Rebalancer)
+	s.appendAntiEntropyService(c.AntiEntropy, c.Cluster)
 	for _, g := range c.Graphites {
 		if err := s.appendGraphiteService(g); err != nil {
 			return nil, err
@@ -172,6 +204,7 @@ func (s *Server) appendClusterService(c cluster.Config) {
 	srv := cluster.NewService(c)
 	srv.TSDBStore = s.TSDBStore
 	srv.MetaStore = s.MetaStore
+	srv.ShardWriter = s.ShardWriter
 	s.Services = append(s.Services, srv)
 	s.ClusterService = srv
 }
@@ -184,11 +217,37 @@ func (s *Server) appendSnapshotterService() {
 	s.SnapshotterService = srv
 }
 
-func (s *Server) appendCopierService() {
+func (s *Server) appendCopierService(c cluster.Config) {
+	r := cluster.NewShardReader(time.Duration(c.ShardWriterTimeout))
+	r.MetaStore = s.MetaStore
+	r.TLSConfig = s.tlsConfig
+
 	srv := copier.NewService()
+	srv.MetaStore = s.MetaStore
 	srv.TSDBStore = s.TSDBStore
+	srv.ShardReader = r
+	srv.ShardWriter = s.ShardWriter
+	srv.TLSConfig = s.tlsConfig
 	s.Services = append(s.Services, srv)
 	s.CopierService = srv
+	s.QueryExecutor.ShardStatementExecutor = &copier.StatementExecutor{Service: srv}
+}
+
+func (s *Server) appendDecommissionerService() {
+	srv := decommissioner.NewService()
+	srv.MetaStore = s.MetaStore
+	srv.Copier = s.CopierService
+	srv.HintedHandoff = s.HintedHandoff
+	s.Services = append(s.Services, srv)
+}
+
+func (s *Server) appendRestorerService() {
+	srv := restorer.NewService()
+	srv.TSDBStore = s.TSDBStore
+	srv.MetaStore = s.MetaStore
+	srv.Copier = s.CopierService
+	s.Services = append(s.Services, srv)
+	s.RestorerService = srv
 }
 
 func (s *Server) appendRetentionPolicyService(c retention.Config) {
@@ -198,6 +257,47 @@ func (s *Server) appendRetentionPolicyService(c retention.Config) {
 	srv := retention.NewService(c)
 	srv.MetaStore = s.MetaStore
 	srv.TSDBStore = s.TSDBStore
+	srv.QueryExecutor = s.QueryExecutor
+	srv.PointsWriter = s.PointsWriter
+	s.Services = append(s.Services, srv)
+}
+
+func (s *Server) appendTieringService(c tiering.Config) {
+	if !c.Enabled {
+		return
+	}
+	srv := tiering.NewService(c)
+	srv.MetaStore = s.MetaStore
+	srv.TSDBStore = s.TSDBStore
+	s.Services = append(s.Services, srv)
+}
+
+func (s *Server) appendRebalancerService(c rebalancer.Config) {
+	srv := rebalancer.NewService(c)
+	srv.MetaStore = s.MetaStore
+	srv.Copier = s.CopierService
+
+	// The plan can be shown even if the service doesn't start moves.
+	s.QueryExecutor.RebalancerStatementExecutor = &rebalancer.StatementExecutor{Service: srv}
+	if !c.Enabled {
+		return
+	}
+	s.Services = append(s.Services, srv)
+}
+
+func (s *Server) appendAntiEntropyService(c antientropy.Config, cc cluster.Config) {
+	if !c.Enabled {
+		return
+	}
+	r := cluster.NewShardReader(time.Duration(cc.ShardWriterTimeout))
+	r.MetaStore = s.MetaStore
+	r.TLSConfig = s.tlsConfig
+
+	srv := antientropy.NewService(c)
+	srv.MetaStore = s.MetaStore
+	srv.TSDBStore = s.TSDBStore
+	srv.ShardReader = r
+	srv.ShardWriter = s.ShardWriter
 	s.Services = append(s.Services, srv)
 }
 
@@ -339,13 +439,16 @@ func (s *Server) Open() error {
 
 		// Multiplex listener.
 		mux := tcp.NewMux()
+		mux.TLSConfig = s.tlsConfig
 		s.MetaStore.RaftListener = mux.Listen(meta.MuxRaftHeader)
 		s.MetaStore.ExecListener = mux.Listen(meta.MuxExecHeader)
 		s.MetaStore.RPCListener = mux.Listen(meta.MuxRPCHeader)
 
 		s.ClusterService.Listener = mux.Listen(cluster.MuxHeader)
+		s.ClusterService.MapperListener = mux.Listen(cluster.MapperMuxHeader)
 		s.SnapshotterService.Listener = mux.Listen(snapshotter.MuxHeader)
 		s.CopierService.Listener = mux.Listen(copier.MuxHeader)
+		s.RestorerService.Listener = mux.Listen(restorer.MuxHeader)
 		go mux.Serve(ln)
 
 		// Open meta store.
@@ -360,11 +463,15 @@ func (s *Server) Open() error {
 		if err := s.Monitor.Open(); err != nil {
 			return fmt.Errorf("open monitor: %v", err)
 		}
+		s.Monitor.RegisterDiagnosticsClient("wal", monitor.DiagsClientFunc(walDiagnostics))
 
-		// Open TSDB store.
+		// Open TSDB store. Shard policies are set first so shards are opened
+		// with them.
+		s.applyShardPolicies()
 		if err := s.TSDBStore.Open(); err != nil {
 			return fmt.Errorf("open tsdb store: %s", err)
 		}
+		go s.watchShardPolicies()
 
 		// Open the hinted handoff service
 		if err := s.HintedHandoff.Open(); err != nil {
@@ -429,6 +536,30 @@ func (s *Server) Close() error {
 	return nil
 }
 
+// watchShardPolicies reapplies the shard policies whenever the meta store
+// changes.
+func (s *Server) watchShardPolicies() {
+	for {
+		if err := s.MetaStore.WaitForDataChanged(); err != nil {
+			return
+		}
+		s.applyShardPolicies()
+	}
+}
+
+// applyShardPolicies applies the duplicate point policy of each retention
+// policy and the read-only flag of each database to their shards.
+func (s *Server) applyShardPolicies() {
+	s.MetaStore.VisitRetentionPolicies(func(di meta.DatabaseInfo, rpi meta.RetentionPolicyInfo) {
+		s.TSDBStore.SetDuplicatePolicy(di.Name, rpi.Name, rpi.DuplicatePolicy)
+	})
+	if dis, err := s.MetaStore.Databases(); err == nil {
+		for _, di := range dis {
+			s.TSDBStore.SetDatabaseReadOnly(di.Name, di.ReadOnly)
+		}
+	}
+}
+
 // startServerReporting starts periodic server reporting.
 func (s *Server) startServerReporting() {
 	for {
@@ -583,6 +714,15 @@ func stopProfile() {
 	}
 }
 
+// walDiagnostics returns the damage repaired while recovering the WAL of each shard.
+func walDiagnostics() (*monitor.Diagnostic, error) {
+	d := monitor.NewDiagnostic([]string{"path", "corrupt_blocks", "lost_entries", "truncated_bytes", "quarantined_files"})
+	for _, r := range wal.Recoveries() {
+		d.AddRow([]interface{}{r.Path, r.CorruptBlocks, r.LostEntries, r.TruncatedBytes, strings.Join(r.QuarantinedFiles, ",")})
+	}
+	return d, nil
+}
+
 type tcpaddr struct{ host string }
 
 func (a *tcpaddr) Network() string { return "tcp" }
@@ -357,7 +357,7 @@ func TestServer_RetentionPolicyCommands(t *testing.T) {
 			&Query{
 				name:    "show retention policy should succeed",
 				command: `SHOW RETENTION POLICIES ON db0`,
-				exp:     `{"results":[{"series":[{"columns":["name","duration","replicaN","default"],"values":[["rp0","1h0m0s",1,false]]}]}]}`,
+				exp:     `{"results":[{"series":[{"columns":["name","duration","replicaN","default","downsample_to","downsample_interval","duplicate_policy"],"values":[["rp0","1h0m0s",1,false,"","","replace"]]}]}]}`,
 			},
 			&Query{
 				name:    "alter retention policy should succeed",
@@ -367,7 +367,7 @@ func TestServer_RetentionPolicyCommands(t *testing.T) {
 			&Query{
 				name:    "show retention policy should have new altered information",
 				command: `SHOW RETENTION POLICIES ON db0`,
-				exp:     `{"results":[{"series":[{"columns":["name","duration","replicaN","default"],"values":[["rp0","2h0m0s",3,true]]}]}]}`,
+				exp:     `{"results":[{"series":[{"columns":["name","duration","replicaN","default","downsample_to","downsample_interval","duplicate_policy"],"values":[["rp0","2h0m0s",3,true,"","","replace"]]}]}]}`,
 			},
 			&Query{
 				name:    "dropping default retention policy should not succeed",
@@ -377,7 +377,7 @@ func TestServer_RetentionPolicyCommands(t *testing.T) {
 			&Query{
 				name:    "show retention policy should still show policy",
 				command: `SHOW RETENTION POLICIES ON db0`,
-				exp:     `{"results":[{"series":[{"columns":["name","duration","replicaN","default"],"values":[["rp0","2h0m0s",3,true]]}]}]}`,
+				exp:     `{"results":[{"series":[{"columns":["name","duration","replicaN","default","downsample_to","downsample_interval","duplicate_policy"],"values":[["rp0","2h0m0s",3,true,"","","replace"]]}]}]}`,
 			},
 			&Query{
 				name:    "create a second non-default retention policy",
@@ -387,7 +387,7 @@ func TestServer_RetentionPolicyCommands(t *testing.T) {
 			&Query{
 				name:    "show retention policy should show both",
 				command: `SHOW RETENTION POLICIES ON db0`,
-				exp:     `{"results":[{"series":[{"columns":["name","duration","replicaN","default"],"values":[["rp0","2h0m0s",3,true],["rp2","1h0m0s",1,false]]}]}]}`,
+				exp:     `{"results":[{"series":[{"columns":["name","duration","replicaN","default","downsample_to","downsample_interval","duplicate_policy"],"values":[["rp0","2h0m0s",3,true,"","","replace"],["rp2","1h0m0s",1,false,"","","replace"]]}]}]}`,
 			},
 			&Query{
 				name:    "dropping non-default retention policy succeed",
@@ -397,7 +397,7 @@ func TestServer_RetentionPolicyCommands(t *testing.T) {
 			&Query{
 				name:    "show retention policy should show just default",
 				command: `SHOW RETENTION POLICIES ON db0`,
-				exp:     `{"results":[{"series":[{"columns":["name","duration","replicaN","default"],"values":[["rp0","2h0m0s",3,true]]}]}]}`,
+				exp:     `{"results":[{"series":[{"columns":["name","duration","replicaN","default","downsample_to","downsample_interval","duplicate_policy"],"values":[["rp0","2h0m0s",3,true,"","","replace"]]}]}]}`,
 			},
 			&Query{
 				name:    "Ensure retention policy with unacceptable retention cannot be created",
@@ -441,7 +441,7 @@ func TestServer_DatabaseRetentionPolicyAutoCreate(t *testing.T) {
 			&Query{
 				name:    "show retention policies should return auto-created policy",
 				command: `SHOW RETENTION POLICIES ON db0`,
-				exp:     `{"results":[{"series":[{"columns":["name","duration","replicaN","default"],"values":[["default","0",1,true]]}]}]}`,
+				exp:     `{"results":[{"series":[{"columns":["name","duration","replicaN","default","downsample_to","downsample_interval","duplicate_policy"],"values":[["default","0",1,true,"","","replace"]]}]}]}`,
 			},
 		},
 	}
@@ -705,7 +705,7 @@ func TestServer_Query_DefaultDBAndRP(t *testing.T) {
 		&Query{
 			name:    "default rp exists",
 			command: `show retention policies ON db0`,
-			exp:     `{"results":[{"series":[{"columns":["name","duration","replicaN","default"],"values":[["default","0",1,false],["rp0","1h0m0s",1,true]]}]}]}`,
+			exp:     `{"results":[{"series":[{"columns":["name","duration","replicaN","default","downsample_to","downsample_interval","duplicate_policy"],"values":[["default","0",1,false,"","","replace"],["rp0","1h0m0s",1,true,"","","replace"]]}]}]}`,
 		},
 		&Query{
 			name:    "default rp",
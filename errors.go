@@ -14,6 +14,9 @@ var (
 
 	// ErrFieldTypeConflict is returned when a new field already exists with a different type.
 	ErrFieldTypeConflict = errors.New("field type conflict")
+
+	// ErrDatabaseReadOnly is returned when writing to a read-only database.
+	ErrDatabaseReadOnly = errors.New("database is read-only")
 )
 
 func ErrDatabaseNotFound(name string) error { return fmt.Errorf("database not found: %s", name) }
@@ -54,6 +57,16 @@ func IsClientError(err error) bool {
 	return false
 }
 
+// IsReadOnlyError indicates whether a write was rejected because the
+// database is read-only. Errors returned by remote nodes only carry the
+// message, so it is matched as well.
+func IsReadOnlyError(err error) bool {
+	if err == nil {
+		return false
+	}
+	return err == ErrDatabaseReadOnly || strings.Contains(err.Error(), ErrDatabaseReadOnly.Error())
+}
+
 // mustMarshal encodes a value to JSON.
 // This will panic if an error occurs. This should only be used internally when
 // an invalid marshal will cause corruption and a panic is appropriate.
@@ -31,6 +31,8 @@ const (
 	Time = 5
 	// Duration means the data type is a duration of time.
 	Duration = 6
+	// Unsigned means the data type is an unsigned integer.
+	Unsigned = 7
 )
 
 // InspectDataType returns the data type of a given value.
@@ -40,6 +42,8 @@ func InspectDataType(v interface{}) DataType {
 		return Float
 	case int64, int32, int:
 		return Integer
+	case uint64:
+		return Unsigned
 	case bool:
 		return Boolean
 	case string:
@@ -59,6 +63,8 @@ func (d DataType) String() string {
 		return "float"
 	case Integer:
 		return "integer"
+	case Unsigned:
+		return "unsigned"
 	case Boolean:
 		return "boolean"
 	case String:
@@ -80,7 +86,9 @@ type Node interface {
 func (*Query) node()     {}
 func (Statements) node() {}
 
+func (*AlterDatabaseStatement) node()         {}
 func (*AlterRetentionPolicyStatement) node()  {}
+func (*CopyShardStatement) node()             {}
 func (*CreateContinuousQueryStatement) node() {}
 func (*CreateDatabaseStatement) node()        {}
 func (*CreateRetentionPolicyStatement) node() {}
@@ -92,21 +100,30 @@ func (*DropDatabaseStatement) node()          {}
 func (*DropMeasurementStatement) node()       {}
 func (*DropRetentionPolicyStatement) node()   {}
 func (*DropSeriesStatement) node()            {}
+func (*DropServerStatement) node()            {}
 func (*DropUserStatement) node()              {}
 func (*GrantStatement) node()                 {}
 func (*GrantAdminStatement) node()            {}
+func (*MoveShardStatement) node()             {}
+func (*PauseHintedHandoffStatement) node()    {}
+func (*PurgeHintedHandoffStatement) node()    {}
+func (*ResumeHintedHandoffStatement) node()   {}
 func (*RevokeStatement) node()                {}
 func (*RevokeAdminStatement) node()           {}
 func (*SelectStatement) node()                {}
 func (*SetPasswordUserStatement) node()       {}
 func (*ShowContinuousQueriesStatement) node() {}
+func (*SnapshotMetaStatement) node()          {}
 func (*ShowGrantsForUserStatement) node()     {}
+func (*ShowHintedHandoffStatement) node()     {}
 func (*ShowServersStatement) node()           {}
 func (*ShowDatabasesStatement) node()         {}
 func (*ShowFieldKeysStatement) node()         {}
 func (*ShowRetentionPoliciesStatement) node() {}
 func (*ShowMeasurementsStatement) node()      {}
 func (*ShowSeriesStatement) node()            {}
+func (*ShowShardMovesStatement) node()        {}
+func (*ShowShardPlanStatement) node()         {}
 func (*ShowShardsStatement) node()            {}
 func (*ShowStatsStatement) node()             {}
 func (*ShowDiagnosticsStatement) node()       {}
@@ -187,7 +204,9 @@ type ExecutionPrivilege struct {
 // ExecutionPrivileges is a list of privileges required to execute a statement.
 type ExecutionPrivileges []ExecutionPrivilege
 
+func (*AlterDatabaseStatement) stmt()         {}
 func (*AlterRetentionPolicyStatement) stmt()  {}
+func (*CopyShardStatement) stmt()             {}
 func (*CreateContinuousQueryStatement) stmt() {}
 func (*CreateDatabaseStatement) stmt()        {}
 func (*CreateRetentionPolicyStatement) stmt() {}
@@ -198,17 +217,26 @@ func (*DropDatabaseStatement) stmt()          {}
 func (*DropMeasurementStatement) stmt()       {}
 func (*DropRetentionPolicyStatement) stmt()   {}
 func (*DropSeriesStatement) stmt()            {}
+func (*DropServerStatement) stmt()            {}
 func (*DropUserStatement) stmt()              {}
 func (*GrantStatement) stmt()                 {}
 func (*GrantAdminStatement) stmt()            {}
+func (*MoveShardStatement) stmt()             {}
+func (*PauseHintedHandoffStatement) stmt()    {}
+func (*PurgeHintedHandoffStatement) stmt()    {}
+func (*ResumeHintedHandoffStatement) stmt()   {}
 func (*ShowContinuousQueriesStatement) stmt() {}
+func (*SnapshotMetaStatement) stmt()          {}
 func (*ShowGrantsForUserStatement) stmt()     {}
+func (*ShowHintedHandoffStatement) stmt()     {}
 func (*ShowServersStatement) stmt()           {}
 func (*ShowDatabasesStatement) stmt()         {}
 func (*ShowFieldKeysStatement) stmt()         {}
 func (*ShowMeasurementsStatement) stmt()      {}
 func (*ShowRetentionPoliciesStatement) stmt() {}
 func (*ShowSeriesStatement) stmt()            {}
+func (*ShowShardMovesStatement) stmt()        {}
+func (*ShowShardPlanStatement) stmt()         {}
 func (*ShowShardsStatement) stmt()            {}
 func (*ShowStatsStatement) stmt()             {}
 func (*ShowDiagnosticsStatement) stmt()       {}
@@ -588,6 +616,15 @@ type CreateRetentionPolicyStatement struct {
 
 	// Should this policy be set as default for the database?
 	Default bool
+
+	// Name of the retention policy that expired data is downsampled into.
+	DownsampleTo string
+
+	// Interval that expired data is downsampled to.
+	DownsampleInterval time.Duration
+
+	// Policy for points written to a series at an existing timestamp.
+	DuplicatePolicy string
 }
 
 // String returns a string representation of the create retention policy.
@@ -604,6 +641,16 @@ func (s *CreateRetentionPolicyStatement) String() string {
 	if s.Default {
 		_, _ = buf.WriteString(" DEFAULT")
 	}
+	if s.DownsampleTo != "" {
+		_, _ = buf.WriteString(" DOWNSAMPLE TO ")
+		_, _ = buf.WriteString(QuoteIdent(s.DownsampleTo))
+		_, _ = buf.WriteString(" EVERY ")
+		_, _ = buf.WriteString(FormatDuration(s.DownsampleInterval))
+	}
+	if s.DuplicatePolicy != "" {
+		_, _ = buf.WriteString(" DUPLICATE ")
+		_, _ = buf.WriteString(s.DuplicatePolicy)
+	}
 	return buf.String()
 }
 
@@ -612,6 +659,33 @@ func (s *CreateRetentionPolicyStatement) RequiredPrivileges() ExecutionPrivilege
 	return ExecutionPrivileges{{Admin: true, Name: "", Privilege: AllPrivileges}}
 }
 
+// AlterDatabaseStatement represents a command to change whether a database accepts writes.
+type AlterDatabaseStatement struct {
+	// Name of the database to alter.
+	Name string
+
+	// Should writes to the database be rejected?
+	ReadOnly bool
+}
+
+// String returns a string representation of the alter database statement.
+func (s *AlterDatabaseStatement) String() string {
+	var buf bytes.Buffer
+	_, _ = buf.WriteString("ALTER DATABASE ")
+	_, _ = buf.WriteString(s.Name)
+	if s.ReadOnly {
+		_, _ = buf.WriteString(" READONLY")
+	} else {
+		_, _ = buf.WriteString(" READWRITE")
+	}
+	return buf.String()
+}
+
+// RequiredPrivileges returns the privilege required to execute an AlterDatabaseStatement.
+func (s *AlterDatabaseStatement) RequiredPrivileges() ExecutionPrivileges {
+	return ExecutionPrivileges{{Admin: true, Name: "", Privilege: AllPrivileges}}
+}
+
 // AlterRetentionPolicyStatement represents a command to alter an existing retention policy.
 type AlterRetentionPolicyStatement struct {
 	// Name of policy to alter.
@@ -628,6 +702,16 @@ type AlterRetentionPolicyStatement struct {
 
 	// Should this policy be set as defalut for the database?
 	Default bool
+
+	// Name of the retention policy that expired data is downsampled into.
+	// An empty name stops downsampling.
+	DownsampleTo *string
+
+	// Interval that expired data is downsampled to.
+	DownsampleInterval *time.Duration
+
+	// Policy for points written to a series at an existing timestamp.
+	DuplicatePolicy *string
 }
 
 // String returns a string representation of the alter retention policy statement.
@@ -652,6 +736,20 @@ func (s *AlterRetentionPolicyStatement) String() string {
 		_, _ = buf.WriteString(" DEFAULT")
 	}
 
+	if s.DownsampleTo != nil && *s.DownsampleTo == "" {
+		_, _ = buf.WriteString(" NO DOWNSAMPLE")
+	} else if s.DownsampleTo != nil && s.DownsampleInterval != nil {
+		_, _ = buf.WriteString(" DOWNSAMPLE TO ")
+		_, _ = buf.WriteString(QuoteIdent(*s.DownsampleTo))
+		_, _ = buf.WriteString(" EVERY ")
+		_, _ = buf.WriteString(FormatDuration(*s.DownsampleInterval))
+	}
+
+	if s.DuplicatePolicy != nil {
+		_, _ = buf.WriteString(" DUPLICATE ")
+		_, _ = buf.WriteString(*s.DuplicatePolicy)
+	}
+
 	return buf.String()
 }
 
@@ -1824,6 +1922,31 @@ func (s DropSeriesStatement) RequiredPrivileges() ExecutionPrivileges {
 	return ExecutionPrivileges{{Admin: false, Name: "", Privilege: WritePrivilege}}
 }
 
+// DropServerStatement represents a command for removing a server from the cluster.
+type DropServerStatement struct {
+	// ID of the node to drop.
+	NodeID uint64
+
+	// Moves the node's shards to other nodes before it's removed.
+	Decommission bool
+}
+
+// String returns a string representation of the drop server statement.
+func (s *DropServerStatement) String() string {
+	var buf bytes.Buffer
+	_, _ = buf.WriteString("DROP SERVER ")
+	_, _ = buf.WriteString(strconv.FormatUint(s.NodeID, 10))
+	if s.Decommission {
+		_, _ = buf.WriteString(" DECOMMISSION")
+	}
+	return buf.String()
+}
+
+// RequiredPrivileges returns the privilege required to execute a DropServerStatement.
+func (s *DropServerStatement) RequiredPrivileges() ExecutionPrivileges {
+	return ExecutionPrivileges{{Admin: true, Name: "", Privilege: AllPrivileges}}
+}
+
 // ShowContinuousQueriesStatement represents a command for listing continuous queries.
 type ShowContinuousQueriesStatement struct{}
 
@@ -2052,6 +2175,143 @@ func (s *ShowShardsStatement) RequiredPrivileges() ExecutionPrivileges {
 	return ExecutionPrivileges{{Admin: true, Name: "", Privilege: AllPrivileges}}
 }
 
+// CopyShardStatement represents a command for copying a shard to another node.
+type CopyShardStatement struct {
+	// ID of the shard to copy.
+	ID uint64
+
+	// ID of the node to copy the shard to.
+	To uint64
+}
+
+// String returns a string representation of the copy shard statement.
+func (s *CopyShardStatement) String() string {
+	return fmt.Sprintf("COPY SHARD %d TO %d", s.ID, s.To)
+}
+
+// RequiredPrivileges returns the privileges required to execute a CopyShardStatement.
+func (s *CopyShardStatement) RequiredPrivileges() ExecutionPrivileges {
+	return ExecutionPrivileges{{Admin: true, Name: "", Privilege: AllPrivileges}}
+}
+
+// MoveShardStatement represents a command for moving a shard between nodes.
+type MoveShardStatement struct {
+	// ID of the shard to move.
+	ID uint64
+
+	// IDs of the node owning the shard and the node to move it to.
+	From uint64
+	To   uint64
+}
+
+// String returns a string representation of the move shard statement.
+func (s *MoveShardStatement) String() string {
+	return fmt.Sprintf("MOVE SHARD %d FROM %d TO %d", s.ID, s.From, s.To)
+}
+
+// RequiredPrivileges returns the privileges required to execute a MoveShardStatement.
+func (s *MoveShardStatement) RequiredPrivileges() ExecutionPrivileges {
+	return ExecutionPrivileges{{Admin: true, Name: "", Privilege: AllPrivileges}}
+}
+
+// ShowShardMovesStatement represents a command for displaying shard copies and moves.
+type ShowShardMovesStatement struct{}
+
+// String returns a string representation.
+func (s *ShowShardMovesStatement) String() string { return "SHOW SHARD MOVES" }
+
+// RequiredPrivileges returns the privileges required to execute the statement.
+func (s *ShowShardMovesStatement) RequiredPrivileges() ExecutionPrivileges {
+	return ExecutionPrivileges{{Admin: true, Name: "", Privilege: AllPrivileges}}
+}
+
+// ShowShardPlanStatement represents a command for displaying the shard moves
+// the rebalancer would start.
+type ShowShardPlanStatement struct{}
+
+// String returns a string representation.
+func (s *ShowShardPlanStatement) String() string { return "SHOW SHARD PLAN" }
+
+// RequiredPrivileges returns the privileges required to execute the statement.
+func (s *ShowShardPlanStatement) RequiredPrivileges() ExecutionPrivileges {
+	return ExecutionPrivileges{{Admin: true, Name: "", Privilege: AllPrivileges}}
+}
+
+// ShowHintedHandoffStatement represents a command for displaying the hinted
+// handoff queues of the node receiving the query.
+type ShowHintedHandoffStatement struct{}
+
+// String returns a string representation.
+func (s *ShowHintedHandoffStatement) String() string { return "SHOW HINTED HANDOFF" }
+
+// RequiredPrivileges returns the privileges required to execute the statement.
+func (s *ShowHintedHandoffStatement) RequiredPrivileges() ExecutionPrivileges {
+	return ExecutionPrivileges{{Admin: true, Name: "", Privilege: AllPrivileges}}
+}
+
+// PurgeHintedHandoffStatement represents a command for dropping the writes
+// queued for a node.
+type PurgeHintedHandoffStatement struct {
+	// ID of the node the writes are queued for.
+	NodeID uint64
+}
+
+// String returns a string representation of the purge statement.
+func (s *PurgeHintedHandoffStatement) String() string {
+	return fmt.Sprintf("PURGE HINTED HANDOFF %d", s.NodeID)
+}
+
+// RequiredPrivileges returns the privileges required to execute a PurgeHintedHandoffStatement.
+func (s *PurgeHintedHandoffStatement) RequiredPrivileges() ExecutionPrivileges {
+	return ExecutionPrivileges{{Admin: true, Name: "", Privilege: AllPrivileges}}
+}
+
+// PauseHintedHandoffStatement represents a command for stopping the replay of
+// the writes queued for a node.
+type PauseHintedHandoffStatement struct {
+	// ID of the node the writes are queued for.
+	NodeID uint64
+}
+
+// String returns a string representation of the pause statement.
+func (s *PauseHintedHandoffStatement) String() string {
+	return fmt.Sprintf("PAUSE HINTED HANDOFF %d", s.NodeID)
+}
+
+// RequiredPrivileges returns the privileges required to execute a PauseHintedHandoffStatement.
+func (s *PauseHintedHandoffStatement) RequiredPrivileges() ExecutionPrivileges {
+	return ExecutionPrivileges{{Admin: true, Name: "", Privilege: AllPrivileges}}
+}
+
+// ResumeHintedHandoffStatement represents a command for restarting the replay
+// of the writes queued for a node.
+type ResumeHintedHandoffStatement struct {
+	// ID of the node the writes are queued for.
+	NodeID uint64
+}
+
+// String returns a string representation of the resume statement.
+func (s *ResumeHintedHandoffStatement) String() string {
+	return fmt.Sprintf("RESUME HINTED HANDOFF %d", s.NodeID)
+}
+
+// RequiredPrivileges returns the privileges required to execute a ResumeHintedHandoffStatement.
+func (s *ResumeHintedHandoffStatement) RequiredPrivileges() ExecutionPrivileges {
+	return ExecutionPrivileges{{Admin: true, Name: "", Privilege: AllPrivileges}}
+}
+
+// SnapshotMetaStatement represents a command for snapshotting the meta store's
+// raft log on the node receiving the query.
+type SnapshotMetaStatement struct{}
+
+// String returns a string representation of the snapshot statement.
+func (s *SnapshotMetaStatement) String() string { return "SNAPSHOT META" }
+
+// RequiredPrivileges returns the privileges required to execute a SnapshotMetaStatement.
+func (s *SnapshotMetaStatement) RequiredPrivileges() ExecutionPrivileges {
+	return ExecutionPrivileges{{Admin: true, Name: "", Privilege: AllPrivileges}}
+}
+
 // ShowDiagnosticsStatement represents a command for show node diagnostics.
 type ShowDiagnosticsStatement struct {
 	// Module
@@ -2943,6 +3203,8 @@ func Eval(expr Expr, m map[string]interface{}) interface{} {
 		return evalBinaryExpr(expr, m)
 	case *BooleanLiteral:
 		return expr.Val
+	case *DurationLiteral:
+		return expr.Val
 	case *NumberLiteral:
 		return expr.Val
 	case *ParenExpr:
@@ -3031,6 +3293,49 @@ func evalBinaryExpr(expr *BinaryExpr, m map[string]interface{}) interface{} {
 			}
 			return lhs / rhs
 		}
+	case uint64:
+		// number literals are parsed as float64, so compare the unsigned
+		// value as a float64 as well
+		rhs, _ := rhs.(float64)
+		lhsf := float64(lhs)
+		switch expr.Op {
+		case EQ:
+			return lhsf == rhs
+		case NEQ:
+			return lhsf != rhs
+		case LT:
+			return lhsf < rhs
+		case LTE:
+			return lhsf <= rhs
+		case GT:
+			return lhsf > rhs
+		case GTE:
+			return lhsf >= rhs
+		}
+	case time.Duration:
+		// durations compare with duration literals, or with number literals
+		// as nanoseconds
+		var rhsd time.Duration
+		switch rhs := rhs.(type) {
+		case time.Duration:
+			rhsd = rhs
+		case float64:
+			rhsd = time.Duration(rhs)
+		}
+		switch expr.Op {
+		case EQ:
+			return lhs == rhsd
+		case NEQ:
+			return lhs != rhsd
+		case LT:
+			return lhs < rhsd
+		case LTE:
+			return lhs <= rhsd
+		case GT:
+			return lhs > rhsd
+		case GTE:
+			return lhs >= rhsd
+		}
 	case string:
 		rhs, _ := rhs.(string)
 		switch expr.Op {
@@ -17,6 +17,9 @@ func TestInspectDataType(t *testing.T) {
 		typ influxql.DataType
 	}{
 		{float64(100), influxql.Float},
+		{int64(100), influxql.Integer},
+		{uint64(100), influxql.Unsigned},
+		{time.Second, influxql.Duration},
 	} {
 		if typ := influxql.InspectDataType(tt.v); tt.typ != typ {
 			t.Errorf("%d. %v (%s): unexpected type: %s", i, tt.v, tt.typ, typ)
@@ -651,6 +654,10 @@ func TestEval(t *testing.T) {
 		{in: `foo = 'bar'`, out: true, data: map[string]interface{}{"foo": "bar"}},
 		{in: `foo = 'bar'`, out: nil, data: map[string]interface{}{"foo": nil}},
 		{in: `foo <> 'bar'`, out: true, data: map[string]interface{}{"foo": "xxx"}},
+		{in: `foo > 10`, out: true, data: map[string]interface{}{"foo": uint64(18446744073709551615)}},
+		{in: `foo = 10`, out: true, data: map[string]interface{}{"foo": uint64(10)}},
+		{in: `foo > 1s`, out: true, data: map[string]interface{}{"foo": 2 * time.Second}},
+		{in: `foo = 1500`, out: true, data: map[string]interface{}{"foo": 1500 * time.Nanosecond}},
 	} {
 		// Evaluate expression.
 		out := influxql.Eval(MustParseExpr(tt.in), tt.data)
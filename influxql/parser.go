@@ -96,9 +96,21 @@ func (p *Parser) ParseStatement() (Statement, error) {
 		return p.parseAlterStatement()
 	case SET:
 		return p.parseSetPasswordUserStatement()
-	default:
-		return nil, newParseError(tokstr(tok, lit), []string{"SELECT", "DELETE", "SHOW", "CREATE", "DROP", "GRANT", "REVOKE", "ALTER", "SET"}, pos)
+	case COPY:
+		return p.parseCopyShardStatement()
+	case MOVE:
+		return p.parseMoveShardStatement()
+	case IDENT:
+		// PURGE, PAUSE, RESUME and SNAPSHOT aren't keywords so they can still be used as identifiers.
+		switch strings.ToLower(lit) {
+		case "purge", "pause", "resume":
+			return p.parseHintedHandoffStatement(strings.ToLower(lit))
+		case "snapshot":
+			return p.parseSnapshotMetaStatement()
+		}
 	}
+
+	return nil, newParseError(tokstr(tok, lit), []string{"SELECT", "DELETE", "SHOW", "CREATE", "DROP", "GRANT", "REVOKE", "ALTER", "SET", "COPY", "MOVE", "PURGE", "PAUSE", "RESUME", "SNAPSHOT"}, pos)
 }
 
 // parseShowStatement parses a string and returns a list statement.
@@ -130,6 +142,14 @@ func (p *Parser) parseShowStatement() (Statement, error) {
 		return nil, newParseError(tokstr(tok, lit), []string{"POLICIES"}, pos)
 	case SERIES:
 		return p.parseShowSeriesStatement()
+	case SHARD:
+		tok, pos, lit := p.scanIgnoreWhitespace()
+		if tok == MOVES {
+			return &ShowShardMovesStatement{}, nil
+		} else if tok == PLAN {
+			return &ShowShardPlanStatement{}, nil
+		}
+		return nil, newParseError(tokstr(tok, lit), []string{"MOVES", "PLAN"}, pos)
 	case SHARDS:
 		return p.parseShowShardsStatement()
 	case STATS:
@@ -146,6 +166,15 @@ func (p *Parser) parseShowStatement() (Statement, error) {
 		return nil, newParseError(tokstr(tok, lit), []string{"KEYS", "VALUES"}, pos)
 	case USERS:
 		return p.parseShowUsersStatement()
+	case IDENT:
+		// HINTED isn't a keyword so it can still be used as an identifier.
+		if strings.ToLower(lit) == "hinted" {
+			p.unscan()
+			if err := p.parseHintedHandoff(); err != nil {
+				return nil, err
+			}
+			return &ShowHintedHandoffStatement{}, nil
+		}
 	}
 
 	showQueryKeywords := []string{
@@ -153,6 +182,7 @@ func (p *Parser) parseShowStatement() (Statement, error) {
 		"DATABASES",
 		"FIELD",
 		"GRANTS",
+		"HINTED",
 		"MEASUREMENTS",
 		"RETENTION",
 		"SERIES",
@@ -161,6 +191,7 @@ func (p *Parser) parseShowStatement() (Statement, error) {
 		"USERS",
 		"STATS",
 		"DIAGNOSTICS",
+		"SHARD",
 		"SHARDS",
 	}
 	sort.Strings(showQueryKeywords)
@@ -208,9 +239,12 @@ func (p *Parser) parseDropStatement() (Statement, error) {
 		return p.parseDropRetentionPolicyStatement()
 	} else if tok == USER {
 		return p.parseDropUserStatement()
+	} else if tok == IDENT && strings.ToLower(lit) == "server" {
+		// SERVER isn't a keyword so it can still be used as an identifier.
+		return p.parseDropServerStatement()
 	}
 
-	return nil, newParseError(tokstr(tok, lit), []string{"SERIES", "CONTINUOUS", "MEASUREMENT"}, pos)
+	return nil, newParseError(tokstr(tok, lit), []string{"SERIES", "CONTINUOUS", "MEASUREMENT", "SERVER"}, pos)
 }
 
 // parseAlterStatement parses a string and returns an alter statement.
@@ -222,9 +256,37 @@ func (p *Parser) parseAlterStatement() (Statement, error) {
 			return nil, newParseError(tokstr(tok, lit), []string{"POLICY"}, pos)
 		}
 		return p.parseAlterRetentionPolicyStatement()
+	} else if tok == DATABASE {
+		return p.parseAlterDatabaseStatement()
 	}
 
-	return nil, newParseError(tokstr(tok, lit), []string{"RETENTION"}, pos)
+	return nil, newParseError(tokstr(tok, lit), []string{"RETENTION", "DATABASE"}, pos)
+}
+
+// parseAlterDatabaseStatement parses a string and returns an AlterDatabaseStatement.
+// This function assumes the ALTER DATABASE tokens have already been consumed.
+func (p *Parser) parseAlterDatabaseStatement() (*AlterDatabaseStatement, error) {
+	stmt := &AlterDatabaseStatement{}
+
+	// Parse the database name.
+	ident, err := p.parseIdent()
+	if err != nil {
+		return nil, err
+	}
+	stmt.Name = ident
+
+	// Parse the write mode.
+	tok, pos, lit := p.scanIgnoreWhitespace()
+	switch tok {
+	case READONLY:
+		stmt.ReadOnly = true
+	case READWRITE:
+		stmt.ReadOnly = false
+	default:
+		return nil, newParseError(tokstr(tok, lit), []string{"READONLY", "READWRITE"}, pos)
+	}
+
+	return stmt, nil
 }
 
 // parseSetPasswordUserStatement parses a string and returns a set statement.
@@ -315,9 +377,78 @@ func (p *Parser) parseCreateRetentionPolicyStatement() (*CreateRetentionPolicySt
 		p.unscan()
 	}
 
+	// Parse optional DOWNSAMPLE clause.
+	if tok, pos, lit = p.scanIgnoreWhitespace(); tok == DOWNSAMPLE {
+		name, d, err := p.parseDownsample()
+		if err != nil {
+			return nil, err
+		}
+		stmt.DownsampleTo, stmt.DownsampleInterval = name, d
+	} else {
+		p.unscan()
+	}
+
+	// Parse optional DUPLICATE clause.
+	if tok, pos, lit = p.scanIgnoreWhitespace(); tok == DUPLICATE {
+		policy, err := p.parseDuplicatePolicy()
+		if err != nil {
+			return nil, err
+		}
+		stmt.DuplicatePolicy = policy
+	} else {
+		p.unscan()
+	}
+
 	return stmt, nil
 }
 
+// parseDownsample parses the target retention policy and interval of a downsample clause.
+// This function assumes the DOWNSAMPLE token has already been consumed.
+func (p *Parser) parseDownsample() (string, time.Duration, error) {
+	// Consume the required TO token.
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != TO {
+		return "", 0, newParseError(tokstr(tok, lit), []string{"TO"}, pos)
+	}
+
+	// Parse the target retention policy name.
+	name, err := p.parseIdent()
+	if err != nil {
+		return "", 0, err
+	}
+
+	// Consume the required EVERY token.
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != EVERY {
+		return "", 0, newParseError(tokstr(tok, lit), []string{"EVERY"}, pos)
+	}
+
+	// Parse the interval, which must be finite.
+	tok, pos, lit := p.scanIgnoreWhitespace()
+	if tok != DURATION_VAL {
+		return "", 0, newParseError(tokstr(tok, lit), []string{"duration"}, pos)
+	}
+	d, err := ParseDuration(lit)
+	if err != nil {
+		return "", 0, &ParseError{Message: err.Error(), Pos: pos}
+	} else if d <= 0 {
+		return "", 0, &ParseError{Message: "downsample interval must be positive", Pos: pos}
+	}
+
+	return name, d, nil
+}
+
+// parseDuplicatePolicy parses the policy name of a duplicate clause.
+// This function assumes the DUPLICATE token has already been consumed.
+func (p *Parser) parseDuplicatePolicy() (string, error) {
+	tok, pos, lit := p.scanIgnoreWhitespace()
+	if tok == IDENT {
+		switch policy := strings.ToLower(lit); policy {
+		case "replace", "merge", "first":
+			return policy, nil
+		}
+	}
+	return "", newParseError(tokstr(tok, lit), []string{"REPLACE", "MERGE", "FIRST"}, pos)
+}
+
 // parseAlterRetentionPolicyStatement parses a string and returns an alter retention policy statement.
 // This function assumes the ALTER RETENTION POLICY tokens have already been consumed.
 func (p *Parser) parseAlterRetentionPolicyStatement() (*AlterRetentionPolicyStatement, error) {
@@ -346,7 +477,7 @@ func (p *Parser) parseAlterRetentionPolicyStatement() (*AlterRetentionPolicyStat
 	stmt.Database = ident
 
 	// Loop through option tokens (DURATION, REPLICATION, DEFAULT, etc.).
-	maxNumOptions := 3
+	maxNumOptions := 5
 Loop:
 	for i := 0; i < maxNumOptions; i++ {
 		tok, pos, lit := p.scanIgnoreWhitespace()
@@ -365,9 +496,37 @@ Loop:
 			stmt.Replication = &n
 		case DEFAULT:
 			stmt.Default = true
+		case DOWNSAMPLE:
+			name, d, err := p.parseDownsample()
+			if err != nil {
+				return nil, err
+			}
+			stmt.DownsampleTo, stmt.DownsampleInterval = &name, &d
+		case DUPLICATE:
+			policy, err := p.parseDuplicatePolicy()
+			if err != nil {
+				return nil, err
+			}
+			stmt.DuplicatePolicy = &policy
+		case IDENT:
+			// NO isn't a keyword so it can still be used as an identifier.
+			if strings.ToUpper(lit) != "NO" {
+				if i < 1 {
+					return nil, newParseError(tokstr(tok, lit), []string{"DURATION", "RETENTION", "DEFAULT", "DOWNSAMPLE", "DUPLICATE", "NO"}, pos)
+				}
+				p.unscan()
+				break Loop
+			}
+
+			// NO DOWNSAMPLE stops downsampling expired data.
+			if tok, pos, lit := p.scanIgnoreWhitespace(); tok != DOWNSAMPLE {
+				return nil, newParseError(tokstr(tok, lit), []string{"DOWNSAMPLE"}, pos)
+			}
+			name := ""
+			stmt.DownsampleTo, stmt.DownsampleInterval = &name, nil
 		default:
 			if i < 1 {
-				return nil, newParseError(tokstr(tok, lit), []string{"DURATION", "RETENTION", "DEFAULT"}, pos)
+				return nil, newParseError(tokstr(tok, lit), []string{"DURATION", "RETENTION", "DEFAULT", "DOWNSAMPLE", "DUPLICATE", "NO"}, pos)
 			}
 			p.unscan()
 			break Loop
@@ -1445,6 +1604,131 @@ func (p *Parser) parseShowShardsStatement() (*ShowShardsStatement, error) {
 	return &ShowShardsStatement{}, nil
 }
 
+// parseCopyShardStatement parses a string and returns a CopyShardStatement.
+// This function assumes the COPY token has already been consumed.
+func (p *Parser) parseCopyShardStatement() (*CopyShardStatement, error) {
+	stmt := &CopyShardStatement{}
+
+	// Parse the shard id.
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != SHARD {
+		return nil, newParseError(tokstr(tok, lit), []string{"SHARD"}, pos)
+	}
+	id, err := p.parseUInt64()
+	if err != nil {
+		return nil, err
+	}
+	stmt.ID = id
+
+	// Parse the destination node id.
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != TO {
+		return nil, newParseError(tokstr(tok, lit), []string{"TO"}, pos)
+	}
+	if stmt.To, err = p.parseUInt64(); err != nil {
+		return nil, err
+	}
+
+	return stmt, nil
+}
+
+// parseMoveShardStatement parses a string and returns a MoveShardStatement.
+// This function assumes the MOVE token has already been consumed.
+func (p *Parser) parseMoveShardStatement() (*MoveShardStatement, error) {
+	stmt := &MoveShardStatement{}
+
+	// Parse the shard id.
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != SHARD {
+		return nil, newParseError(tokstr(tok, lit), []string{"SHARD"}, pos)
+	}
+	id, err := p.parseUInt64()
+	if err != nil {
+		return nil, err
+	}
+	stmt.ID = id
+
+	// Parse the source node id.
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != FROM {
+		return nil, newParseError(tokstr(tok, lit), []string{"FROM"}, pos)
+	}
+	if stmt.From, err = p.parseUInt64(); err != nil {
+		return nil, err
+	}
+
+	// Parse the destination node id.
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != TO {
+		return nil, newParseError(tokstr(tok, lit), []string{"TO"}, pos)
+	}
+	if stmt.To, err = p.parseUInt64(); err != nil {
+		return nil, err
+	}
+
+	return stmt, nil
+}
+
+// parseHintedHandoffStatement parses a string and returns a PURGE, PAUSE or
+// RESUME HINTED HANDOFF statement. This function assumes the PURGE, PAUSE or
+// RESUME token has already been consumed.
+func (p *Parser) parseHintedHandoffStatement(action string) (Statement, error) {
+	if err := p.parseHintedHandoff(); err != nil {
+		return nil, err
+	}
+
+	// Parse the node id.
+	id, err := p.parseUInt64()
+	if err != nil {
+		return nil, err
+	}
+
+	switch action {
+	case "purge":
+		return &PurgeHintedHandoffStatement{NodeID: id}, nil
+	case "pause":
+		return &PauseHintedHandoffStatement{NodeID: id}, nil
+	default:
+		return &ResumeHintedHandoffStatement{NodeID: id}, nil
+	}
+}
+
+// parseHintedHandoff parses the "HINTED HANDOFF" identifiers.
+func (p *Parser) parseHintedHandoff() error {
+	for _, name := range []string{"HINTED", "HANDOFF"} {
+		if tok, pos, lit := p.scanIgnoreWhitespace(); tok != IDENT || strings.ToUpper(lit) != name {
+			return newParseError(tokstr(tok, lit), []string{name}, pos)
+		}
+	}
+	return nil
+}
+
+// parseSnapshotMetaStatement parses a string and returns a SnapshotMetaStatement.
+// This function assumes the SNAPSHOT token has already been consumed.
+func (p *Parser) parseSnapshotMetaStatement() (*SnapshotMetaStatement, error) {
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != IDENT || strings.ToUpper(lit) != "META" {
+		return nil, newParseError(tokstr(tok, lit), []string{"META"}, pos)
+	}
+	return &SnapshotMetaStatement{}, nil
+}
+
+// parseDropServerStatement parses a string and returns a DropServerStatement.
+// This function assumes the "DROP SERVER" tokens have already been consumed.
+func (p *Parser) parseDropServerStatement() (*DropServerStatement, error) {
+	stmt := &DropServerStatement{}
+
+	// Parse the node id.
+	id, err := p.parseUInt64()
+	if err != nil {
+		return nil, err
+	}
+	stmt.NodeID = id
+
+	// Parse optional DECOMMISSION token.
+	if tok, _, _ := p.scanIgnoreWhitespace(); tok == DECOMMISSION {
+		stmt.Decommission = true
+	} else {
+		p.unscan()
+	}
+
+	return stmt, nil
+}
+
 // parseShowStatsStatement parses a string and returns a ShowStatsStatement.
 // This function assumes the "SHOW STATS" tokens have already been consumed.
 func (p *Parser) parseShowStatsStatement() (*ShowStatsStatement, error) {
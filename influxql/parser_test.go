@@ -1221,6 +1221,18 @@ func TestParser_ParseStatement(t *testing.T) {
 			stmt: &influxql.DropUserStatement{Name: "jdoe"},
 		},
 
+		// DROP SERVER statement
+		{
+			s:    `DROP SERVER 2`,
+			stmt: &influxql.DropServerStatement{NodeID: 2},
+		},
+
+		// DROP SERVER DECOMMISSION statement
+		{
+			s:    `DROP SERVER 2 DECOMMISSION`,
+			stmt: &influxql.DropServerStatement{NodeID: 2, Decommission: true},
+		},
+
 		// GRANT READ
 		{
 			s: `GRANT READ ON testdb TO jdoe`,
@@ -1367,6 +1379,42 @@ func TestParser_ParseStatement(t *testing.T) {
 			},
 		},
 
+		// CREATE RETENTION POLICY ... DOWNSAMPLE
+		{
+			s: `CREATE RETENTION POLICY policy1 ON testdb DURATION 7d REPLICATION 1 DEFAULT DOWNSAMPLE TO "1y" EVERY 5m`,
+			stmt: &influxql.CreateRetentionPolicyStatement{
+				Name:               "policy1",
+				Database:           "testdb",
+				Duration:           7 * 24 * time.Hour,
+				Replication:        1,
+				Default:            true,
+				DownsampleTo:       "1y",
+				DownsampleInterval: 5 * time.Minute,
+			},
+		},
+
+		// CREATE RETENTION POLICY ... DUPLICATE
+		{
+			s: `CREATE RETENTION POLICY policy1 ON testdb DURATION 1h REPLICATION 2 DUPLICATE merge`,
+			stmt: &influxql.CreateRetentionPolicyStatement{
+				Name:            "policy1",
+				Database:        "testdb",
+				Duration:        time.Hour,
+				Replication:     2,
+				DuplicatePolicy: "merge",
+			},
+		},
+
+		// ALTER DATABASE
+		{
+			s:    `ALTER DATABASE testdb READONLY`,
+			stmt: &influxql.AlterDatabaseStatement{Name: "testdb", ReadOnly: true},
+		},
+		{
+			s:    `ALTER DATABASE testdb READWRITE`,
+			stmt: &influxql.AlterDatabaseStatement{Name: "testdb", ReadOnly: false},
+		},
+
 		// ALTER RETENTION POLICY
 		{
 			s:    `ALTER RETENTION POLICY policy1 ON testdb DURATION 1m REPLICATION 4 DEFAULT`,
@@ -1408,6 +1456,39 @@ func TestParser_ParseStatement(t *testing.T) {
 			stmt: newAlterRetentionPolicyStatement("default", "testdb", -1, 4, false),
 		},
 
+		// ALTER RETENTION POLICY with DOWNSAMPLE
+		{
+			s: `ALTER RETENTION POLICY policy1 ON testdb DOWNSAMPLE TO rp2 EVERY 1h REPLICATION 2`,
+			stmt: func() *influxql.AlterRetentionPolicyStatement {
+				stmt := newAlterRetentionPolicyStatement("policy1", "testdb", -1, 2, false)
+				name, d := "rp2", time.Hour
+				stmt.DownsampleTo, stmt.DownsampleInterval = &name, &d
+				return stmt
+			}(),
+		},
+
+		// ALTER RETENTION POLICY with NO DOWNSAMPLE
+		{
+			s: `ALTER RETENTION POLICY policy1 ON testdb NO DOWNSAMPLE`,
+			stmt: func() *influxql.AlterRetentionPolicyStatement {
+				stmt := newAlterRetentionPolicyStatement("policy1", "testdb", -1, -1, false)
+				name := ""
+				stmt.DownsampleTo = &name
+				return stmt
+			}(),
+		},
+
+		// ALTER RETENTION POLICY with DUPLICATE
+		{
+			s: `ALTER RETENTION POLICY policy1 ON testdb DUPLICATE FIRST DEFAULT`,
+			stmt: func() *influxql.AlterRetentionPolicyStatement {
+				stmt := newAlterRetentionPolicyStatement("policy1", "testdb", -1, -1, true)
+				policy := "first"
+				stmt.DuplicatePolicy = &policy
+				return stmt
+			}(),
+		},
+
 		// SHOW STATS
 		{
 			s: `SHOW STATS`,
@@ -1428,6 +1509,56 @@ func TestParser_ParseStatement(t *testing.T) {
 			stmt: &influxql.ShowShardsStatement{},
 		},
 
+		// SHOW SHARD MOVES
+		{
+			s:    `SHOW SHARD MOVES`,
+			stmt: &influxql.ShowShardMovesStatement{},
+		},
+
+		// SHOW SHARD PLAN
+		{
+			s:    `SHOW SHARD PLAN`,
+			stmt: &influxql.ShowShardPlanStatement{},
+		},
+
+		// SHOW HINTED HANDOFF
+		{
+			s:    `SHOW HINTED HANDOFF`,
+			stmt: &influxql.ShowHintedHandoffStatement{},
+		},
+
+		// PURGE, PAUSE and RESUME HINTED HANDOFF
+		{
+			s:    `PURGE HINTED HANDOFF 2`,
+			stmt: &influxql.PurgeHintedHandoffStatement{NodeID: 2},
+		},
+		{
+			s:    `pause hinted handoff 2`,
+			stmt: &influxql.PauseHintedHandoffStatement{NodeID: 2},
+		},
+		{
+			s:    `RESUME HINTED HANDOFF 2`,
+			stmt: &influxql.ResumeHintedHandoffStatement{NodeID: 2},
+		},
+
+		// SNAPSHOT META
+		{
+			s:    `SNAPSHOT META`,
+			stmt: &influxql.SnapshotMetaStatement{},
+		},
+
+		// COPY SHARD
+		{
+			s:    `COPY SHARD 10 TO 2`,
+			stmt: &influxql.CopyShardStatement{ID: 10, To: 2},
+		},
+
+		// MOVE SHARD
+		{
+			s:    `MOVE SHARD 10 FROM 1 TO 2`,
+			stmt: &influxql.MoveShardStatement{ID: 10, From: 1, To: 2},
+		},
+
 		// SHOW DIAGNOSTICS
 		{
 			s:    `SHOW DIAGNOSTICS`,
@@ -1441,10 +1572,10 @@ func TestParser_ParseStatement(t *testing.T) {
 		},
 
 		// Errors
-		{s: ``, err: `found EOF, expected SELECT, DELETE, SHOW, CREATE, DROP, GRANT, REVOKE, ALTER, SET at line 1, char 1`},
+		{s: ``, err: `found EOF, expected SELECT, DELETE, SHOW, CREATE, DROP, GRANT, REVOKE, ALTER, SET, COPY, MOVE, PURGE, PAUSE, RESUME, SNAPSHOT at line 1, char 1`},
 		{s: `SELECT`, err: `found EOF, expected identifier, string, number, bool at line 1, char 8`},
 		{s: `SELECT time FROM myseries`, err: `at least 1 non-time field must be queried`},
-		{s: `blah blah`, err: `found blah, expected SELECT, DELETE, SHOW, CREATE, DROP, GRANT, REVOKE, ALTER, SET at line 1, char 1`},
+		{s: `blah blah`, err: `found blah, expected SELECT, DELETE, SHOW, CREATE, DROP, GRANT, REVOKE, ALTER, SET, COPY, MOVE, PURGE, PAUSE, RESUME, SNAPSHOT at line 1, char 1`},
 		{s: `SELECT field1 X`, err: `found X, expected FROM at line 1, char 15`},
 		{s: `SELECT field1 FROM "series" WHERE X +;`, err: `found ;, expected identifier, string, number, bool at line 1, char 38`},
 		{s: `SELECT field1 FROM myseries GROUP`, err: `found EOF, expected BY at line 1, char 35`},
@@ -1521,7 +1652,18 @@ func TestParser_ParseStatement(t *testing.T) {
 		{s: `SHOW RETENTION POLICIES`, err: `found EOF, expected ON at line 1, char 25`},
 		{s: `SHOW RETENTION POLICIES mydb`, err: `found mydb, expected ON at line 1, char 25`},
 		{s: `SHOW RETENTION POLICIES ON`, err: `found EOF, expected identifier at line 1, char 28`},
-		{s: `SHOW FOO`, err: `found FOO, expected CONTINUOUS, DATABASES, DIAGNOSTICS, FIELD, GRANTS, MEASUREMENTS, RETENTION, SERIES, SERVERS, SHARDS, STATS, TAG, USERS at line 1, char 6`},
+		{s: `SHOW FOO`, err: `found FOO, expected CONTINUOUS, DATABASES, DIAGNOSTICS, FIELD, GRANTS, HINTED, MEASUREMENTS, RETENTION, SERIES, SERVERS, SHARD, SHARDS, STATS, TAG, USERS at line 1, char 6`},
+		{s: `SHOW SHARD`, err: `found EOF, expected MOVES, PLAN at line 1, char 12`},
+		{s: `SHOW HINTED`, err: `found EOF, expected HANDOFF at line 1, char 13`},
+		{s: `PURGE HINTED HANDOFF`, err: `found EOF, expected number at line 1, char 22`},
+		{s: `PAUSE HANDOFF 2`, err: `found HANDOFF, expected HINTED at line 1, char 7`},
+		{s: `SNAPSHOT`, err: `found EOF, expected META at line 1, char 10`},
+		{s: `COPY`, err: `found EOF, expected SHARD at line 1, char 6`},
+		{s: `COPY SHARD`, err: `found EOF, expected number at line 1, char 12`},
+		{s: `COPY SHARD 10`, err: `found EOF, expected TO at line 1, char 14`},
+		{s: `COPY SHARD 10 TO`, err: `found EOF, expected number at line 1, char 18`},
+		{s: `MOVE SHARD 10 TO 2`, err: `found TO, expected FROM at line 1, char 15`},
+		{s: `MOVE SHARD 10 FROM 1`, err: `found EOF, expected TO at line 1, char 21`},
 		{s: `SHOW STATS FOR`, err: `found EOF, expected string at line 1, char 16`},
 		{s: `SHOW DIAGNOSTICS FOR`, err: `found EOF, expected string at line 1, char 22`},
 		{s: `SHOW GRANTS`, err: `found EOF, expected FOR at line 1, char 13`},
@@ -1532,7 +1674,7 @@ func TestParser_ParseStatement(t *testing.T) {
 		{s: `DROP CONTINUOUS QUERY myquery ON`, err: `found EOF, expected identifier at line 1, char 34`},
 		{s: `CREATE CONTINUOUS`, err: `found EOF, expected QUERY at line 1, char 19`},
 		{s: `CREATE CONTINUOUS QUERY`, err: `found EOF, expected identifier at line 1, char 25`},
-		{s: `DROP FOO`, err: `found FOO, expected SERIES, CONTINUOUS, MEASUREMENT at line 1, char 6`},
+		{s: `DROP FOO`, err: `found FOO, expected SERIES, CONTINUOUS, MEASUREMENT, SERVER at line 1, char 6`},
 		{s: `CREATE DATABASE`, err: `found EOF, expected identifier at line 1, char 17`},
 		{s: `CREATE DATABASE IF`, err: `found EOF, expected NOT at line 1, char 20`},
 		{s: `CREATE DATABASE IF NOT`, err: `found EOF, expected EXISTS at line 1, char 24`},
@@ -1543,6 +1685,7 @@ func TestParser_ParseStatement(t *testing.T) {
 		{s: `DROP RETENTION POLICY "1h.cpu"`, err: `found EOF, expected ON at line 1, char 31`},
 		{s: `DROP RETENTION POLICY "1h.cpu" ON`, err: `found EOF, expected identifier at line 1, char 35`},
 		{s: `DROP USER`, err: `found EOF, expected identifier at line 1, char 11`},
+		{s: `DROP SERVER`, err: `found EOF, expected number at line 1, char 13`},
 		{s: `CREATE USER testuser`, err: `found EOF, expected WITH at line 1, char 22`},
 		{s: `CREATE USER testuser WITH`, err: `found EOF, expected PASSWORD at line 1, char 27`},
 		{s: `CREATE USER testuser WITH PASSWORD`, err: `found EOF, expected string at line 1, char 36`},
@@ -1624,11 +1767,19 @@ func TestParser_ParseStatement(t *testing.T) {
 		{s: `CREATE RETENTION POLICY policy1 ON testdb DURATION 1h REPLICATION 3.14`, err: `number must be an integer at line 1, char 67`},
 		{s: `CREATE RETENTION POLICY policy1 ON testdb DURATION 1h REPLICATION 0`, err: `invalid value 0: must be 1 <= n <= 2147483647 at line 1, char 67`},
 		{s: `CREATE RETENTION POLICY policy1 ON testdb DURATION 1h REPLICATION bad`, err: `found bad, expected number at line 1, char 67`},
-		{s: `ALTER`, err: `found EOF, expected RETENTION at line 1, char 7`},
+		{s: `CREATE RETENTION POLICY policy1 ON testdb DURATION 1h REPLICATION 1 DOWNSAMPLE rp2`, err: `found rp2, expected TO at line 1, char 80`},
+		{s: `CREATE RETENTION POLICY policy1 ON testdb DURATION 1h REPLICATION 1 DOWNSAMPLE TO rp2`, err: `found EOF, expected EVERY at line 1, char 87`},
+		{s: `CREATE RETENTION POLICY policy1 ON testdb DURATION 1h REPLICATION 1 DOWNSAMPLE TO rp2 EVERY INF`, err: `found INF, expected duration at line 1, char 93`},
+		{s: `CREATE RETENTION POLICY policy1 ON testdb DURATION 1h REPLICATION 1 DUPLICATE`, err: `found EOF, expected REPLACE, MERGE, FIRST at line 1, char 79`},
+		{s: `ALTER`, err: `found EOF, expected RETENTION, DATABASE at line 1, char 7`},
 		{s: `ALTER RETENTION`, err: `found EOF, expected POLICY at line 1, char 17`},
+		{s: `ALTER DATABASE`, err: `found EOF, expected identifier at line 1, char 16`},
+		{s: `ALTER DATABASE testdb`, err: `found EOF, expected READONLY, READWRITE at line 1, char 23`},
 		{s: `ALTER RETENTION POLICY`, err: `found EOF, expected identifier at line 1, char 24`},
 		{s: `ALTER RETENTION POLICY policy1`, err: `found EOF, expected ON at line 1, char 32`}, {s: `ALTER RETENTION POLICY policy1 ON`, err: `found EOF, expected identifier at line 1, char 35`},
-		{s: `ALTER RETENTION POLICY policy1 ON testdb`, err: `found EOF, expected DURATION, RETENTION, DEFAULT at line 1, char 42`},
+		{s: `ALTER RETENTION POLICY policy1 ON testdb`, err: `found EOF, expected DURATION, RETENTION, DEFAULT, DOWNSAMPLE, DUPLICATE, NO at line 1, char 42`},
+		{s: `ALTER RETENTION POLICY policy1 ON testdb NO DUPLICATE`, err: `found DUPLICATE, expected DOWNSAMPLE at line 1, char 45`},
+		{s: `ALTER RETENTION POLICY policy1 ON testdb DUPLICATE last`, err: `found last, expected REPLACE, MERGE, FIRST at line 1, char 52`},
 		{s: `SET`, err: `found EOF, expected PASSWORD at line 1, char 5`},
 		{s: `SET PASSWORD`, err: `found EOF, expected FOR at line 1, char 14`},
 		{s: `SET PASSWORD something`, err: `found something, expected FOR at line 1, char 14`},
@@ -64,15 +64,20 @@ const (
 	BY
 	CREATE
 	CONTINUOUS
+	COPY
 	DATABASE
 	DATABASES
+	DECOMMISSION
 	DEFAULT
 	DELETE
 	DESC
 	DISTINCT
+	DOWNSAMPLE
 	DROP
+	DUPLICATE
 	DURATION
 	END
+	EVERY
 	EXISTS
 	EXPLAIN
 	FIELD
@@ -92,17 +97,22 @@ const (
 	LIMIT
 	MEASUREMENT
 	MEASUREMENTS
+	MOVE
+	MOVES
 	NOT
 	OFFSET
 	ON
 	ORDER
 	PASSWORD
+	PLAN
 	POLICY
 	POLICIES
 	PRIVILEGES
 	QUERIES
 	QUERY
 	READ
+	READONLY
+	READWRITE
 	REPLICATION
 	RETENTION
 	REVOKE
@@ -111,6 +121,7 @@ const (
 	SERVERS
 	SET
 	SHOW
+	SHARD
 	SHARDS
 	SLIMIT
 	STATS
@@ -174,15 +185,20 @@ var tokens = [...]string{
 	BY:           "BY",
 	CREATE:       "CREATE",
 	CONTINUOUS:   "CONTINUOUS",
+	COPY:         "COPY",
 	DATABASE:     "DATABASE",
 	DATABASES:    "DATABASES",
+	DECOMMISSION: "DECOMMISSION",
 	DEFAULT:      "DEFAULT",
 	DELETE:       "DELETE",
 	DESC:         "DESC",
 	DROP:         "DROP",
 	DISTINCT:     "DISTINCT",
+	DOWNSAMPLE:   "DOWNSAMPLE",
+	DUPLICATE:    "DUPLICATE",
 	DURATION:     "DURATION",
 	END:          "END",
+	EVERY:        "EVERY",
 	EXISTS:       "EXISTS",
 	EXPLAIN:      "EXPLAIN",
 	FIELD:        "FIELD",
@@ -202,17 +218,22 @@ var tokens = [...]string{
 	LIMIT:        "LIMIT",
 	MEASUREMENT:  "MEASUREMENT",
 	MEASUREMENTS: "MEASUREMENTS",
+	MOVE:         "MOVE",
+	MOVES:        "MOVES",
 	NOT:          "NOT",
 	OFFSET:       "OFFSET",
 	ON:           "ON",
 	ORDER:        "ORDER",
 	PASSWORD:     "PASSWORD",
+	PLAN:         "PLAN",
 	POLICY:       "POLICY",
 	POLICIES:     "POLICIES",
 	PRIVILEGES:   "PRIVILEGES",
 	QUERIES:      "QUERIES",
 	QUERY:        "QUERY",
 	READ:         "READ",
+	READONLY:     "READONLY",
+	READWRITE:    "READWRITE",
 	REPLICATION:  "REPLICATION",
 	RETENTION:    "RETENTION",
 	REVOKE:       "REVOKE",
@@ -221,6 +242,7 @@ var tokens = [...]string{
 	SERVERS:      "SERVERS",
 	SET:          "SET",
 	SHOW:         "SHOW",
+	SHARD:        "SHARD",
 	SHARDS:       "SHARDS",
 	SLIMIT:       "SLIMIT",
 	SOFFSET:      "SOFFSET",
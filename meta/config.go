@@ -1,8 +1,11 @@
 package meta
 
 import (
+	"crypto/tls"
+	"errors"
 	"time"
 
+	"github.com/influxdb/influxdb/tcp"
 	"github.com/influxdb/influxdb/toml"
 )
 
@@ -24,6 +27,18 @@ const (
 
 	// DefaultCommitTimeout is the default commit timeout for the store.
 	DefaultCommitTimeout = 50 * time.Millisecond
+
+	// DefaultSnapshotInterval is the default interval between checks for
+	// whether the raft log should be snapshotted.
+	DefaultSnapshotInterval = 120 * time.Second
+
+	// DefaultSnapshotThreshold is the default number of log entries written
+	// since the last snapshot before a new snapshot is taken.
+	DefaultSnapshotThreshold = 8192
+
+	// DefaultTrailingLogs is the default number of log entries kept after a
+	// snapshot so followers can catch up without a snapshot.
+	DefaultTrailingLogs = 10240
 )
 
 // Config represents the meta configuration.
@@ -38,6 +53,20 @@ type Config struct {
 	LeaderLeaseTimeout  toml.Duration `toml:"leader-lease-timeout"`
 	CommitTimeout       toml.Duration `toml:"commit-timeout"`
 	ClusterTracing      bool          `toml:"cluster-tracing"`
+
+	// Raft log compaction settings.
+	SnapshotInterval  toml.Duration `toml:"snapshot-interval"`
+	SnapshotThreshold uint64        `toml:"snapshot-threshold"`
+	TrailingLogs      uint64        `toml:"trailing-logs"`
+
+	// TLS settings for all connections on the bind address. They cover every
+	// service sharing it, not only the meta service. Without a CA certificate
+	// peers are verified against the system roots.
+	TLSEnabled       bool   `toml:"tls-enabled"`
+	TLSCertificate   string `toml:"tls-certificate"`
+	TLSPrivateKey    string `toml:"tls-private-key"`
+	TLSCACertificate string `toml:"tls-ca-certificate"`
+	TLSVerifyClient  bool   `toml:"tls-verify-client"`
 }
 
 func NewConfig() *Config {
@@ -49,5 +78,31 @@ func NewConfig() *Config {
 		HeartbeatTimeout:    toml.Duration(DefaultHeartbeatTimeout),
 		LeaderLeaseTimeout:  toml.Duration(DefaultLeaderLeaseTimeout),
 		CommitTimeout:       toml.Duration(DefaultCommitTimeout),
+		SnapshotInterval:    toml.Duration(DefaultSnapshotInterval),
+		SnapshotThreshold:   DefaultSnapshotThreshold,
+		TrailingLogs:        DefaultTrailingLogs,
+	}
+}
+
+// Validate returns an error if the config is invalid.
+func (c *Config) Validate() error {
+	if c.TLSEnabled && c.TLSCertificate == "" {
+		return errors.New("Meta.TLSCertificate must be specified when TLS is enabled")
+	}
+	if c.SnapshotInterval <= 0 {
+		return errors.New("Meta.SnapshotInterval must be greater than 0")
+	}
+	if c.SnapshotThreshold == 0 {
+		return errors.New("Meta.SnapshotThreshold must be greater than 0")
+	}
+	return nil
+}
+
+// TLSConfig returns the TLS configuration for connections between nodes.
+// Returns nil if TLS is disabled.
+func (c *Config) TLSConfig() (*tls.Config, error) {
+	if !c.TLSEnabled {
+		return nil, nil
 	}
+	return tcp.LoadTLSConfig(c.TLSCertificate, c.TLSPrivateKey, c.TLSCACertificate, c.TLSVerifyClient)
 }
@@ -17,6 +17,13 @@ election-timeout = "10s"
 heartbeat-timeout = "20s"
 leader-lease-timeout = "30h"
 commit-timeout = "40m"
+snapshot-interval = "5m"
+snapshot-threshold = 1000
+trailing-logs = 500
+tls-enabled = true
+tls-certificate = "/etc/ssl/influxdb.pem"
+tls-ca-certificate = "/etc/ssl/ca.pem"
+tls-verify-client = true
 `, &c); err != nil {
 		t.Fatal(err)
 	}
@@ -32,5 +39,31 @@ commit-timeout = "40m"
 		t.Fatalf("unexpected leader lease timeout: %v", c.LeaderLeaseTimeout)
 	} else if time.Duration(c.CommitTimeout) != 40*time.Minute {
 		t.Fatalf("unexpected commit timeout: %v", c.CommitTimeout)
+	} else if time.Duration(c.SnapshotInterval) != 5*time.Minute {
+		t.Fatalf("unexpected snapshot interval: %v", c.SnapshotInterval)
+	} else if c.SnapshotThreshold != 1000 {
+		t.Fatalf("unexpected snapshot threshold: %d", c.SnapshotThreshold)
+	} else if c.TrailingLogs != 500 {
+		t.Fatalf("unexpected trailing logs: %d", c.TrailingLogs)
+	} else if !c.TLSEnabled || c.TLSCertificate != "/etc/ssl/influxdb.pem" || c.TLSCACertificate != "/etc/ssl/ca.pem" || !c.TLSVerifyClient {
+		t.Fatalf("unexpected tls settings: %#v", c)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	c := meta.NewConfig()
+	c.TLSEnabled = true
+	if err := c.Validate(); err == nil {
+		t.Fatal("expected error when tls is enabled without a certificate")
+	}
+
+	c.TLSCertificate = "/etc/ssl/influxdb.pem"
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	c.SnapshotThreshold = 0
+	if err := c.Validate(); err == nil {
+		t.Fatal("expected error when snapshot threshold is zero")
 	}
 }
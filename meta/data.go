@@ -22,6 +22,20 @@ const (
 	MinRetentionPolicyDuration = time.Hour
 )
 
+const (
+	// DuplicatePolicyReplace keeps the last point written to a series at a
+	// timestamp. This is the default duplicate policy.
+	DuplicatePolicyReplace = "replace"
+
+	// DuplicatePolicyMerge merges the fields of points written to a series at
+	// the same timestamp. Later values win for fields present in both.
+	DuplicatePolicyMerge = "merge"
+
+	// DuplicatePolicyFirst keeps the first point written to a series at a
+	// timestamp and drops any later ones.
+	DuplicatePolicyFirst = "first"
+)
+
 // Data represents the top level collection of all metadata.
 type Data struct {
 	Term      uint64 // associated raft term
@@ -84,6 +98,28 @@ func (data *Data) DeleteNode(id uint64) error {
 	return ErrNodeNotFound
 }
 
+// SetNodeDecommissioning marks a node as being decommissioned. New shards
+// aren't assigned to a decommissioning node.
+func (data *Data) SetNodeDecommissioning(id uint64, decommissioning bool) error {
+	ni := data.Node(id)
+	if ni == nil {
+		return ErrNodeNotFound
+	}
+	ni.Decommissioning = decommissioning
+	return nil
+}
+
+// assignableNodes returns the nodes that new shards can be assigned to.
+func (data *Data) assignableNodes() []NodeInfo {
+	var a []NodeInfo
+	for _, ni := range data.Nodes {
+		if !ni.Decommissioning {
+			a = append(a, ni)
+		}
+	}
+	return a
+}
+
 // Database returns a database by name.
 func (data *Data) Database(name string) *DatabaseInfo {
 	for i := range data.Databases {
@@ -153,12 +189,26 @@ func (data *Data) CreateRetentionPolicy(database string, rpi *RetentionPolicyInf
 		return ErrRetentionPolicyExists
 	}
 
+	// Validate the downsample target.
+	if rpi.DownsampleTo != "" {
+		if err := di.validateDownsample(rpi.Name, rpi.DownsampleTo, rpi.DownsampleInterval); err != nil {
+			return err
+		}
+	}
+
+	if !validDuplicatePolicy(rpi.DuplicatePolicy) {
+		return ErrInvalidDuplicatePolicy
+	}
+
 	// Append new policy.
 	di.RetentionPolicies = append(di.RetentionPolicies, RetentionPolicyInfo{
 		Name:               rpi.Name,
 		Duration:           rpi.Duration,
 		ShardGroupDuration: shardGroupDuration(rpi.Duration),
 		ReplicaN:           rpi.ReplicaN,
+		DownsampleTo:       rpi.DownsampleTo,
+		DownsampleInterval: rpi.DownsampleInterval,
+		DuplicatePolicy:    rpi.DuplicatePolicy,
 	})
 
 	return nil
@@ -177,6 +227,13 @@ func (data *Data) DropRetentionPolicy(database, name string) error {
 		return ErrRetentionPolicyDefault
 	}
 
+	// Prohibit dropping a policy that another policy downsamples into.
+	for i := range di.RetentionPolicies {
+		if di.RetentionPolicies[i].DownsampleTo == name {
+			return ErrRetentionPolicyDownsampleTarget
+		}
+	}
+
 	// Remove from list.
 	for i := range di.RetentionPolicies {
 		if di.RetentionPolicies[i].Name == name {
@@ -211,8 +268,29 @@ func (data *Data) UpdateRetentionPolicy(database, name string, rpu *RetentionPol
 		return ErrRetentionPolicyDurationTooLow
 	}
 
+	// Validate the downsample target. An empty target stops downsampling.
+	if rpu.DownsampleTo != nil && *rpu.DownsampleTo != "" {
+		var interval time.Duration
+		if rpu.DownsampleInterval != nil {
+			interval = *rpu.DownsampleInterval
+		}
+		if err := di.validateDownsample(name, *rpu.DownsampleTo, interval); err != nil {
+			return err
+		}
+	}
+
+	if rpu.DuplicatePolicy != nil && !validDuplicatePolicy(*rpu.DuplicatePolicy) {
+		return ErrInvalidDuplicatePolicy
+	}
+
 	// Update fields.
 	if rpu.Name != nil {
+		// Keep policies downsampling into this one pointed at it.
+		for i := range di.RetentionPolicies {
+			if di.RetentionPolicies[i].DownsampleTo == name {
+				di.RetentionPolicies[i].DownsampleTo = *rpu.Name
+			}
+		}
 		rpi.Name = *rpu.Name
 	}
 	if rpu.Duration != nil {
@@ -222,6 +300,16 @@ func (data *Data) UpdateRetentionPolicy(database, name string, rpu *RetentionPol
 	if rpu.ReplicaN != nil {
 		rpi.ReplicaN = *rpu.ReplicaN
 	}
+	if rpu.DownsampleTo != nil {
+		rpi.DownsampleTo = *rpu.DownsampleTo
+		rpi.DownsampleInterval = 0
+		if rpi.DownsampleTo != "" {
+			rpi.DownsampleInterval = *rpu.DownsampleInterval
+		}
+	}
+	if rpu.DuplicatePolicy != nil {
+		rpi.DuplicatePolicy = *rpu.DuplicatePolicy
+	}
 
 	return nil
 }
@@ -242,6 +330,16 @@ func (data *Data) SetDefaultRetentionPolicy(database, name string) error {
 	return nil
 }
 
+// SetDatabaseReadOnly sets whether a database rejects writes.
+func (data *Data) SetDatabaseReadOnly(name string, readOnly bool) error {
+	di := data.Database(name)
+	if di == nil {
+		return ErrDatabaseNotFound
+	}
+	di.ReadOnly = readOnly
+	return nil
+}
+
 // ShardGroup returns a list of all shard groups on a database and policy.
 func (data *Data) ShardGroups(database, policy string) ([]ShardGroupInfo, error) {
 	// Find retention policy.
@@ -296,8 +394,10 @@ func (data *Data) ShardGroupByTimestamp(database, policy string, timestamp time.
 
 // CreateShardGroup creates a shard group on a database and policy for a given timestamp.
 func (data *Data) CreateShardGroup(database, policy string, timestamp time.Time) error {
-	// Ensure there are nodes in the metadata.
-	if len(data.Nodes) == 0 {
+	// Ensure there are nodes in the metadata. Decommissioning nodes aren't
+	// assigned new shards.
+	nodes := data.assignableNodes()
+	if len(nodes) == 0 {
 		return ErrNodesRequired
 	}
 
@@ -318,14 +418,14 @@ func (data *Data) CreateShardGroup(database, policy string, timestamp time.Time)
 	replicaN := rpi.ReplicaN
 	if replicaN == 0 {
 		replicaN = 1
-	} else if replicaN > len(data.Nodes) {
-		replicaN = len(data.Nodes)
+	} else if replicaN > len(nodes) {
+		replicaN = len(nodes)
 	}
 
 	// Determine shard count by node count divided by replication factor.
 	// This will ensure nodes will get distributed across nodes evenly and
 	// replicated the correct number of times.
-	shardN := len(data.Nodes) / replicaN
+	shardN := len(nodes) / replicaN
 
 	// Create the shard group.
 	data.MaxShardGroupID++
@@ -343,11 +443,11 @@ func (data *Data) CreateShardGroup(database, policy string, timestamp time.Time)
 
 	// Assign data nodes to shards via round robin.
 	// Start from a repeatably "random" place in the node list.
-	nodeIndex := int(data.Index % uint64(len(data.Nodes)))
+	nodeIndex := int(data.Index % uint64(len(nodes)))
 	for i := range sgi.Shards {
 		si := &sgi.Shards[i]
 		for j := 0; j < replicaN; j++ {
-			nodeID := data.Nodes[nodeIndex%len(data.Nodes)].ID
+			nodeID := nodes[nodeIndex%len(nodes)].ID
 			si.Owners = append(si.Owners, ShardOwner{NodeID: nodeID})
 			nodeIndex++
 		}
@@ -383,6 +483,63 @@ func (data *Data) DeleteShardGroup(database, policy string, id uint64) error {
 	return ErrShardGroupNotFound
 }
 
+// AddShardOwner adds a node to the owners of a shard.
+func (data *Data) AddShardOwner(shardID, nodeID uint64) error {
+	si := data.shard(shardID)
+	if si == nil {
+		return ErrShardNotFound
+	} else if data.Node(nodeID) == nil {
+		return ErrNodeNotFound
+	} else if si.OwnedBy(nodeID) {
+		return ErrShardOwnerExists
+	}
+
+	si.Owners = append(si.Owners, ShardOwner{NodeID: nodeID})
+	return nil
+}
+
+// RemoveShardOwner removes a node from the owners of a shard.
+// The last owner of a shard can't be removed.
+func (data *Data) RemoveShardOwner(shardID, nodeID uint64) error {
+	si := data.shard(shardID)
+	if si == nil {
+		return ErrShardNotFound
+	}
+
+	for i := range si.Owners {
+		if si.Owners[i].NodeID == nodeID {
+			if len(si.Owners) == 1 {
+				return ErrShardOwnerRequired
+			}
+			si.Owners = append(si.Owners[:i], si.Owners[i+1:]...)
+			return nil
+		}
+	}
+	return ErrShardOwnerNotFound
+}
+
+// shard returns a shard of a shard group that isn't deleted by id.
+func (data *Data) shard(id uint64) *ShardInfo {
+	for i := range data.Databases {
+		di := &data.Databases[i]
+		for j := range di.RetentionPolicies {
+			rpi := &di.RetentionPolicies[j]
+			for k := range rpi.ShardGroups {
+				sgi := &rpi.ShardGroups[k]
+				if sgi.Deleted() {
+					continue
+				}
+				for l := range sgi.Shards {
+					if sgi.Shards[l].ID == id {
+						return &sgi.Shards[l]
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
 // CreateContinuousQuery adds a named continuous query to a database.
 func (data *Data) CreateContinuousQuery(database, name, query string) error {
 	di := data.Database(database)
@@ -630,8 +787,9 @@ func (data *Data) UnmarshalBinary(buf []byte) error {
 
 // NodeInfo represents information about a single node in the cluster.
 type NodeInfo struct {
-	ID   uint64
-	Host string
+	ID              uint64
+	Host            string
+	Decommissioning bool // set while the node's shards are moved off
 }
 
 // clone returns a deep copy of ni.
@@ -642,6 +800,7 @@ func (ni NodeInfo) marshal() *internal.NodeInfo {
 	pb := &internal.NodeInfo{}
 	pb.ID = proto.Uint64(ni.ID)
 	pb.Host = proto.String(ni.Host)
+	pb.Decommissioning = proto.Bool(ni.Decommissioning)
 	return pb
 }
 
@@ -649,6 +808,7 @@ func (ni NodeInfo) marshal() *internal.NodeInfo {
 func (ni *NodeInfo) unmarshal(pb *internal.NodeInfo) {
 	ni.ID = pb.GetID()
 	ni.Host = pb.GetHost()
+	ni.Decommissioning = pb.GetDecommissioning()
 }
 
 // DatabaseInfo represents information about a database in the system.
@@ -657,6 +817,7 @@ type DatabaseInfo struct {
 	DefaultRetentionPolicy string
 	RetentionPolicies      []RetentionPolicyInfo
 	ContinuousQueries      []ContinuousQueryInfo
+	ReadOnly               bool // rejects writes if set
 }
 
 // RetentionPolicy returns a retention policy by name.
@@ -694,6 +855,28 @@ func (di DatabaseInfo) ShardInfos() []ShardInfo {
 	return infos
 }
 
+// validateDownsample returns an error if the policy name can't downsample into
+// the policy target every interval.
+func (di DatabaseInfo) validateDownsample(name, target string, interval time.Duration) error {
+	if target == name {
+		return ErrDownsampleTargetSelf
+	} else if di.RetentionPolicy(target) == nil {
+		return ErrDownsampleTargetNotFound
+	} else if interval <= 0 {
+		return ErrDownsampleIntervalRequired
+	}
+	return nil
+}
+
+// validDuplicatePolicy returns true if policy is a known duplicate policy.
+func validDuplicatePolicy(policy string) bool {
+	switch policy {
+	case "", DuplicatePolicyReplace, DuplicatePolicyMerge, DuplicatePolicyFirst:
+		return true
+	}
+	return false
+}
+
 // clone returns a deep copy of di.
 func (di DatabaseInfo) clone() DatabaseInfo {
 	other := di
@@ -721,6 +904,9 @@ func (di DatabaseInfo) marshal() *internal.DatabaseInfo {
 	pb := &internal.DatabaseInfo{}
 	pb.Name = proto.String(di.Name)
 	pb.DefaultRetentionPolicy = proto.String(di.DefaultRetentionPolicy)
+	if di.ReadOnly {
+		pb.ReadOnly = proto.Bool(true)
+	}
 
 	pb.RetentionPolicies = make([]*internal.RetentionPolicyInfo, len(di.RetentionPolicies))
 	for i := range di.RetentionPolicies {
@@ -738,6 +924,7 @@ func (di DatabaseInfo) marshal() *internal.DatabaseInfo {
 func (di *DatabaseInfo) unmarshal(pb *internal.DatabaseInfo) {
 	di.Name = pb.GetName()
 	di.DefaultRetentionPolicy = pb.GetDefaultRetentionPolicy()
+	di.ReadOnly = pb.GetReadOnly()
 
 	if len(pb.GetRetentionPolicies()) > 0 {
 		di.RetentionPolicies = make([]RetentionPolicyInfo, len(pb.GetRetentionPolicies()))
@@ -761,6 +948,15 @@ type RetentionPolicyInfo struct {
 	Duration           time.Duration
 	ShardGroupDuration time.Duration
 	ShardGroups        []ShardGroupInfo
+
+	// DownsampleTo is the name of the policy that expired shard groups are
+	// aggregated into every DownsampleInterval before they're deleted.
+	DownsampleTo       string
+	DownsampleInterval time.Duration
+
+	// DuplicatePolicy determines which point is kept when points are written
+	// to a series at the same timestamp. Empty means DuplicatePolicyReplace.
+	DuplicatePolicy string
 }
 
 // NewRetentionPolicyInfo returns a new instance of RetentionPolicyInfo with defaults set.
@@ -816,6 +1012,14 @@ func (rpi *RetentionPolicyInfo) marshal() *internal.RetentionPolicyInfo {
 		ShardGroupDuration: proto.Int64(int64(rpi.ShardGroupDuration)),
 	}
 
+	if rpi.DownsampleTo != "" {
+		pb.DownsampleTo = proto.String(rpi.DownsampleTo)
+		pb.DownsampleInterval = proto.Int64(int64(rpi.DownsampleInterval))
+	}
+	if rpi.DuplicatePolicy != "" {
+		pb.DuplicatePolicy = proto.String(rpi.DuplicatePolicy)
+	}
+
 	pb.ShardGroups = make([]*internal.ShardGroupInfo, len(rpi.ShardGroups))
 	for i, sgi := range rpi.ShardGroups {
 		pb.ShardGroups[i] = sgi.marshal()
@@ -830,6 +1034,9 @@ func (rpi *RetentionPolicyInfo) unmarshal(pb *internal.RetentionPolicyInfo) {
 	rpi.ReplicaN = int(pb.GetReplicaN())
 	rpi.Duration = time.Duration(pb.GetDuration())
 	rpi.ShardGroupDuration = time.Duration(pb.GetShardGroupDuration())
+	rpi.DownsampleTo = pb.GetDownsampleTo()
+	rpi.DownsampleInterval = time.Duration(pb.GetDownsampleInterval())
+	rpi.DuplicatePolicy = pb.GetDuplicatePolicy()
 
 	if len(pb.GetShardGroups()) > 0 {
 		rpi.ShardGroups = make([]ShardGroupInfo, len(pb.GetShardGroups()))
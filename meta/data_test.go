@@ -187,6 +187,81 @@ func TestData_UpdateRetentionPolicy(t *testing.T) {
 	}
 }
 
+// Ensure a retention policy's downsample target is validated.
+func TestData_CreateRetentionPolicy_Downsample(t *testing.T) {
+	var data meta.Data
+	if err := data.CreateDatabase("db0"); err != nil {
+		t.Fatal(err)
+	} else if err = data.CreateRetentionPolicy("db0", &meta.RetentionPolicyInfo{Name: "1y", ReplicaN: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tt := range []struct {
+		rpi *meta.RetentionPolicyInfo
+		err error
+	}{
+		{rpi: &meta.RetentionPolicyInfo{Name: "7d", ReplicaN: 1, DownsampleTo: "none", DownsampleInterval: time.Minute}, err: meta.ErrDownsampleTargetNotFound},
+		{rpi: &meta.RetentionPolicyInfo{Name: "7d", ReplicaN: 1, DownsampleTo: "7d", DownsampleInterval: time.Minute}, err: meta.ErrDownsampleTargetSelf},
+		{rpi: &meta.RetentionPolicyInfo{Name: "7d", ReplicaN: 1, DownsampleTo: "1y"}, err: meta.ErrDownsampleIntervalRequired},
+		{rpi: &meta.RetentionPolicyInfo{Name: "7d", ReplicaN: 1, DownsampleTo: "1y", DownsampleInterval: time.Minute}},
+	} {
+		if err := data.CreateRetentionPolicy("db0", tt.rpi); err != tt.err {
+			t.Fatalf("%d. unexpected error: %v", i, err)
+		}
+	}
+
+	// The target can't be dropped while it's used.
+	if err := data.DropRetentionPolicy("db0", "1y"); err != meta.ErrRetentionPolicyDownsampleTarget {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Renaming the target updates the policies that downsample into it.
+	var rpu meta.RetentionPolicyUpdate
+	rpu.SetName("2y")
+	if err := data.UpdateRetentionPolicy("db0", "1y", &rpu); err != nil {
+		t.Fatal(err)
+	} else if rpi, _ := data.RetentionPolicy("db0", "7d"); rpi.DownsampleTo != "2y" {
+		t.Fatalf("unexpected downsample target: %s", rpi.DownsampleTo)
+	}
+
+	// Downsampling can be stopped.
+	rpu = meta.RetentionPolicyUpdate{}
+	rpu.SetDownsample("", 0)
+	if err := data.UpdateRetentionPolicy("db0", "7d", &rpu); err != nil {
+		t.Fatal(err)
+	} else if rpi, _ := data.RetentionPolicy("db0", "7d"); rpi.DownsampleTo != "" || rpi.DownsampleInterval != 0 {
+		t.Fatalf("unexpected downsample: %s every %s", rpi.DownsampleTo, rpi.DownsampleInterval)
+	}
+}
+
+// Ensure a retention policy's duplicate policy is validated.
+func TestData_CreateRetentionPolicy_DuplicatePolicy(t *testing.T) {
+	var data meta.Data
+	if err := data.CreateDatabase("db0"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := data.CreateRetentionPolicy("db0", &meta.RetentionPolicyInfo{Name: "rp0", ReplicaN: 1, DuplicatePolicy: "last"}); err != meta.ErrInvalidDuplicatePolicy {
+		t.Fatalf("unexpected error: %v", err)
+	} else if err := data.CreateRetentionPolicy("db0", &meta.RetentionPolicyInfo{Name: "rp0", ReplicaN: 1, DuplicatePolicy: meta.DuplicatePolicyMerge}); err != nil {
+		t.Fatal(err)
+	} else if rpi, _ := data.RetentionPolicy("db0", "rp0"); rpi.DuplicatePolicy != meta.DuplicatePolicyMerge {
+		t.Fatalf("unexpected duplicate policy: %s", rpi.DuplicatePolicy)
+	}
+
+	var rpu meta.RetentionPolicyUpdate
+	rpu.SetDuplicatePolicy("last")
+	if err := data.UpdateRetentionPolicy("db0", "rp0", &rpu); err != meta.ErrInvalidDuplicatePolicy {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rpu.SetDuplicatePolicy(meta.DuplicatePolicyFirst)
+	if err := data.UpdateRetentionPolicy("db0", "rp0", &rpu); err != nil {
+		t.Fatal(err)
+	} else if rpi, _ := data.RetentionPolicy("db0", "rp0"); rpi.DuplicatePolicy != meta.DuplicatePolicyFirst {
+		t.Fatalf("unexpected duplicate policy: %s", rpi.DuplicatePolicy)
+	}
+}
+
 // Ensure a retention policy can be removed.
 func TestData_DropRetentionPolicy(t *testing.T) {
 	var data meta.Data
@@ -277,6 +352,34 @@ func TestData_SetDefaultRetentionPolicy(t *testing.T) {
 	}
 }
 
+// Ensure that a database can be marked read-only.
+func TestData_SetDatabaseReadOnly(t *testing.T) {
+	var data meta.Data
+	if err := data.CreateDatabase("db0"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := data.SetDatabaseReadOnly("db0", true); err != nil {
+		t.Fatal(err)
+	} else if !data.Database("db0").ReadOnly {
+		t.Fatal("expected database to be read-only")
+	}
+
+	if err := data.SetDatabaseReadOnly("db0", false); err != nil {
+		t.Fatal(err)
+	} else if data.Database("db0").ReadOnly {
+		t.Fatal("expected database to be writable")
+	}
+}
+
+// Ensure that marking a non-existent database read-only returns an error.
+func TestData_SetDatabaseReadOnly_ErrDatabaseNotFound(t *testing.T) {
+	var data meta.Data
+	if err := data.SetDatabaseReadOnly("db0", true); err != meta.ErrDatabaseNotFound {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
 // Ensure that a shard group can be created on a database for a given timestamp.
 func TestData_CreateShardGroup(t *testing.T) {
 	var data meta.Data
@@ -317,6 +420,48 @@ func TestData_CreateShardGroup(t *testing.T) {
 	}
 }
 
+// Ensure new shards aren't assigned to decommissioning nodes.
+func TestData_CreateShardGroup_Decommissioning(t *testing.T) {
+	var data meta.Data
+	if err := data.CreateNode("node0"); err != nil {
+		t.Fatal(err)
+	} else if err = data.CreateNode("node1"); err != nil {
+		t.Fatal(err)
+	} else if err = data.CreateDatabase("db0"); err != nil {
+		t.Fatal(err)
+	} else if err = data.CreateRetentionPolicy("db0", &meta.RetentionPolicyInfo{Name: "rp0", ReplicaN: 2, Duration: 1 * time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := data.SetNodeDecommissioning(1, true); err != nil {
+		t.Fatal(err)
+	} else if !data.Node(1).Decommissioning {
+		t.Fatal("expected node to be decommissioning")
+	}
+
+	// Create shard group.
+	if err := data.CreateShardGroup("db0", "rp0", time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify the shard is only owned by the remaining node.
+	sgi, _ := data.ShardGroupByTimestamp("db0", "rp0", time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC))
+	if exp := []meta.ShardOwner{{NodeID: 2}}; !reflect.DeepEqual(sgi.Shards[0].Owners, exp) {
+		t.Fatalf("unexpected owners: %#v", sgi.Shards[0].Owners)
+	}
+
+	// Shard groups can't be created if every node is decommissioning.
+	if err := data.SetNodeDecommissioning(2, true); err != nil {
+		t.Fatal(err)
+	} else if err := data.CreateShardGroup("db0", "rp0", time.Date(2000, time.January, 2, 0, 0, 0, 0, time.UTC)); err != meta.ErrNodesRequired {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := data.SetNodeDecommissioning(3, true); err != meta.ErrNodeNotFound {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
 // Ensure that a shard group is correctly detected as expired.
 func TestData_ShardGroupExpiredDeleted(t *testing.T) {
 	var data meta.Data
@@ -436,6 +581,58 @@ func TestData_DeleteShardGroup(t *testing.T) {
 	}
 }
 
+// Ensure a node can be added to and removed from the owners of a shard.
+func TestData_AddRemoveShardOwner(t *testing.T) {
+	var data meta.Data
+	if err := data.CreateNode("node0"); err != nil {
+		t.Fatal(err)
+	} else if err := data.CreateNode("node1"); err != nil {
+		t.Fatal(err)
+	} else if err := data.CreateDatabase("db0"); err != nil {
+		t.Fatal(err)
+	} else if err = data.CreateRetentionPolicy("db0", &meta.RetentionPolicyInfo{Name: "rp0", ReplicaN: 1}); err != nil {
+		t.Fatal(err)
+	} else if err := data.CreateShardGroup("db0", "rp0", time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	sh := &data.Databases[0].RetentionPolicies[0].ShardGroups[0].Shards[0]
+	owner := sh.Owners[0].NodeID
+	other := 3 - owner
+
+	// Add the other node as an owner.
+	if err := data.AddShardOwner(sh.ID, other); err != nil {
+		t.Fatal(err)
+	} else if !sh.OwnedBy(owner) || !sh.OwnedBy(other) {
+		t.Fatalf("unexpected owners: %v", sh.Owners)
+	} else if err := data.AddShardOwner(sh.ID, other); err != meta.ErrShardOwnerExists {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Remove the original owner.
+	if err := data.RemoveShardOwner(sh.ID, owner); err != nil {
+		t.Fatal(err)
+	} else if sh.OwnedBy(owner) || !sh.OwnedBy(other) {
+		t.Fatalf("unexpected owners: %v", sh.Owners)
+	} else if err := data.RemoveShardOwner(sh.ID, owner); err != meta.ErrShardOwnerNotFound {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The last owner can't be removed.
+	if err := data.RemoveShardOwner(sh.ID, other); err != meta.ErrShardOwnerRequired {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+// Ensure adding an owner to a shard that doesn't exist returns an error.
+func TestData_AddShardOwner_ErrShardNotFound(t *testing.T) {
+	var data meta.Data
+	if err := data.CreateNode("node0"); err != nil {
+		t.Fatal(err)
+	} else if err := data.AddShardOwner(100, 1); err != meta.ErrShardNotFound {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
 // Ensure a continuous query can be created.
 func TestData_CreateContinuousQuery(t *testing.T) {
 	var data meta.Data
@@ -636,6 +833,7 @@ func TestData_MarshalBinary(t *testing.T) {
 			{
 				Name: "db0",
 				DefaultRetentionPolicy: "default",
+				ReadOnly:               true,
 				RetentionPolicies: []meta.RetentionPolicyInfo{
 					{
 						Name:               "rp0",
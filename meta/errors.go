@@ -26,6 +26,10 @@ var (
 	// ErrNodesRequired is returned when at least one node is required for an operation.
 	// This occurs when creating a shard group.
 	ErrNodesRequired = newError("at least one node required")
+
+	// ErrNodeDecommissioning is returned when moving a shard to a node that
+	// is being decommissioned.
+	ErrNodeDecommissioning = newError("node is being decommissioned")
 )
 
 var (
@@ -65,6 +69,25 @@ var (
 	// ErrReplicationFactorTooLow is returned when the replication factor is not in an
 	// acceptable range.
 	ErrReplicationFactorTooLow = newError("replication factor must be greater than 0")
+
+	// ErrDownsampleTargetNotFound is returned when a policy downsamples into a
+	// policy that doesn't exist.
+	ErrDownsampleTargetNotFound = newError("downsample target retention policy not found")
+
+	// ErrDownsampleTargetSelf is returned when a policy downsamples into itself.
+	ErrDownsampleTargetSelf = newError("retention policy cannot downsample into itself")
+
+	// ErrDownsampleIntervalRequired is returned when a policy downsamples without
+	// a positive interval.
+	ErrDownsampleIntervalRequired = newError("downsample interval must be greater than 0")
+
+	// ErrRetentionPolicyDownsampleTarget is returned when dropping a policy that
+	// another policy downsamples into.
+	ErrRetentionPolicyDownsampleTarget = newError("retention policy is a downsample target")
+
+	// ErrInvalidDuplicatePolicy is returned when a policy sets an unknown
+	// duplicate policy.
+	ErrInvalidDuplicatePolicy = newError("duplicate policy must be replace, merge, or first")
 )
 
 var (
@@ -75,6 +98,20 @@ var (
 	ErrShardGroupNotFound = newError("shard group not found")
 )
 
+var (
+	// ErrShardNotFound is returned when mutating a shard that doesn't exist.
+	ErrShardNotFound = newError("shard not found")
+
+	// ErrShardOwnerExists is returned when adding a node that already owns a shard.
+	ErrShardOwnerExists = newError("shard owner already exists")
+
+	// ErrShardOwnerNotFound is returned when removing a node that doesn't own a shard.
+	ErrShardOwnerNotFound = newError("shard owner not found")
+
+	// ErrShardOwnerRequired is returned when removing the last owner of a shard.
+	ErrShardOwnerRequired = newError("shard requires at least one owner")
+)
+
 var (
 	// ErrContinuousQueryExists is returned when creating an already existing continuous query.
 	ErrContinuousQueryExists = newError("continuous query already exists")
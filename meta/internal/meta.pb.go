@@ -39,6 +39,10 @@ It has these top-level messages:
 	SetDataCommand
 	SetAdminPrivilegeCommand
 	UpdateNodeCommand
+	SetDatabaseReadOnlyCommand
+	AddShardOwnerCommand
+	RemoveShardOwnerCommand
+	SetNodeDecommissioningCommand
 	Response
 	ResponseHeader
 	ErrorResponse
@@ -46,6 +50,8 @@ It has these top-level messages:
 	FetchDataResponse
 	JoinRequest
 	JoinResponse
+	RemoveNodeRequest
+	RemoveNodeResponse
 */
 package internal
 
@@ -59,20 +65,23 @@ var _ = math.Inf
 type RPCType int32
 
 const (
-	RPCType_Error     RPCType = 1
-	RPCType_FetchData RPCType = 2
-	RPCType_Join      RPCType = 3
+	RPCType_Error      RPCType = 1
+	RPCType_FetchData  RPCType = 2
+	RPCType_Join       RPCType = 3
+	RPCType_RemoveNode RPCType = 4
 )
 
 var RPCType_name = map[int32]string{
 	1: "Error",
 	2: "FetchData",
 	3: "Join",
+	4: "RemoveNode",
 }
 var RPCType_value = map[string]int32{
-	"Error":     1,
-	"FetchData": 2,
-	"Join":      3,
+	"Error":      1,
+	"FetchData":  2,
+	"Join":       3,
+	"RemoveNode": 4,
 }
 
 func (x RPCType) Enum() *RPCType {
@@ -114,6 +123,10 @@ const (
 	Command_SetDataCommand                   Command_Type = 17
 	Command_SetAdminPrivilegeCommand         Command_Type = 18
 	Command_UpdateNodeCommand                Command_Type = 19
+	Command_SetDatabaseReadOnlyCommand       Command_Type = 20
+	Command_AddShardOwnerCommand             Command_Type = 21
+	Command_RemoveShardOwnerCommand          Command_Type = 22
+	Command_SetNodeDecommissioningCommand    Command_Type = 23
 )
 
 var Command_Type_name = map[int32]string{
@@ -136,6 +149,10 @@ var Command_Type_name = map[int32]string{
 	17: "SetDataCommand",
 	18: "SetAdminPrivilegeCommand",
 	19: "UpdateNodeCommand",
+	20: "SetDatabaseReadOnlyCommand",
+	21: "AddShardOwnerCommand",
+	22: "RemoveShardOwnerCommand",
+	23: "SetNodeDecommissioningCommand",
 }
 var Command_Type_value = map[string]int32{
 	"CreateNodeCommand":                1,
@@ -157,6 +174,10 @@ var Command_Type_value = map[string]int32{
 	"SetDataCommand":                   17,
 	"SetAdminPrivilegeCommand":         18,
 	"UpdateNodeCommand":                19,
+	"SetDatabaseReadOnlyCommand":       20,
+	"AddShardOwnerCommand":             21,
+	"RemoveShardOwnerCommand":          22,
+	"SetNodeDecommissioningCommand":    23,
 }
 
 func (x Command_Type) Enum() *Command_Type {
@@ -259,6 +280,7 @@ func (m *Data) GetMaxShardID() uint64 {
 type NodeInfo struct {
 	ID               *uint64 `protobuf:"varint,1,req" json:"ID,omitempty"`
 	Host             *string `protobuf:"bytes,2,req" json:"Host,omitempty"`
+	Decommissioning  *bool   `protobuf:"varint,3,opt" json:"Decommissioning,omitempty"`
 	XXX_unrecognized []byte  `json:"-"`
 }
 
@@ -280,11 +302,19 @@ func (m *NodeInfo) GetHost() string {
 	return ""
 }
 
+func (m *NodeInfo) GetDecommissioning() bool {
+	if m != nil && m.Decommissioning != nil {
+		return *m.Decommissioning
+	}
+	return false
+}
+
 type DatabaseInfo struct {
 	Name                   *string                `protobuf:"bytes,1,req" json:"Name,omitempty"`
 	DefaultRetentionPolicy *string                `protobuf:"bytes,2,req" json:"DefaultRetentionPolicy,omitempty"`
 	RetentionPolicies      []*RetentionPolicyInfo `protobuf:"bytes,3,rep" json:"RetentionPolicies,omitempty"`
 	ContinuousQueries      []*ContinuousQueryInfo `protobuf:"bytes,4,rep" json:"ContinuousQueries,omitempty"`
+	ReadOnly               *bool                  `protobuf:"varint,5,opt" json:"ReadOnly,omitempty"`
 	XXX_unrecognized       []byte                 `json:"-"`
 }
 
@@ -320,12 +350,22 @@ func (m *DatabaseInfo) GetContinuousQueries() []*ContinuousQueryInfo {
 	return nil
 }
 
+func (m *DatabaseInfo) GetReadOnly() bool {
+	if m != nil && m.ReadOnly != nil {
+		return *m.ReadOnly
+	}
+	return false
+}
+
 type RetentionPolicyInfo struct {
 	Name               *string           `protobuf:"bytes,1,req" json:"Name,omitempty"`
 	Duration           *int64            `protobuf:"varint,2,req" json:"Duration,omitempty"`
 	ShardGroupDuration *int64            `protobuf:"varint,3,req" json:"ShardGroupDuration,omitempty"`
 	ReplicaN           *uint32           `protobuf:"varint,4,req" json:"ReplicaN,omitempty"`
 	ShardGroups        []*ShardGroupInfo `protobuf:"bytes,5,rep" json:"ShardGroups,omitempty"`
+	DownsampleTo       *string           `protobuf:"bytes,6,opt" json:"DownsampleTo,omitempty"`
+	DownsampleInterval *int64            `protobuf:"varint,7,opt" json:"DownsampleInterval,omitempty"`
+	DuplicatePolicy    *string           `protobuf:"bytes,8,opt" json:"DuplicatePolicy,omitempty"`
 	XXX_unrecognized   []byte            `json:"-"`
 }
 
@@ -368,6 +408,27 @@ func (m *RetentionPolicyInfo) GetShardGroups() []*ShardGroupInfo {
 	return nil
 }
 
+func (m *RetentionPolicyInfo) GetDownsampleTo() string {
+	if m != nil && m.DownsampleTo != nil {
+		return *m.DownsampleTo
+	}
+	return ""
+}
+
+func (m *RetentionPolicyInfo) GetDownsampleInterval() int64 {
+	if m != nil && m.DownsampleInterval != nil {
+		return *m.DownsampleInterval
+	}
+	return 0
+}
+
+func (m *RetentionPolicyInfo) GetDuplicatePolicy() string {
+	if m != nil && m.DuplicatePolicy != nil {
+		return *m.DuplicatePolicy
+	}
+	return ""
+}
+
 type ShardGroupInfo struct {
 	ID               *uint64      `protobuf:"varint,1,req" json:"ID,omitempty"`
 	StartTime        *int64       `protobuf:"varint,2,req" json:"StartTime,omitempty"`
@@ -784,12 +845,15 @@ var E_SetDefaultRetentionPolicyCommand_Command = &proto.ExtensionDesc{
 }
 
 type UpdateRetentionPolicyCommand struct {
-	Database         *string `protobuf:"bytes,1,req" json:"Database,omitempty"`
-	Name             *string `protobuf:"bytes,2,req" json:"Name,omitempty"`
-	NewName          *string `protobuf:"bytes,3,opt" json:"NewName,omitempty"`
-	Duration         *int64  `protobuf:"varint,4,opt" json:"Duration,omitempty"`
-	ReplicaN         *uint32 `protobuf:"varint,5,opt" json:"ReplicaN,omitempty"`
-	XXX_unrecognized []byte  `json:"-"`
+	Database           *string `protobuf:"bytes,1,req" json:"Database,omitempty"`
+	Name               *string `protobuf:"bytes,2,req" json:"Name,omitempty"`
+	NewName            *string `protobuf:"bytes,3,opt" json:"NewName,omitempty"`
+	Duration           *int64  `protobuf:"varint,4,opt" json:"Duration,omitempty"`
+	ReplicaN           *uint32 `protobuf:"varint,5,opt" json:"ReplicaN,omitempty"`
+	DownsampleTo       *string `protobuf:"bytes,6,opt" json:"DownsampleTo,omitempty"`
+	DownsampleInterval *int64  `protobuf:"varint,7,opt" json:"DownsampleInterval,omitempty"`
+	DuplicatePolicy    *string `protobuf:"bytes,8,opt" json:"DuplicatePolicy,omitempty"`
+	XXX_unrecognized   []byte  `json:"-"`
 }
 
 func (m *UpdateRetentionPolicyCommand) Reset()         { *m = UpdateRetentionPolicyCommand{} }
@@ -831,6 +895,27 @@ func (m *UpdateRetentionPolicyCommand) GetReplicaN() uint32 {
 	return 0
 }
 
+func (m *UpdateRetentionPolicyCommand) GetDownsampleTo() string {
+	if m != nil && m.DownsampleTo != nil {
+		return *m.DownsampleTo
+	}
+	return ""
+}
+
+func (m *UpdateRetentionPolicyCommand) GetDownsampleInterval() int64 {
+	if m != nil && m.DownsampleInterval != nil {
+		return *m.DownsampleInterval
+	}
+	return 0
+}
+
+func (m *UpdateRetentionPolicyCommand) GetDuplicatePolicy() string {
+	if m != nil && m.DuplicatePolicy != nil {
+		return *m.DuplicatePolicy
+	}
+	return ""
+}
+
 var E_UpdateRetentionPolicyCommand_Command = &proto.ExtensionDesc{
 	ExtendedType:  (*Command)(nil),
 	ExtensionType: (*UpdateRetentionPolicyCommand)(nil),
@@ -1215,6 +1300,134 @@ var E_UpdateNodeCommand_Command = &proto.ExtensionDesc{
 	Tag:           "bytes,119,opt,name=command",
 }
 
+type SetDatabaseReadOnlyCommand struct {
+	Database         *string `protobuf:"bytes,1,req" json:"Database,omitempty"`
+	ReadOnly         *bool   `protobuf:"varint,2,req" json:"ReadOnly,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *SetDatabaseReadOnlyCommand) Reset()         { *m = SetDatabaseReadOnlyCommand{} }
+func (m *SetDatabaseReadOnlyCommand) String() string { return proto.CompactTextString(m) }
+func (*SetDatabaseReadOnlyCommand) ProtoMessage()    {}
+
+func (m *SetDatabaseReadOnlyCommand) GetDatabase() string {
+	if m != nil && m.Database != nil {
+		return *m.Database
+	}
+	return ""
+}
+
+func (m *SetDatabaseReadOnlyCommand) GetReadOnly() bool {
+	if m != nil && m.ReadOnly != nil {
+		return *m.ReadOnly
+	}
+	return false
+}
+
+var E_SetDatabaseReadOnlyCommand_Command = &proto.ExtensionDesc{
+	ExtendedType:  (*Command)(nil),
+	ExtensionType: (*SetDatabaseReadOnlyCommand)(nil),
+	Field:         120,
+	Name:          "internal.SetDatabaseReadOnlyCommand.command",
+	Tag:           "bytes,120,opt,name=command",
+}
+
+type AddShardOwnerCommand struct {
+	ShardID          *uint64 `protobuf:"varint,1,req" json:"ShardID,omitempty"`
+	NodeID           *uint64 `protobuf:"varint,2,req" json:"NodeID,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *AddShardOwnerCommand) Reset()         { *m = AddShardOwnerCommand{} }
+func (m *AddShardOwnerCommand) String() string { return proto.CompactTextString(m) }
+func (*AddShardOwnerCommand) ProtoMessage()    {}
+
+func (m *AddShardOwnerCommand) GetShardID() uint64 {
+	if m != nil && m.ShardID != nil {
+		return *m.ShardID
+	}
+	return 0
+}
+
+func (m *AddShardOwnerCommand) GetNodeID() uint64 {
+	if m != nil && m.NodeID != nil {
+		return *m.NodeID
+	}
+	return 0
+}
+
+var E_AddShardOwnerCommand_Command = &proto.ExtensionDesc{
+	ExtendedType:  (*Command)(nil),
+	ExtensionType: (*AddShardOwnerCommand)(nil),
+	Field:         121,
+	Name:          "internal.AddShardOwnerCommand.command",
+	Tag:           "bytes,121,opt,name=command",
+}
+
+type RemoveShardOwnerCommand struct {
+	ShardID          *uint64 `protobuf:"varint,1,req" json:"ShardID,omitempty"`
+	NodeID           *uint64 `protobuf:"varint,2,req" json:"NodeID,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *RemoveShardOwnerCommand) Reset()         { *m = RemoveShardOwnerCommand{} }
+func (m *RemoveShardOwnerCommand) String() string { return proto.CompactTextString(m) }
+func (*RemoveShardOwnerCommand) ProtoMessage()    {}
+
+func (m *RemoveShardOwnerCommand) GetShardID() uint64 {
+	if m != nil && m.ShardID != nil {
+		return *m.ShardID
+	}
+	return 0
+}
+
+func (m *RemoveShardOwnerCommand) GetNodeID() uint64 {
+	if m != nil && m.NodeID != nil {
+		return *m.NodeID
+	}
+	return 0
+}
+
+var E_RemoveShardOwnerCommand_Command = &proto.ExtensionDesc{
+	ExtendedType:  (*Command)(nil),
+	ExtensionType: (*RemoveShardOwnerCommand)(nil),
+	Field:         122,
+	Name:          "internal.RemoveShardOwnerCommand.command",
+	Tag:           "bytes,122,opt,name=command",
+}
+
+type SetNodeDecommissioningCommand struct {
+	ID               *uint64 `protobuf:"varint,1,req" json:"ID,omitempty"`
+	Decommissioning  *bool   `protobuf:"varint,2,req" json:"Decommissioning,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *SetNodeDecommissioningCommand) Reset()         { *m = SetNodeDecommissioningCommand{} }
+func (m *SetNodeDecommissioningCommand) String() string { return proto.CompactTextString(m) }
+func (*SetNodeDecommissioningCommand) ProtoMessage()    {}
+
+func (m *SetNodeDecommissioningCommand) GetID() uint64 {
+	if m != nil && m.ID != nil {
+		return *m.ID
+	}
+	return 0
+}
+
+func (m *SetNodeDecommissioningCommand) GetDecommissioning() bool {
+	if m != nil && m.Decommissioning != nil {
+		return *m.Decommissioning
+	}
+	return false
+}
+
+var E_SetNodeDecommissioningCommand_Command = &proto.ExtensionDesc{
+	ExtendedType:  (*Command)(nil),
+	ExtensionType: (*SetNodeDecommissioningCommand)(nil),
+	Field:         123,
+	Name:          "internal.SetNodeDecommissioningCommand.command",
+	Tag:           "bytes,123,opt,name=command",
+}
+
 type Response struct {
 	OK               *bool   `protobuf:"varint,1,req" json:"OK,omitempty"`
 	Error            *string `protobuf:"bytes,2,opt" json:"Error,omitempty"`
@@ -1421,6 +1634,38 @@ func (m *JoinResponse) GetNodeID() uint64 {
 	return 0
 }
 
+type RemoveNodeRequest struct {
+	ID               *uint64 `protobuf:"varint,1,req" json:"ID,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *RemoveNodeRequest) Reset()         { *m = RemoveNodeRequest{} }
+func (m *RemoveNodeRequest) String() string { return proto.CompactTextString(m) }
+func (*RemoveNodeRequest) ProtoMessage()    {}
+
+func (m *RemoveNodeRequest) GetID() uint64 {
+	if m != nil && m.ID != nil {
+		return *m.ID
+	}
+	return 0
+}
+
+type RemoveNodeResponse struct {
+	Header           *ResponseHeader `protobuf:"bytes,1,req" json:"Header,omitempty"`
+	XXX_unrecognized []byte          `json:"-"`
+}
+
+func (m *RemoveNodeResponse) Reset()         { *m = RemoveNodeResponse{} }
+func (m *RemoveNodeResponse) String() string { return proto.CompactTextString(m) }
+func (*RemoveNodeResponse) ProtoMessage()    {}
+
+func (m *RemoveNodeResponse) GetHeader() *ResponseHeader {
+	if m != nil {
+		return m.Header
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterEnum("internal.RPCType", RPCType_name, RPCType_value)
 	proto.RegisterEnum("internal.Command_Type", Command_Type_name, Command_Type_value)
@@ -1443,4 +1688,8 @@ func init() {
 	proto.RegisterExtension(E_SetDataCommand_Command)
 	proto.RegisterExtension(E_SetAdminPrivilegeCommand_Command)
 	proto.RegisterExtension(E_UpdateNodeCommand_Command)
+	proto.RegisterExtension(E_SetDatabaseReadOnlyCommand_Command)
+	proto.RegisterExtension(E_AddShardOwnerCommand_Command)
+	proto.RegisterExtension(E_RemoveShardOwnerCommand_Command)
+	proto.RegisterExtension(E_SetNodeDecommissioningCommand_Command)
 }
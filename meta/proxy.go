@@ -6,7 +6,7 @@ import (
 )
 
 // proxy brokers a connection from src to dst
-func proxy(dst, src *net.TCPConn) error {
+func proxy(dst, src net.Conn) error {
 	// channels to wait on the close event for each connection
 	serverClosed := make(chan struct{}, 1)
 	clientClosed := make(chan struct{}, 1)
@@ -25,16 +25,16 @@ func proxy(dst, src *net.TCPConn) error {
 		// the client closed first and any more packets from the server aren't
 		// useful, so we can optionally SetLinger(0) here to recycle the port
 		// faster.
-		dst.SetLinger(0)
-		dst.CloseRead()
+		setLinger(dst, 0)
+		closeRead(dst)
 		waitFor = serverClosed
 	case <-serverClosed:
-		src.CloseRead()
+		closeRead(src)
 		waitFor = clientClosed
 	case err := <-errors:
-		src.CloseRead()
-		dst.SetLinger(0)
-		dst.CloseRead()
+		closeRead(src)
+		setLinger(dst, 0)
+		closeRead(dst)
 		return err
 	}
 
@@ -60,3 +60,21 @@ func broker(dst, src net.Conn, srcClosed chan struct{}, errors chan error) {
 	}
 	srcClosed <- struct{}{}
 }
+
+// closeRead shuts down the reading side of a TCP connection. TLS connections
+// can't be half closed so they're closed completely.
+func closeRead(conn net.Conn) error {
+	if conn, ok := conn.(*net.TCPConn); ok {
+		return conn.CloseRead()
+	}
+	return conn.Close()
+}
+
+// setLinger sets the linger of a TCP connection. It's a no-op for other
+// connections.
+func setLinger(conn net.Conn, sec int) error {
+	if conn, ok := conn.(*net.TCPConn); ok {
+		return conn.SetLinger(sec)
+	}
+	return nil
+}
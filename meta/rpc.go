@@ -1,6 +1,7 @@
 package meta
 
 import (
+	"crypto/tls"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -13,6 +14,7 @@ import (
 	"github.com/gogo/protobuf/proto"
 	"github.com/hashicorp/raft"
 	"github.com/influxdb/influxdb/meta/internal"
+	"github.com/influxdb/influxdb/tcp"
 )
 
 // Max size of a message before we treat the size as invalid
@@ -25,6 +27,7 @@ const (
 type rpc struct {
 	logger         *log.Logger
 	tracingEnabled bool
+	tlsConfig      *tls.Config // dials other nodes over TLS if set
 
 	store interface {
 		cachedData() *Data
@@ -32,8 +35,11 @@ type rpc struct {
 		Leader() string
 		Peers() ([]string, error)
 		AddPeer(host string) error
+		RemovePeer(host string) error
 		CreateNode(host string) (*NodeInfo, error)
+		Node(id uint64) (*NodeInfo, error)
 		NodeByHost(host string) (*NodeInfo, error)
+		DeleteNode(id uint64) error
 		WaitForDataChanged() error
 	}
 }
@@ -49,21 +55,20 @@ type Reply interface {
 }
 
 // proxyLeader proxies the connection to the current raft leader
-func (r *rpc) proxyLeader(conn *net.TCPConn) {
+func (r *rpc) proxyLeader(conn net.Conn) {
 	if r.store.Leader() == "" {
 		r.sendError(conn, "no leader")
 		return
 	}
 
-	leaderConn, err := net.DialTimeout("tcp", r.store.Leader(), leaderDialTimeout)
+	leaderConn, err := tcp.DialTLS("tcp", r.store.Leader(), MuxRPCHeader, leaderDialTimeout, r.tlsConfig)
 	if err != nil {
 		r.sendError(conn, fmt.Sprintf("dial leader: %v", err))
 		return
 	}
 	defer leaderConn.Close()
 
-	leaderConn.Write([]byte{MuxRPCHeader})
-	if err := proxy(leaderConn.(*net.TCPConn), conn); err != nil {
+	if err := proxy(leaderConn, conn); err != nil {
 		r.sendError(conn, fmt.Sprintf("leader proxy error: %v", err))
 	}
 }
@@ -77,7 +82,7 @@ func (r *rpc) handleRPCConn(conn net.Conn) {
 	r.traceCluster("rpc connection from: %v", conn.RemoteAddr())
 
 	if !r.store.IsLeader() {
-		r.proxyLeader(conn.(*net.TCPConn))
+		r.proxyLeader(conn)
 		return
 	}
 
@@ -123,6 +128,13 @@ func (r *rpc) handleRPCConn(conn net.Conn) {
 			}
 			resp, err := r.handleJoinRequest(&req)
 			return rpcType, resp, err
+		case internal.RPCType_RemoveNode:
+			var req internal.RemoveNodeRequest
+			if err := proto.Unmarshal(buf, &req); err != nil {
+				return internal.RPCType_Error, nil, fmt.Errorf("remove node request unmarshal: %v", err)
+			}
+			resp, err := r.handleRemoveNodeRequest(&req)
+			return rpcType, resp, err
 		default:
 			return internal.RPCType_Error, nil, fmt.Errorf("unknown rpc type:%v", rpcType)
 		}
@@ -272,6 +284,41 @@ func (r *rpc) handleJoinRequest(req *internal.JoinRequest) (*internal.JoinRespon
 
 }
 
+// handleRemoveNodeRequest removes a node from the node list and the raft peers.
+func (r *rpc) handleRemoveNodeRequest(req *internal.RemoveNodeRequest) (*internal.RemoveNodeResponse, error) {
+	r.traceCluster("remove node request: %v", req.GetID())
+
+	node, err := r.store.Node(req.GetID())
+	if err != nil {
+		return nil, fmt.Errorf("node: %v", err)
+	} else if node == nil {
+		return nil, ErrNodeNotFound
+	}
+
+	// Delete the node before removing its peer as the leader can't apply
+	// commands once it has removed itself.
+	if err := r.store.DeleteNode(node.ID); err != nil {
+		return nil, fmt.Errorf("delete node: %v", err)
+	}
+
+	peers, err := r.store.Peers()
+	if err != nil {
+		return nil, fmt.Errorf("list peers: %v", err)
+	}
+	if raft.PeerContained(peers, node.Host) {
+		r.logger.Printf("removing raft peer: nodeId=%v addr=%v", node.ID, node.Host)
+		if err := r.store.RemovePeer(node.Host); err != nil {
+			return nil, fmt.Errorf("remove peer: %v", err)
+		}
+	}
+
+	return &internal.RemoveNodeResponse{
+		Header: &internal.ResponseHeader{
+			OK: proto.Bool(true),
+		},
+	}, nil
+}
+
 // pack returns a TLV style byte slice encoding the size of the payload, the RPC type
 // and the RPC data
 func (r *rpc) pack(typ internal.RPCType, b []byte) []byte {
@@ -351,6 +398,30 @@ func (r *rpc) join(localAddr, remoteAddr string) (*JoinResult, error) {
 	}
 }
 
+// removeNode asks the leader to remove the node with id from the cluster.
+func (r *rpc) removeNode(id uint64) error {
+	leader := r.store.Leader()
+	if leader == "" {
+		return errors.New("no leader")
+	}
+
+	resp, err := r.call(leader, &internal.RemoveNodeRequest{
+		ID: proto.Uint64(id),
+	})
+	if err != nil {
+		return err
+	}
+
+	switch t := resp.(type) {
+	case *internal.RemoveNodeResponse:
+		return nil
+	case *internal.ErrorResponse:
+		return fmt.Errorf("rpc failed: %s", t.GetHeader().GetError())
+	default:
+		return fmt.Errorf("rpc failed: unknown response type: %v", t.String())
+	}
+}
+
 // call sends an encoded request to the remote leader and returns
 // an encoded response value.
 func (r *rpc) call(dest string, req proto.Message) (proto.Message, error) {
@@ -361,23 +432,19 @@ func (r *rpc) call(dest string, req proto.Message) (proto.Message, error) {
 		rpcType = internal.RPCType_Join
 	case *internal.FetchDataRequest:
 		rpcType = internal.RPCType_FetchData
+	case *internal.RemoveNodeRequest:
+		rpcType = internal.RPCType_RemoveNode
 	default:
 		return nil, fmt.Errorf("unknown rpc request type: %v", t)
 	}
 
-	// Create a connection to the leader.
-	conn, err := net.DialTimeout("tcp", dest, leaderDialTimeout)
+	// Create a connection to the leader with a marker byte for rpc messages.
+	conn, err := tcp.DialTLS("tcp", dest, MuxRPCHeader, leaderDialTimeout, r.tlsConfig)
 	if err != nil {
 		return nil, fmt.Errorf("rpc dial: %v", err)
 	}
 	defer conn.Close()
 
-	// Write a marker byte for rpc messages.
-	_, err = conn.Write([]byte{MuxRPCHeader})
-	if err != nil {
-		return nil, err
-	}
-
 	b, err := proto.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("rpc marshal: %v", err)
@@ -415,6 +482,8 @@ func (r *rpc) call(dest string, req proto.Message) (proto.Message, error) {
 		resp = &internal.JoinResponse{}
 	case internal.RPCType_FetchData:
 		resp = &internal.FetchDataResponse{}
+	case internal.RPCType_RemoveNode:
+		resp = &internal.RemoveNodeResponse{}
 	case internal.RPCType_Error:
 		resp = &internal.ErrorResponse{}
 	default:
@@ -2,6 +2,7 @@ package meta
 
 import (
 	"net"
+	"reflect"
 	"sync"
 	"testing"
 )
@@ -176,11 +177,45 @@ func TestRPCJoin(t *testing.T) {
 	}
 }
 
+func TestRPCRemoveNode(t *testing.T) {
+	fs := &fakeStore{
+		leader: "1.2.3.4:1234",
+		md:     &Data{Index: 99},
+		nodes:  []NodeInfo{{ID: 1, Host: "1.2.3.4:1234"}, {ID: 2, Host: "1.2.3.5:1234"}},
+	}
+	serverRPC := &rpc{
+		store: fs,
+	}
+
+	srv := newTestServer(t, serverRPC)
+	defer srv.Close()
+	go srv.Serve()
+
+	// Wait for the RPC server to be ready
+	<-srv.Ready
+
+	clientRPC := &rpc{
+		store: &fakeStore{
+			leader: srv.Listener.Addr().String(),
+			md:     &Data{Index: 99},
+		},
+	}
+
+	if err := clientRPC.removeNode(2); err != nil {
+		t.Fatalf("failed to remove node: %v", err)
+	}
+
+	if exp := []NodeInfo{{ID: 1, Host: "1.2.3.4:1234"}}; !reflect.DeepEqual(fs.nodes, exp) {
+		t.Fatalf("nodes mismatch: got %v, exp %v", fs.nodes, exp)
+	}
+}
+
 type fakeStore struct {
 	mu        sync.RWMutex
 	leader    string
 	newNodeID uint64
 	md        *Data
+	nodes     []NodeInfo
 	blockChan chan struct{}
 }
 
@@ -235,7 +270,25 @@ func (f *fakeStore) AddPeer(host string) error { return nil }
 func (f *fakeStore) CreateNode(host string) (*NodeInfo, error) {
 	return &NodeInfo{ID: f.newNodeID, Host: host}, nil
 }
+func (f *fakeStore) Node(id uint64) (*NodeInfo, error) {
+	for i := range f.nodes {
+		if f.nodes[i].ID == id {
+			return &f.nodes[i], nil
+		}
+	}
+	return nil, nil
+}
 func (f *fakeStore) NodeByHost(host string) (*NodeInfo, error) { return nil, nil }
+func (f *fakeStore) DeleteNode(id uint64) error {
+	for i := range f.nodes {
+		if f.nodes[i].ID == id {
+			f.nodes = append(f.nodes[:i], f.nodes[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNodeNotFound
+}
+func (f *fakeStore) RemovePeer(host string) error { return nil }
 func (f *fakeStore) WaitForDataChanged() error {
 	<-f.blockChan
 	return nil
@@ -9,7 +9,9 @@ import (
 	"math/rand"
 	"os"
 	"path/filepath"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hashicorp/raft"
@@ -28,12 +30,36 @@ type raftState interface {
 	sync(index uint64, timeout time.Duration) error
 	setPeers(addrs []string) error
 	addPeer(addr string) error
+	removePeer(addr string) error
 	peers() ([]string, error)
 	invalidate() error
 	close() error
 	lastIndex() uint64
 	apply(b []byte) error
 	snapshot() error
+	status() (*RaftStatus, error)
+}
+
+// RaftStatus is the state of the raft log on the local node.
+type RaftStatus struct {
+	// Leader, Follower or Candidate. Remote if the node isn't a raft peer.
+	State string
+
+	Term              uint64
+	LastIndex         uint64
+	CommitIndex       uint64
+	AppliedIndex      uint64
+	LastSnapshotIndex uint64
+
+	// Time the last snapshot was taken. Zero if there are no snapshots.
+	LastSnapshot time.Time
+
+	Peers []string
+
+	// Number of commands applied by this node and the total time taken to
+	// commit them.
+	ApplyN        int64
+	ApplyDuration time.Duration
 }
 
 // localRaft is a consensus strategy that uses a local raft implementation for
@@ -47,6 +73,10 @@ type localRaft struct {
 	peerStore raft.PeerStore
 	raftStore *raftboltdb.BoltStore
 	raftLayer *raftLayer
+
+	// Number of commands applied and their total duration in nanoseconds.
+	applyN        int64
+	applyDuration int64
 }
 
 func (r *localRaft) remove() error {
@@ -111,6 +141,15 @@ func (r *localRaft) open() error {
 	config.ElectionTimeout = s.ElectionTimeout
 	config.LeaderLeaseTimeout = s.LeaderLeaseTimeout
 	config.CommitTimeout = s.CommitTimeout
+	if s.SnapshotInterval > 0 {
+		config.SnapshotInterval = s.SnapshotInterval
+	}
+	if s.SnapshotThreshold > 0 {
+		config.SnapshotThreshold = s.SnapshotThreshold
+	}
+	if s.TrailingLogs > 0 {
+		config.TrailingLogs = s.TrailingLogs
+	}
 
 	// If no peers are set in the config or there is one and we are it, then start as a single server.
 	if len(s.peers) <= 1 {
@@ -122,7 +161,7 @@ func (r *localRaft) open() error {
 	}
 
 	// Build raft layer to multiplex listener.
-	r.raftLayer = newRaftLayer(s.RaftListener, s.RemoteAddr)
+	r.raftLayer = newRaftLayer(s.RaftListener, s.RemoteAddr, s.TLSConfig)
 
 	// Create a transport layer
 	r.transport = raft.NewNetworkTransport(r.raftLayer, 3, 10*time.Second, config.LogOutput)
@@ -166,6 +205,11 @@ func (r *localRaft) open() error {
 		return fmt.Errorf("file snapshot store: %s", err)
 	}
 
+	// Restore the time of the last snapshot taken before the store was opened.
+	if t := latestSnapshotTime(filepath.Join(s.path, "snapshots")); !t.IsZero() && s.lastSnapshotTime().IsZero() {
+		atomic.StoreInt64(&s.lastSnapshot, t.UnixNano())
+	}
+
 	// Create raft log.
 	ra, err := raft.NewRaft(config, (*storeFSM)(s), store, store, snapshots, r.peerStore, r.transport)
 	if err != nil {
@@ -247,10 +291,13 @@ func (r *localRaft) initialize() error {
 // apply applies a serialized command to the raft log.
 func (r *localRaft) apply(b []byte) error {
 	// Apply to raft log.
+	start := time.Now()
 	f := r.raft.Apply(b, 0)
 	if err := f.Error(); err != nil {
 		return err
 	}
+	atomic.AddInt64(&r.applyN, 1)
+	atomic.AddInt64(&r.applyDuration, int64(time.Since(start)))
 
 	// Return response if it's an error.
 	// No other non-nil objects should be returned.
@@ -299,6 +346,37 @@ func (r *localRaft) snapshot() error {
 	return future.Error()
 }
 
+// status returns the state of the local raft log.
+func (r *localRaft) status() (*RaftStatus, error) {
+	if r.raft == nil {
+		return nil, ErrStoreClosed
+	}
+
+	peers, err := r.peers()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := r.raft.Stats()
+	index := func(key string) uint64 {
+		n, _ := strconv.ParseUint(stats[key], 10, 64)
+		return n
+	}
+
+	return &RaftStatus{
+		State:             r.raft.State().String(),
+		Term:              index("term"),
+		LastIndex:         r.raft.LastIndex(),
+		CommitIndex:       index("commit_index"),
+		AppliedIndex:      index("applied_index"),
+		LastSnapshotIndex: index("last_snapshot_index"),
+		LastSnapshot:      r.store.lastSnapshotTime(),
+		Peers:             peers,
+		ApplyN:            atomic.LoadInt64(&r.applyN),
+		ApplyDuration:     time.Duration(atomic.LoadInt64(&r.applyDuration)),
+	}, nil
+}
+
 // addPeer adds addr to the list of peers in the cluster.
 func (r *localRaft) addPeer(addr string) error {
 	peers, err := r.peerStore.Peers()
@@ -316,6 +394,11 @@ func (r *localRaft) addPeer(addr string) error {
 	return nil
 }
 
+// removePeer removes addr from the list of peers in the cluster.
+func (r *localRaft) removePeer(addr string) error {
+	return r.raft.RemovePeer(addr).Error()
+}
+
 // setPeers sets a list of peers in the cluster.
 func (r *localRaft) setPeers(addrs []string) error {
 	return r.raft.SetPeers(addrs).Error()
@@ -397,6 +480,11 @@ func (r *remoteRaft) addPeer(addr string) error {
 	return fmt.Errorf("cannot add peer using remote raft")
 }
 
+// removePeer removes addr from the list of peers in the cluster.
+func (r *remoteRaft) removePeer(addr string) error {
+	return fmt.Errorf("cannot remove peer using remote raft")
+}
+
 func (r *remoteRaft) peers() ([]string, error) {
 	return readPeersJSON(filepath.Join(r.store.path, "peers.json"))
 }
@@ -464,6 +552,30 @@ func (r *remoteRaft) snapshot() error {
 	return fmt.Errorf("cannot snapshot while in remote raft state")
 }
 
+// status returns the index of the cached metadata. The node has no raft log.
+// The store's lock must be held by the caller.
+func (r *remoteRaft) status() (*RaftStatus, error) {
+	return &RaftStatus{
+		State:     "Remote",
+		Term:      r.store.data.Term,
+		LastIndex: r.store.data.Index,
+		Peers:     r.store.peers,
+	}, nil
+}
+
+// latestSnapshotTime returns the modification time of the newest snapshot in
+// dir. Returns the zero time if there are no snapshots.
+func latestSnapshotTime(dir string) time.Time {
+	var t time.Time
+	paths, _ := filepath.Glob(filepath.Join(dir, "*", "meta.json"))
+	for _, path := range paths {
+		if fi, err := os.Stat(path); err == nil && fi.ModTime().After(t) {
+			t = fi.ModTime()
+		}
+	}
+	return t
+}
+
 func readPeersJSON(path string) ([]string, error) {
 	// Read the file
 	buf, err := ioutil.ReadFile(path)
@@ -15,11 +15,14 @@ type StatementExecutor struct {
 	Store interface {
 		Nodes() ([]NodeInfo, error)
 		Peers() ([]string, error)
+		SetNodeDecommissioning(id uint64, decommissioning bool) error
+		RemoveNode(id uint64) error
 
 		Database(name string) (*DatabaseInfo, error)
 		Databases() ([]DatabaseInfo, error)
 		CreateDatabase(name string) (*DatabaseInfo, error)
 		DropDatabase(name string) error
+		SetDatabaseReadOnly(name string, readOnly bool) error
 
 		DefaultRetentionPolicy(database string) (*RetentionPolicyInfo, error)
 		CreateRetentionPolicy(database string, rpi *RetentionPolicyInfo) (*RetentionPolicyInfo, error)
@@ -38,6 +41,14 @@ type StatementExecutor struct {
 
 		CreateContinuousQuery(database, name, query string) error
 		DropContinuousQuery(database, name string) error
+
+		Snapshot() error
+	}
+
+	// TSDBStore reports where local shards are stored and whether they're open. Optional.
+	TSDBStore interface {
+		ColdShardPath(shardID uint64) string
+		ShardState(shardID uint64) string
 	}
 }
 
@@ -54,6 +65,8 @@ func (e *StatementExecutor) ExecuteStatement(stmt influxql.Statement) *influxql.
 		return e.executeShowGrantsForUserStatement(stmt)
 	case *influxql.ShowServersStatement:
 		return e.executeShowServersStatement(stmt)
+	case *influxql.DropServerStatement:
+		return e.executeDropServerStatement(stmt)
 	case *influxql.CreateUserStatement:
 		return e.executeCreateUserStatement(stmt)
 	case *influxql.SetPasswordUserStatement:
@@ -72,6 +85,8 @@ func (e *StatementExecutor) ExecuteStatement(stmt influxql.Statement) *influxql.
 		return e.executeRevokeAdminStatement(stmt)
 	case *influxql.CreateRetentionPolicyStatement:
 		return e.executeCreateRetentionPolicyStatement(stmt)
+	case *influxql.AlterDatabaseStatement:
+		return e.executeAlterDatabaseStatement(stmt)
 	case *influxql.AlterRetentionPolicyStatement:
 		return e.executeAlterRetentionPolicyStatement(stmt)
 	case *influxql.DropRetentionPolicyStatement:
@@ -88,6 +103,8 @@ func (e *StatementExecutor) ExecuteStatement(stmt influxql.Statement) *influxql.
 		return e.executeShowShardsStatement(stmt)
 	case *influxql.ShowStatsStatement:
 		return e.executeShowStatsStatement(stmt)
+	case *influxql.SnapshotMetaStatement:
+		return e.executeSnapshotMetaStatement(stmt)
 	default:
 		panic(fmt.Sprintf("unsupported statement type: %T", stmt))
 	}
@@ -105,6 +122,10 @@ func (e *StatementExecutor) executeDropDatabaseStatement(q *influxql.DropDatabas
 	return &influxql.Result{Err: e.Store.DropDatabase(q.Name)}
 }
 
+func (e *StatementExecutor) executeAlterDatabaseStatement(q *influxql.AlterDatabaseStatement) *influxql.Result {
+	return &influxql.Result{Err: e.Store.SetDatabaseReadOnly(q.Name, q.ReadOnly)}
+}
+
 func (e *StatementExecutor) executeShowDatabasesStatement(q *influxql.ShowDatabasesStatement) *influxql.Result {
 	dis, err := e.Store.Databases()
 	if err != nil {
@@ -142,13 +163,23 @@ func (e *StatementExecutor) executeShowServersStatement(q *influxql.ShowServersS
 		return &influxql.Result{Err: err}
 	}
 
-	row := &models.Row{Columns: []string{"id", "cluster_addr", "raft"}}
+	row := &models.Row{Columns: []string{"id", "cluster_addr", "raft", "decommissioning"}}
 	for _, ni := range nis {
-		row.Values = append(row.Values, []interface{}{ni.ID, ni.Host, contains(peers, ni.Host)})
+		row.Values = append(row.Values, []interface{}{ni.ID, ni.Host, contains(peers, ni.Host), ni.Decommissioning})
 	}
 	return &influxql.Result{Series: []*models.Row{row}}
 }
 
+// executeDropServerStatement removes a node immediately or marks it as
+// decommissioning. A decommissioning node moves its shards off and then
+// removes itself from the cluster.
+func (e *StatementExecutor) executeDropServerStatement(q *influxql.DropServerStatement) *influxql.Result {
+	if q.Decommission {
+		return &influxql.Result{Err: e.Store.SetNodeDecommissioning(q.NodeID, true)}
+	}
+	return &influxql.Result{Err: e.Store.RemoveNode(q.NodeID)}
+}
+
 func (e *StatementExecutor) executeCreateUserStatement(q *influxql.CreateUserStatement) *influxql.Result {
 	_, err := e.Store.CreateUser(q.Name, q.Password, q.Admin)
 	return &influxql.Result{Err: err}
@@ -207,6 +238,9 @@ func (e *StatementExecutor) executeCreateRetentionPolicyStatement(stmt *influxql
 	rpi := NewRetentionPolicyInfo(stmt.Name)
 	rpi.Duration = stmt.Duration
 	rpi.ReplicaN = stmt.Replication
+	rpi.DownsampleTo = stmt.DownsampleTo
+	rpi.DownsampleInterval = stmt.DownsampleInterval
+	rpi.DuplicatePolicy = stmt.DuplicatePolicy
 
 	// Create new retention policy.
 	_, err := e.Store.CreateRetentionPolicy(stmt.Database, rpi)
@@ -224,8 +258,11 @@ func (e *StatementExecutor) executeCreateRetentionPolicyStatement(stmt *influxql
 
 func (e *StatementExecutor) executeAlterRetentionPolicyStatement(stmt *influxql.AlterRetentionPolicyStatement) *influxql.Result {
 	rpu := &RetentionPolicyUpdate{
-		Duration: stmt.Duration,
-		ReplicaN: stmt.Replication,
+		Duration:           stmt.Duration,
+		ReplicaN:           stmt.Replication,
+		DownsampleTo:       stmt.DownsampleTo,
+		DownsampleInterval: stmt.DownsampleInterval,
+		DuplicatePolicy:    stmt.DuplicatePolicy,
 	}
 
 	// Update the retention policy.
@@ -254,9 +291,17 @@ func (e *StatementExecutor) executeShowRetentionPoliciesStatement(q *influxql.Sh
 		return &influxql.Result{Err: ErrDatabaseNotFound}
 	}
 
-	row := &models.Row{Columns: []string{"name", "duration", "replicaN", "default"}}
+	row := &models.Row{Columns: []string{"name", "duration", "replicaN", "default", "downsample_to", "downsample_interval", "duplicate_policy"}}
 	for _, rpi := range di.RetentionPolicies {
-		row.Values = append(row.Values, []interface{}{rpi.Name, rpi.Duration.String(), rpi.ReplicaN, di.DefaultRetentionPolicy == rpi.Name})
+		var downsampleInterval string
+		if rpi.DownsampleTo != "" {
+			downsampleInterval = rpi.DownsampleInterval.String()
+		}
+		duplicatePolicy := rpi.DuplicatePolicy
+		if duplicatePolicy == "" {
+			duplicatePolicy = DuplicatePolicyReplace
+		}
+		row.Values = append(row.Values, []interface{}{rpi.Name, rpi.Duration.String(), rpi.ReplicaN, di.DefaultRetentionPolicy == rpi.Name, rpi.DownsampleTo, downsampleInterval, duplicatePolicy})
 	}
 	return &influxql.Result{Series: []*models.Row{row}}
 }
@@ -298,7 +343,7 @@ func (e *StatementExecutor) executeShowShardsStatement(stmt *influxql.ShowShards
 
 	rows := []*models.Row{}
 	for _, di := range dis {
-		row := &models.Row{Columns: []string{"id", "start_time", "end_time", "expiry_time", "owners"}, Name: di.Name}
+		row := &models.Row{Columns: []string{"id", "start_time", "end_time", "expiry_time", "owners", "cold_path", "state"}, Name: di.Name}
 		for _, rpi := range di.RetentionPolicies {
 			for _, sgi := range rpi.ShardGroups {
 				for _, si := range sgi.Shards {
@@ -307,12 +352,20 @@ func (e *StatementExecutor) executeShowShardsStatement(stmt *influxql.ShowShards
 						ownerIDs[i] = owner.NodeID
 					}
 
+					var coldPath, state string
+					if e.TSDBStore != nil {
+						coldPath = e.TSDBStore.ColdShardPath(si.ID)
+						state = e.TSDBStore.ShardState(si.ID)
+					}
+
 					row.Values = append(row.Values, []interface{}{
 						si.ID,
 						sgi.StartTime.UTC().Format(time.RFC3339),
 						sgi.EndTime.UTC().Format(time.RFC3339),
 						sgi.EndTime.Add(rpi.Duration).UTC().Format(time.RFC3339),
 						joinUint64(ownerIDs),
+						coldPath,
+						state,
 					})
 				}
 			}
@@ -326,6 +379,10 @@ func (e *StatementExecutor) executeShowStatsStatement(stmt *influxql.ShowStatsSt
 	return &influxql.Result{Err: fmt.Errorf("SHOW STATS is not implemented yet")}
 }
 
+func (e *StatementExecutor) executeSnapshotMetaStatement(stmt *influxql.SnapshotMetaStatement) *influxql.Result {
+	return &influxql.Result{Err: e.Store.Snapshot()}
+}
+
 // joinUint64 returns a comma-delimited string of uint64 numbers.
 func joinUint64(a []uint64) string {
 	var buf bytes.Buffer
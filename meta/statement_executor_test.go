@@ -46,6 +46,32 @@ func TestStatementExecutor_ExecuteStatement_DropDatabase(t *testing.T) {
 	}
 }
 
+// Ensure an ALTER DATABASE statement can be executed.
+func TestStatementExecutor_ExecuteStatement_AlterDatabase(t *testing.T) {
+	e := NewStatementExecutor()
+
+	var readOnly bool
+	e.Store.SetDatabaseReadOnlyFn = func(name string, v bool) error {
+		if name != "foo" {
+			t.Fatalf("unexpected name: %s", name)
+		}
+		readOnly = v
+		return nil
+	}
+
+	if res := e.ExecuteStatement(influxql.MustParseStatement(`ALTER DATABASE foo READONLY`)); res.Err != nil {
+		t.Fatal(res.Err)
+	} else if !readOnly {
+		t.Fatal("expected database to be read-only")
+	}
+
+	if res := e.ExecuteStatement(influxql.MustParseStatement(`ALTER DATABASE foo READWRITE`)); res.Err != nil {
+		t.Fatal(res.Err)
+	} else if readOnly {
+		t.Fatal("expected database to be writable")
+	}
+}
+
 // Ensure a SHOW DATABASES statement can be executed.
 func TestStatementExecutor_ExecuteStatement_ShowDatabases(t *testing.T) {
 	e := NewStatementExecutor()
@@ -119,7 +145,7 @@ func TestStatementExecutor_ExecuteStatement_ShowServers(t *testing.T) {
 	e.Store.NodesFn = func() ([]meta.NodeInfo, error) {
 		return []meta.NodeInfo{
 			{ID: 1, Host: "node0"},
-			{ID: 2, Host: "node1"},
+			{ID: 2, Host: "node1", Decommissioning: true},
 		}, nil
 	}
 	e.Store.PeersFn = func() ([]string, error) {
@@ -130,10 +156,10 @@ func TestStatementExecutor_ExecuteStatement_ShowServers(t *testing.T) {
 		t.Fatal(res.Err)
 	} else if !reflect.DeepEqual(res.Series, models.Rows{
 		{
-			Columns: []string{"id", "cluster_addr", "raft"},
+			Columns: []string{"id", "cluster_addr", "raft", "decommissioning"},
 			Values: [][]interface{}{
-				{uint64(1), "node0", true},
-				{uint64(2), "node1", false},
+				{uint64(1), "node0", true, false},
+				{uint64(2), "node1", false, true},
 			},
 		},
 	}) {
@@ -153,6 +179,60 @@ func TestStatementExecutor_ExecuteStatement_ShowServers_Err(t *testing.T) {
 	}
 }
 
+// Ensure a DROP SERVER statement removes the node.
+func TestStatementExecutor_ExecuteStatement_DropServer(t *testing.T) {
+	e := NewStatementExecutor()
+	e.Store.RemoveNodeFn = func(id uint64) error {
+		if id != 2 {
+			t.Fatalf("unexpected id: %d", id)
+		}
+		return nil
+	}
+
+	if res := e.ExecuteStatement(influxql.MustParseStatement(`DROP SERVER 2`)); res.Err != nil {
+		t.Fatal(res.Err)
+	} else if res.Series != nil {
+		t.Fatalf("unexpected rows: %#v", res.Series)
+	}
+}
+
+// Ensure a DROP SERVER DECOMMISSION statement marks the node as decommissioning.
+func TestStatementExecutor_ExecuteStatement_DropServer_Decommission(t *testing.T) {
+	e := NewStatementExecutor()
+	e.Store.SetNodeDecommissioningFn = func(id uint64, decommissioning bool) error {
+		if id != 2 {
+			t.Fatalf("unexpected id: %d", id)
+		} else if !decommissioning {
+			t.Fatal("expected decommissioning")
+		}
+		return nil
+	}
+
+	if res := e.ExecuteStatement(influxql.MustParseStatement(`DROP SERVER 2 DECOMMISSION`)); res.Err != nil {
+		t.Fatal(res.Err)
+	} else if res.Series != nil {
+		t.Fatalf("unexpected rows: %#v", res.Series)
+	}
+}
+
+// Ensure a SNAPSHOT META statement snapshots the store.
+func TestStatementExecutor_ExecuteStatement_SnapshotMeta(t *testing.T) {
+	var called bool
+	e := NewStatementExecutor()
+	e.Store.SnapshotFn = func() error {
+		called = true
+		return nil
+	}
+
+	if res := e.ExecuteStatement(influxql.MustParseStatement(`SNAPSHOT META`)); res.Err != nil {
+		t.Fatal(res.Err)
+	} else if res.Series != nil {
+		t.Fatalf("unexpected rows: %#v", res.Series)
+	} else if !called {
+		t.Fatal("expected snapshot")
+	}
+}
+
 // Ensure a CREATE USER statement can be executed.
 func TestStatementExecutor_ExecuteStatement_CreateUser(t *testing.T) {
 	e := NewStatementExecutor()
@@ -571,9 +651,12 @@ func TestStatementExecutor_ExecuteStatement_ShowRetentionPolicies(t *testing.T)
 					ReplicaN: 3,
 				},
 				{
-					Name:     "rp1",
-					Duration: 24 * time.Hour,
-					ReplicaN: 1,
+					Name:               "rp1",
+					Duration:           24 * time.Hour,
+					ReplicaN:           1,
+					DownsampleTo:       "rp0",
+					DownsampleInterval: 5 * time.Minute,
+					DuplicatePolicy:    "merge",
 				},
 			},
 		}, nil
@@ -583,10 +666,10 @@ func TestStatementExecutor_ExecuteStatement_ShowRetentionPolicies(t *testing.T)
 		t.Fatal(res.Err)
 	} else if !reflect.DeepEqual(res.Series, models.Rows{
 		{
-			Columns: []string{"name", "duration", "replicaN", "default"},
+			Columns: []string{"name", "duration", "replicaN", "default", "downsample_to", "downsample_interval", "duplicate_policy"},
 			Values: [][]interface{}{
-				{"rp0", "2h0m0s", 3, false},
-				{"rp1", "24h0m0s", 1, true},
+				{"rp0", "2h0m0s", 3, false, "", "", "replace"},
+				{"rp1", "24h0m0s", 1, true, "rp0", "5m0s", "merge"},
 			},
 		},
 	}) {
@@ -801,15 +884,20 @@ func TestStatementExecutor_ExecuteStatement_ShowShards(t *testing.T) {
 		}, nil
 	}
 
+	e.StatementExecutor.TSDBStore = &localShards{
+		cold:   map[uint64]string{2: "/cold/foo/default/2"},
+		states: map[uint64]string{1: "open", 2: "closed"},
+	}
+
 	if res := e.ExecuteStatement(influxql.MustParseStatement(`SHOW SHARDS`)); res.Err != nil {
 		t.Fatal(res.Err)
 	} else if !reflect.DeepEqual(res.Series, models.Rows{
 		{
 			Name:    "foo",
-			Columns: []string{"id", "start_time", "end_time", "expiry_time", "owners"},
+			Columns: []string{"id", "start_time", "end_time", "expiry_time", "owners", "cold_path", "state"},
 			Values: [][]interface{}{
-				{uint64(1), "1970-01-01T00:00:00Z", "1970-01-01T00:00:01Z", "1970-01-01T00:00:02Z", "1,2,3"},
-				{uint64(2), "1970-01-01T00:00:00Z", "1970-01-01T00:00:01Z", "1970-01-01T00:00:02Z", ""},
+				{uint64(1), "1970-01-01T00:00:00Z", "1970-01-01T00:00:01Z", "1970-01-01T00:00:02Z", "1,2,3", "", "open"},
+				{uint64(2), "1970-01-01T00:00:00Z", "1970-01-01T00:00:01Z", "1970-01-01T00:00:02Z", "", "/cold/foo/default/2", "closed"},
 			},
 		},
 	}) {
@@ -817,6 +905,15 @@ func TestStatementExecutor_ExecuteStatement_ShowShards(t *testing.T) {
 	}
 }
 
+// localShards is a mock of the local store that returns shard paths and states from maps.
+type localShards struct {
+	cold   map[uint64]string
+	states map[uint64]string
+}
+
+func (s *localShards) ColdShardPath(shardID uint64) string { return s.cold[shardID] }
+func (s *localShards) ShardState(shardID uint64) string    { return s.states[shardID] }
+
 // StatementExecutor represents a test wrapper for meta.StatementExecutor.
 type StatementExecutor struct {
 	*meta.StatementExecutor
@@ -834,10 +931,13 @@ func NewStatementExecutor() *StatementExecutor {
 type StatementExecutorStore struct {
 	NodesFn                     func() ([]meta.NodeInfo, error)
 	PeersFn                     func() ([]string, error)
+	SetNodeDecommissioningFn    func(id uint64, decommissioning bool) error
+	RemoveNodeFn                func(id uint64) error
 	DatabaseFn                  func(name string) (*meta.DatabaseInfo, error)
 	DatabasesFn                 func() ([]meta.DatabaseInfo, error)
 	CreateDatabaseFn            func(name string) (*meta.DatabaseInfo, error)
 	DropDatabaseFn              func(name string) error
+	SetDatabaseReadOnlyFn       func(name string, readOnly bool) error
 	DefaultRetentionPolicyFn    func(database string) (*meta.RetentionPolicyInfo, error)
 	CreateRetentionPolicyFn     func(database string, rpi *meta.RetentionPolicyInfo) (*meta.RetentionPolicyInfo, error)
 	UpdateRetentionPolicyFn     func(database, name string, rpu *meta.RetentionPolicyUpdate) error
@@ -854,6 +954,7 @@ type StatementExecutorStore struct {
 	ContinuousQueriesFn         func() ([]meta.ContinuousQueryInfo, error)
 	CreateContinuousQueryFn     func(database, name, query string) error
 	DropContinuousQueryFn       func(database, name string) error
+	SnapshotFn                  func() error
 }
 
 func (s *StatementExecutorStore) Nodes() ([]meta.NodeInfo, error) {
@@ -864,6 +965,14 @@ func (s *StatementExecutorStore) Peers() ([]string, error) {
 	return s.PeersFn()
 }
 
+func (s *StatementExecutorStore) SetNodeDecommissioning(id uint64, decommissioning bool) error {
+	return s.SetNodeDecommissioningFn(id, decommissioning)
+}
+
+func (s *StatementExecutorStore) RemoveNode(id uint64) error {
+	return s.RemoveNodeFn(id)
+}
+
 func (s *StatementExecutorStore) Database(name string) (*meta.DatabaseInfo, error) {
 	return s.DatabaseFn(name)
 }
@@ -880,6 +989,10 @@ func (s *StatementExecutorStore) DropDatabase(name string) error {
 	return s.DropDatabaseFn(name)
 }
 
+func (s *StatementExecutorStore) SetDatabaseReadOnly(name string, readOnly bool) error {
+	return s.SetDatabaseReadOnlyFn(name, readOnly)
+}
+
 func (s *StatementExecutorStore) DefaultRetentionPolicy(database string) (*meta.RetentionPolicyInfo, error) {
 	return s.DefaultRetentionPolicyFn(database)
 }
@@ -943,3 +1056,7 @@ func (s *StatementExecutorStore) CreateContinuousQuery(database, name, query str
 func (s *StatementExecutorStore) DropContinuousQuery(database, name string) error {
 	return s.DropContinuousQueryFn(database, name)
 }
+
+func (s *StatementExecutorStore) Snapshot() error {
+	return s.SnapshotFn()
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	crand "crypto/rand"
 	"crypto/sha256"
+	"crypto/tls"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -17,12 +18,14 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gogo/protobuf/proto"
 	"github.com/hashicorp/raft"
 	"github.com/influxdb/influxdb/influxql"
 	"github.com/influxdb/influxdb/meta/internal"
+	"github.com/influxdb/influxdb/tcp"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -104,6 +107,9 @@ type Store struct {
 	// The advertised hostname of the store.
 	Addr net.Addr
 
+	// If set, connections to other nodes are made over TLS.
+	TLSConfig *tls.Config
+
 	// The amount of time before a follower starts a new election.
 	HeartbeatTimeout time.Duration
 
@@ -117,6 +123,18 @@ type Store struct {
 	// The amount of time without an apply before sending a heartbeat.
 	CommitTimeout time.Duration
 
+	// The interval between checks for whether to snapshot the raft log, the
+	// number of entries written before a snapshot is taken and the number of
+	// entries kept after a snapshot. Zero values use the raft defaults.
+	SnapshotInterval  time.Duration
+	SnapshotThreshold uint64
+	TrailingLogs      uint64
+
+	// Time the last snapshot was persisted in nanoseconds since the epoch.
+	// Accessed atomically because snapshots are persisted while the lock may
+	// be held by a caller waiting for raft to shut down.
+	lastSnapshot int64
+
 	// Authentication cache.
 	authCache map[string]authUser
 
@@ -151,6 +169,9 @@ func NewStore(c *Config) *Store {
 		ElectionTimeout:    time.Duration(c.ElectionTimeout),
 		LeaderLeaseTimeout: time.Duration(c.LeaderLeaseTimeout),
 		CommitTimeout:      time.Duration(c.CommitTimeout),
+		SnapshotInterval:   time.Duration(c.SnapshotInterval),
+		SnapshotThreshold:  c.SnapshotThreshold,
+		TrailingLogs:       c.TrailingLogs,
 		authCache:          make(map[string]authUser, 0),
 		hashPassword: func(password string) ([]byte, error) {
 			return bcrypt.GenerateFromPassword([]byte(password), BcryptCost)
@@ -235,6 +256,7 @@ func (s *Store) Open() error {
 	}
 
 	// Begin serving listener.
+	s.rpc.tlsConfig = s.TLSConfig
 	s.wg.Add(1)
 	go s.serveExecListener()
 
@@ -542,6 +564,24 @@ func (s *Store) Snapshot() error {
 	return s.raftState.snapshot()
 }
 
+// RaftStatus returns the state of the raft log on the local node.
+func (s *Store) RaftStatus() (*RaftStatus, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.raftState == nil {
+		return nil, ErrStoreClosed
+	}
+	return s.raftState.status()
+}
+
+// lastSnapshotTime returns the time the last snapshot was persisted.
+func (s *Store) lastSnapshotTime() time.Time {
+	if ns := atomic.LoadInt64(&s.lastSnapshot); ns != 0 {
+		return time.Unix(0, ns)
+	}
+	return time.Time{}
+}
+
 // WaitForLeader sleeps until a leader is found or a timeout occurs.
 // timeout == 0 means to wait forever.
 func (s *Store) WaitForLeader(timeout time.Duration) error {
@@ -605,6 +645,12 @@ func (s *Store) AddPeer(addr string) error {
 	return s.raftState.addPeer(addr)
 }
 
+// RemovePeer removes addr from the list of peers in the cluster.
+// This must be called on the leader.
+func (s *Store) RemovePeer(addr string) error {
+	return s.raftState.removePeer(addr)
+}
+
 // Peers returns the list of peers in the cluster.
 func (s *Store) Peers() ([]string, error) {
 	s.mu.RLock()
@@ -648,15 +694,14 @@ func (s *Store) handleExecConn(conn net.Conn) {
 			return
 		}
 
-		leaderConn, err := net.DialTimeout("tcp", s.Leader(), 10*time.Second)
+		leaderConn, err := tcp.DialTLS("tcp", s.Leader(), MuxExecHeader, 10*time.Second, s.TLSConfig)
 		if err != nil {
 			s.Logger.Printf("Dial leader: %v", err)
 			return
 		}
 		defer leaderConn.Close()
-		leaderConn.Write([]byte{MuxExecHeader})
 
-		if err := proxy(leaderConn.(*net.TCPConn), conn.(*net.TCPConn)); err != nil {
+		if err := proxy(leaderConn, conn); err != nil {
 			s.Logger.Printf("Leader proxy error: %v", err)
 		}
 		conn.Close()
@@ -831,6 +876,22 @@ func (s *Store) DeleteNode(id uint64) error {
 	)
 }
 
+// SetNodeDecommissioning marks a node as being decommissioned.
+func (s *Store) SetNodeDecommissioning(id uint64, decommissioning bool) error {
+	return s.exec(internal.Command_SetNodeDecommissioningCommand, internal.E_SetNodeDecommissioningCommand_Command,
+		&internal.SetNodeDecommissioningCommand{
+			ID:              proto.Uint64(id),
+			Decommissioning: proto.Bool(decommissioning),
+		},
+	)
+}
+
+// RemoveNode removes a node from the raft peers and the list of nodes.
+// The request is sent to the leader so this can be called on any node.
+func (s *Store) RemoveNode(id uint64) error {
+	return s.rpc.removeNode(id)
+}
+
 // Database returns a database by name.
 func (s *Store) Database(name string) (di *DatabaseInfo, err error) {
 	err = s.read(func(data *Data) error {
@@ -1012,6 +1073,16 @@ func (s *Store) SetDefaultRetentionPolicy(database, name string) error {
 	)
 }
 
+// SetDatabaseReadOnly sets whether a database rejects writes.
+func (s *Store) SetDatabaseReadOnly(name string, readOnly bool) error {
+	return s.exec(internal.Command_SetDatabaseReadOnlyCommand, internal.E_SetDatabaseReadOnlyCommand_Command,
+		&internal.SetDatabaseReadOnlyCommand{
+			Database: proto.String(name),
+			ReadOnly: proto.Bool(readOnly),
+		},
+	)
+}
+
 // UpdateRetentionPolicy updates an existing retention policy.
 func (s *Store) UpdateRetentionPolicy(database, name string, rpu *RetentionPolicyUpdate) error {
 	var newName *string
@@ -1031,13 +1102,22 @@ func (s *Store) UpdateRetentionPolicy(database, name string, rpu *RetentionPolic
 		replicaN = &value
 	}
 
+	var downsampleInterval *int64
+	if rpu.DownsampleInterval != nil {
+		value := int64(*rpu.DownsampleInterval)
+		downsampleInterval = &value
+	}
+
 	return s.exec(internal.Command_UpdateRetentionPolicyCommand, internal.E_UpdateRetentionPolicyCommand_Command,
 		&internal.UpdateRetentionPolicyCommand{
-			Database: proto.String(database),
-			Name:     proto.String(name),
-			NewName:  newName,
-			Duration: duration,
-			ReplicaN: replicaN,
+			Database:           proto.String(database),
+			Name:               proto.String(name),
+			NewName:            newName,
+			Duration:           duration,
+			ReplicaN:           replicaN,
+			DownsampleTo:       rpu.DownsampleTo,
+			DownsampleInterval: downsampleInterval,
+			DuplicatePolicy:    rpu.DuplicatePolicy,
 		},
 	)
 }
@@ -1174,6 +1254,26 @@ func (s *Store) ShardOwner(shardID uint64) (database, policy string, sgi *ShardG
 	return
 }
 
+// AddShardOwner adds a node to the owners of a shard.
+func (s *Store) AddShardOwner(shardID, nodeID uint64) error {
+	return s.exec(internal.Command_AddShardOwnerCommand, internal.E_AddShardOwnerCommand_Command,
+		&internal.AddShardOwnerCommand{
+			ShardID: proto.Uint64(shardID),
+			NodeID:  proto.Uint64(nodeID),
+		},
+	)
+}
+
+// RemoveShardOwner removes a node from the owners of a shard.
+func (s *Store) RemoveShardOwner(shardID, nodeID uint64) error {
+	return s.exec(internal.Command_RemoveShardOwnerCommand, internal.E_RemoveShardOwnerCommand_Command,
+		&internal.RemoveShardOwnerCommand{
+			ShardID: proto.Uint64(shardID),
+			NodeID:  proto.Uint64(nodeID),
+		},
+	)
+}
+
 // CreateContinuousQuery creates a new continuous query on the store.
 func (s *Store) CreateContinuousQuery(database, name, query string) error {
 	return s.exec(internal.Command_CreateContinuousQueryCommand, internal.E_CreateContinuousQueryCommand_Command,
@@ -1512,19 +1612,13 @@ func (s *Store) remoteExec(b []byte) error {
 		return errors.New("no leader")
 	}
 
-	// Create a connection to the leader.
-	conn, err := net.DialTimeout("tcp", leader, 10*time.Second)
+	// Create a connection to the leader with a marker byte for exec messages.
+	conn, err := tcp.DialTLS("tcp", leader, MuxExecHeader, 10*time.Second, s.TLSConfig)
 	if err != nil {
 		return err
 	}
 	defer conn.Close()
 
-	// Write a marker byte for exec messages.
-	_, err = conn.Write([]byte{MuxExecHeader})
-	if err != nil {
-		return err
-	}
-
 	// Write a marker message.
 	_, err = conn.Write([]byte(ExecMagic))
 	if err != nil {
@@ -1626,6 +1720,14 @@ func (fsm *storeFSM) Apply(l *raft.Log) interface{} {
 			return fsm.applyDropRetentionPolicyCommand(&cmd)
 		case internal.Command_SetDefaultRetentionPolicyCommand:
 			return fsm.applySetDefaultRetentionPolicyCommand(&cmd)
+		case internal.Command_SetDatabaseReadOnlyCommand:
+			return fsm.applySetDatabaseReadOnlyCommand(&cmd)
+		case internal.Command_AddShardOwnerCommand:
+			return fsm.applyAddShardOwnerCommand(&cmd)
+		case internal.Command_RemoveShardOwnerCommand:
+			return fsm.applyRemoveShardOwnerCommand(&cmd)
+		case internal.Command_SetNodeDecommissioningCommand:
+			return fsm.applySetNodeDecommissioningCommand(&cmd)
 		case internal.Command_UpdateRetentionPolicyCommand:
 			return fsm.applyUpdateRetentionPolicyCommand(&cmd)
 		case internal.Command_CreateShardGroupCommand:
@@ -1755,6 +1857,9 @@ func (fsm *storeFSM) applyCreateRetentionPolicyCommand(cmd *internal.Command) in
 			ReplicaN:           int(pb.GetReplicaN()),
 			Duration:           time.Duration(pb.GetDuration()),
 			ShardGroupDuration: time.Duration(pb.GetShardGroupDuration()),
+			DownsampleTo:       pb.GetDownsampleTo(),
+			DownsampleInterval: time.Duration(pb.GetDownsampleInterval()),
+			DuplicatePolicy:    pb.GetDuplicatePolicy(),
 		}); err != nil {
 		return err
 	}
@@ -1791,6 +1896,62 @@ func (fsm *storeFSM) applySetDefaultRetentionPolicyCommand(cmd *internal.Command
 	return nil
 }
 
+func (fsm *storeFSM) applySetDatabaseReadOnlyCommand(cmd *internal.Command) interface{} {
+	ext, _ := proto.GetExtension(cmd, internal.E_SetDatabaseReadOnlyCommand_Command)
+	v := ext.(*internal.SetDatabaseReadOnlyCommand)
+
+	// Copy data and update.
+	other := fsm.data.Clone()
+	if err := other.SetDatabaseReadOnly(v.GetDatabase(), v.GetReadOnly()); err != nil {
+		return err
+	}
+	fsm.data = other
+
+	return nil
+}
+
+func (fsm *storeFSM) applyAddShardOwnerCommand(cmd *internal.Command) interface{} {
+	ext, _ := proto.GetExtension(cmd, internal.E_AddShardOwnerCommand_Command)
+	v := ext.(*internal.AddShardOwnerCommand)
+
+	// Copy data and update.
+	other := fsm.data.Clone()
+	if err := other.AddShardOwner(v.GetShardID(), v.GetNodeID()); err != nil {
+		return err
+	}
+	fsm.data = other
+
+	return nil
+}
+
+func (fsm *storeFSM) applyRemoveShardOwnerCommand(cmd *internal.Command) interface{} {
+	ext, _ := proto.GetExtension(cmd, internal.E_RemoveShardOwnerCommand_Command)
+	v := ext.(*internal.RemoveShardOwnerCommand)
+
+	// Copy data and update.
+	other := fsm.data.Clone()
+	if err := other.RemoveShardOwner(v.GetShardID(), v.GetNodeID()); err != nil {
+		return err
+	}
+	fsm.data = other
+
+	return nil
+}
+
+func (fsm *storeFSM) applySetNodeDecommissioningCommand(cmd *internal.Command) interface{} {
+	ext, _ := proto.GetExtension(cmd, internal.E_SetNodeDecommissioningCommand_Command)
+	v := ext.(*internal.SetNodeDecommissioningCommand)
+
+	// Copy data and update.
+	other := fsm.data.Clone()
+	if err := other.SetNodeDecommissioning(v.GetID(), v.GetDecommissioning()); err != nil {
+		return err
+	}
+	fsm.data = other
+
+	return nil
+}
+
 func (fsm *storeFSM) applyUpdateRetentionPolicyCommand(cmd *internal.Command) interface{} {
 	ext, _ := proto.GetExtension(cmd, internal.E_UpdateRetentionPolicyCommand_Command)
 	v := ext.(*internal.UpdateRetentionPolicyCommand)
@@ -1805,6 +1966,16 @@ func (fsm *storeFSM) applyUpdateRetentionPolicyCommand(cmd *internal.Command) in
 		value := int(v.GetReplicaN())
 		rpu.ReplicaN = &value
 	}
+	if v.DownsampleTo != nil {
+		rpu.DownsampleTo = v.DownsampleTo
+	}
+	if v.DownsampleInterval != nil {
+		value := time.Duration(v.GetDownsampleInterval())
+		rpu.DownsampleInterval = &value
+	}
+	if v.DuplicatePolicy != nil {
+		rpu.DuplicatePolicy = v.DuplicatePolicy
+	}
 
 	// Copy data and update.
 	other := fsm.data.Clone()
@@ -1956,7 +2127,7 @@ func (fsm *storeFSM) Snapshot() (raft.FSMSnapshot, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	return &storeFSMSnapshot{Data: (*Store)(fsm).data}, nil
+	return &storeFSMSnapshot{Data: (*Store)(fsm).data, store: s}, nil
 }
 
 func (fsm *storeFSM) Restore(r io.ReadCloser) error {
@@ -1981,7 +2152,8 @@ func (fsm *storeFSM) Restore(r io.ReadCloser) error {
 }
 
 type storeFSMSnapshot struct {
-	Data *Data
+	Data  *Data
+	store *Store
 }
 
 func (s *storeFSMSnapshot) Persist(sink raft.SnapshotSink) error {
@@ -2010,6 +2182,11 @@ func (s *storeFSMSnapshot) Persist(sink raft.SnapshotSink) error {
 		return err
 	}
 
+	// Record when the log was last compacted.
+	if s.store != nil {
+		atomic.StoreInt64(&s.store.lastSnapshot, time.Now().UnixNano())
+	}
+
 	return nil
 }
 
@@ -2020,15 +2197,18 @@ func (s *storeFSMSnapshot) Release() {}
 type raftLayer struct {
 	ln     net.Listener
 	addr   net.Addr
+	config *tls.Config
 	conn   chan net.Conn
 	closed chan struct{}
 }
 
-// newRaftLayer returns a new instance of raftLayer.
-func newRaftLayer(ln net.Listener, addr net.Addr) *raftLayer {
+// newRaftLayer returns a new instance of raftLayer. Connections are dialed
+// over TLS if config is not nil.
+func newRaftLayer(ln net.Listener, addr net.Addr, config *tls.Config) *raftLayer {
 	return &raftLayer{
 		ln:     ln,
 		addr:   addr,
+		config: config,
 		conn:   make(chan net.Conn),
 		closed: make(chan struct{}),
 	}
@@ -2039,18 +2219,8 @@ func (l *raftLayer) Addr() net.Addr { return l.addr }
 
 // Dial creates a new network connection.
 func (l *raftLayer) Dial(addr string, timeout time.Duration) (net.Conn, error) {
-	conn, err := net.DialTimeout("tcp", addr, timeout)
-	if err != nil {
-		return nil, err
-	}
-
 	// Write a marker byte for raft messages.
-	_, err = conn.Write([]byte{MuxRaftHeader})
-	if err != nil {
-		conn.Close()
-		return nil, err
-	}
-	return conn, err
+	return tcp.DialTLS("tcp", addr, MuxRaftHeader, timeout, l.config)
 }
 
 // Accept waits for the next connection.
@@ -2064,11 +2234,21 @@ type RetentionPolicyUpdate struct {
 	Name     *string
 	Duration *time.Duration
 	ReplicaN *int
+
+	// DownsampleTo is set to an empty string to stop downsampling.
+	DownsampleTo       *string
+	DownsampleInterval *time.Duration
+
+	DuplicatePolicy *string
 }
 
 func (rpu *RetentionPolicyUpdate) SetName(v string)            { rpu.Name = &v }
 func (rpu *RetentionPolicyUpdate) SetDuration(v time.Duration) { rpu.Duration = &v }
 func (rpu *RetentionPolicyUpdate) SetReplicaN(v int)           { rpu.ReplicaN = &v }
+func (rpu *RetentionPolicyUpdate) SetDuplicatePolicy(v string) { rpu.DuplicatePolicy = &v }
+func (rpu *RetentionPolicyUpdate) SetDownsample(to string, interval time.Duration) {
+	rpu.DownsampleTo, rpu.DownsampleInterval = &to, &interval
+}
 
 // assert will panic with a given formatted message if the given condition is false.
 func assert(condition bool, msg string, v ...interface{}) {
@@ -96,6 +96,9 @@ const (
 	// the number of characters for the smallest possible int64 (-9223372036854775808)
 	minInt64Digits = 20
 
+	// the number of characters for the largest possible uint64 (18446744073709551615)
+	maxUint64Digits = 20
+
 	// the number of characters required for the largest float64 before a range check
 	// would occur during parsing
 	maxFloat64Digits = 25
@@ -551,11 +554,11 @@ func isNumeric(b byte) bool {
 }
 
 // scanNumber returns the end position within buf, start at i after
-// scanning over buf for an integer, or float.  It returns an
-// error if a invalid number is scanned.
+// scanning over buf for an integer, unsigned integer, nanosecond duration, or
+// float.  It returns an error if a invalid number is scanned.
 func scanNumber(buf []byte, i int) (int, error) {
 	start := i
-	var isInt bool
+	var isInt, isUnsigned, isDuration bool
 
 	// Is negative number?
 	if i < len(buf) && buf[i] == '-' {
@@ -577,12 +580,24 @@ func scanNumber(buf []byte, i int) (int, error) {
 			break
 		}
 
-		if buf[i] == 'i' && i > start && !isInt {
+		if buf[i] == 'i' && i > start && !isInt && !isUnsigned && !isDuration {
 			isInt = true
 			i += 1
 			continue
 		}
 
+		if buf[i] == 'u' && i > start && !isInt && !isUnsigned && !isDuration {
+			isUnsigned = true
+			i += 1
+			continue
+		}
+
+		if buf[i] == 'n' && i+1 < len(buf) && buf[i+1] == 's' && i > start && !isInt && !isUnsigned && !isDuration {
+			isDuration = true
+			i += 2
+			continue
+		}
+
 		if buf[i] == '.' {
 			decimals += 1
 		}
@@ -618,7 +633,7 @@ func scanNumber(buf []byte, i int) (int, error) {
 		}
 		i += 1
 	}
-	if isInt && (decimals > 0 || scientific) {
+	if (isInt || isUnsigned || isDuration) && (decimals > 0 || scientific) {
 		return i, fmt.Errorf("invalid number")
 	}
 
@@ -638,6 +653,34 @@ func scanNumber(buf []byte, i int) (int, error) {
 				return i, fmt.Errorf("unable to parse integer %s: %s", buf[start:i-1], err)
 			}
 		}
+	} else if isUnsigned {
+		// Make sure the last char is a 'u' for unsigned integers (e.g. 9u10 is not valid)
+		if buf[i-1] != 'u' {
+			return i, fmt.Errorf("invalid number")
+		}
+		// Unsigned integers can't be negative
+		if buf[start] == '-' {
+			return i, fmt.Errorf("invalid unsigned integer %s: negative value", buf[start:i-1])
+		}
+		// Parse the uint to check bounds the number of digits could be larger than the max range
+		// We subtract 1 from the index to remove the `u` from our tests
+		if len(buf[start:i-1]) >= maxUint64Digits {
+			if _, err := strconv.ParseUint(string(buf[start:i-1]), 10, 64); err != nil {
+				return i, fmt.Errorf("unable to parse unsigned integer %s: %s", buf[start:i-1], err)
+			}
+		}
+	} else if isDuration {
+		// Make sure the last chars are "ns" for durations (e.g. 9ns10 is not valid)
+		if buf[i-1] != 's' || buf[i-2] != 'n' {
+			return i, fmt.Errorf("invalid number")
+		}
+		// Parse the duration to check bounds the number of digits could be larger than the max range
+		// We subtract 2 from the index to remove the `ns` from our tests
+		if len(buf[start:i-2]) >= maxInt64Digits || len(buf[start:i-2]) >= minInt64Digits {
+			if _, err := strconv.ParseInt(string(buf[start:i-2]), 10, 64); err != nil {
+				return i, fmt.Errorf("unable to parse duration %s: %s", buf[start:i-2], err)
+			}
+		}
 	} else {
 		// Parse the float to check bounds if it's scientific or the number of digits could be larger than the max range
 		if scientific || len(buf[start:i]) >= maxFloat64Digits || len(buf[start:i]) >= minFloat64Digits {
@@ -1176,6 +1219,15 @@ func parseNumber(val []byte) (interface{}, error) {
 		val = val[:len(val)-1]
 		return strconv.ParseInt(string(val), 10, 64)
 	}
+	if val[len(val)-1] == 'u' {
+		val = val[:len(val)-1]
+		return strconv.ParseUint(string(val), 10, 64)
+	}
+	if len(val) > 2 && val[len(val)-2] == 'n' && val[len(val)-1] == 's' {
+		val = val[:len(val)-2]
+		n, err := strconv.ParseInt(string(val), 10, 64)
+		return time.Duration(n), err
+	}
 	for i := 0; i < len(val); i++ {
 		// If there is a decimal or an N (NaN), I (Inf), parse as float
 		if val[i] == '.' || val[i] == 'N' || val[i] == 'n' || val[i] == 'I' || val[i] == 'i' || val[i] == 'e' {
@@ -1241,8 +1293,8 @@ func newFieldsFromBinary(buf []byte) Fields {
 
 // MarshalBinary encodes all the fields to their proper type and returns the binary
 // represenation
-// NOTE: uint64 is specifically not supported due to potential overflow when we decode
-// again later to an int64
+// NOTE: uint64 values are encoded with a 'u' suffix so they decode back to a uint64
+// rather than overflowing an int64, and durations with an 'ns' suffix
 func (p Fields) MarshalBinary() []byte {
 	b := []byte{}
 	keys := make([]string, len(p))
@@ -1285,6 +1337,12 @@ func (p Fields) MarshalBinary() []byte {
 		case uint32:
 			b = append(b, []byte(strconv.FormatInt(int64(t), 10))...)
 			b = append(b, 'i')
+		case uint64:
+			b = append(b, []byte(strconv.FormatUint(t, 10))...)
+			b = append(b, 'u')
+		case time.Duration:
+			b = append(b, []byte(strconv.FormatInt(int64(t), 10))...)
+			b = append(b, 'n', 's')
 		case float32:
 			val := []byte(strconv.FormatFloat(float64(t), 'f', -1, 32))
 			b = append(b, val...)
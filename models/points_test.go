@@ -358,6 +358,78 @@ func TestParsePointMinInt64(t *testing.T) {
 	}
 }
 
+func TestParsePointMaxUint64(t *testing.T) {
+	// out of range
+	_, err := models.ParsePointsString(`cpu,host=serverA,region=us-west value=18446744073709551616u`)
+	exp := `unable to parse 'cpu,host=serverA,region=us-west value=18446744073709551616u': unable to parse unsigned integer 18446744073709551616: strconv.ParseUint: parsing "18446744073709551616": value out of range`
+	if err == nil || (err != nil && err.Error() != exp) {
+		t.Fatalf("Error mismatch:\nexp: %s\ngot: %v", exp, err)
+	}
+
+	// max uint
+	p, err := models.ParsePointsString(`cpu,host=serverA,region=us-west value=18446744073709551615u`)
+	if err != nil {
+		t.Fatalf(`ParsePoints("%s") mismatch. got %v, exp nil`, `cpu,host=serverA,region=us-west value=18446744073709551615u`, err)
+	}
+	if exp, got := uint64(18446744073709551615), p[0].Fields()["value"].(uint64); exp != got {
+		t.Fatalf("ParsePoints Value mistmatch. \nexp: %v\ngot: %v", exp, got)
+	}
+
+	// negative
+	_, err = models.ParsePointsString(`cpu,host=serverA,region=us-west value=-1u`)
+	if err == nil {
+		t.Errorf(`ParsePoints("%s") mismatch. got nil, exp error`, `cpu,host=serverA,region=us-west value=-1u`)
+	}
+
+	// mixed suffixes
+	_, err = models.ParsePointsString(`cpu,host=serverA,region=us-west value=1iu`)
+	if err == nil {
+		t.Errorf(`ParsePoints("%s") mismatch. got nil, exp error`, `cpu,host=serverA,region=us-west value=1iu`)
+	}
+
+	// decimals
+	_, err = models.ParsePointsString(`cpu,host=serverA,region=us-west value=1.0u`)
+	if err == nil {
+		t.Errorf(`ParsePoints("%s") mismatch. got nil, exp error`, `cpu,host=serverA,region=us-west value=1.0u`)
+	}
+}
+
+func TestParsePointDuration(t *testing.T) {
+	// out of range
+	_, err := models.ParsePointsString(`cpu,host=serverA,region=us-west value=9223372036854775808ns`)
+	exp := `unable to parse 'cpu,host=serverA,region=us-west value=9223372036854775808ns': unable to parse duration 9223372036854775808: strconv.ParseInt: parsing "9223372036854775808": value out of range`
+	if err == nil || (err != nil && err.Error() != exp) {
+		t.Fatalf("Error mismatch:\nexp: %s\ngot: %v", exp, err)
+	}
+
+	// negative
+	p, err := models.ParsePointsString(`cpu,host=serverA,region=us-west value=-1500ns,other=1i`)
+	if err != nil {
+		t.Fatalf(`ParsePoints("%s") mismatch. got %v, exp nil`, `cpu,host=serverA,region=us-west value=-1500ns,other=1i`, err)
+	}
+	if exp, got := -1500*time.Nanosecond, p[0].Fields()["value"].(time.Duration); exp != got {
+		t.Fatalf("ParsePoints Value mistmatch. \nexp: %v\ngot: %v", exp, got)
+	}
+
+	// mixed suffixes
+	_, err = models.ParsePointsString(`cpu,host=serverA,region=us-west value=1ins`)
+	if err == nil {
+		t.Errorf(`ParsePoints("%s") mismatch. got nil, exp error`, `cpu,host=serverA,region=us-west value=1ins`)
+	}
+
+	// decimals
+	_, err = models.ParsePointsString(`cpu,host=serverA,region=us-west value=1.5ns`)
+	if err == nil {
+		t.Errorf(`ParsePoints("%s") mismatch. got nil, exp error`, `cpu,host=serverA,region=us-west value=1.5ns`)
+	}
+
+	// suffix not at the end
+	_, err = models.ParsePointsString(`cpu,host=serverA,region=us-west value=1ns5`)
+	if err == nil {
+		t.Errorf(`ParsePoints("%s") mismatch. got nil, exp error`, `cpu,host=serverA,region=us-west value=1ns5`)
+	}
+}
+
 func TestParsePointMaxFloat64(t *testing.T) {
 	// out of range
 	_, err := models.ParsePointsString(fmt.Sprintf(`cpu,host=serverA,region=us-west value=%s`, "1"+string(maxFloat64)))
@@ -999,6 +1071,30 @@ func TestNewPointLargeInteger(t *testing.T) {
 	)
 }
 
+func TestNewPointLargeUnsigned(t *testing.T) {
+	test(t, `cpu value=18446744073709551615u 1000000000`,
+		models.NewPoint(
+			"cpu",
+			models.Tags{},
+			models.Fields{
+				"value": uint64(18446744073709551615),
+			},
+			time.Unix(1, 0)),
+	)
+}
+
+func TestNewPointDuration(t *testing.T) {
+	test(t, `cpu value=1500ns 1000000000`,
+		models.NewPoint(
+			"cpu",
+			models.Tags{},
+			models.Fields{
+				"value": 1500 * time.Nanosecond,
+			},
+			time.Unix(1, 0)),
+	)
+}
+
 func TestNewPointNaN(t *testing.T) {
 	test(t, `cpu value=NaN 1000000000`,
 		models.NewPoint(
@@ -1049,7 +1145,7 @@ func TestNewPointLargeNumberOfTags(t *testing.T) {
 }
 
 func TestParsePointIntsFloats(t *testing.T) {
-	pts, err := models.ParsePoints([]byte(`cpu,host=serverA,region=us-east int=10i,float=11.0,float2=12.1 1000000000`))
+	pts, err := models.ParsePoints([]byte(`cpu,host=serverA,region=us-east int=10i,uint=10u,float=11.0,float2=12.1 1000000000`))
 	if err != nil {
 		t.Fatalf(`ParsePoints() failed. got %s`, err)
 	}
@@ -1063,6 +1159,10 @@ func TestParsePointIntsFloats(t *testing.T) {
 		t.Errorf("ParsePoint() int field mismatch: got %T, exp %T", pt.Fields()["int"], int64(10))
 	}
 
+	if _, ok := pt.Fields()["uint"].(uint64); !ok {
+		t.Errorf("ParsePoint() uint field mismatch: got %T, exp %T", pt.Fields()["uint"], uint64(10))
+	}
+
 	if _, ok := pt.Fields()["float"].(float64); !ok {
 		t.Errorf("ParsePoint() float field mismatch: got %T, exp %T", pt.Fields()["float64"], float64(11.0))
 	}
@@ -0,0 +1,68 @@
+package monitor
+
+import (
+	"strings"
+	"time"
+
+	"github.com/influxdb/influxdb/meta"
+)
+
+// raft captures the state of the meta store's raft log.
+type raft struct {
+	status func() (*meta.RaftStatus, error)
+}
+
+func (r *raft) Diagnostics() (*Diagnostic, error) {
+	st, err := r.status()
+	if err != nil {
+		return nil, err
+	}
+
+	diagnostics := map[string]interface{}{
+		"state":               st.State,
+		"term":                st.Term,
+		"last_index":          st.LastIndex,
+		"commit_index":        st.CommitIndex,
+		"applied_index":       st.AppliedIndex,
+		"last_snapshot_index": st.LastSnapshotIndex,
+		"last_snapshot":       "",
+		"snapshot_age":        "",
+		"peers":               strings.Join(st.Peers, ","),
+	}
+	if !st.LastSnapshot.IsZero() {
+		diagnostics["last_snapshot"] = st.LastSnapshot.UTC()
+		diagnostics["snapshot_age"] = time.Since(st.LastSnapshot).String()
+	}
+
+	return DiagnosticFromMap(diagnostics), nil
+}
+
+// raftStatistic returns the raft statistic with the given tags. Snapshot age
+// is -1 if there are no snapshots.
+func raftStatistic(st *meta.RaftStatus, tags map[string]string) *statistic {
+	age := float64(-1)
+	if !st.LastSnapshot.IsZero() {
+		age = time.Since(st.LastSnapshot).Seconds()
+	}
+
+	s := &statistic{
+		Name: "raft",
+		Tags: make(map[string]string),
+	}
+	for k, v := range tags {
+		s.Tags[k] = v
+	}
+
+	s.Values = map[string]interface{}{
+		"term":                int64(st.Term),
+		"last_index":          int64(st.LastIndex),
+		"commit_index":        int64(st.CommitIndex),
+		"applied_index":       int64(st.AppliedIndex),
+		"last_snapshot_index": int64(st.LastSnapshotIndex),
+		"snapshot_age":        age,
+		"peers":               int64(len(st.Peers)),
+		"apply":               st.ApplyN,
+		"apply_duration":      st.ApplyDuration.Seconds(),
+	}
+	return s
+}
@@ -91,6 +91,7 @@ type Monitor struct {
 		CreateRetentionPolicyIfNotExists(database string, rpi *meta.RetentionPolicyInfo) (*meta.RetentionPolicyInfo, error)
 		SetDefaultRetentionPolicy(database, name string) error
 		DropRetentionPolicy(database, name string) error
+		RaftStatus() (*meta.RaftStatus, error)
 	}
 
 	PointsWriter interface {
@@ -127,6 +128,7 @@ func (m *Monitor) Open() error {
 	m.RegisterDiagnosticsClient("runtime", &goRuntime{})
 	m.RegisterDiagnosticsClient("network", &network{})
 	m.RegisterDiagnosticsClient("system", &system{})
+	m.RegisterDiagnosticsClient("raft", &raft{status: m.MetaStore.RaftStatus})
 
 	// If enabled, record stats in a InfluxDB system.
 	if m.storeEnabled {
@@ -276,6 +278,12 @@ func (m *Monitor) Statistics(tags map[string]string) ([]*statistic, error) {
 	}
 	statistics = append(statistics, statistic)
 
+	// Add the state of the meta store's raft log. It's read on demand because
+	// most of the values are kept by raft rather than counted.
+	if st, err := m.MetaStore.RaftStatus(); err == nil {
+		statistics = append(statistics, raftStatistic(st, tags))
+	}
+
 	return statistics, nil
 }
 
@@ -37,6 +37,44 @@ func Test_RegisterStats(t *testing.T) {
 	}
 }
 
+// Test that the raft state is included in SHOW STATS and SHOW DIAGNOSTICS.
+func Test_RaftStatus(t *testing.T) {
+	monitor := openMonitor(t)
+	executor := &StatementExecutor{Monitor: monitor}
+
+	r := executor.ExecuteStatement(&influxql.ShowStatsStatement{Module: "raft"})
+	if r.Err != nil {
+		t.Fatal(r.Err)
+	} else if len(r.Series) != 1 {
+		t.Fatalf("unexpected series: %#v", r.Series)
+	}
+	values := make(map[string]interface{})
+	for i, c := range r.Series[0].Columns {
+		values[c] = r.Series[0].Values[0][i]
+	}
+	if values["term"] != int64(2) || values["last_index"] != int64(10) || values["applied_index"] != int64(9) || values["peers"] != int64(2) {
+		t.Fatalf("unexpected values: %v", values)
+	} else if age := values["snapshot_age"].(float64); age < 60 || age > 120 {
+		t.Fatalf("unexpected snapshot age: %v", age)
+	}
+
+	diags, err := monitor.Diagnostics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := diags["raft"]
+	if d == nil {
+		t.Fatal("raft diagnostics not registered")
+	}
+	for i, c := range d.Columns {
+		if c == "peers" && d.Rows[0][i] != "host1:8088,host2:8088" {
+			t.Fatalf("unexpected peers: %v", d.Rows[0][i])
+		} else if c == "state" && d.Rows[0][i] != "Leader" {
+			t.Fatalf("unexpected state: %v", d.Rows[0][i])
+		}
+	}
+}
+
 type mockMetastore struct{}
 
 func (m *mockMetastore) ClusterID() (uint64, error)                            { return 1, nil }
@@ -51,6 +89,18 @@ func (m *mockMetastore) CreateRetentionPolicyIfNotExists(database string, rpi *m
 	return nil, nil
 }
 
+func (m *mockMetastore) RaftStatus() (*meta.RaftStatus, error) {
+	return &meta.RaftStatus{
+		State:        "Leader",
+		Term:         2,
+		LastIndex:    10,
+		CommitIndex:  10,
+		AppliedIndex: 9,
+		LastSnapshot: time.Now().Add(-time.Minute),
+		Peers:        []string{"host1:8088", "host2:8088"},
+	}, nil
+}
+
 func openMonitor(t *testing.T) *Monitor {
 	monitor := New(NewConfig())
 	monitor.MetaStore = &mockMetastore{}
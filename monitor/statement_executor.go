@@ -3,9 +3,11 @@ package monitor
 import (
 	"fmt"
 	"sort"
+	"time"
 
 	"github.com/influxdb/influxdb/influxql"
 	"github.com/influxdb/influxdb/models"
+	"github.com/influxdb/influxdb/services/hh"
 )
 
 // StatementExecutor translates InfluxQL queries to Monitor methods.
@@ -14,6 +16,13 @@ type StatementExecutor struct {
 		Statistics(map[string]string) ([]*statistic, error)
 		Diagnostics() (map[string]*Diagnostic, error)
 	}
+
+	HintedHandoff interface {
+		Status() ([]hh.QueueStatus, error)
+		Purge(nodeID uint64) error
+		Pause(nodeID uint64) error
+		Resume(nodeID uint64) error
+	}
 }
 
 // ExecuteStatement executes monitor-related query statements.
@@ -23,6 +32,14 @@ func (s *StatementExecutor) ExecuteStatement(stmt influxql.Statement) *influxql.
 		return s.executeShowStatistics(stmt.Module)
 	case *influxql.ShowDiagnosticsStatement:
 		return s.executeShowDiagnostics(stmt.Module)
+	case *influxql.ShowHintedHandoffStatement:
+		return s.executeShowHintedHandoff(time.Now())
+	case *influxql.PurgeHintedHandoffStatement:
+		return &influxql.Result{Err: s.HintedHandoff.Purge(stmt.NodeID)}
+	case *influxql.PauseHintedHandoffStatement:
+		return &influxql.Result{Err: s.HintedHandoff.Pause(stmt.NodeID)}
+	case *influxql.ResumeHintedHandoffStatement:
+		return &influxql.Result{Err: s.HintedHandoff.Resume(stmt.NodeID)}
 	default:
 		panic(fmt.Sprintf("unsupported statement type: %T", stmt))
 	}
@@ -79,3 +96,20 @@ func (s *StatementExecutor) executeShowDiagnostics(module string) *influxql.Resu
 	}
 	return &influxql.Result{Series: rows}
 }
+
+func (s *StatementExecutor) executeShowHintedHandoff(now time.Time) *influxql.Result {
+	a, err := s.HintedHandoff.Status()
+	if err != nil {
+		return &influxql.Result{Err: err}
+	}
+
+	row := &models.Row{Columns: []string{"node_id", "size", "oldest_age", "paused", "last_error"}}
+	for _, qs := range a {
+		var age string
+		if !qs.Oldest.IsZero() {
+			age = now.Sub(qs.Oldest).String()
+		}
+		row.Values = append(row.Values, []interface{}{qs.NodeID, qs.Size, age, qs.Paused, qs.LastError})
+	}
+	return &influxql.Result{Series: []*models.Row{row}}
+}
@@ -0,0 +1,48 @@
+package antientropy
+
+import (
+	"errors"
+	"time"
+
+	"github.com/influxdb/influxdb/toml"
+	"github.com/influxdb/influxdb/tsdb"
+)
+
+const (
+	// DefaultCheckInterval is how often the replicas of shards are compared.
+	DefaultCheckInterval = 30 * time.Minute
+
+	// DefaultDigestDepth is the depth of the digest trees. Each series' time
+	// range is split into 2^depth ranges that are compared separately.
+	DefaultDigestDepth = 4
+)
+
+// Config represents the configuration for the anti-entropy service.
+type Config struct {
+	Enabled       bool          `toml:"enabled"`
+	CheckInterval toml.Duration `toml:"check-interval"`
+	DigestDepth   int           `toml:"digest-depth"`
+}
+
+// NewConfig returns a new Config with defaults.
+func NewConfig() Config {
+	return Config{
+		Enabled:       false,
+		CheckInterval: toml.Duration(DefaultCheckInterval),
+		DigestDepth:   DefaultDigestDepth,
+	}
+}
+
+// Validate returns an error if the config is invalid.
+func (c Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.CheckInterval <= 0 {
+		return errors.New("check-interval must be positive")
+	}
+	if c.DigestDepth < 0 || c.DigestDepth > tsdb.MaxDigestDepth {
+		return errors.New("digest-depth must be between 0 and 16")
+	}
+	return nil
+}
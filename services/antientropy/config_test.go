@@ -0,0 +1,43 @@
+package antientropy_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/influxdb/influxdb/services/antientropy"
+)
+
+func TestConfig_Parse(t *testing.T) {
+	// Parse configuration.
+	var c antientropy.Config
+	if _, err := toml.Decode(`
+enabled = true
+check-interval = "1h"
+digest-depth = 6
+`, &c); err != nil {
+		t.Fatal(err)
+	}
+
+	// Validate configuration.
+	if !c.Enabled {
+		t.Fatalf("unexpected enabled state: %v", c.Enabled)
+	} else if time.Duration(c.CheckInterval) != time.Hour {
+		t.Fatalf("unexpected check interval: %s", c.CheckInterval)
+	} else if c.DigestDepth != 6 {
+		t.Fatalf("unexpected digest depth: %d", c.DigestDepth)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	c := antientropy.NewConfig()
+	if err := c.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	c.Enabled = true
+	c.DigestDepth = 20
+	if err := c.Validate(); err == nil {
+		t.Fatal("expected error for digest depth")
+	}
+}
@@ -0,0 +1,274 @@
+package antientropy
+
+import (
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/influxdb/influxdb/meta"
+	"github.com/influxdb/influxdb/models"
+	"github.com/influxdb/influxdb/tsdb"
+)
+
+// Service periodically compares the local replicas of shards with the
+// replicas on the other owners and writes the points missing on either side.
+// Replicas are compared through per-series digests so only the time ranges
+// that differ are transferred. Points with the same timestamp but different
+// values are left as they are since neither replica is known to be right.
+//
+// Each node compares its replica with the other owners whenever its replica
+// changed since it was last verified, so idle shards aren't reopened on every
+// check. Verified replicas are only tracked in memory and are compared again
+// after a restart.
+type Service struct {
+	MetaStore interface {
+		NodeID() uint64
+		Databases() ([]meta.DatabaseInfo, error)
+	}
+	TSDBStore interface {
+		CreateShard(database, policy string, shardID uint64) error
+		ShardLastModified(shardID uint64) (time.Time, error)
+		WriteToShard(shardID uint64, points []models.Point) error
+		ShardDigest(shardID uint64, min, max int64, depth int) ([]*tsdb.SeriesDigest, error)
+		ShardSeriesPoints(shardID uint64, key string, min, max int64) ([]models.Point, error)
+	}
+	ShardReader interface {
+		ShardDigest(ownerID, shardID uint64, min, max int64, depth int) ([]*tsdb.SeriesDigest, error)
+		SeriesPoints(ownerID, shardID uint64, key string, min, max int64) ([]models.Point, error)
+	}
+	ShardWriter interface {
+		WriteShard(shardID, ownerID uint64, points []models.Point) error
+	}
+
+	checkInterval time.Duration
+	depth         int
+	verified      map[replica]time.Time
+	wg            sync.WaitGroup
+	done          chan struct{}
+
+	logger *log.Logger
+}
+
+// NewService returns a configured anti-entropy service.
+func NewService(c Config) *Service {
+	return &Service{
+		checkInterval: time.Duration(c.CheckInterval),
+		depth:         c.DigestDepth,
+		verified:      make(map[replica]time.Time),
+		logger:        log.New(os.Stderr, "[anti-entropy] ", log.LstdFlags),
+	}
+}
+
+// Open starts comparing replicas.
+func (s *Service) Open() error {
+	if s.done != nil {
+		return nil
+	}
+
+	s.logger.Printf("Starting anti-entropy service with check interval of %s, digest depth of %d",
+		s.checkInterval, s.depth)
+
+	s.done = make(chan struct{})
+
+	s.wg.Add(1)
+	go s.run()
+	return nil
+}
+
+// Close stops the service.
+func (s *Service) Close() error {
+	if s.done == nil {
+		return nil
+	}
+
+	close(s.done)
+	s.wg.Wait()
+	s.done = nil
+	return nil
+}
+
+// SetLogger sets the internal logger to the logger passed in.
+func (s *Service) SetLogger(l *log.Logger) {
+	s.logger = l
+}
+
+func (s *Service) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			if err := s.check(time.Now().UTC()); err != nil {
+				s.logger.Printf("anti-entropy check failed: %s", err)
+			}
+		}
+	}
+}
+
+// check repairs the replicas of every shard owned by the local node. Shard
+// groups that haven't ended are skipped since they're still being written to,
+// as are replicas that haven't changed since they were last verified.
+func (s *Service) check(now time.Time) error {
+	dbs, err := s.MetaStore.Databases()
+	if err != nil {
+		return err
+	}
+
+	// Only keep the verified replicas of shards that are still owned.
+	verified := make(map[replica]time.Time)
+	defer func() { s.verified = verified }()
+
+	id := s.MetaStore.NodeID()
+	for _, di := range dbs {
+		for _, rpi := range di.RetentionPolicies {
+			for _, sgi := range rpi.ShardGroups {
+				if sgi.Deleted() || sgi.EndTime.After(now) {
+					continue
+				}
+				for _, si := range sgi.Shards {
+					if !si.OwnedBy(id) {
+						continue
+					}
+					for _, so := range si.Owners {
+						if so.NodeID == id {
+							continue
+						}
+
+						r := replica{shardID: si.ID, ownerID: so.NodeID}
+						mod, err := s.TSDBStore.ShardLastModified(si.ID)
+						if err == nil && mod.Equal(s.verified[r]) {
+							verified[r] = mod
+							continue
+						}
+
+						if err := s.repair(di.Name, rpi.Name, &sgi, si.ID, so.NodeID); err != nil {
+							s.logger.Printf("repair of shard %d with node %d failed: %s", si.ID, so.NodeID, err)
+							continue
+						}
+
+						// Points pulled during the repair change the replica
+						// so it's marked as verified afterwards.
+						if mod, err := s.TSDBStore.ShardLastModified(si.ID); err == nil {
+							verified[r] = mod
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// repair compares the local replica of a shard with the replica on ownerID
+// and writes the missing points to each side.
+func (s *Service) repair(database, policy string, sgi *meta.ShardGroupInfo, shardID, ownerID uint64) error {
+	min, max := sgi.StartTime.UnixNano(), sgi.EndTime.UnixNano()
+
+	local, err := s.TSDBStore.ShardDigest(shardID, min, max, s.depth)
+	if err == tsdb.ErrShardNotFound {
+		// The shard was lost so it's restored from the other owner.
+		if err := s.TSDBStore.CreateShard(database, policy, shardID); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	remote, err := s.ShardReader.ShardDigest(ownerID, shardID, min, max, s.depth)
+	if err != nil {
+		return err
+	}
+
+	// Index the digests by series key.
+	digests := make(map[string][2]*tsdb.SeriesDigest)
+	for _, d := range local {
+		a := digests[d.Key]
+		a[0] = d
+		digests[d.Key] = a
+	}
+	for _, d := range remote {
+		a := digests[d.Key]
+		a[1] = d
+		digests[d.Key] = a
+	}
+
+	keys := make([]string, 0, len(digests))
+	for key := range digests {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var pulled, pushed int
+	for _, key := range keys {
+		a := digests[key]
+		var leaves []int
+		if a[0] == nil {
+			leaves = a[1].Diff(nil)
+		} else {
+			leaves = a[0].Diff(a[1])
+		}
+
+		for _, i := range leaves {
+			lmin, lmax := tsdb.DigestRange(min, max, s.depth, i)
+			m, n, err := s.repairRange(shardID, ownerID, key, lmin, lmax)
+			if err != nil {
+				return err
+			}
+			pulled, pushed = pulled+m, pushed+n
+		}
+	}
+
+	if pulled > 0 || pushed > 0 {
+		s.logger.Printf("repaired shard %d with node %d: %d points pulled, %d points pushed",
+			shardID, ownerID, pulled, pushed)
+	}
+	return nil
+}
+
+// repairRange exchanges the points of a series in a time range that are
+// missing from or differ between the replicas. Returns the number of points written locally
+// and to ownerID.
+func (s *Service) repairRange(shardID, ownerID uint64, key string, min, max int64) (int, int, error) {
+	local, err := s.TSDBStore.ShardSeriesPoints(shardID, key, min, max)
+	if err != nil {
+		return 0, 0, err
+	}
+	remote, err := s.ShardReader.SeriesPoints(ownerID, shardID, key, min, max)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Points only on one replica are copied to the other. Points that differ
+	// take the value of the owner with the lowest ID so that both owners
+	// repair them the same way.
+	pull, theirs := tsdb.DiffPoints(remote, local)
+	push, ours := tsdb.DiffPoints(local, remote)
+	if s.MetaStore.NodeID() < ownerID {
+		push = append(push, ours...)
+	} else {
+		pull = append(pull, theirs...)
+	}
+	if len(pull) > 0 {
+		if err := s.TSDBStore.WriteToShard(shardID, pull); err != nil {
+			return 0, 0, err
+		}
+	}
+	if len(push) > 0 {
+		if err := s.ShardWriter.WriteShard(shardID, ownerID, push); err != nil {
+			return 0, 0, err
+		}
+	}
+	return len(pull), len(push), nil
+}
+
+// replica identifies the replica of a shard on another owner.
+type replica struct {
+	shardID uint64
+	ownerID uint64
+}
@@ -0,0 +1,300 @@
+package antientropy
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/influxdb/influxdb/meta"
+	"github.com/influxdb/influxdb/models"
+	"github.com/influxdb/influxdb/toml"
+	"github.com/influxdb/influxdb/tsdb"
+)
+
+var (
+	start = time.Date(2015, 10, 1, 0, 0, 0, 0, time.UTC)
+	end   = start.Add(time.Hour)
+)
+
+// Ensure points missing from either replica are written to it.
+func TestService_check(t *testing.T) {
+	s, local, remote := NewTestService([]uint64{1, 2})
+
+	local.write(point("serverA", 1), point("serverA", 40), point("serverB", 10))
+	remote.write(point("serverA", 1), point("serverA", 55), point("serverC", 20))
+
+	if err := s.check(end); err != nil {
+		t.Fatal(err)
+	}
+
+	if exp := []string{
+		fmt.Sprintf("write 1 %s", point("serverA", 55)),
+		fmt.Sprintf("write 1 %s", point("serverC", 20)),
+	}; !reflect.DeepEqual(local.calls, exp) {
+		t.Fatalf("unexpected local writes:\n\ngot=%v\n\nexp=%v", local.calls, exp)
+	}
+	if exp := []string{
+		fmt.Sprintf("write 1 %s", point("serverA", 40)),
+		fmt.Sprintf("write 1 %s", point("serverB", 10)),
+	}; !reflect.DeepEqual(remote.calls, exp) {
+		t.Fatalf("unexpected remote writes:\n\ngot=%v\n\nexp=%v", remote.calls, exp)
+	}
+
+	// The replicas are equal after the repair.
+	local.calls, remote.calls = nil, nil
+	if err := s.check(end); err != nil {
+		t.Fatal(err)
+	} else if local.calls != nil || remote.calls != nil {
+		t.Fatalf("unexpected writes: %v %v", local.calls, remote.calls)
+	}
+}
+
+// Ensure points that differ between replicas take the value of the owner
+// with the lowest ID.
+func TestService_check_Conflict(t *testing.T) {
+	conflict := models.NewPoint("cpu", models.Tags{"host": "serverA"}, models.Fields{"value": float64(99)}, start.Add(time.Minute))
+
+	// The local owner has the lowest ID so its point is pushed.
+	s, local, remote := NewTestService([]uint64{1, 2})
+	local.write(point("serverA", 1))
+	remote.write(conflict)
+	if err := s.check(end); err != nil {
+		t.Fatal(err)
+	} else if local.calls != nil {
+		t.Fatalf("unexpected local writes: %v", local.calls)
+	} else if exp := []string{fmt.Sprintf("write 1 %s", point("serverA", 1))}; !reflect.DeepEqual(remote.calls, exp) {
+		t.Fatalf("unexpected remote writes: %v", remote.calls)
+	}
+
+	// The replicas are equal after the repair.
+	local.calls, remote.calls = nil, nil
+	if err := s.check(end); err != nil {
+		t.Fatal(err)
+	} else if local.calls != nil || remote.calls != nil {
+		t.Fatalf("unexpected writes: %v %v", local.calls, remote.calls)
+	}
+
+	// The remote owner has the lowest ID so its point is pulled.
+	s, local, remote = NewTestService([]uint64{3, 2})
+	s.MetaStore.(*metaStore).id = 3
+	local.write(point("serverA", 1))
+	remote.write(conflict)
+	if err := s.check(end); err != nil {
+		t.Fatal(err)
+	} else if exp := []string{fmt.Sprintf("write 1 %s", conflict)}; !reflect.DeepEqual(local.calls, exp) {
+		t.Fatalf("unexpected local writes: %v", local.calls)
+	} else if remote.calls != nil {
+		t.Fatalf("unexpected remote writes: %v", remote.calls)
+	}
+}
+
+// Ensure a lost shard is created and restored from the other owner.
+func TestService_check_ShardNotFound(t *testing.T) {
+	s, local, remote := NewTestService([]uint64{1, 2})
+	local.shards = nil
+	remote.write(point("serverA", 1))
+
+	if err := s.check(end); err != nil {
+		t.Fatal(err)
+	} else if exp := []string{
+		"create db0 default 1",
+		fmt.Sprintf("write 1 %s", point("serverA", 1)),
+	}; !reflect.DeepEqual(local.calls, exp) {
+		t.Fatalf("unexpected local calls: %v", local.calls)
+	}
+}
+
+// Ensure shards are only compared once their shard group has ended.
+func TestService_check_Skip(t *testing.T) {
+	s, local, remote := NewTestService([]uint64{1, 2})
+	remote.write(point("serverA", 1))
+
+	if err := s.check(end.Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	} else if local.calls != nil || remote.calls != nil {
+		t.Fatalf("unexpected writes: %v %v", local.calls, remote.calls)
+	}
+}
+
+// Ensure replicas are only compared again once the local replica changed.
+func TestService_check_Verified(t *testing.T) {
+	s, local, remote := NewTestService([]uint64{1, 2})
+	local.write(point("serverA", 1))
+	remote.write(point("serverA", 1))
+
+	if err := s.check(end); err != nil {
+		t.Fatal(err)
+	} else if local.digests != 1 || remote.digests != 1 {
+		t.Fatalf("unexpected digests: %d %d", local.digests, remote.digests)
+	}
+
+	// The unchanged shard isn't compared again.
+	if err := s.check(end); err != nil {
+		t.Fatal(err)
+	} else if local.digests != 1 || remote.digests != 1 {
+		t.Fatalf("unexpected digests: %d %d", local.digests, remote.digests)
+	}
+
+	local.write(point("serverA", 2))
+	if err := s.check(end); err != nil {
+		t.Fatal(err)
+	} else if local.digests != 2 || remote.digests != 2 {
+		t.Fatalf("unexpected digests: %d %d", local.digests, remote.digests)
+	} else if exp := []string{fmt.Sprintf("write 1 %s", point("serverA", 2))}; !reflect.DeepEqual(remote.calls, exp) {
+		t.Fatalf("unexpected remote writes: %v", remote.calls)
+	}
+}
+
+// NewTestService returns a service for node 1 with a single shard owned by
+// owners. The returned stores hold the local replica and the replica of
+// node 2.
+func NewTestService(owners []uint64) (*Service, *store, *store) {
+	si := meta.ShardInfo{ID: 1}
+	for _, id := range owners {
+		si.Owners = append(si.Owners, meta.ShardOwner{NodeID: id})
+	}
+
+	local, remote := newStore(), newStore()
+
+	s := NewService(Config{CheckInterval: toml.Duration(time.Minute), DigestDepth: 2})
+	s.MetaStore = &metaStore{
+		id: 1,
+		dbs: []meta.DatabaseInfo{{
+			Name: "db0",
+			RetentionPolicies: []meta.RetentionPolicyInfo{{
+				Name:     "default",
+				ReplicaN: 2,
+				ShardGroups: []meta.ShardGroupInfo{
+					{ID: 1, StartTime: start, EndTime: end, Shards: []meta.ShardInfo{si}},
+				},
+			}},
+		}},
+	}
+	s.TSDBStore = local
+	s.ShardReader = &remoteStore{remote}
+	s.ShardWriter = &remoteStore{remote}
+	return s, local, remote
+}
+
+func point(host string, minute int) models.Point {
+	return models.NewPoint("cpu", models.Tags{"host": host}, models.Fields{"value": float64(minute)}, start.Add(time.Duration(minute)*time.Minute))
+}
+
+type metaStore struct {
+	id  uint64
+	dbs []meta.DatabaseInfo
+}
+
+func (m *metaStore) NodeID() uint64                          { return m.id }
+func (m *metaStore) Databases() ([]meta.DatabaseInfo, error) { return m.dbs, nil }
+
+// store is an in-memory replica of shard 1 that records writes and counts
+// digests.
+type store struct {
+	shards   map[uint64]map[string][]models.Point
+	modified int64
+	digests  int
+	calls    []string
+}
+
+func newStore() *store {
+	return &store{shards: map[uint64]map[string][]models.Point{1: {}}}
+}
+
+// write adds points to shard 1 without recording a call. Points overwrite
+// the point with the same timestamp.
+func (s *store) write(points ...models.Point) {
+	for _, p := range points {
+		key := string(p.Key())
+		a := s.shards[1][key]
+		for i := range a {
+			if a[i].UnixNano() == p.UnixNano() {
+				a = append(a[:i], a[i+1:]...)
+				break
+			}
+		}
+		s.shards[1][key] = append(a, p)
+	}
+	s.modified++
+}
+
+func (s *store) CreateShard(database, policy string, shardID uint64) error {
+	s.calls = append(s.calls, fmt.Sprintf("create %s %s %d", database, policy, shardID))
+	s.shards = map[uint64]map[string][]models.Point{shardID: {}}
+	return nil
+}
+
+func (s *store) ShardLastModified(shardID uint64) (time.Time, error) {
+	if _, ok := s.shards[shardID]; !ok {
+		return time.Time{}, tsdb.ErrShardNotFound
+	}
+	return time.Unix(0, s.modified), nil
+}
+
+func (s *store) WriteToShard(shardID uint64, points []models.Point) error {
+	for _, p := range points {
+		s.calls = append(s.calls, fmt.Sprintf("write %d %s", shardID, p))
+	}
+	s.write(points...)
+	return nil
+}
+
+func (s *store) ShardDigest(shardID uint64, min, max int64, depth int) ([]*tsdb.SeriesDigest, error) {
+	sh, ok := s.shards[shardID]
+	if !ok {
+		return nil, tsdb.ErrShardNotFound
+	}
+	s.digests++
+
+	var a []*tsdb.SeriesDigest
+	for key := range sh {
+		points, _ := s.ShardSeriesPoints(shardID, key, min, max)
+		a = append(a, tsdb.NewSeriesDigest(key, points, min, max, depth))
+	}
+	return a, nil
+}
+
+func (s *store) ShardSeriesPoints(shardID uint64, key string, min, max int64) ([]models.Point, error) {
+	var a points
+	for _, p := range s.shards[shardID][key] {
+		if p.UnixNano() >= min && p.UnixNano() < max {
+			a = append(a, p)
+		}
+	}
+	sort.Sort(a)
+	return a, nil
+}
+
+type points []models.Point
+
+func (a points) Len() int           { return len(a) }
+func (a points) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a points) Less(i, j int) bool { return a[i].UnixNano() < a[j].UnixNano() }
+
+// remoteStore exposes a store as the replica of node 2.
+type remoteStore struct {
+	*store
+}
+
+func (s *remoteStore) ShardDigest(ownerID, shardID uint64, min, max int64, depth int) ([]*tsdb.SeriesDigest, error) {
+	if ownerID != 2 {
+		return nil, fmt.Errorf("unexpected owner: %d", ownerID)
+	}
+	return s.store.ShardDigest(shardID, min, max, depth)
+}
+
+func (s *remoteStore) SeriesPoints(ownerID, shardID uint64, key string, min, max int64) ([]models.Point, error) {
+	if ownerID != 2 {
+		return nil, fmt.Errorf("unexpected owner: %d", ownerID)
+	}
+	return s.store.ShardSeriesPoints(shardID, key, min, max)
+}
+
+func (s *remoteStore) WriteShard(shardID, ownerID uint64, points []models.Point) error {
+	if ownerID != 2 {
+		return fmt.Errorf("unexpected owner: %d", ownerID)
+	}
+	return s.store.WriteToShard(shardID, points)
+}
@@ -38,7 +38,7 @@ type ContinuousQuerier interface {
 
 // queryExecutor is an internal interface to make testing easier.
 type queryExecutor interface {
-	ExecuteQuery(query *influxql.Query, database string, chunkSize int) (<-chan *influxql.Result, error)
+	ExecuteQuery(query *influxql.Query, database string, chunkSize int, level tsdb.ReadConsistency) (<-chan *influxql.Result, error)
 }
 
 // metaStore is an internal interface to make testing easier.
@@ -327,7 +327,7 @@ func (s *Service) runContinuousQueryAndWriteResult(cq *ContinuousQuery) error {
 	}
 
 	// Execute the SELECT.
-	ch, err := s.QueryExecutor.ExecuteQuery(q, cq.Database, NoChunkingSize)
+	ch, err := s.QueryExecutor.ExecuteQuery(q, cq.Database, NoChunkingSize, tsdb.ReadConsistencyOne)
 	if err != nil {
 		return err
 	}
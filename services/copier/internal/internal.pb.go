@@ -11,6 +11,7 @@ It is generated from these files:
 It has these top-level messages:
 	Request
 	Response
+	Move
 */
 package internal
 
@@ -23,6 +24,11 @@ var _ = math.Inf
 
 type Request struct {
 	ShardID          *uint64 `protobuf:"varint,1,req" json:"ShardID,omitempty"`
+	Restore          *bool   `protobuf:"varint,2,opt" json:"Restore,omitempty"`
+	Database         *string `protobuf:"bytes,3,opt" json:"Database,omitempty"`
+	RetentionPolicy  *string `protobuf:"bytes,4,opt" json:"RetentionPolicy,omitempty"`
+	Delete           *bool   `protobuf:"varint,5,opt" json:"Delete,omitempty"`
+	Moves            *bool   `protobuf:"varint,6,opt" json:"Moves,omitempty"`
 	XXX_unrecognized []byte  `json:"-"`
 }
 
@@ -37,8 +43,44 @@ func (m *Request) GetShardID() uint64 {
 	return 0
 }
 
+func (m *Request) GetRestore() bool {
+	if m != nil && m.Restore != nil {
+		return *m.Restore
+	}
+	return false
+}
+
+func (m *Request) GetDatabase() string {
+	if m != nil && m.Database != nil {
+		return *m.Database
+	}
+	return ""
+}
+
+func (m *Request) GetRetentionPolicy() string {
+	if m != nil && m.RetentionPolicy != nil {
+		return *m.RetentionPolicy
+	}
+	return ""
+}
+
+func (m *Request) GetDelete() bool {
+	if m != nil && m.Delete != nil {
+		return *m.Delete
+	}
+	return false
+}
+
+func (m *Request) GetMoves() bool {
+	if m != nil && m.Moves != nil {
+		return *m.Moves
+	}
+	return false
+}
+
 type Response struct {
 	Error            *string `protobuf:"bytes,1,opt" json:"Error,omitempty"`
+	Moves            []*Move `protobuf:"bytes,2,rep" json:"Moves,omitempty"`
 	XXX_unrecognized []byte  `json:"-"`
 }
 
@@ -53,5 +95,108 @@ func (m *Response) GetError() string {
 	return ""
 }
 
+func (m *Response) GetMoves() []*Move {
+	if m != nil {
+		return m.Moves
+	}
+	return nil
+}
+
+type Move struct {
+	ID               *uint64 `protobuf:"varint,1,req" json:"ID,omitempty"`
+	ShardID          *uint64 `protobuf:"varint,2,req" json:"ShardID,omitempty"`
+	From             *uint64 `protobuf:"varint,3,req" json:"From,omitempty"`
+	To               *uint64 `protobuf:"varint,4,req" json:"To,omitempty"`
+	Delete           *bool   `protobuf:"varint,5,req" json:"Delete,omitempty"`
+	State            *string `protobuf:"bytes,6,req" json:"State,omitempty"`
+	Size             *int64  `protobuf:"varint,7,req" json:"Size,omitempty"`
+	Copied           *int64  `protobuf:"varint,8,req" json:"Copied,omitempty"`
+	Error            *string `protobuf:"bytes,9,opt" json:"Error,omitempty"`
+	StartedAt        *int64  `protobuf:"varint,10,req" json:"StartedAt,omitempty"`
+	Drop             *bool   `protobuf:"varint,11,opt" json:"Drop,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Move) Reset()         { *m = Move{} }
+func (m *Move) String() string { return proto.CompactTextString(m) }
+func (*Move) ProtoMessage()    {}
+
+func (m *Move) GetID() uint64 {
+	if m != nil && m.ID != nil {
+		return *m.ID
+	}
+	return 0
+}
+
+func (m *Move) GetShardID() uint64 {
+	if m != nil && m.ShardID != nil {
+		return *m.ShardID
+	}
+	return 0
+}
+
+func (m *Move) GetFrom() uint64 {
+	if m != nil && m.From != nil {
+		return *m.From
+	}
+	return 0
+}
+
+func (m *Move) GetTo() uint64 {
+	if m != nil && m.To != nil {
+		return *m.To
+	}
+	return 0
+}
+
+func (m *Move) GetDelete() bool {
+	if m != nil && m.Delete != nil {
+		return *m.Delete
+	}
+	return false
+}
+
+func (m *Move) GetState() string {
+	if m != nil && m.State != nil {
+		return *m.State
+	}
+	return ""
+}
+
+func (m *Move) GetSize() int64 {
+	if m != nil && m.Size != nil {
+		return *m.Size
+	}
+	return 0
+}
+
+func (m *Move) GetCopied() int64 {
+	if m != nil && m.Copied != nil {
+		return *m.Copied
+	}
+	return 0
+}
+
+func (m *Move) GetError() string {
+	if m != nil && m.Error != nil {
+		return *m.Error
+	}
+	return ""
+}
+
+func (m *Move) GetStartedAt() int64 {
+	if m != nil && m.StartedAt != nil {
+		return *m.StartedAt
+	}
+	return 0
+}
+
+func (m *Move) GetDrop() bool {
+	if m != nil && m.Drop != nil {
+		return *m.Drop
+	}
+	return false
+}
+
 func init() {
 }
@@ -0,0 +1,466 @@
+package copier
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/influxdb/influxdb/meta"
+	"github.com/influxdb/influxdb/services/copier/internal"
+	"github.com/influxdb/influxdb/tsdb"
+)
+
+// MaxFinishedMoves is the number of finished copies and moves that are kept
+// for display after they complete.
+const MaxFinishedMoves = 100
+
+// Move states.
+const (
+	MoveRunning  = "running"
+	MoveComplete = "complete"
+	MoveFailed   = "failed"
+)
+
+const (
+	// catchUpDepth is the depth of the digests used to find the points a
+	// copy is missing.
+	catchUpDepth = 4
+
+	// catchUpAttempts is the number of times a copy is caught up with the
+	// source before the copy fails. The final pass must find nothing missing.
+	catchUpAttempts = 5
+)
+
+// ErrMoveRunning is returned when a shard is already being copied or moved.
+var ErrMoveRunning = errors.New("shard copy or move already running")
+
+// Move represents a shard copy or move.
+type Move struct {
+	ID      uint64
+	Node    uint64 // node running the move
+	ShardID uint64
+	From    uint64 // source node
+	To      uint64 // destination node
+	Delete  bool   // removes the shard from the source if set
+	Drop    bool   // the destination already owns the shard
+
+	State     string
+	Size      int64 // bytes to copy, set once the copy starts
+	Copied    int64 // bytes copied so far
+	Err       error
+	StartedAt time.Time
+}
+
+// CopyShard starts copying a shard from one of its owners to a node in the
+// background. The node is added to the shard's owners once the data is
+// copied and is then caught up with the writes made during the copy.
+func (s *Service) CopyShard(shardID, to uint64) error {
+	return s.startMove(shardID, 0, to, false)
+}
+
+// MoveShard starts moving a shard between nodes in the background. Once the
+// copy completes the destination node replaces the source node in the shard's
+// owners. The shard is only deleted from the source node once the destination
+// has every point on the source.
+func (s *Service) MoveShard(shardID, from, to uint64) error {
+	return s.startMove(shardID, from, to, true)
+}
+
+// DropShard starts removing a shard from one of its owners in the background.
+// Another owner is first caught up with the points on the node so the shard is
+// only deleted once no points would be lost.
+func (s *Service) DropShard(shardID, from uint64) error {
+	_, _, sgi := s.MetaStore.ShardOwner(shardID)
+	if sgi == nil {
+		return meta.ErrShardNotFound
+	}
+
+	var si meta.ShardInfo
+	for _, sh := range sgi.Shards {
+		if sh.ID == shardID {
+			si = sh
+		}
+	}
+	if !si.OwnedBy(from) {
+		return meta.ErrShardOwnerNotFound
+	}
+
+	// Find the nodes holding the shard's data.
+	src, err := s.MetaStore.Node(from)
+	if err != nil {
+		return err
+	} else if src == nil {
+		return meta.ErrNodeNotFound
+	}
+	var to uint64
+	for _, so := range si.Owners {
+		if so.NodeID == from {
+			continue
+		} else if ni, err := s.MetaStore.Node(so.NodeID); err != nil {
+			return err
+		} else if ni != nil && !ni.Decommissioning {
+			to = so.NodeID
+			break
+		}
+	}
+	if to == 0 {
+		return meta.ErrShardOwnerNotFound
+	}
+
+	m := &Move{ShardID: shardID, From: from, To: to, Delete: true, Drop: true}
+	return s.start(m, func() error {
+		return s.removeSource(m, sgi.StartTime.UnixNano(), sgi.EndTime.UnixNano(), src.Host)
+	})
+}
+
+// Moves returns the running and recently finished copies and moves.
+func (s *Service) Moves() []Move {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a := make([]Move, len(s.moves))
+	for i, m := range s.moves {
+		a[i] = *m
+	}
+	return a
+}
+
+// startMove validates a copy or move and runs it in a separate goroutine.
+// The source is the first owner of the shard that's still a node if from is zero.
+func (s *Service) startMove(shardID, from, to uint64, del bool) error {
+	database, policy, sgi := s.MetaStore.ShardOwner(shardID)
+	if sgi == nil {
+		return meta.ErrShardNotFound
+	}
+
+	var si meta.ShardInfo
+	for _, sh := range sgi.Shards {
+		if sh.ID == shardID {
+			si = sh
+		}
+	}
+
+	// Validate the source and destination nodes.
+	if si.OwnedBy(to) {
+		return meta.ErrShardOwnerExists
+	}
+
+	var src *meta.NodeInfo
+	if from == 0 {
+		for _, so := range si.Owners {
+			ni, err := s.MetaStore.Node(so.NodeID)
+			if err != nil {
+				return err
+			} else if ni != nil {
+				from, src = so.NodeID, ni
+				break
+			}
+		}
+		if src == nil {
+			return meta.ErrShardOwnerNotFound
+		}
+	} else if !si.OwnedBy(from) {
+		return meta.ErrShardOwnerNotFound
+	} else if ni, err := s.MetaStore.Node(from); err != nil {
+		return err
+	} else if ni == nil {
+		return meta.ErrNodeNotFound
+	} else {
+		src = ni
+	}
+	dst, err := s.MetaStore.Node(to)
+	if err != nil {
+		return err
+	} else if dst == nil {
+		return meta.ErrNodeNotFound
+	} else if dst.Decommissioning {
+		return meta.ErrNodeDecommissioning
+	}
+
+	m := &Move{ShardID: shardID, From: from, To: to, Delete: del}
+	return s.start(m, func() error {
+		return s.move(m, database, policy, sgi, src.Host, dst.Host)
+	})
+}
+
+// start records m as running and runs fn in a separate goroutine. Only one
+// copy or move of a shard runs at a time.
+func (s *Service) start(m *Move, fn func() error) error {
+	s.mu.Lock()
+	for _, other := range s.moves {
+		if other.ShardID == m.ShardID && other.State == MoveRunning {
+			s.mu.Unlock()
+			return ErrMoveRunning
+		}
+	}
+	s.moveID++
+	m.ID = s.moveID
+	m.Node = s.MetaStore.NodeID()
+	m.State = MoveRunning
+	m.StartedAt = time.Now().UTC()
+	s.moves = append(s.moves, m)
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.finishMove(m, fn())
+	}()
+
+	return nil
+}
+
+// move streams a shard from the source host to the destination host and
+// updates the shard's owners. The destination is added as an owner before
+// it's caught up so it receives new writes while the ones it missed are copied.
+func (s *Service) move(m *Move, database, policy string, sgi *meta.ShardGroupInfo, srcHost, dstHost string) error {
+	r, err := s.newClient(srcHost).ShardReader(m.ShardID)
+	if err != nil {
+		return fmt.Errorf("read shard: %s", err)
+	}
+	defer r.Close()
+
+	// Read the data size so progress can be reported.
+	var size uint64
+	if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+		return fmt.Errorf("read size: %s", err)
+	}
+	s.mu.Lock()
+	m.Size = int64(size)
+	s.mu.Unlock()
+
+	// Stream the shard to the destination.
+	var hdr bytes.Buffer
+	binary.Write(&hdr, binary.BigEndian, size)
+	mr := &moveReader{Reader: io.LimitReader(r, int64(size)), s: s, m: m}
+	if err := s.newClient(dstHost).RestoreShard(database, policy, m.ShardID, io.MultiReader(&hdr, mr)); err != nil {
+		return fmt.Errorf("restore shard: %s", err)
+	}
+
+	// Route writes to the destination and copy the points written to the
+	// source since the data was read. The copy is dropped if it can't be
+	// caught up.
+	if err := s.MetaStore.AddShardOwner(m.ShardID, m.To); err != nil {
+		s.dropCopy(m, dstHost)
+		return fmt.Errorf("add shard owner: %s", err)
+	}
+	min, max := sgi.StartTime.UnixNano(), sgi.EndTime.UnixNano()
+	if err := s.catchUp(m, min, max); err != nil {
+		if err := s.MetaStore.RemoveShardOwner(m.ShardID, m.To); err != nil {
+			s.Logger.Printf("failed to remove node %d from shard %d owners: %s", m.To, m.ShardID, err)
+		} else {
+			s.dropCopy(m, dstHost)
+		}
+		return fmt.Errorf("catch up: %s", err)
+	}
+	if !m.Delete {
+		return nil
+	}
+	return s.removeSource(m, min, max, srcHost)
+}
+
+// removeSource stops routing to the source and catches the destination up
+// with the writes that reached the source before it stopped receiving them.
+// The source's copy is only removed once the destination is verified to have
+// all of its points.
+func (s *Service) removeSource(m *Move, min, max int64, srcHost string) error {
+	if m.Drop {
+		// The destination may be missing points the source has.
+		if err := s.catchUp(m, min, max); err != nil {
+			return fmt.Errorf("catch up: %s", err)
+		}
+	}
+
+	if err := s.MetaStore.RemoveShardOwner(m.ShardID, m.From); err != nil {
+		return fmt.Errorf("remove shard owner: %s", err)
+	} else if err := s.catchUp(m, min, max); err != nil {
+		if err := s.MetaStore.AddShardOwner(m.ShardID, m.From); err != nil {
+			s.Logger.Printf("failed to restore node %d to shard %d owners: %s", m.From, m.ShardID, err)
+		}
+		return fmt.Errorf("verify: %s", err)
+	} else if err := s.newClient(srcHost).DeleteShard(m.ShardID); err != nil {
+		return fmt.Errorf("delete shard: %s", err)
+	}
+
+	return nil
+}
+
+// dropCopy removes the destination's copy of a shard after a failed move.
+func (s *Service) dropCopy(m *Move, dstHost string) {
+	if err := s.newClient(dstHost).DeleteShard(m.ShardID); err != nil {
+		s.Logger.Printf("failed to remove shard %d copy from node %d: %s", m.ShardID, m.To, err)
+	}
+}
+
+// catchUp writes the points of the shard on the source node between min and
+// max that are missing on the destination node. Points on the destination
+// that aren't on the source are left alone. Returns an error if points are
+// still missing after catchUpAttempts passes.
+func (s *Service) catchUp(m *Move, min, max int64) error {
+	for i := 0; i < catchUpAttempts; i++ {
+		n, err := s.catchUpPass(m, min, max)
+		if err != nil {
+			return err
+		} else if n == 0 {
+			return nil
+		}
+		s.Logger.Printf("shard %d copy to node %d caught up: %d points", m.ShardID, m.To, n)
+	}
+	return fmt.Errorf("points still missing after %d attempts", catchUpAttempts)
+}
+
+// catchUpPass compares the digests of the source and destination and writes
+// the points missing on the destination. Returns the number of points written.
+func (s *Service) catchUpPass(m *Move, min, max int64) (int, error) {
+	src, err := s.ShardReader.ShardDigest(m.From, m.ShardID, min, max, catchUpDepth)
+	if err != nil {
+		return 0, fmt.Errorf("source digest: %s", err)
+	}
+	dst, err := s.ShardReader.ShardDigest(m.To, m.ShardID, min, max, catchUpDepth)
+	if err != nil {
+		return 0, fmt.Errorf("destination digest: %s", err)
+	}
+
+	digests := make(map[string]*tsdb.SeriesDigest, len(dst))
+	for _, d := range dst {
+		digests[d.Key] = d
+	}
+
+	var n int
+	for _, d := range src {
+		for _, i := range d.Diff(digests[d.Key]) {
+			lmin, lmax := tsdb.DigestRange(min, max, catchUpDepth, i)
+			srcPoints, err := s.ShardReader.SeriesPoints(m.From, m.ShardID, d.Key, lmin, lmax)
+			if err != nil {
+				return 0, err
+			}
+			dstPoints, err := s.ShardReader.SeriesPoints(m.To, m.ShardID, d.Key, lmin, lmax)
+			if err != nil {
+				return 0, err
+			}
+
+			// Points that differ on the destination are overwritten too.
+			points, conflicting := tsdb.DiffPoints(srcPoints, dstPoints)
+			points = append(points, conflicting...)
+			if len(points) == 0 {
+				continue
+			} else if err := s.ShardWriter.WriteShard(m.ShardID, m.To, points); err != nil {
+				return 0, err
+			}
+			n += len(points)
+		}
+	}
+	return n, nil
+}
+
+// newClient returns a client for the copier service on host.
+func (s *Service) newClient(host string) *Client {
+	c := NewClient(host)
+	c.TLSConfig = s.TLSConfig
+	return c
+}
+
+// finishMove sets the final state of m and removes the oldest finished moves.
+func (s *Service) finishMove(m *Move, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err != nil {
+		m.State, m.Err = MoveFailed, err
+		s.Logger.Printf("shard %d copy to node %d failed: %s", m.ShardID, m.To, err)
+	} else {
+		m.State = MoveComplete
+		s.Logger.Printf("shard %d copied to node %d", m.ShardID, m.To)
+	}
+
+	var n int
+	for _, m := range s.moves {
+		if m.State != MoveRunning {
+			n++
+		}
+	}
+
+	a := s.moves[:0]
+	for _, m := range s.moves {
+		if n > MaxFinishedMoves && m.State != MoveRunning {
+			n--
+			continue
+		}
+		a = append(a, m)
+	}
+	s.moves = a
+}
+
+// moveReader tracks the progress of a move and stops it when the service closes.
+type moveReader struct {
+	io.Reader
+	s *Service
+	m *Move
+}
+
+func (r *moveReader) Read(p []byte) (int, error) {
+	select {
+	case <-r.s.closing:
+		return 0, errors.New("copier closing")
+	default:
+	}
+
+	n, err := r.Reader.Read(p)
+
+	r.s.mu.Lock()
+	r.m.Copied += int64(n)
+	r.s.mu.Unlock()
+
+	return n, err
+}
+
+// encodeMoves converts moves to their protobuf representation.
+func encodeMoves(moves []Move) []*internal.Move {
+	a := make([]*internal.Move, len(moves))
+	for i, m := range moves {
+		pb := &internal.Move{
+			ID:        proto.Uint64(m.ID),
+			ShardID:   proto.Uint64(m.ShardID),
+			From:      proto.Uint64(m.From),
+			To:        proto.Uint64(m.To),
+			Delete:    proto.Bool(m.Delete),
+			Drop:      proto.Bool(m.Drop),
+			State:     proto.String(m.State),
+			Size:      proto.Int64(m.Size),
+			Copied:    proto.Int64(m.Copied),
+			StartedAt: proto.Int64(m.StartedAt.UnixNano()),
+		}
+		if m.Err != nil {
+			pb.Error = proto.String(m.Err.Error())
+		}
+		a[i] = pb
+	}
+	return a
+}
+
+// decodeMoves converts moves from their protobuf representation.
+func decodeMoves(a []*internal.Move) []Move {
+	moves := make([]Move, len(a))
+	for i, pb := range a {
+		moves[i] = Move{
+			ID:        pb.GetID(),
+			ShardID:   pb.GetShardID(),
+			From:      pb.GetFrom(),
+			To:        pb.GetTo(),
+			Delete:    pb.GetDelete(),
+			Drop:      pb.GetDrop(),
+			State:     pb.GetState(),
+			Size:      pb.GetSize(),
+			Copied:    pb.GetCopied(),
+			StartedAt: time.Unix(0, pb.GetStartedAt()).UTC(),
+		}
+		if pb.Error != nil {
+			moves[i].Err = errors.New(pb.GetError())
+		}
+	}
+	return moves
+}
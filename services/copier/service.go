@@ -1,10 +1,13 @@
 package copier
 
 import (
+	"bytes"
+	"crypto/tls"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"net"
 	"os"
@@ -12,6 +15,8 @@ import (
 	"sync"
 
 	"github.com/gogo/protobuf/proto"
+	"github.com/influxdb/influxdb/meta"
+	"github.com/influxdb/influxdb/models"
 	"github.com/influxdb/influxdb/services/copier/internal"
 	"github.com/influxdb/influxdb/tcp"
 	"github.com/influxdb/influxdb/tsdb"
@@ -22,14 +27,48 @@ import (
 // MuxHeader is the header byte used for the TCP muxer.
 const MuxHeader = 6
 
-// Service manages the listener for the endpoint.
+// ErrUnauthorized is returned when a node that isn't in the cluster tries to
+// restore or delete a shard.
+var ErrUnauthorized = errors.New("request not from a cluster node")
+
+// Service manages the listener for the endpoint and the shard copies and
+// moves started on this node.
 type Service struct {
-	wg  sync.WaitGroup
-	err chan error
+	wg      sync.WaitGroup
+	err     chan error
+	closing chan struct{}
+
+	mu     sync.Mutex
+	moves  []*Move
+	moveID uint64
+
+	MetaStore interface {
+		NodeID() uint64
+		Node(id uint64) (*meta.NodeInfo, error)
+		Nodes() ([]meta.NodeInfo, error)
+		ShardOwner(shardID uint64) (database, policy string, sgi *meta.ShardGroupInfo)
+		AddShardOwner(shardID, nodeID uint64) error
+		RemoveShardOwner(shardID, nodeID uint64) error
+	}
 
 	TSDBStore interface {
 		Shard(id uint64) *tsdb.Shard
+		RestoreShard(database, retentionPolicy string, shardID uint64, path string) error
+		DeleteShard(shardID uint64) error
+	}
+
+	// ShardReader and ShardWriter catch copies up with the writes made to
+	// the source while the shard was streamed.
+	ShardReader interface {
+		ShardDigest(ownerID, shardID uint64, min, max int64, depth int) ([]*tsdb.SeriesDigest, error)
+		SeriesPoints(ownerID, shardID uint64, key string, min, max int64) ([]models.Point, error)
 	}
+	ShardWriter interface {
+		WriteShard(shardID, ownerID uint64, points []models.Point) error
+	}
+
+	// If set, shards are streamed between nodes over TLS.
+	TLSConfig *tls.Config
 
 	Listener net.Listener
 	Logger   *log.Logger
@@ -47,6 +86,8 @@ func NewService() *Service {
 func (s *Service) Open() error {
 	s.Logger.Println("Starting copier service")
 
+	s.closing = make(chan struct{})
+
 	s.wg.Add(1)
 	go s.serve()
 	return nil
@@ -57,6 +98,9 @@ func (s *Service) Close() error {
 	if s.Listener != nil {
 		s.Listener.Close()
 	}
+	if s.closing != nil {
+		close(s.closing)
+	}
 	s.wg.Wait()
 	return nil
 }
@@ -104,6 +148,29 @@ func (s *Service) handleConn(conn net.Conn) error {
 		return fmt.Errorf("read request: %s", err)
 	}
 
+	// Return the copies and moves started on this node if requested.
+	if req.GetMoves() {
+		if err := s.writeResponse(conn, &internal.Response{Moves: encodeMoves(s.Moves())}); err != nil {
+			return fmt.Errorf("write response: %s", err)
+		}
+		return nil
+	}
+
+	// Restore or delete the shard if requested. Only other nodes in the
+	// cluster can change the shards in the store.
+	if req.GetRestore() || req.GetDelete() {
+		if err := s.authorize(conn); err != nil {
+			return s.writeErrorResponse(conn, err)
+		}
+	}
+	if req.GetRestore() {
+		err := s.restoreShard(conn, req)
+		return s.writeErrorResponse(conn, err)
+	} else if req.GetDelete() {
+		err := s.TSDBStore.DeleteShard(req.GetShardID())
+		return s.writeErrorResponse(conn, err)
+	}
+
 	// Retrieve shard.
 	sh := s.TSDBStore.Shard(req.GetShardID())
 
@@ -130,6 +197,81 @@ func (s *Service) handleConn(conn net.Conn) error {
 	return nil
 }
 
+// authorize returns an error if conn isn't from a node in the cluster.
+func (s *Service) authorize(conn net.Conn) error {
+	addr, ok := conn.RemoteAddr().(*net.TCPAddr)
+	if !ok {
+		return ErrUnauthorized
+	}
+
+	nodes, err := s.MetaStore.Nodes()
+	if err != nil {
+		return err
+	}
+	for _, ni := range nodes {
+		host, _, err := net.SplitHostPort(ni.Host)
+		if err != nil {
+			continue
+		}
+		ips, err := net.LookupIP(host)
+		if err != nil {
+			continue
+		}
+		for _, ip := range ips {
+			if ip.Equal(addr.IP) {
+				return nil
+			}
+		}
+	}
+	return ErrUnauthorized
+}
+
+// restoreShard reads the shard data following req into a temporary file and
+// restores it into the store. The shard must belong to the database and
+// retention policy in the request.
+func (s *Service) restoreShard(r io.Reader, req *internal.Request) error {
+	database, policy, sgi := s.MetaStore.ShardOwner(req.GetShardID())
+	if sgi == nil {
+		return meta.ErrShardNotFound
+	} else if database != req.GetDatabase() || policy != req.GetRetentionPolicy() {
+		return fmt.Errorf("shard %d belongs to %s.%s", req.GetShardID(), database, policy)
+	}
+
+	// Read data size.
+	var n uint64
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return fmt.Errorf("read size: %s", err)
+	}
+
+	// Copy data to a temporary file.
+	f, err := ioutil.TempFile("", "copier-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if _, err := io.CopyN(f, r, int64(n)); err != nil {
+		return fmt.Errorf("read shard: %s", err)
+	} else if err := f.Close(); err != nil {
+		return err
+	}
+
+	return s.TSDBStore.RestoreShard(req.GetDatabase(), req.GetRetentionPolicy(), req.GetShardID(), f.Name())
+}
+
+// writeErrorResponse writes a response with err to w. A nil err is success.
+func (s *Service) writeErrorResponse(w io.Writer, err error) error {
+	resp := &internal.Response{}
+	if err != nil {
+		resp.Error = proto.String(err.Error())
+	}
+	if err := s.writeResponse(w, resp); err != nil {
+		return fmt.Errorf("write response: %s", err)
+	}
+	return nil
+}
+
 // readRequest reads and unmarshals a Request from r.
 func (s *Service) readRequest(r io.Reader) (*internal.Request, error) {
 	// Read request length.
@@ -174,9 +316,76 @@ func (s *Service) writeResponse(w io.Writer, resp *internal.Response) error {
 	return nil
 }
 
+// RestoreShardTo sends the shard data file at path to the copier service on
+// a node, which adds it to its store under the database and retention policy.
+func (s *Service) RestoreShardTo(nodeID uint64, database, retentionPolicy string, shardID uint64, path string) error {
+	ni, err := s.MetaStore.Node(nodeID)
+	if err != nil {
+		return err
+	} else if ni == nil {
+		return meta.ErrNodeNotFound
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	// The data is preceded by its size, as read from ShardReader.
+	var hdr bytes.Buffer
+	binary.Write(&hdr, binary.BigEndian, uint64(fi.Size()))
+	return s.newClient(ni.Host).RestoreShard(database, retentionPolicy, shardID, io.MultiReader(&hdr, f))
+}
+
+// ClusterMoves returns the running and recently finished copies and moves
+// started on every node. Nodes that can't be reached are skipped.
+func (s *Service) ClusterMoves() ([]Move, error) {
+	nodes, err := s.MetaStore.Nodes()
+	if err != nil {
+		return nil, err
+	}
+
+	id := s.MetaStore.NodeID()
+	a := s.Moves()
+	for _, ni := range nodes {
+		if ni.ID == id {
+			continue
+		}
+		moves, err := s.newClient(ni.Host).Moves()
+		if err != nil {
+			s.Logger.Printf("failed to read shard moves from node %d: %s", ni.ID, err)
+			continue
+		}
+		for i := range moves {
+			moves[i].Node = ni.ID
+		}
+		a = append(a, moves...)
+	}
+	return a, nil
+}
+
+// DeleteShardFrom removes a shard from the store of a node.
+func (s *Service) DeleteShardFrom(nodeID, shardID uint64) error {
+	ni, err := s.MetaStore.Node(nodeID)
+	if err != nil {
+		return err
+	} else if ni == nil {
+		return meta.ErrNodeNotFound
+	}
+	return s.newClient(ni.Host).DeleteShard(shardID)
+}
+
 // Client represents a client for connecting remotely to a copier service.
 type Client struct {
 	host string
+
+	// If set, the client connects over TLS.
+	TLSConfig *tls.Config
 }
 
 // NewClient return a new instance of Client.
@@ -190,7 +399,7 @@ func NewClient(host string) *Client {
 // Returned ReadCloser must be closed by the caller.
 func (c *Client) ShardReader(id uint64) (io.ReadCloser, error) {
 	// Connect to remote server.
-	conn, err := tcp.Dial("tcp", c.host, MuxHeader)
+	conn, err := tcp.DialTLS("tcp", c.host, MuxHeader, 0, c.TLSConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -216,6 +425,88 @@ func (c *Client) ShardReader(id uint64) (io.ReadCloser, error) {
 	return conn, nil
 }
 
+// RestoreShard sends shard data, as read from ShardReader, to the service
+// which adds it to its store under the database and retention policy.
+func (c *Client) RestoreShard(database, retentionPolicy string, id uint64, r io.Reader) error {
+	// Connect to remote server.
+	conn, err := tcp.DialTLS("tcp", c.host, MuxHeader, 0, c.TLSConfig)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Send request and shard data to server.
+	if err := c.writeRequest(conn, &internal.Request{
+		ShardID:         proto.Uint64(id),
+		Restore:         proto.Bool(true),
+		Database:        proto.String(database),
+		RetentionPolicy: proto.String(retentionPolicy),
+	}); err != nil {
+		return fmt.Errorf("write request: %s", err)
+	} else if _, err := io.Copy(conn, r); err != nil {
+		return fmt.Errorf("write shard: %s", err)
+	}
+
+	return c.readErrorResponse(conn)
+}
+
+// DeleteShard removes a shard from the service's store.
+func (c *Client) DeleteShard(id uint64) error {
+	// Connect to remote server.
+	conn, err := tcp.DialTLS("tcp", c.host, MuxHeader, 0, c.TLSConfig)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Send request to server.
+	if err := c.writeRequest(conn, &internal.Request{
+		ShardID: proto.Uint64(id),
+		Delete:  proto.Bool(true),
+	}); err != nil {
+		return fmt.Errorf("write request: %s", err)
+	}
+
+	return c.readErrorResponse(conn)
+}
+
+// Moves returns the copies and moves started on the service's node.
+func (c *Client) Moves() ([]Move, error) {
+	// Connect to remote server.
+	conn, err := tcp.DialTLS("tcp", c.host, MuxHeader, 0, c.TLSConfig)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// Send request to server.
+	if err := c.writeRequest(conn, &internal.Request{
+		ShardID: proto.Uint64(0),
+		Moves:   proto.Bool(true),
+	}); err != nil {
+		return nil, fmt.Errorf("write request: %s", err)
+	}
+
+	resp, err := c.readResponse(conn)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %s", err)
+	} else if resp.GetError() != "" {
+		return nil, errors.New(resp.GetError())
+	}
+	return decodeMoves(resp.GetMoves()), nil
+}
+
+// readErrorResponse reads a response from r and returns its error, if any.
+func (c *Client) readErrorResponse(r io.Reader) error {
+	resp, err := c.readResponse(r)
+	if err != nil {
+		return fmt.Errorf("read response: %s", err)
+	} else if resp.GetError() != "" {
+		return errors.New(resp.GetError())
+	}
+	return nil
+}
+
 // writeRequest marshals and writes req to w.
 func (c *Client) writeRequest(w io.Writer, req *internal.Request) error {
 	// Marshal request.
@@ -3,14 +3,20 @@ package copier_test
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
 	"net"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/influxdb/influxdb/meta"
+	"github.com/influxdb/influxdb/models"
 	"github.com/influxdb/influxdb/services/copier"
 	"github.com/influxdb/influxdb/tcp"
 	"github.com/influxdb/influxdb/tsdb"
@@ -85,12 +91,249 @@ func TestService_handleConn_Error(t *testing.T) {
 	}
 }
 
+// Ensure the service can move a shard between nodes.
+func TestService_MoveShard(t *testing.T) {
+	src, dst := MustOpenService(), MustOpenService()
+	defer src.Close()
+	defer dst.Close()
+
+	// Mock shard on the source node.
+	sh := MustOpenShard(123)
+	defer sh.Close()
+	src.TSDBStore.ShardFn = func(id uint64) *tsdb.Shard { return sh.Shard }
+
+	var deleted bool
+	src.TSDBStore.DeleteShardFn = func(id uint64) error {
+		deleted = id == 123
+		return nil
+	}
+
+	// Verify the data is restored on the destination node.
+	var restored []byte
+	dst.TSDBStore.RestoreShardFn = func(database, retentionPolicy string, id uint64, path string) error {
+		if database != "db0" || retentionPolicy != "rp0" || id != 123 {
+			t.Fatalf("unexpected shard: %s.%s.%d", database, retentionPolicy, id)
+		}
+		restored, _ = ioutil.ReadFile(path)
+		return nil
+	}
+
+	// Mock meta store with the shard owned by node 1.
+	owners := MockMetaStores(src, dst)
+
+	// Mock a point written to the source while the shard was copied.
+	var written []models.Point
+	MockReplicas(src, map[uint64][]models.Point{
+		1: {models.NewPoint("cpu", nil, models.Fields{"value": 1.0}, time.Unix(0, 10))},
+	}, &written)
+
+	if err := src.MoveShard(123, 1, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	m := WaitForMove(t, src)
+	if m.State != copier.MoveComplete {
+		t.Fatalf("unexpected move: %#v", m)
+	} else if m.Size == 0 || m.Copied != m.Size || int64(len(restored)) != m.Size {
+		t.Fatalf("unexpected size: size=%d, copied=%d, restored=%d", m.Size, m.Copied, len(restored))
+	} else if !reflect.DeepEqual(*owners, []string{"+2", "-1"}) {
+		t.Fatalf("unexpected owner changes: %v", *owners)
+	} else if len(written) != 1 || written[0].UnixNano() != 10 {
+		t.Fatalf("unexpected catch up writes: %v", written)
+	} else if !deleted {
+		t.Fatal("expected source shard to be deleted")
+	}
+}
+
+// Ensure a move keeps the source's copy if the destination can't be caught up.
+func TestService_MoveShard_ErrCatchUp(t *testing.T) {
+	src, dst := MustOpenService(), MustOpenService()
+	defer src.Close()
+	defer dst.Close()
+
+	sh := MustOpenShard(123)
+	defer sh.Close()
+	src.TSDBStore.ShardFn = func(id uint64) *tsdb.Shard { return sh.Shard }
+	src.TSDBStore.DeleteShardFn = func(id uint64) error {
+		t.Fatal("source shard deleted")
+		return nil
+	}
+	dst.TSDBStore.RestoreShardFn = func(database, retentionPolicy string, id uint64, path string) error { return nil }
+
+	var dropped bool
+	dst.TSDBStore.DeleteShardFn = func(id uint64) error {
+		dropped = true
+		return nil
+	}
+
+	owners := MockMetaStores(src, dst)
+
+	// Writes to the destination never land.
+	MockReplicas(src, map[uint64][]models.Point{
+		1: {models.NewPoint("cpu", nil, models.Fields{"value": 1.0}, time.Unix(0, 10))},
+	}, nil)
+	src.ShardWriter.WriteShardFn = func(shardID, ownerID uint64, points []models.Point) error { return nil }
+
+	if err := src.MoveShard(123, 1, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	m := WaitForMove(t, src)
+	if m.State != copier.MoveFailed || m.Err == nil || m.Err.Error() != "catch up: points still missing after 5 attempts" {
+		t.Fatalf("unexpected move: %#v", m)
+	} else if !reflect.DeepEqual(*owners, []string{"+2", "-2"}) {
+		t.Fatalf("unexpected owner changes: %v", *owners)
+	} else if !dropped {
+		t.Fatal("expected destination copy to be dropped")
+	}
+}
+
+// Ensure the service only restores and deletes shards for cluster nodes.
+func TestService_DeleteShard_ErrUnauthorized(t *testing.T) {
+	s := MustOpenService()
+	defer s.Close()
+
+	s.MetaStore.NodesFn = func() ([]meta.NodeInfo, error) {
+		return []meta.NodeInfo{{ID: 1, Host: "192.0.2.1:8088"}}, nil
+	}
+	s.TSDBStore.DeleteShardFn = func(id uint64) error {
+		t.Fatal("shard deleted")
+		return nil
+	}
+
+	if err := copier.NewClient(s.Addr().String()).DeleteShard(123); err == nil || err.Error() != copier.ErrUnauthorized.Error() {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure the moves started on every node can be listed.
+func TestService_ClusterMoves(t *testing.T) {
+	s0, s1 := MustOpenService(), MustOpenService()
+	defer s0.Close()
+	defer s1.Close()
+	MockMetaStores(s0, s1)
+
+	// Start a move on the second node that fails to connect to the source.
+	s1.MetaStore.NodeFn = func(id uint64) (*meta.NodeInfo, error) {
+		return &meta.NodeInfo{ID: id, Host: "127.0.0.1:0"}, nil
+	}
+	if err := s1.MoveShard(123, 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	WaitForMove(t, s1)
+
+	moves, err := s0.ClusterMoves()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(moves) != 1 {
+		t.Fatalf("unexpected moves: %#v", moves)
+	} else if m := moves[0]; m.Node != 2 || m.ShardID != 123 || m.From != 1 || m.To != 2 || !m.Delete || m.State != copier.MoveFailed || m.Err == nil {
+		t.Fatalf("unexpected move: %#v", m)
+	}
+}
+
+// Ensure a shard can't be copied to a node that already owns it.
+func TestService_CopyShard_ErrShardOwnerExists(t *testing.T) {
+	s := MustOpenService()
+	defer s.Close()
+
+	s.MetaStore.ShardOwnerFn = func(id uint64) (string, string, *meta.ShardGroupInfo) {
+		return "db0", "rp0", &meta.ShardGroupInfo{Shards: []meta.ShardInfo{{ID: 123, Owners: []meta.ShardOwner{{NodeID: 1}, {NodeID: 2}}}}}
+	}
+	if err := s.CopyShard(123, 2); err != meta.ErrShardOwnerExists {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// WaitForMove waits for the first move on s to finish and returns it.
+func WaitForMove(t *testing.T, s *Service) copier.Move {
+	for i := 0; ; i++ {
+		if m := s.Moves()[0]; m.State != copier.MoveRunning {
+			return m
+		} else if i == 100 {
+			t.Fatal("move timeout")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// MockMetaStores mocks the meta stores of services as nodes in a cluster in
+// which shard 123 is owned by the first node. Returns the owner changes.
+func MockMetaStores(services ...*Service) *[]string {
+	var owners []string
+	for i, s := range services {
+		id := uint64(i + 1)
+		s.MetaStore.NodeIDFn = func() uint64 { return id }
+		s.MetaStore.NodeFn = func(id uint64) (*meta.NodeInfo, error) {
+			return &meta.NodeInfo{ID: id, Host: services[id-1].Addr().String()}, nil
+		}
+		s.MetaStore.NodesFn = func() ([]meta.NodeInfo, error) {
+			var a []meta.NodeInfo
+			for i, s := range services {
+				a = append(a, meta.NodeInfo{ID: uint64(i + 1), Host: s.Addr().String()})
+			}
+			return a, nil
+		}
+		s.MetaStore.ShardOwnerFn = func(id uint64) (string, string, *meta.ShardGroupInfo) {
+			return "db0", "rp0", &meta.ShardGroupInfo{
+				StartTime: time.Unix(0, 0),
+				EndTime:   time.Unix(0, 100),
+				Shards:    []meta.ShardInfo{{ID: 123, Owners: []meta.ShardOwner{{NodeID: 1}}}},
+			}
+		}
+		s.MetaStore.AddShardOwnerFn = func(shardID, nodeID uint64) error {
+			owners = append(owners, fmt.Sprintf("+%d", nodeID))
+			return nil
+		}
+		s.MetaStore.RemoveShardOwnerFn = func(shardID, nodeID uint64) error {
+			owners = append(owners, fmt.Sprintf("-%d", nodeID))
+			return nil
+		}
+	}
+	return &owners
+}
+
+// MockReplicas mocks the shard reader and writer of s with the points of
+// series "cpu" in shard 123 on each node. Points written are appended to
+// written, if set, and added to the node's points.
+func MockReplicas(s *Service, points map[uint64][]models.Point, written *[]models.Point) {
+	var mu sync.Mutex
+	s.ShardReader.ShardDigestFn = func(ownerID, shardID uint64, min, max int64, depth int) ([]*tsdb.SeriesDigest, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return []*tsdb.SeriesDigest{tsdb.NewSeriesDigest("cpu", points[ownerID], min, max, depth)}, nil
+	}
+	s.ShardReader.SeriesPointsFn = func(ownerID, shardID uint64, key string, min, max int64) ([]models.Point, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		var a []models.Point
+		for _, p := range points[ownerID] {
+			if p.UnixNano() >= min && p.UnixNano() < max {
+				a = append(a, p)
+			}
+		}
+		return a, nil
+	}
+	s.ShardWriter.WriteShardFn = func(shardID, ownerID uint64, a []models.Point) error {
+		mu.Lock()
+		defer mu.Unlock()
+		points[ownerID] = append(points[ownerID], a...)
+		if written != nil {
+			*written = append(*written, a...)
+		}
+		return nil
+	}
+}
+
 // Service represents a test wrapper for copier.Service.
 type Service struct {
 	*copier.Service
 
-	ln        net.Listener
-	TSDBStore ServiceTSDBStore
+	ln          net.Listener
+	MetaStore   ServiceMetaStore
+	TSDBStore   ServiceTSDBStore
+	ShardReader ServiceShardReader
+	ShardWriter ServiceShardWriter
 }
 
 // NewService returns a new instance of Service.
@@ -98,7 +341,10 @@ func NewService() *Service {
 	s := &Service{
 		Service: copier.NewService(),
 	}
+	s.Service.MetaStore = &s.MetaStore
 	s.Service.TSDBStore = &s.TSDBStore
+	s.Service.ShardReader = &s.ShardReader
+	s.Service.ShardWriter = &s.ShardWriter
 
 	if !testing.Verbose() {
 		s.SetLogger(log.New(ioutil.Discard, "", 0))
@@ -140,13 +386,78 @@ func (s *Service) Close() error {
 // Addr returns the address of the service.
 func (s *Service) Addr() net.Addr { return s.ln.Addr() }
 
+// ServiceMetaStore is a mock that implements copier.Service.MetaStore.
+type ServiceMetaStore struct {
+	NodeIDFn           func() uint64
+	NodeFn             func(id uint64) (*meta.NodeInfo, error)
+	NodesFn            func() ([]meta.NodeInfo, error)
+	ShardOwnerFn       func(shardID uint64) (string, string, *meta.ShardGroupInfo)
+	AddShardOwnerFn    func(shardID, nodeID uint64) error
+	RemoveShardOwnerFn func(shardID, nodeID uint64) error
+}
+
+func (ms *ServiceMetaStore) NodeID() uint64 { return ms.NodeIDFn() }
+
+func (ms *ServiceMetaStore) Node(id uint64) (*meta.NodeInfo, error) { return ms.NodeFn(id) }
+
+// Nodes returns the mocked nodes. Defaults to a single local node.
+func (ms *ServiceMetaStore) Nodes() ([]meta.NodeInfo, error) {
+	if ms.NodesFn == nil {
+		return []meta.NodeInfo{{ID: 1, Host: "127.0.0.1:0"}}, nil
+	}
+	return ms.NodesFn()
+}
+
+func (ms *ServiceMetaStore) ShardOwner(shardID uint64) (string, string, *meta.ShardGroupInfo) {
+	return ms.ShardOwnerFn(shardID)
+}
+
+func (ms *ServiceMetaStore) AddShardOwner(shardID, nodeID uint64) error {
+	return ms.AddShardOwnerFn(shardID, nodeID)
+}
+
+func (ms *ServiceMetaStore) RemoveShardOwner(shardID, nodeID uint64) error {
+	return ms.RemoveShardOwnerFn(shardID, nodeID)
+}
+
 // ServiceTSDBStore is a mock that implements copier.Service.TSDBStore.
 type ServiceTSDBStore struct {
-	ShardFn func(id uint64) *tsdb.Shard
+	ShardFn        func(id uint64) *tsdb.Shard
+	RestoreShardFn func(database, retentionPolicy string, shardID uint64, path string) error
+	DeleteShardFn  func(shardID uint64) error
 }
 
 func (ss *ServiceTSDBStore) Shard(id uint64) *tsdb.Shard { return ss.ShardFn(id) }
 
+func (ss *ServiceTSDBStore) RestoreShard(database, retentionPolicy string, shardID uint64, path string) error {
+	return ss.RestoreShardFn(database, retentionPolicy, shardID, path)
+}
+
+func (ss *ServiceTSDBStore) DeleteShard(shardID uint64) error { return ss.DeleteShardFn(shardID) }
+
+// ServiceShardReader is a mock that implements copier.Service.ShardReader.
+type ServiceShardReader struct {
+	ShardDigestFn  func(ownerID, shardID uint64, min, max int64, depth int) ([]*tsdb.SeriesDigest, error)
+	SeriesPointsFn func(ownerID, shardID uint64, key string, min, max int64) ([]models.Point, error)
+}
+
+func (r *ServiceShardReader) ShardDigest(ownerID, shardID uint64, min, max int64, depth int) ([]*tsdb.SeriesDigest, error) {
+	return r.ShardDigestFn(ownerID, shardID, min, max, depth)
+}
+
+func (r *ServiceShardReader) SeriesPoints(ownerID, shardID uint64, key string, min, max int64) ([]models.Point, error) {
+	return r.SeriesPointsFn(ownerID, shardID, key, min, max)
+}
+
+// ServiceShardWriter is a mock that implements copier.Service.ShardWriter.
+type ServiceShardWriter struct {
+	WriteShardFn func(shardID, ownerID uint64, points []models.Point) error
+}
+
+func (w *ServiceShardWriter) WriteShard(shardID, ownerID uint64, points []models.Point) error {
+	return w.WriteShardFn(shardID, ownerID, points)
+}
+
 // Shard is a test wrapper for tsdb.Shard.
 type Shard struct {
 	*tsdb.Shard
@@ -0,0 +1,58 @@
+package copier
+
+import (
+	"fmt"
+
+	"github.com/influxdb/influxdb/influxql"
+	"github.com/influxdb/influxdb/models"
+)
+
+// StatementExecutor translates InfluxQL queries to shard copies and moves.
+type StatementExecutor struct {
+	Service interface {
+		CopyShard(shardID, to uint64) error
+		MoveShard(shardID, from, to uint64) error
+		ClusterMoves() ([]Move, error)
+	}
+}
+
+// ExecuteStatement executes shard copy and move statements.
+func (e *StatementExecutor) ExecuteStatement(stmt influxql.Statement) *influxql.Result {
+	switch stmt := stmt.(type) {
+	case *influxql.CopyShardStatement:
+		return &influxql.Result{Err: e.Service.CopyShard(stmt.ID, stmt.To)}
+	case *influxql.MoveShardStatement:
+		return &influxql.Result{Err: e.Service.MoveShard(stmt.ID, stmt.From, stmt.To)}
+	case *influxql.ShowShardMovesStatement:
+		return e.executeShowShardMovesStatement(stmt)
+	default:
+		panic(fmt.Sprintf("unsupported statement type: %T", stmt))
+	}
+}
+
+func (e *StatementExecutor) executeShowShardMovesStatement(stmt *influxql.ShowShardMovesStatement) *influxql.Result {
+	moves, err := e.Service.ClusterMoves()
+	if err != nil {
+		return &influxql.Result{Err: err}
+	}
+
+	row := &models.Row{Columns: []string{"node_id", "id", "shard_id", "type", "source", "destination", "state", "copied", "size", "started_at", "error"}}
+	for _, m := range moves {
+		typ := "copy"
+		if m.Drop {
+			typ = "drop"
+		} else if m.Delete {
+			typ = "move"
+		}
+
+		var errStr string
+		if m.Err != nil {
+			errStr = m.Err.Error()
+		}
+
+		row.Values = append(row.Values, []interface{}{
+			m.Node, m.ID, m.ShardID, typ, m.From, m.To, m.State, m.Copied, m.Size, m.StartedAt, errStr,
+		})
+	}
+	return &influxql.Result{Series: []*models.Row{row}}
+}
@@ -0,0 +1,249 @@
+package decommissioner
+
+import (
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/influxdb/influxdb/meta"
+	"github.com/influxdb/influxdb/services/copier"
+)
+
+// DefaultCheckInterval is the default time between checks of whether the
+// local node is being decommissioned.
+const DefaultCheckInterval = 10 * time.Second
+
+// Service removes the local node from the cluster once it's marked as
+// decommissioning. The node's shards are moved to other nodes one at a time,
+// then the node waits for its hinted handoff queues to drain and finally
+// removes itself from the raft peers and the list of nodes. Writes other nodes
+// still queue for the removed node are rerouted to the shards' new owners.
+type Service struct {
+	MetaStore interface {
+		NodeID() uint64
+		Node(id uint64) (*meta.NodeInfo, error)
+		Nodes() ([]meta.NodeInfo, error)
+		Databases() ([]meta.DatabaseInfo, error)
+		RemoveNode(id uint64) error
+	}
+	Copier interface {
+		MoveShard(shardID, from, to uint64) error
+		DropShard(shardID, from uint64) error
+		Moves() []copier.Move
+	}
+	HintedHandoff interface {
+		Pending() bool
+	}
+
+	CheckInterval time.Duration
+
+	wg   sync.WaitGroup
+	done chan struct{}
+
+	Logger *log.Logger
+}
+
+// NewService returns a new instance of Service.
+func NewService() *Service {
+	return &Service{
+		CheckInterval: DefaultCheckInterval,
+		Logger:        log.New(os.Stderr, "[decommissioner] ", log.LstdFlags),
+	}
+}
+
+// Open starts checking whether the node is being decommissioned.
+func (s *Service) Open() error {
+	if s.done != nil {
+		return nil
+	}
+
+	s.Logger.Println("Starting decommissioner service")
+
+	s.done = make(chan struct{})
+
+	s.wg.Add(1)
+	go s.run()
+	return nil
+}
+
+// Close stops the service.
+func (s *Service) Close() error {
+	if s.done == nil {
+		return nil
+	}
+
+	close(s.done)
+	s.wg.Wait()
+	s.done = nil
+	return nil
+}
+
+// SetLogger sets the internal logger to the logger passed in.
+func (s *Service) SetLogger(l *log.Logger) {
+	s.Logger = l
+}
+
+func (s *Service) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			if err := s.check(); err != nil {
+				s.Logger.Printf("decommission failed: %s", err)
+			}
+		}
+	}
+}
+
+// check advances the decommissioning of the local node by one step.
+func (s *Service) check() error {
+	id := s.MetaStore.NodeID()
+	ni, err := s.MetaStore.Node(id)
+	if err != nil {
+		return err
+	} else if ni == nil || !ni.Decommissioning {
+		return nil
+	}
+
+	// Only move or drop one shard at a time.
+	if s.moving(id) {
+		return nil
+	}
+
+	// Wait until the node no longer owns any shards.
+	if n, err := s.moveShards(id); err != nil {
+		return err
+	} else if n > 0 {
+		return nil
+	}
+
+	// Writes queued for other nodes are lost once the node is removed.
+	if s.HintedHandoff.Pending() {
+		s.Logger.Println("waiting for hinted handoff queues to drain")
+		return nil
+	}
+
+	if err := s.MetaStore.RemoveNode(id); err != nil {
+		return err
+	}
+	s.Logger.Printf("node %d removed from the cluster and can be shut down", id)
+	return nil
+}
+
+// moving returns true if a shard is being moved or dropped from nodeID.
+func (s *Service) moving(nodeID uint64) bool {
+	for _, m := range s.Copier.Moves() {
+		if m.From == nodeID && m.State == copier.MoveRunning {
+			return true
+		}
+	}
+	return false
+}
+
+// moveShards starts moving a shard owned by nodeID to another node and returns the number of shards the node still owns. Shards that
+// have enough owners without the node are dropped instead of moved.
+func (s *Service) moveShards(nodeID uint64) (int, error) {
+	nodes, err := s.MetaStore.Nodes()
+	if err != nil {
+		return 0, err
+	}
+	dbs, err := s.MetaStore.Databases()
+	if err != nil {
+		return 0, err
+	}
+
+	// Count the shards owned by each node that can receive shards.
+	load := make(map[uint64]int)
+	for _, ni := range nodes {
+		if !ni.Decommissioning {
+			load[ni.ID] = 0
+		}
+	}
+
+	var owned []shard
+	for _, di := range dbs {
+		for _, rpi := range di.RetentionPolicies {
+			for _, sgi := range rpi.ShardGroups {
+				if sgi.Deleted() {
+					continue
+				}
+				for _, si := range sgi.Shards {
+					for _, so := range si.Owners {
+						if _, ok := load[so.NodeID]; ok {
+							load[so.NodeID]++
+						}
+					}
+					if si.OwnedBy(nodeID) {
+						owned = append(owned, shard{ShardInfo: si, replicaN: rpi.ReplicaN})
+					}
+				}
+			}
+		}
+	}
+
+	if len(owned) == 0 {
+		return 0, nil
+	} else if len(load) == 0 {
+		s.Logger.Println("no nodes to move shards to")
+		return len(owned), nil
+	}
+
+	// Require at least one replica but no more replicas than nodes.
+	sh := owned[0]
+	replicaN := sh.replicaN
+	if replicaN < 1 {
+		replicaN = 1
+	} else if replicaN > len(load) {
+		replicaN = len(load)
+	}
+
+	// Count the replicas held by other nodes.
+	var n int
+	for _, so := range sh.Owners {
+		if _, ok := load[so.NodeID]; ok {
+			n++
+		}
+	}
+
+	if n >= replicaN {
+		// Other nodes hold enough replicas so drop the local copy once
+		// another owner has caught up with it.
+		if err := s.Copier.DropShard(sh.ID, nodeID); err != nil {
+			return 0, err
+		}
+		s.Logger.Printf("dropping shard %d", sh.ID)
+		return len(owned), nil
+	}
+
+	// Move the shard to the node owning the fewest shards that doesn't own it.
+	var to uint64
+	for id, n := range load {
+		if sh.OwnedBy(id) {
+			continue
+		} else if to == 0 || n < load[to] || (n == load[to] && id < to) {
+			to = id
+		}
+	}
+	if to == 0 {
+		s.Logger.Printf("no node to move shard %d to", sh.ID)
+		return len(owned), nil
+	}
+
+	if err := s.Copier.MoveShard(sh.ID, nodeID, to); err != nil {
+		return 0, err
+	}
+	s.Logger.Printf("moving shard %d to node %d", sh.ID, to)
+	return len(owned), nil
+}
+
+// shard represents a shard owned by the decommissioning node.
+type shard struct {
+	meta.ShardInfo
+	replicaN int
+}
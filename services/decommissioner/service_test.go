@@ -0,0 +1,166 @@
+package decommissioner
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/influxdb/influxdb/meta"
+	"github.com/influxdb/influxdb/services/copier"
+)
+
+// Ensure nothing happens if the node isn't decommissioning.
+func TestService_check_NotDecommissioning(t *testing.T) {
+	s, ms, c := NewTestService([]meta.NodeInfo{{ID: 1}, {ID: 2}}, [][]uint64{{1}})
+	if err := s.check(); err != nil {
+		t.Fatal(err)
+	} else if c.calls != nil || ms.calls != nil {
+		t.Fatalf("unexpected calls: %v %v", c.calls, ms.calls)
+	}
+}
+
+// Ensure a shard is moved to the node owning the fewest shards.
+func TestService_check_MoveShard(t *testing.T) {
+	s, ms, c := NewTestService(
+		[]meta.NodeInfo{{ID: 1, Decommissioning: true}, {ID: 2}, {ID: 3}},
+		[][]uint64{{1}, {1}, {2}},
+	)
+	if err := s.check(); err != nil {
+		t.Fatal(err)
+	} else if exp := []string{"move 1 1 3"}; !reflect.DeepEqual(c.calls, exp) {
+		t.Fatalf("unexpected copier calls: %v", c.calls)
+	} else if ms.calls != nil {
+		t.Fatalf("unexpected meta calls: %v", ms.calls)
+	}
+
+	// No other move starts while one is running.
+	c.moves = []copier.Move{{ShardID: 1, From: 1, To: 3, State: copier.MoveRunning}}
+	c.calls = nil
+	if err := s.check(); err != nil {
+		t.Fatal(err)
+	} else if c.calls != nil {
+		t.Fatalf("unexpected copier calls: %v", c.calls)
+	}
+}
+
+// Ensure a shard with enough replicas on other nodes is dropped through the
+// copier and the node isn't removed while it still owns the shard.
+func TestService_check_DropShard(t *testing.T) {
+	s, ms, c := NewTestService(
+		[]meta.NodeInfo{{ID: 1, Decommissioning: true}, {ID: 2}},
+		[][]uint64{{2, 1}},
+	)
+	if err := s.check(); err != nil {
+		t.Fatal(err)
+	} else if exp := []string{"drop 1 1"}; !reflect.DeepEqual(c.calls, exp) {
+		t.Fatalf("unexpected copier calls: %v", c.calls)
+	} else if ms.calls != nil {
+		t.Fatalf("unexpected meta calls: %v", ms.calls)
+	}
+
+	// Nothing else happens while the drop is running.
+	c.moves = []copier.Move{{ShardID: 1, From: 1, To: 2, Drop: true, State: copier.MoveRunning}}
+	c.calls = nil
+	if err := s.check(); err != nil {
+		t.Fatal(err)
+	} else if c.calls != nil || ms.calls != nil {
+		t.Fatalf("unexpected calls: %v %v", c.calls, ms.calls)
+	}
+}
+
+// Ensure the node is only removed once its hinted handoff queues are drained.
+func TestService_check_RemoveNode(t *testing.T) {
+	s, ms, _ := NewTestService(
+		[]meta.NodeInfo{{ID: 1, Decommissioning: true}, {ID: 2}},
+		[][]uint64{{2}},
+	)
+
+	hh := &hintedHandoff{pending: true}
+	s.HintedHandoff = hh
+	if err := s.check(); err != nil {
+		t.Fatal(err)
+	} else if ms.calls != nil {
+		t.Fatalf("unexpected meta calls: %v", ms.calls)
+	}
+
+	hh.pending = false
+	if err := s.check(); err != nil {
+		t.Fatal(err)
+	} else if exp := []string{"remove node 1"}; !reflect.DeepEqual(ms.calls, exp) {
+		t.Fatalf("unexpected meta calls: %v", ms.calls)
+	}
+}
+
+// NewTestService returns a service for node 1 with a shard per entry in
+// owners. Each shard is owned by the nodes in its entry and has a
+// replication factor of one.
+func NewTestService(nodes []meta.NodeInfo, owners [][]uint64) (*Service, *metaStore, *copierService) {
+	rpi := meta.RetentionPolicyInfo{Name: "default", ReplicaN: 1}
+	for i, a := range owners {
+		si := meta.ShardInfo{ID: uint64(i + 1)}
+		for _, id := range a {
+			si.Owners = append(si.Owners, meta.ShardOwner{NodeID: id})
+		}
+		rpi.ShardGroups = append(rpi.ShardGroups, meta.ShardGroupInfo{ID: si.ID, Shards: []meta.ShardInfo{si}})
+	}
+
+	ms := &metaStore{
+		nodes: nodes,
+		dbs:   []meta.DatabaseInfo{{Name: "db0", RetentionPolicies: []meta.RetentionPolicyInfo{rpi}}},
+	}
+	c := &copierService{}
+
+	s := NewService()
+	s.MetaStore = ms
+	s.Copier = c
+	s.HintedHandoff = &hintedHandoff{}
+	return s, ms, c
+}
+
+type metaStore struct {
+	nodes []meta.NodeInfo
+	dbs   []meta.DatabaseInfo
+	calls []string
+}
+
+func (m *metaStore) NodeID() uint64 { return 1 }
+
+func (m *metaStore) Node(id uint64) (*meta.NodeInfo, error) {
+	for i := range m.nodes {
+		if m.nodes[i].ID == id {
+			return &m.nodes[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *metaStore) Nodes() ([]meta.NodeInfo, error)         { return m.nodes, nil }
+func (m *metaStore) Databases() ([]meta.DatabaseInfo, error) { return m.dbs, nil }
+
+func (m *metaStore) RemoveNode(id uint64) error {
+	m.calls = append(m.calls, fmt.Sprintf("remove node %d", id))
+	return nil
+}
+
+type copierService struct {
+	moves []copier.Move
+	calls []string
+}
+
+func (c *copierService) MoveShard(shardID, from, to uint64) error {
+	c.calls = append(c.calls, fmt.Sprintf("move %d %d %d", shardID, from, to))
+	return nil
+}
+
+func (c *copierService) DropShard(shardID, from uint64) error {
+	c.calls = append(c.calls, fmt.Sprintf("drop %d %d", shardID, from))
+	return nil
+}
+
+func (c *copierService) Moves() []copier.Move { return c.moves }
+
+type hintedHandoff struct {
+	pending bool
+}
+
+func (h *hintedHandoff) Pending() bool { return h.pending }
@@ -15,30 +15,50 @@ const (
 	DefaultMaxAge = 7 * 24 * time.Hour
 
 	// DefaultRetryRateLimit is the default rate that hinted handoffs will be retried.
-	// The rate is in bytes per second and applies across all nodes when retried.   A
+	// The rate is in bytes per second and applies to each node separately.  A
 	// value of 0 disables the rate limit.
 	DefaultRetryRateLimit = 0
 
+	// DefaultRetryRateBurst is the default number of bytes that can be sent to a
+	// node at once after it has been idle. A value of 0 uses the rate limit.
+	DefaultRetryRateBurst = 0
+
 	// DefaultRetryInterval is the default amout of time the system waits before
-	// attempting to flush hinted handoff queues.
+	// attempting to flush hinted handoff queues. It is also the initial time the
+	// system waits before retrying a node after a failed write.
 	DefaultRetryInterval = time.Second
+
+	// DefaultRetryMaxInterval is the default maximum amount of time the system
+	// waits before retrying a node. The wait doubles after each failed write
+	// until it reaches this limit.
+	DefaultRetryMaxInterval = time.Minute
+
+	// DefaultLivenessCheck is the default for whether a node must be reachable
+	// before the writes queued for it are replayed after a failure.
+	DefaultLivenessCheck = true
 )
 
 type Config struct {
-	Enabled        bool          `toml:"enabled"`
-	Dir            string        `toml:"dir"`
-	MaxSize        int64         `toml:"max-size"`
-	MaxAge         toml.Duration `toml:"max-age"`
-	RetryRateLimit int64         `toml:"retry-rate-limit"`
-	RetryInterval  toml.Duration `toml:"retry-interval"`
+	Enabled          bool          `toml:"enabled"`
+	Dir              string        `toml:"dir"`
+	MaxSize          int64         `toml:"max-size"`
+	MaxAge           toml.Duration `toml:"max-age"`
+	RetryRateLimit   int64         `toml:"retry-rate-limit"`
+	RetryRateBurst   int64         `toml:"retry-rate-burst"`
+	RetryInterval    toml.Duration `toml:"retry-interval"`
+	RetryMaxInterval toml.Duration `toml:"retry-max-interval"`
+	LivenessCheck    bool          `toml:"liveness-check"`
 }
 
 func NewConfig() Config {
 	return Config{
-		Enabled:        true,
-		MaxSize:        DefaultMaxSize,
-		MaxAge:         toml.Duration(DefaultMaxAge),
-		RetryRateLimit: DefaultRetryRateLimit,
-		RetryInterval:  toml.Duration(DefaultRetryInterval),
+		Enabled:          true,
+		MaxSize:          DefaultMaxSize,
+		MaxAge:           toml.Duration(DefaultMaxAge),
+		RetryRateLimit:   DefaultRetryRateLimit,
+		RetryRateBurst:   DefaultRetryRateBurst,
+		RetryInterval:    toml.Duration(DefaultRetryInterval),
+		RetryMaxInterval: toml.Duration(DefaultRetryMaxInterval),
+		LivenessCheck:    DefaultLivenessCheck,
 	}
 }
@@ -17,6 +17,9 @@ retry-interval = "10m"
 max-size=2048
 max-age="20m"
 retry-rate-limit=1000
+retry-rate-burst=5000
+retry-max-interval="5m"
+liveness-check=false
 `, &c); err != nil {
 		t.Fatal(err)
 	}
@@ -42,4 +45,16 @@ retry-rate-limit=1000
 		t.Fatalf("unexpected retry rate limit: got %v, exp %v", c.RetryRateLimit, exp)
 	}
 
+	if exp := int64(5000); c.RetryRateBurst != exp {
+		t.Fatalf("unexpected retry rate burst: got %v, exp %v", c.RetryRateBurst, exp)
+	}
+
+	if exp := 5 * time.Minute; c.RetryMaxInterval.String() != exp.String() {
+		t.Fatalf("unexpected retry max interval: got %v, exp %v", c.RetryMaxInterval, exp)
+	}
+
+	if c.LivenessCheck {
+		t.Fatalf("unexpected liveness check: got %v, exp false", c.LivenessCheck)
+	}
+
 }
@@ -2,60 +2,56 @@ package hh
 
 import "time"
 
+// limiter is a token bucket restricting the number of bytes sent per second.
+// Tokens accumulate at the limit up to the burst size. Sends larger than the
+// available tokens are allowed but the caller must wait until the bucket has
+// refilled.
 type limiter struct {
-	count int64
-	limit int64
-	start time.Time
-	delay float64
+	limit  float64
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	now func() time.Time
 }
 
 // NewRateLimiter returns a new limiter configured to restrict a process to the limit per second.
-// limit is the maximum amount that can be used per second.  The limit should be > 0.  A limit
-// <= 0, will not limit the processes.
-func NewRateLimiter(limit int64) *limiter {
-	return &limiter{
-		start: time.Now(),
-		limit: limit,
-		delay: 0.5,
+// limit is the maximum amount that can be used per second and burst is the most that can be
+// used at once after being idle. A burst <= 0 defaults to the limit. A limit <= 0 will not
+// limit the processes.
+func NewRateLimiter(limit, burst int64) *limiter {
+	if burst <= 0 {
+		burst = limit
 	}
-}
 
-// Update updates the amount used
-func (t *limiter) Update(count int) {
-	t.count += int64(count)
+	t := &limiter{
+		limit:  float64(limit),
+		burst:  float64(burst),
+		tokens: float64(burst),
+		now:    time.Now,
+	}
+	t.last = t.now()
+	return t
 }
 
-// Delay returns the amount of time, up to 1 second, that caller should wait
-// to maintain the configured rate
-func (t *limiter) Delay() time.Duration {
-	if t.limit > 0 {
-
-		delta := time.Now().Sub(t.start).Seconds()
-		rate := int64(float64(t.count) / delta)
-
-		// Determine how far off from the max rate we are
-		delayAdj := float64((t.limit - rate)) / float64(t.limit)
-
-		// Don't adjust by more than 1 second at a time
-		delayAdj = t.clamp(delayAdj, -1, 1)
-
-		t.delay -= delayAdj
-		if t.delay < 0 {
-			t.delay = 0
-		}
-
-		return time.Duration(t.delay) * time.Second
+// Take removes count from the bucket and returns the amount of time the caller
+// should wait to maintain the configured rate.
+func (t *limiter) Take(count int) time.Duration {
+	if t.limit <= 0 {
+		return 0
 	}
-	return time.Duration(0)
-}
 
-func (t *limiter) clamp(value, min, max float64) float64 {
-	if value < min {
-		return min
+	// Refill the bucket for the time elapsed since the last call.
+	now := t.now()
+	t.tokens += now.Sub(t.last).Seconds() * t.limit
+	if t.tokens > t.burst {
+		t.tokens = t.burst
 	}
+	t.last = now
 
-	if value > max {
-		return max
+	t.tokens -= float64(count)
+	if t.tokens >= 0 {
+		return 0
 	}
-	return value
+	return time.Duration(-t.tokens / t.limit * float64(time.Second))
 }
@@ -6,42 +6,64 @@ import (
 )
 
 func TestLimiter(t *testing.T) {
-	l := NewRateLimiter(0)
-	l.Update(500)
-	if l.Delay().Nanoseconds() != 0 {
-		t.Errorf("limiter with no limit mismatch: got %v, exp 0", l.Delay())
+	l := NewRateLimiter(0, 0)
+	if d := l.Take(500); d != 0 {
+		t.Errorf("limiter with no limit mismatch: got %v, exp 0", d)
 	}
 }
 
 func TestLimiterWithinLimit(t *testing.T) {
-	if testing.Short() {
-		t.Skip("Shipping TestLimiterWithinLimit")
-	}
+	now := time.Unix(0, 0)
+	l := NewRateLimiter(1000, 0)
+	l.now = func() time.Time { return now }
+	l.last = now
 
-	l := NewRateLimiter(1000)
 	for i := 0; i < 100; i++ {
-		// 50 ever 100ms = 500/s which should be within the rate
-		l.Update(50)
-		l.Delay()
-		time.Sleep(100 * time.Millisecond)
+		// 50 every 100ms = 500/s which should be within the rate
+		if d := l.Take(50); d != 0 {
+			t.Fatalf("limiter delay mismatch: got %v, exp 0", d)
+		}
+		now = now.Add(100 * time.Millisecond)
+	}
+}
+
+func TestLimiterExceeded(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewRateLimiter(1000, 0)
+	l.now = func() time.Time { return now }
+	l.last = now
+
+	// The burst is used up by the first 5 writes.
+	for i := 0; i < 5; i++ {
+		if d := l.Take(200); d != 0 {
+			t.Fatalf("limiter delay mismatch: got %v, exp 0", d)
+		}
 	}
 
-	// Should not have any delay
-	delay := l.Delay().Seconds()
-	if exp := int(0); int(delay) != exp {
-		t.Errorf("limiter rate mismatch: got %v, exp %v", int(delay), exp)
+	if d := l.Take(200); d != 200*time.Millisecond {
+		t.Errorf("limiter delay mismatch: got %v, exp %v", d, 200*time.Millisecond)
 	}
 
+	// Waiting refills the bucket.
+	now = now.Add(time.Second)
+	if d := l.Take(500); d != 0 {
+		t.Errorf("limiter delay mismatch: got %v, exp 0", d)
+	}
 }
 
-func TestLimiterExceeded(t *testing.T) {
-	l := NewRateLimiter(1000)
-	for i := 0; i < 10; i++ {
-		l.Update(200)
-		l.Delay()
+func TestLimiterBurst(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewRateLimiter(100, 1000)
+	l.now = func() time.Time { return now }
+	l.last = now
+
+	if d := l.Take(1000); d != 0 {
+		t.Fatalf("limiter delay mismatch: got %v, exp 0", d)
 	}
-	delay := l.Delay().Seconds()
-	if int(delay) == 0 {
-		t.Errorf("limiter rate mismatch. expected non-zero delay")
+
+	// Idle time doesn't accumulate more than the burst.
+	now = now.Add(time.Hour)
+	if d := l.Take(1100); d != time.Second {
+		t.Errorf("limiter delay mismatch: got %v, exp %v", d, time.Second)
 	}
 }
@@ -4,15 +4,19 @@ import (
 	"encoding/binary"
 	"expvar"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
 
 	"github.com/influxdb/influxdb"
+	"github.com/influxdb/influxdb/meta"
 	"github.com/influxdb/influxdb/models"
 	"github.com/influxdb/influxdb/tsdb"
 )
@@ -23,26 +27,71 @@ const (
 	bytesWrite  = "bytes_write"
 )
 
+// ErrQueueNotFound is returned when no writes have been queued for a node.
+var ErrQueueNotFound = fmt.Errorf("hinted handoff queue not found")
+
 type Processor struct {
 	mu sync.RWMutex
 
-	dir            string
-	maxSize        int64
-	maxAge         time.Duration
-	retryRateLimit int64
+	dir              string
+	maxSize          int64
+	maxAge           time.Duration
+	retryRateLimit   int64
+	retryRateBurst   int64
+	retryInterval    time.Duration
+	retryMaxInterval time.Duration
+	livenessCheck    bool
 
 	queues map[uint64]*queue
 	writer shardWriter
 	Logger *log.Logger
 
+	// If set, the writes queued for nodes that were removed from the cluster
+	// are sent to the current owners of their shards instead.
+	MetaStore interface {
+		Node(id uint64) (*meta.NodeInfo, error)
+		ShardOwner(shardID uint64) (database, policy string, sgi *meta.ShardGroupInfo)
+	}
+
+	// Replay state of each node's queue.
+	stateMu sync.Mutex
+	nodes   map[uint64]*nodeState
+	now     func() time.Time
+
 	// Shard-level and node-level HH stats.
 	shardStatMaps map[uint64]*expvar.Map
 	nodeStatMaps  map[uint64]*expvar.Map
 }
 
 type ProcessorOptions struct {
-	MaxSize        int64
-	RetryRateLimit int64
+	MaxSize          int64
+	RetryRateLimit   int64
+	RetryRateBurst   int64
+	RetryInterval    time.Duration
+	RetryMaxInterval time.Duration
+
+	// If set, a node must respond to a ping before the writes queued for it
+	// are replayed after a failure.
+	LivenessCheck bool
+}
+
+// nodeState is the replay state of a node's queue.
+type nodeState struct {
+	paused    bool
+	lastError string
+
+	// Number of consecutive failed replays and the earliest time the next
+	// replay is attempted.
+	failures int
+	retryAt  time.Time
+
+	limiter *limiter
+
+	// queueMu is held while an entry of the queue is replayed or the queue
+	// is purged so entries aren't advanced past after a purge. The replay
+	// stops while purging is set.
+	queueMu sync.Mutex
+	purging bool
 }
 
 func NewProcessor(dir string, writer shardWriter, options ProcessorOptions) (*Processor, error) {
@@ -50,6 +99,8 @@ func NewProcessor(dir string, writer shardWriter, options ProcessorOptions) (*Pr
 		dir:           dir,
 		queues:        map[uint64]*queue{},
 		writer:        writer,
+		nodes:         make(map[uint64]*nodeState),
+		now:           time.Now,
 		Logger:        log.New(os.Stderr, "[handoff] ", log.LstdFlags),
 		shardStatMaps: make(map[uint64]*expvar.Map),
 		nodeStatMaps:  make(map[uint64]*expvar.Map),
@@ -77,6 +128,26 @@ func (p *Processor) setOptions(options ProcessorOptions) {
 	if options.RetryRateLimit != 0 {
 		p.retryRateLimit = options.RetryRateLimit
 	}
+
+	p.retryRateBurst = DefaultRetryRateBurst
+	if options.RetryRateBurst != 0 {
+		p.retryRateBurst = options.RetryRateBurst
+	}
+
+	p.retryInterval = DefaultRetryInterval
+	if options.RetryInterval != 0 {
+		p.retryInterval = options.RetryInterval
+	}
+
+	p.retryMaxInterval = DefaultRetryMaxInterval
+	if options.RetryMaxInterval != 0 {
+		p.retryMaxInterval = options.RetryMaxInterval
+	}
+	if p.retryMaxInterval < p.retryInterval {
+		p.retryMaxInterval = p.retryInterval
+	}
+
+	p.livenessCheck = options.LivenessCheck
 }
 
 func (p *Processor) loadQueues() error {
@@ -141,11 +212,20 @@ func (p *Processor) WriteShard(shardID, ownerID uint64, points []models.Point) e
 }
 
 func (p *Processor) Process() error {
+	// Paused queues and nodes that are backing off after a failure aren't
+	// replayed.
 	p.mu.RLock()
-	defer p.mu.RUnlock()
-
-	res := make(chan error, len(p.queues))
+	now := p.now()
+	queues := make(map[uint64]*queue, len(p.queues))
 	for nodeID, q := range p.queues {
+		if p.ready(nodeID, now) {
+			queues[nodeID] = q
+		}
+	}
+	p.mu.RUnlock()
+
+	res := make(chan error, len(queues))
+	for nodeID, q := range queues {
 		go func(nodeID uint64, q *queue) {
 
 			// Log how many writes we successfully sent at the end
@@ -157,55 +237,38 @@ func (p *Processor) Process() error {
 				}
 			}(start)
 
-			limiter := NewRateLimiter(p.retryRateLimit)
-			for {
-				// Get the current block from the queue
-				buf, err := q.Current()
-				if err != nil {
+			// Check the node is reachable before replaying to it again.
+			removed := p.removed(nodeID)
+			if !removed && p.needsLivenessCheck(nodeID, q) {
+				if err := p.writer.Ping(nodeID); err != nil {
+					p.Logger.Printf("node %d not reachable: %v", nodeID, err)
+					p.failed(nodeID, err)
 					res <- nil
-					break
-				}
-
-				// unmarshal the byte slice back to shard ID and points
-				shardID, points, err := p.unmarshalWrite(buf)
-				if err != nil {
-					p.Logger.Printf("unmarshal write failed: %v", err)
-					if err := q.Advance(); err != nil {
-						res <- err
-					}
 					return
 				}
+			}
 
-				// Try to send the write to the node
-				if err := p.writer.WriteShard(shardID, nodeID, points); err != nil && tsdb.IsRetryable(err) {
-					p.Logger.Printf("remote write failed: %v", err)
+			limiter := p.limiter(nodeID)
+			for {
+				// Stop if the queue was paused or purged during the replay.
+				if p.isStopped(nodeID) {
 					res <- nil
 					break
 				}
-				p.updateShardStats(shardID, pointsWrite, int64(len(points)))
-				p.nodeStatMaps[nodeID].Add(pointsWrite, int64(len(points)))
 
-				// If we get here, the write succeeded so advance the queue to the next item
-				if err := q.Advance(); err != nil {
+				if ok, err := p.replayNext(nodeID, q, removed, limiter); err != nil {
 					res <- err
 					return
+				} else if !ok {
+					res <- nil
+					break
 				}
-
 				sent += 1
-
-				// Update how many bytes we've sent
-				limiter.Update(len(buf))
-				p.updateShardStats(shardID, bytesWrite, int64(len(buf)))
-				p.nodeStatMaps[nodeID].Add(bytesWrite, int64(len(buf)))
-
-				// Block to maintain the throughput rate
-				time.Sleep(limiter.Delay())
-
 			}
 		}(nodeID, q)
 	}
 
-	for range p.queues {
+	for range queues {
 		err := <-res
 		if err != nil {
 			return err
@@ -214,6 +277,310 @@ func (p *Processor) Process() error {
 	return nil
 }
 
+// replayNext sends the entry at the head of a node's queue and advances the
+// queue. Returns false if the queue is empty or the write failed and should be
+// retried later. The queue can't be purged while the entry is sent.
+func (p *Processor) replayNext(nodeID uint64, q *queue, removed bool, limiter *limiter) (bool, error) {
+	mu := p.queueLock(nodeID)
+	mu.Lock()
+	defer mu.Unlock()
+
+	// Get the current block from the queue
+	buf, err := q.Current()
+	if err != nil {
+		return false, nil
+	}
+
+	// unmarshal the byte slice back to shard ID and points
+	shardID, points, err := p.unmarshalWrite(buf)
+	if err != nil {
+		p.Logger.Printf("unmarshal write failed: %v", err)
+		return false, q.Advance()
+	}
+
+	// Block to maintain the throughput rate
+	time.Sleep(limiter.Take(len(buf)))
+
+	// Try to send the write to the node
+	if removed {
+		err = p.reroute(shardID, points)
+	} else {
+		err = p.writer.WriteShard(shardID, nodeID, points)
+	}
+	if err != nil && tsdb.IsRetryable(err) {
+		p.Logger.Printf("remote write failed: %v", err)
+		p.failed(nodeID, err)
+		return false, nil
+	} else if err != nil {
+		p.setLastError(nodeID, err)
+	}
+	p.succeeded(nodeID)
+	p.updateShardStats(shardID, pointsWrite, int64(len(points)))
+	p.nodeStatMaps[nodeID].Add(pointsWrite, int64(len(points)))
+
+	// If we get here, the write succeeded so advance the queue to the next item
+	if err := q.Advance(); err != nil {
+		return false, err
+	}
+
+	// Update how many bytes we've sent
+	p.updateShardStats(shardID, bytesWrite, int64(len(buf)))
+	p.nodeStatMaps[nodeID].Add(bytesWrite, int64(len(buf)))
+	return true, nil
+}
+
+// queueLock returns the lock held while a node's queue is replayed or purged.
+func (p *Processor) queueLock(nodeID uint64) *sync.Mutex {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+	return &p.node(nodeID).queueMu
+}
+
+// removed returns true if a node was removed from the cluster.
+func (p *Processor) removed(nodeID uint64) bool {
+	if p.MetaStore == nil {
+		return false
+	}
+	ni, err := p.MetaStore.Node(nodeID)
+	return err == nil && ni == nil
+}
+
+// reroute writes points to the current owners of a shard. The points are
+// dropped if the shard was deleted.
+func (p *Processor) reroute(shardID uint64, points []models.Point) error {
+	_, _, sgi := p.MetaStore.ShardOwner(shardID)
+	if sgi == nil {
+		return nil
+	}
+	for _, si := range sgi.Shards {
+		if si.ID != shardID {
+			continue
+		}
+		for _, so := range si.Owners {
+			if err := p.writer.WriteShard(shardID, so.NodeID, points); err != nil {
+				return fmt.Errorf("reroute to node %d: %s", so.NodeID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Pending returns true if writes are queued for any node.
+func (p *Processor) Pending() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, q := range p.queues {
+		if _, err := q.Current(); err != io.EOF {
+			return true
+		}
+	}
+	return false
+}
+
+// QueueStatus is the state of the writes queued for a node.
+type QueueStatus struct {
+	NodeID uint64
+
+	// Size is the number of bytes used on disk by the queue.
+	Size int64
+
+	// Oldest is the time the oldest queued write was queued. It's the
+	// zero time if nothing is queued.
+	Oldest time.Time
+
+	// LastError is the last error returned when replaying to the node.
+	LastError string
+
+	// Paused is true if the queue isn't being replayed.
+	Paused bool
+}
+
+// Status returns the state of every queue sorted by node ID.
+func (p *Processor) Status() ([]QueueStatus, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	a := make(queueStatuses, 0, len(p.queues))
+	for nodeID, q := range p.queues {
+		oldest, err := q.Oldest()
+		if err != nil {
+			return nil, err
+		}
+
+		p.stateMu.Lock()
+		st := p.node(nodeID)
+		a = append(a, QueueStatus{
+			NodeID:    nodeID,
+			Size:      q.Size(),
+			Oldest:    oldest,
+			LastError: st.lastError,
+			Paused:    st.paused,
+		})
+		p.stateMu.Unlock()
+	}
+	sort.Sort(a)
+	return a, nil
+}
+
+// Purge drops every write queued for a node. It only waits for the write
+// being replayed to the node, if any.
+func (p *Processor) Purge(nodeID uint64) error {
+	p.mu.RLock()
+	q, ok := p.queues[nodeID]
+	p.mu.RUnlock()
+	if !ok {
+		return ErrQueueNotFound
+	}
+
+	// Stop the replay and wait for the write being sent.
+	p.setPurging(nodeID, true)
+	defer p.setPurging(nodeID, false)
+
+	mu := p.queueLock(nodeID)
+	mu.Lock()
+	defer mu.Unlock()
+	if err := q.Purge(); err != nil {
+		return err
+	}
+	p.Logger.Printf("purged hinted handoff queue for node %d", nodeID)
+	return nil
+}
+
+// Pause stops replaying the writes queued for a node. Writes are still
+// queued while the queue is paused.
+func (p *Processor) Pause(nodeID uint64) error {
+	return p.setPaused(nodeID, true)
+}
+
+// Resume restarts replaying the writes queued for a node.
+func (p *Processor) Resume(nodeID uint64) error {
+	return p.setPaused(nodeID, false)
+}
+
+func (p *Processor) setPaused(nodeID uint64, paused bool) error {
+	p.mu.RLock()
+	_, ok := p.queues[nodeID]
+	p.mu.RUnlock()
+	if !ok {
+		return ErrQueueNotFound
+	}
+
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+	p.node(nodeID).paused = paused
+	return nil
+}
+
+func (p *Processor) setPurging(nodeID uint64, purging bool) {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+	p.node(nodeID).purging = purging
+}
+
+// isStopped returns true if a node's queue is paused or being purged.
+func (p *Processor) isStopped(nodeID uint64) bool {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+	st := p.node(nodeID)
+	return st.paused || st.purging
+}
+
+// ready returns true if a node's queue can be replayed at now.
+func (p *Processor) ready(nodeID uint64, now time.Time) bool {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+	st := p.node(nodeID)
+	return !st.paused && !now.Before(st.retryAt)
+}
+
+// needsLivenessCheck returns true if the node must be pinged before its queue
+// is replayed. Only nodes that failed and have writes queued are checked.
+func (p *Processor) needsLivenessCheck(nodeID uint64, q *queue) bool {
+	if !p.livenessCheck {
+		return false
+	}
+
+	p.stateMu.Lock()
+	failures := p.node(nodeID).failures
+	p.stateMu.Unlock()
+	if failures == 0 {
+		return false
+	}
+
+	_, err := q.Current()
+	return err == nil
+}
+
+// limiter returns the rate limiter of a node.
+func (p *Processor) limiter(nodeID uint64) *limiter {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+	st := p.node(nodeID)
+	if st.limiter == nil {
+		st.limiter = NewRateLimiter(p.retryRateLimit, p.retryRateBurst)
+	}
+	return st.limiter
+}
+
+// failed records a failed replay to a node and backs off before the next
+// replay is attempted. The wait doubles with each consecutive failure up to
+// the maximum retry interval.
+func (p *Processor) failed(nodeID uint64, err error) {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+
+	st := p.node(nodeID)
+	st.lastError = err.Error()
+	st.failures++
+
+	d := p.retryInterval
+	for i := 1; i < st.failures && d < p.retryMaxInterval; i++ {
+		d *= 2
+	}
+	if d > p.retryMaxInterval {
+		d = p.retryMaxInterval
+	}
+
+	// Jitter the wait so replays to a recovering node from every other node
+	// don't all start at once.
+	d = d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+	st.retryAt = p.now().Add(d)
+}
+
+// succeeded resets the backoff of a node after a successful write.
+func (p *Processor) succeeded(nodeID uint64) {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+	st := p.node(nodeID)
+	st.failures = 0
+	st.retryAt = time.Time{}
+}
+
+func (p *Processor) setLastError(nodeID uint64, err error) {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+	p.node(nodeID).lastError = err.Error()
+}
+
+// node returns the replay state of a node, creating it if it doesn't exist.
+// stateMu must be held.
+func (p *Processor) node(nodeID uint64) *nodeState {
+	st := p.nodes[nodeID]
+	if st == nil {
+		st = &nodeState{}
+		p.nodes[nodeID] = st
+	}
+	return st
+}
+
+// queueStatuses sorts queue statuses by node ID.
+type queueStatuses []QueueStatus
+
+func (a queueStatuses) Len() int           { return len(a) }
+func (a queueStatuses) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a queueStatuses) Less(i, j int) bool { return a[i].NodeID < a[j].NodeID }
+
 func (p *Processor) marshalWrite(shardID uint64, points []models.Point) []byte {
 	b := make([]byte, 8)
 	binary.BigEndian.PutUint64(b, shardID)
@@ -245,13 +612,24 @@ func (p *Processor) updateShardStats(shardID uint64, stat string, inc int64) {
 }
 
 func (p *Processor) PurgeOlderThan(when time.Duration) error {
-	p.mu.Lock()
-	defer p.mu.Unlock()
+	p.mu.RLock()
+	queues := make(map[uint64]*queue, len(p.queues))
+	for nodeID, q := range p.queues {
+		queues[nodeID] = q
+	}
+	p.mu.RUnlock()
 
-	for _, queue := range p.queues {
-		if err := queue.PurgeOlderThan(time.Now().Add(-when)); err != nil {
+	for nodeID, q := range queues {
+		if err := p.purgeOlderThan(nodeID, q, time.Now().Add(-when)); err != nil {
 			return err
 		}
 	}
 	return nil
 }
+
+func (p *Processor) purgeOlderThan(nodeID uint64, q *queue, when time.Time) error {
+	mu := p.queueLock(nodeID)
+	mu.Lock()
+	defer mu.Unlock()
+	return q.PurgeOlderThan(when)
+}
@@ -1,21 +1,33 @@
 package hh
 
 import (
+	"errors"
 	"io/ioutil"
+	"os"
+	"reflect"
 	"testing"
 	"time"
 
+	"github.com/influxdb/influxdb/meta"
 	"github.com/influxdb/influxdb/models"
 )
 
 type fakeShardWriter struct {
 	ShardWriteFn func(shardID, nodeID uint64, points []models.Point) error
+	PingFn       func(nodeID uint64) error
 }
 
 func (f *fakeShardWriter) WriteShard(shardID, nodeID uint64, points []models.Point) error {
 	return f.ShardWriteFn(shardID, nodeID, points)
 }
 
+func (f *fakeShardWriter) Ping(nodeID uint64) error {
+	if f.PingFn == nil {
+		return nil
+	}
+	return f.PingFn(nodeID)
+}
+
 func TestProcessorProcess(t *testing.T) {
 	dir, err := ioutil.TempDir("", "processor_test")
 	if err != nil {
@@ -58,6 +70,10 @@ func TestProcessorProcess(t *testing.T) {
 		t.Fatalf("Process() failed to write points: %v", err)
 	}
 
+	if !p.Pending() {
+		t.Fatalf("Pending() mismatch: got false, exp true")
+	}
+
 	// This should send the write to the shard writer
 	if err := p.Process(); err != nil {
 		t.Fatalf("Process() failed to write points: %v", err)
@@ -67,6 +83,10 @@ func TestProcessorProcess(t *testing.T) {
 		t.Fatalf("Process() write count mismatch: got %v, exp %v", count, exp)
 	}
 
+	if p.Pending() {
+		t.Fatalf("Pending() mismatch: got true, exp false")
+	}
+
 	// Queue should be empty so no writes should be send again
 	if err := p.Process(); err != nil {
 		t.Fatalf("Process() failed to write points: %v", err)
@@ -78,3 +98,334 @@ func TestProcessorProcess(t *testing.T) {
 	}
 
 }
+
+func TestProcessorPause(t *testing.T) {
+	dir, err := ioutil.TempDir("", "processor_test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+
+	var count int
+	sh := &fakeShardWriter{
+		ShardWriteFn: func(shardID, nodeID uint64, points []models.Point) error {
+			count += 1
+			return nil
+		},
+	}
+
+	p, err := NewProcessor(dir, sh, ProcessorOptions{MaxSize: 1024})
+	if err != nil {
+		t.Fatalf("Process() failed to create processor: %v", err)
+	}
+
+	if err := p.Pause(200); err != ErrQueueNotFound {
+		t.Fatalf("Pause() error mismatch: got %v, exp %v", err, ErrQueueNotFound)
+	}
+
+	pt := models.NewPoint("cpu", models.Tags{"foo": "bar"}, models.Fields{"value": 1.0}, time.Unix(0, 0))
+	if err := p.WriteShard(100, 200, []models.Point{pt}); err != nil {
+		t.Fatalf("Process() failed to write points: %v", err)
+	}
+
+	// A paused queue isn't replayed.
+	if err := p.Pause(200); err != nil {
+		t.Fatalf("Pause() failed: %v", err)
+	} else if err := p.Process(); err != nil {
+		t.Fatalf("Process() failed to write points: %v", err)
+	} else if count != 0 {
+		t.Fatalf("Process() write count mismatch: got %v, exp 0", count)
+	}
+
+	if a, err := p.Status(); err != nil {
+		t.Fatalf("Status() failed: %v", err)
+	} else if len(a) != 1 || a[0].NodeID != 200 || !a[0].Paused || a[0].Size == 0 || a[0].Oldest.IsZero() {
+		t.Fatalf("Status() mismatch: got %+v", a)
+	}
+
+	// The queue is replayed once it's resumed.
+	if err := p.Resume(200); err != nil {
+		t.Fatalf("Resume() failed: %v", err)
+	} else if err := p.Process(); err != nil {
+		t.Fatalf("Process() failed to write points: %v", err)
+	} else if count != 1 {
+		t.Fatalf("Process() write count mismatch: got %v, exp 1", count)
+	}
+}
+
+func TestProcessorPurge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "processor_test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+
+	sh := &fakeShardWriter{
+		ShardWriteFn: func(shardID, nodeID uint64, points []models.Point) error {
+			return errors.New("node down")
+		},
+	}
+
+	p, err := NewProcessor(dir, sh, ProcessorOptions{MaxSize: 1024})
+	if err != nil {
+		t.Fatalf("Process() failed to create processor: %v", err)
+	}
+
+	pt := models.NewPoint("cpu", models.Tags{"foo": "bar"}, models.Fields{"value": 1.0}, time.Unix(0, 0))
+	if err := p.WriteShard(100, 200, []models.Point{pt}); err != nil {
+		t.Fatalf("Process() failed to write points: %v", err)
+	} else if err := p.Process(); err != nil {
+		t.Fatalf("Process() failed to write points: %v", err)
+	}
+
+	if a, err := p.Status(); err != nil {
+		t.Fatalf("Status() failed: %v", err)
+	} else if len(a) != 1 || a[0].LastError != "node down" {
+		t.Fatalf("Status() mismatch: got %+v", a)
+	}
+
+	if err := p.Purge(200); err != nil {
+		t.Fatalf("Purge() failed: %v", err)
+	} else if p.Pending() {
+		t.Fatalf("Pending() mismatch: got true, exp false")
+	}
+}
+
+// Ensure a queue can be purged while it's being replayed and the writes left
+// in the queue aren't sent.
+func TestProcessorPurge_Replaying(t *testing.T) {
+	dir, err := ioutil.TempDir("", "processor_test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var n int
+	writing, release := make(chan struct{}), make(chan struct{})
+	sh := &fakeShardWriter{
+		ShardWriteFn: func(shardID, nodeID uint64, points []models.Point) error {
+			if n++; n == 1 {
+				close(writing)
+				<-release
+			}
+			return nil
+		},
+	}
+
+	p, err := NewProcessor(dir, sh, ProcessorOptions{MaxSize: 1024})
+	if err != nil {
+		t.Fatalf("Process() failed to create processor: %v", err)
+	}
+
+	pt := models.NewPoint("cpu", models.Tags{"foo": "bar"}, models.Fields{"value": 1.0}, time.Unix(0, 0))
+	for i := 0; i < 2; i++ {
+		if err := p.WriteShard(100, 200, []models.Point{pt}); err != nil {
+			t.Fatalf("Process() failed to write points: %v", err)
+		}
+	}
+
+	processed := make(chan error)
+	go func() { processed <- p.Process() }()
+	<-writing
+
+	// The purge waits for the write being sent, not for the whole replay.
+	purged := make(chan error)
+	go func() { purged <- p.Purge(200) }()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	if err := <-purged; err != nil {
+		t.Fatalf("Purge() failed: %v", err)
+	} else if err := <-processed; err != nil {
+		t.Fatalf("Process() failed: %v", err)
+	} else if n != 1 {
+		t.Fatalf("unexpected writes: got %d, exp 1", n)
+	} else if p.Pending() {
+		t.Fatalf("Pending() mismatch: got true, exp false")
+	}
+}
+
+func TestProcessorBackoff(t *testing.T) {
+	dir, err := ioutil.TempDir("", "processor_test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+
+	var count int
+	var down = true
+	sh := &fakeShardWriter{
+		ShardWriteFn: func(shardID, nodeID uint64, points []models.Point) error {
+			count += 1
+			if down {
+				return errors.New("node down")
+			}
+			return nil
+		},
+	}
+
+	p, err := NewProcessor(dir, sh, ProcessorOptions{
+		MaxSize:          1024,
+		RetryInterval:    time.Second,
+		RetryMaxInterval: 4 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("Process() failed to create processor: %v", err)
+	}
+	now := time.Unix(0, 0)
+	p.now = func() time.Time { return now }
+
+	pt := models.NewPoint("cpu", models.Tags{"foo": "bar"}, models.Fields{"value": 1.0}, time.Unix(0, 0))
+	if err := p.WriteShard(100, 200, []models.Point{pt}); err != nil {
+		t.Fatalf("Process() failed to write points: %v", err)
+	}
+
+	// Each failure doubles the wait, with jitter, up to the max interval.
+	for i, max := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		if err := p.Process(); err != nil {
+			t.Fatalf("Process() failed to write points: %v", err)
+		} else if count != i+1 {
+			t.Fatalf("Process() write count mismatch: got %v, exp %v", count, i+1)
+		}
+
+		// Not retried while backing off.
+		now = now.Add(max/2 - time.Nanosecond)
+		if err := p.Process(); err != nil {
+			t.Fatalf("Process() failed to write points: %v", err)
+		} else if count != i+1 {
+			t.Fatalf("Process() write count mismatch: got %v, exp %v", count, i+1)
+		}
+		now = now.Add(max/2 + time.Nanosecond)
+	}
+
+	// A successful write resets the backoff.
+	down = false
+	if err := p.Process(); err != nil {
+		t.Fatalf("Process() failed to write points: %v", err)
+	} else if p.Pending() {
+		t.Fatalf("Pending() mismatch: got true, exp false")
+	} else if st := p.nodes[200]; st.failures != 0 || !st.retryAt.IsZero() {
+		t.Fatalf("backoff not reset: %+v", st)
+	}
+}
+
+func TestProcessorLivenessCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "processor_test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+
+	var writes, pings int
+	var down = true
+	sh := &fakeShardWriter{
+		ShardWriteFn: func(shardID, nodeID uint64, points []models.Point) error {
+			writes += 1
+			if down {
+				return errors.New("node down")
+			}
+			return nil
+		},
+		PingFn: func(nodeID uint64) error {
+			pings += 1
+			if down {
+				return errors.New("node down")
+			}
+			return nil
+		},
+	}
+
+	p, err := NewProcessor(dir, sh, ProcessorOptions{MaxSize: 1024, LivenessCheck: true})
+	if err != nil {
+		t.Fatalf("Process() failed to create processor: %v", err)
+	}
+	now := time.Unix(0, 0)
+	p.now = func() time.Time { return now }
+
+	pt := models.NewPoint("cpu", models.Tags{"foo": "bar"}, models.Fields{"value": 1.0}, time.Unix(0, 0))
+	if err := p.WriteShard(100, 200, []models.Point{pt}); err != nil {
+		t.Fatalf("Process() failed to write points: %v", err)
+	}
+
+	// The node isn't pinged before the first failure.
+	if err := p.Process(); err != nil {
+		t.Fatalf("Process() failed to write points: %v", err)
+	} else if writes != 1 || pings != 0 {
+		t.Fatalf("Process() mismatch: got %d writes and %d pings, exp 1 and 0", writes, pings)
+	}
+
+	// The queue isn't replayed while the node can't be reached.
+	now = now.Add(time.Hour)
+	if err := p.Process(); err != nil {
+		t.Fatalf("Process() failed to write points: %v", err)
+	} else if writes != 1 || pings != 1 {
+		t.Fatalf("Process() mismatch: got %d writes and %d pings, exp 1 and 1", writes, pings)
+	}
+
+	down = false
+	now = now.Add(time.Hour)
+	if err := p.Process(); err != nil {
+		t.Fatalf("Process() failed to write points: %v", err)
+	} else if writes != 2 || pings != 2 {
+		t.Fatalf("Process() mismatch: got %d writes and %d pings, exp 2 and 2", writes, pings)
+	} else if p.Pending() {
+		t.Fatalf("Pending() mismatch: got true, exp false")
+	}
+}
+
+type fakeMetaStore struct {
+	nodes map[uint64]bool
+	sgi   *meta.ShardGroupInfo
+}
+
+func (m *fakeMetaStore) Node(id uint64) (*meta.NodeInfo, error) {
+	if !m.nodes[id] {
+		return nil, nil
+	}
+	return &meta.NodeInfo{ID: id}, nil
+}
+
+func (m *fakeMetaStore) ShardOwner(shardID uint64) (string, string, *meta.ShardGroupInfo) {
+	return "db0", "rp0", m.sgi
+}
+
+// Ensure writes queued for a removed node are sent to the shard's owners.
+func TestProcessorReroute(t *testing.T) {
+	dir, err := ioutil.TempDir("", "processor_test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var writes []uint64
+	sh := &fakeShardWriter{
+		ShardWriteFn: func(shardID, nodeID uint64, points []models.Point) error {
+			writes = append(writes, nodeID)
+			return nil
+		},
+		PingFn: func(nodeID uint64) error {
+			t.Fatalf("removed node %d pinged", nodeID)
+			return nil
+		},
+	}
+
+	p, err := NewProcessor(dir, sh, ProcessorOptions{MaxSize: 1024, LivenessCheck: true})
+	if err != nil {
+		t.Fatalf("failed to create processor: %v", err)
+	}
+	p.MetaStore = &fakeMetaStore{
+		nodes: map[uint64]bool{2: true, 3: true},
+		sgi:   &meta.ShardGroupInfo{Shards: []meta.ShardInfo{{ID: 100, Owners: []meta.ShardOwner{{NodeID: 2}, {NodeID: 3}}}}},
+	}
+
+	pt := models.NewPoint("cpu", models.Tags{"foo": "bar"}, models.Fields{"value": 1.0}, time.Unix(0, 0))
+	if err := p.WriteShard(100, 1, []models.Point{pt}); err != nil {
+		t.Fatalf("failed to write points: %v", err)
+	}
+	p.failed(1, errors.New("marker"))
+	p.now = func() time.Time { return time.Now().Add(time.Hour) }
+
+	if err := p.Process(); err != nil {
+		t.Fatalf("Process() failed: %v", err)
+	} else if !reflect.DeepEqual(writes, []uint64{2, 3}) {
+		t.Fatalf("unexpected writes: %v", writes)
+	} else if p.Pending() {
+		t.Fatal("expected queue to be drained")
+	}
+}
@@ -21,6 +21,11 @@ var (
 const (
 	defaultSegmentSize = 10 * 1024 * 1024
 	footerSize         = 8
+
+	// timestampFlag is set in the length of blocks that are followed by the
+	// time they were appended. Blocks written before timestamps were added
+	// don't have it.
+	timestampFlag = 1 << 63
 )
 
 // queue is a bounded, disk-backed, append-only type that combines queue and
@@ -165,20 +170,73 @@ func (l *queue) PurgeOlderThan(when time.Time) error {
 		return err
 	}
 
+	// Remove the segments whose newest entry is older than the cutoff.
 	cutoff := when.Truncate(time.Second)
-	for {
-		mod, err := l.head.lastModified()
-		if err != nil {
+	for len(l.segments) > 1 {
+		if !l.head.newest().Before(cutoff) {
+			return nil
+		}
+		if err := l.trimHead(); err != nil {
 			return err
 		}
+	}
+	return nil
+}
 
-		if mod.After(cutoff) || mod.Equal(cutoff) {
-			return nil
+// Purge removes every entry from the queue.
+func (l *queue) Purge() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.head == nil {
+		return ErrNotOpen
+	}
+
+	// Add a new empty segment and remove all the others.
+	segment, err := l.addSegment()
+	if err != nil {
+		return err
+	}
+	for _, s := range l.segments[:len(l.segments)-1] {
+		if err := s.close(); err != nil {
+			return err
 		}
-		if err := l.trimHead(); err != nil {
+		if err := os.Remove(s.path); err != nil {
 			return err
 		}
 	}
+	l.segments = segments{segment}
+	l.head, l.tail = segment, segment
+	return nil
+}
+
+// Size returns the total size on disk used by the queue.
+func (l *queue) Size() int64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.diskUsage()
+}
+
+// Oldest returns the time the oldest entry was appended. Returns the zero time
+// if the queue is empty.
+func (l *queue) Oldest() (time.Time, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if l.head == nil {
+		return time.Time{}, ErrNotOpen
+	}
+
+	for _, s := range l.segments {
+		t, err := s.oldest()
+		if err == io.EOF {
+			continue
+		} else if err != nil {
+			return time.Time{}, err
+		}
+		return t, nil
+	}
+	return time.Time{}, nil
 }
 
 // diskUsage returns the total size on disk used by the queue
@@ -280,13 +338,14 @@ func (l *queue) Append(b []byte) error {
 
 	// Append the entry to the tail, if the segment is full,
 	// try to create new segment and retry the append
-	if err := l.tail.append(b); err == ErrSegmentFull {
+	now := time.Now()
+	if err := l.tail.append(b, now); err == ErrSegmentFull {
 		segment, err := l.addSegment()
 		if err != nil {
 			return err
 		}
 		l.tail = segment
-		return l.tail.append(b)
+		return l.tail.append(b, now)
 	}
 	return nil
 }
@@ -334,16 +393,20 @@ func (l *queue) trimHead() error {
 }
 
 // Segment is a queue using a single file.  The structure of a segment is a series
-// lengths + block with a single footer point to the position in the segment of the
-// current head block.
+// lengths + timestamp + block with a single footer point to the position in the
+// segment of the current head block.
+//
+// ┌────────────────────────────────────────┐ ┌────────────┐
+// │                Block 1                 │ │   Footer   │
+// └────────────────────────────────────────┘ └────────────┘
+// ┌────────────┐┌────────────┐┌────────────┐ ┌────────────┐
+// │Block 1 Len ││ Timestamp  ││Block 1 Body│ │Head Offset │
+// │  8 bytes   ││  8 bytes   ││  N bytes   │ │  8 bytes   │
+// └────────────┘└────────────┘└────────────┘ └────────────┘
 //
-// ┌──────────────────────────┐ ┌──────────────────────────┐ ┌────────────┐
-// │         Block 1          │ │         Block 2          │ │   Footer   │
-// └──────────────────────────┘ └──────────────────────────┘ └────────────┘
-// ┌────────────┐┌────────────┐ ┌────────────┐┌────────────┐ ┌────────────┐
-// │Block 1 Len ││Block 1 Body│ │Block 2 Len ││Block 2 Body│ │Head Offset │
-// │  8 bytes   ││  N bytes   │ │  8 bytes   ││  N bytes   │ │  8 bytes   │
-// └────────────┘└────────────┘ └────────────┘└────────────┘ └────────────┘
+// The timestamp is the time the block was appended in nanoseconds. Blocks written
+// before timestamps were added have no timestamp and the high bit of their length
+// unset.
 //
 // The footer holds the pointer to the head entry at the end of the segment to allow writes
 // to seek to the end and write sequentially (vs having to seek back to the beginning of
@@ -360,8 +423,10 @@ type segment struct {
 	path string
 
 	pos         int64
-	currentSize int64
+	currentSize int64 // size of the current block after its length
 	maxSize     int64
+
+	last time.Time // time the newest block was appended
 }
 
 func newSegment(path string, maxSize int64) (*segment, error) {
@@ -375,7 +440,7 @@ func newSegment(path string, maxSize int64) (*segment, error) {
 		return nil, err
 	}
 
-	s := &segment{file: f, path: path, size: stats.Size(), maxSize: maxSize}
+	s := &segment{file: f, path: path, size: stats.Size(), maxSize: maxSize, last: stats.ModTime()}
 
 	if err := s.open(); err != nil {
 		return nil, err
@@ -428,14 +493,38 @@ func (l *segment) open() error {
 		if err != nil {
 			return err
 		}
-		l.currentSize = int64(currentSize)
+		l.currentSize = blockSize(currentSize)
 	}
 
+	return l.scan()
+}
+
+// scan sets the time the newest block was appended from the timestamps of the
+// unread blocks. Without timestamps the file's modification time is kept.
+func (l *segment) scan() error {
+	for pos := l.pos; pos < l.size-footerSize; {
+		if err := l.seek(pos); err != nil {
+			return err
+		}
+		sz, err := l.readUint64()
+		if err != nil {
+			return err
+		}
+
+		if sz&timestampFlag != 0 {
+			ts, err := l.readUint64()
+			if err != nil {
+				return err
+			}
+			l.last = time.Unix(0, int64(ts))
+		}
+		pos += blockSize(sz) + 8
+	}
 	return nil
 }
 
 // append adds byte slice to the end of segment
-func (l *segment) append(b []byte) error {
+func (l *segment) append(b []byte, now time.Time) error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
@@ -451,7 +540,11 @@ func (l *segment) append(b []byte) error {
 		return err
 	}
 
-	if err := l.writeUint64(uint64(len(b))); err != nil {
+	if err := l.writeUint64(uint64(len(b)) | timestampFlag); err != nil {
+		return err
+	}
+
+	if err := l.writeUint64(uint64(now.UnixNano())); err != nil {
 		return err
 	}
 
@@ -468,10 +561,11 @@ func (l *segment) append(b []byte) error {
 	}
 
 	if l.currentSize == 0 {
-		l.currentSize = int64(len(b))
+		l.currentSize = int64(len(b)) + 8
 	}
 
-	l.size += int64(len(b)) + 8 // uint64 for slice length
+	l.size += int64(len(b)) + 16 // uint64 for slice length and timestamp
+	l.last = now
 
 	return nil
 }
@@ -489,14 +583,22 @@ func (l *segment) current() ([]byte, error) {
 		return nil, err
 	}
 
-	// read the record size
-	sz, err := l.readUint64()
+	// read the record size and skip its timestamp
+	v, err := l.readUint64()
 	if err != nil {
 		return nil, err
 	}
-	l.currentSize = int64(sz)
+	l.currentSize = blockSize(v)
+
+	sz := l.currentSize
+	if v&timestampFlag != 0 {
+		if _, err := l.readUint64(); err != nil {
+			return nil, err
+		}
+		sz -= 8
+	}
 
-	if int64(sz) > l.maxSize {
+	if sz > l.maxSize {
 		return nil, fmt.Errorf("record size out of range: max %d: got %d", l.maxSize, sz)
 	}
 
@@ -545,7 +647,7 @@ func (l *segment) advance() error {
 	if err != nil {
 		return err
 	}
-	l.currentSize = int64(sz)
+	l.currentSize = blockSize(sz)
 
 	if int64(l.pos) == l.size-footerSize {
 		l.currentSize = 0
@@ -565,15 +667,45 @@ func (l *segment) close() error {
 	return nil
 }
 
-func (l *segment) lastModified() (time.Time, error) {
-	l.mu.RLock()
-	defer l.mu.RUnlock()
+// oldest returns the time the current block was appended. The file's
+// modification time is returned for blocks without a timestamp. Returns
+// io.EOF if there are no unread blocks.
+func (l *segment) oldest() (time.Time, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if int64(l.pos) == l.size-footerSize {
+		return time.Time{}, io.EOF
+	}
 
-	stats, err := os.Stat(l.file.Name())
+	if err := l.seekToCurrent(); err != nil {
+		return time.Time{}, err
+	}
+	sz, err := l.readUint64()
 	if err != nil {
 		return time.Time{}, err
 	}
-	return stats.ModTime(), nil
+
+	if sz&timestampFlag == 0 {
+		stats, err := l.file.Stat()
+		if err != nil {
+			return time.Time{}, err
+		}
+		return stats.ModTime(), nil
+	}
+
+	ts, err := l.readUint64()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, int64(ts)), nil
+}
+
+// newest returns the time the newest block was appended.
+func (l *segment) newest() time.Time {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.last
 }
 
 func (l *segment) diskUsage() int64 {
@@ -655,6 +787,15 @@ func (l *segment) readBytes(b []byte) error {
 	return nil
 }
 
+// blockSize returns the size of a block after its length field from the
+// length's encoded value.
+func blockSize(v uint64) int64 {
+	if v&timestampFlag != 0 {
+		return int64(v&^timestampFlag) + 8
+	}
+	return int64(v)
+}
+
 func u64tob(v uint64) []byte {
 	b := make([]byte, 8)
 	binary.BigEndian.PutUint64(b, v)
@@ -60,8 +60,8 @@ func TestQueueAppendOne(t *testing.T) {
 		t.Fatalf("Queue.Append file not exists. exp %v to exist", exp)
 	}
 
-	// 8 byte header ptr + 8 byte record len + record len
-	if exp := int64(8 + 8 + 4); stats.Size() != exp {
+	// 8 byte header ptr + 8 byte record len + 8 byte timestamp + record len
+	if exp := int64(8 + 8 + 8 + 4); stats.Size() != exp {
 		t.Fatalf("Queue.Append file size mismatch. got %v, exp %v", stats.Size(), exp)
 	}
 
@@ -325,3 +325,115 @@ func TestPurgeQueue(t *testing.T) {
 	}
 
 }
+
+func TestQueuePurgeAll(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hh_queue")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	q, err := newQueue(dir, 1024)
+	if err != nil {
+		t.Fatalf("failed to create queue: %v", err)
+	}
+
+	if err := q.Open(); err != nil {
+		t.Fatalf("failed to open queue: %v", err)
+	}
+
+	if oldest, err := q.Oldest(); err != nil {
+		t.Fatalf("Queue.Oldest failed: %v", err)
+	} else if !oldest.IsZero() {
+		t.Fatalf("Queue.Oldest mismatch: got %v, exp zero time", oldest)
+	}
+
+	for _, s := range []string{"one", "two"} {
+		if err := q.Append([]byte(s)); err != nil {
+			t.Fatalf("Queue.Append failed: %v", err)
+		}
+	}
+
+	if oldest, err := q.Oldest(); err != nil {
+		t.Fatalf("Queue.Oldest failed: %v", err)
+	} else if oldest.IsZero() {
+		t.Fatal("Queue.Oldest mismatch: got zero time")
+	}
+
+	if err := q.Purge(); err != nil {
+		t.Fatalf("Queue.Purge failed: %v", err)
+	}
+
+	if _, err := q.Current(); err != io.EOF {
+		t.Fatalf("Queue.Current expected io.EOF, got: %v", err)
+	} else if size := q.Size(); size != 8 {
+		// An empty segment only holds the position of its current entry.
+		t.Fatalf("Queue.Size mismatch: got %v, exp 8", size)
+	}
+
+	// The queue is still usable after the purge.
+	if err := q.Append([]byte("three")); err != nil {
+		t.Fatalf("Queue.Append failed: %v", err)
+	}
+	if cur, err := q.Current(); err != nil {
+		t.Fatalf("Queue.Current failed: %v", err)
+	} else if exp := "three"; string(cur) != exp {
+		t.Errorf("Queue.Current mismatch: got %v, exp %v", string(cur), exp)
+	}
+}
+
+// Ensure the oldest entry time comes from the entries and not from the
+// segment's modification time.
+func TestQueueOldest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hh_queue")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	q, err := newQueue(dir, 1024)
+	if err != nil {
+		t.Fatalf("failed to create queue: %v", err)
+	}
+
+	if err := q.Open(); err != nil {
+		t.Fatalf("failed to open queue: %v", err)
+	}
+
+	var times []time.Time
+	for _, s := range []string{"one", "two", "three"} {
+		before := time.Now()
+		if err := q.Append([]byte(s)); err != nil {
+			t.Fatalf("Queue.Append failed: %v", err)
+		}
+		times = append(times, before)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Advancing rewrites the segment but the oldest time is the second entry's.
+	if err := q.Advance(); err != nil {
+		t.Fatalf("Queue.Advance failed: %v", err)
+	}
+	oldest, err := q.Oldest()
+	if err != nil {
+		t.Fatalf("Queue.Oldest failed: %v", err)
+	} else if oldest.Before(times[1]) || !oldest.Before(times[2]) {
+		t.Fatalf("Queue.Oldest mismatch: got %v, exp between %v and %v", oldest, times[1], times[2])
+	}
+
+	// The time survives reopening the queue.
+	if err := q.Close(); err != nil {
+		t.Fatalf("Queue.Close failed: %v", err)
+	} else if err := q.Open(); err != nil {
+		t.Fatalf("Queue.Open failed: %v", err)
+	}
+	if other, err := q.Oldest(); err != nil {
+		t.Fatalf("Queue.Oldest failed: %v", err)
+	} else if !other.Equal(oldest) {
+		t.Fatalf("Queue.Oldest mismatch after reopen: got %v, exp %v", other, oldest)
+	} else if cur, err := q.Current(); err != nil {
+		t.Fatalf("Queue.Current failed: %v", err)
+	} else if exp := "two"; string(cur) != exp {
+		t.Errorf("Queue.Current mismatch: got %v, exp %v", string(cur), exp)
+	}
+}
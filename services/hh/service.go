@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/influxdb/influxdb"
+	"github.com/influxdb/influxdb/meta"
 	"github.com/influxdb/influxdb/models"
 )
 
@@ -34,15 +35,28 @@ type Service struct {
 
 	ShardWriter shardWriter
 
+	// Used to re-route the writes queued for nodes removed from the cluster.
+	MetaStore interface {
+		Node(id uint64) (*meta.NodeInfo, error)
+		ShardOwner(shardID uint64) (database, policy string, sgi *meta.ShardGroupInfo)
+	}
+	processor *Processor
+
 	HintedHandoff interface {
 		WriteShard(shardID, ownerID uint64, points []models.Point) error
 		Process() error
 		PurgeOlderThan(when time.Duration) error
+		Pending() bool
+		Status() ([]QueueStatus, error)
+		Purge(nodeID uint64) error
+		Pause(nodeID uint64) error
+		Resume(nodeID uint64) error
 	}
 }
 
 type shardWriter interface {
 	WriteShard(shardID, ownerID uint64, points []models.Point) error
+	Ping(nodeID uint64) error
 }
 
 // NewService returns a new instance of Service.
@@ -56,14 +70,19 @@ func NewService(c Config, w shardWriter) *Service {
 		Logger:  log.New(os.Stderr, "[handoff] ", log.LstdFlags),
 	}
 	processor, err := NewProcessor(c.Dir, w, ProcessorOptions{
-		MaxSize:        c.MaxSize,
-		RetryRateLimit: c.RetryRateLimit,
+		MaxSize:          c.MaxSize,
+		RetryRateLimit:   c.RetryRateLimit,
+		RetryRateBurst:   c.RetryRateBurst,
+		RetryInterval:    time.Duration(c.RetryInterval),
+		RetryMaxInterval: time.Duration(c.RetryMaxInterval),
+		LivenessCheck:    c.LivenessCheck,
 	})
 	if err != nil {
 		s.Logger.Fatalf("Failed to start hinted handoff processor: %v", err)
 	}
 
 	processor.Logger = s.Logger
+	s.processor = processor
 	s.HintedHandoff = processor
 	return s
 }
@@ -80,6 +99,9 @@ func (s *Service) Open() error {
 	defer s.mu.Unlock()
 
 	s.closing = make(chan struct{})
+	if s.processor != nil && s.MetaStore != nil {
+		s.processor.MetaStore = s.MetaStore
+	}
 
 	s.Logger.Printf("Using data dir: %v", s.cfg.Dir)
 
@@ -117,6 +139,40 @@ func (s *Service) WriteShard(shardID, ownerID uint64, points []models.Point) err
 	return s.HintedHandoff.WriteShard(shardID, ownerID, points)
 }
 
+// Pending returns true if writes are queued for other nodes.
+func (s *Service) Pending() bool {
+	return s.HintedHandoff.Pending()
+}
+
+// Status returns the state of the queue of each node.
+func (s *Service) Status() ([]QueueStatus, error) {
+	return s.HintedHandoff.Status()
+}
+
+// Purge drops the writes queued for a node.
+func (s *Service) Purge(nodeID uint64) error {
+	if !s.cfg.Enabled {
+		return ErrHintedHandoffDisabled
+	}
+	return s.HintedHandoff.Purge(nodeID)
+}
+
+// Pause stops replaying the writes queued for a node.
+func (s *Service) Pause(nodeID uint64) error {
+	if !s.cfg.Enabled {
+		return ErrHintedHandoffDisabled
+	}
+	return s.HintedHandoff.Pause(nodeID)
+}
+
+// Resume restarts replaying the writes queued for a node.
+func (s *Service) Resume(nodeID uint64) error {
+	if !s.cfg.Enabled {
+		return ErrHintedHandoffDisabled
+	}
+	return s.HintedHandoff.Resume(nodeID)
+}
+
 func (s *Service) retryWrites() {
 	defer s.wg.Done()
 	ticker := time.NewTicker(time.Duration(s.cfg.RetryInterval))
@@ -25,6 +25,7 @@ import (
 	"github.com/influxdb/influxdb/meta"
 	"github.com/influxdb/influxdb/models"
 	"github.com/influxdb/influxdb/services/continuous_querier"
+	"github.com/influxdb/influxdb/tsdb"
 	"github.com/influxdb/influxdb/uuid"
 )
 
@@ -62,7 +63,7 @@ type Handler struct {
 
 	QueryExecutor interface {
 		Authorize(u *meta.UserInfo, q *influxql.Query, db string) error
-		ExecuteQuery(q *influxql.Query, db string, chunkSize int) (<-chan *influxql.Result, error)
+		ExecuteQuery(q *influxql.Query, db string, chunkSize int, level tsdb.ReadConsistency) (<-chan *influxql.Result, error)
 	}
 
 	PointsWriter interface {
@@ -229,6 +230,14 @@ func (h *Handler) serveQuery(w http.ResponseWriter, r *http.Request, user *meta.
 
 	epoch := strings.TrimSpace(q.Get("epoch"))
 
+	// Parse the read consistency level. Defaults to reading each shard from
+	// one owner.
+	level, err := tsdb.ParseReadConsistency(q.Get("consistency"))
+	if err != nil {
+		httpError(w, err.Error(), pretty, http.StatusBadRequest)
+		return
+	}
+
 	p := influxql.NewParser(strings.NewReader(qp))
 	db := q.Get("db")
 
@@ -269,7 +278,7 @@ func (h *Handler) serveQuery(w http.ResponseWriter, r *http.Request, user *meta.
 
 	// Execute query.
 	w.Header().Add("content-type", "application/json")
-	results, err := h.QueryExecutor.ExecuteQuery(query, db, chunkSize)
+	results, err := h.QueryExecutor.ExecuteQuery(query, db, chunkSize, level)
 
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -429,7 +438,9 @@ func (h *Handler) serveWriteJSON(w http.ResponseWriter, r *http.Request, body []
 		Points:           points,
 	}); err != nil {
 		h.statMap.Add(statPointsWrittenFail, int64(len(points)))
-		if influxdb.IsClientError(err) {
+		if influxdb.IsReadOnlyError(err) {
+			h.writeError(w, influxql.Result{Err: fmt.Errorf("database %q is read-only", bp.Database)}, http.StatusForbidden)
+		} else if influxdb.IsClientError(err) {
 			h.writeError(w, influxql.Result{Err: err}, http.StatusBadRequest)
 		} else {
 			h.writeError(w, influxql.Result{Err: err}, http.StatusInternalServerError)
@@ -526,7 +537,11 @@ func (h *Handler) serveWriteLine(w http.ResponseWriter, r *http.Request, body []
 		RetentionPolicy:  r.FormValue("rp"),
 		ConsistencyLevel: consistency,
 		Points:           points,
-	}); influxdb.IsClientError(err) {
+	}); influxdb.IsReadOnlyError(err) {
+		h.statMap.Add(statPointsWrittenFail, int64(len(points)))
+		h.writeError(w, influxql.Result{Err: fmt.Errorf("database %q is read-only", database)}, http.StatusForbidden)
+		return
+	} else if influxdb.IsClientError(err) {
 		h.statMap.Add(statPointsWrittenFail, int64(len(points)))
 		h.writeError(w, influxql.Result{Err: err}, http.StatusBadRequest)
 		return
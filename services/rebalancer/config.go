@@ -0,0 +1,50 @@
+package rebalancer
+
+import (
+	"errors"
+	"time"
+
+	"github.com/influxdb/influxdb/toml"
+)
+
+const (
+	// DefaultCheckInterval is how often the leader plans and starts moves.
+	DefaultCheckInterval = 10 * time.Minute
+
+	// DefaultMaxConcurrentMoves is the number of shard copies and moves that
+	// can run at the same time.
+	DefaultMaxConcurrentMoves = 1
+)
+
+// Config represents the configuration for the rebalancer service.
+type Config struct {
+	Enabled            bool          `toml:"enabled"`
+	CheckInterval      toml.Duration `toml:"check-interval"`
+	MaxConcurrentMoves int           `toml:"max-concurrent-moves"`
+
+	// Logs the planned moves without starting them.
+	DryRun bool `toml:"dry-run"`
+}
+
+// NewConfig returns a new Config with defaults.
+func NewConfig() Config {
+	return Config{
+		Enabled:            false,
+		CheckInterval:      toml.Duration(DefaultCheckInterval),
+		MaxConcurrentMoves: DefaultMaxConcurrentMoves,
+	}
+}
+
+// Validate returns an error if the config is invalid.
+func (c Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.CheckInterval <= 0 {
+		return errors.New("check-interval must be positive")
+	}
+	if c.MaxConcurrentMoves <= 0 {
+		return errors.New("max-concurrent-moves must be positive")
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package rebalancer_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/influxdb/influxdb/services/rebalancer"
+)
+
+func TestConfig_Parse(t *testing.T) {
+	// Parse configuration.
+	var c rebalancer.Config
+	if _, err := toml.Decode(`
+enabled = true
+check-interval = "2m"
+max-concurrent-moves = 3
+dry-run = true
+`, &c); err != nil {
+		t.Fatal(err)
+	}
+
+	// Validate configuration.
+	if !c.Enabled {
+		t.Fatalf("unexpected enabled state: %v", c.Enabled)
+	} else if time.Duration(c.CheckInterval) != 2*time.Minute {
+		t.Fatalf("unexpected check interval: %s", c.CheckInterval)
+	} else if c.MaxConcurrentMoves != 3 {
+		t.Fatalf("unexpected max concurrent moves: %d", c.MaxConcurrentMoves)
+	} else if !c.DryRun {
+		t.Fatalf("unexpected dry run: %v", c.DryRun)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	c := rebalancer.NewConfig()
+	if err := c.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	c.Enabled = true
+	c.MaxConcurrentMoves = 0
+	if err := c.Validate(); err == nil {
+		t.Fatal("expected error for zero max concurrent moves")
+	}
+}
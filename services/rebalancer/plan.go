@@ -0,0 +1,229 @@
+package rebalancer
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/influxdb/influxdb/meta"
+)
+
+// Move represents a planned change to the owners of a shard.
+type Move struct {
+	Database        string
+	RetentionPolicy string
+	ShardID         uint64
+	From            uint64 // source node
+	To              uint64 // destination node
+
+	// Copy is set if the source keeps the shard.
+	Copy bool
+}
+
+// String returns a string representation of the move.
+func (m Move) String() string {
+	op := "move"
+	if m.Copy {
+		op = "copy"
+	}
+	return fmt.Sprintf("%s shard %d (%s.%s) from node %d to node %d",
+		op, m.ShardID, m.Database, m.RetentionPolicy, m.From, m.To)
+}
+
+// Plan returns the moves that place the shards of dbs across nodes.
+//
+// Shards that lost owners, e.g. because a node left, are first copied to
+// other nodes until they have the replication factor of their retention
+// policy again. Shards are then moved from the nodes owning the most shards to
+// the nodes owning the least until the counts differ by at most one. A node
+// never owns more than one replica of a shard. Shard groups that are deleted
+// or expired at now are ignored, as are groups that haven't ended since
+// they're still being written to.
+//
+// Decommissioning nodes are never the source or destination of a move as they
+// move their shards off themselves.
+func Plan(nodes []meta.NodeInfo, dbs []meta.DatabaseInfo, now time.Time) []Move {
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	// Count the shards owned by each node. Decommissioning nodes move their
+	// own shards off so they are only counted as owners.
+	ids := make([]uint64, 0, len(nodes))
+	load := make(map[uint64]int)
+	draining := make(map[uint64]bool)
+	for _, ni := range nodes {
+		if ni.Decommissioning {
+			draining[ni.ID] = true
+			continue
+		}
+		ids = append(ids, ni.ID)
+		load[ni.ID] = 0
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	sort.Sort(uint64Slice(ids))
+
+	var shards []*shard
+	for _, di := range dbs {
+		for _, rpi := range di.RetentionPolicies {
+			expired := make(map[uint64]bool)
+			for _, sgi := range rpi.ExpiredShardGroups(now) {
+				expired[sgi.ID] = true
+			}
+
+			replicaN := rpi.ReplicaN
+			if replicaN < 1 {
+				replicaN = 1
+			} else if replicaN > len(ids) {
+				replicaN = len(ids)
+			}
+
+			for _, sgi := range rpi.ShardGroups {
+				if sgi.Deleted() || expired[sgi.ID] || sgi.EndTime.After(now) {
+					continue
+				}
+				for _, si := range sgi.Shards {
+					sh := &shard{database: di.Name, policy: rpi.Name, id: si.ID, replicaN: replicaN}
+					for _, so := range si.Owners {
+						if sh.ownedBy(so.NodeID) {
+							continue
+						} else if _, ok := load[so.NodeID]; ok {
+							sh.owners = append(sh.owners, so.NodeID)
+							load[so.NodeID]++
+						} else if draining[so.NodeID] {
+							sh.owners = append(sh.owners, so.NodeID)
+						}
+					}
+					shards = append(shards, sh)
+				}
+			}
+		}
+	}
+	sort.Sort(shardsByID(shards))
+
+	var moves []Move
+
+	// Restore the replication factor of shards with a live owner to copy from.
+	for _, sh := range shards {
+		from := sh.source(draining)
+		if from == 0 {
+			continue
+		}
+		for len(sh.owners) < sh.replicaN {
+			to := leastLoaded(ids, load, sh)
+			moves = append(moves, sh.move(from, to, true))
+			sh.owners = append(sh.owners, to)
+			load[to]++
+		}
+	}
+
+	// Balance the number of shards owned by each node.
+	for {
+		max, min := ids[0], ids[0]
+		for _, id := range ids {
+			if load[id] > load[max] {
+				max = id
+			}
+			if load[id] < load[min] {
+				min = id
+			}
+		}
+		if load[max]-load[min] <= 1 {
+			break
+		}
+
+		// Find a shard that can be moved between the nodes. Shards are only
+		// changed once per plan so moves don't depend on each other.
+		var sh *shard
+		for _, s := range shards {
+			if !s.planned && s.ownedBy(max) && !s.ownedBy(min) {
+				sh = s
+				break
+			}
+		}
+		if sh == nil {
+			break
+		}
+
+		moves = append(moves, sh.move(max, min, false))
+		for i := range sh.owners {
+			if sh.owners[i] == max {
+				sh.owners[i] = min
+			}
+		}
+		load[max]--
+		load[min]++
+	}
+
+	return moves
+}
+
+// leastLoaded returns the node owning the fewest shards that doesn't own sh.
+func leastLoaded(ids []uint64, load map[uint64]int, sh *shard) uint64 {
+	var min uint64
+	for _, id := range ids {
+		if sh.ownedBy(id) {
+			continue
+		} else if min == 0 || load[id] < load[min] {
+			min = id
+		}
+	}
+	return min
+}
+
+// shard represents the live owners of a shard during planning.
+type shard struct {
+	database string
+	policy   string
+	id       uint64
+	replicaN int
+	owners   []uint64
+	planned  bool
+}
+
+func (sh *shard) ownedBy(nodeID uint64) bool {
+	for _, id := range sh.owners {
+		if id == nodeID {
+			return true
+		}
+	}
+	return false
+}
+
+// source returns the first owner of sh that isn't draining or zero if there
+// is none.
+func (sh *shard) source(draining map[uint64]bool) uint64 {
+	for _, id := range sh.owners {
+		if !draining[id] {
+			return id
+		}
+	}
+	return 0
+}
+
+// move returns a move of sh and marks it as planned.
+func (sh *shard) move(from, to uint64, copy bool) Move {
+	sh.planned = true
+	return Move{
+		Database:        sh.database,
+		RetentionPolicy: sh.policy,
+		ShardID:         sh.id,
+		From:            from,
+		To:              to,
+		Copy:            copy,
+	}
+}
+
+type shardsByID []*shard
+
+func (a shardsByID) Len() int           { return len(a) }
+func (a shardsByID) Less(i, j int) bool { return a[i].id < a[j].id }
+func (a shardsByID) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+
+type uint64Slice []uint64
+
+func (a uint64Slice) Len() int           { return len(a) }
+func (a uint64Slice) Less(i, j int) bool { return a[i] < a[j] }
+func (a uint64Slice) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
@@ -0,0 +1,165 @@
+package rebalancer
+
+import (
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/influxdb/influxdb/meta"
+	"github.com/influxdb/influxdb/services/copier"
+)
+
+// Service periodically plans a placement of shards across the nodes in the
+// cluster and moves shards through the copier service to reach it. Only the
+// meta store leader starts moves and only a limited number run at a time.
+type Service struct {
+	MetaStore interface {
+		IsLeader() bool
+		Nodes() ([]meta.NodeInfo, error)
+		Databases() ([]meta.DatabaseInfo, error)
+	}
+	Copier interface {
+		CopyShard(shardID, to uint64) error
+		MoveShard(shardID, from, to uint64) error
+		ClusterMoves() ([]copier.Move, error)
+	}
+
+	checkInterval      time.Duration
+	maxConcurrentMoves int
+	dryRun             bool
+	wg                 sync.WaitGroup
+	done               chan struct{}
+
+	logger *log.Logger
+}
+
+// NewService returns a configured rebalancer service.
+func NewService(c Config) *Service {
+	return &Service{
+		checkInterval:      time.Duration(c.CheckInterval),
+		maxConcurrentMoves: c.MaxConcurrentMoves,
+		dryRun:             c.DryRun,
+		logger:             log.New(os.Stderr, "[rebalancer] ", log.LstdFlags),
+	}
+}
+
+// Open starts rebalancing shards.
+func (s *Service) Open() error {
+	if s.done != nil {
+		return nil
+	}
+
+	s.logger.Printf("Starting rebalancer service with check interval of %s, max concurrent moves of %d, dry run %v",
+		s.checkInterval, s.maxConcurrentMoves, s.dryRun)
+
+	s.done = make(chan struct{})
+
+	s.wg.Add(1)
+	go s.run()
+	return nil
+}
+
+// Close stops the service. Running moves are stopped by the copier service.
+func (s *Service) Close() error {
+	if s.done == nil {
+		return nil
+	}
+
+	close(s.done)
+	s.wg.Wait()
+	s.done = nil
+	return nil
+}
+
+// SetLogger sets the internal logger to the logger passed in.
+func (s *Service) SetLogger(l *log.Logger) {
+	s.logger = l
+}
+
+// Plan returns the moves that would be started to balance the cluster now,
+// ignoring the limit on concurrent moves.
+func (s *Service) Plan() ([]Move, error) {
+	nodes, err := s.MetaStore.Nodes()
+	if err != nil {
+		return nil, err
+	}
+	dbs, err := s.MetaStore.Databases()
+	if err != nil {
+		return nil, err
+	}
+	return Plan(nodes, dbs, time.Now().UTC()), nil
+}
+
+func (s *Service) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+
+		case <-ticker.C:
+			// Only run this on the leader, but always allow the loop to check
+			// as the leader can change.
+			if !s.MetaStore.IsLeader() {
+				continue
+			}
+			s.rebalance()
+		}
+	}
+}
+
+// rebalance starts planned moves until the limit on concurrent moves is
+// reached. Shards that are already being moved by any node are skipped.
+func (s *Service) rebalance() {
+	moves, err := s.Plan()
+	if err != nil {
+		s.logger.Printf("failed to plan moves: %s", err)
+		return
+	}
+
+	if s.dryRun {
+		for _, m := range moves {
+			s.logger.Printf("planned: %s", m)
+		}
+		return
+	}
+
+	cm, err := s.Copier.ClusterMoves()
+	if err != nil {
+		s.logger.Printf("failed to read running moves: %s", err)
+		return
+	}
+
+	running := make(map[uint64]bool)
+	for _, m := range cm {
+		if m.State == copier.MoveRunning {
+			running[m.ShardID] = true
+		}
+	}
+
+	n := len(running)
+	for _, m := range moves {
+		if n >= s.maxConcurrentMoves {
+			return
+		} else if running[m.ShardID] {
+			continue
+		}
+
+		var err error
+		if m.Copy {
+			err = s.Copier.CopyShard(m.ShardID, m.To)
+		} else {
+			err = s.Copier.MoveShard(m.ShardID, m.From, m.To)
+		}
+		if err != nil {
+			s.logger.Printf("failed to start %s: %s", m, err)
+			continue
+		}
+		s.logger.Printf("started %s", m)
+		n++
+	}
+}
@@ -0,0 +1,223 @@
+package rebalancer
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/influxdb/influxdb/influxql"
+	"github.com/influxdb/influxdb/meta"
+	"github.com/influxdb/influxdb/services/copier"
+	"github.com/influxdb/influxdb/toml"
+)
+
+// Ensure shards are moved to a node that joined the cluster.
+func TestPlan_Balance(t *testing.T) {
+	now := time.Date(2015, 10, 1, 0, 0, 0, 0, time.UTC)
+	nodes := []meta.NodeInfo{{ID: 1}, {ID: 2}, {ID: 3}}
+	dbs := databases(now, 2, [][]uint64{
+		{1, 2}, {1, 2}, {1, 2}, {1, 2},
+	})
+
+	exp := []Move{
+		{Database: "db0", RetentionPolicy: "default", ShardID: 1, From: 1, To: 3},
+		{Database: "db0", RetentionPolicy: "default", ShardID: 2, From: 2, To: 3},
+	}
+	if moves := Plan(nodes, dbs, now); !reflect.DeepEqual(moves, exp) {
+		t.Fatalf("unexpected moves:\n\ngot=%#v\n\nexp=%#v", moves, exp)
+	}
+}
+
+// Ensure shards that lost an owner are copied until they have ReplicaN owners.
+func TestPlan_Replicate(t *testing.T) {
+	now := time.Date(2015, 10, 1, 0, 0, 0, 0, time.UTC)
+	nodes := []meta.NodeInfo{{ID: 1}, {ID: 2}, {ID: 3}}
+
+	// Node 4 left the cluster and shard 3 has no owners left to copy from.
+	dbs := databases(now, 2, [][]uint64{
+		{1, 2}, {3, 4}, {4},
+	})
+
+	exp := []Move{
+		{Database: "db0", RetentionPolicy: "default", ShardID: 2, From: 3, To: 1, Copy: true},
+	}
+	if moves := Plan(nodes, dbs, now); !reflect.DeepEqual(moves, exp) {
+		t.Fatalf("unexpected moves:\n\ngot=%#v\n\nexp=%#v", moves, exp)
+	}
+}
+
+// Ensure a node never owns more than one replica of a shard.
+func TestPlan_ReplicaN(t *testing.T) {
+	now := time.Date(2015, 10, 1, 0, 0, 0, 0, time.UTC)
+	nodes := []meta.NodeInfo{{ID: 1}, {ID: 2}}
+
+	// The replication factor is clamped to the number of nodes.
+	dbs := databases(now, 3, [][]uint64{
+		{1, 2}, {1, 2},
+	})
+
+	if moves := Plan(nodes, dbs, now); len(moves) != 0 {
+		t.Fatalf("unexpected moves: %#v", moves)
+	}
+}
+
+// Ensure shards aren't moved to or from decommissioning nodes.
+func TestPlan_Decommissioning(t *testing.T) {
+	now := time.Date(2015, 10, 1, 0, 0, 0, 0, time.UTC)
+	nodes := []meta.NodeInfo{{ID: 1}, {ID: 2}, {ID: 3, Decommissioning: true}}
+	dbs := databases(now, 1, [][]uint64{
+		{1}, {1}, {1}, {3}, {3},
+	})
+
+	exp := []Move{
+		{Database: "db0", RetentionPolicy: "default", ShardID: 1, From: 1, To: 2},
+	}
+	if moves := Plan(nodes, dbs, now); !reflect.DeepEqual(moves, exp) {
+		t.Fatalf("unexpected moves:\n\ngot=%#v\n\nexp=%#v", moves, exp)
+	}
+}
+
+// Ensure deleted, expired and current shard groups are ignored.
+func TestPlan_IgnoreDeletedExpired(t *testing.T) {
+	now := time.Date(2015, 10, 1, 0, 0, 0, 0, time.UTC)
+	nodes := []meta.NodeInfo{{ID: 1}, {ID: 2}}
+	dbs := []meta.DatabaseInfo{{
+		Name: "db0",
+		RetentionPolicies: []meta.RetentionPolicyInfo{{
+			Name:     "default",
+			ReplicaN: 1,
+			Duration: 24 * time.Hour,
+			ShardGroups: []meta.ShardGroupInfo{
+				{ID: 1, EndTime: now.Add(-48 * time.Hour), Shards: []meta.ShardInfo{shardInfo(1, 1)}},
+				{ID: 2, EndTime: now, DeletedAt: now, Shards: []meta.ShardInfo{shardInfo(2, 1)}},
+				{ID: 3, EndTime: now, Shards: []meta.ShardInfo{shardInfo(3, 1)}},
+				{ID: 4, EndTime: now.Add(time.Hour), Shards: []meta.ShardInfo{shardInfo(4, 1)}},
+			},
+		}},
+	}}
+
+	if moves := Plan(nodes, dbs, now); len(moves) != 0 {
+		t.Fatalf("unexpected moves: %#v", moves)
+	}
+}
+
+// Ensure moves are limited by the number of concurrent moves.
+func TestService_rebalance(t *testing.T) {
+	now := time.Now().UTC()
+
+	s := NewService(Config{
+		CheckInterval:      toml.Duration(time.Minute),
+		MaxConcurrentMoves: 2,
+	})
+	s.MetaStore = &metaStore{
+		nodes: []meta.NodeInfo{{ID: 1}, {ID: 2}, {ID: 3}},
+		dbs: databases(now, 1, [][]uint64{
+			{1}, {1}, {1}, {1}, {1}, {1},
+		}),
+	}
+
+	// Shard 1 is already being moved by another node.
+	c := &copierService{moves: []copier.Move{{Node: 2, ShardID: 1, State: copier.MoveRunning}}}
+	s.Copier = c
+
+	s.rebalance()
+
+	if exp := []string{"move 2 1 3"}; !reflect.DeepEqual(c.started, exp) {
+		t.Fatalf("unexpected started moves: got %v, exp %v", c.started, exp)
+	}
+}
+
+// Ensure the plan is shown by SHOW SHARD PLAN.
+func TestStatementExecutor_ShowShardPlan(t *testing.T) {
+	now := time.Now().UTC()
+
+	s := NewService(Config{})
+	s.MetaStore = &metaStore{
+		nodes: []meta.NodeInfo{{ID: 1}, {ID: 2}},
+		dbs:   databases(now, 1, [][]uint64{{1}, {1}, {1}}),
+	}
+
+	e := &StatementExecutor{Service: s}
+	res := e.ExecuteStatement(&influxql.ShowShardPlanStatement{})
+	if res.Err != nil {
+		t.Fatal(res.Err)
+	} else if len(res.Series) != 1 {
+		t.Fatalf("unexpected series: %#v", res.Series)
+	} else if exp := [][]interface{}{{uint64(1), "db0", "default", "move", uint64(1), uint64(2)}}; !reflect.DeepEqual(res.Series[0].Values, exp) {
+		t.Fatalf("unexpected values: %#v", res.Series[0].Values)
+	}
+}
+
+// Ensure moves are only logged in dry-run mode.
+func TestService_rebalance_DryRun(t *testing.T) {
+	now := time.Now().UTC()
+
+	s := NewService(Config{
+		CheckInterval:      toml.Duration(time.Minute),
+		MaxConcurrentMoves: 2,
+		DryRun:             true,
+	})
+	s.MetaStore = &metaStore{
+		nodes: []meta.NodeInfo{{ID: 1}, {ID: 2}},
+		dbs:   databases(now, 1, [][]uint64{{1}, {1}, {1}}),
+	}
+	c := &copierService{}
+	s.Copier = c
+
+	s.rebalance()
+
+	if len(c.started) != 0 {
+		t.Fatalf("unexpected started moves: %v", c.started)
+	}
+}
+
+// databases returns a database with a shard group per entry in owners
+// that ended before now. Each shard group has a single shard owned by the nodes in
+// the entry and the shard has the same ID as its group.
+func databases(now time.Time, replicaN int, owners [][]uint64) []meta.DatabaseInfo {
+	rpi := meta.RetentionPolicyInfo{Name: "default", ReplicaN: replicaN}
+	for i, a := range owners {
+		id := uint64(i + 1)
+		rpi.ShardGroups = append(rpi.ShardGroups, meta.ShardGroupInfo{
+			ID:      id,
+			EndTime: now.Add(-time.Hour),
+			Shards:  []meta.ShardInfo{shardInfo(id, a...)},
+		})
+	}
+	return []meta.DatabaseInfo{{Name: "db0", RetentionPolicies: []meta.RetentionPolicyInfo{rpi}}}
+}
+
+func shardInfo(id uint64, owners ...uint64) meta.ShardInfo {
+	si := meta.ShardInfo{ID: id}
+	for _, nodeID := range owners {
+		si.Owners = append(si.Owners, meta.ShardOwner{NodeID: nodeID})
+	}
+	return si
+}
+
+type metaStore struct {
+	nodes []meta.NodeInfo
+	dbs   []meta.DatabaseInfo
+}
+
+func (m *metaStore) IsLeader() bool                          { return true }
+func (m *metaStore) Nodes() ([]meta.NodeInfo, error)         { return m.nodes, nil }
+func (m *metaStore) Databases() ([]meta.DatabaseInfo, error) { return m.dbs, nil }
+
+type copierService struct {
+	moves   []copier.Move
+	started []string
+}
+
+func (c *copierService) CopyShard(shardID, to uint64) error {
+	c.started = append(c.started, fmt.Sprintf("copy %d %d", shardID, to))
+	return nil
+}
+
+func (c *copierService) MoveShard(shardID, from, to uint64) error {
+	c.started = append(c.started, fmt.Sprintf("move %d %d %d", shardID, from, to))
+	return nil
+}
+
+func (c *copierService) ClusterMoves() ([]copier.Move, error) { return c.moves, nil }
@@ -0,0 +1,42 @@
+package rebalancer
+
+import (
+	"fmt"
+
+	"github.com/influxdb/influxdb/influxql"
+	"github.com/influxdb/influxdb/models"
+)
+
+// StatementExecutor translates InfluxQL queries to rebalancer plans.
+type StatementExecutor struct {
+	Service interface {
+		Plan() ([]Move, error)
+	}
+}
+
+// ExecuteStatement executes shard plan statements.
+func (e *StatementExecutor) ExecuteStatement(stmt influxql.Statement) *influxql.Result {
+	switch stmt := stmt.(type) {
+	case *influxql.ShowShardPlanStatement:
+		return e.executeShowShardPlanStatement(stmt)
+	default:
+		panic(fmt.Sprintf("unsupported statement type: %T", stmt))
+	}
+}
+
+func (e *StatementExecutor) executeShowShardPlanStatement(stmt *influxql.ShowShardPlanStatement) *influxql.Result {
+	moves, err := e.Service.Plan()
+	if err != nil {
+		return &influxql.Result{Err: err}
+	}
+
+	row := &models.Row{Columns: []string{"shard_id", "database", "retention_policy", "type", "source", "destination"}}
+	for _, m := range moves {
+		typ := "move"
+		if m.Copy {
+			typ = "copy"
+		}
+		row.Values = append(row.Values, []interface{}{m.ShardID, m.Database, m.RetentionPolicy, typ, m.From, m.To})
+	}
+	return &influxql.Result{Series: []*models.Row{row}}
+}
@@ -0,0 +1,445 @@
+package restorer
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdb/influxdb/meta"
+	"github.com/influxdb/influxdb/snapshot"
+	"github.com/influxdb/influxdb/tcp"
+)
+
+// MuxHeader is the header byte used for the TCP muxer.
+const MuxHeader = 7
+
+// Request represents a request to restore a snapshot into a running server.
+type Request struct {
+	// Only the given database and retention policy are restored, if set.
+	Database        string `json:"database,omitempty"`
+	RetentionPolicy string `json:"retentionPolicy,omitempty"`
+
+	// The database is restored under this name, if set. Requires Database.
+	NewDatabase string `json:"newDatabase,omitempty"`
+}
+
+// Response represents the result of a restore.
+type Response struct {
+	// Shards that were restored by ID in the snapshot.
+	Shards map[uint64]uint64 `json:"shards,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// Service manages the listener for the restore endpoint.
+type Service struct {
+	wg  sync.WaitGroup
+	err chan error
+
+	MetaStore interface {
+		NodeID() uint64
+		CreateDatabaseIfNotExists(name string) (*meta.DatabaseInfo, error)
+		CreateRetentionPolicyIfNotExists(database string, rpi *meta.RetentionPolicyInfo) (*meta.RetentionPolicyInfo, error)
+		SetDefaultRetentionPolicy(database, name string) error
+		ShardGroupByTimestamp(database, policy string, timestamp time.Time) (*meta.ShardGroupInfo, error)
+		CreateShardGroupIfNotExists(database, policy string, timestamp time.Time) (*meta.ShardGroupInfo, error)
+		DeleteShardGroup(database, policy string, id uint64) error
+	}
+
+	TSDBStore interface {
+		RestoreShard(database, retentionPolicy string, shardID uint64, path string) error
+		DeleteShard(shardID uint64) error
+	}
+
+	// Copier restores shards onto the other nodes that own them.
+	Copier interface {
+		RestoreShardTo(nodeID uint64, database, retentionPolicy string, shardID uint64, path string) error
+		DeleteShardFrom(nodeID, shardID uint64) error
+	}
+
+	Listener net.Listener
+	Logger   *log.Logger
+}
+
+// NewService returns a new instance of Service.
+func NewService() *Service {
+	return &Service{
+		err:    make(chan error),
+		Logger: log.New(os.Stderr, "[restorer] ", log.LstdFlags),
+	}
+}
+
+// Open starts the service.
+func (s *Service) Open() error {
+	s.Logger.Println("Starting restorer service")
+
+	s.wg.Add(1)
+	go s.serve()
+	return nil
+}
+
+// Close implements the Service interface.
+func (s *Service) Close() error {
+	if s.Listener != nil {
+		s.Listener.Close()
+	}
+	s.wg.Wait()
+	return nil
+}
+
+// SetLogger sets the internal logger to the logger passed in.
+func (s *Service) SetLogger(l *log.Logger) {
+	s.Logger = l
+}
+
+// Err returns a channel for fatal out-of-band errors.
+func (s *Service) Err() <-chan error { return s.err }
+
+// serve serves restore requests from the listener.
+func (s *Service) serve() {
+	defer s.wg.Done()
+
+	for {
+		// Wait for next connection.
+		conn, err := s.Listener.Accept()
+		if err != nil && strings.Contains(err.Error(), "connection closed") {
+			s.Logger.Println("restorer listener closed")
+			return
+		} else if err != nil {
+			s.Logger.Println("error accepting restore request: ", err.Error())
+			continue
+		}
+
+		// Handle connection in separate goroutine.
+		s.wg.Add(1)
+		go func(conn net.Conn) {
+			defer s.wg.Done()
+			defer conn.Close()
+			if err := s.handleConn(conn); err != nil {
+				s.Logger.Println(err)
+			}
+		}(conn)
+	}
+}
+
+// handleConn processes conn. This is run in a separate goroutine.
+func (s *Service) handleConn(conn net.Conn) error {
+	var req Request
+	if err := readMessage(conn, &req); err != nil {
+		return fmt.Errorf("read request: %s", err)
+	}
+
+	// Restore the snapshot and report the result to the client.
+	var resp Response
+	shards, err := s.restore(conn, &req)
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	resp.Shards = shards
+
+	if err := writeMessage(conn, &resp); err != nil {
+		return fmt.Errorf("write response: %s", err)
+	}
+	return err
+}
+
+// restore reads a snapshot from r and loads its shards into the store.
+// Returns a mapping of the snapshot's shard IDs to the restored shard IDs.
+func (s *Service) restore(r io.Reader, req *Request) (map[uint64]uint64, error) {
+	if req.NewDatabase != "" && req.Database == "" {
+		return nil, errors.New("database required with new database name")
+	} else if req.RetentionPolicy != "" && req.Database == "" {
+		return nil, errors.New("database required with retention policy")
+	}
+
+	// Spool shard files to a temporary directory until the metadata is read.
+	dir, err := ioutil.TempDir("", "influxdb-restore")
+	if err != nil {
+		return nil, fmt.Errorf("temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var data meta.Data
+	var metaFound bool
+	files := make(map[string]string)
+	sr := snapshot.NewReader(r)
+	for {
+		sf, err := sr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("next: %s", err)
+		}
+
+		// Unpack the metadata.
+		if sf.Name == "meta" {
+			var buf bytes.Buffer
+			if _, err := io.CopyN(&buf, sr, sf.Size); err != nil {
+				return nil, fmt.Errorf("copy meta: %s", err)
+			} else if err := data.UnmarshalBinary(buf.Bytes()); err != nil {
+				return nil, fmt.Errorf("unmarshal meta: %s", err)
+			}
+			metaFound = true
+			continue
+		}
+
+		path := filepath.Join(dir, fmt.Sprintf("%d", len(files)))
+		if err := spoolFile(path, sr, sf.Size); err != nil {
+			return nil, fmt.Errorf("spool: entry=%s, err=%s", sf.Name, err)
+		}
+		files[filepath.ToSlash(sf.Name)] = path
+	}
+	if !metaFound {
+		return nil, errors.New("snapshot meta not found")
+	} else if req.Database != "" && data.Database(req.Database) == nil {
+		return nil, fmt.Errorf("database not found in snapshot: %s", req.Database)
+	}
+
+	shards := make(map[uint64]uint64)
+	for _, di := range data.Databases {
+		if req.Database != "" && di.Name != req.Database {
+			continue
+		}
+
+		name := di.Name
+		if req.NewDatabase != "" {
+			name = req.NewDatabase
+		}
+
+		if err := s.restoreDatabase(name, &di, req.RetentionPolicy, files, shards); err != nil {
+			return shards, fmt.Errorf("restore database %s: %s", name, err)
+		}
+	}
+
+	return shards, nil
+}
+
+// restoreDatabase creates the database, its retention policies and shard
+// groups in the meta store under name and loads the spooled shards.
+func (s *Service) restoreDatabase(name string, di *meta.DatabaseInfo, retentionPolicy string, files map[string]string, shards map[uint64]uint64) error {
+	db, err := s.MetaStore.CreateDatabaseIfNotExists(name)
+	if err != nil {
+		return fmt.Errorf("create database: %s", err)
+	}
+
+	for _, rpi := range di.RetentionPolicies {
+		if retentionPolicy != "" && rpi.Name != retentionPolicy {
+			continue
+		}
+
+		if _, err := s.MetaStore.CreateRetentionPolicyIfNotExists(name, &meta.RetentionPolicyInfo{
+			Name:            rpi.Name,
+			ReplicaN:        rpi.ReplicaN,
+			Duration:        rpi.Duration,
+			DuplicatePolicy: rpi.DuplicatePolicy,
+		}); err != nil {
+			return fmt.Errorf("create retention policy %s: %s", rpi.Name, err)
+		}
+
+		// Keep the snapshot's default policy if the database doesn't have one.
+		if db.DefaultRetentionPolicy == "" && (rpi.Name == di.DefaultRetentionPolicy || retentionPolicy != "") {
+			if err := s.MetaStore.SetDefaultRetentionPolicy(name, rpi.Name); err != nil {
+				return fmt.Errorf("set default retention policy: %s", err)
+			}
+			db.DefaultRetentionPolicy = rpi.Name
+		}
+
+		for _, sgi := range rpi.ShardGroups {
+			if sgi.Deleted() {
+				continue
+			}
+			if err := s.restoreShardGroup(name, di.Name, rpi.Name, &sgi, files, shards); err != nil {
+				return fmt.Errorf("restore shard group %d: %s", sgi.ID, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// restoreShardGroup maps the shards in sgi to a shard group in the meta store
+// and loads their spooled data files onto every owner of the mapped shards.
+// If a shard can't be restored the restored copies are removed, along with
+// the shard group if it was created by the restore.
+func (s *Service) restoreShardGroup(database, source, retentionPolicy string, sgi *meta.ShardGroupInfo, files map[string]string, shards map[uint64]uint64) (err error) {
+	// Find the shards of the group in the snapshot, by their index in the group.
+	paths := make(map[int]string)
+	for i, si := range sgi.Shards {
+		if path, ok := files[fmt.Sprintf("%s/%s/%d", source, retentionPolicy, si.ID)]; ok {
+			paths[i] = path
+		}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+
+	// Create the target shard group, remembering if it already existed.
+	existing, err := s.MetaStore.ShardGroupByTimestamp(database, retentionPolicy, sgi.StartTime)
+	if err != nil {
+		return fmt.Errorf("shard group: %s", err)
+	}
+	target, err := s.MetaStore.CreateShardGroupIfNotExists(database, retentionPolicy, sgi.StartTime)
+	if err != nil {
+		return fmt.Errorf("create shard group: %s", err)
+	}
+
+	var restored []struct{ nodeID, shardID uint64 }
+	defer func() {
+		if err == nil {
+			return
+		}
+		for _, r := range restored {
+			if err := s.deleteShard(r.nodeID, r.shardID); err != nil {
+				s.Logger.Printf("failed to remove restored shard %d from node %d: %s", r.shardID, r.nodeID, err)
+			}
+		}
+		if existing == nil {
+			if err := s.MetaStore.DeleteShardGroup(database, retentionPolicy, target.ID); err != nil {
+				s.Logger.Printf("failed to remove shard group %d: %s", target.ID, err)
+			}
+		}
+	}()
+
+	if !target.StartTime.Equal(sgi.StartTime) || !target.EndTime.Equal(sgi.EndTime) {
+		return fmt.Errorf("shard group time range mismatch: %s-%s", target.StartTime, target.EndTime)
+	}
+
+	// Shards are remapped to the target group's shards in order and copied
+	// to each of their owners.
+	for i, si := range sgi.Shards {
+		path, ok := paths[i]
+		if !ok {
+			continue
+		} else if i >= len(target.Shards) {
+			return fmt.Errorf("no shard to restore shard %d into", si.ID)
+		}
+
+		tsi := target.Shards[i]
+		for _, so := range tsi.Owners {
+			if err := s.restoreShard(so.NodeID, database, retentionPolicy, tsi.ID, path); err != nil {
+				return fmt.Errorf("restore shard %d on node %d: %s", tsi.ID, so.NodeID, err)
+			}
+			restored = append(restored, struct{ nodeID, shardID uint64 }{so.NodeID, tsi.ID})
+		}
+		shards[si.ID] = tsi.ID
+		s.Logger.Printf("restored shard %d as %s/%s/%d", si.ID, database, retentionPolicy, tsi.ID)
+	}
+	return nil
+}
+
+// restoreShard loads a spooled shard data file into the store of a node.
+func (s *Service) restoreShard(nodeID uint64, database, retentionPolicy string, shardID uint64, path string) error {
+	if nodeID == s.MetaStore.NodeID() {
+		return s.TSDBStore.RestoreShard(database, retentionPolicy, shardID, path)
+	} else if s.Copier == nil {
+		return errors.New("remote restores not supported")
+	}
+	return s.Copier.RestoreShardTo(nodeID, database, retentionPolicy, shardID, path)
+}
+
+// deleteShard removes a shard from the store of a node.
+func (s *Service) deleteShard(nodeID, shardID uint64) error {
+	if nodeID == s.MetaStore.NodeID() {
+		return s.TSDBStore.DeleteShard(shardID)
+	} else if s.Copier == nil {
+		return errors.New("remote restores not supported")
+	}
+	return s.Copier.DeleteShardFrom(nodeID, shardID)
+}
+
+// spoolFile copies n bytes from r to a new file at path.
+func spoolFile(path string, r io.Reader, n int64) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := io.CopyN(f, r, n); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// readMessage reads a length-prefixed JSON message from r into v.
+func readMessage(r io.Reader, v interface{}) error {
+	var n uint32
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return fmt.Errorf("read length: %s", err)
+	}
+
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return fmt.Errorf("read body: %s", err)
+	}
+	return json.Unmarshal(buf, v)
+}
+
+// writeMessage writes v to w as a length-prefixed JSON message.
+func writeMessage(w io.Writer, v interface{}) error {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("marshal: %s", err)
+	}
+
+	if err := binary.Write(w, binary.BigEndian, uint32(len(buf))); err != nil {
+		return fmt.Errorf("write length: %s", err)
+	}
+	if _, err := w.Write(buf); err != nil {
+		return fmt.Errorf("write body: %s", err)
+	}
+	return nil
+}
+
+// Client represents a client for pushing snapshots to a restorer service.
+type Client struct {
+	host string
+
+	// If set, the client connects over TLS.
+	TLSConfig *tls.Config
+}
+
+// NewClient returns a new instance of Client.
+func NewClient(host string) *Client {
+	return &Client{
+		host: host,
+	}
+}
+
+// Restore pushes the snapshot written by w to the server and waits for it
+// to be restored.
+func (c *Client) Restore(req *Request, w io.WriterTo) (*Response, error) {
+	conn, err := tcp.DialTLS("tcp", c.host, MuxHeader, 0, c.TLSConfig)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := writeMessage(conn, req); err != nil {
+		return nil, fmt.Errorf("write request: %s", err)
+	}
+	if _, err := w.WriteTo(conn); err != nil {
+		return nil, fmt.Errorf("write snapshot: %s", err)
+	}
+
+	var resp Response
+	if err := readMessage(conn, &resp); err != nil {
+		return nil, fmt.Errorf("read response: %s", err)
+	}
+	if resp.Error != "" {
+		return &resp, errors.New(resp.Error)
+	}
+	return &resp, nil
+}
@@ -0,0 +1,374 @@
+package restorer_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdb/influxdb/meta"
+	"github.com/influxdb/influxdb/models"
+	"github.com/influxdb/influxdb/services/restorer"
+	"github.com/influxdb/influxdb/snapshot"
+	"github.com/influxdb/influxdb/tcp"
+	"github.com/influxdb/influxdb/tsdb"
+	_ "github.com/influxdb/influxdb/tsdb/engine"
+	"github.com/influxdb/influxdb/tsdb/engine/b1"
+)
+
+// Ensure the service can restore a snapshot under a new database name.
+func TestService_Restore(t *testing.T) {
+	s := MustOpenService()
+	defer s.Close()
+
+	// Build a snapshot of a store with a single shard.
+	// The b1 engine writes series to the data file before the WAL is flushed.
+	src := NewStore()
+	src.EngineOptions.EngineVersion = b1.Format
+	if err := src.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	if err := src.CreateShard("db0", "rp0", 1); err != nil {
+		t.Fatal(err)
+	}
+	p, _ := models.ParsePoints([]byte("cpu value=1 0"))
+	if err := src.WriteToShard(1, p); err != nil {
+		t.Fatal(err)
+	}
+
+	var data meta.Data
+	if err := data.CreateNode("host0"); err != nil {
+		t.Fatal(err)
+	} else if err := data.CreateDatabase("db0"); err != nil {
+		t.Fatal(err)
+	} else if err := data.CreateRetentionPolicy("db0", &meta.RetentionPolicyInfo{Name: "rp0", ReplicaN: 1}); err != nil {
+		t.Fatal(err)
+	} else if err := data.CreateShardGroup("db0", "rp0", time.Unix(0, 0)); err != nil {
+		t.Fatal(err)
+	}
+	buf, err := data.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sw, err := tsdb.NewSnapshotWriter(buf, src.Store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sw.Close()
+
+	// Mock the target meta store.
+	var created []string
+	s.MetaStore.CreateDatabaseIfNotExistsFn = func(name string) (*meta.DatabaseInfo, error) {
+		created = append(created, name)
+		return &meta.DatabaseInfo{Name: name}, nil
+	}
+	s.MetaStore.CreateRetentionPolicyIfNotExistsFn = func(database string, rpi *meta.RetentionPolicyInfo) (*meta.RetentionPolicyInfo, error) {
+		created = append(created, database+"."+rpi.Name)
+		return rpi, nil
+	}
+	s.MetaStore.CreateShardGroupIfNotExistsFn = func(database, policy string, timestamp time.Time) (*meta.ShardGroupInfo, error) {
+		sgi := data.Database("db0").RetentionPolicy("rp0").ShardGroups[0]
+		return &meta.ShardGroupInfo{
+			ID:        5,
+			StartTime: sgi.StartTime,
+			EndTime:   sgi.EndTime,
+			Shards:    []meta.ShardInfo{{ID: 10, Owners: []meta.ShardOwner{{NodeID: 1}}}},
+		}, nil
+	}
+
+	// Push the snapshot to the service.
+	resp, err := restorer.NewClient(s.Addr().String()).Restore(&restorer.Request{Database: "db0", NewDatabase: "db1"}, sw)
+	if err != nil {
+		t.Fatal(err)
+	} else if resp.Shards[1] != 10 {
+		t.Fatalf("unexpected shard mapping: %v", resp.Shards)
+	} else if len(created) != 2 || created[0] != "db1" || created[1] != "db1.rp0" {
+		t.Fatalf("unexpected meta changes: %v", created)
+	}
+
+	// Verify the shard was loaded into the store.
+	if sh := s.TSDBStore.Shard(10); sh == nil {
+		t.Fatal("expected shard to be restored")
+	} else if _, err := os.Stat(filepath.Join(s.TSDBStore.Path(), "db1", "rp0", "10")); err != nil {
+		t.Fatal(err)
+	} else if s.TSDBStore.DatabaseIndex("db1").Series("cpu") == nil {
+		t.Fatal("expected series cpu to be in the index")
+	}
+}
+
+// Ensure shards are restored onto every owner and removed again on failure.
+func TestService_Restore_Owners(t *testing.T) {
+	for _, fail := range []bool{false, true} {
+		s := MustOpenService()
+
+		sw, sgi, cleanup := MustWriteShardSnapshot()
+		s.MetaStore.CreateDatabaseIfNotExistsFn = func(name string) (*meta.DatabaseInfo, error) {
+			return &meta.DatabaseInfo{Name: name}, nil
+		}
+		s.MetaStore.CreateRetentionPolicyIfNotExistsFn = func(database string, rpi *meta.RetentionPolicyInfo) (*meta.RetentionPolicyInfo, error) {
+			return rpi, nil
+		}
+		s.MetaStore.CreateShardGroupIfNotExistsFn = func(database, policy string, timestamp time.Time) (*meta.ShardGroupInfo, error) {
+			return &meta.ShardGroupInfo{
+				ID:        5,
+				StartTime: sgi.StartTime,
+				EndTime:   sgi.EndTime,
+				Shards:    []meta.ShardInfo{{ID: 10, Owners: []meta.ShardOwner{{NodeID: 1}, {NodeID: 2}}}},
+			}, nil
+		}
+		var deletedGroup uint64
+		s.MetaStore.DeleteShardGroupFn = func(database, policy string, id uint64) error {
+			deletedGroup = id
+			return nil
+		}
+
+		// The copy to the remote owner fails if requested.
+		var remote []uint64
+		s.Copier.RestoreShardToFn = func(nodeID uint64, database, retentionPolicy string, shardID uint64, path string) error {
+			if fail {
+				return errors.New("marker")
+			}
+			remote = append(remote, nodeID, shardID)
+			return nil
+		}
+
+		_, err := restorer.NewClient(s.Addr().String()).Restore(&restorer.Request{}, sw)
+		if !fail {
+			if err != nil {
+				t.Fatal(err)
+			} else if !reflect.DeepEqual(remote, []uint64{2, 10}) {
+				t.Fatalf("unexpected remote restores: %v", remote)
+			} else if s.TSDBStore.Shard(10) == nil {
+				t.Fatal("expected shard to be restored")
+			} else if deletedGroup != 0 {
+				t.Fatalf("unexpected shard group deletion: %d", deletedGroup)
+			}
+		} else {
+			if err == nil || !strings.Contains(err.Error(), "marker") {
+				t.Fatalf("unexpected error: %v", err)
+			} else if s.TSDBStore.Shard(10) != nil {
+				t.Fatal("expected restored shard to be removed")
+			} else if deletedGroup != 5 {
+				t.Fatalf("unexpected shard group deletion: %d", deletedGroup)
+			}
+		}
+
+		cleanup()
+		s.Close()
+	}
+}
+
+// Ensure the service returns an error for a database that isn't in the snapshot.
+func TestService_Restore_ErrDatabaseNotFound(t *testing.T) {
+	s := MustOpenService()
+	defer s.Close()
+
+	var data meta.Data
+	buf, err := data.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := MustOpenStore()
+	defer src.Close()
+	sw, err := tsdb.NewSnapshotWriter(buf, src.Store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sw.Close()
+
+	_, err = restorer.NewClient(s.Addr().String()).Restore(&restorer.Request{Database: "db0"}, sw)
+	if err == nil || err.Error() != `database not found in snapshot: db0` {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// MustWriteShardSnapshot returns a snapshot writer for a store with a single
+// shard in db0.rp0, the shard group holding it, and a function closing them.
+func MustWriteShardSnapshot() (*snapshot.Writer, meta.ShardGroupInfo, func()) {
+	// The b1 engine writes series to the data file before the WAL is flushed.
+	src := NewStore()
+	src.EngineOptions.EngineVersion = b1.Format
+	if err := src.Open(); err != nil {
+		panic(err)
+	}
+	if err := src.CreateShard("db0", "rp0", 1); err != nil {
+		panic(err)
+	}
+	p, _ := models.ParsePoints([]byte("cpu value=1 0"))
+	if err := src.WriteToShard(1, p); err != nil {
+		panic(err)
+	}
+
+	var data meta.Data
+	if err := data.CreateNode("host0"); err != nil {
+		panic(err)
+	} else if err := data.CreateDatabase("db0"); err != nil {
+		panic(err)
+	} else if err := data.CreateRetentionPolicy("db0", &meta.RetentionPolicyInfo{Name: "rp0", ReplicaN: 1}); err != nil {
+		panic(err)
+	} else if err := data.CreateShardGroup("db0", "rp0", time.Unix(0, 0)); err != nil {
+		panic(err)
+	}
+	buf, err := data.MarshalBinary()
+	if err != nil {
+		panic(err)
+	}
+	sw, err := tsdb.NewSnapshotWriter(buf, src.Store)
+	if err != nil {
+		panic(err)
+	}
+
+	return sw, data.Database("db0").RetentionPolicy("rp0").ShardGroups[0], func() {
+		sw.Close()
+		src.Close()
+	}
+}
+
+// Service represents a test wrapper for restorer.Service.
+type Service struct {
+	*restorer.Service
+
+	ln        net.Listener
+	MetaStore ServiceMetaStore
+	TSDBStore *Store
+	Copier    ServiceCopier
+}
+
+// MustOpenService returns a new, opened service. Panic on error.
+func MustOpenService() *Service {
+	// Open randomly assigned port.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+
+	// Start muxer.
+	mux := tcp.NewMux()
+
+	// Create new service and attach mux'd listener.
+	s := &Service{
+		Service:   restorer.NewService(),
+		ln:        ln,
+		TSDBStore: MustOpenStore(),
+	}
+	s.Service.MetaStore = &s.MetaStore
+	s.Service.TSDBStore = s.TSDBStore.Store
+	s.Service.Copier = &s.Copier
+	s.Listener = mux.Listen(restorer.MuxHeader)
+	if !testing.Verbose() {
+		s.SetLogger(log.New(ioutil.Discard, "", 0))
+	}
+	go mux.Serve(ln)
+
+	if err := s.Open(); err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Close shuts down the service and the attached listener.
+func (s *Service) Close() error {
+	s.ln.Close()
+	err := s.Service.Close()
+	s.TSDBStore.Close()
+	return err
+}
+
+// Addr returns the address of the service.
+func (s *Service) Addr() net.Addr { return s.ln.Addr() }
+
+// ServiceMetaStore is a mock that implements restorer.Service.MetaStore.
+type ServiceMetaStore struct {
+	CreateDatabaseIfNotExistsFn        func(name string) (*meta.DatabaseInfo, error)
+	CreateRetentionPolicyIfNotExistsFn func(database string, rpi *meta.RetentionPolicyInfo) (*meta.RetentionPolicyInfo, error)
+	SetDefaultRetentionPolicyFn        func(database, name string) error
+	CreateShardGroupIfNotExistsFn      func(database, policy string, timestamp time.Time) (*meta.ShardGroupInfo, error)
+	DeleteShardGroupFn                 func(database, policy string, id uint64) error
+}
+
+func (ms *ServiceMetaStore) NodeID() uint64 { return 1 }
+
+func (ms *ServiceMetaStore) CreateDatabaseIfNotExists(name string) (*meta.DatabaseInfo, error) {
+	return ms.CreateDatabaseIfNotExistsFn(name)
+}
+
+func (ms *ServiceMetaStore) CreateRetentionPolicyIfNotExists(database string, rpi *meta.RetentionPolicyInfo) (*meta.RetentionPolicyInfo, error) {
+	return ms.CreateRetentionPolicyIfNotExistsFn(database, rpi)
+}
+
+func (ms *ServiceMetaStore) SetDefaultRetentionPolicy(database, name string) error {
+	if ms.SetDefaultRetentionPolicyFn == nil {
+		return nil
+	}
+	return ms.SetDefaultRetentionPolicyFn(database, name)
+}
+
+func (ms *ServiceMetaStore) ShardGroupByTimestamp(database, policy string, timestamp time.Time) (*meta.ShardGroupInfo, error) {
+	return nil, nil
+}
+
+func (ms *ServiceMetaStore) CreateShardGroupIfNotExists(database, policy string, timestamp time.Time) (*meta.ShardGroupInfo, error) {
+	return ms.CreateShardGroupIfNotExistsFn(database, policy, timestamp)
+}
+
+func (ms *ServiceMetaStore) DeleteShardGroup(database, policy string, id uint64) error {
+	if ms.DeleteShardGroupFn == nil {
+		return nil
+	}
+	return ms.DeleteShardGroupFn(database, policy, id)
+}
+
+// ServiceCopier is a mock that implements restorer.Service.Copier.
+type ServiceCopier struct {
+	RestoreShardToFn  func(nodeID uint64, database, retentionPolicy string, shardID uint64, path string) error
+	DeleteShardFromFn func(nodeID, shardID uint64) error
+}
+
+func (c *ServiceCopier) RestoreShardTo(nodeID uint64, database, retentionPolicy string, shardID uint64, path string) error {
+	return c.RestoreShardToFn(nodeID, database, retentionPolicy, shardID, path)
+}
+
+func (c *ServiceCopier) DeleteShardFrom(nodeID, shardID uint64) error {
+	return c.DeleteShardFromFn(nodeID, shardID)
+}
+
+// Store is a test wrapper for tsdb.Store.
+type Store struct {
+	*tsdb.Store
+	path string
+}
+
+// NewStore returns a store in a temporary directory.
+func NewStore() *Store {
+	path, err := ioutil.TempDir("", "restorer-")
+	if err != nil {
+		panic(err)
+	}
+
+	s := &Store{Store: tsdb.NewStore(filepath.Join(path, "data")), path: path}
+	s.EngineOptions.Config.WALDir = filepath.Join(path, "wal")
+	return s
+}
+
+// MustOpenStore returns a temporary, opened store. Panic on error.
+func MustOpenStore() *Store {
+	s := NewStore()
+	if err := s.Open(); err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Close closes the store and removes its data.
+func (s *Store) Close() error {
+	err := s.Store.Close()
+	os.RemoveAll(s.path)
+	return err
+}
@@ -1,14 +1,23 @@
 package retention
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/influxdb/influxdb/cluster"
+	"github.com/influxdb/influxdb/influxql"
 	"github.com/influxdb/influxdb/meta"
+	"github.com/influxdb/influxdb/models"
+	"github.com/influxdb/influxdb/tsdb"
 )
 
+// downsampleBatchSize is the number of downsampled points written at a time.
+const downsampleBatchSize = 5000
+
 // Service represents the retention policy enforcement service.
 type Service struct {
 	MetaStore interface {
@@ -21,6 +30,15 @@ type Service struct {
 		DeleteShard(shardID uint64) error
 	}
 
+	// QueryExecutor and PointsWriter are used to downsample expired shard groups
+	// into another retention policy before they're deleted.
+	QueryExecutor interface {
+		ExecuteQuery(query *influxql.Query, database string, chunkSize int, level tsdb.ReadConsistency) (<-chan *influxql.Result, error)
+	}
+	PointsWriter interface {
+		WritePoints(p *cluster.WritePointsRequest) error
+	}
+
 	enabled       bool
 	checkInterval time.Duration
 	wg            sync.WaitGroup
@@ -80,6 +98,18 @@ func (s *Service) deleteShardGroups() {
 
 			s.MetaStore.VisitRetentionPolicies(func(d meta.DatabaseInfo, r meta.RetentionPolicyInfo) {
 				for _, g := range r.ExpiredShardGroups(time.Now().UTC()) {
+					// Keep the group until it has been downsampled so it's tried again on the next check.
+					if r.DownsampleTo != "" {
+						n, err := s.downsample(d.Name, &r, g)
+						if err != nil {
+							s.logger.Printf("failed to downsample shard group %d from database %s, retention policy %s into %s: %s",
+								g.ID, d.Name, r.Name, r.DownsampleTo, err.Error())
+							continue
+						}
+						s.logger.Printf("downsampled shard group %d from database %s, retention policy %s into %s: %d points",
+							g.ID, d.Name, r.Name, r.DownsampleTo, n)
+					}
+
 					if err := s.MetaStore.DeleteShardGroup(d.Name, r.Name, g.ID); err != nil {
 						s.logger.Printf("failed to delete shard group %d from database %s, retention policy %s: %s",
 							g.ID, d.Name, r.Name, err.Error())
@@ -127,3 +157,216 @@ func (s *Service) deleteShards() {
 		}
 	}
 }
+
+// downsample aggregates the numeric fields of every measurement in the shard group g into
+// the mean, min and max over each of the policy's downsample intervals, and writes them to
+// the policy's downsample target. The aggregated fields are named after the function and
+// the field, e.g. mean_value. Returns the number of points written.
+func (s *Service) downsample(database string, rpi *meta.RetentionPolicyInfo, g *meta.ShardGroupInfo) (int, error) {
+	if s.QueryExecutor == nil || s.PointsWriter == nil {
+		return 0, fmt.Errorf("downsampling not configured")
+	}
+
+	// Find the fields of each measurement.
+	fields, err := s.fieldKeys(database)
+	if err != nil {
+		return 0, fmt.Errorf("field keys: %s", err)
+	}
+
+	n := 0
+	for _, name := range sortedKeys(fields) {
+		// Merge the aggregates of every field into one point per series and interval.
+		points := make(map[string]*downsamplePoint)
+		for _, field := range fields[name] {
+			src := &influxql.Measurement{Database: database, RetentionPolicy: rpi.Name, Name: name}
+
+			// Aggregates only apply to numeric fields.
+			if ok, err := s.isNumeric(database, src, field, g); err != nil {
+				return n, err
+			} else if !ok {
+				continue
+			}
+
+			q, err := influxql.ParseQuery(fmt.Sprintf(
+				`SELECT mean(%[1]s) AS %[2]s, min(%[1]s) AS %[3]s, max(%[1]s) AS %[4]s FROM %[5]s WHERE %[6]s GROUP BY time(%[7]s), * fill(none)`,
+				influxql.QuoteIdent(field),
+				influxql.QuoteIdent("mean_"+field), influxql.QuoteIdent("min_"+field), influxql.QuoteIdent("max_"+field),
+				src.String(), timeCondition(g), influxql.FormatDuration(rpi.DownsampleInterval),
+			))
+			if err != nil {
+				return n, err
+			}
+
+			rows, err := s.execute(q, database)
+			if err != nil {
+				return n, err
+			}
+			for _, row := range rows {
+				addDownsampleRow(points, name, row)
+			}
+		}
+
+		// Write the points for the measurement in batches.
+		batch := make([]models.Point, 0, downsampleBatchSize)
+		for _, key := range sortedPointKeys(points) {
+			p := points[key]
+			if len(p.fields) == 0 {
+				continue
+			}
+			batch = append(batch, models.NewPoint(name, models.Tags(p.tags), p.fields, p.time))
+			if len(batch) == downsampleBatchSize {
+				if err := s.writePoints(database, rpi.DownsampleTo, batch); err != nil {
+					return n, err
+				}
+				n += len(batch)
+				batch = batch[:0]
+			}
+		}
+		if len(batch) > 0 {
+			if err := s.writePoints(database, rpi.DownsampleTo, batch); err != nil {
+				return n, err
+			}
+			n += len(batch)
+		}
+	}
+
+	return n, nil
+}
+
+// fieldKeys returns the field keys of each measurement in the database.
+func (s *Service) fieldKeys(database string) (map[string][]string, error) {
+	rows, err := s.execute(&influxql.Query{Statements: influxql.Statements{&influxql.ShowFieldKeysStatement{}}}, database)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string][]string)
+	for _, row := range rows {
+		for _, v := range row.Values {
+			if len(v) == 0 {
+				continue
+			}
+			if key, ok := v[0].(string); ok {
+				fields[row.Name] = append(fields[row.Name], key)
+			}
+		}
+	}
+	return fields, nil
+}
+
+// isNumeric returns true if the first value of field in the shard group is numeric.
+// Returns false if there are no values.
+func (s *Service) isNumeric(database string, src *influxql.Measurement, field string, g *meta.ShardGroupInfo) (bool, error) {
+	q, err := influxql.ParseQuery(fmt.Sprintf(`SELECT %s FROM %s WHERE %s LIMIT 1`,
+		influxql.QuoteIdent(field), src.String(), timeCondition(g)))
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := s.execute(q, database)
+	if err != nil {
+		return false, err
+	}
+	for _, row := range rows {
+		for _, v := range row.Values {
+			if len(v) < 2 {
+				continue
+			}
+			switch v[1].(type) {
+			case float64, int64, uint64:
+				return true, nil
+			case nil:
+				continue
+			default:
+				return false, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// execute runs a query and returns all the rows of its results.
+func (s *Service) execute(q *influxql.Query, database string) (models.Rows, error) {
+	ch, err := s.QueryExecutor.ExecuteQuery(q, database, 0, tsdb.ReadConsistencyOne)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows models.Rows
+	for result := range ch {
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		rows = append(rows, result.Series...)
+	}
+	return rows, nil
+}
+
+// writePoints writes downsampled points into the target retention policy.
+func (s *Service) writePoints(database, retentionPolicy string, points []models.Point) error {
+	return s.PointsWriter.WritePoints(&cluster.WritePointsRequest{
+		Database:         database,
+		RetentionPolicy:  retentionPolicy,
+		ConsistencyLevel: cluster.ConsistencyLevelOne,
+		Points:           points,
+	})
+}
+
+// timeCondition returns a WHERE clause matching the time range of a shard group.
+func timeCondition(g *meta.ShardGroupInfo) string {
+	return fmt.Sprintf(`time >= '%s' AND time < '%s'`,
+		g.StartTime.UTC().Format(time.RFC3339Nano), g.EndTime.UTC().Format(time.RFC3339Nano))
+}
+
+// downsamplePoint is a downsampled point being built from the aggregates of each field.
+type downsamplePoint struct {
+	tags   map[string]string
+	time   time.Time
+	fields map[string]interface{}
+}
+
+// addDownsampleRow adds the aggregates in a result row to the points of the measurement name.
+func addDownsampleRow(points map[string]*downsamplePoint, name string, row *models.Row) {
+	seriesKey := string(models.MakeKey([]byte(name), models.Tags(row.Tags)))
+	for _, v := range row.Values {
+		if len(v) != len(row.Columns) {
+			continue
+		}
+		t, ok := v[0].(time.Time)
+		if !ok {
+			continue
+		}
+
+		key := fmt.Sprintf("%s %d", seriesKey, t.UnixNano())
+		p := points[key]
+		if p == nil {
+			p = &downsamplePoint{tags: row.Tags, time: t, fields: make(map[string]interface{})}
+			points[key] = p
+		}
+		for i, c := range row.Columns[1:] {
+			if v[i+1] != nil {
+				p.fields[c] = v[i+1]
+			}
+		}
+	}
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys(m map[string][]string) []string {
+	a := make([]string, 0, len(m))
+	for k := range m {
+		a = append(a, k)
+	}
+	sort.Strings(a)
+	return a
+}
+
+// sortedPointKeys returns the keys of m in order.
+func sortedPointKeys(m map[string]*downsamplePoint) []string {
+	a := make([]string, 0, len(m))
+	for k := range m {
+		a = append(a, k)
+	}
+	sort.Strings(a)
+	return a
+}
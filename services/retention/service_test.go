@@ -0,0 +1,109 @@
+package retention
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/influxdb/influxdb/cluster"
+	"github.com/influxdb/influxdb/influxql"
+	"github.com/influxdb/influxdb/meta"
+	"github.com/influxdb/influxdb/models"
+	"github.com/influxdb/influxdb/tsdb"
+)
+
+// Ensure an expired shard group is aggregated into the downsample target.
+func TestService_downsample(t *testing.T) {
+	start := time.Date(2015, 10, 1, 0, 0, 0, 0, time.UTC)
+	rpi := &meta.RetentionPolicyInfo{Name: "7d", DownsampleTo: "1y", DownsampleInterval: 5 * time.Minute}
+	g := &meta.ShardGroupInfo{ID: 1, StartTime: start, EndTime: start.Add(24 * time.Hour)}
+
+	var queries []string
+	qe := &queryExecutor{fn: func(stmt influxql.Statement) *influxql.Result {
+		queries = append(queries, stmt.String())
+		switch stmt := stmt.(type) {
+		case *influxql.ShowFieldKeysStatement:
+			return &influxql.Result{Series: models.Rows{
+				{Name: "cpu", Columns: []string{"fieldKey"}, Values: [][]interface{}{{"status"}, {"value"}}},
+			}}
+		case *influxql.SelectStatement:
+			if !stmt.IsRawQuery {
+				tags := map[string]string{"host": "serverA"}
+				return &influxql.Result{Series: models.Rows{
+					{Name: "cpu", Tags: tags, Columns: []string{"time", "mean_value", "min_value", "max_value"}, Values: [][]interface{}{
+						{start, 2.0, 1.0, 3.0},
+						{start.Add(5 * time.Minute), 5.0, 4.0, 6.0},
+					}},
+				}}
+			}
+			// Only "value" is numeric.
+			v := interface{}("ok")
+			if stmt.Fields[0].Expr.(*influxql.VarRef).Val == "value" {
+				v = 1.0
+			}
+			return &influxql.Result{Series: models.Rows{
+				{Name: "cpu", Columns: []string{"time", "x"}, Values: [][]interface{}{{start, v}}},
+			}}
+		}
+		t.Fatalf("unexpected statement: %s", stmt)
+		return nil
+	}}
+
+	var req *cluster.WritePointsRequest
+	pw := &pointsWriter{fn: func(p *cluster.WritePointsRequest) error {
+		req = p
+		return nil
+	}}
+
+	s := NewService(NewConfig())
+	s.QueryExecutor = qe
+	s.PointsWriter = pw
+
+	n, err := s.downsample("db0", rpi, g)
+	if err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatalf("unexpected points written: %d", n)
+	}
+
+	exp := `SELECT mean(value) AS "mean_value", min(value) AS "min_value", max(value) AS "max_value" FROM "db0"."7d".cpu WHERE time >= '2015-10-01T00:00:00Z' AND time < '2015-10-02T00:00:00Z' GROUP BY time(5m), * fill(none)`
+	if queries[len(queries)-1] != exp {
+		t.Fatalf("unexpected query:\n got: %s\n exp: %s", queries[len(queries)-1], exp)
+	}
+
+	if req.Database != "db0" || req.RetentionPolicy != "1y" {
+		t.Fatalf("unexpected write target: %s.%s", req.Database, req.RetentionPolicy)
+	}
+
+	var got []string
+	for _, p := range req.Points {
+		got = append(got, p.String())
+	}
+	sort.Strings(got)
+	if exp := []string{
+		"cpu,host=serverA max_value=3,mean_value=2,min_value=1 1443657600000000000",
+		"cpu,host=serverA max_value=6,mean_value=5,min_value=4 1443657900000000000",
+	}; !reflect.DeepEqual(got, exp) {
+		t.Fatalf("unexpected points:\n got: %v\n exp: %v", got, exp)
+	}
+}
+
+type queryExecutor struct {
+	fn func(stmt influxql.Statement) *influxql.Result
+}
+
+func (qe *queryExecutor) ExecuteQuery(query *influxql.Query, database string, chunkSize int, level tsdb.ReadConsistency) (<-chan *influxql.Result, error) {
+	ch := make(chan *influxql.Result, len(query.Statements))
+	for _, stmt := range query.Statements {
+		ch <- qe.fn(stmt)
+	}
+	close(ch)
+	return ch, nil
+}
+
+type pointsWriter struct {
+	fn func(p *cluster.WritePointsRequest) error
+}
+
+func (pw *pointsWriter) WritePoints(p *cluster.WritePointsRequest) error { return pw.fn(p) }
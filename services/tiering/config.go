@@ -0,0 +1,47 @@
+package tiering
+
+import (
+	"errors"
+	"time"
+
+	"github.com/influxdb/influxdb/toml"
+)
+
+const (
+	// DefaultCheckInterval is how often the service checks for shards to move.
+	DefaultCheckInterval = 10 * time.Minute
+
+	// DefaultAge is how long after the end time of a shard group that its shards
+	// are moved to the cold data directory.
+	DefaultAge = 7 * 24 * time.Hour
+)
+
+// Config represents the configuration for the tiered storage service.
+type Config struct {
+	Enabled       bool          `toml:"enabled"`
+	CheckInterval toml.Duration `toml:"check-interval"`
+	Age           toml.Duration `toml:"age"`
+}
+
+// NewConfig returns a new Config with defaults.
+func NewConfig() Config {
+	return Config{
+		Enabled:       false,
+		CheckInterval: toml.Duration(DefaultCheckInterval),
+		Age:           toml.Duration(DefaultAge),
+	}
+}
+
+// Validate returns an error if the config is invalid.
+func (c Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.CheckInterval <= 0 {
+		return errors.New("check-interval must be positive")
+	}
+	if c.Age < 0 {
+		return errors.New("age must not be negative")
+	}
+	return nil
+}
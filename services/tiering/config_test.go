@@ -0,0 +1,43 @@
+package tiering_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/influxdb/influxdb/services/tiering"
+)
+
+func TestConfig_Parse(t *testing.T) {
+	// Parse configuration.
+	var c tiering.Config
+	if _, err := toml.Decode(`
+enabled = true
+check-interval = "2m"
+age = "48h"
+`, &c); err != nil {
+		t.Fatal(err)
+	}
+
+	// Validate configuration.
+	if !c.Enabled {
+		t.Fatalf("unexpected enabled state: %v", c.Enabled)
+	} else if time.Duration(c.CheckInterval) != 2*time.Minute {
+		t.Fatalf("unexpected check interval: %s", c.CheckInterval)
+	} else if time.Duration(c.Age) != 48*time.Hour {
+		t.Fatalf("unexpected age: %s", c.Age)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	c := tiering.NewConfig()
+	if err := c.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	c.Enabled = true
+	c.CheckInterval = 0
+	if err := c.Validate(); err == nil {
+		t.Fatal("expected error for zero check interval")
+	}
+}
@@ -0,0 +1,123 @@
+package tiering
+
+import (
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/influxdb/influxdb/meta"
+)
+
+// Service moves the shards of shard groups that ended more than a configured age
+// ago from the data directory to the cold data directory.
+type Service struct {
+	MetaStore interface {
+		VisitRetentionPolicies(f func(d meta.DatabaseInfo, r meta.RetentionPolicyInfo))
+	}
+	TSDBStore interface {
+		ShardIDs() []uint64
+		ColdPath() string
+		ColdShardPath(shardID uint64) string
+		MoveShard(shardID uint64, dir string) error
+	}
+
+	checkInterval time.Duration
+	age           time.Duration
+	wg            sync.WaitGroup
+	done          chan struct{}
+
+	logger *log.Logger
+}
+
+// NewService returns a configured tiered storage service.
+func NewService(c Config) *Service {
+	return &Service{
+		checkInterval: time.Duration(c.CheckInterval),
+		age:           time.Duration(c.Age),
+		logger:        log.New(os.Stderr, "[tiering] ", log.LstdFlags),
+	}
+}
+
+// Open starts moving cold shards.
+func (s *Service) Open() error {
+	if s.done != nil {
+		return nil
+	}
+
+	s.logger.Printf("Starting tiered storage service with check interval of %s, age of %s, cold dir %s",
+		s.checkInterval, s.age, s.TSDBStore.ColdPath())
+
+	s.done = make(chan struct{})
+
+	s.wg.Add(1)
+	go s.run()
+	return nil
+}
+
+// Close stops the service.
+func (s *Service) Close() error {
+	if s.done == nil {
+		return nil
+	}
+
+	close(s.done)
+	s.wg.Wait()
+	s.done = nil
+	return nil
+}
+
+// SetLogger sets the internal logger to the logger passed in.
+func (s *Service) SetLogger(l *log.Logger) {
+	s.logger = l
+}
+
+func (s *Service) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+
+		case <-ticker.C:
+			s.moveShards(time.Now().UTC())
+		}
+	}
+}
+
+// moveShards moves the local shards that are older than the configured age at now
+// to the cold data directory.
+func (s *Service) moveShards(now time.Time) {
+	cutoff := now.Add(-s.age)
+
+	// find the shards of shard groups that ended before the cutoff
+	cold := make(map[uint64]struct{})
+	s.MetaStore.VisitRetentionPolicies(func(d meta.DatabaseInfo, r meta.RetentionPolicyInfo) {
+		for _, g := range r.ShardGroups {
+			if g.Deleted() || !g.EndTime.Before(cutoff) {
+				continue
+			}
+			for _, sh := range g.Shards {
+				cold[sh.ID] = struct{}{}
+			}
+		}
+	})
+
+	dir := s.TSDBStore.ColdPath()
+	for _, id := range s.TSDBStore.ShardIDs() {
+		if _, ok := cold[id]; !ok {
+			continue
+		} else if s.TSDBStore.ColdShardPath(id) != "" {
+			continue
+		}
+
+		if err := s.TSDBStore.MoveShard(id, dir); err != nil {
+			s.logger.Printf("failed to move shard %d to %s: %s", id, dir, err)
+			continue
+		}
+		s.logger.Printf("moved shard %d to %s", id, s.TSDBStore.ColdShardPath(id))
+	}
+}
@@ -0,0 +1,71 @@
+package tiering
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/influxdb/influxdb/meta"
+	"github.com/influxdb/influxdb/toml"
+)
+
+// Ensure only the local shards of shard groups older than the age are moved.
+func TestService_moveShards(t *testing.T) {
+	now := time.Date(2015, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	ms := metaStore{
+		rps: []meta.RetentionPolicyInfo{{
+			Name: "default",
+			ShardGroups: []meta.ShardGroupInfo{
+				{ID: 1, EndTime: now.Add(-72 * time.Hour), Shards: []meta.ShardInfo{{ID: 1}, {ID: 2}}},
+				{ID: 2, EndTime: now.Add(-72 * time.Hour), Shards: []meta.ShardInfo{{ID: 3}}},
+				{ID: 3, EndTime: now.Add(-72 * time.Hour), DeletedAt: now, Shards: []meta.ShardInfo{{ID: 4}}},
+				{ID: 4, EndTime: now.Add(-1 * time.Hour), Shards: []meta.ShardInfo{{ID: 5}}},
+			},
+		}},
+	}
+
+	// shard 2 isn't local and shard 3 is already cold
+	ts := &tsdbStore{
+		ids:  []uint64{1, 3, 4, 5},
+		cold: map[uint64]string{3: "/cold/db0/default/3"},
+	}
+
+	s := NewService(Config{
+		CheckInterval: toml.Duration(time.Minute),
+		Age:           toml.Duration(48 * time.Hour),
+	})
+	s.MetaStore = ms
+	s.TSDBStore = ts
+
+	s.moveShards(now)
+
+	if exp := []uint64{1}; !reflect.DeepEqual(ts.moved, exp) {
+		t.Fatalf("unexpected moved shards: got %v, exp %v", ts.moved, exp)
+	}
+}
+
+type metaStore struct {
+	rps []meta.RetentionPolicyInfo
+}
+
+func (m metaStore) VisitRetentionPolicies(f func(d meta.DatabaseInfo, r meta.RetentionPolicyInfo)) {
+	for _, rp := range m.rps {
+		f(meta.DatabaseInfo{Name: "db0"}, rp)
+	}
+}
+
+type tsdbStore struct {
+	ids   []uint64
+	cold  map[uint64]string
+	moved []uint64
+}
+
+func (s *tsdbStore) ShardIDs() []uint64                  { return s.ids }
+func (s *tsdbStore) ColdPath() string                    { return "/cold" }
+func (s *tsdbStore) ColdShardPath(shardID uint64) string { return s.cold[shardID] }
+func (s *tsdbStore) MoveShard(shardID uint64, dir string) error {
+	s.moved = append(s.moved, shardID)
+	s.cold[shardID] = dir
+	return nil
+}
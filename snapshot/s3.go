@@ -0,0 +1,359 @@
+package snapshot
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultS3Endpoint is the default endpoint of an S3Target.
+	DefaultS3Endpoint = "https://s3.amazonaws.com"
+
+	// DefaultS3Region is the default region of an S3Target.
+	DefaultS3Region = "us-east-1"
+
+	// DefaultS3PartSize is the default size of the parts of a multipart upload.
+	DefaultS3PartSize = 64 * 1024 * 1024
+
+	// s3MaxParts is the maximum number of parts in a multipart upload.
+	s3MaxParts = 10000
+)
+
+// S3Target stores backup chains as objects in a bucket of an S3-compatible
+// HTTP API. Objects are addressed path-style so any endpoint can be used.
+type S3Target struct {
+	Endpoint string
+	Region   string
+	Bucket   string
+
+	// Prefix is prepended to the name of every object.
+	Prefix string
+
+	// Requests are signed with AWS signature version 4 if set.
+	AccessKeyID     string
+	SecretAccessKey string
+
+	// Objects larger than PartSize are uploaded in parts of PartSize bytes.
+	// The part size grows if more parts than S3 allows would be needed.
+	PartSize int64
+
+	HTTPClient *http.Client
+}
+
+// NewS3Target returns a new instance of S3Target with defaults set.
+func NewS3Target(bucket, prefix string) *S3Target {
+	return &S3Target{
+		Endpoint:   DefaultS3Endpoint,
+		Region:     DefaultS3Region,
+		Bucket:     bucket,
+		Prefix:     prefix,
+		PartSize:   DefaultS3PartSize,
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// Put uploads size bytes from r as the object for name. Objects larger than
+// the part size are sent as a multipart upload.
+func (t *S3Target) Put(name string, r io.Reader, size int64) error {
+	partSize := t.PartSize
+	if partSize <= 0 {
+		partSize = DefaultS3PartSize
+	}
+	if size <= partSize {
+		resp, err := t.do("PUT", t.Prefix+name, nil, r, size)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+
+	if n := (size + s3MaxParts - 1) / s3MaxParts; n > partSize {
+		partSize = n
+	}
+	return t.putMultipart(t.Prefix+name, r, size, partSize)
+}
+
+// putMultipart uploads size bytes from r as the object for key in parts of
+// partSize bytes. The upload is aborted if any part fails.
+func (t *S3Target) putMultipart(key string, r io.Reader, size, partSize int64) error {
+	resp, err := t.do("POST", key, url.Values{"uploads": {""}}, nil, 0)
+	if err != nil {
+		return fmt.Errorf("initiate upload: %s", err)
+	}
+	var initiate struct {
+		UploadID string `xml:"UploadId"`
+	}
+	err = xml.NewDecoder(resp.Body).Decode(&initiate)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("decode upload: %s", err)
+	}
+
+	if err := t.putParts(key, initiate.UploadID, r, size, partSize); err != nil {
+		if resp, err := t.do("DELETE", key, url.Values{"uploadId": {initiate.UploadID}}, nil, 0); err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+	return nil
+}
+
+// putParts uploads the parts of a multipart upload and completes it.
+func (t *S3Target) putParts(key, uploadID string, r io.Reader, size, partSize int64) error {
+	type part struct {
+		PartNumber int
+		ETag       string
+	}
+	var complete struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []part   `xml:"Part"`
+	}
+
+	for n := int64(0); n < size; n += partSize {
+		sz := partSize
+		if size-n < sz {
+			sz = size - n
+		}
+
+		num := len(complete.Parts) + 1
+		resp, err := t.do("PUT", key, url.Values{
+			"partNumber": {strconv.Itoa(num)},
+			"uploadId":   {uploadID},
+		}, io.LimitReader(r, sz), sz)
+		if err != nil {
+			return fmt.Errorf("upload part %d: %s", num, err)
+		}
+		resp.Body.Close()
+		complete.Parts = append(complete.Parts, part{PartNumber: num, ETag: resp.Header.Get("ETag")})
+	}
+
+	body, err := xml.Marshal(&complete)
+	if err != nil {
+		return fmt.Errorf("marshal parts: %s", err)
+	}
+	resp, err := t.do("POST", key, url.Values{"uploadId": {uploadID}}, bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return fmt.Errorf("complete upload: %s", err)
+	}
+	defer resp.Body.Close()
+
+	// Completing an upload can fail after the response status is sent, in
+	// which case the body holds an error.
+	var result struct {
+		XMLName xml.Name
+		Code    string
+		Message string
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("decode complete upload: %s", err)
+	} else if result.XMLName.Local == "Error" {
+		return fmt.Errorf("complete upload: %s: %s", result.Code, result.Message)
+	}
+	return nil
+}
+
+// Get returns the body of the object for name.
+func (t *S3Target) Get(name string) (io.ReadCloser, error) {
+	resp, err := t.do("GET", t.Prefix+name, nil, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// List returns all objects under the prefix.
+func (t *S3Target) List() ([]File, error) {
+	var a []File
+	var token string
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {t.Prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := t.do("GET", "", query, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		var result struct {
+			Contents []struct {
+				Key          string
+				Size         int64
+				LastModified time.Time
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("decode list: %s", err)
+		}
+
+		for _, c := range result.Contents {
+			a = append(a, File{Name: strings.TrimPrefix(c.Key, t.Prefix), Size: c.Size, ModTime: c.LastModified})
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+
+	sort.Sort(Files(a))
+	return a, nil
+}
+
+// Delete removes the object for name.
+func (t *S3Target) Delete(name string) error {
+	resp, err := t.do("DELETE", t.Prefix+name, nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// do sends a signed request for key in the bucket. The bucket itself is
+// requested if key is blank. Returns an error for non-2xx responses.
+func (t *S3Target) do(method, key string, query url.Values, body io.Reader, size int64) (*http.Response, error) {
+	u, err := url.Parse(t.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parse endpoint: %s", err)
+	}
+	u.Path = "/" + t.Bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	u.RawPath = s3Escape(u.Path, false)
+	u.RawQuery = s3CanonicalQuery(query)
+
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	t.sign(req, time.Now().UTC())
+
+	resp, err := t.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound && key != "":
+		resp.Body.Close()
+		return nil, &os.PathError{Op: strings.ToLower(method), Path: key, Err: os.ErrNotExist}
+	case resp.StatusCode/100 != 2:
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, fmt.Errorf("s3 %s %s: status=%d, body=%s", method, u.Path, resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+	return resp, nil
+}
+
+// sign adds an AWS signature version 4 to req. The payload isn't signed so
+// it can be streamed. Requests are sent anonymously without credentials.
+func (t *S3Target) sign(req *http.Request, now time.Time) {
+	if t.AccessKeyID == "" {
+		return
+	}
+
+	const payloadHash = "UNSIGNED-PAYLOAD"
+	amzDate := now.Format("20060102T150405Z")
+	scope := now.Format("20060102") + "/" + t.Region + "/s3/aws4_request"
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hexSHA256([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+t.SecretAccessKey), now.Format("20060102"))
+	key = hmacSHA256(key, t.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		t.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, s string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(s))
+	return h.Sum(nil)
+}
+
+func hexSHA256(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+// s3CanonicalQuery encodes query sorted by key as required for signing.
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var a []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			a = append(a, s3Escape(k, true)+"="+s3Escape(v, true))
+		}
+	}
+	return strings.Join(a, "&")
+}
+
+// s3Escape percent-encodes every byte of s except unreserved characters.
+// Slashes are only encoded if encodeSlash is set.
+func s3Escape(s string, encodeSlash bool) string {
+	var buf []byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !encodeSlash:
+			buf = append(buf, c)
+		default:
+			buf = append(buf, fmt.Sprintf("%%%02X", c)...)
+		}
+	}
+	return string(buf)
+}
@@ -0,0 +1,186 @@
+package snapshot_test
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdb/influxdb/snapshot"
+)
+
+// Ensure an S3 target can store, list and delete objects.
+func TestS3Target(t *testing.T) {
+	s := NewS3Server(t)
+	defer s.Close()
+
+	target := snapshot.NewS3Target("bucket", "backups/")
+	target.Endpoint = s.URL
+	target.AccessKeyID, target.SecretAccessKey = "key", "secret"
+	testTarget(t, target)
+
+	// Objects are stored under the prefix.
+	if _, ok := s.objects["backups/b"]; !ok {
+		t.Fatalf("unexpected objects: %v", s.objects)
+	}
+}
+
+// Ensure an S3 target uploads large objects in parts.
+func TestS3Target_Multipart(t *testing.T) {
+	s := NewS3Server(t)
+	defer s.Close()
+
+	target := snapshot.NewS3Target("bucket", "")
+	target.Endpoint = s.URL
+	target.AccessKeyID, target.SecretAccessKey = "key", "secret"
+	target.PartSize = 4
+
+	if err := target.Put("a", strings.NewReader("0123456789"), 10); err != nil {
+		t.Fatal(err)
+	} else if string(s.objects["a"]) != "0123456789" {
+		t.Fatalf("unexpected object: %q", s.objects["a"])
+	} else if s.partN != 3 {
+		t.Fatalf("unexpected part count: %d", s.partN)
+	} else if len(s.uploads) != 0 {
+		t.Fatalf("unexpected uploads: %v", s.uploads)
+	}
+
+	// A short body aborts the upload.
+	if err := target.Put("b", strings.NewReader("0123456"), 10); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := s.objects["b"]; ok {
+		t.Fatal("unexpected object")
+	} else if len(s.uploads) != 0 {
+		t.Fatalf("unexpected uploads: %v", s.uploads)
+	}
+}
+
+// Ensure an S3 target can be opened from a URL.
+func TestOpenTarget_S3(t *testing.T) {
+	target, err := snapshot.OpenTarget("s3://bucket/backups/?endpoint=http://127.0.0.1:9000&region=eu-west-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s3 := target.(*snapshot.S3Target)
+	if s3.Bucket != "bucket" || s3.Prefix != "backups/" || s3.Endpoint != "http://127.0.0.1:9000" || s3.Region != "eu-west-1" {
+		t.Fatalf("unexpected target: %#v", s3)
+	}
+}
+
+// S3Server is a fake, in-memory S3-compatible server.
+type S3Server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	objects map[string][]byte
+	uploads map[string]map[int][]byte // parts of multipart uploads by upload ID
+	partN   int                       // number of parts uploaded
+}
+
+// NewS3Server returns a new S3Server serving a single bucket named "bucket".
+// Requests must be signed with the access key "key".
+func NewS3Server(t *testing.T) *S3Server {
+	s := &S3Server{objects: make(map[string][]byte), uploads: make(map[string]map[int][]byte)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		} else if !strings.HasPrefix(r.URL.Path, "/bucket") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		key := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/bucket"), "/")
+		query := r.URL.Query()
+		uploadID := query.Get("uploadId")
+		switch {
+		case r.Method == "POST" && query["uploads"] != nil:
+			id := strconv.Itoa(len(s.uploads) + 1)
+			s.uploads[id] = make(map[int][]byte)
+			fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", id)
+		case r.Method == "PUT" && uploadID != "":
+			n, _ := strconv.Atoi(query.Get("partNumber"))
+			b, err := ioutil.ReadAll(r.Body)
+			if err != nil || int64(len(b)) != r.ContentLength {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			s.uploads[uploadID][n] = b
+			s.partN++
+			w.Header().Set("ETag", fmt.Sprintf(`"%d"`, n))
+		case r.Method == "POST" && uploadID != "":
+			var complete struct {
+				Parts []struct{ PartNumber int } `xml:"Part"`
+			}
+			xml.NewDecoder(r.Body).Decode(&complete)
+			var buf []byte
+			for _, p := range complete.Parts {
+				buf = append(buf, s.uploads[uploadID][p.PartNumber]...)
+			}
+			s.objects[key] = buf
+			delete(s.uploads, uploadID)
+			fmt.Fprint(w, "<CompleteMultipartUploadResult></CompleteMultipartUploadResult>")
+		case r.Method == "DELETE" && uploadID != "":
+			delete(s.uploads, uploadID)
+			w.WriteHeader(http.StatusNoContent)
+		case key == "" && r.Method == "GET":
+			s.list(w, r.URL.Query().Get("prefix"))
+		case r.Method == "PUT":
+			if r.ContentLength < 0 {
+				t.Errorf("content length required: %s", key)
+			}
+			b, _ := ioutil.ReadAll(r.Body)
+			s.objects[key] = b
+		case r.Method == "GET":
+			b, ok := s.objects[key]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(b)
+		case r.Method == "DELETE":
+			delete(s.objects, key)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	return s
+}
+
+// list writes a ListBucketResult with the objects under prefix.
+func (s *S3Server) list(w http.ResponseWriter, prefix string) {
+	type content struct {
+		Key          string
+		Size         int64
+		LastModified time.Time
+	}
+	var result struct {
+		XMLName  xml.Name `xml:"ListBucketResult"`
+		Contents []content
+	}
+
+	var keys []string
+	for k := range s.objects {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		result.Contents = append(result.Contents, content{Key: k, Size: int64(len(s.objects[k])), LastModified: time.Now().UTC()})
+	}
+
+	xml.NewEncoder(w).Encode(&result)
+}
@@ -2,10 +2,15 @@ package snapshot
 
 import (
 	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"hash"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"sort"
 	"time"
 )
@@ -16,11 +21,14 @@ const manifestName = "manifest"
 // Manifest represents a list of files in a snapshot.
 type Manifest struct {
 	Files []File `json:"files"`
+
+	// The time the snapshot was taken. Not set by older servers.
+	Timestamp time.Time `json:"timestamp,omitempty"`
 }
 
 // Diff returns a Manifest of files that are newer in m than other.
 func (m *Manifest) Diff(other *Manifest) *Manifest {
-	diff := &Manifest{}
+	diff := &Manifest{Timestamp: m.Timestamp}
 
 	// Find versions of files that are newer in m.
 loop:
@@ -50,7 +58,10 @@ loop:
 // Merge returns a Manifest that combines m with other.
 // Only the newest file between the two snapshots is returned.
 func (m *Manifest) Merge(other *Manifest) *Manifest {
-	ret := &Manifest{}
+	ret := &Manifest{Timestamp: m.Timestamp}
+	if other.Timestamp.After(ret.Timestamp) {
+		ret.Timestamp = other.Timestamp
+	}
 	ret.Files = make([]File, len(m.Files))
 	copy(ret.Files, m.Files)
 
@@ -80,11 +91,31 @@ loop:
 	return ret
 }
 
+// Time returns the time the snapshot was taken. Snapshots from servers that
+// don't record it use the latest modification time of any file instead.
+func (m *Manifest) Time() time.Time {
+	if !m.Timestamp.IsZero() {
+		return m.Timestamp
+	}
+
+	var t time.Time
+	for _, f := range m.Files {
+		if f.ModTime.After(t) {
+			t = f.ModTime
+		}
+	}
+	return t
+}
+
 // File represents a single file in a manifest.
 type File struct {
 	Name    string    `json:"name"`         // filename
 	Size    int64     `json:"size"`         // file size
 	ModTime time.Time `json:"lastModified"` // last modified time
+
+	// Hex-encoded SHA-256 digest of the file. Only set in the manifest
+	// trailing the files since it's computed as they're written.
+	Checksum string `json:"sha256,omitempty"`
 }
 
 // Files represents a sortable list of files.
@@ -95,16 +126,25 @@ func (p Files) Less(i, j int) bool { return p[i].Name < p[j].Name }
 func (p Files) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
 
 // Reader reads a snapshot from a Reader.
+// Files that are read completely are validated against their checksums once
+// the manifest trailing the files is read.
 // This type is not safe for concurrent use.
 type Reader struct {
 	tr       *tar.Reader
 	manifest *Manifest
+
+	curr *File     // current file
+	hash hash.Hash // digest of the current file so far
+	n    int64     // bytes read from the current file
+
+	sums map[string]string // checksums of files read completely
 }
 
 // NewReader returns a new Reader reading from r.
 func NewReader(r io.Reader) *Reader {
 	return &Reader{
-		tr: tar.NewReader(r),
+		tr:   tar.NewReader(r),
+		sums: make(map[string]string),
 	}
 }
 
@@ -149,15 +189,30 @@ func (sr *Reader) Next() (File, error) {
 		return File{}, err
 	}
 
+	// Record the checksum of the previous file if it was read completely.
+	if sr.curr != nil && sr.n == sr.curr.Size {
+		sr.sums[sr.curr.Name] = hex.EncodeToString(sr.hash.Sum(nil))
+	}
+	sr.curr, sr.hash, sr.n = nil, nil, 0
+
 	// Read next header.
 	hdr, err := sr.tr.Next()
 	if err != nil {
 		return File{}, err
 	}
 
+	// The manifest trailing the files holds their checksums.
+	if hdr.Name == manifestName {
+		if err := sr.readTrailer(); err != nil {
+			return File{}, err
+		}
+		return File{}, io.EOF
+	}
+
 	// Match header to file in snapshot.
 	for i := range sr.manifest.Files {
 		if sr.manifest.Files[i].Name == hdr.Name {
+			sr.curr, sr.hash = &sr.manifest.Files[i], sha256.New()
 			return sr.manifest.Files[i], nil
 		}
 	}
@@ -166,6 +221,63 @@ func (sr *Reader) Next() (File, error) {
 	return File{}, fmt.Errorf("snapshot entry not found in manifest: %s", hdr.Name)
 }
 
+// readTrailer reads the manifest trailing the files, sets the checksums on
+// the snapshot manifest and validates the files that were read completely.
+func (sr *Reader) readTrailer() error {
+	var trailer Manifest
+	if err := json.NewDecoder(sr.tr).Decode(&trailer); err != nil {
+		return fmt.Errorf("decode trailing manifest: %s", err)
+	} else if len(trailer.Files) != len(sr.manifest.Files) {
+		return fmt.Errorf("trailing manifest mismatch: files=%d, expected=%d", len(trailer.Files), len(sr.manifest.Files))
+	}
+
+	for i, f := range trailer.Files {
+		m := &sr.manifest.Files[i]
+		if f.Name != m.Name || f.Size != m.Size {
+			return fmt.Errorf("trailing manifest mismatch: file=%s", f.Name)
+		}
+		m.Checksum = f.Checksum
+
+		if sum, ok := sr.sums[f.Name]; ok && sum != f.Checksum {
+			return fmt.Errorf("checksum mismatch: file=%s, exp=%s, got=%s", f.Name, f.Checksum, sum)
+		}
+	}
+	return nil
+}
+
+// Verify reads the remaining files in the snapshot and validates them against
+// their checksums. Files without checksums, such as those in snapshots from
+// servers that don't record them, are left unverified. See Verified.
+func (sr *Reader) Verify() error {
+	for {
+		f, err := sr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		if _, err := io.CopyN(ioutil.Discard, sr, f.Size); err != nil {
+			return fmt.Errorf("read: file=%s, err=%s", f.Name, err)
+		}
+	}
+	return nil
+}
+
+// Verified returns true if every file in the snapshot has a checksum.
+// This is only known once the snapshot has been read completely.
+func (sr *Reader) Verified() bool {
+	if sr.manifest == nil {
+		return false
+	}
+	for _, f := range sr.manifest.Files {
+		if f.Checksum == "" {
+			return false
+		}
+	}
+	return true
+}
+
 // Read reads the current entry in the snapshot.
 func (sr *Reader) Read(b []byte) (n int, err error) {
 	// Read manifest if it hasn't been read yet.
@@ -174,7 +286,12 @@ func (sr *Reader) Read(b []byte) (n int, err error) {
 	}
 
 	// Pass read through to the tar reader.
-	return sr.tr.Read(b)
+	n, err = sr.tr.Read(b)
+	if sr.hash != nil {
+		sr.hash.Write(b[:n])
+		sr.n += int64(n)
+	}
+	return n, err
 }
 
 // MultiReader reads from a collection of snapshots.
@@ -187,6 +304,13 @@ type MultiReader struct {
 	manifest *Manifest // combined manifest from all readers
 	index    int       // index of file in snapshot to read
 	curr     *Reader   // current reader
+
+	// Snapshots taken after Until are ignored, if set.
+	Until time.Time
+
+	// Filter returns true if a file should be read.
+	// All files are read if it's nil.
+	Filter func(f File) bool
 }
 
 // NewMultiReader returns a new MultiReader reading from a list of readers.
@@ -210,13 +334,31 @@ func (ssr *MultiReader) Manifest() (*Manifest, error) {
 	}
 
 	// Build manifest from other readers.
+	// Readers of snapshots taken after the until time are dropped.
 	ss := &Manifest{}
+	readers := make([]*Reader, 0, len(ssr.readers))
 	for i, sr := range ssr.readers {
 		other, err := sr.Manifest()
 		if err != nil {
 			return nil, fmt.Errorf("manifest: idx=%d, err=%s", i, err)
+		} else if !ssr.Until.IsZero() && other.Time().After(ssr.Until) {
+			continue
 		}
 		ss = ss.Merge(other)
+		readers = append(readers, sr)
+	}
+	ssr.readers = readers
+	ssr.files = make([]*File, len(readers))
+
+	// Remove filtered files.
+	if ssr.Filter != nil {
+		files := ss.Files[:0]
+		for _, f := range ss.Files {
+			if ssr.Filter(f) {
+				files = append(files, f)
+			}
+		}
+		ss.Files = files
 	}
 
 	// Cache manifest and return.
@@ -237,15 +379,15 @@ func (ssr *MultiReader) Next() (File, error) {
 		return File{}, io.EOF
 	}
 
-	// Queue up next files.
-	if err := ssr.nextFiles(); err != nil {
-		return File{}, fmt.Errorf("next files: %s", err)
-	}
-
 	// Increment the file index.
 	ssr.index++
 	sf := ss.Files[ssr.index]
 
+	// Queue up next files.
+	if err := ssr.nextFiles(sf.Name); err != nil {
+		return File{}, fmt.Errorf("next files: %s", err)
+	}
+
 	// Find the matching reader. Clear other readers.
 	var sr *Reader
 	for i, f := range ssr.files {
@@ -273,15 +415,16 @@ func (ssr *MultiReader) Next() (File, error) {
 	return sf, nil
 }
 
-// nextFiles queues up a next file for all readers.
-func (ssr *MultiReader) nextFiles() error {
+// nextFiles queues up a next file for all readers. Files named before name
+// have been filtered out of the manifest and are skipped.
+func (ssr *MultiReader) nextFiles(name string) error {
 	for i, sr := range ssr.readers {
-		if ssr.files[i] == nil {
+		for ssr.files[i] == nil || ssr.files[i].Name < name {
 			// Read next file.
 			sf, err := sr.Next()
 			if err == io.EOF {
 				ssr.files[i] = nil
-				continue
+				break
 			} else if err != nil {
 				return fmt.Errorf("next: reader=%d, err=%s", i, err)
 			}
@@ -321,42 +464,89 @@ func (ssr *MultiReader) Read(b []byte) (n int, err error) {
 	return ssr.curr.Read(b)
 }
 
-// OpenFileMultiReader returns a MultiReader based on the path of the base snapshot.
-// Returns the underlying files which need to be closed separately.
-func OpenFileMultiReader(path string) (*MultiReader, []io.Closer, error) {
-	var readers []io.Reader
-	var closers []io.Closer
-	if err := func() error {
-		// Open original snapshot file.
-		f, err := os.Open(path)
-		if os.IsNotExist(err) {
-			return err
+// Verify validates every file in each of the underlying snapshots against
+// its checksum. Files can't be read from ssr afterwards.
+func (ssr *MultiReader) Verify() error {
+	for i, sr := range ssr.readers {
+		if err := sr.Verify(); err != nil {
+			return fmt.Errorf("snapshot %d: %s", i, err)
+		}
+	}
+	return nil
+}
+
+// Verified returns true if every file in each of the underlying snapshots
+// has a checksum.
+func (ssr *MultiReader) Verified() bool {
+	for _, sr := range ssr.readers {
+		if !sr.Verified() {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteTo writes the combined snapshot to w as a single archive.
+// This function will always return n == 0.
+func (ssr *MultiReader) WriteTo(w io.Writer) (n int64, err error) {
+	ss, err := ssr.Manifest()
+	if err != nil {
+		return 0, fmt.Errorf("manifest: %s", err)
+	}
+
+	// Begin writing a tar file to the output.
+	tw := tar.NewWriter(w)
+	defer tw.Close()
+
+	// Write manifest file.
+	if err := writeManifestTo(tw, ss); err != nil {
+		return 0, fmt.Errorf("write manifest: %s", err)
+	}
+
+	// Copy each file from the underlying snapshots.
+	trailer := &Manifest{Timestamp: ss.Timestamp}
+	for {
+		f, err := ssr.Next()
+		if err == io.EOF {
+			break
 		} else if err != nil {
-			return fmt.Errorf("open snapshot: %s", err)
+			return 0, fmt.Errorf("next: %s", err)
 		}
-		readers = append(readers, f)
-		closers = append(closers, f)
-
-		// Open all incremental snapshots.
-		for i := 0; ; i++ {
-			filename := path + fmt.Sprintf(".%d", i)
-			f, err := os.Open(filename)
-			if os.IsNotExist(err) {
-				break
-			} else if err != nil {
-				return fmt.Errorf("open incremental snapshot: file=%s, err=%s", filename, err)
-			}
-			readers = append(readers, f)
-			closers = append(closers, f)
+
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    f.Name,
+			Size:    f.Size,
+			Mode:    0666,
+			ModTime: time.Now(),
+		}); err != nil {
+			return 0, fmt.Errorf("write header: file=%s, err=%s", f.Name, err)
 		}
 
-		return nil
-	}(); err != nil {
-		closeAll(closers)
-		return nil, nil, err
+		h := sha256.New()
+		if _, err := io.CopyN(io.MultiWriter(tw, h), ssr, f.Size); err != nil {
+			return 0, fmt.Errorf("copy: file=%s, err=%s", f.Name, err)
+		}
+		f.Checksum = hex.EncodeToString(h.Sum(nil))
+		trailer.Files = append(trailer.Files, f)
+	}
+
+	// Write the manifest with checksums after the files.
+	if err := writeManifestTo(tw, trailer); err != nil {
+		return 0, fmt.Errorf("write trailing manifest: %s", err)
 	}
 
-	return NewMultiReader(readers...), nil, nil
+	// Close tar writer and check error.
+	if err := tw.Close(); err != nil {
+		return 0, fmt.Errorf("tar close: %s", err)
+	}
+
+	return 0, nil
+}
+
+// OpenFileMultiReader returns a MultiReader based on the path of the base snapshot.
+// Returns the underlying files which need to be closed separately.
+func OpenFileMultiReader(path string) (*MultiReader, []io.Closer, error) {
+	return OpenTargetMultiReader(NewDirTarget(filepath.Dir(path)), filepath.Base(path))
 }
 
 // ReadFileManifest returns a Manifest for a given base snapshot path.
@@ -446,17 +636,22 @@ func (sw *Writer) WriteTo(w io.Writer) (n int64, err error) {
 	defer tw.Close()
 
 	// Write manifest file.
-	if err := sw.writeManifestTo(tw); err != nil {
+	if err := writeManifestTo(tw, sw.Manifest); err != nil {
 		return 0, fmt.Errorf("write manifest: %s", err)
 	}
 
 	// Write each backup file.
-	for _, f := range sw.Manifest.Files {
-		if err := sw.writeFileTo(tw, &f); err != nil {
+	for i := range sw.Manifest.Files {
+		if err := sw.writeFileTo(tw, &sw.Manifest.Files[i]); err != nil {
 			return 0, fmt.Errorf("write file: %s", err)
 		}
 	}
 
+	// Write the manifest with checksums after the files.
+	if err := writeManifestTo(tw, sw.Manifest); err != nil {
+		return 0, fmt.Errorf("write trailing manifest: %s", err)
+	}
+
 	// Close tar writer and check error.
 	if err := tw.Close(); err != nil {
 		return 0, fmt.Errorf("tar close: %s", err)
@@ -466,9 +661,9 @@ func (sw *Writer) WriteTo(w io.Writer) (n int64, err error) {
 }
 
 // writeManifestTo writes a manifest to the archive.
-func (sw *Writer) writeManifestTo(tw *tar.Writer) error {
+func writeManifestTo(tw *tar.Writer, m *Manifest) error {
 	// Convert manifest to JSON.
-	b, err := json.Marshal(sw.Manifest)
+	b, err := json.Marshal(m)
 	if err != nil {
 		return fmt.Errorf("marshal json: %s", err)
 	}
@@ -489,7 +684,7 @@ func (sw *Writer) writeManifestTo(tw *tar.Writer) error {
 	return nil
 }
 
-// writeFileTo writes a single file to the archive.
+// writeFileTo writes a single file to the archive and sets its checksum.
 func (sw *Writer) writeFileTo(tw *tar.Writer, f *File) error {
 	// Retrieve the file writer by filename.
 	fw := sw.FileWriters[f.Name]
@@ -508,11 +703,13 @@ func (sw *Writer) writeFileTo(tw *tar.Writer, f *File) error {
 	}
 
 	// Copy the database to the writer.
-	if nn, err := fw.WriteTo(tw); err != nil {
+	h := sha256.New()
+	if nn, err := fw.WriteTo(io.MultiWriter(tw, h)); err != nil {
 		return fmt.Errorf("write: file=%s, err=%s", f.Name, err)
 	} else if nn != f.Size {
 		return fmt.Errorf("short write: file=%s", f.Name)
 	}
+	f.Checksum = hex.EncodeToString(h.Sum(nil))
 
 	// Close the writer.
 	if err := fw.Close(); err != nil {
@@ -1,10 +1,12 @@
 package snapshot_test
 
 import (
+	"archive/tar"
 	"bytes"
 	"io"
 	"io/ioutil"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -147,11 +149,14 @@ func TestWriter(t *testing.T) {
 	// Read snapshot from buffer.
 	sr := snapshot.NewReader(&buf)
 
-	// Read the manifest.
+	// Read the manifest. Checksums are only known after the files are read.
 	if ss, err := sr.Manifest(); err != nil {
 		t.Fatalf("unexpected error(manifest): %s", err)
-	} else if !reflect.DeepEqual(sw.Manifest, ss) {
-		t.Fatalf("manifest mismatch:\n\nexp=%#v\n\ngot=%#v", sw.Manifest, ss)
+	} else if !reflect.DeepEqual(ss, &snapshot.Manifest{Files: []snapshot.File{
+		{Name: "meta", Size: 3, ModTime: time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{Name: "shards/1", Size: 5, ModTime: time.Date(2000, time.February, 1, 0, 0, 0, 0, time.UTC)},
+	}}) {
+		t.Fatalf("manifest mismatch: %#v", ss)
 	}
 
 	// Next should be the meta file.
@@ -176,6 +181,52 @@ func TestWriter(t *testing.T) {
 	if _, err := sr.Next(); err != io.EOF {
 		t.Fatalf("expected EOF: %s", err)
 	}
+
+	// Checksums should be set from the trailing manifest.
+	if ss, err := sr.Manifest(); err != nil {
+		t.Fatal(err)
+	} else if ss.Files[0].Checksum != "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae" {
+		t.Fatalf("unexpected checksum: %s", ss.Files[0].Checksum)
+	} else if !reflect.DeepEqual(sw.Manifest, ss) {
+		t.Fatalf("manifest mismatch:\n\nexp=%#v\n\ngot=%#v", sw.Manifest, ss)
+	}
+}
+
+// Ensure a reader detects files that don't match their checksums.
+func TestReader_Verify(t *testing.T) {
+	buf := MustWriteSnapshot(map[string]string{"meta": "foo", "shards/1": "55555"}, time.Unix(0, 0))
+	sr := snapshot.NewReader(bytes.NewReader(buf.Bytes()))
+	if err := sr.Verify(); err != nil {
+		t.Fatal(err)
+	} else if !sr.Verified() {
+		t.Fatal("expected snapshot to be verified")
+	}
+
+	// Corrupt the shard data.
+	b := bytes.Replace(buf.Bytes(), []byte("55555"), []byte("55556"), 1)
+	if err := snapshot.NewReader(bytes.NewReader(b)).Verify(); err == nil || !strings.Contains(err.Error(), "checksum mismatch: file=shards/1") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure a reader reads a snapshot without checksums as unverified.
+func TestReader_Verify_NoChecksum(t *testing.T) {
+	// Write an archive with only the leading manifest.
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	b := []byte(`{"files":[{"name":"meta","size":3}]}`)
+	tw.WriteHeader(&tar.Header{Name: "manifest", Size: int64(len(b)), Mode: 0666})
+	tw.Write(b)
+	tw.WriteHeader(&tar.Header{Name: "meta", Size: 3, Mode: 0666})
+	tw.Write([]byte("foo"))
+	tw.Close()
+
+	sr := snapshot.NewReader(&buf)
+	if err := sr.Verify(); err != nil {
+		t.Fatal(err)
+	} else if sr.Verified() {
+		t.Fatal("expected snapshot to be unverified")
+	}
 }
 
 // Ensure a writer closes unused file writers.
@@ -271,6 +322,116 @@ func TestMultiReader(t *testing.T) {
 	}
 }
 
+// Ensure a MultiReader ignores snapshots taken after the until time.
+func TestMultiReader_Until(t *testing.T) {
+	bufs := []*bytes.Buffer{
+		MustWriteSnapshot(map[string]string{"meta": "foo", "shards/1": "11111"},
+			time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)),
+		MustWriteSnapshot(map[string]string{"meta": "bar", "shards/1": "22222", "shards/2": "33333"},
+			time.Date(2001, time.January, 1, 0, 0, 0, 0, time.UTC)),
+	}
+
+	ssr := snapshot.NewMultiReader(bufs[0], bufs[1])
+	ssr.Until = time.Date(2000, time.June, 1, 0, 0, 0, 0, time.UTC)
+
+	// Only the files in the first snapshot should be read.
+	for _, exp := range []struct{ name, data string }{{"meta", "foo"}, {"shards/1", "11111"}} {
+		if f, err := ssr.Next(); err != nil {
+			t.Fatalf("unexpected error(%s): %s", exp.name, err)
+		} else if f.Name != exp.name {
+			t.Fatalf("file mismatch(%s): %#v", exp.name, f)
+		} else if b := MustReadAll(ssr); string(b) != exp.data {
+			t.Fatalf("unexpected file(%s): %s", exp.name, b)
+		}
+	}
+	if _, err := ssr.Next(); err != io.EOF {
+		t.Fatalf("expected EOF: %s", err)
+	}
+}
+
+// Ensure the until time is compared with the time a snapshot was taken rather
+// than the modification times of its files.
+func TestMultiReader_Until_Timestamp(t *testing.T) {
+	sw := snapshot.NewWriter()
+	sw.Manifest.Timestamp = time.Date(2001, time.January, 1, 0, 0, 0, 0, time.UTC)
+	sw.Manifest.Files = []snapshot.File{{Name: "meta", Size: 3, ModTime: time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)}}
+	sw.FileWriters["meta"] = &bufCloser{Buffer: *bytes.NewBufferString("bar")}
+	var buf bytes.Buffer
+	if _, err := sw.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	bufs := []*bytes.Buffer{
+		MustWriteSnapshot(map[string]string{"meta": "foo"}, time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)),
+		&buf,
+	}
+	ssr := snapshot.NewMultiReader(bufs[0], bufs[1])
+	ssr.Until = time.Date(2000, time.June, 1, 0, 0, 0, 0, time.UTC)
+
+	if f, err := ssr.Next(); err != nil {
+		t.Fatal(err)
+	} else if f.Name != "meta" {
+		t.Fatalf("file mismatch: %#v", f)
+	} else if b := MustReadAll(ssr); string(b) != "foo" {
+		t.Fatalf("unexpected file: %s", b)
+	}
+}
+
+// Ensure a MultiReader skips files that don't match its filter.
+func TestMultiReader_Filter(t *testing.T) {
+	bufs := []*bytes.Buffer{
+		MustWriteSnapshot(map[string]string{"db0/rp0/1": "11111", "db1/rp0/2": "22222", "meta": "foo"},
+			time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)),
+		MustWriteSnapshot(map[string]string{"db0/rp0/3": "33333", "db1/rp0/2": "44444", "meta": "bar"},
+			time.Date(2001, time.January, 1, 0, 0, 0, 0, time.UTC)),
+	}
+
+	ssr := snapshot.NewMultiReader(bufs[0], bufs[1])
+	ssr.Filter = func(f snapshot.File) bool { return !strings.HasPrefix(f.Name, "db1/") }
+
+	for _, exp := range []struct{ name, data string }{{"db0/rp0/1", "11111"}, {"db0/rp0/3", "33333"}, {"meta", "bar"}} {
+		if f, err := ssr.Next(); err != nil {
+			t.Fatalf("unexpected error(%s): %s", exp.name, err)
+		} else if f.Name != exp.name {
+			t.Fatalf("file mismatch(%s): %#v", exp.name, f)
+		} else if b := MustReadAll(ssr); string(b) != exp.data {
+			t.Fatalf("unexpected file(%s): %s", exp.name, b)
+		}
+	}
+	if _, err := ssr.Next(); err != io.EOF {
+		t.Fatalf("expected EOF: %s", err)
+	}
+}
+
+// Ensure a MultiReader can write its combined snapshot as a single archive.
+func TestMultiReader_WriteTo(t *testing.T) {
+	bufs := []*bytes.Buffer{
+		MustWriteSnapshot(map[string]string{"meta": "foo", "shards/1": "11111"},
+			time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)),
+		MustWriteSnapshot(map[string]string{"meta": "bar", "shards/2": "22222"},
+			time.Date(2001, time.January, 1, 0, 0, 0, 0, time.UTC)),
+	}
+
+	var buf bytes.Buffer
+	if _, err := snapshot.NewMultiReader(bufs[0], bufs[1]).WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	sr := snapshot.NewReader(&buf)
+	for _, exp := range []struct{ name, data string }{{"meta", "bar"}, {"shards/1", "11111"}, {"shards/2", "22222"}} {
+		if f, err := sr.Next(); err != nil {
+			t.Fatalf("unexpected error(%s): %s", exp.name, err)
+		} else if f.Name != exp.name {
+			t.Fatalf("file mismatch(%s): %#v", exp.name, f)
+		} else if b := MustReadAll(sr); string(b) != exp.data {
+			t.Fatalf("unexpected file(%s): %s", exp.name, b)
+		}
+	}
+	if _, err := sr.Next(); err != io.EOF {
+		t.Fatalf("expected EOF: %s", err)
+	}
+}
+
 // bufCloser adds a Close() method to a bytes.Buffer
 type bufCloser struct {
 	bytes.Buffer
@@ -291,3 +452,18 @@ func MustReadAll(r io.Reader) []byte {
 	}
 	return b
 }
+
+// MustWriteSnapshot writes a snapshot of files by name, all modified at t. Panic on error.
+func MustWriteSnapshot(files map[string]string, t time.Time) *bytes.Buffer {
+	sw := snapshot.NewWriter()
+	for name, data := range files {
+		sw.Manifest.Files = append(sw.Manifest.Files, snapshot.File{Name: name, Size: int64(len(data)), ModTime: t})
+		sw.FileWriters[name] = &bufCloser{Buffer: *bytes.NewBufferString(data)}
+	}
+
+	var buf bytes.Buffer
+	if _, err := sw.WriteTo(&buf); err != nil {
+		panic(err.Error())
+	}
+	return &buf
+}
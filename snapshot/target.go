@@ -0,0 +1,392 @@
+package snapshot
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// checksumSuffix is the suffix of the object holding a chain's checksums.
+const checksumSuffix = ".checksums"
+
+// pendingSuffix is a suffix added to a file while it's being written.
+const pendingSuffix = ".pending"
+
+// Target represents a storage location for backup chains.
+//
+// A chain is a base snapshot stored under its name and incremental snapshots
+// stored under the name suffixed with ".0", ".1", etc.
+type Target interface {
+	// Put stores size bytes from r under name, replacing any existing object.
+	Put(name string, r io.Reader, size int64) error
+
+	// Get returns a reader for the object stored under name. Returns an
+	// error for which os.IsNotExist is true if the object doesn't exist.
+	Get(name string) (io.ReadCloser, error)
+
+	// List returns all objects in the target sorted by name.
+	List() ([]File, error)
+
+	// Delete removes the object stored under name.
+	Delete(name string) error
+}
+
+// OpenTarget returns a Target for s. An "s3://bucket/prefix" URL opens a
+// bucket on an S3-compatible server; the endpoint and region can be set with
+// the "endpoint" and "region" query parameters and credentials are read from
+// the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables.
+// Anything else is used as a local directory path.
+func OpenTarget(s string) (Target, error) {
+	if !strings.HasPrefix(s, "s3://") {
+		return NewDirTarget(s), nil
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("parse target: %s", err)
+	} else if u.Host == "" {
+		return nil, fmt.Errorf("bucket required: %s", s)
+	}
+
+	t := NewS3Target(u.Host, strings.TrimPrefix(u.Path, "/"))
+	if v := u.Query().Get("endpoint"); v != "" {
+		t.Endpoint = v
+	}
+	if v := u.Query().Get("region"); v != "" {
+		t.Region = v
+	}
+	t.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+	t.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	return t, nil
+}
+
+// OpenPathTarget returns the target and chain name for a backup path. If
+// target is blank then path is a local file and its directory is used.
+func OpenPathTarget(target, path string) (Target, string, error) {
+	if target == "" {
+		return NewDirTarget(filepath.Dir(path)), filepath.Base(path), nil
+	}
+
+	t, err := OpenTarget(target)
+	if err != nil {
+		return nil, "", err
+	}
+	return t, path, nil
+}
+
+// DirTarget stores backup chains as files in a local directory.
+type DirTarget struct {
+	Path string
+}
+
+// NewDirTarget returns a new instance of DirTarget.
+func NewDirTarget(path string) *DirTarget {
+	return &DirTarget{Path: path}
+}
+
+// Put writes r to a pending file and renames it to name once it's complete.
+func (t *DirTarget) Put(name string, r io.Reader, size int64) error {
+	if err := os.MkdirAll(t.Path, 0777); err != nil {
+		return err
+	}
+
+	path := filepath.Join(t.Path, name)
+	f, err := os.Create(path + pendingSuffix)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path + pendingSuffix)
+	defer f.Close()
+
+	if n, err := io.Copy(f, r); err != nil {
+		return err
+	} else if n != size {
+		return fmt.Errorf("short write: name=%s, n=%d, size=%d", name, n, size)
+	} else if err := f.Sync(); err != nil {
+		return err
+	} else if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(path+pendingSuffix, path)
+}
+
+// Get opens the file for name.
+func (t *DirTarget) Get(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(t.Path, name))
+}
+
+// List returns the files in the directory, ignoring pending files.
+func (t *DirTarget) List() ([]File, error) {
+	fis, err := ioutil.ReadDir(t.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var a []File
+	for _, fi := range fis {
+		if fi.IsDir() || strings.HasSuffix(fi.Name(), pendingSuffix) {
+			continue
+		}
+		a = append(a, File{Name: fi.Name(), Size: fi.Size(), ModTime: fi.ModTime()})
+	}
+	return a, nil
+}
+
+// Delete removes the file for name.
+func (t *DirTarget) Delete(name string) error {
+	return os.Remove(filepath.Join(t.Path, name))
+}
+
+// SpoolFile creates a file a snapshot can be written to before it's stored in
+// t under name. The file is created next to the chain for a DirTarget so it's
+// on the same disk, and in the system temp directory otherwise. The caller
+// must remove the file.
+func SpoolFile(t Target, name string) (*os.File, error) {
+	dt, ok := t.(*DirTarget)
+	if !ok {
+		return ioutil.TempFile("", "influxdb-backup")
+	}
+
+	if err := os.MkdirAll(dt.Path, 0777); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(filepath.Join(dt.Path, name+".spool"+pendingSuffix), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// OpenTargetMultiReader returns a MultiReader for the chain stored under name.
+// Returns the underlying readers which need to be closed separately.
+func OpenTargetMultiReader(t Target, name string) (*MultiReader, []io.Closer, error) {
+	var readers []io.Reader
+	var closers []io.Closer
+	for i := -1; ; i++ {
+		rc, err := t.Get(chainMemberName(name, i))
+		if os.IsNotExist(err) && i >= 0 {
+			break
+		} else if err != nil {
+			closeAll(closers)
+			return nil, nil, err
+		}
+		readers = append(readers, rc)
+		closers = append(closers, rc)
+	}
+	return NewMultiReader(readers...), closers, nil
+}
+
+// ReadTargetManifest returns the merged Manifest of the chain stored under name.
+func ReadTargetManifest(t Target, name string) (*Manifest, error) {
+	ssr, closers, err := OpenTargetMultiReader(t, name)
+	if err != nil {
+		return nil, err
+	}
+	defer closeAll(closers)
+
+	ss, err := ssr.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("manifest: %s", err)
+	}
+	return ss, nil
+}
+
+// NextName returns the name of the next snapshot in the chain stored under
+// name. This is name if the chain doesn't exist yet.
+func NextName(t Target, name string) (string, error) {
+	files, err := t.List()
+	if err != nil {
+		return "", err
+	}
+
+	names := make(map[string]bool)
+	for _, f := range files {
+		names[f.Name] = true
+	}
+
+	for i := -1; ; i++ {
+		if s := chainMemberName(name, i); !names[s] {
+			return s, nil
+		}
+	}
+}
+
+// Put stores a snapshot of the chain stored under base as name and records
+// its SHA-256 checksum in the chain's checksums.
+func Put(t Target, base, name string, r io.Reader, size int64) error {
+	h := sha256.New()
+	if err := t.Put(name, io.TeeReader(r, h), size); err != nil {
+		return fmt.Errorf("put: %s", err)
+	}
+
+	sums, err := readChecksums(t, base)
+	if os.IsNotExist(err) {
+		sums = make(map[string]string)
+	} else if err != nil {
+		return fmt.Errorf("read checksums: %s", err)
+	}
+	sums[name] = hex.EncodeToString(h.Sum(nil))
+
+	buf, err := json.Marshal(sums)
+	if err != nil {
+		return fmt.Errorf("marshal checksums: %s", err)
+	}
+	if err := t.Put(base+checksumSuffix, bytes.NewReader(buf), int64(len(buf))); err != nil {
+		return fmt.Errorf("put checksums: %s", err)
+	}
+	return nil
+}
+
+// Verify checks every snapshot in the chain stored under name against its
+// recorded checksum. Returns an error for which os.IsNotExist is true if the
+// chain has no checksums.
+func Verify(t Target, name string) error {
+	sums, err := readChecksums(t, name)
+	if err != nil {
+		return err
+	}
+
+	for i := -1; ; i++ {
+		member := chainMemberName(name, i)
+		exp, ok := sums[member]
+		if !ok {
+			// The chain ends at the first snapshot without a checksum unless
+			// the snapshot exists and is missing its checksum.
+			rc, err := t.Get(member)
+			if os.IsNotExist(err) && i >= 0 {
+				return nil
+			} else if err != nil {
+				return fmt.Errorf("get: name=%s, err=%s", member, err)
+			}
+			rc.Close()
+			return fmt.Errorf("checksum not found: %s", member)
+		}
+
+		rc, err := t.Get(member)
+		if err != nil {
+			return fmt.Errorf("get: name=%s, err=%s", member, err)
+		}
+		h := sha256.New()
+		_, err = io.Copy(h, rc)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("read: name=%s, err=%s", member, err)
+		} else if got := hex.EncodeToString(h.Sum(nil)); got != exp {
+			return fmt.Errorf("checksum mismatch: name=%s, exp=%s, got=%s", member, exp, got)
+		}
+	}
+}
+
+// Retain deletes all but the n most recently updated chains in the target.
+// Only chains with checksums are considered so unrelated objects are kept.
+func Retain(t Target, n int) error {
+	files, err := t.List()
+	if err != nil {
+		return fmt.Errorf("list: %s", err)
+	}
+
+	// Group objects by chain and find when each chain was last updated.
+	m := make(map[string]*chain)
+	for _, f := range files {
+		name := chainNameOf(f.Name)
+		c := m[name]
+		if c == nil {
+			c = &chain{name: name}
+			m[name] = c
+		}
+		c.files = append(c.files, f.Name)
+		if f.ModTime.After(c.modTime) {
+			c.modTime = f.ModTime
+		}
+	}
+
+	var a chains
+	for name, c := range m {
+		for _, f := range c.files {
+			if f == name+checksumSuffix {
+				a = append(a, c)
+				break
+			}
+		}
+	}
+	if len(a) <= n {
+		return nil
+	}
+
+	// Delete the oldest chains, removing the checksums last.
+	sort.Sort(sort.Reverse(a))
+	for _, c := range a[n:] {
+		sort.Strings(c.files)
+		for _, f := range c.files {
+			if f == c.name+checksumSuffix {
+				continue
+			} else if err := t.Delete(f); err != nil {
+				return fmt.Errorf("delete: name=%s, err=%s", f, err)
+			}
+		}
+		if err := t.Delete(c.name + checksumSuffix); err != nil {
+			return fmt.Errorf("delete: name=%s, err=%s", c.name+checksumSuffix, err)
+		}
+	}
+	return nil
+}
+
+// chain represents the objects of a backup chain in a target.
+type chain struct {
+	name    string
+	modTime time.Time // last update of any object in the chain
+	files   []string
+}
+
+// chains represents a list of chains sortable by last update.
+type chains []*chain
+
+func (a chains) Len() int           { return len(a) }
+func (a chains) Less(i, j int) bool { return a[i].modTime.Before(a[j].modTime) }
+func (a chains) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+
+// readChecksums returns the checksums of the chain stored under name.
+func readChecksums(t Target, name string) (map[string]string, error) {
+	rc, err := t.Get(name + checksumSuffix)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var sums map[string]string
+	if err := json.NewDecoder(rc).Decode(&sums); err != nil {
+		return nil, fmt.Errorf("decode checksums: %s", err)
+	}
+	return sums, nil
+}
+
+// chainMemberName returns the name of the i-th incremental snapshot in a
+// chain, or the base snapshot if i is negative.
+func chainMemberName(name string, i int) string {
+	if i < 0 {
+		return name
+	}
+	return name + "." + strconv.Itoa(i)
+}
+
+// chainNameOf returns the name of the chain an object belongs to.
+func chainNameOf(name string) string {
+	if strings.HasSuffix(name, checksumSuffix) {
+		return strings.TrimSuffix(name, checksumSuffix)
+	} else if i := strings.LastIndex(name, "."); i != -1 {
+		if _, err := strconv.Atoi(name[i+1:]); err == nil {
+			return name[:i]
+		}
+	}
+	return name
+}
@@ -0,0 +1,154 @@
+package snapshot_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdb/influxdb/snapshot"
+)
+
+// Ensure a directory target can store, list and delete objects.
+func TestDirTarget(t *testing.T) {
+	path := MustTempDir()
+	defer os.RemoveAll(path)
+
+	testTarget(t, snapshot.NewDirTarget(path))
+}
+
+// Ensure backup chains can be stored in a target and verified.
+func TestTarget_Chain(t *testing.T) {
+	path := MustTempDir()
+	defer os.RemoveAll(path)
+	target := snapshot.NewDirTarget(path)
+
+	// Store a base snapshot and an incremental snapshot.
+	for i, buf := range []*bytes.Buffer{
+		MustWriteSnapshot(map[string]string{"meta": "foo", "shards/1": "11111"}, time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)),
+		MustWriteSnapshot(map[string]string{"meta": "bar", "shards/2": "22222"}, time.Date(2001, time.January, 1, 0, 0, 0, 0, time.UTC)),
+	} {
+		name, err := snapshot.NextName(target, "backup")
+		if err != nil {
+			t.Fatal(err)
+		} else if exp := []string{"backup", "backup.0"}[i]; name != exp {
+			t.Fatalf("unexpected name(%d): %s", i, name)
+		}
+		if err := snapshot.Put(target, "backup", name, buf, int64(buf.Len())); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Verify the merged manifest and checksums.
+	if m, err := snapshot.ReadTargetManifest(target, "backup"); err != nil {
+		t.Fatal(err)
+	} else if len(m.Files) != 3 {
+		t.Fatalf("unexpected manifest: %#v", m)
+	}
+	if err := snapshot.Verify(target, "backup"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Corrupt the incremental snapshot.
+	if err := ioutil.WriteFile(path+"/backup.0", []byte("corrupt"), 0666); err != nil {
+		t.Fatal(err)
+	} else if err := snapshot.Verify(target, "backup"); err == nil || !strings.Contains(err.Error(), "checksum mismatch: name=backup.0") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A chain without checksums can't be verified.
+	if err := snapshot.Verify(target, "other"); !os.IsNotExist(err) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure only the most recently updated chains are retained.
+func TestRetain(t *testing.T) {
+	path := MustTempDir()
+	defer os.RemoveAll(path)
+	target := snapshot.NewDirTarget(path)
+
+	for i, name := range []string{"a", "a.0", "b", "c", "c.0"} {
+		base := strings.Split(name, ".")[0]
+		if err := snapshot.Put(target, base, name, strings.NewReader("data"), 4); err != nil {
+			t.Fatal(err)
+		}
+
+		// Order chains by their last update.
+		mtime := time.Unix(int64(i), 0)
+		os.Chtimes(path+"/"+name, mtime, mtime)
+		os.Chtimes(path+"/"+base+".checksums", mtime, mtime)
+	}
+
+	// Unrelated files aren't removed.
+	if err := target.Put("unrelated", strings.NewReader("x"), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := snapshot.Retain(target, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := target.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	if exp := []string{"b", "b.checksums", "c", "c.0", "c.checksums", "unrelated"}; !reflect.DeepEqual(names, exp) {
+		t.Fatalf("unexpected files: %v", names)
+	}
+}
+
+// testTarget runs the common operations against target, which must be empty.
+func testTarget(t *testing.T, target snapshot.Target) {
+	if err := target.Put("b", strings.NewReader("bar"), 3); err != nil {
+		t.Fatal(err)
+	} else if err := target.Put("a", strings.NewReader("foo"), 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// Read an object back.
+	if rc, err := target.Get("a"); err != nil {
+		t.Fatal(err)
+	} else if b := MustReadAll(rc); string(b) != "foo" {
+		t.Fatalf("unexpected data: %s", b)
+	} else {
+		rc.Close()
+	}
+
+	// Missing objects don't exist.
+	if _, err := target.Get("c"); !os.IsNotExist(err) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// List objects sorted by name.
+	if files, err := target.List(); err != nil {
+		t.Fatal(err)
+	} else if len(files) != 2 || files[0].Name != "a" || files[1].Name != "b" || files[1].Size != 3 {
+		t.Fatalf("unexpected files: %#v", files)
+	}
+
+	// Delete an object.
+	if err := target.Delete("a"); err != nil {
+		t.Fatal(err)
+	} else if files, err := target.List(); err != nil {
+		t.Fatal(err)
+	} else if len(files) != 1 || files[0].Name != "b" {
+		t.Fatalf("unexpected files: %#v", files)
+	}
+}
+
+// MustTempDir returns a new temporary directory. Panic on error.
+func MustTempDir() string {
+	path, err := ioutil.TempDir("", "snapshot-")
+	if err != nil {
+		panic(err)
+	}
+	return path
+}
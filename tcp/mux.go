@@ -1,6 +1,7 @@
 package tcp
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
@@ -26,6 +27,9 @@ type Mux struct {
 	// The amount of time to wait for the first header byte.
 	Timeout time.Duration
 
+	// If set, connections are served over TLS with this configuration.
+	TLSConfig *tls.Config
+
 	// Out-of-band error logger
 	Logger *log.Logger
 }
@@ -68,6 +72,12 @@ func (mux *Mux) Serve(ln net.Listener) error {
 
 func (mux *Mux) handleConn(conn net.Conn) {
 	defer mux.wg.Done()
+
+	// Wrap the connection so the handshake happens before the header is read.
+	if mux.TLSConfig != nil {
+		conn = tls.Server(conn, mux.TLSConfig)
+	}
+
 	// Set a read deadline so connections with no data don't timeout.
 	if err := conn.SetReadDeadline(time.Now().Add(mux.Timeout)); err != nil {
 		conn.Close()
@@ -141,12 +151,24 @@ func (ln *listener) Addr() net.Addr { return nil }
 
 // Dial connects to a remote mux listener with a given header byte.
 func Dial(network, address string, header byte) (net.Conn, error) {
-	conn, err := net.Dial(network, address)
+	return DialTLS(network, address, header, 0, nil)
+}
+
+// DialTLS connects to a remote mux listener with a given header byte. The
+// connection is made over TLS if config is not nil. A zero timeout means no
+// timeout.
+func DialTLS(network, address string, header byte, timeout time.Duration, config *tls.Config) (net.Conn, error) {
+	conn, err := net.DialTimeout(network, address, timeout)
 	if err != nil {
 		return nil, err
 	}
 
+	if config != nil {
+		conn = Client(conn, address, config)
+	}
+
 	if _, err := conn.Write([]byte{header}); err != nil {
+		conn.Close()
 		return nil, fmt.Errorf("write mux header: %s", err)
 	}
 
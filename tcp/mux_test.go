@@ -2,10 +2,18 @@ package tcp_test
 
 import (
 	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"io"
 	"io/ioutil"
 	"log"
+	"math/big"
 	"net"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
@@ -135,3 +143,119 @@ func TestMux_Listen_ErrAlreadyRegistered(t *testing.T) {
 	mux.Listen(5)
 	mux.Listen(5)
 }
+
+// Ensure the muxer serves TLS connections and requires client certificates.
+func TestMux_TLS(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "tcp-")
+	defer os.RemoveAll(dir)
+
+	certFile := MustWriteCertificate(dir)
+	config, err := tcp.LoadTLSConfig(certFile, "", certFile, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcpListener.Close()
+
+	mux := tcp.NewMux()
+	mux.Timeout = 200 * time.Millisecond
+	mux.TLSConfig = config
+	mux.Logger = log.New(ioutil.Discard, "", 0)
+	ln := mux.Listen(5)
+	go mux.Serve(tcpListener)
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("OK"))
+			conn.Close()
+		}
+	}()
+
+	// A client with a certificate is accepted.
+	conn, err := tcp.DialTLS("tcp", tcpListener.Addr().String(), 5, time.Second, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var resp [2]byte
+	if _, err := io.ReadFull(conn, resp[:]); err != nil {
+		t.Fatal(err)
+	} else if string(resp[:]) != "OK" {
+		t.Fatalf("unexpected response: %s", resp[:])
+	}
+
+	// A tool's client configuration is accepted with a certificate and
+	// rejected without one.
+	if config, err := tcp.LoadClientTLSConfig(certFile, certFile); err != nil {
+		t.Fatal(err)
+	} else if conn, err := tcp.DialTLS("tcp", tcpListener.Addr().String(), 5, time.Second, config); err != nil {
+		t.Fatal(err)
+	} else if _, err := io.ReadFull(conn, resp[:]); err != nil {
+		t.Fatal(err)
+	} else {
+		conn.Close()
+	}
+	if config, err := tcp.LoadClientTLSConfig("", certFile); err != nil {
+		t.Fatal(err)
+	} else if conn, err := tcp.DialTLS("tcp", tcpListener.Addr().String(), 5, time.Second, config); err != nil {
+		t.Fatal(err)
+	} else if _, err := io.ReadFull(conn, resp[:]); err == nil {
+		t.Fatal("expected error")
+	} else {
+		conn.Close()
+	}
+
+	// A plaintext client is rejected.
+	conn, err = tcp.Dial("tcp", tcpListener.Addr().String(), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := io.ReadFull(conn, resp[:]); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+// MustWriteCertificate writes a self-signed certificate and key for
+// 127.0.0.1 to a single file in dir and returns its path.
+func MustWriteCertificate(dir string) string {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		panic(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "127.0.0.1"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		panic(err)
+	}
+
+	var buf bytes.Buffer
+	pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: der})
+	pem.Encode(&buf, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	path := filepath.Join(dir, "cert.pem")
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		panic(err)
+	}
+	return path
+}
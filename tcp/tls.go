@@ -0,0 +1,94 @@
+package tcp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+)
+
+// LoadTLSConfig returns a TLS configuration for both ends of intra-cluster
+// connections. The certificate is presented by servers and, when requested,
+// by clients. If caFile is set then peers are verified against it instead of
+// the system roots. If verifyClient is true then servers require clients to
+// present a certificate signed by the CA.
+func LoadTLSConfig(certFile, keyFile, caFile string, verifyClient bool) (*tls.Config, error) {
+	if keyFile == "" {
+		keyFile = certFile
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load certificate: %s", err)
+	}
+
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if caFile != "" {
+		pool, err := loadCertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+		config.ClientCAs = pool
+	}
+
+	if verifyClient {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// LoadClientTLSConfig returns a TLS configuration for tools connecting to a
+// node. The certificate, which may also contain the private key, is only
+// needed if nodes verify clients. If caFile is set then the node is verified
+// against it instead of the system roots.
+func LoadClientTLSConfig(certFile, caFile string) (*tls.Config, error) {
+	config := &tls.Config{}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, certFile)
+		if err != nil {
+			return nil, fmt.Errorf("load certificate: %s", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	if caFile != "" {
+		pool, err := loadCertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
+
+// loadCertPool returns a pool of the certificates in a PEM file.
+func loadCertPool(path string) (*x509.CertPool, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read ca certificate: %s", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(buf) {
+		return nil, errors.New("no certificates found in ca certificate")
+	}
+	return pool, nil
+}
+
+// Client returns a TLS client connection over conn to address. The server's
+// certificate is verified against the host in address.
+func Client(conn net.Conn, address string, config *tls.Config) *tls.Conn {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+
+	return tls.Client(conn, &tls.Config{
+		Certificates:       config.Certificates,
+		RootCAs:            config.RootCAs,
+		ServerName:         host,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	})
+}
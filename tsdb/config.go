@@ -1,6 +1,7 @@
 package tsdb
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/influxdb/influxdb/toml"
@@ -43,12 +44,36 @@ const (
 	// This number multiplied by the parition count is roughly the max possible memory
 	// size for the in-memory WAL cache.
 	DefaultPartitionSizeThreshold = 20 * 1024 * 1024 // 20MB
+
+	// DefaultWALFsync is the default durability mode for WAL segment writes.
+	DefaultWALFsync = WALFsyncAlways
+
+	// DefaultWALFsyncInterval is how often the WAL syncs segment files to disk
+	// when the "interval" fsync mode is used.
+	DefaultWALFsyncInterval = 100 * time.Millisecond
+)
+
+// WAL fsync modes.
+const (
+	// WALFsyncAlways syncs every write to disk before it is acknowledged.
+	WALFsyncAlways = "always"
+
+	// WALFsyncInterval groups writes together and syncs them to disk every
+	// wal-fsync-interval. Writes are acknowledged once the sync covering them completes.
+	WALFsyncInterval = "interval"
+
+	// WALFsyncNone never explicitly syncs writes and leaves it to the operating system.
+	WALFsyncNone = "none"
 )
 
 type Config struct {
 	Dir    string `toml:"dir"`
 	Engine string `toml:"engine"`
 
+	// ColdDir is the secondary data directory that older shards are moved to by the
+	// tiering service. Shards already in it are loaded on startup.
+	ColdDir string `toml:"cold-dir"`
+
 	// WAL config options for b1 (introduced in 0.9.2)
 	MaxWALSize             int           `toml:"max-wal-size"`
 	WALFlushInterval       toml.Duration `toml:"wal-flush-interval"`
@@ -62,6 +87,20 @@ type Config struct {
 	WALMaxSeriesSize          int           `toml:"wal-max-series-size"`
 	WALFlushColdInterval      toml.Duration `toml:"wal-flush-cold-interval"`
 	WALPartitionSizeThreshold uint64        `toml:"wal-partition-size-threshold"`
+	WALFsync                  string        `toml:"wal-fsync"`
+	WALFsyncInterval          toml.Duration `toml:"wal-fsync-interval"`
+
+	// CompactInterval is how often bz1 shards rewrite fragmented series and
+	// reclaim free pages into a fresh file. Zero disables online compactions.
+	CompactInterval toml.Duration `toml:"compact-interval"`
+
+	// LazyLoadShards defers opening shards found on startup until they're first
+	// read or written.
+	LazyLoadShards bool `toml:"lazy-load-shards"`
+
+	// ShardIdleTimeout closes shards that haven't been read or written for this
+	// long. They're reopened on their next access. Zero keeps shards open.
+	ShardIdleTimeout toml.Duration `toml:"shard-idle-timeout"`
 
 	// Query logging
 	QueryLogEnabled bool `toml:"query-log-enabled"`
@@ -80,7 +119,29 @@ func NewConfig() Config {
 		WALMaxSeriesSize:          DefaultMaxSeriesSize,
 		WALFlushColdInterval:      toml.Duration(DefaultFlushColdInterval),
 		WALPartitionSizeThreshold: DefaultPartitionSizeThreshold,
+		WALFsync:                  DefaultWALFsync,
+		WALFsyncInterval:          toml.Duration(DefaultWALFsyncInterval),
 
 		QueryLogEnabled: true,
 	}
 }
+
+// Validate returns an error if the config is invalid.
+func (c *Config) Validate() error {
+	switch c.WALFsync {
+	case "", WALFsyncAlways, WALFsyncNone:
+	case WALFsyncInterval:
+		if c.WALFsyncInterval <= 0 {
+			return fmt.Errorf("wal-fsync-interval must be greater than 0")
+		}
+	default:
+		return fmt.Errorf("unknown wal-fsync mode: %q", c.WALFsync)
+	}
+	if c.CompactInterval < 0 {
+		return fmt.Errorf("compact-interval must not be negative")
+	}
+	if c.ShardIdleTimeout < 0 {
+		return fmt.Errorf("shard-idle-timeout must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+package tsdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"sort"
+	"time"
+
+	"github.com/influxdb/influxdb/models"
+)
+
+// MaxDigestDepth is the deepest digest tree that can be computed.
+const MaxDigestDepth = 16
+
+// ErrInvalidDigestDepth is returned when a digest deeper than MaxDigestDepth
+// is requested.
+var ErrInvalidDigestDepth = errors.New("invalid digest depth")
+
+// SeriesDigest is a Merkle tree over the points of a series. The time range
+// being digested is split into 2^depth equal ranges, each leaf hashes the
+// points in its range and each inner node hashes its two children. Ranges
+// without points hash to zero.
+type SeriesDigest struct {
+	Key string
+
+	// Hashes holds the nodes of the tree in level order so the root is the
+	// first element and the leaves are the last 2^depth elements.
+	Hashes []uint64
+}
+
+// NewSeriesDigest returns the digest of a series with 2^depth leaves over the
+// time range [min, max). points must be sorted by time and within the range.
+func NewSeriesDigest(key string, points []models.Point, min, max int64, depth int) *SeriesDigest {
+	d := &SeriesDigest{
+		Key:    key,
+		Hashes: make([]uint64, 1<<uint(depth+1)-1),
+	}
+	d.sum(points, min, max, depth)
+	return d
+}
+
+// Root returns the hash of all points in the series.
+func (d *SeriesDigest) Root() uint64 {
+	if d == nil || len(d.Hashes) == 0 {
+		return 0
+	}
+	return d.Hashes[0]
+}
+
+// Leaves returns the number of time ranges in the digest.
+func (d *SeriesDigest) Leaves() int {
+	return (len(d.Hashes) + 1) / 2
+}
+
+// Diff returns the indexes of the leaves that differ between d and other.
+// Only the subtrees with differing hashes are walked. A nil other is
+// treated as a series without points.
+func (d *SeriesDigest) Diff(other *SeriesDigest) []int {
+	if other == nil {
+		other = &SeriesDigest{Key: d.Key, Hashes: make([]uint64, len(d.Hashes))}
+	} else if len(other.Hashes) != len(d.Hashes) {
+		// Digests of different depths can't be compared so every leaf differs.
+		a := make([]int, d.Leaves())
+		for i := range a {
+			a[i] = i
+		}
+		return a
+	}
+
+	var a []int
+	first := len(d.Hashes) / 2
+	var walk func(i int)
+	walk = func(i int) {
+		if d.Hashes[i] == other.Hashes[i] {
+			return
+		} else if i >= first {
+			a = append(a, i-first)
+			return
+		}
+		walk(2*i + 1)
+		walk(2*i + 2)
+	}
+	walk(0)
+	return a
+}
+
+// DigestRange returns the time range covered by leaf i of a digest of the
+// range [min, max) with 2^depth leaves. The returned range is half-open.
+func DigestRange(min, max int64, depth, i int) (int64, int64) {
+	span := digestSpan(min, max, depth)
+	lmin := min + int64(i)*span
+	lmax := lmin + span
+	if lmax > max || lmax < lmin {
+		lmax = max
+	}
+	return lmin, lmax
+}
+
+// DiffPoints compares the points of a series on two replicas. It returns the
+// points in a with timestamps that aren't in b, and the points in a that
+// differ from the point with the same timestamp in b. Points are compared the
+// same way they are digested.
+func DiffPoints(a, b []models.Point) (missing, conflicting []models.Point) {
+	m := make(map[int64]string, len(b))
+	for _, p := range b {
+		m[p.UnixNano()] = p.String()
+	}
+
+	for _, p := range a {
+		if s, ok := m[p.UnixNano()]; !ok {
+			missing = append(missing, p)
+		} else if s != p.String() {
+			conflicting = append(conflicting, p)
+		}
+	}
+	return missing, conflicting
+}
+
+// digestSpan returns the duration covered by each leaf of a digest.
+func digestSpan(min, max int64, depth int) int64 {
+	n := uint64(1) << uint(depth)
+	span := (uint64(max-min) + n - 1) / n
+	if span == 0 {
+		span = 1
+	}
+	return int64(span)
+}
+
+// sum computes the leaf hashes from the points of the series and then the
+// hashes of the inner nodes.
+func (d *SeriesDigest) sum(points []models.Point, min, max int64, depth int) {
+	first := len(d.Hashes) / 2
+	span := digestSpan(min, max, depth)
+
+	// Hash the points of each leaf in time order.
+	leaf := -1
+	h := fnv.New64a()
+	for _, p := range points {
+		i := int((p.UnixNano() - min) / span)
+		if i != leaf {
+			if leaf >= 0 {
+				d.Hashes[first+leaf] = h.Sum64()
+			}
+			leaf = i
+			h.Reset()
+		}
+		h.Write([]byte(p.String()))
+	}
+	if leaf >= 0 {
+		d.Hashes[first+leaf] = h.Sum64()
+	}
+
+	// Hash the children of each inner node.
+	var buf [16]byte
+	for i := first - 1; i >= 0; i-- {
+		l, r := d.Hashes[2*i+1], d.Hashes[2*i+2]
+		if l == 0 && r == 0 {
+			continue
+		}
+		binary.BigEndian.PutUint64(buf[0:8], l)
+		binary.BigEndian.PutUint64(buf[8:16], r)
+		h.Reset()
+		h.Write(buf[:])
+		d.Hashes[i] = h.Sum64()
+	}
+}
+
+// Digest returns digests of every series with points in the shard between
+// min, inclusive, and max, exclusive. The digests are sorted by series key.
+func (s *Shard) Digest(min, max int64, depth int) ([]*SeriesDigest, error) {
+	if depth < 0 || depth > MaxDigestDepth {
+		return nil, ErrInvalidDigestDepth
+	}
+
+	tx, err := s.ReadOnlyTx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var a []*SeriesDigest
+	for _, key := range s.index.shardSeriesKeys(s.id) {
+		points, err := s.seriesPoints(tx, key, min, max)
+		if err != nil {
+			return nil, err
+		} else if len(points) == 0 {
+			continue
+		}
+
+		a = append(a, NewSeriesDigest(key, points, min, max, depth))
+	}
+	return a, nil
+}
+
+// SeriesPoints returns the points of a series in the shard between min,
+// inclusive, and max, exclusive.
+func (s *Shard) SeriesPoints(key string, min, max int64) ([]models.Point, error) {
+	tx, err := s.ReadOnlyTx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	return s.seriesPoints(tx, key, min, max)
+}
+
+func (s *Shard) seriesPoints(tx Tx, key string, min, max int64) ([]models.Point, error) {
+	ss := s.index.Series(key)
+	if ss == nil {
+		return nil, nil
+	}
+	name := ss.measurement.Name
+
+	// Read every field of the measurement.
+	s.mu.RLock()
+	mf := s.measurementFields[name]
+	s.mu.RUnlock()
+	if mf == nil {
+		return nil, nil
+	}
+	fields := make([]string, 0, len(mf.Fields))
+	for f := range mf.Fields {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+
+	var points []models.Point
+	c := tx.Cursor(key, fields, mf.Codec, true)
+	for k, v := c.SeekTo(min); k != EOF && k < max; k, v = c.Next() {
+		var values map[string]interface{}
+		switch v := v.(type) {
+		case nil:
+			continue
+		case map[string]interface{}:
+			values = v
+		default:
+			values = map[string]interface{}{fields[0]: v}
+		}
+		points = append(points, models.NewPoint(name, ss.Tags, values, time.Unix(0, k)))
+	}
+	return points, nil
+}
+
+// shardSeriesKeys returns the keys of the series defined in a shard.
+func (d *DatabaseIndex) shardSeriesKeys(shardID uint64) []string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	var keys []string
+	for k, ss := range d.series {
+		if ss.shardIDs[shardID] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,185 @@
+package tsdb_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/influxdb/influxdb/models"
+	"github.com/influxdb/influxdb/tsdb"
+)
+
+// Ensure only the leaves under differing subtrees are returned.
+func TestSeriesDigest_Diff(t *testing.T) {
+	a := &tsdb.SeriesDigest{Hashes: []uint64{1, 2, 3, 4, 5, 6, 7}}
+	b := &tsdb.SeriesDigest{Hashes: []uint64{9, 2, 8, 4, 5, 6, 9}}
+	if diff := a.Diff(b); !reflect.DeepEqual(diff, []int{3}) {
+		t.Fatalf("unexpected diff: %v", diff)
+	}
+
+	// Equal roots mean equal digests.
+	b = &tsdb.SeriesDigest{Hashes: []uint64{1, 0, 0, 0, 0, 0, 0}}
+	if diff := a.Diff(b); diff != nil {
+		t.Fatalf("unexpected diff: %v", diff)
+	}
+
+	// A missing digest differs in every non-empty leaf.
+	a = &tsdb.SeriesDigest{Hashes: []uint64{1, 2, 0, 4, 5, 0, 0}}
+	if diff := a.Diff(nil); !reflect.DeepEqual(diff, []int{0, 1}) {
+		t.Fatalf("unexpected diff: %v", diff)
+	}
+}
+
+// Ensure a leaf's time range covers its share of the digested range.
+func TestDigestRange(t *testing.T) {
+	for i, tt := range []struct {
+		min, max   int64
+		depth, i   int
+		lmin, lmax int64
+	}{
+		{min: 0, max: 100, depth: 2, i: 0, lmin: 0, lmax: 25},
+		{min: 0, max: 100, depth: 2, i: 3, lmin: 75, lmax: 100},
+		{min: 10, max: 20, depth: 2, i: 3, lmin: 19, lmax: 20},
+	} {
+		lmin, lmax := tsdb.DigestRange(tt.min, tt.max, tt.depth, tt.i)
+		if lmin != tt.lmin || lmax != tt.lmax {
+			t.Errorf("%d. unexpected range: got [%d, %d), exp [%d, %d)", i, lmin, lmax, tt.lmin, tt.lmax)
+		}
+	}
+}
+
+// Ensure points are only missing if no point has their timestamp, and
+// conflicting if the point with their timestamp differs.
+func TestDiffPoints(t *testing.T) {
+	now := time.Unix(0, 0)
+	p := func(v float64, sec int) models.Point {
+		return models.NewPoint("cpu", nil, models.Fields{"value": v}, now.Add(time.Duration(sec)*time.Second))
+	}
+
+	a := []models.Point{p(1, 1), p(2, 2), p(3, 3)}
+	b := []models.Point{p(1, 1), p(9, 2)}
+	missing, conflicting := tsdb.DiffPoints(a, b)
+	if !reflect.DeepEqual(missing, []models.Point{p(3, 3)}) {
+		t.Fatalf("unexpected missing points: %v", missing)
+	} else if !reflect.DeepEqual(conflicting, []models.Point{p(2, 2)}) {
+		t.Fatalf("unexpected conflicting points: %v", conflicting)
+	}
+}
+
+// Ensure the digests of two shards only differ in the ranges with different points.
+func TestShard_Digest(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir("", "shard_test")
+	defer os.RemoveAll(tmpDir)
+
+	opts := tsdb.NewEngineOptions()
+	opts.Config.WALDir = filepath.Join(tmpDir, "wal")
+
+	sh0 := tsdb.NewShard(1, tsdb.NewDatabaseIndex(), filepath.Join(tmpDir, "shard0"), filepath.Join(tmpDir, "wal0"), opts)
+	sh1 := tsdb.NewShard(1, tsdb.NewDatabaseIndex(), filepath.Join(tmpDir, "shard1"), filepath.Join(tmpDir, "wal1"), opts)
+	for _, sh := range []*tsdb.Shard{sh0, sh1} {
+		if err := sh.Open(); err != nil {
+			t.Fatalf("error opening shard: %s", err)
+		}
+		defer sh.Close()
+	}
+
+	points := []models.Point{
+		models.NewPoint("cpu", models.Tags{"host": "serverA"}, models.Fields{"value": 1.0}, time.Unix(0, 10)),
+		models.NewPoint("cpu", models.Tags{"host": "serverA"}, models.Fields{"value": 2.0, "idle": 3.0}, time.Unix(0, 60)),
+		models.NewPoint("cpu", models.Tags{"host": "serverB"}, models.Fields{"value": 4.0}, time.Unix(0, 30)),
+	}
+	if err := sh0.WritePoints(points); err != nil {
+		t.Fatal(err)
+	} else if err := sh1.WritePoints(points[:1]); err != nil {
+		t.Fatal(err)
+	}
+
+	a0, err := sh0.Digest(0, 100, 2)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(a0) != 2 || a0[0].Key != "cpu,host=serverA" || a0[1].Key != "cpu,host=serverB" {
+		t.Fatalf("unexpected digests: %#v", a0)
+	}
+
+	a1, err := sh1.Digest(0, 100, 2)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(a1) != 1 {
+		t.Fatalf("unexpected digests: %#v", a1)
+	}
+
+	// The second write to serverA only differs in the third range.
+	if diff := a0[0].Diff(a1[0]); !reflect.DeepEqual(diff, []int{2}) {
+		t.Fatalf("unexpected diff: %v", diff)
+	}
+
+	// The points of the differing range can be read from the shard.
+	min, max := tsdb.DigestRange(0, 100, 2, 2)
+	if a, err := sh0.SeriesPoints("cpu,host=serverA", min, max); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 1 || a[0].String() != points[1].String() {
+		t.Fatalf("unexpected points: %v", a)
+	}
+
+	// Writing the missing point makes the digests equal.
+	if err := sh1.WritePoints(points[1:2]); err != nil {
+		t.Fatal(err)
+	} else if a1, err = sh1.Digest(0, 100, 2); err != nil {
+		t.Fatal(err)
+	} else if a0[0].Root() != a1[0].Root() {
+		t.Fatalf("unexpected digest: %#v", a1[0])
+	}
+}
+
+// Ensure digests only include the series of the shard when shards share an
+// index, including after the shards are reopened.
+func TestShard_Digest_SharedIndex(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir("", "shard_test")
+	defer os.RemoveAll(tmpDir)
+
+	opts := tsdb.NewEngineOptions()
+	opts.Config.WALDir = filepath.Join(tmpDir, "wal")
+
+	index := tsdb.NewDatabaseIndex()
+	sh0 := tsdb.NewShard(1, index, filepath.Join(tmpDir, "shard0"), filepath.Join(tmpDir, "wal0"), opts)
+	sh1 := tsdb.NewShard(2, index, filepath.Join(tmpDir, "shard1"), filepath.Join(tmpDir, "wal1"), opts)
+	for _, sh := range []*tsdb.Shard{sh0, sh1} {
+		if err := sh.Open(); err != nil {
+			t.Fatalf("error opening shard: %s", err)
+		}
+		defer sh.Close()
+	}
+
+	if err := sh0.WritePoints([]models.Point{
+		models.NewPoint("cpu", models.Tags{"host": "serverA"}, models.Fields{"value": 1.0}, time.Unix(0, 10)),
+	}); err != nil {
+		t.Fatal(err)
+	} else if err := sh1.WritePoints([]models.Point{
+		models.NewPoint("cpu", models.Tags{"host": "serverB"}, models.Fields{"value": 2.0}, time.Unix(0, 20)),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if a, err := sh1.Digest(0, 100, 2); err != nil {
+			t.Fatal(err)
+		} else if len(a) != 1 || a[0].Key != "cpu,host=serverB" {
+			t.Fatalf("unexpected digests: %#v", a)
+		}
+
+		// Reopen the shard with a new index holding the other shard's series.
+		if err := sh1.Close(); err != nil {
+			t.Fatal(err)
+		}
+		index = tsdb.NewDatabaseIndex()
+		index.CreateSeriesIndexIfNotExists("cpu", tsdb.NewSeries("cpu,host=serverA", map[string]string{"host": "serverA"}))
+		sh1 = tsdb.NewShard(2, index, filepath.Join(tmpDir, "shard1"), filepath.Join(tmpDir, "wal1"), opts)
+		if err := sh1.Open(); err != nil {
+			t.Fatal(err)
+		}
+		defer sh1.Close()
+	}
+}
@@ -3,13 +3,18 @@ package tsdb
 import (
 	"bytes"
 	"errors"
+	"expvar"
 	"fmt"
 	"io"
 	"os"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/boltdb/bolt"
+	"github.com/influxdb/influxdb"
+	"github.com/influxdb/influxdb/meta"
 	"github.com/influxdb/influxdb/models"
 )
 
@@ -31,6 +36,7 @@ type Engine interface {
 	DeleteSeries(keys []string) error
 	DeleteMeasurement(name string, seriesKeys []string) error
 	SeriesCount() (n int, err error)
+	Statistics() EngineStats
 
 	io.WriterTo
 }
@@ -103,6 +109,11 @@ type EngineOptions struct {
 	WALFlushInterval       time.Duration
 	WALPartitionFlushDelay time.Duration
 
+	// The shard the engine stores. Used to tag statistics.
+	ShardID         uint64
+	Database        string
+	RetentionPolicy string
+
 	Config Config
 }
 
@@ -117,6 +128,111 @@ func NewEngineOptions() EngineOptions {
 	}
 }
 
+// Statistics published by every engine.
+const (
+	StatPointsWrite         = "points_write"
+	StatDiskBytes           = "disk_bytes"
+	StatCursorSeek          = "cursor_seek"
+	StatBlocksDecode        = "blks_decode"
+	StatWALFlush            = "wal_flush"
+	StatWALFlushDuration    = "wal_flush_duration"
+	StatWALPartitionSizePfx = "wal_partition_size_"
+	StatCompact             = "compact"
+)
+
+// StatWALPartitionSize returns the statistic holding the size of a WAL partition.
+func StatWALPartitionSize(id uint8) string {
+	return StatWALPartitionSizePfx + strconv.Itoa(int(id))
+}
+
+// EngineStats represents the statistics every engine reports.
+type EngineStats struct {
+	PointsWritten     int64           // points written to the data file
+	DiskBytes         int64           // size of the data file
+	CursorSeeks       int64           // number of cursor seeks
+	BlocksDecoded     int64           // number of compressed blocks decoded
+	WALFlushes        int64           // number of WAL flushes into the data file
+	WALFlushDuration  time.Duration   // total time spent flushing the WAL
+	WALPartitionSizes map[uint8]int64 // approximate bytes held by each WAL partition
+	Compactions       int64           // number of data file compactions
+}
+
+// NewEngineStatistics returns the statistics map of an engine at path. It's
+// published through expvar, so the monitor records it with the other
+// statistics. The common statistics start at zero so every engine reports
+// the same set. Engines return them through Statistics using NewEngineStats.
+func NewEngineStatistics(path string, opt EngineOptions) *expvar.Map {
+	key := fmt.Sprintf("engine:%s:%s", opt.EngineVersion, path)
+	tags := map[string]string{
+		"path":             path,
+		"version":          opt.EngineVersion,
+		"id":               strconv.FormatUint(opt.ShardID, 10),
+		"database":         opt.Database,
+		"retention_policy": opt.RetentionPolicy,
+	}
+	m := influxdb.NewStatistics(key, "engine", tags)
+
+	for _, k := range []string{StatPointsWrite, StatDiskBytes, StatCursorSeek, StatBlocksDecode, StatWALFlush, StatCompact} {
+		m.Set(k, new(expvar.Int))
+	}
+	m.Set(StatWALFlushDuration, new(expvar.Float))
+
+	return m
+}
+
+// NewEngineStats returns the common statistics held in an engine's statistics map.
+func NewEngineStats(m *expvar.Map) EngineStats {
+	stats := EngineStats{WALPartitionSizes: make(map[uint8]int64)}
+	m.Do(func(kv expvar.KeyValue) {
+		var n int64
+		switch v := kv.Value.(type) {
+		case *expvar.Int:
+			n = v.Value()
+		case *expvar.Float:
+			if kv.Key == StatWALFlushDuration {
+				stats.WALFlushDuration = time.Duration(v.Value() * float64(time.Second))
+			}
+			return
+		default:
+			return
+		}
+
+		switch kv.Key {
+		case StatPointsWrite:
+			stats.PointsWritten = n
+		case StatDiskBytes:
+			stats.DiskBytes = n
+		case StatCursorSeek:
+			stats.CursorSeeks = n
+		case StatBlocksDecode:
+			stats.BlocksDecoded = n
+		case StatWALFlush:
+			stats.WALFlushes = n
+		case StatCompact:
+			stats.Compactions = n
+		default:
+			if strings.HasPrefix(kv.Key, StatWALPartitionSizePfx) {
+				id, err := strconv.ParseUint(strings.TrimPrefix(kv.Key, StatWALPartitionSizePfx), 10, 8)
+				if err == nil {
+					stats.WALPartitionSizes[uint8(id)] = n
+				}
+			}
+		}
+	})
+	return stats
+}
+
+// SetStatInt sets an integer statistic to v.
+func SetStatInt(m *expvar.Map, key string, v int64) {
+	if i, ok := m.Get(key).(*expvar.Int); ok {
+		i.Set(v)
+		return
+	}
+	i := new(expvar.Int)
+	i.Set(v)
+	m.Set(key, i)
+}
+
 // Tx represents a transaction.
 type Tx interface {
 	io.WriterTo
@@ -148,6 +264,77 @@ func DedupeEntries(a [][]byte) [][]byte {
 	return other
 }
 
+// DuplicateResolver decides which data is kept when points are written to a
+// series at the same timestamp. A nil resolver replaces the existing point.
+type DuplicateResolver struct {
+	// Policy is one of the meta.DuplicatePolicy constants.
+	Policy string
+
+	// FieldCodec returns the codec for a measurement's fields.
+	// It is required to merge fields.
+	FieldCodec func(measurement string) *FieldCodec
+}
+
+// Resolve returns the encoded fields to keep when data is written to the
+// series key at the timestamp of existing.
+func (r *DuplicateResolver) Resolve(key string, existing, data []byte) []byte {
+	if r == nil {
+		return data
+	}
+
+	switch r.Policy {
+	case meta.DuplicatePolicyFirst:
+		return existing
+	case meta.DuplicatePolicyMerge:
+		if r.FieldCodec == nil {
+			return data
+		}
+		// Fall back to replacing the point if either side can't be decoded.
+		b, err := r.FieldCodec(MeasurementFromSeriesKey(key)).MergeFields(existing, data)
+		if err != nil {
+			return data
+		}
+		return b
+	default:
+		return data
+	}
+}
+
+// DedupeEntries returns entries of the series key sorted with unique keys
+// (the first 8 bytes). Entries sharing a key are resolved in the order they
+// appear in a.
+func (r *DuplicateResolver) DedupeEntries(key string, a [][]byte) [][]byte {
+	if r == nil || r.Policy == "" || r.Policy == meta.DuplicatePolicyReplace {
+		return DedupeEntries(a)
+	}
+
+	// Sort by key only so duplicates stay in write order.
+	other := make([][]byte, len(a))
+	copy(other, a)
+	sort.Stable(byEntryKey(other))
+
+	// Collapse each run of duplicates into a single entry.
+	n := 0
+	for _, b := range other {
+		if n > 0 && bytes.Equal(other[n-1][0:8], b[0:8]) {
+			data := r.Resolve(key, other[n-1][8:], b[8:])
+			other[n-1] = append(append(make([]byte, 0, 8+len(data)), b[0:8]...), data...)
+			continue
+		}
+		other[n] = b
+		n++
+	}
+
+	return other[:n]
+}
+
+// byEntryKey sorts entries by their key (the first 8 bytes).
+type byEntryKey [][]byte
+
+func (a byEntryKey) Len() int           { return len(a) }
+func (a byEntryKey) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byEntryKey) Less(i, j int) bool { return bytes.Compare(a[i][0:8], a[j][0:8]) == -1 }
+
 type ByteSlices [][]byte
 
 func (a ByteSlices) Len() int           { return len(a) }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"errors"
+	"expvar"
 	"fmt"
 	"hash/fnv"
 	"io"
@@ -56,6 +57,9 @@ type Engine struct {
 	// Used for out-of-band error messages.
 	logger *log.Logger
 
+	// expvar-based stats.
+	statMap *expvar.Map
+
 	// The maximum size and time thresholds for flushing the WAL.
 	MaxWALSize             int
 	WALFlushInterval       time.Duration
@@ -69,8 +73,9 @@ type Engine struct {
 // to be added for later engine versions.
 func NewEngine(path string, walPath string, opt tsdb.EngineOptions) tsdb.Engine {
 	e := &Engine{
-		path:  path,
-		flush: make(chan struct{}, 1),
+		path:    path,
+		flush:   make(chan struct{}, 1),
+		statMap: tsdb.NewEngineStatistics(path, opt),
 
 		MaxWALSize:             opt.MaxWALSize,
 		WALFlushInterval:       opt.WALFlushInterval,
@@ -123,6 +128,8 @@ func (e *Engine) Open() error {
 			return fmt.Errorf("init: %s", err)
 		}
 
+		e.setDiskBytes()
+
 		// Start flush interval timer.
 		e.flushTimer = time.NewTimer(e.WALFlushInterval)
 
@@ -298,6 +305,7 @@ func (e *Engine) WritePoints(points []models.Point, measurementFieldsToSave map[
 
 			// Calculate estimated WAL size.
 			e.walSize += len(key) + len(v)
+			e.statMap.Add(tsdb.StatWALPartitionSize(partitionID), int64(len(key)+len(v)))
 		}
 
 		// Sort by timestamp if not appending.
@@ -453,6 +461,12 @@ func (e *Engine) FlushPartition(partitionID uint8) error {
 	// Reset cache.
 	e.cache[partitionID] = make(map[string][][]byte)
 
+	e.statMap.Add(tsdb.StatPointsWrite, int64(pointN))
+	e.statMap.Add(tsdb.StatWALFlush, 1)
+	e.statMap.AddFloat(tsdb.StatWALFlushDuration, time.Since(startTime).Seconds())
+	tsdb.SetStatInt(e.statMap, tsdb.StatWALPartitionSize(partitionID), 0)
+	e.setDiskBytes()
+
 	if pointN > 0 {
 		e.logger.Printf("flush %d points in %.3fs", pointN, time.Since(startTime).Seconds())
 	}
@@ -460,6 +474,13 @@ func (e *Engine) FlushPartition(partitionID uint8) error {
 	return nil
 }
 
+// setDiskBytes updates the data file size statistic.
+func (e *Engine) setDiskBytes() {
+	if fi, err := os.Stat(e.path); err == nil {
+		tsdb.SetStatInt(e.statMap, tsdb.StatDiskBytes, fi.Size())
+	}
+}
+
 // autoflusher waits for notification of a flush and kicks it off in the background.
 // This method runs in a separate goroutine.
 func (e *Engine) autoflusher(closing chan struct{}) {
@@ -513,6 +534,10 @@ func (e *Engine) SeriesCount() (n int, err error) {
 	return
 }
 
+// Statistics returns the engine's common statistics. b1 stores points
+// individually, so it never decodes blocks or compacts.
+func (e *Engine) Statistics() tsdb.EngineStats { return tsdb.NewEngineStats(e.statMap) }
+
 // Begin starts a new transaction on the engine.
 func (e *Engine) Begin(writable bool) (tsdb.Tx, error) {
 	tx, err := e.db.Begin(writable)
@@ -574,6 +599,7 @@ func (tx *Tx) Cursor(series string, fields []string, dec *tsdb.FieldCodec, ascen
 		fields:    fields,
 		dec:       dec,
 		ascending: ascending,
+		statMap:   tx.engine.statMap,
 	}
 	if b != nil {
 		cur.cursor = b.Cursor()
@@ -610,12 +636,18 @@ type Cursor struct {
 
 	// The direction the cursor pointer moves after each call to Next()
 	ascending bool
+
+	statMap *expvar.Map
 }
 
 func (c *Cursor) Ascending() bool { return c.ascending }
 
 // Seek moves the cursor to a position and returns the closest key/value pair.
 func (c *Cursor) SeekTo(seek int64) (key int64, value interface{}) {
+	if c.statMap != nil {
+		c.statMap.Add(tsdb.StatCursorSeek, 1)
+	}
+
 	// Seek bolt cursor.
 	seekBytes := u64tob(uint64(seek))
 	if c.cursor != nil {
@@ -77,6 +77,55 @@ func TestEngine_WritePoints(t *testing.T) {
 	}
 }
 
+// Ensure the engine reports the common engine statistics.
+func TestEngine_Statistics(t *testing.T) {
+	e := OpenDefaultEngine()
+	defer e.Close()
+
+	mf := &tsdb.MeasurementFields{Fields: make(map[string]*tsdb.Field)}
+	mf.CreateFieldIfNotExists("value", influxql.Float)
+	points, err := models.ParsePointsWithPrecision([]byte("temperature value=100 1434059627\ntemperature value=200 1434059628"), time.Now().UTC(), "s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range points {
+		data, err := mf.Codec.EncodeFields(p.Fields())
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.SetData(data)
+	}
+
+	if err := e.WritePoints(points, map[string]*tsdb.MeasurementFields{"temperature": mf}, nil); err != nil {
+		t.Fatal(err)
+	}
+	partitionID := b1.WALPartition([]byte("temperature"))
+	if stats := e.Statistics(); stats.WALPartitionSizes[partitionID] == 0 {
+		t.Fatalf("unexpected partition sizes: %v", stats.WALPartitionSizes)
+	}
+
+	if err := e.Flush(0); err != nil {
+		t.Fatal(err)
+	}
+
+	tx := e.MustBegin(false)
+	defer tx.Rollback()
+	tx.Cursor("temperature", []string{"value"}, mf.Codec, true).SeekTo(0)
+
+	stats := e.Statistics()
+	if stats.PointsWritten != 2 {
+		t.Fatalf("unexpected points written: %d", stats.PointsWritten)
+	} else if stats.DiskBytes == 0 {
+		t.Fatal("expected disk bytes")
+	} else if stats.CursorSeeks != 1 {
+		t.Fatalf("unexpected cursor seeks: %d", stats.CursorSeeks)
+	} else if stats.WALFlushes == 0 {
+		t.Fatal("expected wal flushes")
+	} else if stats.WALPartitionSizes[partitionID] != 0 {
+		t.Fatalf("unexpected partition sizes: %v", stats.WALPartitionSizes)
+	}
+}
+
 // Ensure points can be written to the engine and queried in reverse order.
 func TestEngine_WritePoints_Reverse(t *testing.T) {
 	e := OpenDefaultEngine()
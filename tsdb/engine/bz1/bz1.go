@@ -12,11 +12,11 @@ import (
 	"math"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/boltdb/bolt"
 	"github.com/golang/snappy"
-	"github.com/influxdb/influxdb"
 	"github.com/influxdb/influxdb/models"
 	"github.com/influxdb/influxdb/tsdb"
 	"github.com/influxdb/influxdb/tsdb/engine/wal"
@@ -34,7 +34,6 @@ const (
 
 const (
 	statSlowInsert               = "slow_insert"
-	statPointsWrite              = "points_write"
 	statPointsWriteDedupe        = "points_write_dedupe"
 	statBlocksWrite              = "blks_write"
 	statBlocksWriteBytes         = "blks_write_bytes"
@@ -67,6 +66,26 @@ type Engine struct {
 
 	// Size of uncompressed points to write to a block.
 	BlockSize int
+
+	// How often the engine compacts itself. Zero disables online compactions.
+	CompactInterval time.Duration
+
+	// Fraction of the data file that must be free pages before a compaction
+	// copies it into a fresh file.
+	CompactThreshold float64
+
+	// compactMu is held for writing while a compaction swaps the data file.
+	// txN is the number of transactions returned by Begin that are still open.
+	compactMu sync.RWMutex
+	txN       int64
+
+	closing chan struct{}
+	wg      sync.WaitGroup
+
+	// Resolves points written to a series at the timestamp of an existing point.
+	// It has its own lock since it's read while the WAL flushes during Close.
+	resolverMu sync.RWMutex
+	resolver   *tsdb.DuplicateResolver
 }
 
 // WAL represents a write ahead log that can be queried
@@ -83,9 +102,7 @@ type WAL interface {
 // NewEngine returns a new instance of Engine.
 func NewEngine(path string, walPath string, opt tsdb.EngineOptions) tsdb.Engine {
 	// Configure statistics collection.
-	key := fmt.Sprintf("engine:%s:%s", opt.EngineVersion, path)
-	tags := map[string]string{"path": path, "version": opt.EngineVersion}
-	statMap := influxdb.NewStatistics(key, "engine", tags)
+	statMap := tsdb.NewEngineStatistics(path, opt)
 
 	// create the writer with a directory of the same name as the shard, but with the wal extension
 	w := wal.NewLog(walPath)
@@ -97,13 +114,18 @@ func NewEngine(path string, walPath string, opt tsdb.EngineOptions) tsdb.Engine
 	w.PartitionSizeThreshold = opt.Config.WALPartitionSizeThreshold
 	w.ReadySeriesSize = opt.Config.WALReadySeriesSize
 	w.LoggingEnabled = opt.Config.WALLoggingEnabled
+	w.Fsync = opt.Config.WALFsync
+	w.FsyncInterval = time.Duration(opt.Config.WALFsyncInterval)
+	w.EngineStatMap = statMap
 
 	e := &Engine{
 		path: path,
 
-		statMap:   statMap,
-		BlockSize: DefaultBlockSize,
-		WAL:       w,
+		statMap:          statMap,
+		BlockSize:        DefaultBlockSize,
+		CompactInterval:  time.Duration(opt.Config.CompactInterval),
+		CompactThreshold: DefaultCompactThreshold,
+		WAL:              w,
 	}
 
 	w.Index = e
@@ -143,6 +165,14 @@ func (e *Engine) Open() error {
 		}); err != nil {
 			return fmt.Errorf("init: %s", err)
 		}
+		tsdb.SetStatInt(e.statMap, tsdb.StatDiskBytes, fileSize(e.path))
+
+		// Start compacting in the background.
+		if e.CompactInterval > 0 {
+			e.closing = make(chan struct{})
+			e.wg.Add(1)
+			go e.compactor(e.closing)
+		}
 
 		return nil
 	}(); err != nil {
@@ -155,6 +185,15 @@ func (e *Engine) Open() error {
 
 // Close closes the engine.
 func (e *Engine) Close() error {
+	// Stop the compactor before it can start another compaction.
+	e.mu.Lock()
+	if e.closing != nil {
+		close(e.closing)
+		e.closing = nil
+	}
+	e.mu.Unlock()
+	e.wg.Wait()
+
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
@@ -172,12 +211,64 @@ func (e *Engine) close() error {
 	return nil
 }
 
+// SetDuplicateResolver sets how points written to a series at the timestamp
+// of an existing point are resolved, in both the WAL and the index.
+func (e *Engine) SetDuplicateResolver(r *tsdb.DuplicateResolver) {
+	e.resolverMu.Lock()
+	e.resolver = r
+	e.resolverMu.Unlock()
+
+	if w, ok := e.WAL.(interface {
+		SetDuplicateResolver(r *tsdb.DuplicateResolver)
+	}); ok {
+		w.SetDuplicateResolver(r)
+	}
+}
+
+// duplicateResolver returns the engine's duplicate resolver.
+func (e *Engine) duplicateResolver() *tsdb.DuplicateResolver {
+	e.resolverMu.RLock()
+	defer e.resolverMu.RUnlock()
+	return e.resolver
+}
+
+// compactor periodically compacts the engine until closing is closed.
+func (e *Engine) compactor(closing chan struct{}) {
+	defer e.wg.Done()
+
+	t := time.NewTicker(e.CompactInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-closing:
+			return
+		case <-t.C:
+			// Failures are counted in the stats and retried on the next tick.
+			e.Compact()
+		}
+	}
+}
+
+// view runs fn in a read-only transaction.
+func (e *Engine) view(fn func(*bolt.Tx) error) error {
+	e.compactMu.RLock()
+	defer e.compactMu.RUnlock()
+	return e.db.View(fn)
+}
+
+// update runs fn in a read-write transaction.
+func (e *Engine) update(fn func(*bolt.Tx) error) error {
+	e.compactMu.RLock()
+	defer e.compactMu.RUnlock()
+	return e.db.Update(fn)
+}
+
 // SetLogOutput is a no-op.
 func (e *Engine) SetLogOutput(w io.Writer) {}
 
 // LoadMetadataIndex loads the shard metadata into memory.
 func (e *Engine) LoadMetadataIndex(index *tsdb.DatabaseIndex, measurementFields map[string]*tsdb.MeasurementFields) error {
-	if err := e.db.View(func(tx *bolt.Tx) error {
+	if err := e.view(func(tx *bolt.Tx) error {
 		// Load measurement metadata
 		fields, err := e.readFields(tx)
 		if err != nil {
@@ -237,7 +328,9 @@ func (e *Engine) WritePoints(points []models.Point, measurementFieldsToSave map[
 
 // WriteIndex writes marshaled points to the engine's underlying index.
 func (e *Engine) WriteIndex(pointsByKey map[string][][]byte, measurementFieldsToSave map[string]*tsdb.MeasurementFields, seriesToCreate []*tsdb.SeriesCreate) error {
-	return e.db.Update(func(tx *bolt.Tx) error {
+	defer func() { tsdb.SetStatInt(e.statMap, tsdb.StatDiskBytes, fileSize(e.path)) }()
+
+	return e.update(func(tx *bolt.Tx) error {
 		// Write series & field metadata.
 		if err := e.writeNewSeries(tx, seriesToCreate); err != nil {
 			return fmt.Errorf("write series: %s", err)
@@ -358,7 +451,7 @@ func (e *Engine) writeIndex(tx *bolt.Tx, key string, a [][]byte) error {
 	if len(a) == 0 {
 		return nil
 	}
-	e.statMap.Add(statPointsWrite, int64(len(a)))
+	e.statMap.Add(tsdb.StatPointsWrite, int64(len(a)))
 
 	// Create or retrieve series bucket.
 	bkt, err := tx.Bucket([]byte("points")).CreateBucketIfNotExists([]byte(key))
@@ -368,7 +461,8 @@ func (e *Engine) writeIndex(tx *bolt.Tx, key string, a [][]byte) error {
 	c := bkt.Cursor()
 
 	// Ensure the slice is sorted before retrieving the time range.
-	a = tsdb.DedupeEntries(a)
+	r := e.duplicateResolver()
+	a = r.DedupeEntries(key, a)
 	e.statMap.Add(statPointsWriteDedupe, int64(len(a)))
 
 	// Convert the raw time and byte slices to entries with lengths
@@ -400,10 +494,10 @@ func (e *Engine) writeIndex(tx *bolt.Tx, key string, a [][]byte) error {
 		return nil
 	}
 
-	// Generate map of inserted keys.
-	m := make(map[int64]struct{})
-	for _, b := range a {
-		m[int64(btou64(b[0:8]))] = struct{}{}
+	// Generate map of inserted entries by key.
+	m := make(map[int64]int, len(a))
+	for i, b := range a {
+		m[int64(btou64(b[0:8]))] = i
 	}
 
 	// If time range overlaps existing blocks then unpack full range and reinsert.
@@ -426,10 +520,17 @@ func (e *Engine) writeIndex(tx *bolt.Tx, key string, a [][]byte) error {
 			return fmt.Errorf("decode block: %s", err)
 		}
 
-		// Copy out any entries that aren't being overwritten.
+		// Copy out any entries that aren't being overwritten and resolve
+		// the ones that are against the new entries.
 		for _, entry := range SplitEntries(buf) {
-			if _, ok := m[int64(btou64(entry[0:8]))]; !ok {
+			timestamp := int64(btou64(entry[0:8]))
+			i, ok := m[timestamp]
+			if !ok {
 				existing = append(existing, entry)
+				continue
+			}
+			if r != nil {
+				a[i] = MarshalEntry(timestamp, r.Resolve(key, entry[entryHeaderSize:], a[i][entryHeaderSize:]))
 			}
 		}
 
@@ -501,7 +602,7 @@ func (e *Engine) DeleteSeries(keys []string) error {
 		return err
 	}
 
-	return e.db.Update(func(tx *bolt.Tx) error {
+	return e.update(func(tx *bolt.Tx) error {
 		series, err := e.readSeries(tx)
 		if err != nil {
 			return err
@@ -524,7 +625,7 @@ func (e *Engine) DeleteMeasurement(name string, seriesKeys []string) error {
 		return err
 	}
 
-	return e.db.Update(func(tx *bolt.Tx) error {
+	return e.update(func(tx *bolt.Tx) error {
 		fields, err := e.readFields(tx)
 		if err != nil {
 			return err
@@ -551,7 +652,7 @@ func (e *Engine) DeleteMeasurement(name string, seriesKeys []string) error {
 
 // SeriesCount returns the number of series buckets on the shard.
 func (e *Engine) SeriesCount() (n int, err error) {
-	err = e.db.View(func(tx *bolt.Tx) error {
+	err = e.view(func(tx *bolt.Tx) error {
 		c := tx.Bucket([]byte("points")).Cursor()
 		for k, _ := c.First(); k != nil; k, _ = c.Next() {
 			n++
@@ -561,18 +662,38 @@ func (e *Engine) SeriesCount() (n int, err error) {
 	return
 }
 
+// Statistics returns the engine's common statistics, including the WAL's.
+func (e *Engine) Statistics() tsdb.EngineStats { return tsdb.NewEngineStats(e.statMap) }
+
 // Begin starts a new transaction on the engine.
 func (e *Engine) Begin(writable bool) (tsdb.Tx, error) {
-	tx, err := e.db.Begin(writable)
+	tx, err := e.begin(writable)
 	if err != nil {
 		return nil, err
 	}
 	return &Tx{Tx: tx, engine: e, wal: e.WAL}, nil
 }
 
+// begin starts a bolt transaction that must be closed through the engine's Tx
+// or finished with txDone.
+func (e *Engine) begin(writable bool) (*bolt.Tx, error) {
+	e.compactMu.RLock()
+	defer e.compactMu.RUnlock()
+
+	tx, err := e.db.Begin(writable)
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddInt64(&e.txN, 1)
+	return tx, nil
+}
+
+// txDone marks a transaction started by begin as closed.
+func (e *Engine) txDone() { atomic.AddInt64(&e.txN, -1) }
+
 // Stats returns internal statistics for the engine.
 func (e *Engine) Stats() (stats Stats, err error) {
-	err = e.db.View(func(tx *bolt.Tx) error {
+	err = e.view(func(tx *bolt.Tx) error {
 		stats.Size = tx.Size()
 		return nil
 	})
@@ -581,7 +702,7 @@ func (e *Engine) Stats() (stats Stats, err error) {
 
 // SeriesBucketStats returns internal BoltDB stats for a series bucket.
 func (e *Engine) SeriesBucketStats(key string) (stats bolt.BucketStats, err error) {
-	err = e.db.View(func(tx *bolt.Tx) error {
+	err = e.view(func(tx *bolt.Tx) error {
 		bkt := tx.Bucket([]byte("points")).Bucket([]byte(key))
 		if bkt != nil {
 			stats = bkt.Stats()
@@ -592,11 +713,17 @@ func (e *Engine) SeriesBucketStats(key string) (stats bolt.BucketStats, err erro
 }
 
 // WriteTo writes the length and contents of the engine to w.
+// The WAL is flushed first so the copy includes every point written so far.
 func (e *Engine) WriteTo(w io.Writer) (n int64, err error) {
-	tx, err := e.db.Begin(false)
+	if err := e.WAL.Flush(); err != nil {
+		return 0, fmt.Errorf("flush wal: %s", err)
+	}
+
+	tx, err := e.begin(false)
 	if err != nil {
 		return 0, err
 	}
+	defer e.txDone()
 	defer tx.Rollback()
 
 	// Write size.
@@ -620,6 +747,20 @@ type Tx struct {
 	*bolt.Tx
 	engine *Engine
 	wal    WAL
+
+	once sync.Once
+}
+
+// Commit writes all changes to disk and closes the transaction.
+func (tx *Tx) Commit() error {
+	defer tx.once.Do(tx.engine.txDone)
+	return tx.Tx.Commit()
+}
+
+// Rollback closes the transaction and ignores all previous updates.
+func (tx *Tx) Rollback() error {
+	defer tx.once.Do(tx.engine.txDone)
+	return tx.Tx.Rollback()
 }
 
 // Cursor returns an iterator for a key.
@@ -637,6 +778,7 @@ func (tx *Tx) Cursor(series string, fields []string, dec *tsdb.FieldCodec, ascen
 		fields:    fields,
 		dec:       dec,
 		ascending: ascending,
+		statMap:   tx.engine.statMap,
 	}
 
 	if !ascending {
@@ -657,6 +799,8 @@ type Cursor struct {
 
 	fields []string
 	dec    *tsdb.FieldCodec
+
+	statMap *expvar.Map
 }
 
 func (c *Cursor) last() {
@@ -668,6 +812,9 @@ func (c *Cursor) Ascending() bool { return c.ascending }
 
 // Seek moves the cursor to a position and returns the closest key/value pair.
 func (c *Cursor) SeekTo(seek int64) (key int64, value interface{}) {
+	if c.statMap != nil {
+		c.statMap.Add(tsdb.StatCursorSeek, 1)
+	}
 	seekBytes := u64tob(uint64(seek))
 
 	// Move cursor to appropriate block and set to buffer.
@@ -768,6 +915,9 @@ func (c *Cursor) setBuf(block []byte) {
 		c.buf = c.buf[0:0]
 		log.Printf("block decode error: %s", err)
 	}
+	if c.statMap != nil {
+		c.statMap.Add(tsdb.StatBlocksDecode, 1)
+	}
 
 	if c.ascending {
 		c.buf, c.off = buf, 0
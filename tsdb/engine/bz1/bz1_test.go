@@ -8,11 +8,13 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/influxdb/influxdb/influxql"
+	"github.com/influxdb/influxdb/meta"
 	"github.com/influxdb/influxdb/models"
 	"github.com/influxdb/influxdb/tsdb"
 	"github.com/influxdb/influxdb/tsdb/engine/bz1"
@@ -138,6 +140,29 @@ func TestEngine_WritePoints_ErrPointsWriter(t *testing.T) {
 	}
 }
 
+// Ensure the engine flushes the WAL before it's copied.
+func TestEngine_WriteTo_FlushWAL(t *testing.T) {
+	e := OpenDefaultEngine()
+	defer e.Close()
+
+	var flushed bool
+	e.PointsWriter.FlushFn = func() error {
+		flushed = true
+		return nil
+	}
+	if _, err := e.WriteTo(ioutil.Discard); err != nil {
+		t.Fatal(err)
+	} else if !flushed {
+		t.Fatal("WAL not flushed")
+	}
+
+	// Ensure the copy fails if the WAL can't be flushed.
+	e.PointsWriter.FlushFn = func() error { return errors.New("marker") }
+	if _, err := e.WriteTo(ioutil.Discard); err == nil || err.Error() != `flush wal: marker` {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
 // Ensure the engine can write points to the index.
 func TestEngine_WriteIndex_Append(t *testing.T) {
 	e := OpenDefaultEngine()
@@ -247,6 +272,229 @@ func TestEngine_WriteIndex_Insert(t *testing.T) {
 	}
 }
 
+// Ensure the engine resolves overwritten points with its duplicate policy.
+func TestEngine_WriteIndex_DuplicatePolicy(t *testing.T) {
+	e := OpenDefaultEngine()
+	defer e.Close()
+
+	// Create codec.
+	codec := tsdb.NewFieldCodec(map[string]*tsdb.Field{
+		"value": {ID: uint8(1), Name: "value", Type: influxql.Float},
+		"idle":  {ID: uint8(2), Name: "idle", Type: influxql.Integer},
+	})
+
+	for _, tt := range []struct {
+		policy string
+		exp    map[string]interface{}
+	}{
+		{policy: meta.DuplicatePolicyReplace, exp: map[string]interface{}{"value": float64(3)}},
+		{policy: meta.DuplicatePolicyMerge, exp: map[string]interface{}{"value": float64(3), "idle": int64(5)}},
+		{policy: meta.DuplicatePolicyFirst, exp: map[string]interface{}{"value": float64(1), "idle": int64(5)}},
+	} {
+		e.SetDuplicateResolver(&tsdb.DuplicateResolver{
+			Policy:     tt.policy,
+			FieldCodec: func(string) *tsdb.FieldCodec { return codec },
+		})
+		key := "cpu,policy=" + tt.policy
+
+		// Write initial point to index.
+		if err := e.WriteIndex(map[string][][]byte{
+			key: [][]byte{
+				append(u64tob(10), MustEncodeFields(codec, models.Fields{"value": float64(1), "idle": int64(5)})...),
+			},
+		}, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		// Overwrite it with points missing a field, in the batch and against the block.
+		if err := e.WriteIndex(map[string][][]byte{
+			key: [][]byte{
+				append(u64tob(10), MustEncodeFields(codec, models.Fields{"value": float64(2)})...),
+				append(u64tob(10), MustEncodeFields(codec, models.Fields{"value": float64(3)})...),
+			},
+		}, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		tx := e.MustBegin(false)
+		c := tx.Cursor(key, []string{"value", "idle"}, codec, true)
+		if k, v := c.SeekTo(0); k != 10 || !reflect.DeepEqual(v, tt.exp) {
+			t.Fatalf("%s: unexpected key/value: %d / %v", tt.policy, k, v)
+		} else if k, _ = c.Next(); k != tsdb.EOF {
+			t.Fatalf("%s: unexpected key: %d", tt.policy, k)
+		}
+		tx.Rollback()
+	}
+}
+
+// Ensure the engine can compact series fragmented by out-of-order writes.
+func TestEngine_Compact(t *testing.T) {
+	e := OpenDefaultEngine()
+	defer e.Close()
+	e.BlockSize = 64
+
+	codec := tsdb.NewFieldCodec(map[string]*tsdb.Field{
+		"value": {ID: uint8(1), Name: "value", Type: influxql.Float},
+	})
+
+	// Write even timestamps and then insert odd ones between them.
+	var a [][]byte
+	for i := 0; i < 200; i += 2 {
+		a = append(a, append(u64tob(uint64(i)), MustEncodeFields(codec, models.Fields{"value": float64(i)})...))
+	}
+	if err := e.WriteIndex(map[string][][]byte{"cpu": a}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < 200; i += 40 {
+		if err := e.WriteIndex(map[string][][]byte{
+			"cpu": [][]byte{append(u64tob(uint64(i)), MustEncodeFields(codec, models.Fields{"value": float64(i)})...)},
+		}, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := e.Compact()
+	if err != nil {
+		t.Fatal(err)
+	} else if stats.SeriesN != 1 || stats.BlocksAfter >= stats.BlocksBefore {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	// Compacting again doesn't rewrite anything.
+	if stats, err := e.Compact(); err != nil {
+		t.Fatal(err)
+	} else if stats.SeriesN != 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	// Verify all points can be read back in order from the new file.
+	tx := e.MustBegin(false)
+	defer tx.Rollback()
+	c := tx.Cursor("cpu", []string{"value"}, codec, true)
+	var n int
+	prev := int64(-1)
+	for k, v := c.SeekTo(0); k != tsdb.EOF; k, v = c.Next() {
+		if k <= prev || v.(float64) != float64(k) {
+			t.Fatalf("unexpected key/value: %d / %v", k, v)
+		}
+		prev = k
+		n++
+	}
+	if n != 105 {
+		t.Fatalf("unexpected point count: %d", n)
+	}
+}
+
+// Ensure the engine reports the common engine statistics.
+func TestEngine_Statistics(t *testing.T) {
+	e := OpenDefaultEngine()
+	defer e.Close()
+
+	codec := tsdb.NewFieldCodec(map[string]*tsdb.Field{
+		"value": {ID: uint8(1), Name: "value", Type: influxql.Float},
+	})
+	if err := e.WriteIndex(map[string][][]byte{
+		"cpu": [][]byte{
+			append(u64tob(1), MustEncodeFields(codec, models.Fields{"value": float64(1)})...),
+			append(u64tob(2), MustEncodeFields(codec, models.Fields{"value": float64(2)})...),
+		},
+	}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	func() {
+		tx := e.MustBegin(false)
+		defer tx.Rollback()
+		tx.Cursor("cpu", []string{"value"}, codec, true).SeekTo(0)
+	}()
+
+	if _, err := e.Compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := e.Statistics()
+	if stats.PointsWritten != 2 {
+		t.Fatalf("unexpected points written: %d", stats.PointsWritten)
+	} else if stats.DiskBytes == 0 {
+		t.Fatal("expected disk bytes")
+	} else if stats.CursorSeeks != 1 {
+		t.Fatalf("unexpected cursor seeks: %d", stats.CursorSeeks)
+	} else if stats.BlocksDecoded != 1 {
+		t.Fatalf("unexpected blocks decoded: %d", stats.BlocksDecoded)
+	} else if stats.Compactions != 1 {
+		t.Fatalf("unexpected compactions: %d", stats.Compactions)
+	}
+}
+
+// Ensure a compaction only copies the data file once enough of it is free.
+func TestEngine_Compact_Threshold(t *testing.T) {
+	e := OpenDefaultEngine()
+	defer e.Close()
+
+	codec := tsdb.NewFieldCodec(map[string]*tsdb.Field{
+		"value": {ID: uint8(1), Name: "value", Type: influxql.Float},
+	})
+	m := make(map[string][][]byte)
+	for i := 0; i < 1000; i++ {
+		m["cpu"+strconv.Itoa(i)] = [][]byte{append(u64tob(uint64(i)), MustEncodeFields(codec, models.Fields{"value": float64(i)})...)}
+	}
+	if err := e.WriteIndex(m, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Stat(e.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The file has few free pages so it's left in place.
+	if _, err := e.Compact(); err != nil {
+		t.Fatal(err)
+	} else if other, err := os.Stat(e.Path()); err != nil {
+		t.Fatal(err)
+	} else if !os.SameFile(fi, other) {
+		t.Fatal("expected data file to be kept")
+	}
+
+	// Without a threshold the file is always copied.
+	e.CompactThreshold = 0
+	if _, err := e.Compact(); err != nil {
+		t.Fatal(err)
+	} else if other, err := os.Stat(e.Path()); err != nil {
+		t.Fatal(err)
+	} else if os.SameFile(fi, other) {
+		t.Fatal("expected data file to be replaced")
+	} else if _, err := os.Stat(e.Path() + "." + bz1.CompactExtension + ".old"); !os.IsNotExist(err) {
+		t.Fatalf("unexpected old file: %v", err)
+	}
+}
+
+// Ensure a compaction doesn't swap files while a transaction is open.
+func TestEngine_Compact_OpenTx(t *testing.T) {
+	e := OpenDefaultEngine()
+	defer e.Close()
+	e.CompactThreshold = 0
+
+	tx := e.MustBegin(false)
+	done := make(chan error)
+	go func() {
+		_, err := e.Compact()
+		done <- err
+	}()
+
+	// The compaction waits for the transaction to close.
+	select {
+	case err := <-done:
+		t.Fatalf("unexpected compaction: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	tx.Rollback()
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
 // Ensure the engine can rewrite blocks that contain the new point range.
 func TestEngine_Cursor_Reverse(t *testing.T) {
 	e := OpenDefaultEngine()
@@ -422,6 +670,7 @@ func (e *Engine) MustBegin(writable bool) tsdb.Tx {
 // EnginePointsWriter represents a mock that implements Engine.PointsWriter.
 type EnginePointsWriter struct {
 	WritePointsFn func(points []models.Point) error
+	FlushFn       func() error
 }
 
 func (w *EnginePointsWriter) WritePoints(points []models.Point, measurementFieldsToSave map[string]*tsdb.MeasurementFields, seriesToCreate []*tsdb.SeriesCreate) error {
@@ -442,7 +691,12 @@ func (w *EnginePointsWriter) Cursor(series string, fields []string, dec *tsdb.Fi
 	return &Cursor{ascending: ascending}
 }
 
-func (w *EnginePointsWriter) Flush() error { return nil }
+func (w *EnginePointsWriter) Flush() error {
+	if w.FlushFn == nil {
+		return nil
+	}
+	return w.FlushFn()
+}
 
 // Cursor represents a mock that implements tsdb.Curosr.
 type Cursor struct {
@@ -0,0 +1,350 @@
+package bz1
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/golang/snappy"
+	"github.com/influxdb/influxdb/tsdb"
+)
+
+const (
+	statCompactFail         = "compact_fail"
+	statCompactDuration     = "compact_duration"
+	statCompactProgress     = "compact_progress"
+	statCompactSeries       = "compact_series"
+	statCompactBlocksBefore = "compact_blks_before"
+	statCompactBlocksAfter  = "compact_blks_after"
+	statCompactBytesSaved   = "compact_bytes_saved"
+)
+
+// CompactExtension is the extension of the temporary file a compaction copies
+// the data file into.
+const CompactExtension = "compact"
+
+// compactTxTimeout is how long an online compaction waits for open transactions
+// to finish before giving up on swapping in the compacted file.
+const compactTxTimeout = 10 * time.Second
+
+// DefaultCompactThreshold is the fraction of the data file that must be free
+// pages before a compaction copies it into a fresh file.
+const DefaultCompactThreshold = 0.2
+
+// ErrCompactTxTimeout is returned when an online compaction can't swap files
+// because transactions are still open.
+var ErrCompactTxTimeout = errors.New("timeout waiting for open transactions")
+
+// CompactStats describes the result of a compaction.
+type CompactStats struct {
+	SeriesN      int   // number of series rewritten
+	BlocksBefore int   // number of blocks in the rewritten series before compaction
+	BlocksAfter  int   // number of blocks in the rewritten series after compaction
+	SizeBefore   int64 // data file size before compaction
+	SizeAfter    int64 // data file size after compaction
+}
+
+// BytesSaved returns the number of bytes the data file shrank by.
+func (s CompactStats) BytesSaved() int64 { return s.SizeBefore - s.SizeAfter }
+
+// Compact rewrites fragmented series into full blocks and then, if at least
+// CompactThreshold of the data file is free pages, copies the data into a
+// fresh file to release them. Writes and new transactions wait while the
+// files are swapped.
+func (e *Engine) Compact() (CompactStats, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.db == nil {
+		return CompactStats{}, fmt.Errorf("engine closed")
+	}
+
+	e.statMap.Add(tsdb.StatCompact, 1)
+	start := time.Now()
+	stats, err := e.compact()
+	e.statMap.AddFloat(statCompactDuration, time.Since(start).Seconds())
+	if err != nil {
+		e.statMap.Add(statCompactFail, 1)
+		return stats, err
+	}
+	e.statMap.Add(statCompactBytesSaved, stats.BytesSaved())
+
+	return stats, nil
+}
+
+// compact runs a compaction of the open data file. Must be called with e.mu held.
+func (e *Engine) compact() (CompactStats, error) {
+	stats := CompactStats{SizeBefore: fileSize(e.path)}
+
+	if err := e.rewriteSeries(&stats); err != nil {
+		return stats, err
+	}
+
+	// Only copy the file if enough of it can be reclaimed.
+	if ratio := freeRatio(e.db, e.path); ratio < e.CompactThreshold {
+		stats.SizeAfter = fileSize(e.path)
+		return stats, nil
+	}
+
+	// Block writes and new transactions while the file is swapped.
+	e.compactMu.Lock()
+	defer e.compactMu.Unlock()
+
+	for deadline := time.Now().Add(compactTxTimeout); atomic.LoadInt64(&e.txN) > 0; {
+		if time.Now().After(deadline) {
+			stats.SizeAfter = fileSize(e.path)
+			return stats, ErrCompactTxTimeout
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	tmpPath := e.path + "." + CompactExtension
+	if err := copyFile(e.db, tmpPath); err != nil {
+		return stats, err
+	}
+
+	// Keep a link to the current file so it can be put back if the
+	// compacted file can't be opened. The old handle stays open until then.
+	oldPath := tmpPath + ".old"
+	os.Remove(oldPath)
+	if err := os.Link(e.path, oldPath); err != nil {
+		os.Remove(tmpPath)
+		return stats, err
+	}
+	defer os.Remove(oldPath)
+
+	if err := os.Rename(tmpPath, e.path); err != nil {
+		os.Remove(tmpPath)
+		return stats, err
+	}
+
+	db, err := bolt.Open(e.path, 0666, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		if rerr := os.Rename(oldPath, e.path); rerr != nil {
+			return stats, fmt.Errorf("reopen: %s, restore: %s", err, rerr)
+		}
+		stats.SizeAfter = fileSize(e.path)
+		return stats, fmt.Errorf("reopen: %s", err)
+	}
+	e.db.Close()
+	e.db = db
+
+	stats.SizeAfter = fileSize(e.path)
+	tsdb.SetStatInt(e.statMap, tsdb.StatDiskBytes, stats.SizeAfter)
+	return stats, nil
+}
+
+// freeRatio returns the fraction of the data file at path held by free pages.
+func freeRatio(db *bolt.DB, path string) float64 {
+	sz := fileSize(path)
+	if sz == 0 {
+		return 0
+	}
+	return float64(db.Stats().FreeAlloc) / float64(sz)
+}
+
+// rewriteSeries rewrites each fragmented series bucket into full blocks.
+func (e *Engine) rewriteSeries(stats *CompactStats) error {
+	// Find fragmented series in a read transaction so that series that don't
+	// need a rewrite don't cost a write transaction each.
+	var keys []string
+	if err := e.view(func(tx *bolt.Tx) error {
+		points := tx.Bucket([]byte("points"))
+		return points.ForEach(func(k, _ []byte) error {
+			bkt := points.Bucket(k)
+			if bkt == nil {
+				return nil
+			}
+			if _, fragmented, err := e.fragmented(bkt); err != nil {
+				return fmt.Errorf("key=%x, err=%s", k, err)
+			} else if fragmented {
+				keys = append(keys, string(k))
+			}
+			return nil
+		})
+	}); err != nil {
+		return fmt.Errorf("rewrite series: %s", err)
+	}
+
+	setFloat(e.statMap, statCompactProgress, 0)
+	for i, key := range keys {
+		if err := e.update(func(tx *bolt.Tx) error {
+			return e.rewriteSeriesBucket(tx, key, stats)
+		}); err != nil {
+			return fmt.Errorf("rewrite series: key=%x, err=%s", key, err)
+		}
+		setFloat(e.statMap, statCompactProgress, float64(i+1)/float64(len(keys))*100)
+	}
+	setFloat(e.statMap, statCompactProgress, 100)
+
+	return nil
+}
+
+// fragmented returns the number of blocks in a series bucket and whether it's
+// fragmented. Blocks are written until they reach the block size, so a series
+// is fragmented if any block other than the last is smaller than that.
+func (e *Engine) fragmented(bkt *bolt.Bucket) (n int, fragmented bool, err error) {
+	var small bool
+	err = bkt.ForEach(func(_, v []byte) error {
+		sz, err := snappy.DecodedLen(v[8:])
+		if err != nil {
+			return fmt.Errorf("decode block length: %s", err)
+		}
+		n++
+		fragmented = fragmented || small
+		small = sz < e.BlockSize
+		return nil
+	})
+	return n, fragmented, err
+}
+
+// rewriteSeriesBucket rewrites a series bucket into full blocks if it's fragmented.
+func (e *Engine) rewriteSeriesBucket(tx *bolt.Tx, key string, stats *CompactStats) error {
+	bkt := tx.Bucket([]byte("points")).Bucket([]byte(key))
+	if bkt == nil {
+		return nil
+	}
+
+	n, fragmented, err := e.fragmented(bkt)
+	if err != nil {
+		return err
+	} else if !fragmented {
+		return nil
+	}
+
+	// Read all entries and remove the blocks.
+	var a, blocks [][]byte
+	c := bkt.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		buf, err := snappy.Decode(nil, v[8:])
+		if err != nil {
+			return fmt.Errorf("decode block: %s", err)
+		}
+		a = append(a, SplitEntries(buf)...)
+		blocks = append(blocks, append([]byte(nil), k...))
+	}
+	for _, k := range blocks {
+		if err := bkt.Delete(k); err != nil {
+			return err
+		}
+	}
+
+	bkt.FillPercent = 1.0
+	if err := e.writeBlocks(bkt, a); err != nil {
+		return fmt.Errorf("rewrite blocks: %s", err)
+	}
+
+	var after int
+	for k, _ := c.First(); k != nil; k, _ = c.Next() {
+		after++
+	}
+	stats.SeriesN++
+	stats.BlocksBefore += n
+	stats.BlocksAfter += after
+	e.statMap.Add(statCompactSeries, 1)
+	e.statMap.Add(statCompactBlocksBefore, int64(n))
+	e.statMap.Add(statCompactBlocksAfter, int64(after))
+
+	return nil
+}
+
+// CompactFile compacts the data file of a closed bz1 engine at path. The file
+// is always copied regardless of how much of it is free.
+func CompactFile(path string, blockSize int) (CompactStats, error) {
+	db, err := bolt.Open(path, 0666, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return CompactStats{}, err
+	}
+
+	// Verify the file format.
+	var format string
+	if err := db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte("meta")); b != nil {
+			format = string(b.Get([]byte("format")))
+		}
+		return nil
+	}); err != nil {
+		db.Close()
+		return CompactStats{}, err
+	} else if format != Format {
+		db.Close()
+		return CompactStats{}, fmt.Errorf("invalid format: %q", format)
+	}
+
+	e := &Engine{
+		path:      path,
+		db:        db,
+		statMap:   new(expvar.Map).Init(),
+		BlockSize: blockSize,
+	}
+	stats, err := e.compact()
+	if e.db != nil {
+		e.db.Close()
+	}
+	return stats, err
+}
+
+// copyFile copies every bucket in db into a new file at path.
+func copyFile(db *bolt.DB, path string) error {
+	os.Remove(path)
+	dst, err := bolt.Open(path, 0666, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return err
+	}
+
+	if err := db.View(func(src *bolt.Tx) error {
+		return dst.Update(func(tx *bolt.Tx) error {
+			return src.ForEach(func(name []byte, b *bolt.Bucket) error {
+				bkt, err := tx.CreateBucket(name)
+				if err != nil {
+					return err
+				}
+				return copyBucket(bkt, b)
+			})
+		})
+	}); err != nil {
+		dst.Close()
+		os.Remove(path)
+		return fmt.Errorf("copy: %s", err)
+	}
+
+	return dst.Close()
+}
+
+// copyBucket copies the keys and nested buckets of src into dst.
+func copyBucket(dst, src *bolt.Bucket) error {
+	// Keys are inserted in order so pages can be filled.
+	dst.FillPercent = 1.0
+
+	return src.ForEach(func(k, v []byte) error {
+		if v != nil {
+			return dst.Put(k, v)
+		}
+
+		bkt, err := dst.CreateBucket(k)
+		if err != nil {
+			return err
+		}
+		return copyBucket(bkt, src.Bucket(k))
+	})
+}
+
+// fileSize returns the size of the file at path, or zero if it can't be read.
+func fileSize(path string) int64 {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return fi.Size()
+}
+
+// setFloat sets a float statistic to v.
+func setFloat(m *expvar.Map, key string, v float64) {
+	f := new(expvar.Float)
+	f.Set(v)
+	m.Set(key, f)
+}
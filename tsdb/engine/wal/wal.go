@@ -17,6 +17,18 @@ The WAL does not flush everything in a partition when it comes time. It will
 only flush series that are over a given threshold (32kb by default). The rest
 will be written into a new segment file so they can be flushed later. This
 is like a compaction in an LSM Tree.
+
+Each block in a segment file starts with an 8 byte length. Blocks written by
+this version set the top two bytes of the length to ChecksumSequence and are
+followed by the number of entries in the block and a CRC-32 of the entry count
+and compressed data:
+
+	| 0xFF 0xFE | length (6) | entry count (4) | crc32 (4) | snappy block |
+
+When a WAL is opened, blocks that fail their checksum are skipped, a partially
+written block at the end of a file is truncated, and files whose block headers
+can't be read are renamed with the QuarantineExtension and set aside. The
+damage found is reported by Recoveries.
 */
 package wal
 
@@ -27,6 +39,7 @@ import (
 	"errors"
 	"expvar"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"log"
 	"os"
@@ -56,6 +69,9 @@ const (
 	// CompactionExtension is the file extension we expect for compaction files
 	CompactionExtension = "CPT"
 
+	// QuarantineExtension is appended to segment files that are too damaged to be recovered
+	QuarantineExtension = "quarantine"
+
 	// MetaFlushInterval is the period after which any compressed meta data in the .meta file will get
 	// flushed to the index
 	MetaFlushInterval = 10 * time.Minute
@@ -85,6 +101,13 @@ const (
 	statFlushDuration  = "flush_duration"
 	statWriteFail      = "write_fail"
 	statMemorySize     = "mem_size"
+	statFsync          = "fsync"
+	statFsyncDuration  = "fsync_duration"
+	statFsyncFail      = "fsync_fail"
+	statCorruptBlocks  = "corrupt_blocks"
+	statLostEntries    = "lost_entries"
+	statTruncatedBytes = "truncated_bytes"
+	statQuarantined    = "quarantined_files"
 )
 
 // flushType indiciates why a flush and compaction are being run so the partition can
@@ -118,8 +141,22 @@ var (
 	// CompactSequence is the byte sequence within a segment file that has been compacted
 	// that indicates the start of a compaction marker
 	CompactSequence = []byte{0xFF, 0xFF}
+
+	// ChecksumSequence is the byte sequence at the start of a block length that indicates
+	// the block has an entry count and checksum header
+	ChecksumSequence = []byte{0xFF, 0xFE}
+
+	// errCorruptBlock is returned when a block fails its checksum and was skipped
+	errCorruptBlock = errors.New("corrupt block")
+
+	// errCorruptSegment is returned when a block header can't be read and the rest
+	// of the segment file can't be recovered
+	errCorruptSegment = errors.New("corrupt segment")
 )
 
+// blockHeaderSize is the size of the header preceding each checksummed block
+const blockHeaderSize = 16
+
 type Log struct {
 	path string
 
@@ -170,6 +207,22 @@ type Log struct {
 	// LoggingEnabled specifies if detailed logs should be output
 	LoggingEnabled bool
 
+	// EngineStatMap receives the common engine statistics of flushes and
+	// partition sizes, if set.
+	EngineStatMap *expvar.Map
+
+	// Fsync is the durability mode for segment writes. One of tsdb.WALFsyncAlways,
+	// tsdb.WALFsyncInterval or tsdb.WALFsyncNone.
+	Fsync string
+
+	// FsyncInterval is how often writes are group-committed to disk when Fsync
+	// is tsdb.WALFsyncInterval.
+	FsyncInterval time.Duration
+
+	// resolver determines which point is kept when points are written to a
+	// series at the same timestamp. A nil resolver keeps the last one.
+	resolver *tsdb.DuplicateResolver
+
 	// expvar-based statistics
 	statMap *expvar.Map
 }
@@ -200,6 +253,8 @@ func NewLog(path string) *Log {
 		CompactionThreshold:    tsdb.DefaultCompactionThreshold,
 		PartitionSizeThreshold: tsdb.DefaultPartitionSizeThreshold,
 		ReadySeriesSize:        tsdb.DefaultReadySeriesSize,
+		Fsync:                  tsdb.DefaultWALFsync,
+		FsyncInterval:          tsdb.DefaultWALFsyncInterval,
 		flushCheckInterval:     defaultFlushCheckInterval,
 		logger:                 log.New(os.Stderr, "[wal] ", log.LstdFlags),
 		statMap:                influxdb.NewStatistics(key, "wal", tags),
@@ -212,6 +267,10 @@ func (l *Log) Open() error {
 	if l.LoggingEnabled {
 		l.logger.Printf("WAL starting with %d ready series size, %0.2f compaction threshold, and %d partition size threshold\n", l.ReadySeriesSize, l.CompactionThreshold, l.PartitionSizeThreshold)
 		l.logger.Printf("WAL writing to %s\n", l.path)
+		l.logger.Printf("WAL fsync mode is %s\n", l.Fsync)
+	}
+	if l.Fsync == tsdb.WALFsyncInterval && l.FsyncInterval <= 0 {
+		return fmt.Errorf("invalid fsync interval: %s", l.FsyncInterval)
 	}
 	if err := os.MkdirAll(l.path, 0777); err != nil {
 		return err
@@ -228,6 +287,8 @@ func (l *Log) Open() error {
 		return err
 	}
 	p.log = l
+	p.fsync = l.Fsync
+	p.resolver = l.resolver
 	l.partition = p
 	if err := l.openPartitionFile(); err != nil {
 		return err
@@ -240,6 +301,11 @@ func (l *Log) Open() error {
 	l.closing = make(chan struct{})
 	go l.autoflusher(l.closing)
 
+	if l.Fsync == tsdb.WALFsyncInterval {
+		l.wg.Add(1)
+		go l.groupCommitter(l.closing)
+	}
+
 	return nil
 }
 
@@ -275,6 +341,20 @@ func (l *Log) WritePoints(points []models.Point, fields map[string]*tsdb.Measure
 	return l.partition.Write(points)
 }
 
+// SetDuplicateResolver sets how points written to a series at the timestamp of
+// a cached point are resolved.
+func (l *Log) SetDuplicateResolver(r *tsdb.DuplicateResolver) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.resolver = r
+	if l.partition != nil {
+		l.partition.mu.Lock()
+		l.partition.resolver = r
+		l.partition.mu.Unlock()
+	}
+}
+
 // Flush will force a flush on all paritions
 func (l *Log) Flush() error {
 	l.statMap.Add(statFlush, 1)
@@ -444,6 +524,9 @@ func (l *Log) writeSeriesAndFields(fields map[string]*tsdb.MeasurementFields, se
 		return err
 	}
 
+	if l.Fsync == tsdb.WALFsyncNone {
+		return nil
+	}
 	return l.metaFile.Sync()
 }
 
@@ -514,8 +597,9 @@ func (l *Log) openPartitionFile() error {
 	if l.LoggingEnabled && len(fileNames) > 0 {
 		l.logger.Println("reading WAL files to flush to index")
 	}
+	rec := &Recovery{Path: l.path}
 	for _, n := range fileNames {
-		entries, err := l.partition.readFile(n)
+		entries, quarantined, err := l.partition.readFile(n, rec)
 		if err != nil {
 			return err
 		}
@@ -531,11 +615,24 @@ func (l *Log) openPartitionFile() error {
 		if err := l.Index.WriteIndex(seriesToFlush, nil, nil); err != nil {
 			return err
 		}
+		if quarantined {
+			continue
+		}
 		if err := os.Remove(n); err != nil {
 			return err
 		}
 	}
 
+	if rec.damaged() {
+		l.logger.Printf("WAL recovery of %s: %d corrupt blocks, %d lost entries, %d truncated bytes, %d quarantined files\n",
+			l.path, rec.CorruptBlocks, rec.LostEntries, rec.TruncatedBytes, len(rec.QuarantinedFiles))
+		l.statMap.Add(statCorruptBlocks, int64(rec.CorruptBlocks))
+		l.statMap.Add(statLostEntries, int64(rec.LostEntries))
+		l.statMap.Add(statTruncatedBytes, rec.TruncatedBytes)
+		l.statMap.Add(statQuarantined, int64(len(rec.QuarantinedFiles)))
+		addRecovery(rec)
+	}
+
 	return nil
 }
 
@@ -620,6 +717,26 @@ func (l *Log) autoflusher(closing chan struct{}) {
 	}
 }
 
+// groupCommitter syncs the partition's pending writes to disk every FsyncInterval.
+// This method runs in a separate goroutine.
+func (l *Log) groupCommitter(closing chan struct{}) {
+	defer l.wg.Done()
+
+	ticker := time.NewTicker(l.FsyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-closing:
+			// Release any writes still waiting on a sync.
+			l.partition.groupCommit()
+			return
+		case <-ticker.C:
+			l.partition.groupCommit()
+		}
+	}
+}
+
 // flushMetadata will write start a new metafile for writes to go through and then flush all
 // metadata from previous files to the index. After a sucessful write, the metadata files
 // will be removed. While the flush to index is happening we aren't blocked for new metadata writes.
@@ -737,6 +854,14 @@ type Partition struct {
 	log     *Log
 	statMap *expvar.Map
 
+	// fsync is the durability mode for segment writes. When it is tsdb.WALFsyncInterval,
+	// pendingSync holds the writes waiting on the next group commit.
+	fsync       string
+	pendingSync *syncGroup
+
+	// resolver resolves points written to a series at the timestamp of a cached point.
+	resolver *tsdb.DuplicateResolver
+
 	// Used for mocking OS calls
 	os struct {
 		OpenCompactionFile func(name string, flag int, perm os.FileMode) (file *os.File, err error)
@@ -749,6 +874,7 @@ type Partition struct {
 	// without allocating
 	buf       []byte
 	snappybuf []byte
+	header    [blockHeaderSize]byte
 }
 
 const partitionBufLen = 16 << 10 // 16kb
@@ -789,9 +915,10 @@ func (p *Partition) Close() error {
 
 	p.cache = nil
 	if p.currentSegmentFile == nil {
+		p.releasePendingSync(nil)
 		return nil
 	}
-	if err := p.currentSegmentFile.Close(); err != nil {
+	if err := p.closeSegmentFile(); err != nil {
 		return err
 	}
 	p.currentSegmentFile = nil
@@ -801,8 +928,23 @@ func (p *Partition) Close() error {
 
 // Write will write a compressed block of the points to the current segment file. If the segment
 // file is larger than the max size, it will roll over to a new file before performing the write.
-// This method will also add the points to the in memory cache
+// This method will also add the points to the in memory cache. Depending on the fsync mode it
+// returns once the write has been synced to disk, once the next group commit completes, or
+// immediately.
 func (p *Partition) Write(points []models.Point) error {
+	g, err := p.write(points)
+	if err != nil || g == nil {
+		return err
+	}
+
+	// Wait for the group commit covering this write.
+	<-g.done
+	return g.err
+}
+
+// write appends the points to the current segment file. It returns the sync group
+// to wait on if the write will be synced by a later group commit.
+func (p *Partition) write(points []models.Point) (*syncGroup, error) {
 
 	// Check if we should compact due to memory pressure and if we should fail the write if
 	// we're way too far over the threshold.
@@ -822,7 +964,7 @@ func (p *Partition) Write(points []models.Point) error {
 		go p.flushAndCompact(memoryFlush)
 	} else if shouldFailWrite {
 		p.statMap.Add(statWriteFail, 1)
-		return fmt.Errorf("write throughput too high. backoff and retry")
+		return nil, fmt.Errorf("write throughput too high. backoff and retry")
 	}
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -851,23 +993,31 @@ func (p *Partition) Write(points []models.Point) error {
 		if p.currentSegmentFile == nil || p.currentSegmentSize > p.maxSegmentSize {
 			err := p.newSegmentFile()
 			if err != nil {
-				return err
+				return nil, err
 			}
 		}
 
-		if n, err := p.currentSegmentFile.Write(u64tob(uint64(len(b)))); err != nil {
-			return err
-		} else if n != 8 {
-			return fmt.Errorf("expected to write %d bytes but wrote %d", 8, n)
+		// write the block header: the length with the checksum marker, the entry count and
+		// a checksum of the count and compressed block
+		header := p.header[:]
+		binary.BigEndian.PutUint64(header[0:8], uint64(len(b)))
+		copy(header[0:2], ChecksumSequence)
+		binary.BigEndian.PutUint32(header[8:12], uint32(len(marshaledPoints)))
+		binary.BigEndian.PutUint32(header[12:16], blockChecksum(header[8:12], b))
+
+		if n, err := p.currentSegmentFile.Write(header); err != nil {
+			return nil, err
+		} else if n != len(header) {
+			return nil, fmt.Errorf("expected to write %d bytes but wrote %d", len(header), n)
 		}
 
 		if n, err := p.currentSegmentFile.Write(b); err != nil {
-			return err
+			return nil, err
 		} else if n != len(b) {
-			return fmt.Errorf("expected to write %d bytes but wrote %d", len(b), n)
+			return nil, fmt.Errorf("expected to write %d bytes but wrote %d", len(b), n)
 		}
 
-		p.currentSegmentSize += int64(8 + len(b))
+		p.currentSegmentSize += int64(blockHeaderSize + len(b))
 		p.lastWriteTime = time.Now()
 
 		for _, pp := range marshaledPoints {
@@ -875,14 +1025,79 @@ func (p *Partition) Write(points []models.Point) error {
 		}
 	}
 
-	return p.currentSegmentFile.Sync()
+	switch p.fsync {
+	case tsdb.WALFsyncNone:
+		return nil, nil
+	case tsdb.WALFsyncInterval:
+		if p.pendingSync == nil {
+			p.pendingSync = &syncGroup{done: make(chan struct{})}
+		}
+		return p.pendingSync, nil
+	default:
+		return nil, p.syncSegmentFile()
+	}
+}
+
+// syncSegmentFile syncs the current segment file to disk and records the latency.
+// Must be called with p.mu held.
+func (p *Partition) syncSegmentFile() error {
+	if p.currentSegmentFile == nil {
+		return nil
+	}
+
+	startTime := time.Now()
+	err := p.currentSegmentFile.Sync()
+	p.statMap.Add(statFsync, 1)
+	p.statMap.AddFloat(statFsyncDuration, time.Since(startTime).Seconds())
+	if err != nil {
+		p.statMap.Add(statFsyncFail, 1)
+	}
+	return err
+}
+
+// groupCommit syncs the current segment file and acknowledges every write waiting
+// on it. The lock is held during the sync so the segment can't be rotated out from
+// under it; writes arriving meanwhile join the next group.
+func (p *Partition) groupCommit() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.pendingSync == nil {
+		return
+	}
+	p.releasePendingSync(p.syncSegmentFile())
+}
+
+// releasePendingSync acknowledges the writes waiting on the current sync group with
+// the given error. Must be called with p.mu held.
+func (p *Partition) releasePendingSync(err error) {
+	if p.pendingSync == nil {
+		return
+	}
+	p.pendingSync.err = err
+	close(p.pendingSync.done)
+	p.pendingSync = nil
+}
+
+// closeSegmentFile closes the current segment file. If writes are waiting on a group
+// commit the file is synced first and those writes are acknowledged. Must be called
+// with p.mu held.
+func (p *Partition) closeSegmentFile() error {
+	if p.pendingSync != nil {
+		err := p.syncSegmentFile()
+		p.releasePendingSync(err)
+		if err != nil {
+			return err
+		}
+	}
+	return p.currentSegmentFile.Close()
 }
 
 // newSegmentFile will close the current segment file and open a new one, updating bookkeeping info on the partition
 func (p *Partition) newSegmentFile() error {
 	p.currentSegmentID += 1
 	if p.currentSegmentFile != nil {
-		if err := p.currentSegmentFile.Close(); err != nil {
+		if err := p.closeSegmentFile(); err != nil {
 			return err
 		}
 	}
@@ -972,7 +1187,7 @@ func (p *Partition) prepareSeriesToFlush(readySeriesSize int, flush flushType) (
 	if flush == idleFlush {
 		// don't create a new segment file because this partition is idle
 		if p.currentSegmentFile != nil {
-			if err := p.currentSegmentFile.Close(); err != nil {
+			if err := p.closeSegmentFile(); err != nil {
 				return nil, err
 			}
 		}
@@ -1036,6 +1251,10 @@ func (p *Partition) flushAndCompact(flush flushType) error {
 
 	writeDuration := time.Since(startTime)
 	p.statMap.AddFloat(statFlushDuration, writeDuration.Seconds())
+	if m := p.engineStatMap(); m != nil {
+		m.Add(tsdb.StatWALFlush, 1)
+		m.AddFloat(tsdb.StatWALFlushDuration, writeDuration.Seconds())
+	}
 	if p.log.LoggingEnabled {
 		p.log.logger.Printf("write to index of partition %d took %s\n", p.id, writeDuration)
 	}
@@ -1045,7 +1264,7 @@ func (p *Partition) flushAndCompact(flush flushType) error {
 	p.flushCache = nil
 	p.memorySize -= uint64(c.flushSize)
 	p.mu.Unlock()
-	p.statMap.Add(statMemorySize, -int64(c.flushSize))
+	p.addMemorySize(-int64(c.flushSize))
 
 	// ensure that we mark that compaction is no longer running
 	defer func() {
@@ -1100,12 +1319,19 @@ func (p *Partition) recoverCompactionFile() error {
 	defer f.Close()
 
 	// Iterate through all named blocks.
-	sf := newSegment(f, p.log.logger)
+	sf, err := newSegment(f, p.log.logger)
+	if err != nil {
+		return err
+	}
 	var hasData bool
 	for {
 		// Only read named blocks.
 		name, a, err := sf.readCompressedBlock()
-		if err != nil {
+		if err == errCorruptBlock {
+			continue
+		} else if err == errCorruptSegment {
+			break
+		} else if err != nil {
 			return fmt.Errorf("read name block: %s", err)
 		} else if name == "" && a == nil {
 			break // eof
@@ -1114,7 +1340,9 @@ func (p *Partition) recoverCompactionFile() error {
 		}
 
 		// Read data for the named block.
-		if s, entries, err := sf.readCompressedBlock(); err != nil {
+		if s, entries, err := sf.readCompressedBlock(); err == errCorruptBlock || err == errCorruptSegment {
+			break
+		} else if err != nil {
 			return fmt.Errorf("read data block: %s", err)
 		} else if s != "" {
 			return fmt.Errorf("unexpected double name block")
@@ -1158,21 +1386,33 @@ func (p *Partition) recoverCompactionFile() error {
 	return nil
 }
 
-// readFile will read a segment file and marshal its entries into the cache
-func (p *Partition) readFile(path string) (entries []*entry, err error) {
+// readFile will read a segment file and marshal its entries into the cache. Corrupt blocks
+// are skipped and a partially written block at the end of the file is truncated. If the
+// file can't be read past a damaged block header, the entries before it are returned and
+// the file is quarantined. Any damage found is added to rec.
+func (p *Partition) readFile(path string, rec *Recovery) (entries []*entry, quarantined bool, err error) {
 	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0666)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
-	sf := newSegment(f, p.log.logger)
+	sf, err := newSegment(f, p.log.logger)
+	if err != nil {
+		f.Close()
+		return nil, false, err
+	}
 	for {
 		name, a, err := sf.readCompressedBlock()
 		if name != "" {
 			continue // skip name blocks
+		} else if err == errCorruptBlock {
+			continue // skip blocks that fail their checksum
+		} else if err == errCorruptSegment {
+			quarantined = true
+			break
 		} else if err != nil {
 			f.Close()
-			return nil, err
+			return nil, false, err
 		} else if a == nil {
 			break
 		}
@@ -1180,21 +1420,65 @@ func (p *Partition) readFile(path string) (entries []*entry, err error) {
 		entries = append(entries, a...)
 	}
 
+	rec.CorruptBlocks += sf.corruptBlocks
+	rec.LostEntries += sf.lostEntries
+	rec.TruncatedBytes += sf.truncatedBytes
+
 	if err := f.Close(); err != nil {
-		return nil, err
+		return nil, false, err
+	}
+
+	if quarantined {
+		newpath := fmt.Sprintf("%s.%s", path, QuarantineExtension)
+		if err := os.Rename(path, newpath); err != nil {
+			return nil, false, fmt.Errorf("quarantine segment: %s", err)
+		}
+		p.log.logger.Printf("quarantined unreadable segment file %s to %s\n", path, newpath)
+		rec.QuarantinedFiles = append(rec.QuarantinedFiles, newpath)
 	}
 	return
 }
 
+// engineStatMap returns the statistics map of the log's engine, if any.
+func (p *Partition) engineStatMap() *expvar.Map {
+	if p.log == nil {
+		return nil
+	}
+	return p.log.EngineStatMap
+}
+
+// addMemorySize adds n bytes to the partition's memory size statistics.
+func (p *Partition) addMemorySize(n int64) {
+	p.statMap.Add(statMemorySize, n)
+	if m := p.engineStatMap(); m != nil {
+		m.Add(tsdb.StatWALPartitionSize(p.id), n)
+	}
+}
+
 // addToCache will marshal the entry and add it to the in memory cache. It will also mark if this key will need sorting later
 func (p *Partition) addToCache(key, data []byte, timestamp int64) {
 	// Generate in-memory cache entry of <timestamp,data>.
 	v := MarshalEntry(timestamp, data)
-	p.memorySize += uint64(len(v))
-	p.statMap.Add(statMemorySize, int64(len(v)))
 	keystr := string(key)
 
 	entry := p.cache[keystr]
+
+	// Resolve a point written at the timestamp of a cached point in place.
+	if entry != nil && p.resolver != nil {
+		if i := entry.indexOf(v[0:8]); i != -1 {
+			existing := entry.points[i]
+			v = MarshalEntry(timestamp, p.resolver.Resolve(keystr, existing[8:], data))
+			entry.points[i] = v
+			entry.size += len(v) - len(existing)
+			p.memorySize = p.memorySize + uint64(len(v)) - uint64(len(existing))
+			p.addMemorySize(int64(len(v) - len(existing)))
+			return
+		}
+	}
+
+	p.memorySize += uint64(len(v))
+	p.addMemorySize(int64(len(v)))
+
 	if entry == nil {
 		entry = &cacheEntry{
 			points: [][]byte{v},
@@ -1231,13 +1515,13 @@ func (p *Partition) cursor(series string, fields []string, dec *tsdb.FieldCodec,
 			copy(c, fc)
 			c = append(c, entry.points...)
 
-			dedupe := tsdb.DedupeEntries(c)
+			dedupe := p.resolver.DedupeEntries(series, c)
 			return newCursor(dedupe, fields, dec, ascending)
 		}
 	}
 
 	if entry.isDirtySort {
-		entry.points = tsdb.DedupeEntries(entry.points)
+		entry.points = p.resolver.DedupeEntries(series, entry.points)
 		entry.isDirtySort = false
 	}
 
@@ -1287,46 +1571,71 @@ type compactionInfo struct {
 	countCompacting      int
 }
 
+// syncGroup is a set of writes waiting on the same group commit. done is closed
+// once the sync has completed and err is set.
+type syncGroup struct {
+	done chan struct{}
+	err  error
+}
+
 // segmentFile is a struct for reading in segment files from the WAL. Used on startup only while loading
 type segment struct {
 	f      *os.File
 	block  []byte
 	length []byte
-	size   int64
+	header []byte
 	logger *log.Logger
+
+	// size is the size of the file and offset is the position of the block being read
+	size   int64
+	offset int64
+
+	// damage found while reading the file
+	corruptBlocks  int
+	lostEntries    int
+	truncatedBytes int64
 }
 
-func newSegment(f *os.File, l *log.Logger) *segment {
+func newSegment(f *os.File, l *log.Logger) (*segment, error) {
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
 	return &segment{
 		length: make([]byte, 8),
+		header: make([]byte, blockHeaderSize-8),
 		f:      f,
+		size:   st.Size(),
 		logger: l,
-	}
+	}, nil
 }
 
 // readCompressedBlock will read the next compressed block from the file and marshal the entries.
-// if we've hit the end of the file or corruption the entry array will be nil
+// if we've hit the end of the file the entry array will be nil. A partially written block at the
+// end of the file is truncated and treated as the end of the file. errCorruptBlock is returned if
+// the block failed its checksum and was skipped, and errCorruptSegment if the block header is
+// unreadable and the rest of the file can't be recovered.
 func (s *segment) readCompressedBlock() (name string, entries []*entry, err error) {
-	blockSize := int64(0)
-
-	n, err := s.f.Read(s.length)
-	if err == io.EOF {
+	if _, err := io.ReadFull(s.f, s.length); err == io.EOF {
 		return "", nil, nil
+	} else if err == io.ErrUnexpectedEOF {
+		return "", nil, s.truncate("unable to read the size of a data block")
 	} else if err != nil {
 		return "", nil, fmt.Errorf("read length: %s", err)
-	} else if n != len(s.length) {
-		// seek back before this length so we can start overwriting the file from here
-		s.logger.Println("unable to read the size of a data block from file:", s.f.Name())
-		s.f.Seek(-int64(n), 1)
-		return "", nil, nil
 	}
-	blockSize += int64(n)
 
 	// Compacted WAL files will have a magic byte sequence that indicate the next part is a file name
 	// instead of a compressed block. We can ignore these bytes and the ensuing file name to get to the next block.
+	// Blocks written with a checksum have their own magic byte sequence. Anything else in the top
+	// two bytes means the length itself is damaged and we can't find the next block.
 	isCompactionFileNameBlock := bytes.Equal(s.length[0:2], CompactSequence)
-	if isCompactionFileNameBlock {
+	isChecksummed := bytes.Equal(s.length[0:2], ChecksumSequence)
+	if isCompactionFileNameBlock || isChecksummed {
 		s.length[0], s.length[1] = 0x00, 0x00
+	} else if s.length[0] != 0x00 || s.length[1] != 0x00 {
+		s.logger.Printf("corrupt block length at offset %d in file: %s\n", s.offset, s.f.Name())
+		return "", nil, errCorruptSegment
 	}
 
 	dataLength := btou64(s.length)
@@ -1337,46 +1646,65 @@ func (s *segment) readCompressedBlock() (name string, entries []*entry, err erro
 		return "", nil, nil
 	}
 
-	if len(s.block) < int(dataLength) {
-		s.block = make([]byte, dataLength)
+	headerLength := int64(len(s.length))
+	var count, checksum uint32
+	if isChecksummed {
+		if _, err := io.ReadFull(s.f, s.header); err == io.EOF || err == io.ErrUnexpectedEOF {
+			return "", nil, s.truncate("partial block header")
+		} else if err != nil {
+			return "", nil, fmt.Errorf("read block header: %s", err)
+		}
+		count = binary.BigEndian.Uint32(s.header[0:4])
+		checksum = binary.BigEndian.Uint32(s.header[4:8])
+		headerLength += int64(len(s.header))
 	}
 
-	n, err = s.f.Read(s.block[:dataLength])
-	if err != nil {
-		return "", nil, fmt.Errorf("read block: %s", err)
+	// a block extending past the end of the file was only partially written
+	if s.offset+headerLength+int64(dataLength) > s.size {
+		return "", nil, s.truncate("partial compressed block")
 	}
-	blockSize += int64(n)
 
-	// read the compressed block and decompress it. if partial or corrupt,
-	// overwrite with zeroes so we can start over on this wal file
-	if n != int(dataLength) {
-		s.logger.Println("partial compressed block in file:", s.f.Name())
-
-		// seek back to before this block and its size so we can overwrite the corrupt data
-		s.f.Seek(-int64(len(s.length)+n), 1)
-		if err := s.f.Truncate(s.size); err != nil {
-			return "", nil, fmt.Errorf("truncate(0): sz=%d, err=%s", s.size, err)
-		}
+	if len(s.block) < int(dataLength) {
+		s.block = make([]byte, dataLength)
+	}
 
-		return "", nil, nil
+	if _, err := io.ReadFull(s.f, s.block[:dataLength]); err == io.EOF || err == io.ErrUnexpectedEOF {
+		return "", nil, s.truncate("partial compressed block")
+	} else if err != nil {
+		return "", nil, fmt.Errorf("read block: %s", err)
 	}
+	blockOffset := s.offset
+	s.offset += headerLength + int64(dataLength)
 
 	// skip the rest if this is just the filename from a compaction
 	if isCompactionFileNameBlock {
 		return string(s.block[:dataLength]), nil, nil
 	}
 
-	// if there was an error decoding, this is a corrupt block so we zero out the rest of the file
+	// skip checksummed blocks that don't match, the length is still good so we can
+	// carry on reading from the next one
+	if isChecksummed && blockChecksum(s.header[0:4], s.block[:dataLength]) != checksum {
+		s.logger.Printf("checksum mismatch for block at offset %d with %d entries in file: %s\n", blockOffset, count, s.f.Name())
+		s.corruptBlocks++
+		s.lostEntries += int(count)
+		return "", nil, errCorruptBlock
+	}
+
+	// if there was an error decoding, this is a corrupt block. A checksummed block can be
+	// skipped but for older blocks we can't trust the length so we zero out the rest of the file
 	buf, err := snappy.Decode(nil, s.block[:dataLength])
 	if err != nil {
 		s.logger.Println("corrupt compressed block in file:", err.Error(), s.f.Name())
-
-		// go back to the start of this block and zero out the rest of the file
-		s.f.Seek(-int64(len(s.length)+n), 1)
-		if err := s.f.Truncate(s.size); err != nil {
-			return "", nil, fmt.Errorf("truncate(1): sz=%d, err=%s", s.size, err)
+		s.corruptBlocks++
+		if isChecksummed {
+			s.lostEntries += int(count)
+			return "", nil, errCorruptBlock
 		}
 
+		s.offset = blockOffset
+		if err := s.truncate(""); err != nil {
+			return "", nil, err
+		}
 		return "", nil, nil
 	}
 
@@ -1392,11 +1720,92 @@ func (s *segment) readCompressedBlock() (name string, entries []*entry, err erro
 		entries = append(entries, &entry{key: key, data: data, timestamp: timestamp})
 	}
 
-	s.size = blockSize
-
 	return
 }
 
+// truncate removes everything from the start of the current block to the end of the file so
+// new writes can start over from there. The reason is logged if it's not empty.
+func (s *segment) truncate(reason string) error {
+	if reason != "" {
+		s.logger.Printf("%s at offset %d in file: %s\n", reason, s.offset, s.f.Name())
+	}
+
+	if _, err := s.f.Seek(s.offset, 0); err != nil {
+		return fmt.Errorf("seek: offset=%d, err=%s", s.offset, err)
+	}
+	if err := s.f.Truncate(s.offset); err != nil {
+		return fmt.Errorf("truncate: sz=%d, err=%s", s.offset, err)
+	}
+	s.truncatedBytes += s.size - s.offset
+	s.size = s.offset
+
+	return nil
+}
+
+// blockChecksum returns the checksum of a block's entry count and compressed data.
+func blockChecksum(count, block []byte) uint32 {
+	return crc32.Update(crc32.ChecksumIEEE(count), crc32.IEEETable, block)
+}
+
+// Recovery describes the damage found and repaired while recovering a WAL's segment
+// files when it was opened.
+type Recovery struct {
+	Path             string
+	CorruptBlocks    int
+	LostEntries      int
+	TruncatedBytes   int64
+	QuarantinedFiles []string
+}
+
+// damaged returns true if any damage was found.
+func (r *Recovery) damaged() bool {
+	return r.CorruptBlocks > 0 || r.LostEntries > 0 || r.TruncatedBytes > 0 || len(r.QuarantinedFiles) > 0
+}
+
+// recoveries holds the damage found in each WAL opened by this process, by path.
+var recoveries = struct {
+	mu sync.Mutex
+	m  map[string]*Recovery
+}{m: make(map[string]*Recovery)}
+
+// addRecovery adds the damage found in a WAL to the totals for its path.
+func addRecovery(r *Recovery) {
+	recoveries.mu.Lock()
+	defer recoveries.mu.Unlock()
+
+	total := recoveries.m[r.Path]
+	if total == nil {
+		total = &Recovery{Path: r.Path}
+		recoveries.m[r.Path] = total
+	}
+	total.CorruptBlocks += r.CorruptBlocks
+	total.LostEntries += r.LostEntries
+	total.TruncatedBytes += r.TruncatedBytes
+	total.QuarantinedFiles = append(total.QuarantinedFiles, r.QuarantinedFiles...)
+}
+
+// Recoveries returns the damage found while opening every WAL that has needed repair
+// since the process started, sorted by path.
+func Recoveries() []*Recovery {
+	recoveries.mu.Lock()
+	defer recoveries.mu.Unlock()
+
+	a := make([]*Recovery, 0, len(recoveries.m))
+	for _, r := range recoveries.m {
+		other := *r
+		other.QuarantinedFiles = append([]string(nil), r.QuarantinedFiles...)
+		a = append(a, &other)
+	}
+	sort.Sort(recoverySlice(a))
+	return a
+}
+
+type recoverySlice []*Recovery
+
+func (a recoverySlice) Len() int           { return len(a) }
+func (a recoverySlice) Less(i, j int) bool { return a[i].Path < a[j].Path }
+func (a recoverySlice) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+
 // entry is used as a temporary object when reading data from segment files
 type entry struct {
 	key       []byte
@@ -1514,6 +1923,30 @@ type cacheEntry struct {
 	size        int
 }
 
+// indexOf returns the index of the point with the given timestamp, or -1 if
+// there isn't one. The most recently written point is returned if there are several.
+func (e *cacheEntry) indexOf(timestamp []byte) int {
+	// Points after the last one can't be cached yet.
+	if n := len(e.points); n == 0 || bytes.Compare(e.points[n-1][0:8], timestamp) == -1 {
+		return -1
+	}
+
+	if !e.isDirtySort {
+		i := sort.Search(len(e.points), func(i int) bool { return bytes.Compare(e.points[i][0:8], timestamp) != -1 })
+		if i < len(e.points) && bytes.Equal(e.points[i][0:8], timestamp) {
+			return i
+		}
+		return -1
+	}
+
+	for i := len(e.points) - 1; i >= 0; i-- {
+		if bytes.Equal(e.points[i][0:8], timestamp) {
+			return i
+		}
+	}
+	return -1
+}
+
 // marshalWALEntry encodes point data into a single byte slice.
 //
 // The format of the byte slice is:
@@ -2,15 +2,18 @@ package wal
 
 import (
 	"bytes"
+	"expvar"
 	"fmt"
 	"io/ioutil"
 	"math/rand"
 	"os"
 	"reflect"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/influxdb/influxdb/influxql"
+	"github.com/influxdb/influxdb/meta"
 	"github.com/influxdb/influxdb/models"
 	"github.com/influxdb/influxdb/tsdb"
 )
@@ -279,6 +282,144 @@ func TestWAL_CorruptDataBlock(t *testing.T) {
 	}
 }
 
+// Ensure a block that fails its checksum is skipped and the blocks after it are still recovered
+func TestWAL_CorruptChecksum(t *testing.T) {
+	log := openTestWAL()
+	defer log.Close()
+	defer os.RemoveAll(log.path)
+
+	if err := log.Open(); err != nil {
+		t.Fatalf("couldn't open wal: %s", err.Error())
+	}
+
+	codec := tsdb.NewFieldCodec(map[string]*tsdb.Field{
+		"value": {
+			ID:   uint8(1),
+			Name: "value",
+			Type: influxql.Float,
+		},
+	})
+
+	// write three blocks, the first with two entries
+	p1 := parsePoint("cpu,host=A value=23.2 1", codec)
+	p2 := parsePoint("cpu,host=B value=25.3 4", codec)
+	if err := log.WritePoints([]models.Point{p1, p2}, nil, nil); err != nil {
+		t.Fatalf("failed to write points: %s", err.Error())
+	}
+	p3 := parsePoint("cpu,host=A value=29.2 6", codec)
+	if err := log.WritePoints([]models.Point{p3}, nil, nil); err != nil {
+		t.Fatalf("failed to write points: %s", err.Error())
+	}
+	p4 := parsePoint("cpu,host=A value=30.1 8", codec)
+	if err := log.WritePoints([]models.Point{p4}, nil, nil); err != nil {
+		t.Fatalf("failed to write points: %s", err.Error())
+	}
+
+	// flip a byte in the data of the first block
+	f := log.partition.currentSegmentFile
+	name := f.Name()
+	log.Close()
+
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatalf("failed to read segment: %s", err.Error())
+	}
+	b[blockHeaderSize+2] ^= 0xFF
+	if err := ioutil.WriteFile(name, b, 0666); err != nil {
+		t.Fatalf("failed to write segment: %s", err.Error())
+	}
+
+	points := make([]map[string][][]byte, 0)
+	log.Index = &testIndexWriter{fn: func(pointsByKey map[string][][]byte, measurementFieldsToSave map[string]*tsdb.MeasurementFields, seriesToCreate []*tsdb.SeriesCreate) error {
+		points = append(points, pointsByKey)
+		return nil
+	}}
+
+	if err := log.Open(); err != nil {
+		t.Fatalf("couldn't reopen wal: %s", err.Error())
+	}
+	if len(points) != 1 {
+		t.Fatalf("expected one flush but got %d", len(points))
+	} else if p := points[0]; len(p["cpu,host=A"]) != 2 {
+		t.Fatalf("expected two points for cpu,host=A but got %d", len(p["cpu,host=A"]))
+	} else if len(p["cpu,host=B"]) != 0 {
+		t.Fatal("expected no points for cpu,host=B")
+	}
+
+	if v := log.statMap.Get(statCorruptBlocks).String(); v != "1" {
+		t.Fatalf("expected 1 corrupt block but got %s", v)
+	} else if v := log.statMap.Get(statLostEntries).String(); v != "2" {
+		t.Fatalf("expected 2 lost entries but got %s", v)
+	}
+
+	var found bool
+	for _, r := range Recoveries() {
+		if r.Path == log.path {
+			found = true
+			if r.CorruptBlocks != 1 || r.LostEntries != 2 {
+				t.Fatalf("unexpected recovery: %#v", r)
+			}
+		}
+	}
+	if !found {
+		t.Fatal("expected recovery to be reported")
+	}
+}
+
+// Ensure a segment file with an unreadable block header is quarantined and the blocks before it are recovered
+func TestWAL_QuarantineSegment(t *testing.T) {
+	log := openTestWAL()
+	defer log.Close()
+	defer os.RemoveAll(log.path)
+
+	if err := log.Open(); err != nil {
+		t.Fatalf("couldn't open wal: %s", err.Error())
+	}
+
+	codec := tsdb.NewFieldCodec(map[string]*tsdb.Field{
+		"value": {
+			ID:   uint8(1),
+			Name: "value",
+			Type: influxql.Float,
+		},
+	})
+
+	p1 := parsePoint("cpu,host=A value=23.2 1", codec)
+	if err := log.WritePoints([]models.Point{p1}, nil, nil); err != nil {
+		t.Fatalf("failed to write points: %s", err.Error())
+	}
+
+	// write a block length with a bad marker followed by junk
+	f := log.partition.currentSegmentFile
+	name := f.Name()
+	f.Write([]byte{0x12, 0x34, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10})
+	for i := 0; i < 100; i++ {
+		f.Write([]byte{0x23, 0x78, 0x11, 0x33})
+	}
+	f.Sync()
+	log.Close()
+
+	points := make([]map[string][][]byte, 0)
+	log.Index = &testIndexWriter{fn: func(pointsByKey map[string][][]byte, measurementFieldsToSave map[string]*tsdb.MeasurementFields, seriesToCreate []*tsdb.SeriesCreate) error {
+		points = append(points, pointsByKey)
+		return nil
+	}}
+
+	if err := log.Open(); err != nil {
+		t.Fatalf("couldn't reopen wal: %s", err.Error())
+	}
+	if len(points) != 1 || len(points[0]["cpu,host=A"]) != 1 {
+		t.Fatalf("expected one point for cpu,host=A but got %v", points)
+	}
+
+	if _, err := os.Stat(name + "." + QuarantineExtension); err != nil {
+		t.Fatalf("expected segment to be quarantined: %s", err.Error())
+	}
+	if v := log.statMap.Get(statQuarantined).String(); v != "1" {
+		t.Fatalf("expected 1 quarantined file but got %s", v)
+	}
+}
+
 // Ensure the wal forces a full flush after not having a write in a given interval of time
 func TestWAL_CompactAfterTimeWithoutWrite(t *testing.T) {
 	log := openTestWAL()
@@ -347,6 +488,68 @@ func TestWAL_CompactAfterTimeWithoutWrite(t *testing.T) {
 	}
 }
 
+// Ensure writes are acknowledged and synced under each fsync mode.
+func TestWAL_WritePoints_Fsync(t *testing.T) {
+	for _, mode := range []string{tsdb.WALFsyncAlways, tsdb.WALFsyncInterval, tsdb.WALFsyncNone} {
+		func() {
+			log := openTestWAL()
+			log.Fsync = mode
+			log.FsyncInterval = 10 * time.Millisecond
+			defer os.RemoveAll(log.path)
+
+			log.Index = &testIndexWriter{fn: func(pointsByKey map[string][][]byte, measurementFieldsToSave map[string]*tsdb.MeasurementFields, seriesToCreate []*tsdb.SeriesCreate) error {
+				return nil
+			}}
+
+			if err := log.Open(); err != nil {
+				t.Fatalf("%s: couldn't open wal: %s", mode, err.Error())
+			}
+			defer log.Close()
+
+			codec := tsdb.NewFieldCodec(map[string]*tsdb.Field{
+				"value": {
+					ID:   uint8(1),
+					Name: "value",
+					Type: influxql.Float,
+				},
+			})
+
+			p := parsePoint("cpu,host=A value=23.2 1", codec)
+			if err := log.WritePoints([]models.Point{p}, nil, nil); err != nil {
+				t.Fatalf("%s: failed to write points: %s", mode, err.Error())
+			}
+
+			c := log.Cursor("cpu,host=A", []string{"value"}, codec, true)
+			if k, v := c.Next(); k != 1 || v.(float64) != 23.2 {
+				t.Fatalf("%s: unexpected point: %v %v", mode, k, v)
+			}
+
+			// A sync should have been recorded unless syncing is disabled.
+			fsyncs := int64(0)
+			if v := log.statMap.Get(statFsync); v != nil {
+				fsyncs, _ = strconv.ParseInt(v.String(), 10, 64)
+			}
+			if mode == tsdb.WALFsyncNone && fsyncs != 0 {
+				t.Fatalf("%s: expected no fsyncs, got %d", mode, fsyncs)
+			} else if mode != tsdb.WALFsyncNone && fsyncs == 0 {
+				t.Fatalf("%s: expected fsyncs to be recorded", mode)
+			}
+		}()
+	}
+}
+
+// Ensure an interval fsync mode requires a positive interval.
+func TestWAL_Open_InvalidFsyncInterval(t *testing.T) {
+	log := openTestWAL()
+	log.Fsync = tsdb.WALFsyncInterval
+	log.FsyncInterval = 0
+	defer os.RemoveAll(log.path)
+
+	if err := log.Open(); err == nil {
+		t.Fatal("expected error opening wal with zero fsync interval")
+	}
+}
+
 func TestWAL_SeriesAndFieldsGetPersisted(t *testing.T) {
 	log := openTestWAL()
 	defer log.Close()
@@ -662,6 +865,91 @@ func TestWAL_PointsSorted(t *testing.T) {
 	}
 }
 
+// Ensure the cache resolves points written at the same timestamp with the duplicate policy.
+func TestWAL_DuplicatePolicy(t *testing.T) {
+	codec := tsdb.NewFieldCodec(map[string]*tsdb.Field{
+		"value": {ID: uint8(1), Name: "value", Type: influxql.Float},
+		"idle":  {ID: uint8(2), Name: "idle", Type: influxql.Integer},
+	})
+
+	for _, tt := range []struct {
+		policy string
+		exp    map[string]interface{}
+	}{
+		{policy: meta.DuplicatePolicyReplace, exp: map[string]interface{}{"value": 3.3}},
+		{policy: meta.DuplicatePolicyMerge, exp: map[string]interface{}{"value": 3.3, "idle": int64(5)}},
+		{policy: meta.DuplicatePolicyFirst, exp: map[string]interface{}{"value": 1.1, "idle": int64(5)}},
+	} {
+		func() {
+			log := openTestWAL()
+			defer log.Close()
+			defer os.RemoveAll(log.path)
+
+			if err := log.Open(); err != nil {
+				t.Fatalf("couldn't open wal: %s", err.Error())
+			}
+			log.SetDuplicateResolver(&tsdb.DuplicateResolver{
+				Policy:     tt.policy,
+				FieldCodec: func(string) *tsdb.FieldCodec { return codec },
+			})
+
+			// Overwrite the point at 2 after the series is out of order.
+			points := parsePoints("cpu,host=A value=1.1,idle=5i 2\ncpu,host=A value=6.6 6\ncpu,host=A value=2.2 2\ncpu,host=A value=4.4 4\ncpu,host=A value=3.3 2", codec)
+			for _, p := range points {
+				if err := log.WritePoints([]models.Point{p}, nil, nil); err != nil {
+					t.Fatalf("failed to write points: %s", err.Error())
+				}
+			}
+
+			c := log.Cursor("cpu,host=A", []string{"value", "idle"}, codec, true)
+			if k, v := c.Next(); k != 2 || !reflect.DeepEqual(v, tt.exp) {
+				t.Fatalf("%s: unexpected key/value: %d / %v", tt.policy, k, v)
+			} else if k, _ = c.Next(); k != 4 {
+				t.Fatalf("%s: unexpected key: %d", tt.policy, k)
+			} else if k, _ = c.Next(); k != 6 {
+				t.Fatalf("%s: unexpected key: %d", tt.policy, k)
+			} else if k, _ = c.Next(); k != tsdb.EOF {
+				t.Fatalf("%s: unexpected key: %d", tt.policy, k)
+			}
+		}()
+	}
+}
+
+// Ensure flushes and partition sizes are reported in the engine statistics.
+func TestWAL_EngineStatistics(t *testing.T) {
+	log := openTestWAL()
+	defer log.Close()
+	defer os.RemoveAll(log.path)
+	log.EngineStatMap = new(expvar.Map).Init()
+	log.Index = &testIndexWriter{fn: func(pointsByKey map[string][][]byte, measurementFieldsToSave map[string]*tsdb.MeasurementFields, seriesToCreate []*tsdb.SeriesCreate) error {
+		return nil
+	}}
+
+	if err := log.Open(); err != nil {
+		t.Fatalf("couldn't open wal: %s", err.Error())
+	}
+
+	codec := tsdb.NewFieldCodec(map[string]*tsdb.Field{
+		"value": {ID: uint8(1), Name: "value", Type: influxql.Float},
+	})
+	if err := log.WritePoints(parsePoints("cpu,host=A value=1.1 1\ncpu,host=A value=2.2 2", codec), nil, nil); err != nil {
+		t.Fatalf("failed to write points: %s", err.Error())
+	}
+
+	if stats := tsdb.NewEngineStats(log.EngineStatMap); stats.WALPartitionSizes[1] == 0 {
+		t.Fatalf("unexpected partition sizes: %v", stats.WALPartitionSizes)
+	}
+
+	if err := log.Flush(); err != nil {
+		t.Fatalf("failed to flush: %s", err.Error())
+	}
+	if stats := tsdb.NewEngineStats(log.EngineStatMap); stats.WALFlushes != 1 {
+		t.Fatalf("unexpected flush count: %d", stats.WALFlushes)
+	} else if stats.WALPartitionSizes[1] != 0 {
+		t.Fatalf("unexpected partition sizes: %v", stats.WALPartitionSizes)
+	}
+}
+
 func TestWAL_Cursor_Reverse(t *testing.T) {
 	log := openTestWAL()
 	defer log.Close()
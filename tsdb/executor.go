@@ -936,7 +936,7 @@ func (rqdp *RawQueryDerivativeProcessor) canProcess(input []*MapperValue) bool {
 	// See if the field value is numeric, if it's not, we can't process the derivative
 	validType := false
 	switch input[0].Value.(type) {
-	case int64:
+	case int64, uint64:
 		validType = true
 	case float64:
 		validType = true
@@ -969,7 +969,7 @@ func (rqdp *RawQueryDerivativeProcessor) Process(input []*MapperValue) []*Mapper
 
 		// Calculate the derivative of successive points by dividing the difference
 		// of each value by the elapsed time normalized to the interval
-		diff := int64toFloat64(v.Value) - int64toFloat64(rqdp.LastValueFromPreviousChunk.Value)
+		diff := numericDiff(v.Value, rqdp.LastValueFromPreviousChunk.Value)
 
 		elapsed := v.Time - rqdp.LastValueFromPreviousChunk.Time
 
@@ -1048,7 +1048,7 @@ func ProcessAggregateDerivative(results [][]interface{}, isNonNegative bool, int
 	// because derivatives cannot be combined with other aggregates currently.
 	validType := false
 	switch results[0][1].(type) {
-	case int64:
+	case int64, uint64:
 		validType = true
 	case float64:
 		validType = true
@@ -1073,7 +1073,7 @@ func ProcessAggregateDerivative(results [][]interface{}, isNonNegative bool, int
 		}
 
 		elapsed := cur[0].(time.Time).Sub(prev[0].(time.Time))
-		diff := int64toFloat64(cur[1]) - int64toFloat64(prev[1])
+		diff := numericDiff(cur[1], prev[1])
 		value := 0.0
 		if elapsed > 0 {
 			value = float64(diff) / (float64(elapsed) / float64(interval))
@@ -1126,10 +1126,26 @@ func int64toFloat64(v interface{}) float64 {
 	switch v.(type) {
 	case int64:
 		return float64(v.(int64))
+	case uint64:
+		return float64(v.(uint64))
 	case float64:
 		return v.(float64)
 	}
-	panic(fmt.Sprintf("expected either int64 or float64, got %v", v))
+	panic(fmt.Sprintf("expected either int64, uint64 or float64, got %v", v))
+}
+
+// numericDiff returns cur - prev as a float64. Unsigned values are subtracted
+// before conversion so that large counters don't lose precision.
+func numericDiff(cur, prev interface{}) float64 {
+	if c, ok := cur.(uint64); ok {
+		if p, ok := prev.(uint64); ok {
+			if c >= p {
+				return float64(c - p)
+			}
+			return -float64(p - c)
+		}
+	}
+	return int64toFloat64(cur) - int64toFloat64(prev)
 }
 
 type int64arr []int64
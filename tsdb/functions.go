@@ -364,6 +364,7 @@ type NumberType int8
 const (
 	Float64Type NumberType = iota
 	Int64Type
+	Uint64Type
 )
 
 // MapSum computes the summation of values in an iterator.
@@ -372,55 +373,84 @@ func MapSum(input *MapInput) interface{} {
 		return nil
 	}
 
-	n := float64(0)
-	var resultType NumberType
+	var sum sumAccumulator
 	for _, item := range input.Items {
-		switch v := item.Value.(type) {
-		case float64:
-			n += v
-		case int64:
-			n += float64(v)
-			resultType = Int64Type
-		}
-	}
-
-	switch resultType {
-	case Float64Type:
-		return n
-	case Int64Type:
-		return int64(n)
-	default:
-		return nil
+		sum.add(item.Value)
 	}
+	return sum.value()
 }
 
 // ReduceSum computes the sum of values for each key.
 func ReduceSum(values []interface{}) interface{} {
-	var n float64
-	count := 0
-	var resultType NumberType
+	var sum sumAccumulator
 	for _, v := range values {
-		if v == nil {
-			continue
+		sum.add(v)
+	}
+	return sum.value()
+}
+
+// sumAccumulator sums numeric values. Unsigned values are summed exactly so
+// counters above 2^53 don't lose precision. If an unsigned sum overflows, or
+// unsigned values are summed with floats, the sum is returned as a float.
+type sumAccumulator struct {
+	n        float64 // sum of the float and signed values
+	u        uint64  // sum of the unsigned values
+	f        float64 // sum of all values
+	overflow bool
+
+	hasFloat, hasInt, hasUint bool
+}
+
+func (a *sumAccumulator) add(v interface{}) {
+	switch v := v.(type) {
+	case float64:
+		a.n += v
+		a.f += v
+		a.hasFloat = true
+	case int64:
+		a.n += float64(v)
+		a.f += float64(v)
+		a.hasInt = true
+	case uint64:
+		if a.u+v < a.u {
+			a.overflow = true
 		}
-		count++
-		switch n1 := v.(type) {
-		case float64:
-			n += n1
-		case int64:
-			n += float64(n1)
-			resultType = Int64Type
+		a.u += v
+		a.f += float64(v)
+		a.hasUint = true
+	}
+}
+
+// value returns the sum. Returns nil if no values were added.
+func (a *sumAccumulator) value() interface{} {
+	switch {
+	case !a.hasFloat && !a.hasInt && !a.hasUint:
+		return nil
+	case !a.hasUint:
+		if a.hasInt {
+			return int64(a.n)
 		}
+		return a.n
+	case a.hasFloat || a.overflow:
+		return a.f
+	case !a.hasInt:
+		return a.u
 	}
-	if count > 0 {
-		switch resultType {
-		case Float64Type:
-			return n
-		case Int64Type:
-			return int64(n)
+
+	// Combine the signed and unsigned sums, keeping the result unsigned
+	// unless it's negative.
+	i := int64(a.n)
+	if i >= 0 {
+		if sum := a.u + uint64(i); sum >= a.u {
+			return sum
 		}
+		return a.f
 	}
-	return nil
+	m := uint64(-i)
+	if a.u >= m {
+		return a.u - m
+	}
+	return -int64(m - a.u)
 }
 
 // MapMean computes the count and sum of values in an iterator to be combined by the reducer.
@@ -438,6 +468,9 @@ func MapMean(input *MapInput) interface{} {
 		case int64:
 			out.Mean += (float64(v) - out.Mean) / float64(out.Count)
 			out.ResultType = Int64Type
+		case uint64:
+			out.Mean += (float64(v) - out.Mean) / float64(out.Count)
+			out.ResultType = Uint64Type
 		}
 	}
 	return out
@@ -625,108 +658,98 @@ func partition(data []float64) (lows []float64, pivotValue float64, highs []floa
 type minMaxMapOut struct {
 	Time   int64
 	Val    float64
+	UVal   uint64 // exact value when Type is Uint64Type
 	Type   NumberType
 	Fields map[string]interface{}
 	Tags   map[string]string
 }
 
-// MapMin collects the values to pass to the reducer
-func MapMin(input *MapInput, fieldName string) interface{} {
-	min := &minMaxMapOut{}
+// less returns true if the value of o is less than the value of other. The
+// field may have a different type in each shard so values are only compared
+// exactly if both are unsigned, and as floats otherwise.
+func (o *minMaxMapOut) less(other *minMaxMapOut) bool {
+	if o.Type == Uint64Type && other.Type == Uint64Type {
+		return o.UVal < other.UVal
+	}
+	return o.Val < other.Val
+}
 
-	pointsYielded := false
-	var val float64
+// point returns the value of o as a PositionPoint of its own type.
+func (o *minMaxMapOut) point() PositionPoint {
+	p := PositionPoint{Time: o.Time, Fields: o.Fields, Tags: o.Tags}
+	switch o.Type {
+	case Int64Type:
+		p.Value = int64(o.Val)
+	case Uint64Type:
+		p.Value = o.UVal
+	default:
+		p.Value = o.Val
+	}
+	return p
+}
 
+// mapMinMax returns the item with the lowest value if max is false, or the
+// item with the highest value if max is true. Returns nil without values.
+func mapMinMax(input *MapInput, fieldName string, max bool) interface{} {
+	var out *minMaxMapOut
 	for _, item := range input.Items {
-		switch v := item.Value.(type) {
+		v := &minMaxMapOut{Time: item.Timestamp, Fields: item.Fields, Tags: item.Tags}
+		switch value := item.Value.(type) {
 		case float64:
-			val = v
+			v.Val = value
 		case int64:
-			val = float64(v)
-			min.Type = Int64Type
+			v.Val, v.Type = float64(value), Int64Type
+		case uint64:
+			v.Val, v.UVal, v.Type = float64(value), value, Uint64Type
 		case map[string]interface{}:
-			if d, t, ok := decodeValueAndNumberType(v[fieldName]); ok {
-				val, min.Type = d, t
-			} else {
+			d, t, ok := decodeValueAndNumberType(value[fieldName])
+			if !ok {
 				continue
 			}
+			v.Val, v.Type = d, t
+			v.UVal, _ = value[fieldName].(uint64)
+		default:
+			continue
 		}
 
-		// Initialize min
-		if !pointsYielded {
-			min.Time = item.Timestamp
-			min.Val = val
-			min.Fields = item.Fields
-			min.Tags = item.Tags
-			pointsYielded = true
-		}
-		current := min.Val
-		min.Val = math.Min(min.Val, val)
-
-		// Check to see if the value changed, if so, update the fields/tags
-		if current != min.Val {
-			min.Time = item.Timestamp
-			min.Fields = item.Fields
-			min.Tags = item.Tags
+		if out == nil || (!max && v.less(out)) || (max && out.less(v)) {
+			out = v
 		}
 	}
-	if pointsYielded {
-		return min
+	if out == nil {
+		return nil
 	}
-	return nil
+	return out
 }
 
-// ReduceMin computes the min of value.
-func ReduceMin(values []interface{}) interface{} {
-	min := &minMaxMapOut{}
-	pointsYielded := false
-
+// reduceMinMax returns the lowest value if max is false, or the highest value
+// if max is true. Returns nil without values.
+func reduceMinMax(values []interface{}, max bool) interface{} {
+	var out *minMaxMapOut
 	for _, value := range values {
-		if value == nil {
-			continue
-		}
-
 		v, ok := value.(*minMaxMapOut)
-		if !ok {
+		if !ok || v == nil {
 			continue
 		}
 
-		// Initialize min
-		if !pointsYielded {
-			min.Time = v.Time
-			min.Val = v.Val
-			min.Type = v.Type
-			min.Fields = v.Fields
-			min.Tags = v.Tags
-			pointsYielded = true
-		}
-		min.Val = math.Min(min.Val, v.Val)
-		current := min.Val
-		if current != min.Val {
-			min.Time = v.Time
-			min.Fields = v.Fields
-			min.Tags = v.Tags
+		if out == nil || (!max && v.less(out)) || (max && out.less(v)) {
+			out = v
 		}
 	}
-	if pointsYielded {
-		switch min.Type {
-		case Float64Type:
-			return PositionPoint{
-				Time:   min.Time,
-				Value:  min.Val,
-				Fields: min.Fields,
-				Tags:   min.Tags,
-			}
-		case Int64Type:
-			return PositionPoint{
-				Time:   min.Time,
-				Value:  int64(min.Val),
-				Fields: min.Fields,
-				Tags:   min.Tags,
-			}
-		}
+	if out == nil {
+		return nil
 	}
-	return nil
+	return out.point()
+}
+
+// MapMin collects the values to pass to the reducer
+func MapMin(input *MapInput, fieldName string) interface{} {
+	return mapMinMax(input, fieldName, false)
+}
+
+// ReduceMin computes the min of value.
+func ReduceMin(values []interface{}) interface{} {
+	return reduceMinMax(values, false)
 }
 
 func decodeValueAndNumberType(v interface{}) (float64, NumberType, bool) {
@@ -735,6 +758,8 @@ func decodeValueAndNumberType(v interface{}) (float64, NumberType, bool) {
 		return n, Float64Type, true
 	case int64:
 		return float64(n), Int64Type, true
+	case uint64:
+		return float64(n), Uint64Type, true
 	default:
 		return 0, Float64Type, false
 	}
@@ -742,167 +767,98 @@ func decodeValueAndNumberType(v interface{}) (float64, NumberType, bool) {
 
 // MapMax collects the values to pass to the reducer
 func MapMax(input *MapInput, fieldName string) interface{} {
-	max := &minMaxMapOut{}
-
-	pointsYielded := false
-	var val float64
-
-	for _, item := range input.Items {
-		switch v := item.Value.(type) {
-		case float64:
-			val = v
-		case int64:
-			val = float64(v)
-			max.Type = Int64Type
-		case map[string]interface{}:
-			if d, t, ok := decodeValueAndNumberType(v[fieldName]); ok {
-				val, max.Type = d, t
-			} else {
-				continue
-			}
-		}
-
-		// Initialize max
-		if !pointsYielded {
-			max.Time = item.Timestamp
-			max.Val = val
-			max.Fields = item.Fields
-			max.Tags = item.Tags
-			pointsYielded = true
-		}
-		current := max.Val
-		max.Val = math.Max(max.Val, val)
-
-		// Check to see if the value changed, if so, update the fields/tags
-		if current != max.Val {
-			max.Time = item.Timestamp
-			max.Fields = item.Fields
-			max.Tags = item.Tags
-		}
-	}
-	if pointsYielded {
-		return max
-	}
-	return nil
+	return mapMinMax(input, fieldName, true)
 }
 
 // ReduceMax computes the max of value.
 func ReduceMax(values []interface{}) interface{} {
-	max := &minMaxMapOut{}
-	pointsYielded := false
-
-	for _, value := range values {
-		if value == nil {
-			continue
-		}
+	return reduceMinMax(values, true)
+}
 
-		v, ok := value.(*minMaxMapOut)
-		if !ok {
-			continue
-		}
+type spreadMapOutput struct {
+	Min, Max   float64
+	UMin, UMax uint64 // exact values when Type is Uint64Type
+	Type       NumberType
+}
 
-		// Initialize max
-		if !pointsYielded {
-			max.Time = v.Time
-			max.Val = v.Val
-			max.Type = v.Type
-			max.Fields = v.Fields
-			max.Tags = v.Tags
-			pointsYielded = true
-		}
-		current := max.Val
-		max.Val = math.Max(max.Val, v.Val)
-		if current != max.Val {
-			max.Time = v.Time
-			max.Fields = v.Fields
-			max.Tags = v.Tags
+// add includes the values between min and max of type typ in the spread.
+// umin and umax are the exact values if typ is Uint64Type. The spread is only
+// exact while all values are unsigned, and is kept as floats otherwise.
+func (o *spreadMapOutput) add(min, max float64, umin, umax uint64, typ NumberType) {
+	if o.Type == Uint64Type && typ == Uint64Type {
+		if umin < o.UMin {
+			o.UMin = umin
 		}
-	}
-	if pointsYielded {
-		switch max.Type {
-		case Float64Type:
-			return PositionPoint{
-				Time:   max.Time,
-				Value:  max.Val,
-				Fields: max.Fields,
-				Tags:   max.Tags,
-			}
-		case Int64Type:
-			return PositionPoint{
-				Time:   max.Time,
-				Value:  int64(max.Val),
-				Fields: max.Fields,
-				Tags:   max.Tags,
-			}
+		if umax > o.UMax {
+			o.UMax = umax
 		}
+	} else if o.Type != typ {
+		o.Type = Float64Type
 	}
-	return nil
-}
-
-type spreadMapOutput struct {
-	Min, Max float64
-	Type     NumberType
+	o.Min = math.Min(o.Min, min)
+	o.Max = math.Max(o.Max, max)
 }
 
 // MapSpread collects the values to pass to the reducer
 func MapSpread(input *MapInput) interface{} {
-	out := &spreadMapOutput{}
-	pointsYielded := false
-	var val float64
-
+	var out *spreadMapOutput
 	for _, item := range input.Items {
+		var val float64
+		var uval uint64
+		typ := Float64Type
 		switch v := item.Value.(type) {
 		case float64:
 			val = v
 		case int64:
-			val = float64(v)
-			out.Type = Int64Type
+			val, typ = float64(v), Int64Type
+		case uint64:
+			val, uval, typ = float64(v), v, Uint64Type
+		default:
+			continue
 		}
 
 		// Initialize
-		if !pointsYielded {
-			out.Max = val
-			out.Min = val
-			pointsYielded = true
+		if out == nil {
+			out = &spreadMapOutput{Min: val, Max: val, UMin: uval, UMax: uval, Type: typ}
+			continue
 		}
-		out.Max = math.Max(out.Max, val)
-		out.Min = math.Min(out.Min, val)
+		out.add(val, val, uval, uval, typ)
 	}
-	if pointsYielded {
-		return out
+	if out == nil {
+		return nil
 	}
-	return nil
+	return out
 }
 
 // ReduceSpread computes the spread of values.
 func ReduceSpread(values []interface{}) interface{} {
-	result := &spreadMapOutput{}
-	pointsYielded := false
-
+	var result *spreadMapOutput
 	for _, v := range values {
-		if v == nil {
+		val, ok := v.(*spreadMapOutput)
+		if !ok || val == nil {
 			continue
 		}
-		val := v.(*spreadMapOutput)
+
 		// Initialize
-		if !pointsYielded {
-			result.Max = val.Max
-			result.Min = val.Min
-			result.Type = val.Type
-			pointsYielded = true
+		if result == nil {
+			result = &spreadMapOutput{}
+			*result = *val
+			continue
 		}
-		result.Max = math.Max(result.Max, val.Max)
-		result.Min = math.Min(result.Min, val.Min)
+		result.add(val.Min, val.Max, val.UMin, val.UMax, val.Type)
 	}
-	if pointsYielded {
-		switch result.Type {
-		case Float64Type:
-			return result.Max - result.Min
-		case Int64Type:
-			return int64(result.Max - result.Min)
-		}
+	if result == nil {
+		return nil
+	}
+
+	switch result.Type {
+	case Int64Type:
+		return int64(result.Max - result.Min)
+	case Uint64Type:
+		return result.UMax - result.UMin
+	default:
+		return result.Max - result.Min
 	}
-	return nil
 }
 
 // MapStddev collects the values to pass to the reducer
@@ -914,6 +870,8 @@ func MapStddev(input *MapInput) interface{} {
 			a = append(a, v)
 		case int64:
 			a = append(a, float64(v))
+		case uint64:
+			a = append(a, float64(v))
 		}
 	}
 	return a
@@ -1656,6 +1614,8 @@ func ReducePercentile(values []interface{}, c *influxql.Call) interface{} {
 			switch v.(type) {
 			case int64:
 				allValues = append(allValues, float64(v.(int64)))
+			case uint64:
+				allValues = append(allValues, float64(v.(uint64)))
 			case float64:
 				allValues = append(allValues, v.(float64))
 			}
@@ -1711,6 +1671,8 @@ func greaterThan(a, b interface{}) bool {
 	switch t := a.(type) {
 	case int64:
 		return t > b.(int64)
+	case uint64:
+		return t > b.(uint64)
 	case float64:
 		return t > b.(float64)
 	case string:
@@ -61,6 +61,123 @@ func TestMapMean(t *testing.T) {
 	}
 }
 
+func TestMapSumUnsigned(t *testing.T) {
+	input := &MapInput{
+		Items: []MapItem{
+			{Timestamp: 1, Value: uint64(18446744073709551000)},
+			{Timestamp: 2, Value: uint64(15)},
+		},
+	}
+	if exp, got := uint64(18446744073709551015), MapSum(input); got != exp {
+		t.Errorf("MapSum: output mismatch: exp %v got %v", exp, got)
+	}
+	if exp, got := uint64(18446744073709551016), ReduceSum([]interface{}{MapSum(input), uint64(1)}); got != exp {
+		t.Errorf("ReduceSum: output mismatch: exp %v got %v", exp, got)
+	}
+}
+
+// Ensure an unsigned sum that overflows is returned as a float.
+func TestMapSumUnsigned_Overflow(t *testing.T) {
+	input := &MapInput{
+		Items: []MapItem{
+			{Timestamp: 1, Value: uint64(18446744073709551615)},
+			{Timestamp: 2, Value: uint64(2)},
+		},
+	}
+	if exp, got := float64(18446744073709551617), MapSum(input); got != exp {
+		t.Errorf("MapSum: output mismatch: exp %v got %v", exp, got)
+	}
+	if exp, got := float64(18446744073709551617), ReduceSum([]interface{}{uint64(18446744073709551615), uint64(2)}); got != exp {
+		t.Errorf("ReduceSum: output mismatch: exp %v got %v", exp, got)
+	}
+}
+
+// Ensure signed values are included when summed with unsigned values.
+func TestMapSumUnsigned_Mixed(t *testing.T) {
+	for i, tt := range []struct {
+		values []interface{}
+		exp    interface{}
+	}{
+		{values: []interface{}{uint64(18446744073709551000), int64(15)}, exp: uint64(18446744073709551015)},
+		{values: []interface{}{uint64(10), int64(-4)}, exp: uint64(6)},
+		{values: []interface{}{uint64(4), int64(-10)}, exp: int64(-6)},
+		{values: []interface{}{uint64(18446744073709551615), int64(1)}, exp: float64(18446744073709551616)},
+		{values: []interface{}{uint64(4), float64(1.5)}, exp: float64(5.5)},
+	} {
+		input := &MapInput{}
+		for j, v := range tt.values {
+			input.Items = append(input.Items, MapItem{Timestamp: int64(j), Value: v})
+		}
+		if got := MapSum(input); got != tt.exp {
+			t.Errorf("%d. MapSum: output mismatch: exp %v (%T) got %v (%T)", i, tt.exp, tt.exp, got, got)
+		}
+		if got := ReduceSum(tt.values); got != tt.exp {
+			t.Errorf("%d. ReduceSum: output mismatch: exp %v (%T) got %v (%T)", i, tt.exp, tt.exp, got, got)
+		}
+	}
+}
+
+func TestMapMaxUnsigned(t *testing.T) {
+	// Both values round to the same float64, so they must be compared exactly.
+	input := &MapInput{
+		Items: []MapItem{
+			{Timestamp: 1, Value: uint64(18446744073709551614)},
+			{Timestamp: 2, Value: uint64(18446744073709551615)},
+			{Timestamp: 3, Value: uint64(18446744073709551613)},
+		},
+	}
+	got := ReduceMax([]interface{}{MapMax(input, "")}).(PositionPoint)
+	if exp := uint64(18446744073709551615); got.Value != exp || got.Time != 2 {
+		t.Errorf("output mismatch: exp %v@2 got %v@%d", exp, got.Value, got.Time)
+	}
+}
+
+// Ensure the min and max of shards with different field types compare values
+// of each type rather than only the unsigned values.
+func TestReduceMinMaxUnsigned_Mixed(t *testing.T) {
+	mapOutput := func(v interface{}, fn func(*MapInput, string) interface{}) interface{} {
+		return fn(&MapInput{Items: []MapItem{{Timestamp: 1, Value: v}}}, "")
+	}
+
+	for i, tt := range []struct {
+		values   []interface{}
+		min, max interface{}
+	}{
+		{values: []interface{}{uint64(10), float64(2.5), int64(20)}, min: float64(2.5), max: int64(20)},
+		{values: []interface{}{int64(-5), uint64(18446744073709551615)}, min: int64(-5), max: uint64(18446744073709551615)},
+		{values: []interface{}{uint64(18446744073709551614), uint64(18446744073709551615)}, min: uint64(18446744073709551614), max: uint64(18446744073709551615)},
+	} {
+		var mins, maxes []interface{}
+		for _, v := range tt.values {
+			mins = append(mins, mapOutput(v, MapMin))
+			maxes = append(maxes, mapOutput(v, MapMax))
+		}
+
+		if got := ReduceMin(mins).(PositionPoint).Value; got != tt.min {
+			t.Errorf("%d. ReduceMin: output mismatch: exp %v (%T) got %v (%T)", i, tt.min, tt.min, got, got)
+		}
+		if got := ReduceMax(maxes).(PositionPoint).Value; got != tt.max {
+			t.Errorf("%d. ReduceMax: output mismatch: exp %v (%T) got %v (%T)", i, tt.max, tt.max, got, got)
+		}
+	}
+}
+
+// Ensure the spread of unsigned values is exact beyond the precision of a
+// float64.
+func TestReduceSpreadUnsigned(t *testing.T) {
+	a := MapSpread(&MapInput{Items: []MapItem{{Timestamp: 1, Value: uint64(18446744073709551615)}}})
+	b := MapSpread(&MapInput{Items: []MapItem{{Timestamp: 2, Value: uint64(18446744073709551614)}}})
+	if got, exp := ReduceSpread([]interface{}{a, b}), uint64(1); got != exp {
+		t.Errorf("output mismatch: exp %v (%T) got %v (%T)", exp, exp, got, got)
+	}
+
+	// Signed values make the spread a float.
+	c := MapSpread(&MapInput{Items: []MapItem{{Timestamp: 3, Value: int64(-1)}}})
+	if got, exp := ReduceSpread([]interface{}{b, c}), float64(18446744073709551615); got != exp {
+		t.Errorf("output mismatch: exp %v (%T) got %v (%T)", exp, exp, got, got)
+	}
+}
+
 func TestInitializeMapFuncDerivative(t *testing.T) {
 
 	for _, fn := range []string{"derivative", "non_negative_derivative"} {
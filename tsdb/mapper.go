@@ -180,7 +180,7 @@ func (m *RawMapper) Open() error {
 	m.qmin, m.qmax = influxql.TimeRangeAsEpochNano(m.stmt.Condition)
 
 	// Get a read-only transaction.
-	tx, err := m.shard.engine.Begin(false)
+	tx, err := m.shard.ReadOnlyTx()
 	if err != nil {
 		return err
 	}
@@ -416,7 +416,7 @@ func (m *AggregateMapper) Open() error {
 	}
 
 	// Get a read-only transaction.
-	tx, err := m.shard.engine.Begin(false)
+	tx, err := m.shard.ReadOnlyTx()
 	if err != nil {
 		return err
 	}
@@ -105,6 +105,29 @@ func (s *DatabaseIndex) CreateSeriesIndexIfNotExists(measurementName string, ser
 	return series
 }
 
+// merge adds the measurements and series of other to the index and marks the
+// series as defined in shardID. The caller must hold the index lock.
+func (d *DatabaseIndex) merge(other *DatabaseIndex, shardID uint64) {
+	for name, m := range other.measurements {
+		mm := d.measurements[name]
+		if mm == nil {
+			mm = NewMeasurement(name, d)
+			d.measurements[name] = mm
+		}
+		for f := range m.fieldNames {
+			mm.SetFieldName(f)
+		}
+	}
+
+	for key, ss := range other.series {
+		series := d.series[key]
+		if series == nil {
+			series = d.CreateSeriesIndexIfNotExists(MeasurementFromSeriesKey(key), NewSeries(key, ss.Tags))
+		}
+		series.shardIDs[shardID] = true
+	}
+}
+
 // CreateMeasurementIndexIfNotExists creates or retrieves an in memory index object for the measurement
 func (s *DatabaseIndex) CreateMeasurementIndexIfNotExists(name string) *Measurement {
 	name = escape.UnescapeString(name)
@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/influxdb/influxdb/influxql"
@@ -41,9 +42,19 @@ type QueryExecutor struct {
 		ExecuteStatement(stmt influxql.Statement) *influxql.Result
 	}
 
+	// Execute statements that copy and move shards between nodes.
+	ShardStatementExecutor interface {
+		ExecuteStatement(stmt influxql.Statement) *influxql.Result
+	}
+
+	// Execute statements that show how shards would be rebalanced.
+	RebalancerStatementExecutor interface {
+		ExecuteStatement(stmt influxql.Statement) *influxql.Result
+	}
+
 	// Maps shards for queries.
 	ShardMapper interface {
-		CreateMapper(shard meta.ShardInfo, stmt influxql.Statement, chunkSize int) (Mapper, error)
+		CreateMapper(shard meta.ShardInfo, stmt influxql.Statement, chunkSize int, level ReadConsistency) (Mapper, error)
 	}
 
 	Logger          *log.Logger
@@ -126,8 +137,9 @@ func (q *QueryExecutor) Authorize(u *meta.UserInfo, query *influxql.Query, datab
 
 // ExecuteQuery executes an InfluxQL query against the server.
 // It sends results down the passed in chan and closes it when done. It will close the chan
-// on the first statement that throws an error.
-func (q *QueryExecutor) ExecuteQuery(query *influxql.Query, database string, chunkSize int) (<-chan *influxql.Result, error) {
+// on the first statement that throws an error. level sets how many owners of each
+// shard must be available to read it.
+func (q *QueryExecutor) ExecuteQuery(query *influxql.Query, database string, chunkSize int, level ReadConsistency) (<-chan *influxql.Result, error) {
 	// Execute each statement. Keep the iterator external so we can
 	// track how many of the statements were executed
 	results := make(chan *influxql.Result)
@@ -159,7 +171,7 @@ func (q *QueryExecutor) ExecuteQuery(query *influxql.Query, database string, chu
 			var res *influxql.Result
 			switch stmt := stmt.(type) {
 			case *influxql.SelectStatement:
-				if err := q.executeStatement(i, stmt, database, results, chunkSize); err != nil {
+				if err := q.executeStatement(i, stmt, database, results, chunkSize, level); err != nil {
 					results <- &influxql.Result{Err: err}
 					break
 				}
@@ -172,12 +184,12 @@ func (q *QueryExecutor) ExecuteQuery(query *influxql.Query, database string, chu
 				// TODO: handle this in a cluster
 				res = q.executeDropMeasurementStatement(stmt, database)
 			case *influxql.ShowMeasurementsStatement:
-				if err := q.executeStatement(i, stmt, database, results, chunkSize); err != nil {
+				if err := q.executeStatement(i, stmt, database, results, chunkSize, level); err != nil {
 					results <- &influxql.Result{Err: err}
 					break
 				}
 			case *influxql.ShowTagKeysStatement:
-				if err := q.executeStatement(i, stmt, database, results, chunkSize); err != nil {
+				if err := q.executeStatement(i, stmt, database, results, chunkSize, level); err != nil {
 					results <- &influxql.Result{Err: err}
 					break
 				}
@@ -190,9 +202,16 @@ func (q *QueryExecutor) ExecuteQuery(query *influxql.Query, database string, chu
 			case *influxql.DropDatabaseStatement:
 				// TODO: handle this in a cluster
 				res = q.executeDropDatabaseStatement(stmt)
-			case *influxql.ShowStatsStatement, *influxql.ShowDiagnosticsStatement:
+			case *influxql.ShowStatsStatement, *influxql.ShowDiagnosticsStatement,
+				*influxql.ShowHintedHandoffStatement, *influxql.PurgeHintedHandoffStatement,
+				*influxql.PauseHintedHandoffStatement, *influxql.ResumeHintedHandoffStatement:
 				// Send monitor-related queries to the monitor service.
 				res = q.MonitorStatementExecutor.ExecuteStatement(stmt)
+			case *influxql.CopyShardStatement, *influxql.MoveShardStatement, *influxql.ShowShardMovesStatement:
+				// Send shard copies and moves to the copier service.
+				res = q.ShardStatementExecutor.ExecuteStatement(stmt)
+			case *influxql.ShowShardPlanStatement:
+				res = q.RebalancerStatementExecutor.ExecuteStatement(stmt)
 			default:
 				// Delegate all other meta statements to a separate executor. They don't hit tsdb storage.
 				res = q.MetaStatementExecutor.ExecuteStatement(stmt)
@@ -222,7 +241,7 @@ func (q *QueryExecutor) ExecuteQuery(query *influxql.Query, database string, chu
 }
 
 // Plan creates an execution plan for the given SelectStatement and returns an Executor.
-func (q *QueryExecutor) PlanSelect(stmt *influxql.SelectStatement, chunkSize int) (Executor, error) {
+func (q *QueryExecutor) PlanSelect(stmt *influxql.SelectStatement, chunkSize int, level ReadConsistency) (Executor, error) {
 	shards := map[uint64]meta.ShardInfo{} // Shards requiring mappers.
 
 	// It is important to "stamp" this time so that everywhere we evaluate `now()` in the statement is EXACTLY the same `now`
@@ -260,7 +279,7 @@ func (q *QueryExecutor) PlanSelect(stmt *influxql.SelectStatement, chunkSize int
 	// Build the Mappers, one per shard.
 	mappers := []Mapper{}
 	for _, sh := range shards {
-		m, err := q.ShardMapper.CreateMapper(sh, stmt, chunkSize)
+		m, err := q.ShardMapper.CreateMapper(sh, stmt, chunkSize, level)
 		if err != nil {
 			return nil, err
 		}
@@ -276,9 +295,9 @@ func (q *QueryExecutor) PlanSelect(stmt *influxql.SelectStatement, chunkSize int
 }
 
 // executeSelectStatement plans and executes a select statement against a database.
-func (q *QueryExecutor) executeSelectStatement(statementID int, stmt *influxql.SelectStatement, results chan *influxql.Result, chunkSize int) error {
+func (q *QueryExecutor) executeSelectStatement(statementID int, stmt *influxql.SelectStatement, results chan *influxql.Result, chunkSize int, level ReadConsistency) error {
 	// Plan statement execution.
-	e, err := q.PlanSelect(stmt, chunkSize)
+	e, err := q.PlanSelect(stmt, chunkSize, level)
 	if err != nil {
 		return err
 	}
@@ -573,21 +592,21 @@ func (q *QueryExecutor) filterShowSeriesResult(limit, offset int, rows models.Ro
 	return filteredSeries
 }
 
-func (q *QueryExecutor) planStatement(stmt influxql.Statement, database string, chunkSize int) (Executor, error) {
+func (q *QueryExecutor) planStatement(stmt influxql.Statement, database string, chunkSize int, level ReadConsistency) (Executor, error) {
 	switch stmt := stmt.(type) {
 	case *influxql.SelectStatement:
-		return q.PlanSelect(stmt, chunkSize)
+		return q.PlanSelect(stmt, chunkSize, level)
 	case *influxql.ShowMeasurementsStatement:
-		return q.PlanShowMeasurements(stmt, database, chunkSize)
+		return q.PlanShowMeasurements(stmt, database, chunkSize, level)
 	case *influxql.ShowTagKeysStatement:
-		return q.PlanShowTagKeys(stmt, database, chunkSize)
+		return q.PlanShowTagKeys(stmt, database, chunkSize, level)
 	default:
 		return nil, fmt.Errorf("can't plan statement type: %v", stmt)
 	}
 }
 
 // PlanShowMeasurements creates an execution plan for a SHOW TAG KEYS statement and returns an Executor.
-func (q *QueryExecutor) PlanShowMeasurements(stmt *influxql.ShowMeasurementsStatement, database string, chunkSize int) (Executor, error) {
+func (q *QueryExecutor) PlanShowMeasurements(stmt *influxql.ShowMeasurementsStatement, database string, chunkSize int, level ReadConsistency) (Executor, error) {
 	// Get the database info.
 	di, err := q.MetaStore.Database(database)
 	if err != nil {
@@ -602,7 +621,7 @@ func (q *QueryExecutor) PlanShowMeasurements(stmt *influxql.ShowMeasurementsStat
 	// Build the Mappers, one per shard.
 	mappers := []Mapper{}
 	for _, sh := range shards {
-		m, err := q.ShardMapper.CreateMapper(sh, stmt, chunkSize)
+		m, err := q.ShardMapper.CreateMapper(sh, stmt, chunkSize, level)
 		if err != nil {
 			return nil, err
 		}
@@ -618,7 +637,7 @@ func (q *QueryExecutor) PlanShowMeasurements(stmt *influxql.ShowMeasurementsStat
 }
 
 // PlanShowTagKeys creates an execution plan for a SHOW MEASUREMENTS statement and returns an Executor.
-func (q *QueryExecutor) PlanShowTagKeys(stmt *influxql.ShowTagKeysStatement, database string, chunkSize int) (Executor, error) {
+func (q *QueryExecutor) PlanShowTagKeys(stmt *influxql.ShowTagKeysStatement, database string, chunkSize int, level ReadConsistency) (Executor, error) {
 	// Get the database info.
 	di, err := q.MetaStore.Database(database)
 	if err != nil {
@@ -633,7 +652,7 @@ func (q *QueryExecutor) PlanShowTagKeys(stmt *influxql.ShowTagKeysStatement, dat
 	// Build the Mappers, one per shard.
 	mappers := []Mapper{}
 	for _, sh := range shards {
-		m, err := q.ShardMapper.CreateMapper(sh, stmt, chunkSize)
+		m, err := q.ShardMapper.CreateMapper(sh, stmt, chunkSize, level)
 		if err != nil {
 			return nil, err
 		}
@@ -648,9 +667,9 @@ func (q *QueryExecutor) PlanShowTagKeys(stmt *influxql.ShowTagKeysStatement, dat
 	return executor, nil
 }
 
-func (q *QueryExecutor) executeStatement(statementID int, stmt influxql.Statement, database string, results chan *influxql.Result, chunkSize int) error {
+func (q *QueryExecutor) executeStatement(statementID int, stmt influxql.Statement, database string, results chan *influxql.Result, chunkSize int, level ReadConsistency) error {
 	// Plan statement execution.
-	e, err := q.planStatement(stmt, database, chunkSize)
+	e, err := q.planStatement(stmt, database, chunkSize, level)
 	if err != nil {
 		return err
 	}
@@ -675,8 +694,8 @@ func (q *QueryExecutor) executeStatement(statementID int, stmt influxql.Statemen
 	return nil
 }
 
-func (q *QueryExecutor) executeShowMeasurementsStatement(statementID int, stmt *influxql.ShowMeasurementsStatement, database string, results chan *influxql.Result, chunkSize int) error { // Plan statement execution.
-	e, err := q.PlanShowMeasurements(stmt, database, chunkSize)
+func (q *QueryExecutor) executeShowMeasurementsStatement(statementID int, stmt *influxql.ShowMeasurementsStatement, database string, results chan *influxql.Result, chunkSize int, level ReadConsistency) error { // Plan statement execution.
+	e, err := q.PlanShowMeasurements(stmt, database, chunkSize, level)
 	if err != nil {
 		return err
 	}
@@ -951,8 +970,67 @@ var (
 	// ErrNotExecuted is returned when a statement is not executed in a query.
 	// This can occur when a previous statement in the same query has errored.
 	ErrNotExecuted = errors.New("not executed")
+
+	// ErrInvalidReadConsistency is returned when parsing an unknown read
+	// consistency level.
+	ErrInvalidReadConsistency = errors.New("invalid read consistency level")
+)
+
+// ReadConsistency is the number of owners each shard is read from by a
+// query. The query fails unless that many owners are available and they
+// return the same data.
+type ReadConsistency int
+
+const (
+	// ReadConsistencyOne reads each shard from one owner.
+	ReadConsistencyOne ReadConsistency = iota
+
+	// ReadConsistencyQuorum reads each shard from a majority of its owners.
+	ReadConsistencyQuorum
+
+	// ReadConsistencyAll reads each shard from all of its owners.
+	ReadConsistencyAll
 )
 
+// ParseReadConsistency converts a read consistency level string to the
+// corresponding ReadConsistency const. An empty string is ReadConsistencyOne.
+func ParseReadConsistency(level string) (ReadConsistency, error) {
+	switch strings.ToLower(level) {
+	case "", "one":
+		return ReadConsistencyOne, nil
+	case "quorum":
+		return ReadConsistencyQuorum, nil
+	case "all":
+		return ReadConsistencyAll, nil
+	default:
+		return 0, ErrInvalidReadConsistency
+	}
+}
+
+// String returns the name of the read consistency level.
+func (l ReadConsistency) String() string {
+	switch l {
+	case ReadConsistencyQuorum:
+		return "quorum"
+	case ReadConsistencyAll:
+		return "all"
+	default:
+		return "one"
+	}
+}
+
+// Required returns the number of owners out of n that must be read.
+func (l ReadConsistency) Required(n int) int {
+	switch l {
+	case ReadConsistencyQuorum:
+		return n/2 + 1
+	case ReadConsistencyAll:
+		return n
+	default:
+		return 1
+	}
+}
+
 func ErrDatabaseNotFound(name string) error { return fmt.Errorf("database not found: %s", name) }
 
 func ErrMeasurementNotFound(name string) error { return fmt.Errorf("measurement not found: %s", name) }
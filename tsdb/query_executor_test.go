@@ -347,6 +347,31 @@ func TestAuthenticateIfUserCountZeroAndCreateUser(t *testing.T) {
 	}
 }
 
+// Ensure read consistency levels can be parsed and require the right number of owners.
+func TestParseReadConsistency(t *testing.T) {
+	for i, tt := range []struct {
+		s        string
+		level    tsdb.ReadConsistency
+		required int
+		err      error
+	}{
+		{s: "", level: tsdb.ReadConsistencyOne, required: 1},
+		{s: "one", level: tsdb.ReadConsistencyOne, required: 1},
+		{s: "QUORUM", level: tsdb.ReadConsistencyQuorum, required: 2},
+		{s: "all", level: tsdb.ReadConsistencyAll, required: 3},
+		{s: "any", err: tsdb.ErrInvalidReadConsistency},
+	} {
+		level, err := tsdb.ParseReadConsistency(tt.s)
+		if err != tt.err {
+			t.Errorf("%d. %q: unexpected error: %v", i, tt.s, err)
+		} else if err == nil && level != tt.level {
+			t.Errorf("%d. %q: unexpected level: %s", i, tt.s, level)
+		} else if err == nil && level.Required(3) != tt.required {
+			t.Errorf("%d. %q: unexpected required owners: %d", i, tt.s, level.Required(3))
+		}
+	}
+}
+
 func testStoreAndExecutor(storePath string) (*tsdb.Store, *tsdb.QueryExecutor) {
 	if storePath == "" {
 		storePath, _ = ioutil.TempDir("", "")
@@ -372,7 +397,7 @@ func testStoreAndExecutor(storePath string) (*tsdb.Store, *tsdb.QueryExecutor) {
 }
 
 func executeAndGetJSON(query string, executor *tsdb.QueryExecutor) string {
-	ch, err := executor.ExecuteQuery(mustParseQuery(query), "foo", 20)
+	ch, err := executor.ExecuteQuery(mustParseQuery(query), "foo", 20, tsdb.ReadConsistencyOne)
 	if err != nil {
 		panic(err.Error())
 	}
@@ -478,7 +503,7 @@ type testShardMapper struct {
 	store *tsdb.Store
 }
 
-func (t *testShardMapper) CreateMapper(shard meta.ShardInfo, stmt influxql.Statement, chunkSize int) (tsdb.Mapper, error) {
+func (t *testShardMapper) CreateMapper(shard meta.ShardInfo, stmt influxql.Statement, chunkSize int, level tsdb.ReadConsistency) (tsdb.Mapper, error) {
 	m, err := t.store.CreateMapper(shard.ID, stmt, chunkSize)
 	return m, err
 }
@@ -10,13 +10,15 @@ import (
 	"math"
 	"os"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/influxdb/influxdb"
 	"github.com/influxdb/influxdb/influxql"
+	"github.com/influxdb/influxdb/meta"
 	"github.com/influxdb/influxdb/models"
 	"github.com/influxdb/influxdb/tsdb/internal"
 
-	"github.com/boltdb/bolt"
 	"github.com/gogo/protobuf/proto"
 )
 
@@ -42,6 +44,9 @@ var (
 	// ErrFieldUnmappedID is returned when the system is presented, during decode, with a field ID
 	// there is no mapping for.
 	ErrFieldUnmappedID = errors.New("field ID not mapped")
+
+	// ErrShardClosed is returned when a shard is accessed after it's been closed.
+	ErrShardClosed = errors.New("shard closed")
 )
 
 // Shard represents a self-contained time series database. An inverted index of
@@ -49,7 +54,6 @@ var (
 // Data can be split across many shards. The query engine in TSDB is responsible
 // for combining the output of many shards into a single query result.
 type Shard struct {
-	db      *bolt.DB // underlying data store
 	index   *DatabaseIndex
 	path    string
 	walPath string
@@ -61,6 +65,25 @@ type Shard struct {
 	mu                sync.RWMutex
 	measurementFields map[string]*MeasurementFields // measurement name to their fields
 
+	// Field codecs by measurement name. Kept under their own lock so the engine
+	// can decode fields while resolving duplicate points with the shard locked.
+	codecMu sync.RWMutex
+	codecs  map[string]*FieldCodec
+
+	// Duplicate point policy of the shard's retention policy.
+	duplicatePolicy string
+
+	// Writes are rejected while the shard's database is read-only.
+	readOnly bool
+
+	// The shard is opened on its next access if it's closed and lazy is set.
+	// indexed is set once the shard's series have been loaded into the index.
+	lazy       bool
+	indexed    bool
+	lastAccess int64 // unix nanoseconds, accessed atomically
+	lastWrite  int64 // unix nanoseconds, accessed atomically
+	txN        int64 // open read-only transactions and engine calls, accessed atomically
+
 	// expvar-based stats.
 	statMap *expvar.Map
 
@@ -75,6 +98,10 @@ func NewShard(id uint64, index *DatabaseIndex, path string, walPath string, opti
 	tags := map[string]string{"path": path, "id": fmt.Sprintf("%d", id), "engine": options.EngineVersion}
 	statMap := influxdb.NewStatistics(key, "shard", tags)
 
+	// Engines tag their statistics with the shard.
+	options.ShardID = id
+	options.Database, options.RetentionPolicy = shardLocation(path)
+
 	return &Shard{
 		index:             index,
 		path:              path,
@@ -82,6 +109,7 @@ func NewShard(id uint64, index *DatabaseIndex, path string, walPath string, opti
 		id:                id,
 		options:           options,
 		measurementFields: make(map[string]*MeasurementFields),
+		codecs:            make(map[string]*FieldCodec),
 
 		statMap:   statMap,
 		LogOutput: os.Stderr,
@@ -91,8 +119,127 @@ func NewShard(id uint64, index *DatabaseIndex, path string, walPath string, opti
 // Path returns the path set on the shard when it was created.
 func (s *Shard) Path() string { return s.path }
 
+// Shard states reported by State.
+const (
+	ShardStateOpen   = "open"
+	ShardStateClosed = "closed"
+)
+
+// State returns whether the shard's engine is open.
+func (s *Shard) State() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.engine != nil {
+		return ShardStateOpen
+	}
+	return ShardStateClosed
+}
+
+// touch marks the shard as accessed now.
+func (s *Shard) touch() { atomic.StoreInt64(&s.lastAccess, time.Now().UnixNano()) }
+
+// modified records that the shard's data changed.
+func (s *Shard) modified() { atomic.StoreInt64(&s.lastWrite, time.Now().UnixNano()) }
+
+// LastModified returns the last time the shard's data changed. Changes made
+// before the shard was opened are taken from the modification time of its
+// file so the shard doesn't need to be opened.
+func (s *Shard) LastModified() (time.Time, error) {
+	s.mu.RLock()
+	fi, err := os.Stat(s.path)
+	s.mu.RUnlock()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	t := fi.ModTime()
+	if w := time.Unix(0, atomic.LoadInt64(&s.lastWrite)); w.After(t) {
+		t = w
+	}
+	return t, nil
+}
+
+// ready marks the shard as accessed and opens it if it was closed lazily.
+func (s *Shard) ready() error {
+	s.touch()
+
+	s.mu.RLock()
+	open, lazy := s.engine != nil, s.lazy
+	s.mu.RUnlock()
+	if open {
+		return nil
+	} else if !lazy {
+		return ErrShardClosed
+	}
+	return s.open(true)
+}
+
+// acquire opens the shard if needed and returns its engine. The shard isn't
+// closed while idle until release is called.
+func (s *Shard) acquire() (Engine, error) {
+	for {
+		if err := s.ready(); err != nil {
+			return nil, err
+		}
+
+		// The shard may have been closed again since it was opened.
+		s.mu.RLock()
+		e := s.engine
+		if e != nil {
+			atomic.AddInt64(&s.txN, 1)
+		}
+		s.mu.RUnlock()
+		if e != nil {
+			return e, nil
+		}
+	}
+}
+
+// release releases an engine returned by acquire.
+func (s *Shard) release() {
+	s.touch()
+	atomic.AddInt64(&s.txN, -1)
+}
+
+// loadIndex loads the series and fields of a lazily opened shard into the
+// index. The shard is closed again afterwards unless it's in use.
+func (s *Shard) loadIndex() error {
+	s.mu.RLock()
+	indexed := s.indexed
+	s.mu.RUnlock()
+	if indexed {
+		return nil
+	}
+
+	if err := s.ready(); err != nil {
+		return err
+	}
+	_, err := s.closeIdle(0)
+	return err
+}
+
+// closeIdle closes the shard if it hasn't been accessed for d and has no open
+// transactions. It's reopened on its next access. Returns true if it was closed.
+func (s *Shard) closeIdle(d time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.engine == nil || atomic.LoadInt64(&s.txN) > 0 {
+		return false, nil
+	} else if time.Since(time.Unix(0, atomic.LoadInt64(&s.lastAccess))) < d {
+		return false, nil
+	}
+
+	s.lazy = true
+	return true, s.close()
+}
+
 // open initializes and opens the shard's store.
-func (s *Shard) Open() error {
+func (s *Shard) Open() error { return s.open(false) }
+
+// open opens the shard. If lazy is set the shard is only opened if it was
+// closed lazily, so a shard closed by Close isn't reopened.
+func (s *Shard) open(lazy bool) error {
 	if err := func() error {
 		s.mu.Lock()
 		defer s.mu.Unlock()
@@ -103,7 +250,10 @@ func (s *Shard) Open() error {
 		// Return if the shard is already open
 		if s.engine != nil {
 			return nil
+		} else if lazy && !s.lazy {
+			return ErrShardClosed
 		}
+		s.touch()
 
 		// Initialize underlying engine.
 		e, err := NewEngine(s.path, s.walPath, s.options)
@@ -120,10 +270,18 @@ func (s *Shard) Open() error {
 			return fmt.Errorf("open engine: %s", err)
 		}
 
-		// Load metadata index.
-		if err := s.engine.LoadMetadataIndex(s.index, s.measurementFields); err != nil {
+		// Load metadata index. The shard's series are loaded into a separate
+		// index first so they can be marked as defined in this shard.
+		index := NewDatabaseIndex()
+		if err := s.engine.LoadMetadataIndex(index, s.measurementFields); err != nil {
 			return fmt.Errorf("load metadata index: %s", err)
 		}
+		s.index.merge(index, s.id)
+		for name, m := range s.measurementFields {
+			s.setCodec(name, m.Codec)
+		}
+		s.setDuplicateResolver()
+		s.indexed = true
 
 		return nil
 	}(); err != nil {
@@ -138,12 +296,15 @@ func (s *Shard) Open() error {
 func (s *Shard) Close() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.lazy = false
 	return s.close()
 }
 
 func (s *Shard) close() error {
 	if s.engine != nil {
-		return s.engine.Close()
+		err := s.engine.Close()
+		s.engine = nil
+		return err
 	}
 	return nil
 }
@@ -164,7 +325,46 @@ func (s *Shard) DiskSize() (int64, error) {
 // ReadOnlyTx returns a read-only transaction for the shard.  The transaction must be rolled back to
 // release resources.
 func (s *Shard) ReadOnlyTx() (Tx, error) {
-	return s.engine.Begin(false)
+	if err := s.ready(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.engine == nil {
+		return nil, ErrShardClosed
+	}
+
+	tx, err := s.engine.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddInt64(&s.txN, 1)
+	return &shardTx{Tx: tx, shard: s}, nil
+}
+
+// shardTx tracks a read-only transaction so the shard isn't closed while it's open.
+type shardTx struct {
+	Tx
+	shard *Shard
+	once  sync.Once
+}
+
+// Commit commits the transaction.
+func (tx *shardTx) Commit() error {
+	tx.once.Do(tx.done)
+	return tx.Tx.Commit()
+}
+
+// Rollback rolls back the transaction.
+func (tx *shardTx) Rollback() error {
+	tx.once.Do(tx.done)
+	return tx.Tx.Rollback()
+}
+
+func (tx *shardTx) done() {
+	tx.shard.touch()
+	atomic.AddInt64(&tx.shard.txN, -1)
 }
 
 // TODO: this is temporarily exported to make tx.go work. When the query engine gets refactored
@@ -179,6 +379,67 @@ func (s *Shard) FieldCodec(measurementName string) *FieldCodec {
 	return m.Codec
 }
 
+// SetDuplicatePolicy sets how points written to a series at the same timestamp
+// are resolved. policy is one of the meta.DuplicatePolicy constants.
+func (s *Shard) SetDuplicatePolicy(policy string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.duplicatePolicy = policy
+	s.setDuplicateResolver()
+}
+
+// SetReadOnly sets whether writes to the shard are rejected.
+func (s *Shard) SetReadOnly(readOnly bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.readOnly = readOnly
+}
+
+// ReadOnly returns true if writes to the shard are rejected.
+func (s *Shard) ReadOnly() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.readOnly
+}
+
+// setDuplicateResolver passes the duplicate policy to the engine, if it supports one.
+// Must be called with s.mu held.
+func (s *Shard) setDuplicateResolver() {
+	e, ok := s.engine.(interface {
+		SetDuplicateResolver(r *DuplicateResolver)
+	})
+	if !ok {
+		return
+	}
+
+	var r *DuplicateResolver
+	if s.duplicatePolicy != "" && s.duplicatePolicy != meta.DuplicatePolicyReplace {
+		r = &DuplicateResolver{Policy: s.duplicatePolicy, FieldCodec: s.codec}
+	}
+	e.SetDuplicateResolver(r)
+}
+
+// codec returns the field codec for a measurement without taking the shard lock.
+func (s *Shard) codec(measurementName string) *FieldCodec {
+	s.codecMu.RLock()
+	defer s.codecMu.RUnlock()
+	if c := s.codecs[measurementName]; c != nil {
+		return c
+	}
+	return NewFieldCodec(nil)
+}
+
+// setCodec sets the field codec for a measurement. A nil codec removes it.
+func (s *Shard) setCodec(measurementName string, c *FieldCodec) {
+	s.codecMu.Lock()
+	defer s.codecMu.Unlock()
+	if c == nil {
+		delete(s.codecs, measurementName)
+		return
+	}
+	s.codecs[measurementName] = c
+}
+
 // struct to hold information for a field to create on a measurement
 type FieldCreate struct {
 	Measurement string
@@ -195,6 +456,16 @@ type SeriesCreate struct {
 func (s *Shard) WritePoints(points []models.Point) error {
 	s.statMap.Add(statWriteReq, 1)
 
+	if s.ReadOnly() {
+		return influxdb.ErrDatabaseReadOnly
+	}
+
+	e, err := s.acquire()
+	if err != nil {
+		return err
+	}
+	defer s.release()
+
 	seriesToCreate, fieldsToCreate, seriesToAddShardTo, err := s.validateSeriesAndFields(points)
 	if err != nil {
 		return err
@@ -253,7 +524,8 @@ func (s *Shard) WritePoints(points []models.Point) error {
 	}
 
 	// Write to the engine.
-	if err := s.engine.WritePoints(points, measurementFieldsToSave, seriesToCreate); err != nil {
+	s.modified()
+	if err := e.WritePoints(points, measurementFieldsToSave, seriesToCreate); err != nil {
 		s.statMap.Add(statWritePointsFail, 1)
 		return fmt.Errorf("engine: %s", err)
 	}
@@ -263,11 +535,16 @@ func (s *Shard) WritePoints(points []models.Point) error {
 }
 
 func (s *Shard) ValidateAggregateFieldsInStatement(measurementName string, stmt *influxql.SelectStatement) error {
+	if err := s.ready(); err != nil {
+		return err
+	}
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	// Durations can only be selected and compared, not aggregated.
 	validateType := func(aname, fname string, t influxql.DataType) error {
-		if t != influxql.Float && t != influxql.Integer {
+		if t != influxql.Float && t != influxql.Integer && t != influxql.Unsigned {
 			return fmt.Errorf("aggregate '%s' requires numerical field values. Field '%s' is of type %s",
 				aname, fname, t)
 		}
@@ -316,20 +593,34 @@ func (s *Shard) ValidateAggregateFieldsInStatement(measurementName string, stmt
 
 // DeleteSeries deletes a list of series.
 func (s *Shard) DeleteSeries(keys []string) error {
-	return s.engine.DeleteSeries(keys)
+	e, err := s.acquire()
+	if err != nil {
+		return err
+	}
+	defer s.release()
+	s.modified()
+	return e.DeleteSeries(keys)
 }
 
 // DeleteMeasurement deletes a measurement and all underlying series.
 func (s *Shard) DeleteMeasurement(name string, seriesKeys []string) error {
+	e, err := s.acquire()
+	if err != nil {
+		return err
+	}
+	defer s.release()
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if err := s.engine.DeleteMeasurement(name, seriesKeys); err != nil {
+	s.modified()
+	if err := e.DeleteMeasurement(name, seriesKeys); err != nil {
 		return err
 	}
 
 	// Remove entry from shard index.
 	delete(s.measurementFields, name)
+	s.setCodec(name, nil)
 
 	return nil
 }
@@ -363,6 +654,7 @@ func (s *Shard) createFieldsAndMeasurements(fieldsToCreate []*FieldCreate) (map[
 		if err := m.CreateFieldIfNotExists(f.Field.Name, f.Field.Type); err != nil {
 			return nil, err
 		}
+		s.setCodec(f.Measurement, m.Codec)
 
 		// ensure the measurement is in the index and the field is there
 		measurement := s.index.CreateMeasurementIndexIfNotExists(f.Measurement)
@@ -426,11 +718,25 @@ func (s *Shard) validateSeriesAndFields(points []models.Point) ([]*SeriesCreate,
 }
 
 // SeriesCount returns the number of series buckets on the shard.
-func (s *Shard) SeriesCount() (int, error) { return s.engine.SeriesCount() }
+func (s *Shard) SeriesCount() (int, error) {
+	e, err := s.acquire()
+	if err != nil {
+		return 0, err
+	}
+	defer s.release()
+	return e.SeriesCount()
+}
 
 // WriteTo writes the shard's data to w.
 func (s *Shard) WriteTo(w io.Writer) (int64, error) {
-	n, err := s.engine.WriteTo(w)
+	// Keep the shard open while it's copied.
+	e, err := s.acquire()
+	if err != nil {
+		return 0, err
+	}
+	defer s.release()
+
+	n, err := e.WriteTo(w)
 	s.statMap.Add(statWriteBytes, int64(n))
 	return n, err
 }
@@ -563,6 +869,14 @@ func (f *FieldCodec) EncodeFields(values map[string]interface{}) ([]byte, error)
 			}
 			buf = make([]byte, 9)
 			binary.BigEndian.PutUint64(buf[1:9], value)
+		case influxql.Unsigned:
+			value := v.(uint64)
+			buf = make([]byte, 9)
+			binary.BigEndian.PutUint64(buf[1:9], value)
+		case influxql.Duration:
+			value := v.(time.Duration)
+			buf = make([]byte, 9)
+			binary.BigEndian.PutUint64(buf[1:9], uint64(value))
 		case influxql.Boolean:
 			value := v.(bool)
 
@@ -640,6 +954,14 @@ func (f *FieldCodec) DecodeFields(b []byte) (map[uint8]interface{}, error) {
 			value = int64(binary.BigEndian.Uint64(b[1:9]))
 			// Move bytes forward.
 			b = b[9:]
+		case influxql.Unsigned:
+			value = binary.BigEndian.Uint64(b[1:9])
+			// Move bytes forward.
+			b = b[9:]
+		case influxql.Duration:
+			value = time.Duration(binary.BigEndian.Uint64(b[1:9]))
+			// Move bytes forward.
+			b = b[9:]
 		case influxql.Boolean:
 			if b[1] == 1 {
 				value = true
@@ -713,6 +1035,12 @@ func (f *FieldCodec) DecodeByID(targetID uint8, b []byte) (interface{}, error) {
 		case influxql.Integer:
 			value = int64(binary.BigEndian.Uint64(b[1:9]))
 			b = b[9:]
+		case influxql.Unsigned:
+			value = binary.BigEndian.Uint64(b[1:9])
+			b = b[9:]
+		case influxql.Duration:
+			value = time.Duration(binary.BigEndian.Uint64(b[1:9]))
+			b = b[9:]
 		case influxql.Boolean:
 			if b[1] == 1 {
 				value = true
@@ -748,6 +1076,65 @@ func (f *FieldCodec) DecodeByName(name string, b []byte) (interface{}, error) {
 	return f.DecodeByID(fi.ID, b)
 }
 
+// MergeFields combines two encoded sets of fields. Values in b replace values in
+// a for fields in both, and fields only in a are kept.
+func (f *FieldCodec) MergeFields(a, b []byte) ([]byte, error) {
+	newFields, err := f.splitFields(b)
+	if err != nil {
+		return nil, err
+	}
+	oldFields, err := f.splitFields(a)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make(map[uint8]struct{}, len(newFields))
+	for _, field := range newFields {
+		ids[field[0]] = struct{}{}
+	}
+
+	other := make([]byte, 0, len(a)+len(b))
+	other = append(other, b...)
+	for _, field := range oldFields {
+		if _, ok := ids[field[0]]; !ok {
+			other = append(other, field...)
+		}
+	}
+	return other, nil
+}
+
+// splitFields returns each encoded field in b, including its leading field ID.
+func (f *FieldCodec) splitFields(b []byte) ([][]byte, error) {
+	var a [][]byte
+	for len(b) > 0 {
+		field := f.fieldsByID[b[0]]
+		if field == nil {
+			return nil, ErrFieldUnmappedID
+		}
+
+		var n int
+		switch field.Type {
+		case influxql.Float, influxql.Integer, influxql.Unsigned, influxql.Duration:
+			n = 9
+		case influxql.Boolean:
+			n = 2
+		case influxql.String:
+			if len(b) < 3 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			n = 3 + int(binary.BigEndian.Uint16(b[1:3]))
+		default:
+			panic(fmt.Sprintf("unsupported value type during split fields: %T", field.Type))
+		}
+		if len(b) < n {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		a, b = append(a, b[:n]), b[n:]
+	}
+	return a, nil
+}
+
 // FieldByName returns the field by its name. It will return a nil if not found
 func (f *FieldCodec) fieldByName(name string) *Field {
 	return f.fieldsByName[name]
@@ -10,6 +10,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/influxdb/influxdb/influxql"
 	"github.com/influxdb/influxdb/models"
 	"github.com/influxdb/influxdb/tsdb"
 	"github.com/influxdb/influxdb/tsdb/engine/b1"
@@ -140,6 +141,39 @@ func TestShardWriteAddNewField(t *testing.T) {
 
 }
 
+// Ensure the last modification time of a shard changes when it's written to.
+func TestShard_LastModified(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir("", "shard_test")
+	defer os.RemoveAll(tmpDir)
+
+	opts := tsdb.NewEngineOptions()
+	opts.Config.WALDir = filepath.Join(tmpDir, "wal")
+
+	sh := tsdb.NewShard(1, tsdb.NewDatabaseIndex(), filepath.Join(tmpDir, "shard"), filepath.Join(tmpDir, "wal"), opts)
+	if err := sh.Open(); err != nil {
+		t.Fatalf("error opening shard: %s", err)
+	}
+	defer sh.Close()
+
+	t0, err := sh.LastModified()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if err := sh.WritePoints([]models.Point{
+		models.NewPoint("cpu", models.Tags{"host": "server"}, models.Fields{"value": 1.0}, time.Unix(1, 2)),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if t1, err := sh.LastModified(); err != nil {
+		t.Fatal(err)
+	} else if !t1.After(t0) {
+		t.Fatalf("last modified time not updated: %s <= %s", t1, t0)
+	}
+}
+
 // Ensure the shard will automatically flush the WAL after a threshold has been reached.
 func TestShard_Autoflush(t *testing.T) {
 	path, _ := ioutil.TempDir("", "shard_test")
@@ -346,3 +380,39 @@ func chunkedWrite(shard *tsdb.Shard, points []models.Point) {
 		end += chunkSz
 	}
 }
+
+// Ensure the codec can merge two sets of encoded fields.
+func TestFieldCodec_MergeFields(t *testing.T) {
+	codec := tsdb.NewFieldCodec(map[string]*tsdb.Field{
+		"value": {ID: uint8(1), Name: "value", Type: influxql.Float},
+		"host":  {ID: uint8(2), Name: "host", Type: influxql.String},
+		"up":    {ID: uint8(3), Name: "up", Type: influxql.Boolean},
+		"wait":  {ID: uint8(4), Name: "wait", Type: influxql.Duration},
+	})
+
+	a, err := codec.EncodeFields(map[string]interface{}{"value": float64(1), "host": "server01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := codec.EncodeFields(map[string]interface{}{"value": float64(2), "up": true, "wait": time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	merged, err := codec.MergeFields(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields, err := codec.DecodeFieldsWithNames(merged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := map[string]interface{}{"value": float64(2), "host": "server01", "up": true, "wait": time.Second}; !reflect.DeepEqual(fields, exp) {
+		t.Fatalf("unexpected fields: %v", fields)
+	}
+
+	// Fields without a mapping can't be merged.
+	if _, err := codec.MergeFields([]byte{9, 0}, b); err != tsdb.ErrFieldUnmappedID {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -8,7 +8,6 @@ import (
 	"path/filepath"
 	"time"
 
-	"github.com/boltdb/bolt"
 	"github.com/influxdb/influxdb/snapshot"
 )
 
@@ -17,6 +16,7 @@ import (
 func NewSnapshotWriter(meta []byte, store *Store) (*snapshot.Writer, error) {
 	// Create snapshot writer.
 	sw := snapshot.NewWriter()
+	sw.Manifest.Timestamp = time.Now().UTC()
 	if err := func() error {
 		// Create meta file.
 		f := &snapshot.File{
@@ -83,7 +83,7 @@ func appendShardSnapshotFile(sw *snapshot.Writer, sh *Shard, name string) error
 	}
 
 	// Begin transaction.
-	tx, err := sh.db.Begin(false)
+	tx, err := sh.ReadOnlyTx()
 	if err != nil {
 		return fmt.Errorf("begin: %s", err)
 	}
@@ -97,17 +97,17 @@ func appendShardSnapshotFile(sw *snapshot.Writer, sh *Shard, name string) error
 
 	// Append to snapshot writer.
 	sw.Manifest.Files = append(sw.Manifest.Files, f)
-	sw.FileWriters[f.Name] = &boltTxCloser{tx}
+	sw.FileWriters[f.Name] = &txCloser{tx}
 	return nil
 }
 
-// boltTxCloser wraps a Bolt transaction to implement io.Closer.
-type boltTxCloser struct {
-	*bolt.Tx
+// txCloser wraps a shard transaction to implement io.Closer.
+type txCloser struct {
+	Tx
 }
 
 // Close rolls back the transaction.
-func (tx *boltTxCloser) Close() error { return tx.Rollback() }
+func (tx *txCloser) Close() error { return tx.Rollback() }
 
 // NopWriteToCloser returns an io.WriterTo that implements io.Closer.
 func NopWriteToCloser(w io.WriterTo) interface {
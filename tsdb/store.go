@@ -2,6 +2,7 @@ package tsdb
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
@@ -9,6 +10,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/influxdb/influxdb/influxql"
 	"github.com/influxdb/influxdb/models"
@@ -19,9 +21,12 @@ func NewStore(path string) *Store {
 	opts.Config = NewConfig()
 
 	return &Store{
-		path:          path,
-		EngineOptions: opts,
-		Logger:        log.New(os.Stderr, "[store] ", log.LstdFlags),
+		path:              path,
+		duplicatePolicies: make(map[string]map[string]string),
+		readOnly:          make(map[string]bool),
+		moving:            make(map[uint64]bool),
+		EngineOptions:     opts,
+		Logger:            log.New(os.Stderr, "[store] ", log.LstdFlags),
 	}
 }
 
@@ -36,14 +41,31 @@ type Store struct {
 	databaseIndexes map[string]*DatabaseIndex
 	shards          map[uint64]*Shard
 
+	// duplicate point policies by database and retention policy
+	duplicatePolicies map[string]map[string]string
+
+	// databases that reject writes
+	readOnly map[string]bool
+
+	// shards being moved by MoveShard
+	moving map[uint64]bool
+
+	// closed once lazily loaded shards have been added to the database indexes
+	indexed chan struct{}
+
 	EngineOptions EngineOptions
 	Logger        *log.Logger
 	closing       chan struct{}
+	wg            sync.WaitGroup
 }
 
 // Path returns the store's root path.
 func (s *Store) Path() string { return s.path }
 
+// ColdPath returns the root path that cold shards are moved to. Returns an empty
+// string if tiered storage isn't configured.
+func (s *Store) ColdPath() string { return s.EngineOptions.Config.ColdDir }
+
 // DatabaseIndexN returns the number of databases indicies in the store.
 func (s *Store) DatabaseIndexN() int {
 	s.mu.RLock()
@@ -58,6 +80,18 @@ func (s *Store) Shard(id uint64) *Shard {
 	return s.shards[id]
 }
 
+// ShardState returns whether a shard is open or closed. Returns an empty string
+// if the shard doesn't exist.
+func (s *Store) ShardState(shardID uint64) string {
+	s.mu.RLock()
+	sh := s.shards[shardID]
+	s.mu.RUnlock()
+	if sh == nil {
+		return ""
+	}
+	return sh.State()
+}
+
 // ShardN returns the number of shard in the store.
 func (s *Store) ShardN() int {
 	s.mu.RLock()
@@ -80,6 +114,31 @@ func (s *Store) CreateShard(database, retentionPolicy string, shardID uint64) er
 		return nil
 	}
 
+	return s.openShard(database, retentionPolicy, shardID, "")
+}
+
+// RestoreShard moves the shard data file at path into the store and opens it.
+// Returns an error if the shard already exists.
+func (s *Store) RestoreShard(database, retentionPolicy string, shardID uint64, path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.closing:
+		return fmt.Errorf("closing")
+	default:
+	}
+
+	if _, ok := s.shards[shardID]; ok {
+		return fmt.Errorf("shard already exists: %d", shardID)
+	}
+
+	return s.openShard(database, retentionPolicy, shardID, path)
+}
+
+// openShard creates and opens a shard. If src is set the shard's data file is
+// moved from there first. Must be called with s.mu held.
+func (s *Store) openShard(database, retentionPolicy string, shardID uint64, src string) error {
 	// created the db and retention policy dirs if they don't exist
 	if err := os.MkdirAll(filepath.Join(s.path, database, retentionPolicy), 0700); err != nil {
 		return err
@@ -99,10 +158,18 @@ func (s *Store) CreateShard(database, retentionPolicy string, shardID uint64) er
 	}
 
 	shardPath := filepath.Join(s.path, database, retentionPolicy, strconv.FormatUint(shardID, 10))
+	if src != "" {
+		if err := moveFile(src, shardPath); err != nil {
+			return err
+		}
+	}
+
 	shard := NewShard(shardID, db, shardPath, walPath, s.EngineOptions)
 	if err := shard.Open(); err != nil {
 		return err
 	}
+	shard.SetDuplicatePolicy(s.duplicatePolicies[database][retentionPolicy])
+	shard.SetReadOnly(s.readOnly[database])
 
 	s.shards[shardID] = shard
 
@@ -150,6 +217,11 @@ func (s *Store) DeleteDatabase(name string, shardIDs []uint64) error {
 	if err := os.RemoveAll(filepath.Join(s.path, name)); err != nil {
 		return err
 	}
+	if cold := s.ColdPath(); cold != "" {
+		if err := os.RemoveAll(filepath.Join(cold, name)); err != nil {
+			return err
+		}
+	}
 	if err := os.RemoveAll(filepath.Join(s.EngineOptions.Config.WALDir, name)); err != nil {
 		return err
 	}
@@ -157,6 +229,210 @@ func (s *Store) DeleteDatabase(name string, shardIDs []uint64) error {
 	return nil
 }
 
+// ColdShardPath returns the path of a shard if it has been moved to the cold data
+// directory. Returns an empty string if the shard doesn't exist or isn't cold.
+func (s *Store) ColdShardPath(shardID uint64) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sh := s.shards[shardID]
+	if sh == nil || !s.isCold(sh.path) {
+		return ""
+	}
+	return sh.path
+}
+
+// isCold returns true if path is under the cold data directory.
+func (s *Store) isCold(path string) bool {
+	cold := s.ColdPath()
+	if cold == "" {
+		return false
+	}
+	rel, err := filepath.Rel(cold, path)
+	return err == nil && !strings.HasPrefix(rel, "..")
+}
+
+// MoveShard relocates a shard's data file under the root directory dir, keeping its
+// database and retention policy layout. The shard is closed while it's moved and
+// reopened at the new path. The WAL is left where it is.
+func (s *Store) MoveShard(shardID uint64, dir string) error {
+	sh, path, err := s.beginMove(shardID, dir)
+	if err != nil || sh == nil {
+		return err
+	}
+	defer s.endMove(shardID)
+
+	// The shard is closed while it's moved so it can't be written to. Only the
+	// shard is unavailable; the store isn't locked while its files are copied.
+	if err := sh.Close(); err != nil {
+		return err
+	}
+
+	// reopen the shard where it was if it can't be moved
+	moveErr := moveFile(sh.path, path)
+	if moveErr != nil {
+		path = sh.path
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The shard may have been deleted while it was moved.
+	if s.shards[shardID] != sh {
+		if moveErr == nil {
+			os.RemoveAll(path)
+		}
+		return ErrShardNotFound
+	}
+
+	database, retentionPolicy := shardLocation(sh.path)
+	shard := NewShard(shardID, sh.index, path, sh.walPath, s.EngineOptions)
+	if err := shard.Open(); err != nil {
+		delete(s.shards, shardID)
+		return fmt.Errorf("failed to open shard %d: %s", shardID, err)
+	}
+	shard.SetDuplicatePolicy(s.duplicatePolicies[database][retentionPolicy])
+	shard.SetReadOnly(s.readOnly[database])
+	s.shards[shardID] = shard
+
+	if moveErr != nil {
+		return fmt.Errorf("move shard %d: %s", shardID, moveErr)
+	}
+	return nil
+}
+
+// beginMove marks a shard as being moved and returns it along with the path it
+// will be moved to. Returns a nil shard if it's already in dir.
+func (s *Store) beginMove(shardID uint64, dir string) (*Shard, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.closing:
+		return nil, "", fmt.Errorf("closing")
+	default:
+	}
+
+	sh, ok := s.shards[shardID]
+	if !ok {
+		return nil, "", ErrShardNotFound
+	} else if s.moving[shardID] {
+		return nil, "", fmt.Errorf("shard %d is already being moved", shardID)
+	}
+
+	database, retentionPolicy := shardLocation(sh.path)
+	path := filepath.Join(dir, database, retentionPolicy, strconv.FormatUint(shardID, 10))
+	if path == sh.path {
+		return nil, "", nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, "", err
+	}
+
+	s.moving[shardID] = true
+	return sh, path, nil
+}
+
+// endMove clears the move started by beginMove.
+func (s *Store) endMove(shardID uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.moving, shardID)
+}
+
+// moveFile renames src to dst. If they're on different devices the file is copied
+// and synced before src is removed.
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+
+	return os.Remove(src)
+}
+
+// shardLocation returns the database and retention policy of a shard path.
+func shardLocation(path string) (database, retentionPolicy string) {
+	// shard paths are <root>/<database>/<retention policy>/<id>
+	rpPath := filepath.Dir(path)
+	return filepath.Base(filepath.Dir(rpPath)), filepath.Base(rpPath)
+}
+
+// SetDuplicatePolicy sets the duplicate point policy of a retention policy's
+// shards. Shards created or loaded later use it too.
+func (s *Store) SetDuplicatePolicy(database, retentionPolicy, policy string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if p, ok := s.duplicatePolicies[database][retentionPolicy]; ok && p == policy {
+		return
+	}
+	if s.duplicatePolicies == nil {
+		s.duplicatePolicies = make(map[string]map[string]string)
+	}
+	if s.duplicatePolicies[database] == nil {
+		s.duplicatePolicies[database] = make(map[string]string)
+	}
+	s.duplicatePolicies[database][retentionPolicy] = policy
+
+	for _, sh := range s.shards {
+		if db, rp := shardLocation(sh.path); db == database && rp == retentionPolicy {
+			sh.SetDuplicatePolicy(policy)
+		}
+	}
+}
+
+// SetDatabaseReadOnly sets whether writes to a database's shards are rejected.
+// Shards created or loaded later use it too.
+func (s *Store) SetDatabaseReadOnly(database string, readOnly bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.readOnly[database] == readOnly {
+		return
+	}
+	if s.readOnly == nil {
+		s.readOnly = make(map[string]bool)
+	}
+	if readOnly {
+		s.readOnly[database] = true
+	} else {
+		delete(s.readOnly, database)
+	}
+
+	for _, sh := range s.shards {
+		if db, _ := shardLocation(sh.path); db == database {
+			sh.SetReadOnly(readOnly)
+		}
+	}
+}
+
 // ShardIDs returns a slice of all ShardIDs under management.
 func (s *Store) ShardIDs() []uint64 {
 	ids := make([]uint64, 0, len(s.shards))
@@ -176,10 +452,17 @@ func (s *Store) ValidateAggregateFieldsInStatement(shardID uint64, measurementNa
 	return shard.ValidateAggregateFieldsInStatement(measurementName, stmt)
 }
 
+// DatabaseIndex returns the index of a database. If shards are loaded lazily
+// it waits until their series have been added to the index.
 func (s *Store) DatabaseIndex(name string) *DatabaseIndex {
 	s.mu.RLock()
-	defer s.mu.RUnlock()
-	return s.databaseIndexes[name]
+	db, indexed := s.databaseIndexes[name], s.indexed
+	s.mu.RUnlock()
+
+	if db != nil && indexed != nil {
+		<-indexed
+	}
+	return db
 }
 
 // Databases returns all the databases in the indexes
@@ -243,26 +526,50 @@ func (s *Store) deleteMeasurement(name string, seriesKeys []string) error {
 	return nil
 }
 
-func (s *Store) loadIndexes() error {
-	dbs, err := ioutil.ReadDir(s.path)
-	if err != nil {
-		return err
+// dataDirs returns the root directories that shards are stored in.
+func (s *Store) dataDirs() []string {
+	if cold := s.ColdPath(); cold != "" {
+		return []string{s.path, cold}
 	}
-	for _, db := range dbs {
-		if !db.IsDir() {
-			s.Logger.Printf("Skipping database dir: %s. Not a directory", db.Name())
-			continue
+	return []string{s.path}
+}
+
+func (s *Store) loadIndexes() error {
+	for _, dir := range s.dataDirs() {
+		dbs, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, db := range dbs {
+			if !db.IsDir() {
+				s.Logger.Printf("Skipping database dir: %s. Not a directory", db.Name())
+				continue
+			}
+			if _, ok := s.databaseIndexes[db.Name()]; !ok {
+				s.databaseIndexes[db.Name()] = NewDatabaseIndex()
+			}
 		}
-		s.databaseIndexes[db.Name()] = NewDatabaseIndex()
 	}
 	return nil
 }
 
 func (s *Store) loadShards() error {
+	for _, dir := range s.dataDirs() {
+		if err := s.loadShardsFrom(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadShardsFrom opens the shards under the root directory dir.
+func (s *Store) loadShardsFrom(dir string) error {
 	// loop through the current database indexes
 	for db := range s.databaseIndexes {
-		rps, err := ioutil.ReadDir(filepath.Join(s.path, db))
-		if err != nil {
+		rps, err := ioutil.ReadDir(filepath.Join(dir, db))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
 			return err
 		}
 
@@ -273,12 +580,12 @@ func (s *Store) loadShards() error {
 				continue
 			}
 
-			shards, err := ioutil.ReadDir(filepath.Join(s.path, db, rp.Name()))
+			shards, err := ioutil.ReadDir(filepath.Join(dir, db, rp.Name()))
 			if err != nil {
 				return err
 			}
 			for _, sh := range shards {
-				path := filepath.Join(s.path, db, rp.Name(), sh.Name())
+				path := filepath.Join(dir, db, rp.Name(), sh.Name())
 				walPath := filepath.Join(s.EngineOptions.Config.WALDir, db, rp.Name(), sh.Name())
 
 				// Shard file names are numeric shardIDs
@@ -288,11 +595,20 @@ func (s *Store) loadShards() error {
 					continue
 				}
 
+				// a shard left in both directories by an interrupted move keeps the first copy
+				if _, ok := s.shards[shardID]; ok {
+					s.Logger.Printf("Skipping shard: %s. Already loaded from %s", path, s.shards[shardID].path)
+					continue
+				}
+
 				shard := NewShard(shardID, s.databaseIndexes[db], path, walPath, s.EngineOptions)
-				err = shard.Open()
-				if err != nil {
+				if s.EngineOptions.Config.LazyLoadShards {
+					shard.lazy = true
+				} else if err := shard.Open(); err != nil {
 					return fmt.Errorf("failed to open shard %d: %s", shardID, err)
 				}
+				shard.SetDuplicatePolicy(s.duplicatePolicies[db][rp.Name()])
+				shard.SetReadOnly(s.readOnly[db])
 				s.shards[shardID] = shard
 			}
 		}
@@ -316,6 +632,12 @@ func (s *Store) Open() error {
 	if err := os.MkdirAll(s.path, 0777); err != nil {
 		return err
 	}
+	if cold := s.ColdPath(); cold != "" {
+		s.Logger.Printf("Using cold data dir: %v", cold)
+		if err := os.MkdirAll(cold, 0777); err != nil {
+			return err
+		}
+	}
 
 	// TODO: Start AE for Node
 	if err := s.loadIndexes(); err != nil {
@@ -326,9 +648,76 @@ func (s *Store) Open() error {
 		return err
 	}
 
+	// Lazily loaded shards are indexed in the background so queries see
+	// their series without waiting for the shards to be opened.
+	s.indexed = nil
+	if s.EngineOptions.Config.LazyLoadShards {
+		var shards []*Shard
+		for _, sh := range s.shards {
+			shards = append(shards, sh)
+		}
+		s.indexed = make(chan struct{})
+		s.wg.Add(1)
+		go s.loadShardIndexes(s.closing, s.indexed, shards)
+	}
+
+	if d := time.Duration(s.EngineOptions.Config.ShardIdleTimeout); d > 0 {
+		s.wg.Add(1)
+		go s.closeIdleShards(s.closing, d)
+	}
+
 	return nil
 }
 
+// loadShardIndexes loads the series of lazily loaded shards into the database
+// indexes, closing the shards again unless they're in use. indexed is closed
+// when it's done.
+func (s *Store) loadShardIndexes(closing, indexed chan struct{}, shards []*Shard) {
+	defer s.wg.Done()
+	defer close(indexed)
+
+	for _, sh := range shards {
+		select {
+		case <-closing:
+			return
+		default:
+		}
+
+		if err := sh.loadIndex(); err != nil && err != ErrShardClosed {
+			s.Logger.Printf("failed to index shard %d: %s", sh.id, err)
+		}
+	}
+}
+
+// closeIdleShards periodically closes shards that haven't been accessed for d.
+func (s *Store) closeIdleShards(closing chan struct{}, d time.Duration) {
+	defer s.wg.Done()
+
+	interval := d / 2
+	if interval < 100*time.Millisecond {
+		interval = 100 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-closing:
+			return
+		case <-ticker.C:
+			s.mu.RLock()
+			for id, sh := range s.shards {
+				if closed, err := sh.closeIdle(d); err != nil {
+					s.Logger.Printf("failed to close idle shard %d: %s", id, err)
+				} else if closed {
+					s.Logger.Printf("closed idle shard %d", id)
+				}
+			}
+			s.mu.RUnlock()
+		}
+	}
+}
+
 func (s *Store) WriteToShard(shardID uint64, points []models.Point) error {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -340,9 +729,45 @@ func (s *Store) WriteToShard(shardID uint64, points []models.Point) error {
 	return sh.WritePoints(points)
 }
 
+// ShardDigest returns the digests of the series in a shard between min,
+// inclusive, and max, exclusive.
+func (s *Store) ShardDigest(shardID uint64, min, max int64, depth int) ([]*SeriesDigest, error) {
+	sh := s.Shard(shardID)
+	if sh == nil {
+		return nil, ErrShardNotFound
+	}
+	return sh.Digest(min, max, depth)
+}
+
+// ShardLastModified returns the last time the data of a shard changed.
+func (s *Store) ShardLastModified(shardID uint64) (time.Time, error) {
+	sh := s.Shard(shardID)
+	if sh == nil {
+		return time.Time{}, ErrShardNotFound
+	}
+	return sh.LastModified()
+}
+
+// ShardSeriesPoints returns the points of a series in a shard between min,
+// inclusive, and max, exclusive.
+func (s *Store) ShardSeriesPoints(shardID uint64, key string, min, max int64) ([]models.Point, error) {
+	sh := s.Shard(shardID)
+	if sh == nil {
+		return nil, ErrShardNotFound
+	}
+	return sh.SeriesPoints(key, min, max)
+}
+
 func (s *Store) CreateMapper(shardID uint64, stmt influxql.Statement, chunkSize int) (Mapper, error) {
 	shard := s.Shard(shardID)
 
+	// Load the shard's series into the index before the statement is rewritten.
+	if shard != nil {
+		if err := shard.ready(); err != nil {
+			return nil, err
+		}
+	}
+
 	switch stmt := stmt.(type) {
 	case *influxql.SelectStatement:
 		if (stmt.IsRawQuery && !stmt.HasDistinct()) || stmt.IsSimpleDerivative() {
@@ -364,6 +789,15 @@ func (s *Store) CreateMapper(shardID uint64, stmt influxql.Statement, chunkSize
 }
 
 func (s *Store) Close() error {
+	// Stop closing idle shards before the shards are closed.
+	s.mu.Lock()
+	if s.closing != nil {
+		close(s.closing)
+	}
+	s.closing = nil
+	s.mu.Unlock()
+	s.wg.Wait()
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -372,10 +806,6 @@ func (s *Store) Close() error {
 			return err
 		}
 	}
-	if s.closing != nil {
-		close(s.closing)
-	}
-	s.closing = nil
 	s.shards = nil
 	s.databaseIndexes = nil
 
@@ -4,10 +4,13 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
 	"testing"
 	"time"
 
+	"github.com/influxdb/influxdb"
 	"github.com/influxdb/influxdb/models"
+	"github.com/influxdb/influxdb/toml"
 	"github.com/influxdb/influxdb/tsdb"
 )
 
@@ -277,6 +280,200 @@ func TestStoreEnsureSeriesPersistedInNewShards(t *testing.T) {
 	}
 }
 
+// Ensure a shard can be moved to the cold data directory and is loaded from there on reopen.
+func TestStoreMoveShard(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store_test")
+	if err != nil {
+		t.Fatalf("Store.Open() failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := tsdb.NewStore(filepath.Join(dir, "data"))
+	s.EngineOptions.Config.WALDir = filepath.Join(dir, "wal")
+	s.EngineOptions.Config.ColdDir = filepath.Join(dir, "cold")
+	if err := s.Open(); err != nil {
+		t.Fatalf("Store.Open() failed: %v", err)
+	}
+
+	if err := s.CreateShard("foo", "default", 1); err != nil {
+		t.Fatalf("error creating shard: %v", err)
+	}
+
+	p, _ := models.ParsePoints([]byte("cpu val=1 10"))
+	if err := s.WriteToShard(1, p); err != nil {
+		t.Fatalf("error writing to shard: %v", err)
+	}
+
+	if path := s.ColdShardPath(1); path != "" {
+		t.Fatalf("unexpected cold shard path: %s", path)
+	}
+
+	if err := s.MoveShard(1, s.ColdPath()); err != nil {
+		t.Fatalf("error moving shard: %v", err)
+	}
+
+	exp := filepath.Join(dir, "cold", "foo", "default", "1")
+	if path := s.ColdShardPath(1); path != exp {
+		t.Fatalf("cold shard path mismatch: got %v, exp %v", path, exp)
+	} else if _, err := os.Stat(filepath.Join(dir, "data", "foo", "default", "1")); !os.IsNotExist(err) {
+		t.Fatalf("expected shard to be removed from the data dir: %v", err)
+	}
+
+	// the moved shard should still take writes
+	p, _ = models.ParsePoints([]byte("cpu val=2 20"))
+	if err := s.WriteToShard(1, p); err != nil {
+		t.Fatalf("error writing to moved shard: %v", err)
+	}
+	s.Close()
+
+	s = tsdb.NewStore(filepath.Join(dir, "data"))
+	s.EngineOptions.Config.WALDir = filepath.Join(dir, "wal")
+	s.EngineOptions.Config.ColdDir = filepath.Join(dir, "cold")
+	if err := s.Open(); err != nil {
+		t.Fatalf("Store.Open() failed: %v", err)
+	}
+	defer s.Close()
+
+	if got := s.ShardN(); got != 1 {
+		t.Fatalf("shard count mismatch: got %v, exp %v", got, 1)
+	} else if path := s.ColdShardPath(1); path != exp {
+		t.Fatalf("cold shard path mismatch: got %v, exp %v", path, exp)
+	}
+	if d := s.DatabaseIndex("foo"); d == nil || d.Series("cpu") == nil {
+		t.Fatal("expected series cpu to be in the index")
+	}
+}
+
+// Ensure shards are opened on first access when lazy loading is enabled.
+func TestStoreOpen_LazyLoadShards(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store_test")
+	if err != nil {
+		t.Fatalf("Store.Open() failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := tsdb.NewStore(filepath.Join(dir, "data"))
+	s.EngineOptions.Config.WALDir = filepath.Join(dir, "wal")
+	if err := s.Open(); err != nil {
+		t.Fatalf("Store.Open() failed: %v", err)
+	}
+	for _, id := range []uint64{1, 2} {
+		if err := s.CreateShard("foo", "default", id); err != nil {
+			t.Fatalf("error creating shard: %v", err)
+		}
+	}
+	p, _ := models.ParsePoints([]byte("cpu val=1 10"))
+	if err := s.WriteToShard(1, p); err != nil {
+		t.Fatalf("error writing to shard: %v", err)
+	}
+	s.Close()
+
+	s = tsdb.NewStore(filepath.Join(dir, "data"))
+	s.EngineOptions.Config.WALDir = filepath.Join(dir, "wal")
+	s.EngineOptions.Config.LazyLoadShards = true
+	if err := s.Open(); err != nil {
+		t.Fatalf("Store.Open() failed: %v", err)
+	}
+	defer s.Close()
+
+	// The index holds the series of the shards, which are left closed.
+	if d := s.DatabaseIndex("foo"); d == nil || d.Series("cpu") == nil {
+		t.Fatal("expected series cpu to be in the index")
+	}
+	for _, id := range []uint64{1, 2} {
+		if state := s.ShardState(id); state != tsdb.ShardStateClosed {
+			t.Fatalf("unexpected shard %d state: %s", id, state)
+		}
+	}
+
+	// Writing opens the shard.
+	p, _ = models.ParsePoints([]byte("cpu val=2 20"))
+	if err := s.WriteToShard(2, p); err != nil {
+		t.Fatalf("error writing to shard: %v", err)
+	} else if state := s.ShardState(2); state != tsdb.ShardStateOpen {
+		t.Fatalf("unexpected shard state: %s", state)
+	} else if state := s.ShardState(1); state != tsdb.ShardStateClosed {
+		t.Fatalf("unexpected shard state: %s", state)
+	}
+}
+
+// Ensure idle shards are closed and reopened on their next access.
+func TestStore_CloseIdleShards(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store_test")
+	if err != nil {
+		t.Fatalf("Store.Open() failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := tsdb.NewStore(filepath.Join(dir, "data"))
+	s.EngineOptions.Config.WALDir = filepath.Join(dir, "wal")
+	s.EngineOptions.Config.ShardIdleTimeout = toml.Duration(10 * time.Millisecond)
+	if err := s.Open(); err != nil {
+		t.Fatalf("Store.Open() failed: %v", err)
+	}
+	defer s.Close()
+
+	if err := s.CreateShard("foo", "default", 1); err != nil {
+		t.Fatalf("error creating shard: %v", err)
+	}
+
+	// A shard with an open transaction stays open.
+	tx, err := s.Shard(1).ReadOnlyTx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	if state := s.ShardState(1); state != tsdb.ShardStateOpen {
+		t.Fatalf("unexpected shard state: %s", state)
+	}
+	tx.Rollback()
+
+	for i := 0; s.ShardState(1) != tsdb.ShardStateClosed; i++ {
+		if i == 100 {
+			t.Fatal("timed out waiting for shard to close")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	p, _ := models.ParsePoints([]byte("cpu val=1 10"))
+	if err := s.WriteToShard(1, p); err != nil {
+		t.Fatalf("error writing to shard: %v", err)
+	} else if state := s.ShardState(1); state != tsdb.ShardStateOpen {
+		t.Fatalf("unexpected shard state: %s", state)
+	}
+}
+
+// Ensure shards can be written to while idle shards are being closed.
+func TestStore_CloseIdleShards_Write(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store_test")
+	if err != nil {
+		t.Fatalf("Store.Open() failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := tsdb.NewStore(filepath.Join(dir, "data"))
+	s.EngineOptions.Config.WALDir = filepath.Join(dir, "wal")
+	s.EngineOptions.Config.ShardIdleTimeout = toml.Duration(time.Nanosecond)
+	if err := s.Open(); err != nil {
+		t.Fatalf("Store.Open() failed: %v", err)
+	}
+	defer s.Close()
+
+	if err := s.CreateShard("foo", "default", 1); err != nil {
+		t.Fatalf("error creating shard: %v", err)
+	}
+
+	for i := 0; i < 50; i++ {
+		p, _ := models.ParsePoints([]byte("cpu val=1 " + strconv.Itoa(i)))
+		if err := s.WriteToShard(1, p); err != nil {
+			t.Fatalf("error writing to shard: %v", err)
+		} else if _, err := s.Shard(1).SeriesCount(); err != nil {
+			t.Fatalf("error counting series: %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
 func BenchmarkStoreOpen_200KSeries_100Shards(b *testing.B) { benchmarkStoreOpen(b, 64, 5, 5, 1, 100) }
 
 func benchmarkStoreOpen(b *testing.B, mCnt, tkCnt, tvCnt, pntCnt, shardCnt int) {
@@ -344,3 +541,46 @@ func chunkedWriteStoreShard(store *tsdb.Store, shardID int, points []models.Poin
 		end += chunkSz
 	}
 }
+
+// Ensure writes to a read-only database's shards are rejected.
+func TestStore_SetDatabaseReadOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store_test")
+	if err != nil {
+		t.Fatalf("Store.Open() failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := tsdb.NewStore(dir)
+	s.EngineOptions.Config.WALDir = filepath.Join(dir, "wal")
+	if err := s.Open(); err != nil {
+		t.Fatalf("Store.Open() failed: %v", err)
+	}
+	defer s.Close()
+
+	if err := s.CreateShard("foo", "default", 1); err != nil {
+		t.Fatalf("error creating shard: %v", err)
+	}
+	s.SetDatabaseReadOnly("foo", true)
+
+	// Shards created after the database is marked read-only reject writes too.
+	if err := s.CreateShard("foo", "default", 2); err != nil {
+		t.Fatalf("error creating shard: %v", err)
+	} else if err := s.CreateShard("bar", "default", 3); err != nil {
+		t.Fatalf("error creating shard: %v", err)
+	}
+
+	p, _ := models.ParsePoints([]byte("cpu val=1"))
+	for _, id := range []uint64{1, 2} {
+		if err := s.WriteToShard(id, p); err != influxdb.ErrDatabaseReadOnly {
+			t.Fatalf("unexpected error writing to shard %d: %v", id, err)
+		}
+	}
+	if err := s.WriteToShard(3, p); err != nil {
+		t.Fatalf("error writing to shard: %v", err)
+	}
+
+	s.SetDatabaseReadOnly("foo", false)
+	if err := s.WriteToShard(1, p); err != nil {
+		t.Fatalf("error writing to shard: %v", err)
+	}
+}